@@ -0,0 +1,431 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// alignChunkSentences is about the number of source sentences the LLM
+// aligns at a time with --method llm.
+const alignChunkSentences = 30
+
+// alignBead is a group of consecutive source sentences aligned with a group
+// of consecutive target sentences, as the number of sentences of each.
+type alignBead struct {
+	source, target int
+}
+
+// alignBeadPriors are the beads the length-based alignment is made of, with
+// their probability in translated texts as measured by Gale and Church:
+// most sentences are translated by one sentence, some are split or merged
+// and a few are left out or added.
+var alignBeadPriors = []struct {
+	bead  alignBead
+	prior float64
+}{
+	{alignBead{1, 1}, 0.89},
+	{alignBead{1, 0}, 0.0099 / 2},
+	{alignBead{0, 1}, 0.0099 / 2},
+	{alignBead{2, 1}, 0.089 / 2},
+	{alignBead{1, 2}, 0.089 / 2},
+	{alignBead{2, 2}, 0.011},
+}
+
+// alignGroup is a group of the --method llm response, the 1-based numbers
+// of its source and target sentences.
+type alignGroup struct {
+	Source      []int `json:"source"`
+	Translation []int `json:"translation"`
+}
+
+var alignCmd = &cobra.Command{
+	Use:   "align <source> <target>",
+	Short: "Align the sentences of a text with the ones of its existing translation",
+	Long: `The "align" command reads a text and an existing translation of it, such as a book and its published translation, and pairs every sentence of the text with the sentences translating it, producing the same results as "analise": sections of the source with their translation. The results can seed "flashcards", "cloze" and "srs" decks, or the --memory of analise.
+With --method length (the default) the sentences are aligned without any request, by their lengths as in the Gale-Church algorithm: long sentences are translated by long ones, and the alignment that best fits their lengths is found, allowing sentences to be split, merged, left out or added. With --method llm the LLM then refines that alignment, a few dozen sentences at a time, pairing the sentences by their meaning; it is slower but copes with translations that stray from the text.
+Sentences the translation left out, or that it added, are joined to the section before them. The languages of the texts are detected with the --detector unless given with --source-language and --translation-language.
+The results are written in JSON or any other --format of analise.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAlign,
+}
+
+func runAlign(cmd *cobra.Command, args []string) error {
+	method, err := cmd.Flags().GetString("method")
+	if err != nil {
+		return fmt.Errorf("retrieving method flag: %w", err)
+	}
+	if method != "length" && method != "llm" {
+		return fmt.Errorf("unsupported method %q (expected length or llm)", method)
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	render, buffered := renderers[format]
+	if !buffered && format != "ndjson" {
+		return fmt.Errorf("unsupported format %q (expected one of %s)", format, strings.Join(supportedFormats(), ", "))
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+	if outputPath != "" {
+		if err := checkOutputPath(outputPath, force); err != nil {
+			return err
+		}
+	}
+
+	sourcePath, targetPath := args[0], args[1]
+	sourceText, _, err := readAnalysisFile(sourcePath, "")
+	if err != nil {
+		return fmt.Errorf("reading source file: %w", err)
+	}
+	targetText, _, err := readAnalysisFile(targetPath, "")
+	if err != nil {
+		return fmt.Errorf("reading target file: %w", err)
+	}
+	source, target := splitSentences(sourceText), splitSentences(targetText)
+	if len(source) == 0 {
+		return fmt.Errorf("%s: no sentences to align", sourcePath)
+	}
+	if len(target) == 0 {
+		return fmt.Errorf("%s: no sentences to align", targetPath)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(opts.translationLanguages) > 1 {
+		return errors.New("align requires a single --translation-language")
+	}
+
+	detectTarget := !cmd.Flags().Changed("translation-language")
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	// The length-based alignment of texts of known languages makes no
+	// request.
+	if method == "llm" || opts.sourceLanguage == "" || detectTarget {
+		warmUp(ctx, opts)
+	}
+
+	analysis, err := alignTexts(ctx, source, target, method, opts, detectTarget)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+	analysis.File = sourcePath
+
+	var b bytes.Buffer
+	if buffered {
+		err = render(&b, []Analysis{analysis}, false)
+	} else {
+		encoder := json.NewEncoder(&b)
+		for _, item := range analysis.Results {
+			if err = encoder.Encode(ndjsonItem{File: sourcePath, ResultItem: item}); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d aligned sections to %s\n", len(analysis.Results), outputPath)
+	return nil
+}
+
+// alignTexts aligns the source sentences with the target ones by the
+// method, detecting the language of the source unless it was given and the
+// one of the target when detectTarget is set.
+func alignTexts(ctx context.Context, source, target []string, method string, opts analysisOptions, detectTarget bool) (Analysis, error) {
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		sourceLanguage, err := detectLanguage(ctx, strings.Join(source, " "), opts)
+		if err != nil {
+			return Analysis{}, err
+		}
+		opts.sourceLanguage = sourceLanguage
+	}
+	if detectTarget {
+		targetLanguage, err := detectLanguage(ctx, strings.Join(target, " "), opts)
+		if err != nil {
+			return Analysis{}, err
+		}
+		opts.translationLanguage = targetLanguage
+	}
+
+	beads := alignByLength(source, target)
+	if method == "llm" {
+		var err error
+		if beads, err = refineAlignment(ctx, source, target, beads, opts); err != nil {
+			return Analysis{}, err
+		}
+	}
+
+	analysis := Analysis{SourceLanguage: opts.sourceLanguage, TranslationLanguage: opts.translationLanguage, Results: alignedResults(source, target, beads)}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	analysis.Usage = &usage
+	return analysis, nil
+}
+
+// alignByLength aligns the source sentences with the target ones by their
+// lengths, after Gale and Church: every bead costs the unlikelihood of its
+// kind and of the difference between the lengths of its sentences, and the
+// cheapest sequence of beads covering both texts is found by dynamic
+// programming. Only the alignments close to the diagonal are considered,
+// which keeps whole books tractable.
+func alignByLength(source, target []string) []alignBead {
+	sourceLengths, targetLengths := alignLengths(source), alignLengths(target)
+	n, m := len(source), len(target)
+	// ratio is the number of target characters per source character, which
+	// accounts for the scripts and the wordiness of the languages.
+	ratio := float64(max(targetLengths[n], 1)) / float64(max(sourceLengths[n], 1))
+
+	band := max(20, n/10, m/10) + absInt(n-m)
+	lo := func(i int) int { return max(0, i*m/n-band) }
+	hi := func(i int) int { return min(m, i*m/n+band) }
+
+	costs := make([][]float64, n+1)
+	back := make([][]int8, n+1)
+	for i := 0; i <= n; i++ {
+		width := hi(i) - lo(i) + 1
+		costs[i], back[i] = make([]float64, width), make([]int8, width)
+		for j := lo(i); j <= hi(i); j++ {
+			best, bestBead := math.Inf(1), int8(-1)
+			if i == 0 && j == 0 {
+				best = 0
+			}
+			for k, kind := range alignBeadPriors {
+				pi, pj := i-kind.bead.source, j-kind.bead.target
+				if pi < 0 || pj < 0 || pj < lo(pi) || pj > hi(pi) {
+					continue
+				}
+				previous := costs[pi][pj-lo(pi)]
+				if math.IsInf(previous, 1) {
+					continue
+				}
+				cost := previous + alignBeadCost(sourceLengths[i]-sourceLengths[pi], targetLengths[j]-targetLengths[pj], ratio, kind.prior)
+				if cost < best {
+					best, bestBead = cost, int8(k)
+				}
+			}
+			costs[i][j-lo(i)], back[i][j-lo(i)] = best, bestBead
+		}
+	}
+
+	var beads []alignBead
+	for i, j := n, m; i > 0 || j > 0; {
+		k := back[i][j-lo(i)]
+		if k < 0 {
+			// The band missed the end of the texts, which is only possible
+			// with texts of wildly different lengths: pair what is left.
+			beads = append(beads, alignBead{i, j})
+			break
+		}
+		bead := alignBeadPriors[k].bead
+		beads = append(beads, bead)
+		i, j = i-bead.source, j-bead.target
+	}
+	for i, j := 0, len(beads)-1; i < j; i, j = i+1, j-1 {
+		beads[i], beads[j] = beads[j], beads[i]
+	}
+	return beads
+}
+
+// alignLengths returns the cumulative lengths of sentences, in characters
+// other than spaces: lengths[i] is the length of the first i sentences.
+func alignLengths(sentences []string) []int {
+	lengths := make([]int, len(sentences)+1)
+	for i, sentence := range sentences {
+		length := 0
+		for _, r := range sentence {
+			if !unicode.IsSpace(r) {
+				length++
+			}
+		}
+		lengths[i+1] = lengths[i] + length
+	}
+	return lengths
+}
+
+// alignBeadCost returns the cost of a bead of sentences of sourceLength and
+// targetLength characters: the negative log of the probability of its kind
+// and of their lengths differing as much, the target being expected to be
+// ratio times as long with a variance growing with the length.
+func alignBeadCost(sourceLength, targetLength int, ratio, prior float64) float64 {
+	const variance = 6.8
+	cost := -math.Log(prior)
+	mean := (float64(sourceLength) + float64(targetLength)/ratio) / 2
+	if mean == 0 {
+		return cost
+	}
+	z := math.Abs(float64(targetLength)-ratio*float64(sourceLength)) / math.Sqrt(variance*ratio*mean)
+	// The probability of a difference at least as large, floored so that
+	// the costs stay finite.
+	return cost - math.Log(max(math.Erfc(z/math.Sqrt2), 1e-100))
+}
+
+// refineAlignment has the LLM realign the sentences, in chunks of about
+// alignChunkSentences source sentences cut at the beads of the length-based
+// alignment. A chunk whose response doesn't cover its sentences in order
+// keeps the beads of the length-based alignment.
+func refineAlignment(ctx context.Context, source, target []string, beads []alignBead, opts analysisOptions) ([]alignBead, error) {
+	var refined []alignBead
+	for start, i, j := 0, 0, 0; start < len(beads); {
+		end, sourceEnd, targetEnd := start, i, j
+		for end < len(beads) && (sourceEnd-i < alignChunkSentences || end == start) {
+			sourceEnd, targetEnd = sourceEnd+beads[end].source, targetEnd+beads[end].target
+			end++
+		}
+		chunk, err := alignChunk(ctx, source[i:sourceEnd], target[j:targetEnd], opts)
+		if err != nil {
+			return nil, err
+		}
+		if chunk == nil {
+			verbosef("Keeping the length-based alignment of source sentences %d to %d, which the LLM didn't align", i+1, sourceEnd)
+			chunk = beads[start:end]
+		}
+		refined = append(refined, chunk...)
+		start, i, j = end, sourceEnd, targetEnd
+	}
+	return refined, nil
+}
+
+// alignChunk asks the LLM to align the source sentences with the target
+// ones, returning nil when its groups don't cover them in order.
+func alignChunk(ctx context.Context, source, target []string, opts analysisOptions) ([]alignBead, error) {
+	if len(source) == 0 || len(target) == 0 {
+		return []alignBead{{len(source), len(target)}}, nil
+	}
+	var b strings.Builder
+	b.WriteString("Source:\n")
+	for i, sentence := range source {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, singleLine(sentence))
+	}
+	b.WriteString("\nTranslation:\n")
+	for i, sentence := range target {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, singleLine(sentence))
+	}
+	req, err := renderPrompt(defaultAlignPrompt, opts.segmentModel, promptData{Text: strings.TrimSuffix(b.String(), "\n"), Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return nil, err
+	}
+	var groups []alignGroup
+	if err := generateJSON(ctx, opts, req, &groups); err != nil {
+		return nil, fmt.Errorf("aligning sentences: %w", err)
+	}
+
+	var beads []alignBead
+	nextSource, nextTarget := 1, 1
+	consecutive := func(numbers []int, next *int) bool {
+		for _, number := range numbers {
+			if number != *next {
+				return false
+			}
+			*next++
+		}
+		return true
+	}
+	for _, group := range groups {
+		if len(group.Source) == 0 && len(group.Translation) == 0 {
+			continue
+		}
+		if !consecutive(group.Source, &nextSource) || !consecutive(group.Translation, &nextTarget) {
+			return nil, nil
+		}
+		beads = append(beads, alignBead{len(group.Source), len(group.Translation)})
+	}
+	if nextSource != len(source)+1 || nextTarget != len(target)+1 {
+		return nil, nil
+	}
+	return beads, nil
+}
+
+// alignedResults returns the sections of the beads, the source sentences of
+// each with the target sentences translating them. The sentences of the
+// beads missing one side are joined to the section before them, or after
+// them at the start of the texts.
+func alignedResults(source, target []string, beads []alignBead) []ResultItem {
+	var results []ResultItem
+	var pendingSource, pendingTarget []string
+	i, j := 0, 0
+	for _, bead := range beads {
+		sources, targets := source[i:i+bead.source], target[j:j+bead.target]
+		i, j = i+bead.source, j+bead.target
+		if len(sources) == 0 || len(targets) == 0 {
+			if len(results) > 0 {
+				last := &results[len(results)-1]
+				last.Source = joinSentences(append([]string{last.Source}, sources...))
+				last.Translation = joinSentences(append([]string{last.Translation}, targets...))
+				continue
+			}
+			pendingSource, pendingTarget = append(pendingSource, sources...), append(pendingTarget, targets...)
+			continue
+		}
+		results = append(results, ResultItem{
+			Source:      joinSentences(append(pendingSource, sources...)),
+			Translation: joinSentences(append(pendingTarget, targets...)),
+		})
+		pendingSource, pendingTarget = nil, nil
+	}
+	if len(pendingSource) > 0 || len(pendingTarget) > 0 {
+		results = append(results, ResultItem{Source: joinSentences(pendingSource), Translation: joinSentences(pendingTarget)})
+	}
+	return results
+}
+
+// joinSentences joins consecutive sentences with a space, except after the
+// sentences of scripts written without spaces, such as Japanese.
+func joinSentences(sentences []string) string {
+	var b strings.Builder
+	for _, sentence := range sentences {
+		if sentence == "" {
+			continue
+		}
+		if last, _ := utf8.DecodeLastRuneInString(b.String()); b.Len() > 0 && !strings.ContainsRune("。！？」』”）", last) {
+			b.WriteString(" ")
+		}
+		b.WriteString(sentence)
+	}
+	return b.String()
+}
+
+// absInt returns the absolute value of n.
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func init() {
+	addAnalysisFlags(alignCmd.Flags())
+	alignCmd.Flags().String("method", "length", "How the sentences are aligned: length, by their lengths without any request, or llm, refining that alignment by meaning")
+	alignCmd.Flags().String("format", "json", "The output format: json, for flashcards and the --memory of analise, yaml, csv, table, markdown, xliff, xlsx or ndjson")
+	alignCmd.Flags().StringP("output", "o", "", "Write the results to this file instead of stdout")
+	alignCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	rootCmd.AddCommand(alignCmd)
+}
@@ -2,182 +2,807 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"os"
+	"io"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
 	"github.com/spf13/cobra"
 )
 
-type RequestPayload struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
-}
-
-type ResponsePayload struct {
-	Response string `json:"response"`
-}
-
-type TranslationPayload struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+type ResultItem struct {
+	Source string `json:"source" yaml:"source"`
+	// Page and Paragraph locate the source in the PDF it was read from, and
+	// are only set for --file PDF documents.
+	Page      int `json:"page,omitempty" yaml:"page,omitempty"`
+	Paragraph int `json:"paragraph,omitempty" yaml:"paragraph,omitempty"`
+	// Transliteration is the source in Latin script, only set with
+	// --transliterate.
+	Transliteration string `json:"transliteration,omitempty" yaml:"transliteration,omitempty"`
+	// Synonyms holds the synonyms and antonyms of the key words of the
+	// source, only set with --synonyms.
+	Synonyms []wordRelations `json:"synonyms,omitempty" yaml:"synonyms,omitempty"`
+	// Level is the CEFR level of the source, only set with --difficulty.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// IPA is the IPA transcription of the source, only set with --ipa.
+	IPA string `json:"ipa,omitempty" yaml:"ipa,omitempty"`
+	// Audio is the path to the recording of the source, only set with
+	// --tts.
+	Audio string `json:"audio,omitempty" yaml:"audio,omitempty"`
+	// Pinyin is the source in pinyin, only set with --pinyin for Chinese.
+	Pinyin string `json:"pinyin,omitempty" yaml:"pinyin,omitempty"`
+	// Furigana is the source with the readings of its kanji, only set with
+	// --furigana for Japanese.
+	Furigana string `json:"furigana,omitempty" yaml:"furigana,omitempty"`
+	// POS is the source with the part-of-speech tag of every word, as
+	// word/TAG, and Tokens the same tags as an array; only one of them is
+	// set, depending on the --pos style.
+	POS         string        `json:"pos,omitempty" yaml:"pos,omitempty"`
+	Tokens      []taggedToken `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	Translation string        `json:"translation,omitempty" yaml:"translation,omitempty"`
+	// Translations holds the translation into each language when more than
+	// one --translation-language is given, in which case Translation is empty.
+	Translations map[string]string `json:"translations,omitempty" yaml:"translations,omitempty"`
+	// Explanation describes the grammar of the source, only set with
+	// --explain.
+	Explanation string `json:"explanation,omitempty" yaml:"explanation,omitempty"`
+	// GlossaryMismatches lists the --glossary terms of the section that
+	// were not translated as required.
+	GlossaryMismatches []string `json:"glossary_mismatches,omitempty" yaml:"glossary_mismatches,omitempty"`
+	// The fields below are only set with --verify.
+	BackTranslation string   `json:"back_translation,omitempty" yaml:"back_translation,omitempty"`
+	Similarity      *float64 `json:"similarity,omitempty" yaml:"similarity,omitempty"`
+	Divergent       bool     `json:"divergent,omitempty" yaml:"divergent,omitempty"`
+	// Origin is where the section was taken from, only set by merge.
+	Origin *resultOrigin `json:"origin,omitempty" yaml:"origin,omitempty"`
 }
 
-type TranslationResponse struct {
-	Translation string `json:"response"`
+// Analysis holds the results of analyzing a single input, along with the
+// file it was read from when the text came from --file.
+type Analysis struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Transcription is the text transcribed from the --audio file, or read
+	// from the --image, which the results are the sections of.
+	Transcription  string `json:"transcription,omitempty" yaml:"transcription,omitempty"`
+	SourceLanguage string `json:"source_language" yaml:"source_language"`
+	// TranslationLanguage is the language of ResultItem.Translation, and
+	// TranslationLanguages lists the languages of ResultItem.Translations, in
+	// the order they were requested, when there is more than one.
+	TranslationLanguage  string   `json:"translation_language,omitempty" yaml:"translation_language,omitempty"`
+	TranslationLanguages []string `json:"translation_languages,omitempty" yaml:"translation_languages,omitempty"`
+	// Provider and Host name the provider of the failover chain that
+	// finished the analysis, and are only set when there is a chain.
+	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+	// TransliterationScheme names the scheme of ResultItem.Transliteration.
+	TransliterationScheme string `json:"transliteration_scheme,omitempty" yaml:"transliteration_scheme,omitempty"`
+	// Usage counts the tokens of the requests made for the analysis, and
+	// their estimated cost.
+	Usage *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+	// Memory counts the sections found in the --memory, and is only set
+	// with one.
+	Memory  *memoryStats `json:"memory,omitempty" yaml:"memory,omitempty"`
+	Results []ResultItem `json:"results" yaml:"results"`
 }
 
-type ResultItem struct {
-	Source      string `json:"source"`
-	Translation string `json:"translation"`
+// ndjsonItem is a single line of --format ndjson output.
+type ndjsonItem struct {
+	File string `json:"file,omitempty"`
+	ResultItem
 }
 
 var analiseCmd = &cobra.Command{
 	Use:   "analise [text]",
 	Short: "Analyze and output the words in JSON format",
-	Long: `The "analise" command takes a string of text as an argument, sends it to an Ollama instance for processing, using the llama3 model, and outputs the result in JSON format.
-Optionally, you can specify the Ollama instance URL and the translation language locale.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		text := args[0]
+	Long: `The "analise" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), sends it to an Ollama instance for processing, using the llama3 model by default, and outputs the result in JSON format (or the one chosen with --format).
+Optionally, you can specify the Ollama instance URL, the translation language locale and the models used for segmentation and translation.
+Use --file (repeatable) to analyze text files instead; each file is analyzed separately and reported with its name, or --clipboard to analyze the text on the system clipboard.
+With --copy the output is also placed on the clipboard, or only the text of the translations with --copy=translation. The clipboard is read and written with pbpaste and pbcopy on macOS, PowerShell and clip on Windows, and wl-clipboard, xclip or xsel elsewhere.
+Use --template to render the output with a Go text/template file instead, such as an HTML snippet, a LaTeX table or a custom report. It is executed with .Analyses, the analyses as in the JSON output (.File, .SourceLanguage and .Results, each with .Source, .Translation and .Translations by language), and the metadata of the run: .Provider, .Model, .Translator, .TranslationLanguages and .StartedAt. Besides the builtin functions of Go templates, such as html and printf, templates have join, upper, lower, trim, replace, json and latex, escaping text for LaTeX.
+With --format xlsx the results are written as an Excel workbook, usually to an --output file such as results.xlsx, with the source and translation side by side and every column as wide as its contents; multiple --translation-language values get a sheet each.
+Use --memory (repeatable) to reuse the translations of earlier runs, from TMX files such as the ones of "export tmx" or from JSON results: every section found in the memory, exactly or as a fuzzy match scoring at least --memory-threshold, takes its translation instead of being sent to the LLM, and the sections translated during the run join the memory, so that repeated sections are translated the same way across documents. The results count the exact and fuzzy matches and the misses under memory.
+Use --resume with the output of an earlier run, such as the NDJSON printed by a run of a long document that failed halfway (the --output of a failed --format ndjson run keeps the results written before the failure), to only translate the sections it is missing: every section whose source it has already translated, into the same --translation-language, is reused with its translation and enrichments, and only gets the enrichments requested since. Other sections, new or changed, are translated as usual.
+With --validate-output the results are checked against the versioned JSON Schema of the output, printed by the "schema" command, before they are written in any format, and the command fails when they don't match it.
+An --output file ending with .db, .sqlite or .sqlite3 is written as a SQLite database instead, with a table of the run (when it was made, its provider and model), of its analyses, of their sections and of their translations with their language pairs, to be queried with SQL; "export sqlite" gathers the JSON results of many runs into a single database.
+Use --audio (repeatable) to analyze recordings: each one is transcribed by a Whisper model, through --whisper-host, and its transcription is analyzed and included in the results. The host is the inference endpoint of a whisper.cpp server (http://localhost:8080/inference by default), or the transcription endpoint or API root of OpenAI and OpenAI-compatible servers, with the API key in STARTER_GO_CLI_WHISPER_API_KEY or stored by "auth set whisper".
+Use --image (repeatable) to analyze the text in photos and scans, such as a menu or a sign: a vision model of the provider, --vision-model (llava by default, as pulled by Ollama), reads the text of every image, which is analyzed and included in the results like a transcription. Vision models are available with the ollama, openai, gemini and bedrock (anthropic models) providers.
+With --tts every section is read aloud by a text-to-speech backend, piper (the default when given without a value) through the HTTP server of Piper, or openai through the speech endpoint of OpenAI or an OpenAI-compatible server at --tts-host, and its recording is written to --tts-dir with its path in the results. Recordings are reused by later runs reading the same text with the same voice; "flashcards --apkg" bundles them into the deck as its media.
+PDF documents (.pdf) given to --file have the text of their pages extracted, and every result gives the page and the paragraph of the page its source starts in. Use --pages to only analyze some of their pages, such as --pages 1-3,5; scanned documents without any text need OCR first.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAnalise,
+}
 
-		llmHost, err := cmd.Flags().GetString("llm-host")
-		if err != nil {
-			fmt.Println("Error retrieving llm-host flag:", err)
-			os.Exit(1)
+func runAnalise(cmd *cobra.Command, args []string) error {
+	files, err := cmd.Flags().GetStringArray("file")
+	if err != nil {
+		return fmt.Errorf("retrieving file flag: %w", err)
+	}
+	if len(files) > 0 && len(args) > 0 {
+		return errors.New("the text argument cannot be combined with --file")
+	}
+	audioFiles, err := cmd.Flags().GetStringArray("audio")
+	if err != nil {
+		return fmt.Errorf("retrieving audio flag: %w", err)
+	}
+	if len(audioFiles) > 0 && (len(files) > 0 || len(args) > 0) {
+		return errors.New("--audio cannot be combined with the text argument or --file")
+	}
+	images, err := cmd.Flags().GetStringArray("image")
+	if err != nil {
+		return fmt.Errorf("retrieving image flag: %w", err)
+	}
+	if len(images) > 0 && (len(files) > 0 || len(args) > 0 || len(audioFiles) > 0) {
+		return errors.New("--image cannot be combined with the text argument, --file or --audio")
+	}
+	pages, err := cmd.Flags().GetString("pages")
+	if err != nil {
+		return fmt.Errorf("retrieving pages flag: %w", err)
+	}
+	if pages != "" && len(files) == 0 {
+		return errors.New("--pages requires a --file PDF document")
+	}
+	clipboard, err := cmd.Flags().GetBool("clipboard")
+	if err != nil {
+		return fmt.Errorf("retrieving clipboard flag: %w", err)
+	}
+	if clipboard && (len(files) > 0 || len(args) > 0 || len(audioFiles) > 0 || len(images) > 0) {
+		return errors.New("--clipboard cannot be combined with the text argument, --file, --audio or --image")
+	}
+	copyMode, err := cmd.Flags().GetString("copy")
+	if err != nil {
+		return fmt.Errorf("retrieving copy flag: %w", err)
+	}
+	if err := checkCopyMode(copyMode); err != nil {
+		return err
+	}
+
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	render, buffered := renderers[format]
+	if !buffered && format != "ndjson" {
+		return fmt.Errorf("unsupported format %q (expected one of %s)", format, strings.Join(supportedFormats(), ", "))
+	}
+	database := isSQLitePath(outputPath)
+	if database && cmd.Flags().Changed("format") {
+		return errors.New("--format cannot be combined with an --output database")
+	}
+	if database && copyMode == "output" {
+		return errors.New("--copy=output cannot be combined with an --output database")
+	}
+	templatePath, err := cmd.Flags().GetString("template")
+	if err != nil {
+		return fmt.Errorf("retrieving template flag: %w", err)
+	}
+	var outputTemplate *template.Template
+	if templatePath != "" {
+		if cmd.Flags().Changed("format") || database {
+			return errors.New("--template cannot be combined with --format or an --output database")
 		}
-		if llmHost == "" {
-			llmHost = os.Getenv("STARTER_GO_CLI_LLM_HOST")
-			if llmHost == "" {
-				llmHost = "http://localhost:11434/api/generate"
-				fmt.Println("Using default Ollama host:", llmHost)
-			}
+		if outputTemplate, err = loadOutputTemplate(templatePath); err != nil {
+			return err
 		}
+	}
+	validateOutput, err := cmd.Flags().GetBool("validate-output")
+	if err != nil {
+		return fmt.Errorf("retrieving validate-output flag: %w", err)
+	}
+	var validator *schemaValidator
+	if validateOutput {
+		if validator, err = newResultsValidator(); err != nil {
+			return err
+		}
+	}
+	if format == "xlsx" && copyMode == "output" {
+		return errors.New("--copy=output cannot be combined with --format xlsx, whose workbooks are binary")
+	}
 
-		translationLanguage, err := cmd.Flags().GetString("translation-language")
+	var text string
+	switch {
+	case clipboard:
+		text, err = readClipboard()
 		if err != nil {
-			fmt.Println("Error retrieving language flag:", err)
-			os.Exit(1)
+			return fmt.Errorf("reading the clipboard: %w", err)
 		}
-		if translationLanguage == "" {
-			translationLanguage = os.Getenv("STARTER_GO_CLI_TRANSLATION_LANGUAGE")
-			if translationLanguage == "" {
-				translationLanguage = "en-US"
-				fmt.Println("Using default translation language:", translationLanguage)
-			}
+	case len(files) == 0 && len(audioFiles) == 0 && len(images) == 0:
+		text, err = readInputText(args)
+		if err != nil {
+			return fmt.Errorf("reading input text: %w", err)
 		}
+	}
 
-		prompt := fmt.Sprintf("Divide the text below into small sections, each representing a particular thought or idea. Use grammar as a basis and avoid creating a section with a single word. You can break a phrase into subject and predicate.\n\nExample text:\n\nHey, kannst du mir den heutigen Mittagsmenü schicken? Ich bin gerade total eingebunden bei der Arbeit und schaffe es nicht reinzukommen.\n\nExample output:\n\n[\n    \"Hey\",\n    \"kannst du mir\",\n    \"den heutigen Mittagsmenü schicken?\",\n    \"Ich bin gerade\",\n    \"total eingebunden\",\n    \"bei der Arbeit\",\n    \"und\",\n    \"schaffe es nicht reinzukommen.\"\n]\n\nActual text:\n\n%s\n\nActual output:\n\nProvide only the JSON array as the output without any additional text or explanation.", text)
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if opts.speech, err = newSpeechOutput(cmd); err != nil {
+		return err
+	}
+	if opts.resume, err = newResumedResults(cmd, opts); err != nil {
+		return err
+	}
 
-		payload := RequestPayload{
-			Model:  "llama3",
-			Prompt: prompt,
-			Stream: false,
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return fmt.Errorf("retrieving dry-run flag: %w", err)
+	}
+	if dryRun && len(audioFiles) > 0 {
+		return errors.New("--dry-run cannot be used with --audio, whose text is only known once it is transcribed")
+	}
+	if dryRun && len(images) > 0 {
+		return errors.New("--dry-run cannot be used with --image, whose text is only known once it is read")
+	}
+	if dryRun {
+		if len(files) == 0 {
+			return printDryRun(cmd.OutOrStdout(), "", text, opts)
 		}
+		for _, file := range files {
+			fileText, _, err := readAnalysisFile(file, pages)
+			if err != nil {
+				return fmt.Errorf("reading input file: %w", err)
+			}
+			if err := printDryRun(cmd.OutOrStdout(), file, fileText, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-		payloadBytes, err := json.Marshal(payload)
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+	started := time.Now()
+
+	out := cmd.OutOrStdout()
+	var outputFile *atomicFile
+	// streamed counts the NDJSON results written so far, and committed is
+	// set once the output file is in place.
+	streamed, committed := 0, false
+	if outputPath != "" {
+		outputFile, err = createAtomicFile(outputPath, force)
 		if err != nil {
-			fmt.Println("Error marshalling request payload:", err)
-			os.Exit(1)
+			return fmt.Errorf("creating output file: %w", err)
 		}
+		// The results streamed before a failure are kept for --resume; any
+		// other partially written output is discarded.
+		defer func() {
+			if committed {
+				return
+			}
+			if streamed == 0 {
+				outputFile.Abort()
+				return
+			}
+			if err := outputFile.Commit(); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: could not keep the partial output: %v\n", err)
+				return
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Kept the %d results written to %s before the failure, to be reused with --resume\n", streamed, outputPath)
+		}()
+		out = outputFile
+	}
+	// The output placed on the clipboard is the one printed, streamed or
+	// not.
+	var copied bytes.Buffer
+	if copyMode == "output" {
+		out = io.MultiWriter(out, &copied)
+	}
 
-		resp, err := http.Post(llmHost, "application/json", bytes.NewBuffer(payloadBytes))
-		if err != nil {
-			fmt.Println("Error making HTTP request:", err)
-			os.Exit(1)
+	// emitFor returns the callback streaming results of file as NDJSON,
+	// or nil when results are only printed once the analysis is done.
+	encoder := json.NewEncoder(out)
+	emitFor := func(file string) func(ResultItem) error {
+		if format != "ndjson" {
+			return nil
+		}
+		return func(item ResultItem) error {
+			if validator != nil {
+				if err := validator.validateResults(item, "#/$defs/result"); err != nil {
+					return err
+				}
+			}
+			if err := encoder.Encode(ndjsonItem{File: file, ResultItem: item}); err != nil {
+				return err
+			}
+			streamed++
+			return nil
 		}
-		defer resp.Body.Close()
+	}
 
-		body, err := ioutil.ReadAll(resp.Body)
+	var analyses []Analysis
+	switch {
+	case len(audioFiles) > 0:
+		whisper, err := newWhisper(cmd)
 		if err != nil {
-			fmt.Println("Error reading response body:", err)
-			os.Exit(1)
+			return err
 		}
+		for _, file := range audioFiles {
+			transcription, err := transcribeAudio(ctx, whisper, file, opts)
+			if err != nil {
+				return runError(ctx, opts, err)
+			}
+			analysis, err := analyzeText(ctx, transcription, opts, emitFor(file))
+			if err != nil {
+				return fmt.Errorf("analyzing %s: %w", file, runError(ctx, opts, err))
+			}
+			analysis.File = file
+			analysis.Transcription = transcription
+			analyses = append(analyses, analysis)
+		}
+	case len(images) > 0:
+		model, err := visionModel(cmd)
+		if err != nil {
+			return err
+		}
+		for _, file := range images {
+			imageText, err := extractImageText(ctx, file, model, opts)
+			if err != nil {
+				return runError(ctx, opts, err)
+			}
+			analysis, err := analyzeText(ctx, imageText, opts, emitFor(file))
+			if err != nil {
+				return fmt.Errorf("analyzing %s: %w", file, runError(ctx, opts, err))
+			}
+			analysis.File = file
+			analysis.Transcription = imageText
+			analyses = append(analyses, analysis)
+		}
+	case len(files) == 0:
+		analysis, err := analyzeText(ctx, text, opts, emitFor(""))
+		if err != nil {
+			return runError(ctx, opts, err)
+		}
+		analyses = append(analyses, analysis)
+	default:
+		for _, file := range files {
+			fileText, paragraphs, err := readAnalysisFile(file, pages)
+			if err != nil {
+				return fmt.Errorf("reading input file: %w", err)
+			}
 
-		if resp.StatusCode != http.StatusOK {
-			fmt.Printf("Error: received status code %d\n", resp.StatusCode)
-			os.Exit(1)
+			// The results of PDF documents are located on their page, both
+			// when streamed and once the analysis is done.
+			emit := emitFor(file)
+			if emit != nil && paragraphs != nil {
+				locator, next := newParagraphLocator(paragraphs), emit
+				emit = func(item ResultItem) error {
+					locator.locate(&item)
+					return next(item)
+				}
+			}
+			analysis, err := analyzeText(ctx, fileText, opts, emit)
+			if err != nil {
+				return fmt.Errorf("analyzing %s: %w", file, runError(ctx, opts, err))
+			}
+			if paragraphs != nil {
+				locator := newParagraphLocator(paragraphs)
+				for i := range analysis.Results {
+					locator.locate(&analysis.Results[i])
+				}
+			}
+			analysis.File = file
+			analyses = append(analyses, analysis)
 		}
+	}
 
-		var responsePayload ResponsePayload
-		err = json.Unmarshal(body, &responsePayload)
+	if opts.resume != nil {
+		verbosef("Reused %d sections of the --resume results", opts.resume.Reused())
+	}
+	withFiles := len(files) > 0 || len(audioFiles) > 0 || len(images) > 0
+	if validator != nil {
+		if err := validator.validateResults(outputDocument(analyses, withFiles), ""); err != nil {
+			return err
+		}
+	}
+	translator, _, _ := strings.Cut(opts.translatorKey, " ")
+	switch {
+	case database:
+		data, err := writeResultsDB([]resultsRun{{
+			CreatedAt:  started,
+			Provider:   opts.providerName,
+			Model:      opts.translateModel,
+			Translator: translator,
+			Analyses:   analyses,
+		}})
 		if err != nil {
-			fmt.Println("Error parsing JSON response:", err)
-			os.Exit(1)
+			return fmt.Errorf("writing results: %w", err)
+		}
+		if _, err := out.Write(data); err != nil {
+			return fmt.Errorf("writing results: %w", err)
+		}
+	case outputTemplate != nil:
+		data := outputTemplateData{
+			Analyses:             analyses,
+			Provider:             opts.providerName,
+			Model:                opts.translateModel,
+			Translator:           translator,
+			TranslationLanguages: opts.translationLanguages,
+			StartedAt:            started,
+		}
+		if err := outputTemplate.Execute(out, data); err != nil {
+			return fmt.Errorf("rendering output template: %w", err)
+		}
+	case buffered:
+		if err := render(out, analyses, withFiles); err != nil {
+			return fmt.Errorf("writing results: %w", err)
 		}
+	}
 
-		var sections []string
-		err = json.Unmarshal([]byte(responsePayload.Response), &sections)
-		if err != nil {
-			fmt.Println("Error parsing response array:", err)
-			os.Exit(1)
+	if outputFile != nil {
+		committed = true
+		if err := outputFile.Commit(); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
+		}
+	}
+	if copyMode != "" {
+		if copyMode == "translation" {
+			copied.WriteString(translationText(analyses))
+		}
+		if err := writeClipboard(copied.String()); err != nil {
+			return fmt.Errorf("copying to the clipboard: %w", err)
 		}
+	}
+	return nil
+}
 
-		var results []ResultItem
+// analyzeText detects the language of text unless it was given, divides text
+// into sections and translates each one of them. When emit is not nil it is
+// called with every result as soon as it is ready.
+func analyzeText(ctx context.Context, text string, opts analysisOptions, emit func(ResultItem) error) (Analysis, error) {
+	usageBefore := opts.usage.Snapshot()
+	var memoryBefore memoryStats
+	if opts.memory != nil {
+		memoryBefore = opts.memory.Snapshot()
+	}
 
-		for _, section := range sections {
-			translationPrompt := fmt.Sprintf("Translate the following text to %s:\n\n%s\n\nProvide only the translation without any additional text or explanation.", translationLanguage, section)
-			translationPayload := TranslationPayload{
-				Model:  "llama3",
-				Prompt: translationPrompt,
-				Stream: false,
+	// The steps of the CLI run in between the ones of the pipeline, with
+	// opts following the source language and glossary it settles on.
+	var combined []ResultItem
+	var scheme transliterationScheme
+	var furigana, pinyin bool
+	prepare := func(ctx context.Context, text string, pipelineOpts *analyze.Options) error {
+		opts.sourceLanguage = pipelineOpts.SourceLanguage
+		if len(opts.keepEntities) > 0 {
+			glossary, err := entityGlossary(ctx, text, opts)
+			if err != nil {
+				return err
 			}
-
-			translationPayloadBytes, err := json.Marshal(translationPayload)
+			opts.glossary = glossary
+			pipelineOpts.Glossary = glossary
+		}
+		if opts.transliterate {
+			var err error
+			scheme, err = resolveTransliterationScheme(opts.sourceLanguage, opts.scheme)
 			if err != nil {
-				fmt.Println("Error marshalling translation request payload:", err)
-				os.Exit(1)
+				return err
 			}
+		}
+		furigana = furiganaApplies(opts.sourceLanguage, opts)
+		pinyin = pinyinApplies(opts.sourceLanguage, opts)
+		return nil
+	}
 
-			translationResp, err := http.Post(llmHost, "application/json", bytes.NewBuffer(translationPayloadBytes))
+	// In combined mode sections come back already translated and only need
+	// to be reviewed against the glossary and verified.
+	segment := func(ctx context.Context, text string, _ analyze.Options) ([]string, error) {
+		var sections []string
+		if opts.combined {
+			var err error
+			combined, err = segmentAndTranslate(ctx, text, opts)
 			if err != nil {
-				fmt.Println("Error making HTTP request for translation:", err)
-				os.Exit(1)
+				return nil, err
 			}
-			defer translationResp.Body.Close()
-
-			translationBody, err := ioutil.ReadAll(translationResp.Body)
+			for _, item := range combined {
+				sections = append(sections, item.Source)
+			}
+		} else {
+			var err error
+			sections, err = segmentText(ctx, text, opts)
 			if err != nil {
-				fmt.Println("Error reading translation response body:", err)
-				os.Exit(1)
+				return nil, err
 			}
+		}
+		opts.progress.Start(len(sections))
+		return sections, nil
+	}
+	defer opts.progress.Finish()
 
-			if translationResp.StatusCode != http.StatusOK {
-				fmt.Printf("Error: received status code %d for translation\n", translationResp.StatusCode)
-				os.Exit(1)
+	translate := func(ctx context.Context, i int, section string, _ analyze.Options) (ResultItem, error) {
+		opts.progress.Begin(section)
+		// The sections translated by the --resume run keep their
+		// translation, and only get the enrichments they lack.
+		resumed, isResumed := opts.resume.lookup(section)
+
+		// Sections easier than --min-level are rated first so that they
+		// are never translated.
+		var level string
+		if opts.difficulty {
+			if level = resumed.Level; level == "" {
+				var err error
+				if level, err = rateDifficulty(ctx, section, opts); err != nil {
+					return ResultItem{}, err
+				}
+			}
+			if belowLevel(level, opts) {
+				opts.progress.Advance()
+				return ResultItem{Source: section, Level: level}, nil
+			}
+		}
+
+		var result ResultItem
+		var err error
+		switch {
+		case isResumed:
+			result = resumed
+		case opts.combined:
+			result, err = reviewResult(ctx, combined[i], opts)
+		default:
+			result, err = translateResult(ctx, section, opts)
+		}
+		if err != nil {
+			return ResultItem{}, err
+		}
+		if opts.difficulty {
+			result.Level = level
+		}
+		if opts.transliterate && result.Transliteration == "" {
+			result.Transliteration, err = transliterateSection(ctx, section, scheme, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+		}
+		if opts.ipa && result.IPA == "" {
+			result.IPA, err = transcribeIPA(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+		}
+		if pinyin && result.Pinyin == "" {
+			result.Pinyin, err = annotatePinyin(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+		}
+		if furigana && result.Furigana == "" {
+			result.Furigana, err = annotateFurigana(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+		}
+		if opts.speech != nil && result.Audio == "" {
+			result.Audio, err = synthesizeSection(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+		}
+		if opts.pos != "" && result.POS == "" && result.Tokens == nil {
+			tokens, err := tagPartsOfSpeech(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+			if opts.pos == "array" {
+				result.Tokens = tokens
+			} else {
+				result.POS = formatTaggedTokens(tokens)
+			}
+		}
+		if opts.synonyms && result.Synonyms == nil {
+			result.Synonyms, err = findSynonyms(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
 			}
+		}
+		if opts.explain && result.Explanation == "" {
+			result.Explanation, err = explainGrammar(ctx, section, opts)
+			if err != nil {
+				return ResultItem{}, err
+			}
+		}
+		opts.progress.Advance()
+		return result, nil
+	}
 
-			var translationResponse TranslationResponse
-			err = json.Unmarshal(translationBody, &translationResponse)
+	var done func(int, ResultItem) error
+	if emit != nil {
+		done = func(_ int, result ResultItem) error {
+			if belowLevel(result.Level, opts) {
+				return nil
+			}
+			err := opts.progress.Suspend(func() error {
+				return emit(result)
+			})
 			if err != nil {
-				fmt.Println("Error parsing translation JSON response:", err)
-				os.Exit(1)
+				return fmt.Errorf("writing result: %w", err)
 			}
+			return nil
+		}
+	}
 
-			result := ResultItem{
-				Source:      section,
-				Translation: translationResponse.Translation,
+	results, err := analyze.Run(ctx, newAnalyzer(opts), text, analyzeOptions(opts), analyze.Pipeline[ResultItem]{
+		DetectLanguage: func(ctx context.Context, text string) (string, error) {
+			return detectLanguage(ctx, text, opts)
+		},
+		Prepare:   prepare,
+		Segment:   segment,
+		Translate: translate,
+		Done:      done,
+	})
+	if err != nil {
+		return Analysis{}, err
+	}
+	if opts.minLevel > 0 {
+		studied := make([]ResultItem, 0, len(results))
+		for _, result := range results {
+			if !belowLevel(result.Level, opts) {
+				studied = append(studied, result)
 			}
-			results = append(results, result)
 		}
+		results = studied
+	}
+	analysis := Analysis{SourceLanguage: opts.sourceLanguage, Results: results}
+	if len(opts.translationLanguages) > 1 {
+		analysis.TranslationLanguages = opts.translationLanguages
+	} else {
+		analysis.TranslationLanguage = opts.translationLanguage
+	}
+	if opts.transliterate {
+		analysis.TransliterationScheme = scheme.name
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	analysis.Usage = &usage
+	if opts.memory != nil {
+		memory := opts.memory.Snapshot().sub(memoryBefore)
+		analysis.Memory = &memory
+	}
+	if chain, ok := opts.provider.(*failoverProvider); ok {
+		target := chain.active()
+		analysis.Provider, analysis.Host = target.name, llm.RedactURL(target.host)
+	}
+	return analysis, nil
+}
+
+// explainGrammar asks the LLM why section is built the way it is, in the
+// language it is translated into.
+func explainGrammar(ctx context.Context, section string, opts analysisOptions) (string, error) {
+	req, err := renderPrompt(defaultExplainPrompt, opts.translateModel, promptData{Text: section, Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return "", err
+	}
+	explanation, err := generate(ctx, opts, req)
+	if err != nil {
+		return "", fmt.Errorf("explaining %q: %w", section, err)
+	}
+	return strings.TrimSpace(explanation), nil
+}
 
-		resultsJSON, err := json.MarshalIndent(results, "", "    ")
+// translateResult translates section into every translation language,
+// applying the glossary and verification when enabled.
+func translateResult(ctx context.Context, section string, opts analysisOptions) (ResultItem, error) {
+	if len(opts.translationLanguages) > 1 {
+		translations, err := translateIntoLanguages(ctx, section, opts)
 		if err != nil {
-			fmt.Println("Error marshalling final results to JSON:", err)
-			os.Exit(1)
+			return ResultItem{}, err
 		}
+		return ResultItem{Source: section, Translations: translations}, nil
+	}
+
+	terms := analyze.GlossaryTermsIn(opts.glossary, section)
+	translation, err := translateWithMemory(ctx, section, opts.sourceLanguage, opts.translationLanguage, terms, opts)
+	if err != nil {
+		return ResultItem{}, err
+	}
+	return reviewResult(ctx, ResultItem{Source: section, Translation: translation}, opts)
+}
+
+// reviewResult checks a translated result against the glossary and verifies
+// it by back-translation, when enabled.
+func reviewResult(ctx context.Context, result ResultItem, opts analysisOptions) (ResultItem, error) {
+	if terms := analyze.GlossaryTermsIn(opts.glossary, result.Source); len(terms) > 0 {
+		if err := enforceGlossary(ctx, &result, terms, opts); err != nil {
+			return ResultItem{}, err
+		}
+	}
+	if opts.verify {
+		if err := verifyResult(ctx, &result, opts); err != nil {
+			return ResultItem{}, err
+		}
+	}
+	return result, nil
+}
+
+// translateIntoLanguages translates section into all of the translation
+// languages in parallel, returning the translations keyed by language.
+func translateIntoLanguages(ctx context.Context, section string, opts analysisOptions) (map[string]string, error) {
+	languages := opts.translationLanguages
+	translate := func(_ int, language string) (string, error) {
+		return translateWithMemory(ctx, section, opts.sourceLanguage, language, nil, opts)
+	}
+
+	translations, err := analyze.RunOrdered(languages, len(languages), translate, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	byLanguage := make(map[string]string, len(languages))
+	for i, language := range languages {
+		byLanguage[language] = translations[i]
+	}
+	return byLanguage, nil
+}
+
+// segmentText asks the LLM to divide text into small sections, each
+// representing a particular thought or idea.
+func segmentText(ctx context.Context, text string, opts analysisOptions) ([]string, error) {
+	sections, err := newAnalyzer(opts).Segment(ctx, text, analyzeOptions(opts))
+	if err != nil {
+		return nil, providerError(err)
+	}
+	return sections, nil
+}
+
+// segmentPromptData returns the data of the segmentation prompt for text.
+func segmentPromptData(text string, opts analysisOptions) promptData {
+	return promptData{
+		Text:           text,
+		Language:       opts.translationLanguage,
+		SourceLanguage: opts.sourceLanguage,
+		Granularity:    opts.granularity,
+		MinWords:       opts.minSectionWords,
+		MaxWords:       opts.maxSectionWords,
+		Guidance:       analyze.SegmentationGuidance(analyzeOptions(opts)),
+	}
+}
 
-		fmt.Println(string(resultsJSON))
-	},
+// translateSection asks the LLM to translate a single section from one
+// language into another.
+// glossary holds the terms the translation must respect.
+func translateSection(ctx context.Context, section, from, to string, glossary []glossaryTerm, opts analysisOptions) (string, error) {
+	return newAnalyzer(opts).Translate(ctx, section, from, to, glossary, analyzeOptions(opts))
 }
 
 func init() {
-	analiseCmd.Flags().StringP("llm-host", "l", "", "The Ollama host URL for the LLM service (default is 'http://localhost:11434/api/generate')")
-	analiseCmd.Flags().StringP("translation-language", "t", "", "The language for translation in locale format (default is 'en-US')")
+	addAnalysisFlags(analiseCmd.PersistentFlags())
+	analiseCmd.Flags().StringArrayP("file", "f", nil, "A text or PDF file to analyze (repeatable); UTF-8 and UTF-16 encodings are detected automatically")
+	analiseCmd.Flags().String("pages", "", "The pages of the --file PDF documents to analyze, such as 1-3,5 (default all of them)")
+	analiseCmd.Flags().StringP("output", "o", "", "Write the results to this file instead of stdout; files ending with .db, .sqlite or .sqlite3 are written as SQLite databases")
+	analiseCmd.Flags().String("format", "json", "The output format: json, yaml, csv, table, markdown, xliff, xlsx for an Excel workbook, or ndjson to print each result as soon as it is translated")
+	analiseCmd.Flags().String("template", "", "A Go text/template file rendering the output instead of --format, executed with the analyses and the metadata of the run")
+	analiseCmd.Flags().Bool("validate-output", false, "Check the results against the JSON Schema printed by the schema command before writing them, failing when they don't match")
+	analiseCmd.Flags().Bool("dry-run", false, "Print the prompts and request bodies that would be sent, without calling the LLM")
+	analiseCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+	analiseCmd.Flags().String("resume", "", "The JSON or NDJSON output of an earlier run whose translated sections are reused, only translating the missing or changed ones")
+	analiseCmd.Flags().StringArray("audio", nil, "An audio file to transcribe with Whisper and analyze (repeatable)")
+	analiseCmd.Flags().String("whisper-host", "", "The Whisper endpoint transcribing --audio: the /inference endpoint of a whisper.cpp server, or the /v1/audio/transcriptions endpoint or /v1 root of an OpenAI-compatible API (default is 'http://localhost:8080/inference')")
+	analiseCmd.Flags().String("whisper-model", "", "The Whisper model transcribing --audio, which whisper.cpp servers ignore (default is 'whisper-1')")
+	analiseCmd.Flags().StringArray("image", nil, "An image to read the text of with a vision model and analyze, such as the photo of a menu (repeatable)")
+	analiseCmd.Flags().String("vision-model", "", "The vision model reading the text of --image, through the --provider (default is 'llava')")
+	analiseCmd.Flags().String("tts", "", "Read every section aloud and add the path to its recording to the results, with piper (the default when given without a value) or openai")
+	analiseCmd.Flags().Lookup("tts").NoOptDefVal = "piper"
+	analiseCmd.Flags().String("tts-host", "", "The URL of the --tts backend: the Piper HTTP server, or the API root of OpenAI or an OpenAI-compatible server (default depends on --tts)")
+	analiseCmd.Flags().String("tts-model", "", "The model of the --tts backend (default is 'tts-1' for openai)")
+	analiseCmd.Flags().String("tts-voice", "", "The voice of the --tts backend, such as alloy for openai or de_DE-thorsten-high for piper (default depends on the backend)")
+	analiseCmd.Flags().String("tts-dir", "audio", "The directory the --tts recordings are written to")
+	analiseCmd.Flags().Bool("clipboard", false, "Analyze the text on the system clipboard")
+	analiseCmd.Flags().String("copy", "", "Also place the results on the system clipboard: output, as printed, or translation, the text of the translations alone")
+	analiseCmd.Flags().Lookup("copy").NoOptDefVal = "output"
 
 	rootCmd.AddCommand(analiseCmd)
 }
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// analysisClient sends the requests of package analyze through generate and
+// translate, with the cache, retries and metering of opts.
+type analysisClient struct {
+	opts analysisOptions
+}
+
+func (c analysisClient) Generate(ctx context.Context, req llm.Request) (string, error) {
+	return generate(ctx, c.opts, req)
+}
+
+func (c analysisClient) Translate(ctx context.Context, req llm.TranslateRequest) (string, error) {
+	return translate(ctx, c.opts, req.Text, req.From, req.To, llm.Request{Model: req.Model, System: req.System, Examples: req.Examples, Prompt: req.Prompt})
+}
+
+// newAnalyzer returns the analyzer sending its requests with opts.
+func newAnalyzer(opts analysisOptions) *analyze.Analyzer {
+	return analyze.New(analysisClient{opts}, stderrLogger{})
+}
+
+// analyzeOptions returns the pipeline options of opts.
+func analyzeOptions(opts analysisOptions) analyze.Options {
+	return analyze.Options{
+		SegmentModel:         opts.segmentModel,
+		TranslateModel:       opts.translateModel,
+		SourceLanguage:       opts.sourceLanguage,
+		TranslationLanguages: opts.translationLanguages,
+		Granularity:          opts.granularity,
+		MinSectionWords:      opts.minSectionWords,
+		MaxSectionWords:      opts.maxSectionWords,
+		Glossary:             opts.glossary,
+		SegmentPrompt:        &opts.segmentPrompt,
+		TranslatePrompt:      &opts.translatePrompt,
+		Concurrency:          opts.concurrency,
+	}
+}
@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apkgFields are the fields a note type of an Anki package may have, by the
+// name --fields gives them.
+var apkgFields = []string{"source", "translation", "reading", "audio", "notes"}
+
+// apkgAudioExtensions are the extensions of the audio files looked up in
+// --audio-dir, in order.
+var apkgAudioExtensions = []string{".mp3", ".ogg", ".opus", ".m4a", ".wav", ".flac"}
+
+// ankiGUIDCharacters are the characters of the GUIDs of Anki notes.
+const ankiGUIDCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+
+// ankiFieldTag matches the tags of the fields of notes, which their checksum
+// leaves out.
+var ankiFieldTag = regexp.MustCompile(`<[^>]*>`)
+
+// apkgField is a field of the note type of an Anki package: which of
+// apkgFields it holds, under the name it has in Anki.
+type apkgField struct {
+	Kind string
+	Name string
+}
+
+// apkgNoteType is the note type of the notes of an Anki package.
+type apkgNoteType struct {
+	Name   string
+	Fields []apkgField
+}
+
+// The schema of the collection of an Anki package, in version 11 of it,
+// which every version of Anki imports.
+const (
+	ankiColSQL = `CREATE TABLE col (
+    id              integer primary key,
+    crt             integer not null,
+    mod             integer not null,
+    scm             integer not null,
+    ver             integer not null,
+    dty             integer not null,
+    usn             integer not null,
+    ls              integer not null,
+    conf            text not null,
+    models          text not null,
+    decks           text not null,
+    dconf           text not null,
+    tags            text not null
+)`
+	ankiNotesSQL = `CREATE TABLE notes (
+    id              integer primary key,
+    guid            text not null,
+    mid             integer not null,
+    mod             integer not null,
+    usn             integer not null,
+    tags            text not null,
+    flds            text not null,
+    sfld            integer not null,
+    csum            integer not null,
+    flags           integer not null,
+    data            text not null
+)`
+	ankiCardsSQL = `CREATE TABLE cards (
+    id              integer primary key,
+    nid             integer not null,
+    did             integer not null,
+    ord             integer not null,
+    mod             integer not null,
+    usn             integer not null,
+    type            integer not null,
+    queue           integer not null,
+    due             integer not null,
+    ivl             integer not null,
+    factor          integer not null,
+    reps            integer not null,
+    lapses          integer not null,
+    left            integer not null,
+    odue            integer not null,
+    odid            integer not null,
+    flags           integer not null,
+    data            text not null
+)`
+	ankiRevlogSQL = `CREATE TABLE revlog (
+    id              integer primary key,
+    cid             integer not null,
+    usn             integer not null,
+    ease            integer not null,
+    ivl             integer not null,
+    lastIvl         integer not null,
+    factor          integer not null,
+    time            integer not null,
+    type            integer not null
+)`
+	ankiGravesSQL = `CREATE TABLE graves (
+    usn             integer not null,
+    oid             integer not null,
+    type            integer not null
+)`
+)
+
+// parseAPKGFields parses the --fields of the note type, each one of
+// apkgFields optionally followed by =Name, the name of the field in Anki.
+// The notes field is added with --notes when it is not listed.
+func parseAPKGFields(specs []string, notes bool) ([]apkgField, error) {
+	var fields []apkgField
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		kind, name, renamed := strings.Cut(strings.TrimSpace(spec), "=")
+		kind = strings.ToLower(kind)
+		known := false
+		for _, field := range apkgFields {
+			known = known || field == kind
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid field %q (expected %s, optionally followed by =Name)", spec, strings.Join(apkgFields, ", "))
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("the %s field is listed twice in --fields", kind)
+		}
+		if !renamed {
+			name = strings.ToUpper(kind[:1]) + kind[1:]
+		}
+		if name == "" || strings.ContainsAny(name, ":{}\"#^/") {
+			return nil, fmt.Errorf("invalid field name %q: Anki field names cannot be empty or contain :{}\"#^/", name)
+		}
+		seen[kind] = true
+		fields = append(fields, apkgField{Kind: kind, Name: name})
+	}
+	if notes && !seen["notes"] {
+		fields = append(fields, apkgField{Kind: "notes", Name: "Notes"})
+	}
+	if len(fields) == 0 || fields[0].Kind == "audio" {
+		return nil, fmt.Errorf("--fields must start with a field of text, shown on the front of the cards")
+	}
+	return fields, nil
+}
+
+// findAudio returns the audio file of term in dir, named after it with one
+// of apkgAudioExtensions, or an empty path when there is none.
+func findAudio(dir, term string) string {
+	if dir == "" || term == "" || strings.ContainsAny(term, `/\`) {
+		return ""
+	}
+	for _, extension := range apkgAudioExtensions {
+		path := filepath.Join(dir, term+extension)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// writeAPKG writes cards as an Anki package: a zip file with a collection,
+// the SQLite database of an Anki profile holding the notes of noteType in
+// their decks, and the audio files of the notes as its media. Notes have
+// GUIDs made from their deck and their source, so that importing the
+// package again updates them.
+func writeAPKG(cards []flashcard, noteType apkgNoteType, tags []string, now time.Time) ([]byte, error) {
+	milliseconds := now.UnixMilli()
+	modelID := ankiModelID(noteType)
+	media := make(map[string]string)
+	var mediaNames []string
+	mediaName := func(path string) string {
+		name := filepath.Base(path)
+		for i := 2; media[name] != "" && media[name] != path; i++ {
+			extension := filepath.Ext(path)
+			name = strings.TrimSuffix(filepath.Base(path), extension) + "-" + strconv.Itoa(i) + extension
+		}
+		if media[name] == "" {
+			media[name] = path
+			mediaNames = append(mediaNames, name)
+		}
+		return name
+	}
+
+	decks := map[string]int64{"Default": 1}
+	deckNames := []string{"Default"}
+	var notes, cardRows []sqliteRow
+	tagList := ""
+	if len(tags) > 0 {
+		tagList = " " + strings.Join(tags, " ") + " "
+	}
+	for i, card := range cards {
+		deck := card.Deck
+		if deck == "" {
+			deck = "Default"
+		}
+		if _, ok := decks[deck]; !ok {
+			decks[deck] = milliseconds + int64(len(decks))
+			deckNames = append(deckNames, deck)
+		}
+
+		values := make([]string, len(noteType.Fields))
+		for j, field := range noteType.Fields {
+			switch field.Kind {
+			case "source":
+				values[j] = ankiFieldHTML(card.Front)
+			case "translation":
+				values[j] = ankiFieldHTML(card.Back)
+			case "reading":
+				values[j] = card.Reading
+				// Ruby readings are HTML already.
+				if !strings.Contains(card.Reading, "<ruby>") {
+					values[j] = ankiFieldHTML(card.Reading)
+				}
+			case "audio":
+				if card.Audio != "" {
+					values[j] = "[sound:" + mediaName(card.Audio) + "]"
+				}
+			case "notes":
+				values[j] = ankiFieldHTML(card.Notes)
+			}
+		}
+		sortField := html.UnescapeString(ankiFieldTag.ReplaceAllString(values[0], ""))
+		sum := sha1.Sum([]byte(sortField))
+		noteID := milliseconds + int64(i)
+		notes = append(notes, sqliteRow{ID: noteID, Values: []any{
+			nil, ankiGUID(noteType.Name, deck, card.Front), modelID, now.Unix(), -1, tagList, strings.Join(values, "\x1f"),
+			sortField, int64(binary.BigEndian.Uint32(sum[:4])), 0, "",
+		}})
+		// New cards are due in the order of the notes.
+		cardRows = append(cardRows, sqliteRow{ID: milliseconds + int64(i), Values: []any{
+			nil, noteID, decks[deck], 0, now.Unix(), -1, 0, 0, i + 1, 0, 0, 0, 0, 0, 0, 0, 0, "",
+		}})
+	}
+
+	col, err := ankiCollection(noteType, modelID, decks, deckNames, len(cards), now)
+	if err != nil {
+		return nil, err
+	}
+	database, err := writeSQLite([]sqliteTable{
+		{Name: "col", SQL: ankiColSQL, Rows: []sqliteRow{{ID: 1, Values: col}}},
+		{Name: "notes", SQL: ankiNotesSQL, Rows: notes, Indexes: []sqliteIndex{
+			{Name: "ix_notes_usn", SQL: "CREATE INDEX ix_notes_usn on notes (usn)", Columns: []int{4}},
+			{Name: "ix_notes_csum", SQL: "CREATE INDEX ix_notes_csum on notes (csum)", Columns: []int{8}},
+		}},
+		{Name: "cards", SQL: ankiCardsSQL, Rows: cardRows, Indexes: []sqliteIndex{
+			{Name: "ix_cards_usn", SQL: "CREATE INDEX ix_cards_usn on cards (usn)", Columns: []int{5}},
+			{Name: "ix_cards_nid", SQL: "CREATE INDEX ix_cards_nid on cards (nid)", Columns: []int{1}},
+			{Name: "ix_cards_sched", SQL: "CREATE INDEX ix_cards_sched on cards (did, queue, due)", Columns: []int{2, 7, 8}},
+		}},
+		{Name: "revlog", SQL: ankiRevlogSQL, Indexes: []sqliteIndex{
+			{Name: "ix_revlog_usn", SQL: "CREATE INDEX ix_revlog_usn on revlog (usn)", Columns: []int{2}},
+			{Name: "ix_revlog_cid", SQL: "CREATE INDEX ix_revlog_cid on revlog (cid)", Columns: []int{1}},
+		}},
+		{Name: "graves", SQL: ankiGravesSQL},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The media of a package are its files named by number, and a media
+	// file mapping the numbers to their names.
+	var b bytes.Buffer
+	archive := zip.NewWriter(&b)
+	entry, err := archive.Create("collection.anki2")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := entry.Write(database); err != nil {
+		return nil, err
+	}
+	mapping := make(map[string]string, len(mediaNames))
+	for i, name := range mediaNames {
+		data, err := os.ReadFile(media[name])
+		if err != nil {
+			return nil, fmt.Errorf("reading audio: %w", err)
+		}
+		entry, err := archive.Create(strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return nil, err
+		}
+		mapping[strconv.Itoa(i)] = name
+	}
+	mappingJSON, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, err
+	}
+	entry, err = archive.Create("media")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := entry.Write(mappingJSON); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// ankiCollection returns the values of the row of the col table of a
+// collection with the notes of noteType in decks: its configuration, its
+// note types, its decks and their options, as JSON.
+func ankiCollection(noteType apkgNoteType, modelID int64, decks map[string]int64, deckNames []string, notes int, now time.Time) ([]any, error) {
+	conf := map[string]any{
+		"activeDecks": []int64{1}, "curDeck": 1, "newSpread": 0, "collapseTime": 1200, "timeLim": 0,
+		"estTimes": true, "dueCounts": true, "curModel": modelID, "nextPos": notes + 1,
+		"sortType": "noteFld", "sortBackwards": false, "addToCur": true,
+	}
+
+	var flds []map[string]any
+	for i, field := range noteType.Fields {
+		flds = append(flds, map[string]any{"name": field.Name, "ord": i, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}})
+	}
+	front, back := ankiTemplates(noteType.Fields)
+	models := map[string]any{strconv.FormatInt(modelID, 10): map[string]any{
+		"id": modelID, "name": noteType.Name, "type": 0, "mod": now.Unix(), "usn": -1, "sortf": 0, "did": 1,
+		"tmpls": []map[string]any{{
+			"name": "Card 1", "ord": 0, "qfmt": front, "afmt": back,
+			"bqfmt": "", "bafmt": "", "did": nil, "bfont": "", "bsize": 0,
+		}},
+		"flds":      flds,
+		"css":       ".card {\n  font-family: arial;\n  font-size: 20px;\n  text-align: center;\n  color: black;\n  background-color: white;\n}\n\n.reading, .notes {\n  font-size: 16px;\n  color: #666;\n}\n",
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"latexsvg":  false,
+		"req":       []any{[]any{0, "any", []int{0}}},
+		"tags":      []string{},
+		"vers":      []any{},
+	}}
+
+	deckJSON := make(map[string]any, len(decks))
+	for _, name := range deckNames {
+		id := decks[name]
+		deckJSON[strconv.FormatInt(id, 10)] = map[string]any{
+			"id": id, "name": name, "desc": "", "mod": now.Unix(), "usn": -1, "conf": 1, "dyn": 0,
+			"collapsed": false, "browserCollapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+
+	dconf := map[string]any{"1": map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "dyn": false, "maxTaken": 60, "timer": 0,
+		"autoplay": true, "replayq": true,
+		"new": map[string]any{
+			"bury": true, "delays": []int{1, 10}, "initialFactor": 2500, "ints": []int{1, 4, 7},
+			"order": 1, "perDay": 20, "separate": true,
+		},
+		"rev": map[string]any{
+			"bury": true, "ease4": 1.3, "fuzz": 0.05, "ivlFct": 1, "maxIvl": 36500, "minSpace": 1, "perDay": 200,
+		},
+		"lapse": map[string]any{"delays": []int{10}, "leechAction": 0, "leechFails": 8, "minInt": 1, "mult": 0},
+	}}
+
+	values := []any{nil}
+	created := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	values = append(values, created, now.UnixMilli(), now.UnixMilli(), 11, 0, 0, 0)
+	for _, value := range []any{conf, models, deckJSON, dconf, map[string]any{}} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, string(data))
+	}
+	return values, nil
+}
+
+// ankiTemplates returns the front and the back of the cards of a note type
+// with fields: the front has the first field and the audio, which Anki
+// plays, and the back the other fields.
+func ankiTemplates(fields []apkgField) (string, string) {
+	reference := func(field apkgField) string {
+		if field.Kind == "reading" {
+			return "{{furigana:" + field.Name + "}}"
+		}
+		return "{{" + field.Name + "}}"
+	}
+	front := reference(fields[0])
+	var back strings.Builder
+	back.WriteString("{{FrontSide}}\n\n<hr id=answer>\n")
+	for _, field := range fields[1:] {
+		if field.Kind == "audio" {
+			front += "\n{{" + field.Name + "}}"
+			continue
+		}
+		fmt.Fprintf(&back, "\n{{#%s}}<div class=\"%s\">%s</div>{{/%s}}", field.Name, field.Kind, reference(field), field.Name)
+	}
+	return front, back.String()
+}
+
+// ankiFieldHTML returns text as the HTML of the field of a note.
+func ankiFieldHTML(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// ankiModelID returns the ID of noteType, the same for the same name and
+// fields so that importing packages again doesn't add note types to Anki.
+// It is in the range of the millisecond timestamps Anki makes IDs from.
+func ankiModelID(noteType apkgNoteType) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(noteType.Name))
+	for _, field := range noteType.Fields {
+		h.Write([]byte("\x1f" + field.Name))
+	}
+	return 1_000_000_000_000 + int64(h.Sum64()%1_000_000_000_000)
+}
+
+// ankiGUID returns the GUID of the note of source in deck, in the base 91
+// that Anki writes them in.
+func ankiGUID(noteType, deck, source string) string {
+	h := fnv.New64a()
+	h.Write([]byte(noteType + "\x00" + deck + "\x00" + source))
+	n := h.Sum64()
+	var guid []byte
+	for n > 0 {
+		guid = append(guid, ankiGUIDCharacters[n%uint64(len(ankiGUIDCharacters))])
+		n /= uint64(len(ankiGUIDCharacters))
+	}
+	return string(guid)
+}
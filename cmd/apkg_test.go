@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAPKGFields(t *testing.T) {
+	fields, err := parseAPKGFields([]string{"source=Front", "reading", " Audio "}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []apkgField{{"source", "Front"}, {"reading", "Reading"}, {"audio", "Audio"}, {"notes", "Notes"}}
+	if len(fields) != len(want) {
+		t.Fatalf("fields = %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("field %d = %v, want %v", i, fields[i], want[i])
+		}
+	}
+
+	for _, specs := range [][]string{{"source", "source"}, {"front"}, {"audio", "source"}, {"source=Fr:ont"}, {"source="}} {
+		if _, err := parseAPKGFields(specs, false); err == nil {
+			t.Errorf("parseAPKGFields(%q) didn't fail", specs)
+		}
+	}
+}
+
+func TestFindAudio(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Hund.ogg", "Hund.wav", "Katze.mp3"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "Maus.mp3"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for term, want := range map[string]string{"Hund": "Hund.ogg", "Katze": "Katze.mp3", "Maus": "", "../Hund": "", "": ""} {
+		got := findAudio(dir, term)
+		if want != "" {
+			want = filepath.Join(dir, want)
+		}
+		if got != want {
+			t.Errorf("findAudio(%q) = %q, want %q", term, got, want)
+		}
+	}
+}
+
+func TestWriteAPKG(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "Hund.mp3")
+	if err := os.WriteFile(audio, []byte("ID3 bark"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cards := []flashcard{
+		{Front: "der Hund", Back: "the dog", Deck: "Deutsch::Tiere", Audio: audio, Reading: "<ruby>犬<rt>いぬ</rt></ruby>"},
+		{Front: "Tom & Jerry", Back: "a <cat>\nand a mouse", Notes: "cartoon", Audio: audio},
+	}
+	fields, err := parseAPKGFields([]string{"source", "translation", "reading", "audio"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noteType := apkgNoteType{Name: "starter-go-cli", Fields: fields}
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	data, err := writeAPKG(cards, noteType, []string{"starter", "de"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := zipFiles(archive)
+	mapping, err := readZipFile(files["media"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapping != `{"0":"Hund.mp3"}` {
+		t.Errorf("media = %s, want the audio once", mapping)
+	}
+	if media, _ := readZipFile(files["0"]); media != "ID3 bark" {
+		t.Errorf("media file 0 = %q", media)
+	}
+
+	database, err := readZipFile(files["collection.anki2"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := os.WriteFile(path, []byte(database), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := sqliteQuery(t, path, "PRAGMA integrity_check;"); got != "ok" {
+		t.Fatalf("integrity check: %s", got)
+	}
+	got := sqliteQuery(t, path, "SELECT replace(flds, char(31), '|'), sfld, '[' || tags || ']' FROM notes ORDER BY id;")
+	want := "der Hund|the dog|<ruby>犬<rt>いぬ</rt></ruby>|[sound:Hund.mp3]||der Hund|[ starter de ]\n" +
+		"Tom &amp; Jerry|a &lt;cat&gt;<br>and a mouse||[sound:Hund.mp3]|cartoon|Tom & Jerry|[ starter de ]"
+	if got != want {
+		t.Errorf("notes:\n%s\nwant:\n%s", got, want)
+	}
+	if got := sqliteQuery(t, path, "SELECT count(*) FROM notes JOIN cards ON cards.nid = notes.id;"); got != "2" {
+		t.Errorf("cards of the notes = %s, want 2", got)
+	}
+
+	var decks map[string]struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(sqliteQuery(t, path, "SELECT decks FROM col;")), &decks); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, deck := range decks {
+		names = append(names, deck.Name)
+	}
+	if len(names) != 2 || !strings.Contains(strings.Join(names, "|"), "Deutsch::Tiere") {
+		t.Errorf("decks = %v", names)
+	}
+	if got := sqliteQuery(t, path, "SELECT did = (SELECT did FROM cards WHERE nid = (SELECT min(id) FROM notes)) FROM cards WHERE nid = (SELECT max(id) FROM notes);"); got != "0" {
+		t.Error("the notes of different decks have cards in the same deck")
+	}
+
+	// Writing the cards again, later, gives the notes the same GUIDs, so
+	// that Anki updates them.
+	again, err := writeAPKG(cards, noteType, nil, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err = zip.NewReader(bytes.NewReader(again), int64(len(again)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if database, err = readZipFile(zipFiles(archive)["collection.anki2"]); err != nil {
+		t.Fatal(err)
+	}
+	againPath := filepath.Join(t.TempDir(), "collection.anki2")
+	if err := os.WriteFile(againPath, []byte(database), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	query := "SELECT guid, mid FROM notes ORDER BY id;"
+	if first, second := sqliteQuery(t, path, query), sqliteQuery(t, againPath, query); first != second {
+		t.Errorf("GUIDs and note types changed from %s to %s", first, second)
+	}
+}
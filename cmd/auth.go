@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keyringService is the service the API keys are stored under in the OS
+// keyring, with the provider or translator name as the user.
+const keyringService = "starter-go-cli"
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the API keys stored in the OS keyring",
+	Long: `The "auth" commands store the API keys of the providers and translators in the OS keyring (macOS Keychain, the Secret Service on Linux or the Windows Credential Manager), where they are found automatically.
+Keys in environment variables take precedence over the keyring, which takes precedence over the api_keys of the config file.`,
+}
+
+var authSetCmd = &cobra.Command{
+	Use:   "set <provider>",
+	Short: "Store the API key of a provider or translator in the OS keyring",
+	Long: `The "set" command stores the API key of a provider or translator in the OS keyring.
+The key is prompted for without echo when stdin is a terminal, and read from stdin otherwise.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAuthSet,
+}
+
+var authDeleteCmd = &cobra.Command{
+	Use:   "delete <provider>",
+	Short: "Remove the API key of a provider or translator from the OS keyring",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAuthDelete,
+}
+
+// keyedService returns the provider or translator name, which must take an
+// API key.
+func keyedService(name string) (providerSpec, error) {
+	spec, ok := providers[name]
+	if !ok {
+		spec, ok = translators[name]
+	}
+	if !ok {
+		spec, ok = speechServices[name]
+	}
+	if !ok || len(spec.apiKeyEnv) == 0 {
+		return providerSpec{}, fmt.Errorf("%q takes no API key (expected one of %s)", name, strings.Join(keyedServices(), ", "))
+	}
+	return spec, nil
+}
+
+// keyedServices lists the providers and translators taking an API key.
+func keyedServices() []string {
+	var names []string
+	for name, spec := range providers {
+		if len(spec.apiKeyEnv) > 0 {
+			names = append(names, name)
+		}
+	}
+	for name, spec := range translators {
+		if len(spec.apiKeyEnv) > 0 {
+			names = append(names, name)
+		}
+	}
+	for name, spec := range speechServices {
+		if len(spec.apiKeyEnv) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runAuthSet(cmd *cobra.Command, args []string) error {
+	spec, err := keyedService(args[0])
+	if err != nil {
+		return err
+	}
+
+	var key []byte
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "API key for %s: ", spec.title)
+		key, err = term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+	} else {
+		key, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("reading API key: %w", err)
+	}
+	trimmed := strings.TrimSpace(string(key))
+	if trimmed == "" {
+		return errors.New("the API key is empty")
+	}
+
+	if err := keyring.Set(keyringService, args[0], trimmed); err != nil {
+		return fmt.Errorf("storing API key in the OS keyring: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Stored the API key for %s in the OS keyring\n", spec.title)
+	return nil
+}
+
+func runAuthDelete(cmd *cobra.Command, args []string) error {
+	spec, err := keyedService(args[0])
+	if err != nil {
+		return err
+	}
+	if err := keyring.Delete(keyringService, args[0]); err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return fmt.Errorf("no API key for %s in the OS keyring", spec.title)
+		}
+		return fmt.Errorf("removing API key from the OS keyring: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Removed the API key for %s from the OS keyring\n", spec.title)
+	return nil
+}
+
+// keyringAPIKey returns the API key of name stored in the OS keyring, or ""
+// when there is none or no keyring is available.
+func keyringAPIKey(name string) string {
+	key, err := keyring.Get(keyringService, name)
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) {
+			debugf("Reading the %s API key from the OS keyring failed: %v", name, err)
+		}
+		return ""
+	}
+	return key
+}
+
+func init() {
+	authCmd.AddCommand(authSetCmd)
+	authCmd.AddCommand(authDeleteCmd)
+	rootCmd.AddCommand(authCmd)
+}
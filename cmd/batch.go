@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// batchInput is a file to analyze in a batch, along with the path of its
+// results relative to the output directory.
+type batchInput struct {
+	path   string
+	result string
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <dir|glob>...",
+	Short: "Analyze a set of text files and write one JSON result per file",
+	Long: `The "batch" command analyzes every file found in the given directories (recursively, matching --pattern) or matching the given glob patterns.
+The results of each file are written as JSON into --output-dir, mirroring the input directory layout, and a summary of the successes and failures is printed at the end.
+With --csv, the command analyzes the --text-column of every row of a CSV file with a header row instead, and writes the results of every row, with its --id-column, to stdout or --output: as CSV, with a row per section, or as JSON with --format json. Rows without any text are skipped, and the summary is printed to stderr.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runBatch,
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	csvPath, err := cmd.Flags().GetString("csv")
+	if err != nil {
+		return fmt.Errorf("retrieving csv flag: %w", err)
+	}
+	if csvPath != "" {
+		if len(args) > 0 {
+			return errors.New("the <dir|glob> arguments cannot be combined with --csv")
+		}
+		return runCSVBatch(cmd, csvPath)
+	}
+	if len(args) == 0 {
+		return errors.New("requires at least one <dir|glob> argument, or --csv")
+	}
+
+	outputDir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return fmt.Errorf("retrieving output-dir flag: %w", err)
+	}
+
+	pattern, err := cmd.Flags().GetString("pattern")
+	if err != nil {
+		return fmt.Errorf("retrieving pattern flag: %w", err)
+	}
+
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	inputs, err := collectBatchInputs(args, pattern)
+	if err != nil {
+		return fmt.Errorf("collecting input files: %w", err)
+	}
+	if len(inputs) == 0 {
+		return errors.New("no input files found")
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+
+	failures := make(map[string]error)
+	var firstFailure error
+	for i, input := range inputs {
+		if ctx.Err() != nil {
+			failures[input.path] = runError(ctx, opts, ctx.Err())
+		} else {
+			fmt.Fprintf(os.Stderr, "Analyzing %s (%d/%d)\n", input.path, i+1, len(inputs))
+			if err := analyzeBatchInput(ctx, input, outputDir, force, opts); err != nil {
+				failures[input.path] = runError(ctx, opts, err)
+			}
+		}
+		if firstFailure == nil {
+			firstFailure = failures[input.path]
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Batch summary: %d file(s), %d succeeded, %d failed\n", len(inputs), len(inputs)-len(failures), len(failures))
+	for _, input := range inputs {
+		if reason, failed := failures[input.path]; failed {
+			fmt.Fprintf(out, "  FAILED %s: %v\n", input.path, reason)
+		}
+	}
+
+	switch {
+	case len(failures) == 0:
+		return nil
+	case len(failures) < len(inputs):
+		return withExitCode(exitPartial, fmt.Errorf("%d of %d files failed", len(failures), len(inputs)))
+	default:
+		return withExitCode(exitCode(firstFailure), fmt.Errorf("all %d files failed", len(inputs)))
+	}
+}
+
+// analyzeBatchInput analyzes a single batch file and writes its results.
+func analyzeBatchInput(ctx context.Context, input batchInput, outputDir string, force bool, opts analysisOptions) error {
+	resultPath := filepath.Join(outputDir, input.result)
+	if err := checkOutputPath(resultPath, force); err != nil {
+		return err
+	}
+
+	text, err := readInputFile(input.path)
+	if err != nil {
+		return err
+	}
+
+	analysis, err := analyzeText(ctx, text, opts, nil)
+	if err != nil {
+		return err
+	}
+	analysis.File = input.path
+
+	resultsJSON, err := json.MarshalIndent(analysis, "", "    ")
+	if err != nil {
+		return fmt.Errorf("marshalling results to JSON: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(resultPath), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(resultPath, append(resultsJSON, '\n'), force)
+}
+
+// collectBatchInputs expands directories and glob patterns into the list of
+// files to analyze, sorted and without duplicates.
+func collectBatchInputs(args []string, pattern string) ([]batchInput, error) {
+	byResult := make(map[string]string)
+	var inputs []batchInput
+
+	add := func(path, result string) error {
+		result = strings.TrimSuffix(result, filepath.Ext(result)) + ".json"
+		if previous, exists := byResult[result]; exists {
+			if previous == path {
+				return nil
+			}
+			return fmt.Errorf("%s and %s would both be written to %s", previous, path, result)
+		}
+		byResult[result] = path
+		inputs = append(inputs, batchInput{path: path, result: result})
+		return nil
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err == nil && info.IsDir() {
+			err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !entry.Type().IsRegular() {
+					return nil
+				}
+				if matched, _ := filepath.Match(pattern, entry.Name()); !matched {
+					return nil
+				}
+				rel, err := filepath.Rel(arg, path)
+				if err != nil {
+					return err
+				}
+				return add(path, rel)
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no such file or directory", arg)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if err := add(match, filepath.Base(match)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return inputs, nil
+}
+
+func init() {
+	batchCmd.Flags().StringP("output-dir", "d", "results", "The directory the per-file JSON results are written to")
+	batchCmd.Flags().String("pattern", "*.txt", "The file name pattern used when walking directories")
+	batchCmd.Flags().Bool("force", false, "Overwrite existing result files")
+	batchCmd.Flags().String("csv", "", "A CSV file with a header row whose --text-column is analyzed row by row, instead of text files")
+	batchCmd.Flags().String("text-column", "text", "The column of the --csv file holding the texts to analyze")
+	batchCmd.Flags().String("id-column", "", "The column of the --csv file identifying its rows in the results (default is the number of the row)")
+	batchCmd.Flags().String("format", "csv", "The format of the results of --csv: csv or json")
+	batchCmd.Flags().StringP("output", "o", "", "Write the results of --csv to this file instead of stdout")
+
+	analiseCmd.AddCommand(batchCmd)
+}
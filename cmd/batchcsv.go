@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// csvBatchRow is the analysis of the text of a row of a --csv batch, with the
+// id of the row.
+type csvBatchRow struct {
+	ID string `json:"id"`
+	Analysis
+}
+
+// runCSVBatch analyzes the --text-column of every row of the CSV file at
+// path and writes the results of every row, with its --id-column, as a
+// single CSV or JSON document.
+func runCSVBatch(cmd *cobra.Command, path string) error {
+	textColumn, err := cmd.Flags().GetString("text-column")
+	if err != nil {
+		return fmt.Errorf("retrieving text-column flag: %w", err)
+	}
+	idColumn, err := cmd.Flags().GetString("id-column")
+	if err != nil {
+		return fmt.Errorf("retrieving id-column flag: %w", err)
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unsupported format %q (expected csv or json)", format)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+	if outputPath != "" {
+		if err := checkOutputPath(outputPath, force); err != nil {
+			return err
+		}
+	}
+
+	text, err := readInputFile(path)
+	if err != nil {
+		return fmt.Errorf("reading CSV file: %w", err)
+	}
+	ids, texts, err := readCSVColumns(text, idColumn, textColumn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(texts) == 0 {
+		return fmt.Errorf("%s: no rows to analyze", path)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	var rows []csvBatchRow
+	failures := make(map[int]error)
+	var firstFailure error
+	skipped := 0
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			skipped++
+			continue
+		}
+		if ctx.Err() != nil {
+			failures[i] = runError(ctx, opts, ctx.Err())
+		} else {
+			fmt.Fprintf(os.Stderr, "Analyzing row %s (%d/%d)\n", ids[i], i+1, len(texts))
+			// Languages are detected for every row, whose texts may be in
+			// different ones.
+			analysis, err := analyzeText(ctx, text, opts, nil)
+			if err != nil {
+				failures[i] = runError(ctx, opts, err)
+			} else {
+				rows = append(rows, csvBatchRow{ID: ids[i], Analysis: analysis})
+			}
+		}
+		if firstFailure == nil {
+			firstFailure = failures[i]
+		}
+	}
+
+	var b bytes.Buffer
+	if err := writeCSVBatch(&b, rows, format); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	if outputPath == "" {
+		if _, err := cmd.OutOrStdout().Write(b.Bytes()); err != nil {
+			return err
+		}
+	} else if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+
+	// The results go to stdout, so the summary goes to stderr.
+	analyzed := len(texts) - skipped
+	fmt.Fprintf(os.Stderr, "Batch summary: %d row(s), %d succeeded, %d failed, %d without text\n", len(texts), analyzed-len(failures), len(failures), skipped)
+	for i := range texts {
+		if reason, failed := failures[i]; failed {
+			fmt.Fprintf(os.Stderr, "  FAILED row %s: %v\n", ids[i], reason)
+		}
+	}
+
+	switch {
+	case len(failures) == 0:
+		return nil
+	case len(failures) < analyzed:
+		return withExitCode(exitPartial, fmt.Errorf("%d of %d rows failed", len(failures), analyzed))
+	default:
+		return withExitCode(exitCode(firstFailure), fmt.Errorf("all %d rows failed", analyzed))
+	}
+}
+
+// readCSVColumns returns the ids and the texts of the rows of a CSV file with
+// a header row. The id of a row is its number, from 1, when idColumn is
+// empty.
+func readCSVColumns(text, idColumn, textColumn string) ([]string, []string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing CSV: %w", err)
+	}
+	column := func(name string) (int, error) {
+		for i, field := range header {
+			if strings.TrimSpace(field) == name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no %q column (the columns are %s)", name, strings.Join(header, ", "))
+	}
+	textIndex, err := column(textColumn)
+	if err != nil {
+		return nil, nil, err
+	}
+	idIndex := -1
+	if idColumn != "" {
+		if idIndex, err = column(idColumn); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var ids, texts []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return ids, texts, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing CSV: %w", err)
+		}
+		id := strconv.Itoa(len(texts) + 1)
+		if idIndex >= 0 && idIndex < len(record) {
+			id = record[idIndex]
+		}
+		text := ""
+		if textIndex < len(record) {
+			text = record[textIndex]
+		}
+		ids = append(ids, id)
+		texts = append(texts, text)
+	}
+}
+
+// writeCSVBatch writes the results of the rows of a --csv batch: as JSON, or
+// as CSV with a row per section, starting with the id of its row.
+func writeCSVBatch(w io.Writer, rows []csvBatchRow, format string) error {
+	if format == "json" {
+		if rows == nil {
+			rows = []csvBatchRow{}
+		}
+		return writeJSON(w, rows)
+	}
+	analyses := make([]Analysis, len(rows))
+	for i, row := range rows {
+		analyses[i] = row.Analysis
+		analyses[i].File = row.ID
+	}
+	header, records := tableRows(analyses, true)
+	header[0] = "id"
+	return writeCSV(w, header, records)
+}
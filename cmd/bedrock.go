@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// newBedrockProvider returns the Bedrock provider, authenticated with the
+// credential chain of the AWS SDK: environment variables, shared config and
+// credentials files, SSO, and the roles of containers and instances. host,
+// when set, replaces the regional endpoint. The SDK fetches the credentials
+// through client as well.
+func newBedrockProvider(cmd *cobra.Command, cfg *config, host string, client *http.Client) (llm.Provider, error) {
+	region, err := resolveSetting(cmd, "aws-region", "", cfg.AWSRegion, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving aws-region flag: %w", err)
+	}
+
+	loadOptions := []func(*awsconfig.LoadOptions) error{awsconfig.WithHTTPClient(client)}
+	if region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(cmd.Context(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, errors.New("no AWS region: set --aws-region, AWS_REGION or a region in the AWS configuration")
+	}
+
+	provider := llm.NewBedrock(awsCfg.Region, bedrockSigner(awsCfg), stderrLogger{})
+	provider.Client = client
+	if host != "" {
+		provider.BaseURL = host
+	}
+	return provider, nil
+}
+
+// bedrockSigner signs Bedrock requests with the credentials of awsCfg.
+func bedrockSigner(awsCfg aws.Config) func(*http.Request, []byte) error {
+	signer := v4.NewSigner()
+	return func(req *http.Request, body []byte) error {
+		credentials, err := awsCfg.Credentials.Retrieve(req.Context())
+		if err != nil {
+			return fmt.Errorf("retrieving AWS credentials: %w", err)
+		}
+		sum := sha256.Sum256(body)
+		return signer.SignHTTP(req.Context(), credentials, req, hex.EncodeToString(sum[:]), "bedrock", awsCfg.Region, time.Now())
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxBreakerOpenings is the number of times in a row the circuit may open
+// before the run gives up on the host.
+const maxBreakerOpenings = 5
+
+// breakerError ends a request once the circuit breaker gave up on the host,
+// which is not worth retrying.
+type breakerError struct {
+	err error
+}
+
+func (e *breakerError) Error() string { return e.err.Error() }
+func (e *breakerError) Unwrap() error { return e.err }
+
+// circuitBreaker stops every request of the run once threshold requests in a
+// row failed, instead of letting the workers keep hammering a failing host.
+// After cooldown a single trial request is let through: the circuit closes
+// again when it succeeds and stays open for another cooldown otherwise. A nil
+// *circuitBreaker is valid and never opens, which is how it is disabled.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openings  int
+	// openUntil is zero while the circuit is closed.
+	openUntil time.Time
+	// trial is set while the trial request is in flight.
+	trial bool
+	// failover, when set, is tried before opening the circuit, and reports
+	// whether the next provider took over.
+	failover func(err error) bool
+}
+
+// newCircuitBreaker returns the breaker of --breaker-threshold and
+// --breaker-cooldown, or nil when threshold is not positive.
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow blocks while the circuit is open, or until ctx is done.
+func (b *circuitBreaker) Allow(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		if b.openUntil.IsZero() {
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Until(b.openUntil)
+		if wait <= 0 && !b.trial {
+			b.trial = true
+			b.mu.Unlock()
+			return nil
+		}
+		if wait <= 0 {
+			// Wait for the outcome of the trial request.
+			wait = 100 * time.Millisecond
+		}
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Record counts the outcome of a request and reports whether it failed
+// because the host is failing, in which case the request should be made
+// again once Allow lets it through; otherwise err is returned, possibly
+// wrapped. Only the errors worth retrying count as failures: the others mean
+// that the host is answering.
+func (b *circuitBreaker) Record(err error) (bool, error) {
+	if b == nil {
+		return false, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !isRetryable(err) {
+		b.failures, b.openings, b.openUntil, b.trial = 0, 0, time.Time{}, false
+		return false, err
+	}
+
+	b.failures++
+	switch {
+	case b.trial:
+		b.trial = false
+	case !b.openUntil.IsZero():
+		// The request was sent before the circuit opened.
+		return true, nil
+	case b.failures < b.threshold:
+		return false, err
+	case b.failover != nil && b.failover(err):
+		b.failures = 0
+		return true, nil
+	}
+
+	b.openings++
+	if b.openings > maxBreakerOpenings {
+		return false, &breakerError{fmt.Errorf("giving up after the host kept failing for %d cool-downs: %w", maxBreakerOpenings, err)}
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	if b.openings == 1 {
+		fmt.Fprintf(os.Stderr, "Circuit breaker open: %d requests in a row failed, the last one with: %v\nPausing all requests for %s before trying again (configure --failover to switch to another provider instead)\n", b.failures, err, b.cooldown)
+	} else {
+		fmt.Fprintf(os.Stderr, "Circuit breaker still open: the trial request failed with: %v\nPausing all requests for another %s (%d/%d)\n", err, b.cooldown, b.openings, maxBreakerOpenings)
+	}
+	return true, nil
+}
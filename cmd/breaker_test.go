@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker(0, time.Second)
+	if b != nil {
+		t.Fatal("a breaker without a threshold is enabled")
+	}
+	for i := 0; i < 10; i++ {
+		if again, err := b.Record(errUnreachable); again || err != errUnreachable {
+			t.Fatalf("Record = %v, %v, want the error back", again, err)
+		}
+	}
+	if err := b.Allow(context.Background()); err != nil {
+		t.Errorf("Allow = %v", err)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	b := newCircuitBreaker(2, 50*time.Millisecond)
+	if again, err := b.Record(errUnreachable); again || err != errUnreachable {
+		t.Fatalf("first failure: Record = %v, %v, want the error back", again, err)
+	}
+	// The requests failing once the threshold is reached are made again
+	// once the circuit lets them through.
+	if again, err := b.Record(errOverloaded); !again || err != nil {
+		t.Fatalf("second failure: Record = %v, %v, want the request made again", again, err)
+	}
+	if again, err := b.Record(errUnreachable); !again || err != nil {
+		t.Fatalf("request sent before the circuit opened: Record = %v, %v, want it made again", again, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Allow(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Allow while open = %v, want to wait past the deadline", err)
+	}
+
+	// After the cooldown a single trial request goes through, and its
+	// success closes the circuit for the others.
+	start := time.Now()
+	if err := b.Allow(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("the trial request went through after %s", waited)
+	}
+	second := make(chan error)
+	go func() { second <- b.Allow(context.Background()) }()
+	select {
+	case <-second:
+		t.Fatal("a second request went through during the trial")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if again, err := b.Record(nil); again || err != nil {
+		t.Fatalf("trial success: Record = %v, %v", again, err)
+	}
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the circuit stayed open after the trial succeeded")
+	}
+}
+
+func TestCircuitBreakerAnsweringHost(t *testing.T) {
+	b := newCircuitBreaker(2, time.Hour)
+	b.Record(errUnreachable)
+	// A bad request is answered, and so doesn't count as a failure.
+	if again, err := b.Record(errBadRequest); again || err != errBadRequest {
+		t.Fatalf("Record = %v, %v, want the error back", again, err)
+	}
+	if again, err := b.Record(errUnreachable); again || err != errUnreachable {
+		t.Fatalf("first failure after an answer: Record = %v, %v, want the error back", again, err)
+	}
+}
+
+func TestCircuitBreakerGivesUp(t *testing.T) {
+	b := newCircuitBreaker(1, 0)
+	for opening := 1; opening <= maxBreakerOpenings; opening++ {
+		if again, err := b.Record(errUnreachable); !again || err != nil {
+			t.Fatalf("opening %d: Record = %v, %v, want the request made again", opening, again, err)
+		}
+		if err := b.Allow(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	again, err := b.Record(errUnreachable)
+	var breakerErr *breakerError
+	if again || !errors.As(err, &breakerErr) || !errors.Is(err, errUnreachable) {
+		t.Fatalf("Record = %v, %v, want to give up on the host", again, err)
+	}
+	if isRetryable(err) {
+		t.Error("giving up on the host is retried")
+	}
+}
+
+func TestCircuitBreakerFailsOver(t *testing.T) {
+	b := newCircuitBreaker(1, time.Hour)
+	var failedOver []error
+	b.failover = func(err error) bool {
+		failedOver = append(failedOver, err)
+		return len(failedOver) == 1
+	}
+	if again, err := b.Record(errUnreachable); !again || err != nil {
+		t.Fatalf("Record = %v, %v, want the request made again with the next provider", again, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Allow(ctx); err != nil {
+		t.Fatalf("Allow after failing over = %v, want the circuit closed", err)
+	}
+
+	// Without another provider to fail over to, the circuit opens.
+	if again, err := b.Record(errUnreachable); !again || err != nil || len(failedOver) != 2 {
+		t.Fatalf("Record = %v, %v after %d failovers", again, err, len(failedOver))
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Allow(ctx); err == nil {
+		t.Error("the circuit didn't open once there was no provider left")
+	}
+}
@@ -0,0 +1,374 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// bundleLanguage matches the names of message bundles that are a language
+// tag, such as de or pt-BR.
+var bundleLanguage = regexp.MustCompile(`^[a-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+var i18nTranslateCmd = &cobra.Command{
+	Use:   "translate <source> --to <target>",
+	Short: "Fill in the messages missing from a JSON or YAML message bundle",
+	Long: `The "translate" command reads a JSON or YAML message bundle, such as the en.json of i18next or vue-i18n or the en.yml of Rails, and adds the messages missing from the --to bundle, translated into the --translation-language, which is the name of the --to file when it is a language tag, such as de.json.
+Nested keys and arrays are walked to the end, and only the strings missing from the --to bundle, or empty there, are translated, keeping their placeholders, such as {{count}}, {name}, %{name} or %s. The messages already in the --to bundle, along with its keys missing from the source, are kept as they are.
+Keys keep the order of the source bundle, followed by the ones only in the --to bundle, so that running the command again after adding messages to the source only adds them. Bundles with their messages under their language, such as the en: of Rails, have it renamed after the --to file. The --to bundle is created when it doesn't exist, and written in the format of its extension, .json, .yaml or .yml.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runI18nTranslate,
+}
+
+func runI18nTranslate(cmd *cobra.Command, args []string) error {
+	targetPath, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return fmt.Errorf("retrieving to flag: %w", err)
+	}
+	if targetPath == "" {
+		return errors.New("--to is required: the bundle to fill in, such as de.json")
+	}
+	targetJSON, err := bundleFormat(targetPath)
+	if err != nil {
+		return err
+	}
+	sourceJSON, err := bundleFormat(args[0])
+	if err != nil {
+		return err
+	}
+
+	text, err := readInputFile(args[0])
+	if err != nil {
+		return fmt.Errorf("reading source bundle: %w", err)
+	}
+	source, err := parseBundle(text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	if source == nil {
+		return fmt.Errorf("%s: the bundle has no messages", args[0])
+	}
+	var target *yaml.Node
+	indent := "  "
+	if sourceJSON == targetJSON {
+		indent = bundleIndent(text)
+	}
+	if text, err := readInputFile(targetPath); err == nil {
+		if target, err = parseBundle(text); err != nil {
+			return fmt.Errorf("%s: %w", targetPath, err)
+		}
+		indent = bundleIndent(text)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading target bundle: %w", err)
+	}
+
+	// The language of the --to bundle is the one it is named after, unless
+	// another one is given.
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	languages, err := resolveTranslationLanguages(cmd, cfg)
+	if err != nil {
+		return fmt.Errorf("retrieving language flag: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(targetPath), filepath.Ext(targetPath))
+	if len(languages) == 0 && bundleLanguage.MatchString(name) {
+		if err := cmd.Flags().Set("translation-language", strings.ReplaceAll(name, "_", "-")); err != nil {
+			return err
+		}
+	}
+
+	// Rails bundles have their messages under their language, such as en:,
+	// which becomes the one of the --to bundle.
+	sourceName := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	if len(source.Content) == 2 && source.Content[0].Value == sourceName && bundleLanguage.MatchString(sourceName) && bundleLanguage.MatchString(name) {
+		key := *source.Content[0]
+		key.Value = name
+		source = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: source.Style, Content: []*yaml.Node{&key, source.Content[1]}}
+	}
+
+	var missing []*yaml.Node
+	merged := mergeBundle(source, target, sourceJSON != targetJSON, &missing)
+	if len(missing) > 0 {
+		opts, err := analysisOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if len(opts.translationLanguages) > 1 {
+			return errors.New("i18n translate requires a single --translation-language")
+		}
+
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		defer opts.tracer.Flush()
+		warmUp(ctx, opts)
+
+		if opts.sourceLanguage == "" {
+			messages := make([]string, len(missing))
+			for i, node := range missing {
+				messages[i], _, _, _ = maskMessage(node.Value)
+			}
+			if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(messages, " "), readabilitySampleWords), opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+
+		opts.progress.Start(len(missing))
+		translate := func(_ int, node *yaml.Node) (string, error) {
+			opts.progress.Begin(node.Value)
+			translation, err := translateMessage(ctx, node.Value, opts)
+			if err != nil {
+				return "", err
+			}
+			opts.progress.Advance()
+			return translation, nil
+		}
+		translations, err := analyze.RunOrdered(missing, opts.concurrency, translate, nil)
+		opts.progress.Finish()
+		if err != nil {
+			return runError(ctx, opts, err)
+		}
+		for i, node := range missing {
+			node.Value = translations[i]
+		}
+	}
+
+	var b bytes.Buffer
+	if targetJSON {
+		writeBundleJSON(&b, merged, indent, 0)
+		b.WriteByte('\n')
+	} else {
+		encoder := yaml.NewEncoder(&b)
+		encoder.SetIndent(max(len(indent), 2))
+		if err := encoder.Encode(merged); err != nil {
+			return fmt.Errorf("writing bundle: %w", err)
+		}
+		encoder.Close()
+	}
+	if err := writeFileAtomic(targetPath, b.Bytes(), true); err != nil {
+		return fmt.Errorf("writing target bundle: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d translated messages to %s\n", len(missing), targetPath)
+	return nil
+}
+
+// bundleFormat reports whether the bundle at path is JSON rather than YAML.
+func bundleFormat(path string) (bool, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return true, nil
+	case ".yaml", ".yml":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s: unsupported bundle format (expected .json, .yaml or .yml)", path)
+}
+
+// parseBundle reads a JSON or YAML bundle, keeping the order of its keys.
+// JSON is read as the YAML it also is.
+func parseBundle(text string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return nil, fmt.Errorf("parsing bundle: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("parsing bundle: expected an object of messages")
+	}
+	return doc.Content[0], nil
+}
+
+// bundleIndent returns the indentation of the first indented line of text,
+// two spaces when there is none.
+func bundleIndent(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]; indent != "" && strings.TrimSpace(line) != "" {
+			return indent
+		}
+	}
+	return "  "
+}
+
+// mergeBundle returns the messages of target along with the ones of source
+// it lacks, in the order of source, adding the strings to translate to
+// missing. The nodes taken from source lose their style when restyle is
+// set, since the bundles are written in another format.
+func mergeBundle(source, target *yaml.Node, restyle bool, missing *[]*yaml.Node) *yaml.Node {
+	if target != nil && target.Kind == yaml.AliasNode {
+		target = target.Alias
+	}
+	if source.Kind == yaml.AliasNode {
+		source = source.Alias
+	}
+	switch source.Kind {
+	case yaml.MappingNode:
+		merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: source.Style}
+		if restyle {
+			merged.Style = 0
+		}
+		values := make(map[string]*yaml.Node)
+		if target != nil && target.Kind == yaml.MappingNode {
+			merged.Style = target.Style
+			for i := 0; i+1 < len(target.Content); i += 2 {
+				values[target.Content[i].Value] = target.Content[i+1]
+			}
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(source.Content); i += 2 {
+			key := *source.Content[i]
+			if restyle {
+				key.Style = 0
+			}
+			seen[key.Value] = true
+			merged.Content = append(merged.Content, &key, mergeBundle(source.Content[i+1], values[key.Value], restyle, missing))
+		}
+		if target != nil && target.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(target.Content); i += 2 {
+				if !seen[target.Content[i].Value] {
+					merged.Content = append(merged.Content, target.Content[i], target.Content[i+1])
+				}
+			}
+		}
+		return merged
+	case yaml.SequenceNode:
+		merged := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: source.Style}
+		if restyle {
+			merged.Style = 0
+		}
+		var values []*yaml.Node
+		if target != nil && target.Kind == yaml.SequenceNode {
+			merged.Style = target.Style
+			values = target.Content
+		}
+		for i, item := range source.Content {
+			var value *yaml.Node
+			if i < len(values) {
+				value = values[i]
+			}
+			merged.Content = append(merged.Content, mergeBundle(item, value, restyle, missing))
+		}
+		if len(values) > len(source.Content) {
+			merged.Content = append(merged.Content, values[len(source.Content):]...)
+		}
+		return merged
+	}
+
+	if target != nil && target.Kind == yaml.ScalarNode && (target.Tag != "!!str" || strings.TrimSpace(target.Value) != "" || source.Tag != "!!str") {
+		return target
+	}
+	node := *source
+	node.Anchor = ""
+	if restyle {
+		node.Style = 0
+	}
+	if node.Tag == "!!str" && strings.TrimSpace(node.Value) != "" {
+		*missing = append(*missing, &node)
+	}
+	return &node
+}
+
+// translateMessage translates message, keeping its placeholders and the
+// whitespace around it. Messages whose translation loses any placeholder
+// are kept untranslated, with a warning.
+func translateMessage(ctx context.Context, message string, opts analysisOptions) (string, error) {
+	text, kept, prefix, suffix := maskMessage(message)
+	if strings.IndexFunc(text, unicode.IsLetter) < 0 {
+		return message, nil
+	}
+	translation, err := translateSection(ctx, text, opts.sourceLanguage, opts.translationLanguage, opts.glossary, opts)
+	if err != nil {
+		return "", err
+	}
+	translation, ok := restorePlaceholders(strings.TrimSpace(translation), kept)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Warning: keeping %q untranslated, its translation lost some of its placeholders\n", message)
+		return message, nil
+	}
+	return prefix + translation + suffix, nil
+}
+
+// writeBundleJSON writes node as indented JSON, keeping the order of its
+// keys.
+func writeBundleJSON(b *bytes.Buffer, node *yaml.Node, indent string, depth int) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	str := func(value string) {
+		encoder := json.NewEncoder(b)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(value)
+		b.Truncate(b.Len() - 1)
+	}
+	newline := func(depth int) {
+		b.WriteString("\n" + strings.Repeat(indent, depth))
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			str(node.Content[i].Value)
+			b.WriteString(": ")
+			writeBundleJSON(b, node.Content[i+1], indent, depth+1)
+		}
+		newline(depth)
+		b.WriteByte('}')
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			writeBundleJSON(b, item, indent, depth+1)
+		}
+		newline(depth)
+		b.WriteByte(']')
+	default:
+		switch node.Tag {
+		case "!!int", "!!float", "!!bool":
+			var value interface{}
+			if err := node.Decode(&value); err == nil {
+				if data, err := json.Marshal(value); err == nil {
+					b.Write(data)
+					return
+				}
+			}
+		case "!!null":
+			b.WriteString("null")
+			return
+		}
+		str(node.Value)
+	}
+}
+
+func init() {
+	addAnalysisFlags(i18nTranslateCmd.Flags())
+	i18nTranslateCmd.Flags().String("to", "", "The bundle to fill in with the missing messages, such as de.json, created when it doesn't exist")
+
+	i18nCmd.AddCommand(i18nTranslateCmd)
+}
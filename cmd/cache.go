@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// responseCache stores LLM responses on disk, keyed by a hash of everything
+// that determines them, so repeated runs don't pay for the same requests. A
+// nil *responseCache is valid and caches nothing.
+type responseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cacheEntry is the content of a cache file.
+type cacheEntry struct {
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Response  string    `json:"response"`
+}
+
+// defaultCacheDir returns the cache location used unless
+// STARTER_GO_CLI_CACHE_DIR or the config file say otherwise.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "starter-go-cli")
+}
+
+// cacheKey hashes the request fields that determine the model's response,
+// along with the provider and host answering it.
+func cacheKey(provider, host string, req llm.Request) string {
+	data, _ := json.Marshal(struct {
+		Provider string                 `json:"provider,omitempty"`
+		Host     string                 `json:"host,omitempty"`
+		Model    string                 `json:"model"`
+		System   string                 `json:"system,omitempty"`
+		Examples []llm.Example          `json:"examples,omitempty"`
+		Prompt   string                 `json:"prompt"`
+		Options  map[string]interface{} `json:"options,omitempty"`
+		Schema   json.RawMessage        `json:"schema,omitempty"`
+		Images   [][]byte               `json:"images,omitempty"`
+	}{provider, host, req.Model, req.System, req.Examples, req.Prompt, req.Options, req.Schema, req.Images})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Permanent returns the cache with entries that never expire, for the
+// responses that cannot change over time, such as conjugations.
+func (c *responseCache) Permanent() *responseCache {
+	if c == nil {
+		return nil
+	}
+	return &responseCache{dir: c.dir}
+}
+
+func (c *responseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get returns the cached response for key, unless it is missing or expired.
+func (c *responseCache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return "", false
+	}
+	if c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl {
+		return "", false
+	}
+	return entry.Response, true
+}
+
+// Put stores response under key. Failing to write the cache never fails the
+// run, so errors are only reported.
+func (c *responseCache) Put(key, model, response string) error {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(cacheEntry{Model: model, CreatedAt: time.Now(), Response: response})
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, true)
+}
+
+// newResponseCache returns the cache in dir, or nil when caching is disabled.
+func newResponseCache(disabled bool, dir string, ttl time.Duration) (*responseCache, error) {
+	if disabled {
+		return nil, nil
+	}
+	if dir == "" {
+		return nil, errors.New("no cache directory available: set STARTER_GO_CLI_CACHE_DIR or use --no-cache")
+	}
+	return &responseCache{dir: dir, ttl: ttl}, nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// copyModes are what --copy places on the clipboard: the output as it is
+// printed, or the text of the translations alone.
+var copyModes = []string{"output", "translation"}
+
+// checkCopyMode reports whether mode is one of copyModes, or empty when
+// --copy is not set.
+func checkCopyMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, known := range copyModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported copy mode %q (expected %s)", mode, strings.Join(copyModes, " or "))
+}
+
+// clipboardTool is a command reading or writing the system clipboard.
+type clipboardTool struct {
+	name string
+	args []string
+}
+
+// clipboardTools returns the commands that read and write the clipboard on
+// this system, in the order they are tried.
+func clipboardTools() (readers, writers []clipboardTool) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardTool{{"pbpaste", nil}}, []clipboardTool{{"pbcopy", nil}}
+	case "windows":
+		// clip reads UTF-16 with a byte order mark as Unicode, and
+		// PowerShell prints the clipboard in the encoding it is told.
+		return []clipboardTool{{"powershell", []string{"-NoProfile", "-NonInteractive", "-Command", "[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard -Raw"}}},
+			[]clipboardTool{{"clip", nil}}
+	}
+	readers = []clipboardTool{{"xclip", []string{"-selection", "clipboard", "-out"}}, {"xsel", []string{"--clipboard", "--output"}}}
+	writers = []clipboardTool{{"xclip", []string{"-selection", "clipboard", "-in"}}, {"xsel", []string{"--clipboard", "--input"}}}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		readers = append([]clipboardTool{{"wl-paste", []string{"--no-newline"}}}, readers...)
+		writers = append([]clipboardTool{{"wl-copy", nil}}, writers...)
+	}
+	return readers, writers
+}
+
+// findClipboardTool returns the first of tools installed.
+func findClipboardTool(tools []clipboardTool) (clipboardTool, error) {
+	var names []string
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool.name); err == nil {
+			return tool, nil
+		}
+		names = append(names, tool.name)
+	}
+	return clipboardTool{}, fmt.Errorf("no clipboard command found (install %s)", strings.Join(names, " or "))
+}
+
+// readClipboard returns the text on the system clipboard.
+func readClipboard() (string, error) {
+	readers, _ := clipboardTools()
+	tool, err := findClipboardTool(readers)
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	command := exec.Command(tool.name, tool.args...)
+	command.Stderr = &stderr
+	data, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s: %w: %s", tool.name, err, strings.TrimSpace(stderr.String()))
+	}
+	text, err := decodeText(data)
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("the clipboard has no text")
+	}
+	return text, nil
+}
+
+// writeClipboard places text on the system clipboard.
+func writeClipboard(text string) error {
+	_, writers := clipboardTools()
+	tool, err := findClipboardTool(writers)
+	if err != nil {
+		return err
+	}
+	input := []byte(text)
+	if tool.name == "clip" {
+		input = binary.LittleEndian.AppendUint16(nil, 0xfeff)
+		for _, unit := range utf16.Encode([]rune(text)) {
+			input = binary.LittleEndian.AppendUint16(input, unit)
+		}
+	}
+	var stderr bytes.Buffer
+	command := exec.Command(tool.name, tool.args...)
+	command.Stdin = bytes.NewReader(input)
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("running %s: %w: %s", tool.name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// translationText returns the translations of analyses as plain text: the
+// translations of the sections of every analysis joined into a paragraph,
+// one per language when there are several, prefixed by the language.
+func translationText(analyses []Analysis) string {
+	var paragraphs []string
+	for _, analysis := range analyses {
+		if len(analysis.TranslationLanguages) == 0 {
+			var sections []string
+			for _, item := range analysis.Results {
+				if item.Translation != "" {
+					sections = append(sections, item.Translation)
+				}
+			}
+			paragraphs = append(paragraphs, strings.Join(sections, " "))
+			continue
+		}
+		for _, language := range analysis.TranslationLanguages {
+			var sections []string
+			for _, item := range analysis.Results {
+				if translation := item.Translations[language]; translation != "" {
+					sections = append(sections, translation)
+				}
+			}
+			paragraphs = append(paragraphs, language+": "+strings.Join(sections, " "))
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// clozeBlank replaces the word of a cloze in its question.
+const clozeBlank = "____"
+
+// clozeCard is a section with its key word blanked out.
+type clozeCard struct {
+	Source      string `json:"source" yaml:"source"`
+	Translation string `json:"translation,omitempty" yaml:"translation,omitempty"`
+	// Word is the answer, as it appears in Source, and Hint helps finding
+	// it, such as its translation.
+	Word string `json:"word" yaml:"word"`
+	Hint string `json:"hint,omitempty" yaml:"hint,omitempty"`
+	// Cloze is Source with Word blanked out.
+	Cloze string `json:"cloze" yaml:"cloze"`
+	// Deck is the name of the results the section was read from.
+	Deck string `json:"deck,omitempty" yaml:"deck,omitempty"`
+}
+
+// clozeDeck is the output of the cloze command.
+type clozeDeck struct {
+	Usage  *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Clozes []clozeCard  `json:"clozes" yaml:"clozes"`
+}
+
+var clozeCmd = &cobra.Command{
+	Use:   "cloze <results.json>...",
+	Short: "Turn the sections of analise results into cloze cards",
+	Long: `The "cloze" command reads the JSON results of "analise" (from the given files, or stdin when the argument is "-") and asks the LLM for the key vocabulary word of every section, which is blanked out, along with a hint written in the --translation-language.
+The clozes are written as JSON, which "quiz" drills and "flashcards" turns into basic cards, or with --format anki as a deck of Anki's Cloze note type, with the translation of the section as its extra information. Each input file is its own Anki deck, named after the file unless --deck is set.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCloze,
+}
+
+func runCloze(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "json" && format != "yaml" && format != "anki" {
+		return fmt.Errorf("unsupported format %q (expected json, yaml or anki)", format)
+	}
+	separatorName, err := cmd.Flags().GetString("separator")
+	if err != nil {
+		return fmt.Errorf("retrieving separator flag: %w", err)
+	}
+	separator, err := parseSeparator(separatorName)
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return fmt.Errorf("retrieving tag flag: %w", err)
+	}
+	deckName, err := cmd.Flags().GetString("deck")
+	if err != nil {
+		return fmt.Errorf("retrieving deck flag: %w", err)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+
+	usageBefore := opts.usage.Snapshot()
+	deck := clozeDeck{Clozes: []clozeCard{}}
+	for _, path := range args {
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		analyses, err := parseAnalyses(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fileDeck := deckName
+		switch {
+		case fileDeck != "":
+		case path == "-":
+			fileDeck = "Default"
+		default:
+			fileDeck = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+		}
+		for _, analysis := range analyses {
+			clozes, err := makeClozes(ctx, analysis, opts)
+			if err != nil {
+				return runError(ctx, opts, err)
+			}
+			for _, card := range clozes {
+				card.Deck = fileDeck
+				deck.Clozes = append(deck.Clozes, card)
+			}
+		}
+	}
+	if len(deck.Clozes) == 0 {
+		return errors.New("no sections to turn into clozes")
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	deck.Usage = &usage
+
+	var b bytes.Buffer
+	switch format {
+	case "anki":
+		cards := make([]flashcard, 0, len(deck.Clozes))
+		for _, card := range deck.Clozes {
+			cards = append(cards, flashcard{Front: ankiCloze(card), Back: card.Translation, Deck: card.Deck})
+		}
+		err = writeAnkiDeck(&b, cards, separator, tags, false, true)
+	case "yaml":
+		err = writeYAML(&b, deck)
+	default:
+		err = writeJSON(&b, deck)
+	}
+	if err != nil {
+		return fmt.Errorf("writing clozes: %w", err)
+	}
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d clozes to %s\n", len(deck.Clozes), outputPath)
+	return nil
+}
+
+// makeClozes asks the LLM for the key word of every section of analysis.
+// Sections in several languages are written with the first one as the
+// translation.
+func makeClozes(ctx context.Context, analysis Analysis, opts analysisOptions) ([]clozeCard, error) {
+	if analysis.SourceLanguage != "" {
+		opts.sourceLanguage = analysis.SourceLanguage
+	}
+	opts.progress.Start(len(analysis.Results))
+	defer opts.progress.Finish()
+
+	cloze := func(_ int, item ResultItem) (clozeCard, error) {
+		opts.progress.Begin(item.Source)
+		translation := item.Translation
+		if translation == "" && len(analysis.TranslationLanguages) > 0 {
+			translation = item.Translations[analysis.TranslationLanguages[0]]
+		}
+		card, err := clozeSection(ctx, item.Source, opts)
+		if err != nil {
+			return clozeCard{}, err
+		}
+		card.Translation = translation
+		opts.progress.Advance()
+		return card, nil
+	}
+	return analyze.RunOrdered(analysis.Results, opts.concurrency, cloze, nil)
+}
+
+// clozeSection asks the LLM for the key word of section and blanks it out.
+func clozeSection(ctx context.Context, section string, opts analysisOptions) (clozeCard, error) {
+	req, err := renderPrompt(defaultClozePrompt, opts.translateModel, promptData{Text: section, Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return clozeCard{}, err
+	}
+	var answer struct {
+		Word string `json:"word"`
+		Hint string `json:"hint"`
+	}
+	if err := generateJSON(ctx, opts, req, &answer); err != nil {
+		return clozeCard{}, fmt.Errorf("picking the key word of %q: %w", section, err)
+	}
+
+	// The word is blanked out as it appears in the section, whatever its
+	// case in the response.
+	word := strings.TrimSpace(answer.Word)
+	start := -1
+	if word != "" {
+		start = strings.Index(section, word)
+		// Lowercasing keeps the offsets as long as it keeps the length.
+		if lower := strings.ToLower(section); start < 0 && len(lower) == len(section) {
+			word = strings.ToLower(word)
+			start = strings.Index(lower, word)
+		}
+	}
+	if start < 0 {
+		return clozeCard{}, parseError(fmt.Errorf("picking the key word of %q: %q is not in the section", section, answer.Word))
+	}
+	word = section[start : start+len(word)]
+	return clozeCard{
+		Source: section,
+		Word:   word,
+		Hint:   strings.TrimSpace(answer.Hint),
+		Cloze:  section[:start] + clozeBlank + section[start+len(word):],
+	}, nil
+}
+
+// ankiCloze returns the source of card with its word as an Anki cloze
+// deletion, along with the hint.
+func ankiCloze(card clozeCard) string {
+	deletion := "{{c1::" + card.Word
+	if card.Hint != "" {
+		deletion += "::" + card.Hint
+	}
+	return strings.Replace(card.Cloze, clozeBlank, deletion+"}}", 1)
+}
+
+// clozeFlashcards has a flashcard per cloze, with the hint after the blanked
+// out section on the front and the word on the back.
+func clozeFlashcards(deck clozeDeck) []flashcard {
+	cards := make([]flashcard, 0, len(deck.Clozes))
+	for _, card := range deck.Clozes {
+		front := card.Cloze
+		if card.Hint != "" {
+			front += " (" + card.Hint + ")"
+		}
+		cards = append(cards, flashcard{Front: front, Back: card.Word, Notes: card.Translation})
+	}
+	return cards
+}
+
+func init() {
+	addAnalysisFlags(clozeCmd.Flags())
+	clozeCmd.Flags().String("format", "json", "The output format: json, for quiz and flashcards, yaml, or anki for a deck of Anki's Cloze note type")
+	clozeCmd.Flags().String("separator", "tab", "The field separator of --format anki: tab, comma, semicolon, pipe, colon, space or a single character")
+	clozeCmd.Flags().StringArray("tag", nil, "A tag added to every note of --format anki (repeatable)")
+	clozeCmd.Flags().String("deck", "", "The name of the deck (default is the name of each input file)")
+	clozeCmd.Flags().StringP("output", "o", "", "Write the clozes to this file instead of stdout")
+	clozeCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	rootCmd.AddCommand(clozeCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+)
+
+// segmentAndTranslate divides text into sections and translates them with a
+// single request, trading the per-section prompts for lower latency.
+func segmentAndTranslate(ctx context.Context, text string, opts analysisOptions) ([]ResultItem, error) {
+	data := segmentPromptData(text, opts)
+	data.Glossary = analyze.GlossaryTermsIn(opts.glossary, text)
+	req, err := renderPrompt(opts.combinedPrompt, opts.translateModel, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []ResultItem
+	if err := generateJSON(ctx, opts, req, &items); err != nil {
+		return nil, fmt.Errorf("segmenting and translating text: %w", err)
+	}
+
+	results := make([]ResultItem, 0, len(items))
+	for _, item := range items {
+		source := strings.TrimSpace(item.Source)
+		if source == "" {
+			continue
+		}
+		results = append(results, ResultItem{Source: source, Translation: strings.TrimSpace(item.Translation)})
+	}
+	if len(results) == 0 && strings.TrimSpace(text) != "" {
+		return nil, parseError(errors.New("segmenting and translating text: the model returned no sections"))
+	}
+	return results, nil
+}
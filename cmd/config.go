@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the optional YAML configuration file. Every setting can be
+// overridden by its environment variable, which in turn is overridden by its
+// command-line flag.
+type config struct {
+	LLMHost string `yaml:"llm_host"`
+	// Proxy is the URL of the HTTP, HTTPS or SOCKS5 proxy, see --proxy.
+	Proxy string `yaml:"proxy"`
+	// OTelEndpoint is the OTLP/HTTP collector of the traces, see
+	// --otel-endpoint.
+	OTelEndpoint string `yaml:"otel_endpoint"`
+	// The TLS settings, see --ca-cert, --client-cert, --client-key and
+	// --insecure-skip-verify.
+	CACert             string `yaml:"ca_cert"`
+	ClientCert         string `yaml:"client_cert"`
+	ClientKey          string `yaml:"client_key"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	// Headers are added to the requests to the LLM host, see --header.
+	Headers map[string]string `yaml:"headers"`
+	// APIKey is the bearer token of the LLM host, see --api-key.
+	APIKey string `yaml:"api_key"`
+	// Provider selects the backend, see --provider.
+	Provider string `yaml:"provider"`
+	// OllamaAPI is chat or generate, see --ollama-api.
+	OllamaAPI string `yaml:"ollama_api"`
+	// KeepAlive is how long Ollama keeps the model loaded, see --keep-alive.
+	KeepAlive      string `yaml:"keep_alive"`
+	Translator     string `yaml:"translator"`
+	TranslatorHost string `yaml:"translator_host"`
+	// Formality is the DeepL formality, see --formality.
+	Formality           string `yaml:"formality"`
+	GoogleProject       string `yaml:"google_project"`
+	GoogleLocation      string `yaml:"google_location"`
+	GoogleGlossary      string `yaml:"google_glossary"`
+	TranslationLanguage string `yaml:"translation_language"`
+	// TranslationLanguages takes precedence over TranslationLanguage.
+	TranslationLanguages []string               `yaml:"translation_languages"`
+	SourceLanguage       string                 `yaml:"source_language"`
+	Model                string                 `yaml:"model"`
+	SegmentModel         string                 `yaml:"segment_model"`
+	TranslateModel       string                 `yaml:"translate_model"`
+	Glossary             string                 `yaml:"glossary"`
+	Options              map[string]interface{} `yaml:"options"`
+	CacheDir             string                 `yaml:"cache_dir"`
+	// APIKeys holds the API key of each provider, keyed by provider name.
+	APIKeys map[string]string `yaml:"api_keys"`
+	// SafetySettings maps Gemini harm categories to their thresholds.
+	SafetySettings  map[string]string `yaml:"safety_settings"`
+	AzureAPIVersion string            `yaml:"azure_api_version"`
+	AzureDeployment string            `yaml:"azure_deployment"`
+	// AzureDeployments maps model names to the Azure OpenAI deployments
+	// serving them; other models are deployed under their own name.
+	AzureDeployments map[string]string `yaml:"azure_deployments"`
+	AWSRegion        string            `yaml:"aws_region"`
+	// Prices are the prices of the models of the paid providers in US
+	// dollars per million tokens, keyed by model name or prefix, see
+	// --max-cost.
+	Prices map[string]modelPrice `yaml:"prices"`
+	// Failover lists the providers taking over, in order, when the previous
+	// one fails, see --failover.
+	Failover []failoverConfig `yaml:"failover"`
+	// SRSDeck is the file the review deck is stored in, see srs --deck.
+	SRSDeck string `yaml:"srs_deck"`
+	// Detector detects the language of the texts, see --detector.
+	Detector string `yaml:"detector"`
+	// WhisperHost and WhisperModel transcribe the --audio of analise, see
+	// --whisper-host and --whisper-model.
+	WhisperHost  string `yaml:"whisper_host"`
+	WhisperModel string `yaml:"whisper_model"`
+	// TTSHost, TTSModel and TTSVoice read the sections aloud, see
+	// --tts-host, --tts-model and --tts-voice.
+	TTSHost  string `yaml:"tts_host"`
+	TTSModel string `yaml:"tts_model"`
+	TTSVoice string `yaml:"tts_voice"`
+	// VisionModel reads the --image of analise, see --vision-model.
+	VisionModel string `yaml:"vision_model"`
+}
+
+// failoverConfig is an entry of the failover list of the config file.
+// Model, when empty, is the requested model for the same provider and the
+// default model of any other one.
+type failoverConfig struct {
+	Provider string `yaml:"provider"`
+	LLMHost  string `yaml:"llm_host"`
+	Model    string `yaml:"model"`
+}
+
+var (
+	loadedConfig     *config
+	loadedConfigPath string
+)
+
+// defaultConfigPath returns the location of the configuration file used when
+// neither --config nor STARTER_GO_CLI_CONFIG are set.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "starter-go-cli", "config.yaml")
+}
+
+// loadConfig reads the configuration file selected by --config, the
+// STARTER_GO_CLI_CONFIG environment variable or the default location. A
+// missing file at the default location yields an empty configuration.
+func loadConfig(cmd *cobra.Command) (*config, error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return nil, err
+	}
+	explicit := true
+	if path == "" {
+		path = os.Getenv("STARTER_GO_CLI_CONFIG")
+	}
+	if path == "" {
+		path, explicit = defaultConfigPath(), false
+	}
+
+	if loadedConfig != nil && loadedConfigPath == path {
+		return loadedConfig, nil
+	}
+
+	cfg := &config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return nil, fmt.Errorf("reading config file: %w", err)
+		default:
+			if err := yaml.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+			}
+		}
+	}
+
+	loadedConfig, loadedConfigPath = cfg, path
+	return cfg, nil
+}
+
+// resolveSetting returns the value of a string flag, falling back to the
+// environment variable env, then to the configured value and finally to
+// fallback. A flag given on the command line wins even when empty, which
+// restores fallback whatever env and the configuration set.
+func resolveSetting(cmd *cobra.Command, flag, env, configured, fallback string) (string, error) {
+	if flag != "" && cmd.Flags().Changed(flag) {
+		value, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return "", err
+		}
+		if value == "" {
+			return fallback, nil
+		}
+		return value, nil
+	}
+	if env != "" {
+		if value := os.Getenv(env); value != "" {
+			return value, nil
+		}
+	}
+	if configured != "" {
+		return configured, nil
+	}
+	return fallback, nil
+}
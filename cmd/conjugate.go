@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// conjugation is the output of the conjugate command.
+type conjugation struct {
+	Verb        string       `json:"verb" yaml:"verb"`
+	Language    string       `json:"language" yaml:"language"`
+	Infinitive  string       `json:"infinitive" yaml:"infinitive"`
+	Translation string       `json:"translation,omitempty" yaml:"translation,omitempty"`
+	Tenses      []verbTense  `json:"tenses" yaml:"tenses"`
+	Usage       *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+}
+
+// verbTense holds the forms of a verb in a tense and mood.
+type verbTense struct {
+	Mood  string     `json:"mood,omitempty" yaml:"mood,omitempty"`
+	Tense string     `json:"tense" yaml:"tense"`
+	Forms []verbForm `json:"forms" yaml:"forms"`
+}
+
+// verbForm is the form of a verb for a grammatical person.
+type verbForm struct {
+	Person string `json:"person" yaml:"person"`
+	Form   string `json:"form" yaml:"form"`
+}
+
+var conjugateCmd = &cobra.Command{
+	Use:   "conjugate <verb>",
+	Short: "Print the conjugation table of a verb",
+	Long: `The "conjugate" command asks the LLM for the forms of a verb in every tense and mood of its language, for every grammatical person, along with the translation of its infinitive into the --translation-language.
+The language of the verb must be given with --language, since a single word is not enough to detect it. Conjugations never change, so they are cached without expiring regardless of --cache-ttl.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runConjugate,
+}
+
+func runConjugate(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if err := checkDocumentFormat(format); err != nil {
+		return err
+	}
+	verb := strings.TrimSpace(args[0])
+	if verb == "" {
+		return errors.New("the verb is empty")
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	language, err := resolveWordLanguage(cmd, opts)
+	if err != nil {
+		return err
+	}
+	opts.cache = opts.cache.Permanent()
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+
+	table, err := conjugateVerb(ctx, verb, language, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	header := []string{"mood", "tense", "person", "form"}
+	var rows [][]string
+	for _, tense := range table.Tenses {
+		for _, form := range tense.Forms {
+			rows = append(rows, []string{tense.Mood, tense.Tense, form.Person, form.Form})
+		}
+	}
+	if format == "table" && table.Translation != "" {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n\n", table.Infinitive, table.Translation)
+	}
+	if err := writeDocument(cmd.OutOrStdout(), format, table, header, rows); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// conjugateVerb asks the LLM for the conjugation table of verb, written in
+// language.
+func conjugateVerb(ctx context.Context, verb, language string, opts analysisOptions) (conjugation, error) {
+	usageBefore := opts.usage.Snapshot()
+	req, err := renderPrompt(defaultConjugatePrompt, opts.translateModel, promptData{Text: verb, Language: opts.translationLanguage, SourceLanguage: language})
+	if err != nil {
+		return conjugation{}, err
+	}
+
+	table := conjugation{Verb: verb, Language: language}
+	if err := generateJSON(ctx, opts, req, &table); err != nil {
+		return conjugation{}, fmt.Errorf("conjugating %q: %w", verb, err)
+	}
+	// The model must not rename the verb or the language it was asked for.
+	table.Verb, table.Language = verb, language
+	if len(table.Tenses) == 0 {
+		return conjugation{}, parseError(fmt.Errorf("conjugating %q: no tenses in the response, is it a %s verb?", verb, language))
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	table.Usage = &usage
+	return table, nil
+}
+
+func init() {
+	addAnalysisFlags(conjugateCmd.Flags())
+	conjugateCmd.Flags().String("language", "", "The language of the verb as a BCP 47 tag, such as de-DE (defaults to --source-language)")
+	conjugateCmd.Flags().String("format", "table", "The output format: table, json, yaml or csv")
+
+	rootCmd.AddCommand(conjugateCmd)
+}
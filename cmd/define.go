@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// dictionaryEntry is the output of the define command.
+type dictionaryEntry struct {
+	Word         string       `json:"word" yaml:"word"`
+	Language     string       `json:"language" yaml:"language"`
+	Lemma        string       `json:"lemma" yaml:"lemma"`
+	PartOfSpeech string       `json:"part_of_speech,omitempty" yaml:"part_of_speech,omitempty"`
+	Gender       string       `json:"gender,omitempty" yaml:"gender,omitempty"`
+	Plural       string       `json:"plural,omitempty" yaml:"plural,omitempty"`
+	Register     string       `json:"register,omitempty" yaml:"register,omitempty"`
+	Senses       []wordSense  `json:"senses" yaml:"senses"`
+	Usage        *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+}
+
+// wordSense is a meaning of a word, defined in its own language.
+type wordSense struct {
+	Definition  string        `json:"definition" yaml:"definition"`
+	Translation string        `json:"translation,omitempty" yaml:"translation,omitempty"`
+	Examples    []exampleText `json:"examples,omitempty" yaml:"examples,omitempty"`
+}
+
+// exampleText is an example sentence along with its translation.
+type exampleText struct {
+	Text        string `json:"text" yaml:"text"`
+	Translation string `json:"translation,omitempty" yaml:"translation,omitempty"`
+}
+
+var defineCmd = &cobra.Command{
+	Use:   "define <word>",
+	Short: "Print the dictionary entry of a word",
+	Long: `The "define" command asks the LLM for the dictionary entry of a word: its dictionary form, part of speech, gender and plural form when its language has them, and register, followed by its senses.
+Every sense is defined in the language of the word and comes with the translation of the word into the --translation-language and two or three example sentences with their translations.
+The entry is printed as a card for the terminal, or as JSON or YAML with --format. The language of the word must be given with --language, since a single word is not enough to detect it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDefine,
+}
+
+func runDefine(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "card" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q (expected card, json or yaml)", format)
+	}
+	word := strings.TrimSpace(args[0])
+	if word == "" {
+		return errors.New("the word is empty")
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	language, err := resolveWordLanguage(cmd, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+
+	entry, err := defineWord(ctx, word, language, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	switch format {
+	case "json":
+		err = writeJSON(cmd.OutOrStdout(), entry)
+	case "yaml":
+		err = writeYAML(cmd.OutOrStdout(), entry)
+	default:
+		err = writeDefinitionCard(cmd.OutOrStdout(), entry)
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// defineWord asks the LLM for the dictionary entry of word, written in
+// language.
+func defineWord(ctx context.Context, word, language string, opts analysisOptions) (dictionaryEntry, error) {
+	usageBefore := opts.usage.Snapshot()
+	req, err := renderPrompt(defaultDefinePrompt, opts.translateModel, promptData{Text: word, Language: opts.translationLanguage, SourceLanguage: language})
+	if err != nil {
+		return dictionaryEntry{}, err
+	}
+
+	var entry dictionaryEntry
+	if err := generateJSON(ctx, opts, req, &entry); err != nil {
+		return dictionaryEntry{}, fmt.Errorf("defining %q: %w", word, err)
+	}
+	entry.Word, entry.Language = word, language
+	if entry.Lemma == "" {
+		entry.Lemma = word
+	}
+	if len(entry.Senses) == 0 {
+		return dictionaryEntry{}, parseError(fmt.Errorf("defining %q: no senses in the response, is it a %s word?", word, language))
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	entry.Usage = &usage
+	return entry, nil
+}
+
+// writeDefinitionCard writes entry the way a printed dictionary lays it
+// out: the headword with its grammar, then the numbered senses with their
+// examples.
+func writeDefinitionCard(w io.Writer, entry dictionaryEntry) error {
+	var grammar []string
+	for _, part := range []string{entry.PartOfSpeech, entry.Gender} {
+		if part != "" {
+			grammar = append(grammar, part)
+		}
+	}
+	if entry.Plural != "" {
+		grammar = append(grammar, "plural: "+entry.Plural)
+	}
+
+	var b strings.Builder
+	b.WriteString(entry.Lemma)
+	if len(grammar) > 0 {
+		b.WriteString("  (" + strings.Join(grammar, ", ") + ")")
+	}
+	if entry.Register != "" {
+		b.WriteString("  [" + entry.Register + "]")
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat("=", len([]rune(entry.Lemma))) + "\n")
+
+	for i, sense := range entry.Senses {
+		fmt.Fprintf(&b, "\n%d. %s\n", i+1, sense.Definition)
+		if sense.Translation != "" {
+			fmt.Fprintf(&b, "   → %s\n", sense.Translation)
+		}
+		for _, example := range sense.Examples {
+			fmt.Fprintf(&b, "   • %s\n", example.Text)
+			if example.Translation != "" {
+				fmt.Fprintf(&b, "     %s\n", example.Translation)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func init() {
+	addAnalysisFlags(defineCmd.Flags())
+	defineCmd.Flags().String("language", "", "The language of the word as a BCP 47 tag, such as de-DE (defaults to --source-language)")
+	defineCmd.Flags().String("format", "card", "The output format: card, json or yaml")
+
+	rootCmd.AddCommand(defineCmd)
+}
@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// detectors are the ways of detecting the language of a text: "llm" asks the
+// LLM, "local" uses the detector built into the CLI without any request, and
+// "auto" asks the LLM only when the local detector is unsure.
+var detectors = []string{"llm", "local", "auto"}
+
+// autoDetectConfidence is the confidence of the local detector below which
+// the "auto" detector asks the LLM instead.
+const autoDetectConfidence = 0.8
+
+// languageDetection is the language of a text along with the confidence of
+// the detector, between 0 and 1, which is 0 when it didn't tell.
+type languageDetection struct {
+	Language   string  `json:"language" yaml:"language"`
+	Confidence float64 `json:"confidence,omitempty" yaml:"confidence,omitempty"`
+	Detector   string  `json:"detector" yaml:"detector"`
+}
+
+// scriptLanguages maps the scripts written by a single language to it.
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Bengali, "bn"},
+	{unicode.Tamil, "ta"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// letterHints are letters telling apart the languages sharing a script.
+var letterHints = map[string]string{
+	"uk": "іїєґ",
+	"be": "ў",
+	"sr": "ђјљњћџ",
+	"mk": "ѓќѕ",
+	"fa": "پچژگ",
+	"ur": "ٹڈڑںے",
+	"de": "ßäöü",
+	"es": "ñ¿¡",
+	"pt": "ãõ",
+	"fr": "œèêëîûù",
+	"pl": "łąęśźżń",
+	"cs": "řěů",
+	"tr": "ğşı",
+	"ro": "ășț",
+	"hu": "őű",
+	"sv": "å",
+	"da": "øæ",
+	"nb": "øæå",
+}
+
+// stopwords are the most frequent words of the languages written in Latin
+// script, which the local detector counts.
+var stopwords = map[string]string{
+	"en": "the and of to is in that it you for was with on are this be have not but they at he his she her what",
+	"de": "der die das und ist nicht ich du ein eine zu den mit sie es auf dem für von sich auch wir ihr",
+	"fr": "le la les et est un une des du que qui dans pas pour ne je il elle nous vous sur au avec ce",
+	"es": "el la los las y es un una que de en no por con para se lo del al como pero su yo muy",
+	"pt": "o a os as e é um uma que de em não para com do da por se no na mais eu você muito",
+	"it": "il lo la gli le e è un una che di in non per con del della sono ma si io anche mi",
+	"nl": "de het een en is van dat niet ik je in op te zijn voor met die er maar we ook",
+	"sv": "och är en ett att det som på inte jag du med för av har till den de men vi om",
+	"da": "og er en et at det som på ikke jeg du med for af har til den de men vi om hvad meget nu",
+	"nb": "og er en et at det som på ikke jeg du med for av har til den de men vi om hva veldig nå",
+	"pl": "i w nie to się na jest że z co do jak ale o tak jestem czy mnie go ten",
+	"cs": "a je to v se na že s z do jsem jak ale o tak není by co pro už",
+	"tr": "ve bir bu da de ne için ben sen o ile çok var mı değil gibi daha ama",
+	"ro": "și este un o că de în nu pe la cu se care mai ce sunt din pentru",
+	"hu": "a az és hogy nem egy is van de meg ez el csak már én te mi",
+	"fi": "ja on ei se että oli mutta hän minä sinä kuin niin tämä ovat olen",
+	"id": "dan yang di ini itu tidak dengan untuk dari saya ada akan ke juga",
+}
+
+var detectLanguageCmd = &cobra.Command{
+	Use:   "detect-language [text]",
+	Short: "Print the language of a text along with the confidence of the detection",
+	Long: `The "detect-language" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted) and prints its BCP 47 language tag along with the confidence of the detection, between 0 and 1.
+The --detector also used by every other command when --source-language is not set decides how: llm asks the LLM, local uses the detector built into the CLI, which knows the scripts and the most frequent words of the common languages and sends no request at all, and auto asks the LLM only when the local detector is less than 80% confident.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDetectLanguage,
+}
+
+func runDetectLanguage(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "text" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q (expected text, json or yaml)", format)
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+
+	detection, err := identifyLanguage(ctx, text, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	switch format {
+	case "json":
+		err = writeJSON(cmd.OutOrStdout(), detection)
+	case "yaml":
+		err = writeYAML(cmd.OutOrStdout(), detection)
+	default:
+		err = writeDetection(cmd.OutOrStdout(), detection)
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// checkDetector reports an error when name is not one of detectors.
+func checkDetector(name string) error {
+	for _, known := range detectors {
+		if name == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported detector %q (expected %s)", name, strings.Join(detectors, ", "))
+}
+
+// identifyLanguage detects the language of text with the --detector.
+func identifyLanguage(ctx context.Context, text string, opts analysisOptions) (languageDetection, error) {
+	if opts.detector == "local" || opts.detector == "auto" {
+		detection, ok := detectLanguageLocally(text)
+		if ok && (opts.detector == "local" || detection.Confidence >= autoDetectConfidence) {
+			return detection, nil
+		}
+		if opts.detector == "local" {
+			return languageDetection{}, errors.New("detecting source language: the text has too few known words; set it with --source-language or use --detector llm")
+		}
+	}
+	return detectLanguageWithLLM(ctx, text, opts)
+}
+
+// detectLanguageLocally detects the language of text from its scripts and,
+// for the Latin and Cyrillic ones, from the letters and the stopwords only
+// some languages have. It reports false when it can't tell.
+func detectLanguageLocally(text string) (languageDetection, bool) {
+	letters, kana, han, cyrillic, arabic, latin := 0, 0, 0, 0, 0, 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for _, entry := range scriptLanguages {
+				if unicode.Is(entry.script, r) {
+					scripts[entry.language]++
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return languageDetection{}, false
+	}
+	share := func(n int) float64 { return float64(n) / float64(letters) }
+	detected := func(language string, confidence float64) (languageDetection, bool) {
+		return languageDetection{Language: language, Confidence: roundScore(confidence), Detector: "local"}, true
+	}
+
+	// Japanese mixes kana with kanji, which Chinese writes alone.
+	if kana > 0 {
+		return detected("ja", share(kana+han))
+	}
+	if han > 0 && share(han) >= 0.5 {
+		return detected("zh", share(han))
+	}
+	for language, n := range scripts {
+		if share(n) >= 0.5 {
+			return detected(language, share(n))
+		}
+	}
+	lower := strings.ToLower(text)
+	if cyrillic > 0 && share(cyrillic) >= 0.5 {
+		language := bestHint(lower, []string{"uk", "be", "sr", "mk"})
+		if language == "" {
+			language = "ru"
+			if strings.Contains(lower, "ъ") && !strings.ContainsAny(lower, "ыэё") {
+				language = "bg"
+			}
+		}
+		return detected(language, share(cyrillic)*0.9)
+	}
+	if arabic > 0 && share(arabic) >= 0.5 {
+		language := bestHint(lower, []string{"ur", "fa"})
+		if language == "" {
+			language = "ar"
+		}
+		return detected(language, share(arabic)*0.9)
+	}
+	if latin == 0 {
+		return languageDetection{}, false
+	}
+	return detectLatinLanguage(lower)
+}
+
+// bestHint returns the language among candidates with the most of its
+// letterHints in text, or "" when text has none of them.
+func bestHint(text string, candidates []string) string {
+	best, bestCount := "", 0
+	for _, language := range candidates {
+		count := 0
+		for _, r := range letterHints[language] {
+			count += strings.Count(text, string(r))
+		}
+		if count > bestCount {
+			best, bestCount = language, count
+		}
+	}
+	return best
+}
+
+// detectLatinLanguage scores every language of stopwords by the stopwords of
+// text and its letterHints. The confidence is how far ahead of the second the
+// best score is, lowered for texts with less than five stopwords.
+func detectLatinLanguage(text string) (languageDetection, bool) {
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' })
+	scores := make(map[string]float64, len(stopwords))
+	hits := 0
+	for language, list := range stopwords {
+		known := make(map[string]bool)
+		for _, word := range strings.Fields(list) {
+			known[word] = true
+		}
+		for _, word := range words {
+			if known[word] {
+				scores[language]++
+			}
+		}
+		if int(scores[language]) > hits {
+			hits = int(scores[language])
+		}
+		for _, r := range letterHints[language] {
+			scores[language] += 0.5 * float64(strings.Count(text, string(r)))
+		}
+	}
+
+	languages := make([]string, 0, len(scores))
+	for language := range scores {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if scores[languages[i]] != scores[languages[j]] {
+			return scores[languages[i]] > scores[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+	best, second := languages[0], 0.0
+	if scores[best] == 0 {
+		return languageDetection{}, false
+	}
+	if len(languages) > 1 {
+		second = scores[languages[1]]
+	}
+	confidence := (1 - second/scores[best]) * min(1, float64(hits)/5)
+	return languageDetection{Language: best, Confidence: roundScore(confidence), Detector: "local"}, true
+}
+
+// writeDetection writes the language of detection, followed by its
+// confidence when the detector told it.
+func writeDetection(w io.Writer, detection languageDetection) error {
+	if detection.Confidence == 0 {
+		_, err := fmt.Fprintln(w, detection.Language)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %.2f\n", detection.Language, detection.Confidence)
+	return err
+}
+
+func init() {
+	addAnalysisFlags(detectLanguageCmd.Flags())
+	detectLanguageCmd.Flags().String("format", "text", "The output format: text, json or yaml")
+
+	rootCmd.AddCommand(detectLanguageCmd)
+}
@@ -0,0 +1,374 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// colorDiffMarks are the marks of the colored diffs: removed words in red
+// and added ones in green.
+var colorDiffMarks = diffMarks{"\033[31m", "\033[0m", "\033[32m", "\033[0m"}
+
+// sectionDiff is a section whose translation differs between two results.
+type sectionDiff struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Status is changed for a section of both results, or removed or added
+	// for one only in the first or the second.
+	Status   string `json:"status" yaml:"status"`
+	Source   string `json:"source" yaml:"source"`
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	// TranslationA and TranslationB are the translations of the section in
+	// the first and the second results.
+	TranslationA string `json:"translation_a,omitempty" yaml:"translation_a,omitempty"`
+	TranslationB string `json:"translation_b,omitempty" yaml:"translation_b,omitempty"`
+}
+
+// languageMismatch is a pair of analyses translated into languages the other
+// one doesn't have, which are left out of the comparison.
+type languageMismatch struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// OnlyA and OnlyB are the translation languages of the first and the
+	// second analysis only.
+	OnlyA []string `json:"only_a,omitempty" yaml:"only_a,omitempty"`
+	OnlyB []string `json:"only_b,omitempty" yaml:"only_b,omitempty"`
+}
+
+// resultsDiff is the output of the diff command.
+type resultsDiff struct {
+	A         string        `json:"a" yaml:"a"`
+	B         string        `json:"b" yaml:"b"`
+	Unchanged int           `json:"unchanged" yaml:"unchanged"`
+	Changed   int           `json:"changed" yaml:"changed"`
+	Removed   int           `json:"removed" yaml:"removed"`
+	Added     int           `json:"added" yaml:"added"`
+	Sections  []sectionDiff `json:"sections" yaml:"sections"`
+	// Mismatched are the analyses whose translation languages differ,
+	// compared only in the languages they share.
+	Mismatched []languageMismatch `json:"mismatched,omitempty" yaml:"mismatched,omitempty"`
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <resultsA.json> <resultsB.json>",
+	Short: "Compare the translations of two results of analise",
+	Long: `The "diff" command compares two JSON results of analise, such as the ones of two models or of two versions of a prompt, and shows the sections whose translation differs.
+The analyses of files are matched by their file, and their sections by their source, so that sections only one of the results has, when the text was segmented differently, are shown as removed or added. The translations into every language both results have are compared, for results translated into several; the languages only one of them has are reported instead, and analyses without a language in common are not compared.
+The text format shows the changes of every translation word by word, colored when printing to a terminal (see --color) or marked as [-removed-]{+added+} otherwise, followed by the counts of changed, removed, added and unchanged sections; use --format json or yaml for the differing sections and their translations.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "text" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q (expected text, json or yaml)", format)
+	}
+	color, err := cmd.Flags().GetString("color")
+	if err != nil {
+		return fmt.Errorf("retrieving color flag: %w", err)
+	}
+	if color != "auto" && color != "always" && color != "never" {
+		return fmt.Errorf("unsupported color %q (expected auto, always or never)", color)
+	}
+
+	var sides [2][]Analysis
+	for i, path := range args {
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		if sides[i], err = parseAnalyses(data); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	diff := diffResults(sides[0], sides[1])
+	diff.A, diff.B = args[0], args[1]
+
+	switch format {
+	case "json":
+		err = writeJSON(cmd.OutOrStdout(), diff)
+	case "yaml":
+		err = writeYAML(cmd.OutOrStdout(), diff)
+	default:
+		marks := plainDiffMarks
+		if out, ok := cmd.OutOrStdout().(*os.File); color == "always" || (color == "auto" && ok && isTerminal(out) && os.Getenv("NO_COLOR") == "") {
+			marks = colorDiffMarks
+		}
+		err = writeResultsDiff(cmd.OutOrStdout(), diff, marks)
+	}
+	if err != nil {
+		return fmt.Errorf("writing diff: %w", err)
+	}
+	return nil
+}
+
+// diffResults compares the translations of the analyses of a with the ones
+// of b.
+func diffResults(a, b []Analysis) resultsDiff {
+	diff := resultsDiff{Sections: []sectionDiff{}}
+	for _, pair := range pairAnalyses(a, b) {
+		diff.diffAnalyses(pair[0], pair[1])
+	}
+	return diff
+}
+
+// pairAnalyses pairs the analyses of a with the ones of b of the same file,
+// or at the same position when they have none. The analyses without a pair
+// are paired with nil.
+func pairAnalyses(a, b []Analysis) [][2]*Analysis {
+	key := func(analyses []Analysis, i int) string {
+		if analyses[i].File == "" {
+			return fmt.Sprintf("\x00%d", i)
+		}
+		return analyses[i].File
+	}
+	used := make([]bool, len(b))
+	var pairs [][2]*Analysis
+	for i := range a {
+		pair := [2]*Analysis{&a[i], nil}
+		for j := range b {
+			if !used[j] && key(a, i) == key(b, j) {
+				used[j], pair[1] = true, &b[j]
+				break
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	for j := range b {
+		if !used[j] {
+			pairs = append(pairs, [2]*Analysis{nil, &b[j]})
+		}
+	}
+	return pairs
+}
+
+// diffAnalyses adds the sections whose translations differ between a and b,
+// either of which may be nil.
+func (d *resultsDiff) diffAnalyses(a, b *Analysis) {
+	var itemsA, itemsB []ResultItem
+	var file string
+	if a != nil {
+		itemsA, file = a.Results, a.File
+	}
+	if b != nil {
+		itemsB = b.Results
+		if file == "" {
+			file = b.File
+		}
+	}
+
+	// Sections of both analyses are only compared in the languages both
+	// have; without any, the analyses are not compared at all.
+	languages := analysisLanguages(a)
+	if a != nil && b != nil {
+		var onlyA, onlyB []string
+		languages, onlyA, onlyB = sharedLanguages(analysisLanguages(a), analysisLanguages(b))
+		if len(onlyA) > 0 || len(onlyB) > 0 {
+			d.Mismatched = append(d.Mismatched, languageMismatch{File: file, OnlyA: onlyA, OnlyB: onlyB})
+		}
+		if len(languages) == 0 {
+			return
+		}
+	}
+
+	for _, match := range matchSections(itemsA, itemsB) {
+		i, j := match[0], match[1]
+		switch {
+		case j < 0:
+			for _, language := range analysisLanguages(a) {
+				d.Sections = append(d.Sections, sectionDiff{File: file, Status: "removed", Source: itemsA[i].Source, Language: language, TranslationA: itemTranslation(a, itemsA[i], language)})
+			}
+			d.Removed++
+		case i < 0:
+			for _, language := range analysisLanguages(b) {
+				d.Sections = append(d.Sections, sectionDiff{File: file, Status: "added", Source: itemsB[j].Source, Language: language, TranslationB: itemTranslation(b, itemsB[j], language)})
+			}
+			d.Added++
+		default:
+			changed := false
+			for _, language := range languages {
+				translationA, translationB := itemTranslation(a, itemsA[i], language), itemTranslation(b, itemsB[j], language)
+				if translationA != translationB {
+					d.Sections = append(d.Sections, sectionDiff{File: file, Status: "changed", Source: itemsA[i].Source, Language: language, TranslationA: translationA, TranslationB: translationB})
+					changed = true
+				}
+			}
+			if changed {
+				d.Changed++
+			} else {
+				d.Unchanged++
+			}
+		}
+	}
+}
+
+// matchSections pairs the sections of a with the ones of b of the same
+// source, in order, as the longest common subsequence of their sources.
+// Every section of a and b is returned once, as the pair of its index and
+// the one of its match, or -1 when it has none.
+func matchSections(a, b []ResultItem) [][2]int {
+	// The sections of the same segmentation are matched as they are, and
+	// the subsequence only searched in between the common prefix and suffix.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && memoryKey(a[prefix].Source) == memoryKey(b[prefix].Source) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && memoryKey(a[len(a)-1-suffix].Source) == memoryKey(b[len(b)-1-suffix].Source) {
+		suffix++
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lengths[i][j] is the length of the longest common subsequence of the
+	// sources of middleA[i:] and middleB[j:].
+	lengths := make([][]int, len(middleA)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(middleB)+1)
+	}
+	for i := len(middleA) - 1; i >= 0; i-- {
+		for j := len(middleB) - 1; j >= 0; j-- {
+			if memoryKey(middleA[i].Source) == memoryKey(middleB[j].Source) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var matches [][2]int
+	for i := 0; i < prefix; i++ {
+		matches = append(matches, [2]int{i, i})
+	}
+	i, j := 0, 0
+	for i < len(middleA) || j < len(middleB) {
+		switch {
+		case i < len(middleA) && j < len(middleB) && memoryKey(middleA[i].Source) == memoryKey(middleB[j].Source):
+			matches = append(matches, [2]int{prefix + i, prefix + j})
+			i, j = i+1, j+1
+		case j < len(middleB) && (i == len(middleA) || lengths[i][j+1] >= lengths[i+1][j]):
+			matches = append(matches, [2]int{-1, prefix + j})
+			j++
+		default:
+			matches = append(matches, [2]int{prefix + i, -1})
+			i++
+		}
+	}
+	for k := suffix; k > 0; k-- {
+		matches = append(matches, [2]int{len(a) - k, len(b) - k})
+	}
+	return matches
+}
+
+// analysisLanguages returns the translation languages of analysis, in
+// order, or a single empty one when it doesn't name its language.
+func analysisLanguages(analysis *Analysis) []string {
+	switch {
+	case analysis == nil:
+		return nil
+	case len(analysis.TranslationLanguages) > 0:
+		return analysis.TranslationLanguages
+	}
+	return []string{analysis.TranslationLanguage}
+}
+
+// sharedLanguages returns the languages of a that b has too, in order,
+// followed by the ones only a and only b have. An unnamed language is the one
+// of the other analysis when it has a single one.
+func sharedLanguages(a, b []string) (shared, onlyA, onlyB []string) {
+	if len(a) == 1 && len(b) == 1 && (a[0] == "" || b[0] == "") {
+		return []string{a[0] + b[0]}, nil, nil
+	}
+	contains := func(languages []string, language string) bool {
+		for _, known := range languages {
+			if language == known {
+				return true
+			}
+		}
+		return false
+	}
+	for _, language := range a {
+		if contains(b, language) {
+			shared = append(shared, language)
+		} else {
+			onlyA = append(onlyA, language)
+		}
+	}
+	for _, language := range b {
+		if !contains(a, language) {
+			onlyB = append(onlyB, language)
+		}
+	}
+	return shared, onlyA, onlyB
+}
+
+// itemTranslation returns the translation of item of analysis into language:
+// the one of its Translations, when it was translated into several
+// languages, or else its Translation when language is the translation
+// language of analysis, or the analysis doesn't name it.
+func itemTranslation(analysis *Analysis, item ResultItem, language string) string {
+	if item.Translations != nil {
+		return item.Translations[language]
+	}
+	if analysis.TranslationLanguage == "" || language == analysis.TranslationLanguage {
+		return item.Translation
+	}
+	return ""
+}
+
+// writeResultsDiff writes the sections of diff with the changes of their
+// translations marked word by word, followed by the counts of sections.
+func writeResultsDiff(w io.Writer, diff resultsDiff, marks diffMarks) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", diff.A, diff.B)
+	file := ""
+	for _, section := range diff.Sections {
+		if section.File != file {
+			file = section.File
+			fmt.Fprintf(&b, "\n@@ %s\n", file)
+		}
+		b.WriteString("\n")
+		language := ""
+		if section.Language != "" {
+			language = " (" + section.Language + ")"
+		}
+		switch section.Status {
+		case "removed":
+			fmt.Fprintf(&b, "- %s%s\n  %s\n", singleLine(section.Source), language, marks.removedStart+singleLine(section.TranslationA)+marks.removedEnd)
+		case "added":
+			fmt.Fprintf(&b, "+ %s%s\n  %s\n", singleLine(section.Source), language, marks.addedStart+singleLine(section.TranslationB)+marks.addedEnd)
+		default:
+			fmt.Fprintf(&b, "~ %s%s\n  %s\n", singleLine(section.Source), language, markWordDiff(singleLine(section.TranslationA), singleLine(section.TranslationB), marks))
+		}
+	}
+	for _, mismatch := range diff.Mismatched {
+		fmt.Fprintf(&b, "\n! ")
+		if mismatch.File != "" {
+			fmt.Fprintf(&b, "%s: ", mismatch.File)
+		}
+		var parts []string
+		if len(mismatch.OnlyA) > 0 {
+			parts = append(parts, fmt.Sprintf("%s only in %s", strings.Join(mismatch.OnlyA, ", "), diff.A))
+		}
+		if len(mismatch.OnlyB) > 0 {
+			parts = append(parts, fmt.Sprintf("%s only in %s", strings.Join(mismatch.OnlyB, ", "), diff.B))
+		}
+		fmt.Fprintf(&b, "translated into %s, not compared\n", strings.Join(parts, " and "))
+	}
+	fmt.Fprintf(&b, "\n%d changed, %d removed, %d added, %d unchanged\n", diff.Changed, diff.Removed, diff.Added, diff.Unchanged)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func init() {
+	diffCmd.Flags().String("format", "text", "The output format: text, with the changes marked in the translations, json or yaml")
+	diffCmd.Flags().String("color", "auto", "When to color the text format: auto, when printing to a terminal, always or never")
+
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// sectionItems returns result items of the sources, translated as t(source).
+func sectionItems(sources ...string) []ResultItem {
+	var items []ResultItem
+	for _, source := range sources {
+		items = append(items, ResultItem{Source: source, Translation: "t(" + source + ")"})
+	}
+	return items
+}
+
+func TestMatchSections(t *testing.T) {
+	for _, test := range []struct {
+		a, b []string
+		want [][2]int
+	}{
+		{nil, nil, nil},
+		{[]string{"a", "b"}, []string{"a", "b"}, [][2]int{{0, 0}, {1, 1}}},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, [][2]int{{0, 0}, {1, -1}, {2, 1}}},
+		{[]string{"a", "c"}, []string{"a", "b", "c"}, [][2]int{{0, 0}, {-1, 1}, {1, 2}}},
+		{[]string{"a", "b c", "d"}, []string{"a", "b", "c", "d"}, [][2]int{{0, 0}, {-1, 1}, {-1, 2}, {1, -1}, {2, 3}}},
+		{[]string{"x", "a", "y"}, []string{"a"}, [][2]int{{0, -1}, {1, 0}, {2, -1}}},
+		// Sources differing only in their spacing match.
+		{[]string{"Der  Hund\n"}, []string{"Der Hund"}, [][2]int{{0, 0}}},
+	} {
+		got := matchSections(sectionItems(test.a...), sectionItems(test.b...))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("matchSections(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSharedLanguages(t *testing.T) {
+	for _, test := range []struct {
+		a, b                 []string
+		shared, onlyA, onlyB []string
+	}{
+		{[]string{"en"}, []string{"en"}, []string{"en"}, nil, nil},
+		{[]string{""}, []string{"fr"}, []string{"fr"}, nil, nil},
+		{[]string{"en"}, []string{""}, []string{"en"}, nil, nil},
+		{[]string{"en", "fr"}, []string{"fr", "de"}, []string{"fr"}, []string{"en"}, []string{"de"}},
+		{[]string{""}, []string{"en", "fr"}, nil, []string{""}, []string{"en", "fr"}},
+		{[]string{"en"}, []string{"de"}, nil, []string{"en"}, []string{"de"}},
+	} {
+		shared, onlyA, onlyB := sharedLanguages(test.a, test.b)
+		if !reflect.DeepEqual(shared, test.shared) || !reflect.DeepEqual(onlyA, test.onlyA) || !reflect.DeepEqual(onlyB, test.onlyB) {
+			t.Errorf("sharedLanguages(%q, %q) = %q, %q, %q", test.a, test.b, shared, onlyA, onlyB)
+		}
+	}
+}
+
+func TestPairAnalyses(t *testing.T) {
+	a := []Analysis{{File: "a.txt"}, {File: "b.txt"}, {}}
+	b := []Analysis{{File: "b.txt"}, {}, {File: "c.txt"}, {}}
+	var got []string
+	for _, pair := range pairAnalyses(a, b) {
+		got = append(got, fmt.Sprintf("%s=%s", pairFile(pair[0], a), pairFile(pair[1], b)))
+	}
+	// The analyses without a file pair by position.
+	want := []string{"a.txt#0=", "b.txt#1=b.txt#0", "#2=", "=#1", "=c.txt#2", "=#3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pairs = %q, want %q", got, want)
+	}
+
+	a, b = []Analysis{{}, {}}, []Analysis{{}}
+	got = nil
+	for _, pair := range pairAnalyses(a, b) {
+		got = append(got, fmt.Sprintf("%s=%s", pairFile(pair[0], a), pairFile(pair[1], b)))
+	}
+	if want := []string{"#0=#0", "#1="}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pairs = %q, want %q", got, want)
+	}
+}
+
+// pairFile describes analysis, one of analyses or nil, by its file and its
+// position.
+func pairFile(analysis *Analysis, analyses []Analysis) string {
+	for i := range analyses {
+		if analysis == &analyses[i] {
+			return fmt.Sprintf("%s#%d", analysis.File, i)
+		}
+	}
+	return ""
+}
+
+func TestDiffResults(t *testing.T) {
+	a := []Analysis{{
+		File:                "a.txt",
+		TranslationLanguage: "en",
+		Results:             sectionItems("Hund", "Katze", "Maus", "Vogel"),
+	}, {
+		File:    "gone.txt",
+		Results: sectionItems("Fisch"),
+	}}
+	b := []Analysis{{
+		File:                "a.txt",
+		TranslationLanguage: "en",
+		Results:             append(sectionItems("Hund", "Maus"), ResultItem{Source: "Vogel", Translation: "bird"}, ResultItem{Source: "Pferd", Translation: "horse"}),
+	}}
+	diff := diffResults(a, b)
+	if diff.Unchanged != 2 || diff.Changed != 1 || diff.Removed != 2 || diff.Added != 1 {
+		t.Errorf("counts = %d unchanged, %d changed, %d removed, %d added", diff.Unchanged, diff.Changed, diff.Removed, diff.Added)
+	}
+	want := []sectionDiff{
+		{File: "a.txt", Status: "removed", Source: "Katze", Language: "en", TranslationA: "t(Katze)"},
+		{File: "a.txt", Status: "changed", Source: "Vogel", Language: "en", TranslationA: "t(Vogel)", TranslationB: "bird"},
+		{File: "a.txt", Status: "added", Source: "Pferd", Language: "en", TranslationB: "horse"},
+		{File: "gone.txt", Status: "removed", Source: "Fisch", TranslationA: "t(Fisch)"},
+	}
+	if !reflect.DeepEqual(diff.Sections, want) {
+		t.Errorf("sections = %+v, want %+v", diff.Sections, want)
+	}
+	if diff.Mismatched != nil {
+		t.Errorf("mismatched = %+v", diff.Mismatched)
+	}
+}
+
+func TestDiffResultsLanguages(t *testing.T) {
+	// An analysis without a language compares with the one of the other.
+	unnamed := []Analysis{{Results: sectionItems("Hund")}}
+	named := []Analysis{{TranslationLanguage: "en", Results: []ResultItem{{Source: "Hund", Translation: "dog"}}}}
+	diff := diffResults(unnamed, named)
+	if want := []sectionDiff{{Status: "changed", Source: "Hund", Language: "en", TranslationA: "t(Hund)", TranslationB: "dog"}}; !reflect.DeepEqual(diff.Sections, want) || diff.Mismatched != nil {
+		t.Errorf("sections = %+v, mismatched = %+v", diff.Sections, diff.Mismatched)
+	}
+
+	// Only the languages both have are compared, the others reported.
+	a := []Analysis{{
+		File:                 "a.txt",
+		TranslationLanguages: []string{"en", "fr"},
+		Results:              []ResultItem{{Source: "Hund", Translations: map[string]string{"en": "dog", "fr": "chien"}}},
+	}}
+	b := []Analysis{{
+		File:                 "a.txt",
+		TranslationLanguages: []string{"fr", "de"},
+		Results:              []ResultItem{{Source: "Hund", Translations: map[string]string{"fr": "chien", "de": "Hund"}}},
+	}}
+	diff = diffResults(a, b)
+	if diff.Unchanged != 1 || len(diff.Sections) != 0 {
+		t.Errorf("%d unchanged, sections = %+v, want the shared language only", diff.Unchanged, diff.Sections)
+	}
+	if want := []languageMismatch{{File: "a.txt", OnlyA: []string{"en"}, OnlyB: []string{"de"}}}; !reflect.DeepEqual(diff.Mismatched, want) {
+		t.Errorf("mismatched = %+v, want %+v", diff.Mismatched, want)
+	}
+
+	// Without a language in common, the analyses are not compared.
+	a[0].TranslationLanguages, b[0].TranslationLanguages = []string{"en"}, []string{"de"}
+	diff = diffResults(a, b)
+	if diff.Unchanged+diff.Changed+diff.Removed+diff.Added != 0 || len(diff.Mismatched) != 1 {
+		t.Errorf("diff = %+v, want only the mismatch", diff)
+	}
+}
+
+func TestItemTranslation(t *testing.T) {
+	item := ResultItem{Translation: "dog"}
+	for _, test := range []struct {
+		analysis Analysis
+		item     ResultItem
+		language string
+		want     string
+	}{
+		{Analysis{TranslationLanguage: "en"}, item, "en", "dog"},
+		{Analysis{TranslationLanguage: "en"}, item, "fr", ""},
+		{Analysis{}, item, "fr", "dog"},
+		{Analysis{}, ResultItem{Translations: map[string]string{"fr": "chien"}}, "fr", "chien"},
+		{Analysis{}, ResultItem{Translations: map[string]string{"fr": "chien"}}, "en", ""},
+	} {
+		if got := itemTranslation(&test.analysis, test.item, test.language); got != test.want {
+			t.Errorf("itemTranslation(%+v, %+v, %q) = %q, want %q", test.analysis, test.item, test.language, got, test.want)
+		}
+	}
+}
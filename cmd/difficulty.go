@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// cefrLevels are the levels of the Common European Framework of Reference
+// for Languages, from the easiest.
+var cefrLevels = []string{"A1", "A2", "B1", "B2", "C1", "C2"}
+
+// cefrLevelPattern matches a CEFR level in a response that may contain prose
+// around it.
+var cefrLevelPattern = regexp.MustCompile(`\b[ABC][12]\b`)
+
+// parseCEFRLevel returns the position of level in cefrLevels.
+func parseCEFRLevel(level string) (int, error) {
+	for i, known := range cefrLevels {
+		if strings.EqualFold(level, known) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid CEFR level %q (expected one of %s)", level, strings.Join(cefrLevels, ", "))
+}
+
+// belowLevel reports whether level is rated and easier than the --min-level
+// of opts, so that its result is left out.
+func belowLevel(level string, opts analysisOptions) bool {
+	if level == "" {
+		return false
+	}
+	rank, err := parseCEFRLevel(level)
+	return err == nil && rank < opts.minLevel
+}
+
+// rateDifficulty asks the LLM for the CEFR level a learner needs to
+// understand text.
+func rateDifficulty(ctx context.Context, text string, opts analysisOptions) (string, error) {
+	req, err := renderPrompt(defaultDifficultyPrompt, opts.translateModel, promptData{Text: text, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return "", err
+	}
+	response, err := generate(ctx, opts, req)
+	if err != nil {
+		return "", fmt.Errorf("rating the difficulty of %q: %w", text, err)
+	}
+	level := cefrLevelPattern.FindString(strings.ToUpper(response))
+	if level == "" {
+		return "", parseError(fmt.Errorf("rating the difficulty of %q: no CEFR level in response %q", text, response))
+	}
+	return level, nil
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// docxParts matches the parts of a DOCX document with text to translate:
+	// its body, headers, footers, footnotes and endnotes.
+	docxParts = regexp.MustCompile(`^word/(document|header\d*|footer\d*|footnotes|endnotes)\.xml$`)
+	// docxProofErr matches the marks of spelling and grammar errors, which
+	// split the runs of a paragraph for nothing.
+	docxProofErr = regexp.MustCompile(`<w:proofErr\b[^>]*/>`)
+	// docxRunBoundary matches the markup between the text of two runs that
+	// has nothing but their properties.
+	docxRunBoundary = regexp.MustCompile(`(?s)^</w:t>\s*</w:r>\s*<w:r\b[^>]*>\s*(<w:rPr>.*?</w:rPr>)?\s*<w:t\b[^>]*>$`)
+	// docPlaceholderPattern matches the placeholders of text nodes.
+	docPlaceholderPattern = regexp.MustCompile(`⟦\d+⟧`)
+)
+
+// translateDOCX translates the paragraphs of the body, headers, footers and
+// notes of the DOCX document at path and writes the translated document to
+// outputPath.
+func translateDOCX(cmd *cobra.Command, path, outputPath string, force bool) error {
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(opts.translationLanguages) > 1 {
+		return errors.New("translate-doc requires a single --translation-language")
+	}
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(path, ".docx") + "." + opts.translationLanguage + ".docx"
+	}
+	if err := checkOutputPath(outputPath, force); err != nil {
+		return err
+	}
+
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("reading document: %w", err)
+	}
+	defer reader.Close()
+	parts := make(map[string][]docSegment)
+	var names, nodes []string
+	for _, file := range reader.File {
+		if !docxParts.MatchString(file.Name) {
+			continue
+		}
+		text, err := readZipFile(file)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", file.Name, err)
+		}
+		parts[file.Name] = parseDOCX(text)
+		names = append(names, file.Name)
+		for _, segment := range parts[file.Name] {
+			if segment.Translate {
+				nodes = append(nodes, htmlEntity.ReplaceAllString(docPlaceholderText(segment.Text), " "))
+			}
+		}
+	}
+	if parts["word/document.xml"] == nil {
+		return fmt.Errorf("%s: not a DOCX document: missing word/document.xml", path)
+	}
+	if len(nodes) == 0 {
+		return errors.New("the document has no text to translate")
+	}
+	// The body comes first, so that the language is detected from it.
+	sort.SliceStable(names, func(i, j int) bool { return names[i] == "word/document.xml" && names[j] != names[i] })
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	if opts.sourceLanguage == "" {
+		if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(nodes, " "), readabilitySampleWords), opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+	}
+
+	translated := make(map[string]string, len(parts))
+	for _, name := range names {
+		verbosef("Translating %s", name)
+		segments, err := translateSegments(ctx, parts[name], validDOCXTranslation, opts)
+		if err != nil {
+			return runError(ctx, opts, err)
+		}
+		var b strings.Builder
+		for _, segment := range segments {
+			b.WriteString(segment.Text)
+		}
+		translated[name] = b.String()
+	}
+
+	var b bytes.Buffer
+	if err := writeTranslatedZip(&b, reader.File, translated); err != nil {
+		return fmt.Errorf("writing document: %w", err)
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d translated paragraphs to %s\n", len(nodes), outputPath)
+	return nil
+}
+
+// parseDOCX splits a part of a DOCX document into the text of its
+// paragraphs, which includes the text of the cells of its tables, and the
+// markup around them. The markup between the runs of a paragraph is kept as
+// placeholders, so that their styles stay on the text they are on; runs with
+// the same properties are joined first.
+func parseDOCX(text string) []docSegment {
+	text = docxProofErr.ReplaceAllString(text, "")
+	// Translations may start or end with spaces, which Word drops unless
+	// they are preserved.
+	text = strings.ReplaceAll(text, "<w:t>", `<w:t xml:space="preserve">`)
+	tokens := tokenizeHTML(text)
+
+	// A unit is the text tokens of a paragraph, along with the properties of
+	// their run. Paragraphs within paragraphs, such as the ones of text
+	// boxes, are units of their own, splitting the one around them.
+	var units [][]int
+	var open [][]int
+	properties := make(map[int]string)
+	runProperties, propertiesStart := "", -1
+	for i, token := range tokens {
+		switch {
+		case token.Name == "w:p" && !token.End && !token.Closed:
+			if len(open) > 0 {
+				units = append(units, open[len(open)-1])
+				open[len(open)-1] = nil
+			}
+			open = append(open, nil)
+		case token.Name == "w:p" && token.End && len(open) > 0:
+			units = append(units, open[len(open)-1])
+			open = open[:len(open)-1]
+		case token.Name == "w:r" && !token.End:
+			runProperties = ""
+		case token.Name == "w:rpr" && !token.End && !token.Closed:
+			propertiesStart = i
+		case token.Name == "w:rpr" && token.End && propertiesStart >= 0:
+			var b strings.Builder
+			for _, property := range tokens[propertiesStart : i+1] {
+				b.WriteString(property.Raw)
+			}
+			runProperties, propertiesStart = b.String(), -1
+		case token.Name == "" && !token.Comment && len(open) > 0 && i > 0 && tokens[i-1].Name == "w:t" && !tokens[i-1].End:
+			open[len(open)-1] = append(open[len(open)-1], i)
+			properties[i] = runProperties
+		}
+	}
+
+	var segments []docSegment
+	var markup strings.Builder
+	next := 0
+	for _, unit := range units {
+		if len(unit) == 0 {
+			continue
+		}
+		for ; next < unit[0]; next++ {
+			markup.WriteString(tokens[next].Raw)
+		}
+		var source, masked strings.Builder
+		var kept []string
+		letters := false
+		addText := func(text string) {
+			letters = letters || strings.IndexFunc(htmlEntity.ReplaceAllString(text, ""), unicode.IsLetter) >= 0
+			masked.WriteString(htmlEntity.ReplaceAllStringFunc(text, func(entity string) string {
+				kept = append(kept, entity)
+				return docPlaceholder(len(kept) - 1)
+			}))
+		}
+		addText(tokens[unit[0]].Raw)
+		for k := 1; k < len(unit); k++ {
+			var piece strings.Builder
+			for _, token := range tokens[unit[k-1]+1 : unit[k]] {
+				piece.WriteString(token.Raw)
+			}
+			if !docxRunBoundary.MatchString(piece.String()) || properties[unit[k]] != properties[unit[k-1]] {
+				kept = append(kept, piece.String())
+				masked.WriteString(docPlaceholder(len(kept) - 1))
+			}
+			addText(tokens[unit[k]].Raw)
+		}
+		for ; next <= unit[len(unit)-1]; next++ {
+			source.WriteString(tokens[next].Raw)
+		}
+		if !letters {
+			markup.WriteString(source.String())
+			continue
+		}
+
+		// The whitespace around the text is kept apart, as translations
+		// lose it.
+		text := masked.String()
+		trimmed := strings.TrimSpace(text)
+		start := strings.Index(text, trimmed)
+		markup.WriteString(text[:start])
+		segments = append(segments, docSegment{Text: markup.String()}, docSegment{Text: trimmed, Translate: true, Kept: kept})
+		markup.Reset()
+		markup.WriteString(text[start+len(trimmed):])
+	}
+	for ; next < len(tokens); next++ {
+		markup.WriteString(tokens[next].Raw)
+	}
+	return append(segments, docSegment{Text: markup.String()})
+}
+
+// docPlaceholderText returns text without its placeholders, for detecting
+// its language.
+func docPlaceholderText(text string) string {
+	return docPlaceholderPattern.ReplaceAllString(text, " ")
+}
+
+// validDOCXTranslation reports whether the translation of a paragraph keeps
+// its runs nested the way they were, and has no markup or markup characters
+// of its own.
+func validDOCXTranslation(source, translation string) bool {
+	for _, token := range tokenizeHTML(translation) {
+		if token.Name == "" && !token.Comment && (strings.Contains(token.Raw, "<") || strings.Contains(htmlEntity.ReplaceAllString(token.Raw, ""), "&")) {
+			return false
+		}
+	}
+	// The tags of a translation are the ones of its kept pieces, in any
+	// order; Word refuses the others, such as the <b> of HTML.
+	if docxTags(source) != docxTags(translation) {
+		return false
+	}
+	const start, end = "<w:r><w:t>", "</w:t></w:r>"
+	if htmlBalanced(start + translation + end) {
+		return true
+	}
+	// Paragraphs whose runs are within elements started before them, such
+	// as hyperlinks, must keep their markup in the same order.
+	return !htmlBalanced(start+source+end) && docxMarkup(source) == docxMarkup(translation)
+}
+
+// docxTags returns the tags of text, sorted.
+func docxTags(text string) string {
+	var tags []string
+	for _, token := range tokenizeHTML(text) {
+		if token.Name != "" || token.Comment {
+			tags = append(tags, token.Raw)
+		}
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, "")
+}
+
+// docxMarkup returns the tags of text, in order.
+func docxMarkup(text string) string {
+	var b strings.Builder
+	for _, token := range tokenizeHTML(text) {
+		if token.Name != "" || token.Comment {
+			b.WriteString(token.Raw)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const docxBodyFixture = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+	`<w:p><w:r><w:t>Als ich sechs </w:t></w:r><w:proofErr w:type="spellStart"/><w:r><w:t>Jahre</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve"> alt war</w:t></w:r><w:r><w:t>, sah ich Tom &amp; Jerry.</w:t></w:r></w:p>` +
+	`<w:p><w:r><w:t>  </w:t></w:r></w:p>` +
+	`<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Zelle</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+	`<w:p><w:r><w:t>Vor dem Textfeld</w:t></w:r><w:r><w:txbxContent><w:p><w:r><w:t>Im Textfeld</w:t></w:r></w:p></w:txbxContent></w:r></w:p>` +
+	`</w:body></w:document>`
+
+// docxSources returns the text of the translated segments.
+func docxSources(segments []docSegment) []string {
+	var sources []string
+	for _, segment := range segments {
+		if segment.Translate {
+			sources = append(sources, segment.Text)
+		}
+	}
+	return sources
+}
+
+func TestParseDOCX(t *testing.T) {
+	segments := parseDOCX(docxBodyFixture)
+	want := []string{"Als ich sechs Jahre⟦1⟧ alt war⟦2⟧, sah ich Tom ⟦3⟧ Jerry.", "Zelle", "Vor dem Textfeld", "Im Textfeld"}
+	if got := docxSources(segments); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("paragraphs = %q, want %q", got, want)
+	}
+	if kept := segments[1].Kept; len(kept) != 3 || !strings.Contains(kept[0], "<w:b/>") || kept[2] != "&amp;" {
+		t.Errorf("kept = %q", kept)
+	}
+
+	// Left untranslated, the document only loses its proofing marks, joins
+	// the runs with the same properties and preserves the spaces of its
+	// text.
+	untranslated := strings.Replace(docxBodyFixture, `sechs </w:t></w:r><w:proofErr w:type="spellStart"/><w:r><w:t>Jahre`, "sechs Jahre", 1)
+	untranslated = strings.ReplaceAll(untranslated, "<w:t>", `<w:t xml:space="preserve">`)
+	if got := translateSegmentsWith(segments, func(text string) string { return text }); got != untranslated {
+		t.Errorf("untranslated document = %s, want %s", got, untranslated)
+	}
+}
+
+func TestTranslateDOCXParagraphs(t *testing.T) {
+	translations := map[string]string{
+		"Als ich sechs Jahre⟦1⟧ alt war⟦2⟧, sah ich Tom ⟦3⟧ Jerry.": "When I was⟦1⟧ six years old⟦2⟧, I saw Tom ⟦3⟧ Jerry.",
+		"Zelle":            "Cell",
+		"Vor dem Textfeld": "Before the text box",
+		"Im Textfeld":      "In the text box",
+	}
+	translated := translateSegmentsWith(parseDOCX(docxBodyFixture), func(text string) string { return translations[text] })
+	for _, want := range []string{
+		`<w:p><w:r><w:t xml:space="preserve">When I was</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve"> six years old</w:t></w:r><w:r><w:t xml:space="preserve">, I saw Tom &amp; Jerry.</w:t></w:r></w:p>`,
+		`<w:tc><w:p><w:r><w:t xml:space="preserve">Cell</w:t></w:r></w:p></w:tc>`,
+		`<w:t xml:space="preserve">Before the text box</w:t>`,
+		`<w:txbxContent><w:p><w:r><w:t xml:space="preserve">In the text box</w:t></w:r></w:p></w:txbxContent>`,
+	} {
+		if !strings.Contains(translated, want) {
+			t.Errorf("translated document lacks %s:\n%s", want, translated)
+		}
+	}
+}
+
+func TestValidDOCXTranslation(t *testing.T) {
+	for _, test := range []struct {
+		source, translation string
+		want                bool
+	}{
+		{"Jahre", "years", true},
+		{"Tom &amp; Jerry", "Tom &amp; Jerry", true},
+		{"Tom &amp; Jerry", "Tom & Jerry", false},
+		{"sechs", "six <b>years</b>", false},
+		{"sechs", "six <w:br/>years", false},
+		{"sechs</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>Jahre", "six</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>years", true},
+		{"sechs</w:t></w:r><w:r><w:t>Jahre", "six</w:t></w:r>years", false},
+	} {
+		if got := validDOCXTranslation(test.source, test.translation); got != test.want {
+			t.Errorf("validDOCXTranslation(%q, %q) = %v, want %v", test.source, test.translation, got, test.want)
+		}
+	}
+}
+
+func TestWriteTranslatedDOCX(t *testing.T) {
+	source := zipFixture(t,
+		"[Content_Types].xml", `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		"word/document.xml", docxBodyFixture,
+		"word/header1.xml", `<w:hdr><w:p><w:r><w:t>Kopfzeile</w:t></w:r></w:p></w:hdr>`,
+		"word/styles.xml", `<w:styles><w:style><w:name w:val="Titel"/></w:style></w:styles>`,
+	)
+	translated := make(map[string]string)
+	for _, file := range source.File {
+		if !docxParts.MatchString(file.Name) {
+			continue
+		}
+		text, err := readZipFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		translated[file.Name] = translateSegmentsWith(parseDOCX(text), strings.ToUpper)
+	}
+	if len(translated) != 2 {
+		t.Fatalf("translated parts = %d, want the body and the header", len(translated))
+	}
+
+	var b bytes.Buffer
+	if err := writeTranslatedZip(&b, source.File, translated); err != nil {
+		t.Fatal(err)
+	}
+	written, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := zipFiles(source)
+	for _, file := range written.File {
+		got, err := readZipFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, ok := translated[file.Name]
+		if !ok {
+			if want, err = readZipFile(files[file.Name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", file.Name, got, want)
+		}
+	}
+	if header, _ := readZipFile(zipFiles(written)["word/header1.xml"]); !strings.Contains(header, ">KOPFZEILE<") {
+		t.Errorf("header = %s", header)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// dryRunSection stands for the sections in translation prompts, since they
+// are only known once the text has actually been segmented.
+const dryRunSection = "<SECTION>"
+
+// printDryRun writes the prompts and request bodies analyzing text would send,
+// without sending any of them.
+func printDryRun(w io.Writer, name, text string, opts analysisOptions) error {
+	if name != "" {
+		fmt.Fprintf(w, "=== %s ===\n\n", name)
+	}
+
+	if opts.sourceLanguage == "" {
+		if err := printDryRunRequest(w, "Language detection", opts, analyze.DetectionRequest(opts.segmentModel, text)); err != nil {
+			return err
+		}
+		opts.sourceLanguage = "<DETECTED LANGUAGE>"
+	}
+
+	data := segmentPromptData(text, opts)
+	if opts.combined {
+		data.Glossary = analyze.GlossaryTermsIn(opts.glossary, text)
+		req, err := renderPrompt(opts.combinedPrompt, opts.translateModel, data)
+		if err != nil {
+			return err
+		}
+		return printDryRunRequest(w, "Combined segmentation and translation", opts, req)
+	}
+
+	req, err := renderPrompt(opts.segmentPrompt, opts.segmentModel, data)
+	if err != nil {
+		return err
+	}
+	if err := printDryRunRequest(w, "Segmentation", opts, req); err != nil {
+		return err
+	}
+
+	for _, language := range opts.translationLanguages {
+		title := fmt.Sprintf("Translation into %s, sent once per section", language)
+		if previewer, ok := opts.translator.(llm.TranslatePreviewer); ok && opts.translatorKey != "" {
+			endpoint, body, err := previewer.PreviewTranslate(llm.TranslateRequest{Text: dryRunSection, From: opts.sourceLanguage, To: language})
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "--- %s (POST %s) ---\n\nRequest body:\n\n%s\n\n", title, endpoint, body); err != nil {
+				return err
+			}
+			continue
+		}
+
+		req, err := renderPrompt(opts.translatePrompt, opts.translateModel, promptData{Text: dryRunSection, Language: language, SourceLanguage: opts.sourceLanguage})
+		if err != nil {
+			return err
+		}
+		if err := printDryRunRequest(w, title, opts, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printDryRunRequest(w io.Writer, title string, opts analysisOptions, req llm.Request) error {
+	req.Options = opts.generationOptions
+	req.Stream = streamWriter(opts)
+	prompt := req.Flatten()
+
+	previewer, ok := opts.provider.(llm.Previewer)
+	if !ok {
+		_, err := fmt.Fprintf(w, "--- %s (%s) ---\n\nPrompt:\n\n%s\n\n", title, req.Model, prompt)
+		return err
+	}
+
+	endpoint, body, err := previewer.Preview(req)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "--- %s (POST %s) ---\n\nPrompt:\n\n%s\n\nRequest body:\n\n%s\n\n", title, endpoint, prompt, body)
+	return err
+}
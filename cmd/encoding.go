@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// decodeText converts raw file contents into a UTF-8 string. Byte order marks
+// for UTF-8 and UTF-16 are honoured; without a BOM, UTF-16 is detected by
+// the NUL bytes ASCII characters leave in every other position.
+func decodeText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		data = data[len(bomUTF8):]
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeUTF16(data[len(bomUTF16LE):], binary.LittleEndian)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeUTF16(data[len(bomUTF16BE):], binary.BigEndian)
+	default:
+		if order, ok := guessUTF16(data); ok {
+			return decodeUTF16(data, order)
+		}
+	}
+
+	if !utf8.Valid(data) {
+		return "", errors.New("unsupported text encoding: expected UTF-8 or UTF-16")
+	}
+	return string(data), nil
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) (string, error) {
+	if len(data)%2 != 0 {
+		return "", errors.New("invalid UTF-16 text: odd number of bytes")
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// guessUTF16 reports whether BOM-less data looks like UTF-16 and in which
+// byte order, based on where NUL bytes appear.
+func guessUTF16(data []byte) (binary.ByteOrder, bool) {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return nil, false
+	}
+	var evenZeros, oddZeros int
+	for i, b := range data {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	half := len(data) / 2
+	switch {
+	case oddZeros > half/2 && evenZeros == 0:
+		return binary.LittleEndian, true
+	case evenZeros > half/2 && oddZeros == 0:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// epubMetadata matches the elements of the metadata of an EPUB package that
+// are translated.
+var epubMetadata = regexp.MustCompile(`(?s)(<dc:(?:title|description|subject)\b[^>]*>)(.*?)(</dc:(?:title|description|subject)>)`)
+
+// epubLanguage matches the language of an EPUB package.
+var epubLanguage = regexp.MustCompile(`(?s)(<dc:language\b[^>]*>).*?(</dc:language>)`)
+
+// epubContainer is the META-INF/container.xml file of an EPUB, which tells
+// where its package is.
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the part of the package of an EPUB listing its files.
+type epubPackage struct {
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubCheckpoint holds the files of an EPUB already translated, so that an
+// interrupted translation resumes after the last of them.
+type epubCheckpoint struct {
+	Source   string            `json:"source"`
+	Language string            `json:"language"`
+	Files    map[string]string `json:"files"`
+}
+
+// translateEPUB translates the chapters, the table of contents and the
+// metadata of the EPUB at path and writes the translated book to
+// outputPath, checkpointing every translated file next to it.
+func translateEPUB(cmd *cobra.Command, path, outputPath string, force bool) error {
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(opts.translationLanguages) > 1 {
+		return errors.New("translate-doc requires a single --translation-language")
+	}
+	if outputPath == "" {
+		outputPath = strings.TrimSuffix(path, ".epub") + "." + opts.translationLanguage + ".epub"
+	}
+	if err := checkOutputPath(outputPath, force); err != nil {
+		return err
+	}
+
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("reading document: %w", err)
+	}
+	defer reader.Close()
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	packagePath, documents, err := epubDocuments(files)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	checkpointPath := outputPath + ".checkpoint.json"
+	checkpoint := epubCheckpoint{Source: path, Language: opts.translationLanguage, Files: map[string]string{}}
+	if data, err := os.ReadFile(checkpointPath); err == nil {
+		var saved epubCheckpoint
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("parsing %s: %w", checkpointPath, err)
+		}
+		if saved.Source == checkpoint.Source && saved.Language == checkpoint.Language {
+			checkpoint = saved
+			fmt.Fprintf(os.Stderr, "Resuming from %s: %d of %d files already translated\n", checkpointPath, len(saved.Files), len(documents)+1)
+		}
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	if opts.sourceLanguage == "" {
+		var sample []string
+		for _, name := range documents {
+			text, err := readZipFile(files[name])
+			if err != nil {
+				return fmt.Errorf("reading %s: %w", name, err)
+			}
+			for _, segment := range parseHTML(text) {
+				if segment.Translate {
+					sample = append(sample, segment.Text)
+				}
+			}
+			if len(strings.Fields(strings.Join(sample, " "))) >= readabilitySampleWords {
+				break
+			}
+		}
+		if len(sample) == 0 {
+			return errors.New("the document has no text to translate")
+		}
+		if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(sample, " "), readabilitySampleWords), opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+	}
+
+	for i, name := range append(documents, packagePath) {
+		if _, ok := checkpoint.Files[name]; ok {
+			continue
+		}
+		verbosef("Translating %s (%d of %d)", name, i+1, len(documents)+1)
+		text, err := readZipFile(files[name])
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", name, err)
+		}
+		segments := parseHTML(text)
+		if name == packagePath {
+			segments = epubMetadataSegments(text, opts.translationLanguage)
+		}
+		if segments, err = translateSegments(ctx, segments, validHTMLTranslation, opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+		var b strings.Builder
+		for _, segment := range segments {
+			b.WriteString(segment.Text)
+		}
+		checkpoint.Files[name] = b.String()
+		data, err := json.Marshal(checkpoint)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(checkpointPath, data, true); err != nil {
+			return fmt.Errorf("writing checkpoint: %w", err)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := writeTranslatedZip(&b, reader.File, checkpoint.Files); err != nil {
+		return fmt.Errorf("writing document: %w", err)
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	os.Remove(checkpointPath)
+	fmt.Fprintf(os.Stderr, "Wrote %d translated files to %s\n", len(checkpoint.Files), outputPath)
+	return nil
+}
+
+// epubDocuments returns the path of the package of an EPUB and the paths of
+// its XHTML documents and NCX table of contents, the chapters of its spine
+// first, in reading order.
+func epubDocuments(files map[string]*zip.File) (string, []string, error) {
+	file, ok := files["META-INF/container.xml"]
+	if !ok {
+		return "", nil, errors.New("not an EPUB: missing META-INF/container.xml")
+	}
+	text, err := readZipFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	var container epubContainer
+	if err := xml.Unmarshal([]byte(text), &container); err != nil {
+		return "", nil, fmt.Errorf("parsing META-INF/container.xml: %w", err)
+	}
+	if len(container.Rootfiles) == 0 || files[container.Rootfiles[0].FullPath] == nil {
+		return "", nil, errors.New("META-INF/container.xml names no package")
+	}
+	packagePath := container.Rootfiles[0].FullPath
+	if text, err = readZipFile(files[packagePath]); err != nil {
+		return "", nil, err
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal([]byte(text), &pkg); err != nil {
+		return "", nil, fmt.Errorf("parsing %s: %w", packagePath, err)
+	}
+
+	hrefs := make(map[string]string, len(pkg.Manifest))
+	var documents []string
+	seen := make(map[string]bool)
+	add := func(href string) {
+		name, err := url.PathUnescape(href)
+		if err != nil {
+			name = href
+		}
+		name = path.Join(path.Dir(packagePath), name)
+		if files[name] != nil && !seen[name] {
+			seen[name] = true
+			documents = append(documents, name)
+		}
+	}
+	for _, item := range pkg.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+	for _, item := range pkg.Spine {
+		add(hrefs[item.IDRef])
+	}
+	for _, item := range pkg.Manifest {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "application/x-dtbncx+xml" {
+			add(item.Href)
+		}
+	}
+	return packagePath, documents, nil
+}
+
+// epubMetadataSegments splits the package of an EPUB so that only the text
+// of its title, description and subjects is translated, and sets its
+// language to language.
+func epubMetadataSegments(text, language string) []docSegment {
+	text = epubLanguage.ReplaceAllString(text, "${1}"+language+"${2}")
+	var segments []docSegment
+	last := 0
+	for _, match := range epubMetadata.FindAllStringSubmatchIndex(text, -1) {
+		segments = append(segments, docSegment{Text: text[last:match[3]]})
+		segments = append(segments, parseHTML(text[match[4]:match[5]])...)
+		last = match[6]
+	}
+	return append(segments, docSegment{Text: text[last:]})
+}
+
+// readZipFile reads a file of a zip archive as text.
+func readZipFile(file *zip.File) (string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// writeTranslatedZip writes the files of a zip archive, such as an EPUB or a
+// DOCX document, with the translated ones in place of their source. The
+// mimetype file of EPUBs is written first, uncompressed and without any extra
+// field, as the format requires.
+func writeTranslatedZip(w io.Writer, files []*zip.File, translated map[string]string) error {
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		if file.Name != "mimetype" {
+			continue
+		}
+		text, err := readZipFile(file)
+		if err != nil {
+			return err
+		}
+		entry, err := archive.CreateRaw(&zip.FileHeader{
+			Name:               file.Name,
+			Method:             zip.Store,
+			ModifiedTime:       file.ModifiedTime,
+			ModifiedDate:       file.ModifiedDate,
+			CRC32:              crc32.ChecksumIEEE([]byte(text)),
+			CompressedSize64:   uint64(len(text)),
+			UncompressedSize64: uint64(len(text)),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, text); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		text, ok := translated[file.Name]
+		switch {
+		case file.Name == "mimetype":
+		case !ok:
+			if err := archive.Copy(file); err != nil {
+				return err
+			}
+		default:
+			entry, err := archive.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, ModifiedTime: file.ModifiedTime, ModifiedDate: file.ModifiedDate})
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(entry, text); err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
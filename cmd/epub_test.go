@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// zipFixture returns the zip archive of files, given as name and contents
+// pairs and written in that order.
+func zipFixture(t *testing.T, files ...string) *zip.Reader {
+	t.Helper()
+	var b bytes.Buffer
+	archive := zip.NewWriter(&b)
+	for i := 0; i < len(files); i += 2 {
+		entry, err := archive.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(entry, files[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+// zipFiles indexes the files of archive by name.
+func zipFiles(archive *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	return files
+}
+
+// translateSegmentsWith replaces the text of the translated segments with
+// translate of it, joining the document back.
+func translateSegmentsWith(segments []docSegment, translate func(string) string) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		text := segment.Text
+		if segment.Translate {
+			text, _ = segment.restore(translate(text))
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+const epubPackageFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Der kleine Prinz</dc:title>
+    <dc:creator>Antoine de Saint-Exupéry</dc:creator>
+    <dc:language>de</dc:language>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml"/>
+    <item id="two" href="text/chapter%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="one" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="cover" href="cover.jpg" media-type="image/jpeg"/>
+  </manifest>
+  <spine>
+    <itemref idref="one"/>
+    <itemref idref="two"/>
+  </spine>
+</package>`
+
+func epubFixture(t *testing.T) *zip.Reader {
+	return zipFixture(t,
+		"mimetype", "application/epub+zip",
+		"META-INF/container.xml", `<?xml version="1.0"?><container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`,
+		"OEBPS/content.opf", epubPackageFixture,
+		"OEBPS/nav.xhtml", `<html><body><nav><ol><li><a href="text/chapter1.xhtml">Erstes Kapitel</a></li></ol></nav></body></html>`,
+		"OEBPS/text/chapter1.xhtml", `<html><body><h1>Erstes Kapitel</h1><p>Als ich <em>sechs</em> Jahre alt war.</p></body></html>`,
+		"OEBPS/text/chapter 2.xhtml", `<html><body><p>Zweites Kapitel.</p></body></html>`,
+		"OEBPS/toc.ncx", `<ncx><navMap><navPoint><navLabel><text>Erstes Kapitel</text></navLabel></navPoint></navMap></ncx>`,
+		"OEBPS/cover.jpg", "\xff\xd8\xff\xe0 not really a picture",
+	)
+}
+
+func TestEPUBDocuments(t *testing.T) {
+	packagePath, documents, err := epubDocuments(zipFiles(epubFixture(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packagePath != "OEBPS/content.opf" {
+		t.Errorf("package = %q", packagePath)
+	}
+	want := []string{"OEBPS/text/chapter1.xhtml", "OEBPS/text/chapter 2.xhtml", "OEBPS/nav.xhtml", "OEBPS/toc.ncx"}
+	if strings.Join(documents, "|") != strings.Join(want, "|") {
+		t.Errorf("documents = %q, want %q", documents, want)
+	}
+
+	if _, _, err := epubDocuments(zipFiles(zipFixture(t, "mimetype", "application/epub+zip"))); err == nil {
+		t.Error("an archive without META-INF/container.xml was read as an EPUB")
+	}
+}
+
+func TestEPUBChapterText(t *testing.T) {
+	text, err := readZipFile(zipFiles(epubFixture(t))["OEBPS/text/chapter1.xhtml"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	for _, segment := range parseHTML(text) {
+		if segment.Translate {
+			sources = append(sources, segment.Text)
+		}
+	}
+	if got, want := strings.Join(sources, "|"), "Erstes Kapitel|Als ich ⟦1⟧sechs⟦2⟧ Jahre alt war."; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
+
+func TestEPUBMetadataSegments(t *testing.T) {
+	segments := epubMetadataSegments(epubPackageFixture, "en-US")
+	var sources []string
+	for _, segment := range segments {
+		if segment.Translate {
+			sources = append(sources, segment.Text)
+		}
+	}
+	if got := strings.Join(sources, "|"); got != "Der kleine Prinz" {
+		t.Errorf("translated metadata = %q, want only the title", got)
+	}
+
+	translated := translateSegmentsWith(segments, func(string) string { return "The Little Prince" })
+	for _, want := range []string{"<dc:title>The Little Prince</dc:title>", "<dc:language>en-US</dc:language>", "<dc:creator>Antoine de Saint-Exupéry</dc:creator>", `<itemref idref="one"/>`} {
+		if !strings.Contains(translated, want) {
+			t.Errorf("translated package lacks %s:\n%s", want, translated)
+		}
+	}
+}
+
+func TestWriteTranslatedEPUB(t *testing.T) {
+	source := epubFixture(t)
+	files := zipFiles(source)
+	text, err := readZipFile(files["OEBPS/text/chapter1.xhtml"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapter := translateSegmentsWith(parseHTML(text), strings.ToUpper)
+
+	var b bytes.Buffer
+	if err := writeTranslatedZip(&b, source.File, map[string]string{"OEBPS/text/chapter1.xhtml": chapter}); err != nil {
+		t.Fatal(err)
+	}
+	written, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mimetype comes first, stored, as readers check it at a fixed
+	// offset.
+	if len(written.File) != len(source.File) {
+		t.Fatalf("wrote %d files, want %d", len(written.File), len(source.File))
+	}
+	if first := written.File[0]; first.Name != "mimetype" || first.Method != zip.Store || len(first.Extra) != 0 {
+		t.Errorf("first file is %s with method %d", first.Name, first.Method)
+	}
+	if !bytes.HasPrefix(b.Bytes()[30:], []byte("mimetypeapplication/epub+zip")) {
+		t.Error("the mimetype isn't at the start of the archive")
+	}
+
+	for _, file := range written.File {
+		got, err := readZipFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := readZipFile(files[file.Name])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Name == "OEBPS/text/chapter1.xhtml" {
+			want = "<html><body><h1>ERSTES KAPITEL</h1><p>ALS ICH <em>SECHS</em> JAHRE ALT WAR.</p></body></html>"
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", file.Name, got, want)
+		}
+	}
+}
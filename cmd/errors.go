@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Exit codes of the CLI, also listed in the README.
+const (
+	// exitFailure is used for invalid usage and any error not covered below.
+	exitFailure = 1
+	// exitConnection means the LLM host could not be reached or kept
+	// answering with errors.
+	exitConnection = 2
+	// exitParse means the LLM answered with something that could not be
+	// parsed, even after asking it to correct itself.
+	exitParse = 3
+	// exitPartial means some of the inputs of a batch failed while others
+	// succeeded.
+	exitPartial = 4
+	// exitTimeout means --total-timeout was exceeded.
+	exitTimeout = 5
+	// exitInterrupted means the run was cancelled with Ctrl+C.
+	exitInterrupted = 130
+)
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExitCode wraps err so that the process exits with code when it reaches
+// Execute. A nil err stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// connectionError marks failures to talk to the LLM host.
+func connectionError(err error) error {
+	return withExitCode(exitConnection, err)
+}
+
+// parseError marks LLM responses that could not be parsed.
+func parseError(err error) error {
+	return withExitCode(exitParse, err)
+}
+
+// exitCode returns the exit code for err: the innermost one attached with
+// withExitCode, or exitFailure.
+func exitCode(err error) int {
+	code := exitFailure
+	for err != nil {
+		var exitErr *exitError
+		if !errors.As(err, &exitErr) {
+			break
+		}
+		code = exitErr.code
+		err = exitErr.err
+	}
+	return code
+}
+
+// runError explains why a run failed, replacing the context errors caused by
+// --total-timeout or Ctrl+C with a clear message and exit code.
+func runError(ctx context.Context, opts analysisOptions, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return withExitCode(exitTimeout, fmt.Errorf("total timeout of %s exceeded", opts.totalTimeout))
+	case errors.Is(ctx.Err(), context.Canceled):
+		return withExitCode(exitInterrupted, errors.New("interrupted"))
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+func TestExitCode(t *testing.T) {
+	failed := errors.New("reading input file: no such file")
+	for _, test := range []struct {
+		err  error
+		want int
+	}{
+		{failed, exitFailure},
+		{connectionError(failed), exitConnection},
+		{fmt.Errorf("analyzing a.txt: %w", parseError(failed)), exitParse},
+		{withExitCode(exitPartial, failed), exitPartial},
+		// The innermost code is the most precise one.
+		{withExitCode(exitPartial, fmt.Errorf("a.txt: %w", connectionError(failed))), exitConnection},
+	} {
+		if got := exitCode(test.err); got != test.want {
+			t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+	if withExitCode(exitParse, nil) != nil {
+		t.Error("withExitCode wrapped a nil error")
+	}
+	if err := connectionError(failed); !errors.Is(err, failed) || err.Error() != failed.Error() {
+		t.Errorf("connectionError(%v) = %v, want the error unchanged", failed, err)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	failed := connectionError(errors.New("making HTTP request: connection refused"))
+	opts := analysisOptions{totalTimeout: time.Minute}
+	if err := runError(context.Background(), opts, failed); err != failed {
+		t.Errorf("runError = %v, want the error of the run", err)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	err := runError(expired, opts, fmt.Errorf("translating: %w", context.DeadlineExceeded))
+	if exitCode(err) != exitTimeout || err.Error() != "total timeout of 1m0s exceeded" {
+		t.Errorf("runError after the timeout = %v with exit code %d", err, exitCode(err))
+	}
+
+	interrupted, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = runError(interrupted, opts, failed)
+	if exitCode(err) != exitInterrupted || err.Error() != "interrupted" {
+		t.Errorf("runError after an interruption = %v with exit code %d", err, exitCode(err))
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want int
+	}{
+		{&llm.ConnectionError{Err: errors.New("connection refused")}, exitConnection},
+		{fmt.Errorf("translating: %w", &llm.StatusError{Code: 503}), exitConnection},
+		{&llm.StatusError{Code: 401, Message: "invalid API key"}, exitConnection},
+		{&llm.ParseError{Err: errors.New("no valid JSON array or object found in response")}, exitParse},
+		{errors.New("rendering prompt template"), exitFailure},
+	} {
+		if got := exitCode(providerError(test.err)); got != test.want {
+			t.Errorf("exit code of %v = %d, want %d", test.err, got, test.want)
+		}
+	}
+	if providerError(nil) != nil {
+		t.Error("providerError(nil) is an error")
+	}
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// failoverAfter is the number of consecutive failed requests after which a
+// host answering with errors is abandoned for the next one. Unreachable hosts
+// are abandoned right away.
+const failoverAfter = 2
+
+// failoverTarget is an entry of the failover chain.
+type failoverTarget struct {
+	name     string
+	host     string
+	provider llm.Provider
+	// model replaces the model of the requests, unless empty.
+	model string
+}
+
+// failoverProvider sends the requests to the first target of the chain
+// until it turns out to be unreachable or keeps failing, and then to the
+// next ones, for the rest of the run.
+type failoverProvider struct {
+	mu       sync.Mutex
+	targets  []failoverTarget
+	current  int
+	failures int
+}
+
+// newFailoverProvider returns primary, followed by the targets of --failover
+// or the failover list of the config file. primary is returned as it is when
+// there are none.
+func newFailoverProvider(cmd *cobra.Command, primary llm.Provider, host string) (llm.Provider, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	primaryName, err := resolveProvider(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := cfg.Failover
+	specs, err := cmd.Flags().GetStringArray("failover")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving failover flag: %w", err)
+	}
+	if len(specs) > 0 {
+		entries = nil
+		for _, spec := range specs {
+			name, host, _ := strings.Cut(spec, "=")
+			entries = append(entries, failoverConfig{Provider: strings.TrimSpace(name), LLMHost: strings.TrimSpace(host)})
+		}
+	}
+	if len(entries) == 0 {
+		return primary, nil
+	}
+
+	targets := []failoverTarget{{name: primaryName, host: orDefaultHost(primaryName, host), provider: primary}}
+	for _, entry := range entries {
+		spec, ok := providers[entry.Provider]
+		if !ok {
+			return nil, fmt.Errorf("unsupported failover provider %q (supported: %s)", entry.Provider, strings.Join(supportedProviders(), ", "))
+		}
+		provider, err := newNamedProvider(cmd, cfg, entry.Provider, entry.LLMHost)
+		if err != nil {
+			return nil, fmt.Errorf("setting up failover to %s: %w", spec.title, err)
+		}
+		// The models of another provider are named differently.
+		model := entry.Model
+		if model == "" && entry.Provider != primaryName {
+			model = spec.model
+			if model == "" {
+				return nil, fmt.Errorf("%s has no default model: set the model of its failover entry in the config file", spec.title)
+			}
+		}
+		targets = append(targets, failoverTarget{name: entry.Provider, host: orDefaultHost(entry.Provider, entry.LLMHost), provider: provider, model: model})
+	}
+	return &failoverProvider{targets: targets}, nil
+}
+
+// orDefaultHost returns host, or the default host of the provider name when
+// it is empty.
+func orDefaultHost(name, host string) string {
+	if host == "" {
+		return providers[name].host
+	}
+	return host
+}
+
+// active returns the target currently receiving the requests.
+func (f *failoverProvider) active() failoverTarget {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.targets[f.current]
+}
+
+// do calls fn with the active target, moving on to the next target when the
+// failure of the call calls for it.
+func (f *failoverProvider) do(fn func(failoverTarget) error) error {
+	for {
+		f.mu.Lock()
+		index := f.current
+		target := f.targets[index]
+		f.mu.Unlock()
+
+		err := fn(target)
+		if !f.record(index, err) {
+			return err
+		}
+	}
+}
+
+// record counts the outcome of a call to the target at index and reports
+// whether the call should be made again with the next target.
+func (f *failoverProvider) record(index int, err error) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if index != f.current {
+		// Another request already failed over.
+		return err != nil && isRetryable(err)
+	}
+	if err == nil || !isRetryable(err) {
+		f.failures = 0
+		return false
+	}
+	f.failures++
+	var statusErr *llm.StatusError
+	unreachable := !errors.As(err, &statusErr)
+	if !unreachable && f.failures < failoverAfter {
+		return false
+	}
+	return f.advance(err)
+}
+
+// failOver moves on to the next target after err, for the circuit breaker,
+// and reports whether there was one.
+func (f *failoverProvider) failOver(err error) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.advance(err)
+}
+
+// advance moves on to the next target, with f.mu held, and reports whether
+// there was one.
+func (f *failoverProvider) advance(err error) bool {
+	if f.current == len(f.targets)-1 {
+		return false
+	}
+	f.current++
+	f.failures = 0
+	fmt.Fprintf(os.Stderr, "%s failed (%v), failing over to %s\n", providers[f.targets[f.current-1].name].title, err, describeTarget(f.targets[f.current]))
+	return true
+}
+
+// describeTarget names target in messages.
+func describeTarget(target failoverTarget) string {
+	title := providers[target.name].title
+	if target.host == "" {
+		return title
+	}
+	return fmt.Sprintf("%s at %s", title, llm.RedactURL(target.host))
+}
+
+func (t failoverTarget) modelFor(model string) string {
+	if t.model != "" {
+		return t.model
+	}
+	return model
+}
+
+// Generate sends req to the active target.
+func (f *failoverProvider) Generate(ctx context.Context, req llm.Request) (string, error) {
+	var response string
+	err := f.do(func(target failoverTarget) error {
+		targetReq := req
+		targetReq.Model = target.modelFor(req.Model)
+		var err error
+		response, err = target.provider.Generate(ctx, targetReq)
+		return err
+	})
+	return response, err
+}
+
+// Translate sends req to the active target.
+func (f *failoverProvider) Translate(ctx context.Context, req llm.TranslateRequest) (string, error) {
+	var translation string
+	err := f.do(func(target failoverTarget) error {
+		targetReq := req
+		targetReq.Model = target.modelFor(req.Model)
+		var err error
+		translation, err = target.provider.Translate(ctx, targetReq)
+		return err
+	})
+	return translation, err
+}
+
+// Embed sends the request to the active target.
+func (f *failoverProvider) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	var embedding []float64
+	err := f.do(func(target failoverTarget) error {
+		var err error
+		embedding, err = target.provider.Embed(ctx, target.modelFor(model), text)
+		return err
+	})
+	return embedding, err
+}
+
+// Preview shows the request the active target would receive.
+func (f *failoverProvider) Preview(req llm.Request) (string, []byte, error) {
+	target := f.active()
+	previewer, ok := target.provider.(llm.Previewer)
+	if !ok {
+		return "", nil, fmt.Errorf("%s cannot preview its requests", providers[target.name].title)
+	}
+	req.Model = target.modelFor(req.Model)
+	return previewer.Preview(req)
+}
+
+// Warm loads model on the active target, when it is able to.
+func (f *failoverProvider) Warm(ctx context.Context, model string) error {
+	target := f.active()
+	warmer, ok := target.provider.(llm.Warmer)
+	if !ok {
+		return fmt.Errorf("%s cannot load models ahead of the requests", providers[target.name].title)
+	}
+	return warmer.Warm(ctx, target.modelFor(model))
+}
+
+// Pull downloads model on the active target, which reported it missing.
+func (f *failoverProvider) Pull(ctx context.Context, model string, progress func(llm.PullProgress)) error {
+	target := f.active()
+	puller, ok := target.provider.(llm.Puller)
+	if !ok {
+		return fmt.Errorf("%s cannot pull models", providers[target.name].title)
+	}
+	return puller.Pull(ctx, model, progress)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// fakeProvider is an llm.Provider failing with errs in turn and then
+// answering every request with its prompt, or its text, prefixed with name.
+// It records the models of the requests it got.
+type fakeProvider struct {
+	name string
+	errs []error
+
+	mu     sync.Mutex
+	models []string
+}
+
+func (p *fakeProvider) answer(model, text string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, model)
+	if len(p.models) <= len(p.errs) {
+		if err := p.errs[len(p.models)-1]; err != nil {
+			return "", err
+		}
+	}
+	return p.name + ": " + text, nil
+}
+
+func (p *fakeProvider) Generate(ctx context.Context, req llm.Request) (string, error) {
+	return p.answer(req.Model, req.Prompt)
+}
+
+func (p *fakeProvider) Translate(ctx context.Context, req llm.TranslateRequest) (string, error) {
+	return p.answer(req.Model, req.Text)
+}
+
+func (p *fakeProvider) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	if _, err := p.answer(model, text); err != nil {
+		return nil, err
+	}
+	return []float64{float64(len(text))}, nil
+}
+
+// requested returns the models of the requests p got, joined.
+func (p *fakeProvider) requested() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return strings.Join(p.models, " ")
+}
+
+func newFakeChain(providers ...*fakeProvider) *failoverProvider {
+	chain := &failoverProvider{}
+	for i, provider := range providers {
+		target := failoverTarget{name: "ollama", provider: provider}
+		if i > 0 {
+			target.name, target.model = "openai", "gpt-4o-mini"
+		}
+		chain.targets = append(chain.targets, target)
+	}
+	return chain
+}
+
+func TestFailoverRecord(t *testing.T) {
+	chain := newFakeChain(&fakeProvider{}, &fakeProvider{}, &fakeProvider{})
+
+	// Hosts answering with errors are given failoverAfter tries, and the
+	// failures count in a row only.
+	if chain.record(0, errOverloaded) || chain.record(0, nil) || chain.record(0, errOverloaded) {
+		t.Fatal("failed over before failoverAfter failures in a row")
+	}
+	if !chain.record(0, errOverloaded) || chain.current != 1 {
+		t.Fatalf("didn't fail over after %d failures, at target %d", failoverAfter, chain.current)
+	}
+	// The requests sent to the previous target before the failover are
+	// made again, unless they failed for good.
+	if !chain.record(0, errOverloaded) || chain.record(0, errBadRequest) || chain.record(0, nil) || chain.current != 1 {
+		t.Fatalf("late outcomes of the first target moved the chain to target %d", chain.current)
+	}
+
+	// Answers, even bad ones, reset the failures, and unreachable hosts
+	// are abandoned right away.
+	if chain.record(1, errOverloaded) || chain.record(1, errBadRequest) || chain.record(1, errOverloaded) {
+		t.Fatal("failed over although the host answered in between")
+	}
+	if !chain.record(1, errUnreachable) || chain.current != 2 {
+		t.Fatalf("didn't fail over from an unreachable host, at target %d", chain.current)
+	}
+
+	// The last target has none to fail over to.
+	if chain.record(2, errUnreachable) || chain.current != 2 {
+		t.Fatal("failed over past the last target")
+	}
+	if chain.failOver(errUnreachable) {
+		t.Fatal("the circuit breaker failed over past the last target")
+	}
+}
+
+func TestFailoverProvider(t *testing.T) {
+	primary := &fakeProvider{name: "primary", errs: []error{nil, errUnreachable}}
+	secondary := &fakeProvider{name: "secondary", errs: []error{errOverloaded}}
+	chain := newFakeChain(primary, secondary)
+	ctx := context.Background()
+
+	response, err := chain.Generate(ctx, llm.Request{Model: "llama3", Prompt: "Hund"})
+	if err != nil || response != "primary: Hund" {
+		t.Fatalf("Generate = %q, %v", response, err)
+	}
+	// The unreachable primary is left for the secondary, which gets its
+	// own model; its failure is the caller's to retry.
+	if _, err := chain.Translate(ctx, llm.TranslateRequest{Model: "llama3", Text: "Katze"}); err != errOverloaded {
+		t.Fatalf("Translate = %v, want the failure of the secondary", err)
+	}
+	translation, err := chain.Translate(ctx, llm.TranslateRequest{Model: "llama3", Text: "Katze"})
+	if err != nil || translation != "secondary: Katze" {
+		t.Fatalf("Translate = %q, %v", translation, err)
+	}
+	if _, err := chain.Embed(ctx, "nomic-embed-text", "Maus"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := primary.requested(); got != "llama3 llama3" {
+		t.Errorf("the primary got requests for %s", got)
+	}
+	if got := secondary.requested(); got != "gpt-4o-mini gpt-4o-mini gpt-4o-mini" {
+		t.Errorf("the secondary got requests for %s", got)
+	}
+	if target := chain.active(); target.provider != secondary || target.modelFor("llama3") != "gpt-4o-mini" {
+		t.Errorf("the active target is %+v", target)
+	}
+}
+
+func TestFailoverProviderExhausted(t *testing.T) {
+	chain := newFakeChain(&fakeProvider{errs: []error{errUnreachable}}, &fakeProvider{errs: []error{errUnreachable}})
+	_, err := chain.Generate(context.Background(), llm.Request{Model: "llama3"})
+	if !errors.Is(err, errUnreachable) {
+		t.Fatalf("Generate = %v, want the failure of the last target", err)
+	}
+}
@@ -0,0 +1,412 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// flashcard is a note of the exported deck.
+type flashcard struct {
+	Front string
+	Back  string
+	Notes string
+	Deck  string
+	// Reading is the reading of the front, such as its furigana, pinyin or
+	// transliteration, and Audio the path to a recording of it; both are
+	// only written to --apkg packages.
+	Reading string
+	Audio   string
+	// Term is what the audio of the card is named after: the source of a
+	// section or the dictionary form of a word.
+	Term string
+}
+
+// ankiSeparators are the field separators named in the #separator header of
+// Anki's text import.
+var ankiSeparators = map[string]rune{
+	"tab":       '\t',
+	"comma":     ',',
+	"semicolon": ';',
+	"pipe":      '|',
+	"colon":     ':',
+	"space":     ' ',
+}
+
+var flashcardsCmd = &cobra.Command{
+	Use:   "flashcards <results.json>...",
+	Short: "Turn the results of analise or vocab into an Anki deck",
+	Long: `The "flashcards" command reads the JSON results of "analise", "vocab" or "cloze" (from the given files, or stdin when the argument is "-") and writes them as a text file that Anki imports as a deck.
+Every section becomes a note with the source on the front and the translation on the back; every vocabulary entry has the word and its dictionary form on the front and the translation on the back; every cloze has the sentence with its word blanked out and the hint on the front and the word on the back. With --notes a third field holds the part of speech, synonyms, antonyms and example sentences of the word, or the back-translation and the synonyms of the key words of a section.
+The file starts with the headers of Anki's import, so the separator, the tags and the deck are picked up without configuring the import. Each input file is its own deck, named after the file unless --deck is set; stdin goes to Anki's Default deck.
+With --apkg the deck is written as an Anki package instead, which Anki imports with its notes, decks, note type and media. The note type is named by --note-type and has the --fields listed: the source, the translation, the reading of the source (its furigana, pinyin, transliteration or IPA) and its audio, the recording of "analise --tts" or a file in --audio-dir named after the source, or the dictionary form of a word, such as "Guten Morgen.mp3". Importing a package again updates the notes it imported before.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runFlashcards,
+}
+
+func runFlashcards(cmd *cobra.Command, args []string) error {
+	separatorName, err := cmd.Flags().GetString("separator")
+	if err != nil {
+		return fmt.Errorf("retrieving separator flag: %w", err)
+	}
+	separator, err := parseSeparator(separatorName)
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return fmt.Errorf("retrieving tag flag: %w", err)
+	}
+	for _, tag := range tags {
+		if tag == "" || strings.ContainsAny(tag, " \t") {
+			return fmt.Errorf("invalid tag %q: Anki tags cannot be empty or contain spaces", tag)
+		}
+	}
+	deck, err := cmd.Flags().GetString("deck")
+	if err != nil {
+		return fmt.Errorf("retrieving deck flag: %w", err)
+	}
+	notes, err := cmd.Flags().GetBool("notes")
+	if err != nil {
+		return fmt.Errorf("retrieving notes flag: %w", err)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+	apkgPath, err := cmd.Flags().GetString("apkg")
+	if err != nil {
+		return fmt.Errorf("retrieving apkg flag: %w", err)
+	}
+	noteTypeName, err := cmd.Flags().GetString("note-type")
+	if err != nil {
+		return fmt.Errorf("retrieving note-type flag: %w", err)
+	}
+	fieldSpecs, err := cmd.Flags().GetStringSlice("fields")
+	if err != nil {
+		return fmt.Errorf("retrieving fields flag: %w", err)
+	}
+	audioDir, err := cmd.Flags().GetString("audio-dir")
+	if err != nil {
+		return fmt.Errorf("retrieving audio-dir flag: %w", err)
+	}
+	var noteType apkgNoteType
+	if apkgPath != "" {
+		if outputPath != "" {
+			return errors.New("--apkg is the file the deck is written to and cannot be used with --output")
+		}
+		if noteTypeName == "" {
+			return errors.New("--note-type cannot be empty")
+		}
+		fields, err := parseAPKGFields(fieldSpecs, notes)
+		if err != nil {
+			return err
+		}
+		noteType = apkgNoteType{Name: noteTypeName, Fields: fields}
+		if err := checkOutputPath(apkgPath, force); err != nil {
+			return err
+		}
+	}
+
+	var cards []flashcard
+	for _, path := range args {
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		fileCards, err := parseFlashcards(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fileDeck := deck
+		switch {
+		case fileDeck != "":
+		case path == "-":
+			fileDeck = "Default"
+		default:
+			fileDeck = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+		}
+		for _, card := range fileCards {
+			card.Deck = fileDeck
+			// The --tts recordings are next to the results they are in
+			// when they are not relative to the current directory.
+			if card.Audio != "" && !filepath.IsAbs(card.Audio) && path != "-" {
+				if _, err := os.Stat(card.Audio); err != nil {
+					card.Audio = filepath.Join(filepath.Dir(name), card.Audio)
+				}
+			}
+			cards = append(cards, card)
+		}
+	}
+	if len(cards) == 0 {
+		return errors.New("no results to turn into flashcards")
+	}
+
+	if apkgPath != "" {
+		for i := range cards {
+			if cards[i].Audio == "" {
+				cards[i].Audio = findAudio(audioDir, cards[i].Term)
+			}
+		}
+		data, err := writeAPKG(cards, noteType, tags, time.Now())
+		if err != nil {
+			return fmt.Errorf("writing Anki package: %w", err)
+		}
+		if err := writeFileAtomic(apkgPath, data, force); err != nil {
+			return fmt.Errorf("writing output file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d flashcards to %s\n", len(cards), apkgPath)
+		return nil
+	}
+
+	var b bytes.Buffer
+	if err := writeAnkiDeck(&b, cards, separator, tags, notes, false); err != nil {
+		return fmt.Errorf("writing flashcards: %w", err)
+	}
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d flashcards to %s\n", len(cards), outputPath)
+	return nil
+}
+
+// parseSeparator returns the field separator named by --separator, either
+// one of ankiSeparators or a single character.
+func parseSeparator(name string) (rune, error) {
+	if r, ok := ankiSeparators[strings.ToLower(name)]; ok {
+		return r, nil
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		if r != '"' && r != '\n' && r != '\r' {
+			return r, nil
+		}
+	}
+	names := make([]string, 0, len(ankiSeparators))
+	for name := range ankiSeparators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("invalid separator %q (expected one of %s, or a single character)", name, strings.Join(names, ", "))
+}
+
+// readResults reads the results in path, or stdin when it is "-", returning
+// the name they are reported under.
+func readResults(path string) ([]byte, string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return data, "stdin", err
+	}
+	data, err := os.ReadFile(path)
+	return data, path, err
+}
+
+// parseFlashcards turns the JSON output of analise, for a single text or
+// for files, of vocab or of cloze into flashcards.
+func parseFlashcards(data []byte) ([]flashcard, error) {
+	data = bytes.TrimSpace(data)
+	var probe struct {
+		Entries *json.RawMessage `json:"entries"`
+		Clozes  *json.RawMessage `json:"clozes"`
+	}
+	if !bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return nil, fmt.Errorf("parsing results: %w", err)
+		}
+	}
+	switch {
+	case probe.Entries != nil:
+		var vocab vocabulary
+		if err := json.Unmarshal(data, &vocab); err != nil {
+			return nil, fmt.Errorf("parsing vocab results: %w", err)
+		}
+		return vocabFlashcards(vocab), nil
+	case probe.Clozes != nil:
+		var clozes clozeDeck
+		if err := json.Unmarshal(data, &clozes); err != nil {
+			return nil, fmt.Errorf("parsing cloze results: %w", err)
+		}
+		return clozeFlashcards(clozes), nil
+	}
+	analyses, err := parseAnalyses(data)
+	if err != nil {
+		return nil, err
+	}
+	return analysisFlashcards(analyses), nil
+}
+
+// parseAnalyses parses the JSON output of analise, either for a single text
+// or for files.
+func parseAnalyses(data []byte) ([]Analysis, error) {
+	data = bytes.TrimSpace(data)
+	var analyses []Analysis
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &analyses); err != nil {
+			return nil, fmt.Errorf("parsing analise results: %w", err)
+		}
+		return analyses, nil
+	}
+
+	var probe struct {
+		Results *json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("parsing results: %w", err)
+	}
+	if probe.Results == nil {
+		return nil, errors.New("not the JSON output of analise, vocab or cloze")
+	}
+	var analysis Analysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("parsing analise results: %w", err)
+	}
+	return []Analysis{analysis}, nil
+}
+
+// analysisFlashcards has a flashcard per translated section. Sections
+// translated into several languages have every translation on the back,
+// one per line.
+func analysisFlashcards(analyses []Analysis) []flashcard {
+	var cards []flashcard
+	for _, analysis := range analyses {
+		for _, item := range analysis.Results {
+			back := item.Translation
+			if len(item.Translations) > 0 {
+				var lines []string
+				for _, language := range analysis.TranslationLanguages {
+					lines = append(lines, language+": "+item.Translations[language])
+				}
+				back = strings.Join(lines, "\n")
+			}
+			if back == "" {
+				continue
+			}
+			notes := item.BackTranslation
+			if len(item.Synonyms) > 0 {
+				notes = strings.TrimSpace(notes + "\n" + formatWordRelations(item.Synonyms))
+			}
+			reading := item.Furigana
+			for _, other := range []string{item.Pinyin, item.Transliteration, item.IPA} {
+				if reading == "" {
+					reading = other
+				}
+			}
+			cards = append(cards, flashcard{Front: item.Source, Back: back, Notes: notes, Reading: reading, Audio: item.Audio, Term: item.Source})
+		}
+	}
+	return cards
+}
+
+// vocabFlashcards has a flashcard per word, with its dictionary form on the
+// front when it differs from the word.
+func vocabFlashcards(vocab vocabulary) []flashcard {
+	cards := make([]flashcard, 0, len(vocab.Entries))
+	for _, entry := range vocab.Entries {
+		front := entry.Lemma
+		if entry.Word != "" && !strings.EqualFold(entry.Word, entry.Lemma) {
+			front = entry.Lemma + " (" + entry.Word + ")"
+		}
+		notes := entry.POS
+		if entry.Sentence != "" {
+			notes += "\n" + entry.Sentence
+		}
+		if len(entry.Synonyms) > 0 {
+			notes += "\nsynonyms: " + strings.Join(entry.Synonyms, ", ")
+		}
+		if len(entry.Antonyms) > 0 {
+			notes += "\nantonyms: " + strings.Join(entry.Antonyms, ", ")
+		}
+		for _, example := range entry.Examples {
+			notes += "\n" + example.Text
+		}
+		reading := entry.Furigana
+		for _, other := range []string{entry.Pinyin, entry.IPA} {
+			if reading == "" {
+				reading = other
+			}
+		}
+		cards = append(cards, flashcard{Front: front, Back: entry.Translation, Notes: strings.TrimSpace(notes), Reading: reading, Term: entry.Lemma})
+	}
+	return cards
+}
+
+// writeAnkiDeck writes cards in the text format of Anki's import, with the
+// headers describing the separator, the tags and the decks. Cloze cards have
+// the cloze deletions in their front and the extra information on their
+// back, and are imported as notes of the Cloze type.
+func writeAnkiDeck(w io.Writer, cards []flashcard, separator rune, tags []string, notes, cloze bool) error {
+	separatorName := string(separator)
+	for name, r := range ankiSeparators {
+		if r == separator {
+			separatorName = strings.ToUpper(name[:1]) + name[1:]
+		}
+	}
+	columns := []string{"Front", "Back"}
+	if cloze {
+		columns = []string{"Text", "Back Extra"}
+	}
+	if notes {
+		columns = append(columns, "Notes")
+	}
+	columns = append(columns, "Deck")
+
+	fmt.Fprintf(w, "#separator:%s\n", separatorName)
+	fmt.Fprintln(w, "#html:false")
+	if cloze {
+		fmt.Fprintln(w, "#notetype:Cloze")
+	}
+	if len(tags) > 0 {
+		fmt.Fprintf(w, "#tags:%s\n", strings.Join(tags, " "))
+	}
+	fmt.Fprintf(w, "#columns:%s\n", strings.Join(columns, string(separator)))
+	fmt.Fprintf(w, "#deck column:%d\n", len(columns))
+
+	writer := csv.NewWriter(w)
+	writer.Comma = separator
+	for _, card := range cards {
+		record := []string{card.Front, card.Back}
+		if notes {
+			record = append(record, card.Notes)
+		}
+		record = append(record, card.Deck)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func init() {
+	flashcardsCmd.Flags().String("separator", "tab", "The field separator: tab, comma, semicolon, pipe, colon, space or a single character")
+	flashcardsCmd.Flags().StringArray("tag", nil, "A tag added to every note (repeatable)")
+	flashcardsCmd.Flags().String("deck", "", "The name of the deck (default is the name of each input file)")
+	flashcardsCmd.Flags().Bool("notes", false, "Add a third field with the part of speech, synonyms and sentences of a word, or the back-translation and synonyms of a section")
+	flashcardsCmd.Flags().StringP("output", "o", "", "Write the deck to this file instead of stdout")
+	flashcardsCmd.Flags().Bool("force", false, "Overwrite the --output or --apkg file if it already exists")
+	flashcardsCmd.Flags().String("apkg", "", "Write the deck as an Anki package (.apkg) to this file instead of a text file")
+	flashcardsCmd.Flags().String("note-type", "starter-go-cli", "The name of the note type of the --apkg notes")
+	flashcardsCmd.Flags().StringSlice("fields", []string{"source", "translation", "reading", "audio"}, "The fields of the --apkg note type, in order: source, translation, reading, audio and notes, each optionally followed by =Name")
+	flashcardsCmd.Flags().String("audio-dir", "", "A directory with the audio of the --apkg notes, as files named after their source")
+
+	rootCmd.AddCommand(flashcardsCmd)
+}
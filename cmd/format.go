@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderer writes the finished analyses in a given output format. withFiles is
+// set when the input came from --file, in which case every analysis must be
+// attributed to its file.
+type renderer func(w io.Writer, analyses []Analysis, withFiles bool) error
+
+// renderers holds the formats printed once the analysis is done. The ndjson
+// format streams results instead and is handled by the command itself.
+var renderers = map[string]renderer{
+	"json":     renderJSON,
+	"yaml":     renderYAML,
+	"csv":      renderCSV,
+	"table":    renderTable,
+	"markdown": renderMarkdown,
+	"xliff":    renderXLIFF,
+	"xlsx":     renderXLSX,
+}
+
+// supportedFormats lists every value accepted by --format.
+func supportedFormats() []string {
+	formats := []string{"ndjson"}
+	for name := range renderers {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// documentFormats are the --format values of the commands printing a single
+// document rather than analyses.
+var documentFormats = []string{"csv", "json", "table", "yaml"}
+
+// checkDocumentFormat reports an error when format is not one of
+// documentFormats.
+func checkDocumentFormat(format string) error {
+	for _, name := range documentFormats {
+		if format == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported format %q (expected one of %s)", format, strings.Join(documentFormats, ", "))
+}
+
+// writeDocument writes v in format: as it is for the structured formats, or
+// as header and rows for the tabular ones.
+func writeDocument(w io.Writer, format string, v interface{}, header []string, rows [][]string) error {
+	switch format {
+	case "yaml":
+		return writeYAML(w, v)
+	case "csv":
+		return writeCSV(w, header, rows)
+	case "table":
+		return writeTable(w, header, rows)
+	}
+	return writeJSON(w, v)
+}
+
+// outputDocument returns the value serialized by the structured formats: the
+// analysis of a single text, or every analysis when reading files.
+func outputDocument(analyses []Analysis, withFiles bool) interface{} {
+	if withFiles || len(analyses) != 1 {
+		return analyses
+	}
+	return analyses[0]
+}
+
+func renderJSON(w io.Writer, analyses []Analysis, withFiles bool) error {
+	return writeJSON(w, outputDocument(analyses, withFiles))
+}
+
+func renderYAML(w io.Writer, analyses []Analysis, withFiles bool) error {
+	return writeYAML(w, outputDocument(analyses, withFiles))
+}
+
+// writeJSON writes v as indented JSON. HTML is left unescaped, so that the
+// ruby of --furigana stays readable.
+func writeJSON(w io.Writer, v interface{}) error {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("marshalling final results to JSON: %w", err)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeYAML writes v as YAML.
+func writeYAML(w io.Writer, v interface{}) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("marshalling final results to YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+// sourceAnnotation is a column of the tabular formats written next to the
+// source, such as its transliteration.
+type sourceAnnotation struct {
+	name  string
+	value func(ResultItem) string
+}
+
+// sourceAnnotations are the columns following the source, in order.
+var sourceAnnotations = []sourceAnnotation{
+	{"transliteration", func(item ResultItem) string { return item.Transliteration }},
+	{"ipa", func(item ResultItem) string { return item.IPA }},
+	{"pinyin", func(item ResultItem) string { return item.Pinyin }},
+	{"furigana", func(item ResultItem) string { return item.Furigana }},
+	{"audio", func(item ResultItem) string { return item.Audio }},
+	{"pos", func(item ResultItem) string {
+		if item.POS != "" {
+			return item.POS
+		}
+		return formatTaggedTokens(item.Tokens)
+	}},
+	{"level", func(item ResultItem) string { return item.Level }},
+	{"synonyms", func(item ResultItem) string { return formatWordRelations(item.Synonyms) }},
+}
+
+// tableRows flattens analyses into a header and one row per section, for the
+// tabular formats. Verification columns are only included when the results
+// were verified, and the sourceAnnotations and the explanation when any
+// section has them.
+func tableRows(analyses []Analysis, withFiles bool) ([]string, [][]string) {
+	verified, explained, located := false, false, false
+	annotated := make(map[string]bool)
+	for _, analysis := range analyses {
+		for _, item := range analysis.Results {
+			verified = verified || item.Similarity != nil
+			explained = explained || item.Explanation != ""
+			located = located || item.Page > 0
+			for _, annotation := range sourceAnnotations {
+				annotated[annotation.name] = annotated[annotation.name] || annotation.value(item) != ""
+			}
+		}
+	}
+	var annotations []sourceAnnotation
+	for _, annotation := range sourceAnnotations {
+		if annotated[annotation.name] {
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	// Multiple translation languages get a column each, in the order of the
+	// first analysis that used them.
+	var languages []string
+	for _, analysis := range analyses {
+		if len(analysis.TranslationLanguages) > 0 {
+			languages = analysis.TranslationLanguages
+			break
+		}
+	}
+
+	header := []string{"source"}
+	for _, annotation := range annotations {
+		header = append(header, annotation.name)
+	}
+	if len(languages) > 0 {
+		for _, language := range languages {
+			header = append(header, "translation_"+language)
+		}
+	} else {
+		header = append(header, "translation")
+	}
+	if explained {
+		header = append(header, "explanation")
+	}
+	if located {
+		header = append([]string{"page", "paragraph"}, header...)
+	}
+	if withFiles {
+		header = append([]string{"file"}, header...)
+	}
+	if verified {
+		header = append(header, "back_translation", "similarity", "divergent")
+	}
+
+	var rows [][]string
+	for _, analysis := range analyses {
+		for _, item := range analysis.Results {
+			row := []string{item.Source}
+			for _, annotation := range annotations {
+				row = append(row, annotation.value(item))
+			}
+			if len(languages) > 0 {
+				for _, language := range languages {
+					row = append(row, item.Translations[language])
+				}
+			} else {
+				row = append(row, item.Translation)
+			}
+			if explained {
+				row = append(row, item.Explanation)
+			}
+			if located {
+				page, paragraph := "", ""
+				if item.Page > 0 {
+					page, paragraph = strconv.Itoa(item.Page), strconv.Itoa(item.Paragraph)
+				}
+				row = append([]string{page, paragraph}, row...)
+			}
+			if withFiles {
+				row = append([]string{analysis.File}, row...)
+			}
+			if verified {
+				similarity := ""
+				if item.Similarity != nil {
+					similarity = strconv.FormatFloat(*item.Similarity, 'f', 2, 64)
+				}
+				row = append(row, item.BackTranslation, similarity, strconv.FormatBool(item.Divergent))
+			}
+			rows = append(rows, row)
+		}
+	}
+	return header, rows
+}
+
+func renderCSV(w io.Writer, analyses []Analysis, withFiles bool) error {
+	header, rows := tableRows(analyses, withFiles)
+	return writeCSV(w, header, rows)
+}
+
+// writeCSV writes header and rows as CSV.
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func renderTable(w io.Writer, analyses []Analysis, withFiles bool) error {
+	header, rows := tableRows(analyses, withFiles)
+	return writeTable(w, header, rows)
+}
+
+// writeTable writes header and rows as a table aligned for the terminal.
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	upper := make([]string, len(header))
+	rule := make([]string, len(header))
+	for i, name := range header {
+		upper[i] = strings.ToUpper(name)
+		rule[i] = strings.Repeat("-", len(name))
+	}
+	fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	fmt.Fprintln(tw, strings.Join(rule, "\t"))
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = singleLine(cell)
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func renderMarkdown(w io.Writer, analyses []Analysis, withFiles bool) error {
+	header, rows := tableRows(analyses, withFiles)
+
+	titles := make([]string, len(header))
+	rule := make([]string, len(header))
+	for i, name := range header {
+		titles[i] = strings.ToUpper(name[:1]) + name[1:]
+		rule[i] = "---"
+	}
+
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(titles, " | ") + " |\n")
+	b.WriteString("| " + strings.Join(rule, " | ") + " |\n")
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(singleLine(cell), "|", `\|`)
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// singleLine collapses the whitespace of a cell, so multi-line translations
+// don't break the row layout.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
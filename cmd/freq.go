@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// lemmaBatchSize is the number of distinct words lemmatized by a request.
+const lemmaBatchSize = 100
+
+// corpusExtensions are the extensions of the files read from the
+// directories given to --file.
+var corpusExtensions = []string{".txt", ".md"}
+
+// wordFrequency is a lemma of the counted text, with its surface forms.
+type wordFrequency struct {
+	// Rank is the position of the lemma among all of the lemmas of the text,
+	// from the most frequent, whether or not it is listed.
+	Rank  int         `json:"rank" yaml:"rank"`
+	Lemma string      `json:"lemma" yaml:"lemma"`
+	Count int         `json:"count" yaml:"count"`
+	Forms []formCount `json:"forms" yaml:"forms"`
+	Known bool        `json:"known" yaml:"known"`
+}
+
+// formCount is the number of times a surface form of a lemma appears.
+type formCount struct {
+	Form  string `json:"form" yaml:"form"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// frequencyList is the output of the freq command.
+type frequencyList struct {
+	SourceLanguage string       `json:"source_language,omitempty" yaml:"source_language,omitempty"`
+	Usage          *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Tokens         int          `json:"tokens" yaml:"tokens"`
+	Lemmas         int          `json:"lemmas" yaml:"lemmas"`
+	// KnownCoverage is the share of the tokens whose lemma is known, only
+	// set with --known.
+	KnownCoverage *float64        `json:"known_coverage,omitempty" yaml:"known_coverage,omitempty"`
+	Words         []wordFrequency `json:"words" yaml:"words"`
+}
+
+var freqCmd = &cobra.Command{
+	Use:   "freq [text]",
+	Short: "Count the lemmas of a text and list the frequent ones you don't know",
+	Long: `The "freq" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), or the files given with --file, where directories stand for every .txt and .md file they contain, and counts how often each word appears.
+The distinct words are lemmatized by the LLM, 100 to a request, so that every form of a word counts towards its lemma; with --surface the forms are counted as they are, without any request.
+With --known, a file of the words you know, one per line, the lemmas whose lemma or forms are in it are left out of the list unless --all is set, and the share of the text you know is reported: the most frequent unknown words are the ones worth learning next.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runFreq,
+}
+
+func runFreq(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if err := checkDocumentFormat(format); err != nil {
+		return err
+	}
+	files, err := cmd.Flags().GetStringArray("file")
+	if err != nil {
+		return fmt.Errorf("retrieving file flag: %w", err)
+	}
+	knownPath, err := cmd.Flags().GetString("known")
+	if err != nil {
+		return fmt.Errorf("retrieving known flag: %w", err)
+	}
+	surface, err := cmd.Flags().GetBool("surface")
+	if err != nil {
+		return fmt.Errorf("retrieving surface flag: %w", err)
+	}
+	top, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return fmt.Errorf("retrieving top flag: %w", err)
+	}
+	minCount, err := cmd.Flags().GetInt("min-count")
+	if err != nil {
+		return fmt.Errorf("retrieving min-count flag: %w", err)
+	}
+	all, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		return fmt.Errorf("retrieving all flag: %w", err)
+	}
+	if top < 0 {
+		return errors.New("--top cannot be negative")
+	}
+
+	var text string
+	if len(files) == 0 {
+		if text, err = readInputText(args); err != nil {
+			return fmt.Errorf("reading input text: %w", err)
+		}
+	} else if text, err = readCorpus(files); err != nil {
+		return fmt.Errorf("reading input files: %w", err)
+	}
+	words := textWords(text)
+	if len(words) == 0 {
+		return errors.New("the text has no words to count")
+	}
+	var known map[string]bool
+	if knownPath != "" {
+		knownWords, err := loadWordList(knownPath)
+		if err != nil {
+			return fmt.Errorf("loading known words: %w", err)
+		}
+		known = make(map[string]bool, len(knownWords))
+		for _, word := range knownWords {
+			known[word] = true
+		}
+	}
+
+	list := frequencyList{Tokens: len(words)}
+	lemmas := make(map[string]string)
+	if !surface {
+		opts, err := analysisOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		defer opts.tracer.Flush()
+		warmUp(ctx, opts)
+
+		usageBefore := opts.usage.Snapshot()
+		if opts.sourceLanguage == "" {
+			if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(text, readabilitySampleWords), opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+		if lemmas, err = lemmatizeWords(ctx, words, opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+		usage := opts.usage.Snapshot().sub(usageBefore)
+		list.SourceLanguage, list.Usage = opts.sourceLanguage, &usage
+	}
+
+	frequencies := countLemmas(words, lemmas)
+	list.Lemmas = len(frequencies)
+	knownTokens := 0
+	for i := range frequencies {
+		frequency := &frequencies[i]
+		frequency.Known = known[frequency.Lemma]
+		for _, form := range frequency.Forms {
+			frequency.Known = frequency.Known || known[form.Form]
+		}
+		if frequency.Known {
+			knownTokens += frequency.Count
+		}
+	}
+	if known != nil {
+		coverage := roundScore(float64(knownTokens) / float64(len(words)))
+		list.KnownCoverage = &coverage
+	}
+	list.Words = []wordFrequency{}
+	for _, frequency := range frequencies {
+		if top > 0 && len(list.Words) == top {
+			break
+		}
+		if frequency.Count >= minCount && (all || !frequency.Known) {
+			list.Words = append(list.Words, frequency)
+		}
+	}
+
+	header := []string{"rank", "lemma", "count", "forms"}
+	if known != nil {
+		header = append(header, "known")
+	}
+	rows := make([][]string, 0, len(list.Words))
+	for _, frequency := range list.Words {
+		forms := make([]string, len(frequency.Forms))
+		for i, form := range frequency.Forms {
+			forms[i] = fmt.Sprintf("%s (%d)", form.Form, form.Count)
+		}
+		row := []string{strconv.Itoa(frequency.Rank), frequency.Lemma, strconv.Itoa(frequency.Count), strings.Join(forms, ", ")}
+		if known != nil {
+			row = append(row, strconv.FormatBool(frequency.Known))
+		}
+		rows = append(rows, row)
+	}
+	if err := writeDocument(cmd.OutOrStdout(), format, list, header, rows); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// readCorpus reads every path of the --file flag, which may name
+// directories of corpusExtensions files, into a single text.
+func readCorpus(paths []string) (string, error) {
+	var texts []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || (file != path && !hasCorpusExtension(file)) {
+				return nil
+			}
+			text, err := readInputFile(file)
+			if err != nil {
+				return err
+			}
+			texts = append(texts, text)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(texts) == 0 {
+		return "", errors.New("no .txt or .md files found")
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// hasCorpusExtension reports whether the extension of path is one of
+// corpusExtensions.
+func hasCorpusExtension(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, known := range corpusExtensions {
+		if extension == known {
+			return true
+		}
+	}
+	return false
+}
+
+// lemmatizeWords asks the LLM for the lemma of every distinct word of words,
+// returning them keyed by word. Words the LLM leaves out are their own
+// lemma.
+func lemmatizeWords(ctx context.Context, words []string, opts analysisOptions) (map[string]string, error) {
+	seen := make(map[string]bool)
+	var distinct []string
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			distinct = append(distinct, word)
+		}
+	}
+	sort.Strings(distinct)
+	var batches [][]string
+	for start := 0; start < len(distinct); start += lemmaBatchSize {
+		batches = append(batches, distinct[start:min(start+lemmaBatchSize, len(distinct))])
+	}
+
+	opts.progress.Start(len(batches))
+	defer opts.progress.Finish()
+	lemmatize := func(_ int, batch []string) (map[string]string, error) {
+		opts.progress.Begin(batch[0])
+		req, err := renderPrompt(defaultLemmaPrompt, opts.translateModel, promptData{Text: strings.Join(batch, "\n"), SourceLanguage: opts.sourceLanguage})
+		if err != nil {
+			return nil, err
+		}
+		lemmas := make(map[string]string)
+		if err := generateJSON(ctx, opts, req, &lemmas); err != nil {
+			return nil, fmt.Errorf("lemmatizing %q to %q: %w", batch[0], batch[len(batch)-1], err)
+		}
+		opts.progress.Advance()
+		return lemmas, nil
+	}
+	results, err := analyze.RunOrdered(batches, opts.concurrency, lemmatize, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	lemmas := make(map[string]string, len(distinct))
+	for _, result := range results {
+		for word, lemma := range result {
+			if lemma = strings.ToLower(strings.TrimSpace(lemma)); seen[strings.ToLower(word)] && lemma != "" {
+				lemmas[strings.ToLower(word)] = lemma
+			}
+		}
+	}
+	return lemmas, nil
+}
+
+// countLemmas counts words by their lemma in lemmas, or by themselves when
+// they have none, from the most frequent lemma.
+func countLemmas(words []string, lemmas map[string]string) []wordFrequency {
+	forms := make(map[string]map[string]int)
+	for _, word := range words {
+		lemma := lemmas[word]
+		if lemma == "" {
+			lemma = word
+		}
+		if forms[lemma] == nil {
+			forms[lemma] = make(map[string]int)
+		}
+		forms[lemma][word]++
+	}
+
+	frequencies := make([]wordFrequency, 0, len(forms))
+	for lemma, counts := range forms {
+		frequency := wordFrequency{Lemma: lemma}
+		for form, count := range counts {
+			frequency.Count += count
+			frequency.Forms = append(frequency.Forms, formCount{Form: form, Count: count})
+		}
+		sort.Slice(frequency.Forms, func(i, j int) bool {
+			if frequency.Forms[i].Count != frequency.Forms[j].Count {
+				return frequency.Forms[i].Count > frequency.Forms[j].Count
+			}
+			return frequency.Forms[i].Form < frequency.Forms[j].Form
+		})
+		frequencies = append(frequencies, frequency)
+	}
+	sort.Slice(frequencies, func(i, j int) bool {
+		if frequencies[i].Count != frequencies[j].Count {
+			return frequencies[i].Count > frequencies[j].Count
+		}
+		return frequencies[i].Lemma < frequencies[j].Lemma
+	})
+	for i := range frequencies {
+		frequencies[i].Rank = i + 1
+	}
+	return frequencies
+}
+
+func init() {
+	addAnalysisFlags(freqCmd.Flags())
+	freqCmd.Flags().String("format", "table", "The output format: table, json, yaml or csv")
+	freqCmd.Flags().StringArrayP("file", "f", nil, "A text file, or a directory of .txt and .md files, to count (repeatable)")
+	freqCmd.Flags().String("known", "", "A file of the words you know, one per line, left out of the list")
+	freqCmd.Flags().Bool("surface", false, "Count the words as they are written instead of by lemma, without any LLM request")
+	freqCmd.Flags().Int("top", 50, "The number of lemmas listed (0 lists all of them)")
+	freqCmd.Flags().Int("min-count", 1, "Leave out the lemmas appearing fewer times")
+	freqCmd.Flags().Bool("all", false, "List the known lemmas too")
+
+	rootCmd.AddCommand(freqCmd)
+}
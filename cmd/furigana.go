@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// furiganaStyles are the ways of writing the readings of --furigana.
+var furiganaStyles = []string{"bracket", "ruby"}
+
+// furiganaSegment is a part of a Japanese text along with its reading in
+// hiragana, empty when it has no kanji.
+type furiganaSegment struct {
+	Text    string `json:"text"`
+	Reading string `json:"reading"`
+}
+
+// checkFuriganaStyle reports whether style is one of furiganaStyles, or
+// empty when --furigana is not set.
+func checkFuriganaStyle(style string) error {
+	if style == "" {
+		return nil
+	}
+	for _, known := range furiganaStyles {
+		if style == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported furigana style %q (expected %s)", style, strings.Join(furiganaStyles, " or "))
+}
+
+// isJapanese reports whether language is a BCP 47 tag of Japanese.
+func isJapanese(language string) bool {
+	primary, _, _ := strings.Cut(strings.ToLower(language), "-")
+	return primary == "ja"
+}
+
+// furiganaApplies reports whether the readings of --furigana are added to
+// text in language, warning when it was set for a language other than
+// Japanese.
+func furiganaApplies(language string, opts analysisOptions) bool {
+	if opts.furigana == "" {
+		return false
+	}
+	if !isJapanese(language) {
+		fmt.Fprintf(os.Stderr, "Warning: --furigana only applies to Japanese, not %s; leaving it out\n", language)
+		return false
+	}
+	return true
+}
+
+// annotateFurigana returns text with the readings of its kanji, written in
+// the --furigana style. Texts without kanji are returned as they are.
+func annotateFurigana(ctx context.Context, text string, opts analysisOptions) (string, error) {
+	if !strings.ContainsFunc(text, isKanji) {
+		return text, nil
+	}
+	req, err := renderPrompt(defaultFuriganaPrompt, opts.translateModel, promptData{Text: text})
+	if err != nil {
+		return "", err
+	}
+	var segments []furiganaSegment
+	if err := generateJSON(ctx, opts, req, &segments); err != nil {
+		return "", fmt.Errorf("reading the kanji of %q: %w", text, err)
+	}
+
+	// The readings are only reliable if the model kept the text intact.
+	var joined strings.Builder
+	for _, segment := range segments {
+		joined.WriteString(segment.Text)
+	}
+	if joined.String() != text {
+		return "", parseError(fmt.Errorf("reading the kanji of %q: the segments add up to %q instead", text, joined.String()))
+	}
+	return writeFurigana(segments, opts.furigana), nil
+}
+
+// writeFurigana joins segments with their readings in style: bracket
+// notation, as Anki reads it, or HTML ruby.
+func writeFurigana(segments []furiganaSegment, style string) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		reading := strings.TrimSpace(segment.Reading)
+		if reading == "" || reading == segment.Text || !strings.ContainsFunc(segment.Text, isKanji) {
+			if style == "ruby" {
+				b.WriteString(html.EscapeString(segment.Text))
+			} else {
+				b.WriteString(segment.Text)
+			}
+			continue
+		}
+		if style == "ruby" {
+			fmt.Fprintf(&b, "<ruby>%s<rt>%s</rt></ruby>", html.EscapeString(segment.Text), html.EscapeString(reading))
+			continue
+		}
+		// A space tells where the annotated base starts, unless the
+		// segment opens the text.
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(&b, "%s[%s]", segment.Text, reading)
+	}
+	return b.String()
+}
+
+// isKanji reports whether r is a kanji, including the iteration mark.
+func isKanji(r rune) bool {
+	return unicode.Is(unicode.Han, r) || r == '々'
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// integerOptions are the Ollama generation options that only accept integers.
+var integerOptions = map[string]bool{
+	"seed":          true,
+	"num_ctx":       true,
+	"num_predict":   true,
+	"top_k":         true,
+	"repeat_last_n": true,
+	"num_keep":      true,
+	"num_batch":     true,
+	"num_gpu":       true,
+	"num_thread":    true,
+	"mirostat":      true,
+	"main_gpu":      true,
+}
+
+// parseGenerationOptions merges the configured generation options with the
+// key=value pairs given through --option, which take precedence.
+func parseGenerationOptions(configured map[string]interface{}, pairs []string) (map[string]interface{}, error) {
+	if len(configured) == 0 && len(pairs) == 0 {
+		return nil, nil
+	}
+
+	options := make(map[string]interface{}, len(configured)+len(pairs))
+	for key, value := range configured {
+		if integerOptions[key] {
+			if f, ok := value.(float64); ok && f == float64(int64(f)) {
+				value = int64(f)
+			}
+		}
+		options[key] = value
+	}
+
+	for _, pair := range pairs {
+		key, raw, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid option %q: expected key=value", pair)
+		}
+		value, err := parseOptionValue(key, strings.TrimSpace(raw))
+		if err != nil {
+			return nil, err
+		}
+		options[key] = value
+	}
+
+	return options, nil
+}
+
+// parseOptionValue converts a command-line option value into the JSON type
+// Ollama expects for it.
+func parseOptionValue(key, raw string) (interface{}, error) {
+	if integerOptions[key] {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option %s=%s: expected an integer", key, raw)
+		}
+		return value, nil
+	}
+	if value, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseFloat(raw, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseBool(raw); err == nil {
+		return value, nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// glossWidth is the width the aligned glosses are wrapped at.
+const glossWidth = 80
+
+// glossToken is a word of a sentence with its interlinear gloss.
+type glossToken struct {
+	Token string `json:"token" yaml:"token"`
+	Gloss string `json:"gloss" yaml:"gloss"`
+}
+
+// glossedSentence is a sentence glossed word by word, with its free
+// translation.
+type glossedSentence struct {
+	Text        string       `json:"text" yaml:"text"`
+	Tokens      []glossToken `json:"tokens" yaml:"tokens"`
+	Translation string       `json:"translation" yaml:"translation"`
+}
+
+// interlinearGloss is the output of the gloss command.
+type interlinearGloss struct {
+	SourceLanguage      string            `json:"source_language" yaml:"source_language"`
+	TranslationLanguage string            `json:"translation_language" yaml:"translation_language"`
+	Usage               *usageTotals      `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Sentences           []glossedSentence `json:"sentences" yaml:"sentences"`
+}
+
+var glossCmd = &cobra.Command{
+	Use:   "gloss [text]",
+	Short: "Gloss a text word by word, interlinear style",
+	Long: `The "gloss" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), divides it into sentences and asks the LLM to gloss each one word by word following the Leipzig Glossing Rules.
+Every word is aligned with its literal translation into the --translation-language and the abbreviations of its grammatical categories underneath, such as child-PL, followed by the free translation of the sentence in quotes. Use --format json or yaml for the structured glosses.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runGloss,
+}
+
+func runGloss(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "text" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q (expected text, json or yaml)", format)
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	gloss, err := glossText(ctx, text, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	switch format {
+	case "json":
+		err = writeJSON(cmd.OutOrStdout(), gloss)
+	case "yaml":
+		err = writeYAML(cmd.OutOrStdout(), gloss)
+	default:
+		err = writeInterlinear(cmd.OutOrStdout(), gloss, glossWidth)
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// glossText asks the LLM for the interlinear gloss of every sentence of
+// text.
+func glossText(ctx context.Context, text string, opts analysisOptions) (interlinearGloss, error) {
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		sourceLanguage, err := detectLanguage(ctx, text, opts)
+		if err != nil {
+			return interlinearGloss{}, err
+		}
+		opts.sourceLanguage = sourceLanguage
+	}
+
+	sentences := splitSentences(text)
+	opts.progress.Start(len(sentences))
+	defer opts.progress.Finish()
+
+	glossSentence := func(_ int, sentence string) (glossedSentence, error) {
+		opts.progress.Begin(sentence)
+		req, err := renderPrompt(defaultGlossPrompt, opts.translateModel, promptData{Text: sentence, Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage})
+		if err != nil {
+			return glossedSentence{}, err
+		}
+		glossed := glossedSentence{Text: sentence}
+		if err := generateJSON(ctx, opts, req, &glossed); err != nil {
+			return glossedSentence{}, fmt.Errorf("glossing %q: %w", sentence, err)
+		}
+		glossed.Text = sentence
+		opts.progress.Advance()
+		return glossed, nil
+	}
+	glossed, err := analyze.RunOrdered(sentences, opts.concurrency, glossSentence, nil)
+	if err != nil {
+		return interlinearGloss{}, err
+	}
+
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	return interlinearGloss{
+		SourceLanguage:      opts.sourceLanguage,
+		TranslationLanguage: opts.translationLanguage,
+		Usage:               &usage,
+		Sentences:           glossed,
+	}, nil
+}
+
+// writeInterlinear writes every sentence of gloss as aligned lines of words
+// and glosses, wrapped at width, followed by its free translation.
+func writeInterlinear(w io.Writer, gloss interlinearGloss, width int) error {
+	var b strings.Builder
+	for i, sentence := range gloss.Sentences {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		var words, glosses strings.Builder
+		groups := 0
+		flush := func() {
+			if words.Len() > 0 {
+				if groups > 0 {
+					// Wrapped lines are set apart from the ones they continue.
+					b.WriteString("\n")
+				}
+				groups++
+				b.WriteString(strings.TrimRight(words.String(), " ") + "\n")
+				b.WriteString(strings.TrimRight(glosses.String(), " ") + "\n")
+			}
+			words.Reset()
+			glosses.Reset()
+		}
+		line := 0
+		for _, token := range sentence.Tokens {
+			column := max(utf8.RuneCountInString(token.Token), utf8.RuneCountInString(token.Gloss)) + 2
+			if line > 0 && line+column > width {
+				flush()
+				line = 0
+			}
+			words.WriteString(padRight(token.Token, column))
+			glosses.WriteString(padRight(token.Gloss, column))
+			line += column
+		}
+		flush()
+		if sentence.Translation != "" {
+			fmt.Fprintf(&b, "'%s'\n", sentence.Translation)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// padRight pads s with spaces to width characters.
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func init() {
+	addAnalysisFlags(glossCmd.Flags())
+	glossCmd.Flags().String("format", "text", "The output format: text, with the glosses aligned under the words, json or yaml")
+
+	rootCmd.AddCommand(glossCmd)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+)
+
+// glossaryTerm is the translation a source term must consistently get.
+type glossaryTerm = analyze.GlossaryTerm
+
+// loadGlossary reads a CSV file of source,target pairs. A header row naming
+// the columns "source" and "target" is skipped, as are lines starting with #.
+func loadGlossary(path string) ([]glossaryTerm, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var terms []glossaryTerm
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing glossary %s: %w", path, err)
+		}
+		source, target := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if line == 1 && strings.EqualFold(source, "source") && strings.EqualFold(target, "target") {
+			continue
+		}
+		if source == "" || target == "" {
+			return nil, fmt.Errorf("parsing glossary %s: empty term on record %d", path, line)
+		}
+		terms = append(terms, glossaryTerm{Source: source, Target: target})
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("glossary %s has no terms", path)
+	}
+	return terms, nil
+}
+
+// glossaryMismatches returns the source terms whose required translation is
+// missing from translation.
+func glossaryMismatches(terms []glossaryTerm, translation string) []string {
+	lower := strings.ToLower(translation)
+	var mismatches []string
+	for _, term := range terms {
+		if !strings.Contains(lower, strings.ToLower(term.Target)) {
+			mismatches = append(mismatches, term.Source)
+		}
+	}
+	return mismatches
+}
+
+// enforceGlossary flags the glossary terms result doesn't translate as
+// required. With --glossary-retranslate the section is translated once more,
+// insisting on the missing terms, before flagging whatever is still wrong.
+func enforceGlossary(ctx context.Context, result *ResultItem, terms []glossaryTerm, opts analysisOptions) error {
+	mismatches := glossaryMismatches(terms, result.Translation)
+	if len(mismatches) > 0 && opts.glossaryRetranslate {
+		var missing []glossaryTerm
+		for _, term := range terms {
+			for _, source := range mismatches {
+				if term.Source == source {
+					missing = append(missing, term)
+				}
+			}
+		}
+
+		translation, err := translateSection(ctx, result.Source, opts.sourceLanguage, opts.translationLanguage, missing, opts)
+		if err != nil {
+			return err
+		}
+		if retried := glossaryMismatches(terms, translation); len(retried) < len(mismatches) {
+			result.Translation, mismatches = translation, retried
+		}
+	}
+
+	result.GlossaryMismatches = mismatches
+	return nil
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// grammarCategories are the kinds of mistakes grammar-check reports.
+var grammarCategories = []string{"agreement", "word_order", "case", "conjugation", "tense", "spelling", "punctuation", "vocabulary", "other"}
+
+// diffToken matches a word along with the whitespace following it, which the
+// diff view compares without the whitespace.
+var diffToken = regexp.MustCompile(`\S+\s*`)
+
+// grammarIssue is a mistake of the checked text along with its correction.
+type grammarIssue struct {
+	Original    string `json:"original" yaml:"original"`
+	Correction  string `json:"correction" yaml:"correction"`
+	Category    string `json:"category" yaml:"category"`
+	Explanation string `json:"explanation" yaml:"explanation"`
+}
+
+// grammarCheck is the output of the grammar-check command.
+type grammarCheck struct {
+	SourceLanguage string         `json:"source_language" yaml:"source_language"`
+	Usage          *usageTotals   `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Text           string         `json:"text" yaml:"text"`
+	Corrected      string         `json:"corrected" yaml:"corrected"`
+	Issues         []grammarIssue `json:"issues" yaml:"issues"`
+}
+
+var grammarCheckCmd = &cobra.Command{
+	Use:   "grammar-check [text]",
+	Short: "Check the grammar of a text written by a learner",
+	Long: `The "grammar-check" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), written by a learner of its language, and asks the LLM to correct it.
+Every mistake is reported with its correction, its category (agreement, word_order, case, conjugation, tense, spelling, punctuation, vocabulary or other) and an explanation written in the --translation-language, along with the whole corrected text.
+The diff format marks the changes of the corrected text as [-removed-]{+added+}, followed by the numbered mistakes; use --format json or yaml for the structured corrections.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runGrammarCheck,
+}
+
+func runGrammarCheck(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "diff" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q (expected diff, json or yaml)", format)
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	check, err := checkGrammar(ctx, text, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	switch format {
+	case "json":
+		err = writeJSON(cmd.OutOrStdout(), check)
+	case "yaml":
+		err = writeYAML(cmd.OutOrStdout(), check)
+	default:
+		err = writeGrammarDiff(cmd.OutOrStdout(), check)
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// checkGrammar asks the LLM for the mistakes of text and its corrected
+// version.
+func checkGrammar(ctx context.Context, text string, opts analysisOptions) (grammarCheck, error) {
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		sourceLanguage, err := detectLanguage(ctx, text, opts)
+		if err != nil {
+			return grammarCheck{}, err
+		}
+		opts.sourceLanguage = sourceLanguage
+	}
+
+	req, err := renderPrompt(defaultGrammarCheckPrompt, opts.translateModel, promptData{Text: text, Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return grammarCheck{}, err
+	}
+	check := grammarCheck{Issues: []grammarIssue{}}
+	if err := generateJSON(ctx, opts, req, &check); err != nil {
+		return grammarCheck{}, fmt.Errorf("checking the grammar: %w", err)
+	}
+	check.SourceLanguage, check.Text = opts.sourceLanguage, text
+	if strings.TrimSpace(check.Corrected) == "" {
+		check.Corrected = text
+	}
+	for i, issue := range check.Issues {
+		check.Issues[i].Category = normalizeGrammarCategory(issue.Category)
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	check.Usage = &usage
+	return check, nil
+}
+
+// normalizeGrammarCategory returns category as one of grammarCategories,
+// falling back to "other".
+func normalizeGrammarCategory(category string) string {
+	category = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(category)), " ", "_")
+	for _, known := range grammarCategories {
+		if category == known {
+			return category
+		}
+	}
+	return "other"
+}
+
+// writeGrammarDiff writes the corrected text of check as a word diff of the
+// original, followed by the numbered mistakes.
+func writeGrammarDiff(w io.Writer, check grammarCheck) error {
+	var b strings.Builder
+	b.WriteString(wordDiff(check.Text, check.Corrected) + "\n")
+	if len(check.Issues) == 0 {
+		b.WriteString("\nNo mistakes found.\n")
+	} else {
+		b.WriteString("\n")
+	}
+	for i, issue := range check.Issues {
+		fmt.Fprintf(&b, "%d. %q → %q (%s)", i+1, issue.Original, issue.Correction, issue.Category)
+		if issue.Explanation != "" {
+			b.WriteString(": " + issue.Explanation)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// diffMarks are the marks around the words removed and added by a word
+// diff.
+type diffMarks struct {
+	removedStart, removedEnd string
+	addedStart, addedEnd     string
+}
+
+// plainDiffMarks are the marks of wordDiff.
+var plainDiffMarks = diffMarks{"[-", "-]", "{+", "+}"}
+
+// wordDiff marks the words of original removed in corrected as [-words-] and
+// the ones added as {+words+}, keeping the whitespace of corrected.
+func wordDiff(original, corrected string) string {
+	return markWordDiff(original, corrected, plainDiffMarks)
+}
+
+// markWordDiff is wordDiff with the given marks.
+func markWordDiff(original, corrected string, marks diffMarks) string {
+	a := diffToken.FindAllString(original, -1)
+	c := diffToken.FindAllString(corrected, -1)
+	word := func(token string) string { return strings.TrimRightFunc(token, unicode.IsSpace) }
+
+	// lengths[i][j] is the length of the longest common subsequence of the
+	// words of a[i:] and c[j:].
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(c)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(c) - 1; j >= 0; j-- {
+			if word(a[i]) == word(c[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(corrected[:len(corrected)-len(strings.TrimLeftFunc(corrected, unicode.IsSpace))])
+	var removed, added []string
+	flush := func() {
+		if len(removed) == 0 && len(added) == 0 {
+			return
+		}
+		// The whitespace after the change is kept outside of its marks.
+		last := removed
+		if len(added) > 0 {
+			last = added
+		}
+		if len(removed) > 0 {
+			b.WriteString(marks.removedStart + word(strings.Join(removed, "")) + marks.removedEnd)
+		}
+		if len(added) > 0 {
+			b.WriteString(marks.addedStart + word(strings.Join(added, "")) + marks.addedEnd)
+		}
+		b.WriteString(strings.TrimPrefix(last[len(last)-1], word(last[len(last)-1])))
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(c) {
+		switch {
+		case i < len(a) && j < len(c) && word(a[i]) == word(c[j]):
+			flush()
+			b.WriteString(c[j])
+			i, j = i+1, j+1
+		case j < len(c) && (i == len(a) || lengths[i][j+1] >= lengths[i+1][j]):
+			added = append(added, c[j])
+			j++
+		default:
+			removed = append(removed, a[i])
+			i++
+		}
+	}
+	flush()
+	return b.String()
+}
+
+func init() {
+	addAnalysisFlags(grammarCheckCmd.Flags())
+	grammarCheckCmd.Flags().String("format", "diff", "The output format: diff, with the corrections marked in the text, json or yaml")
+
+	rootCmd.AddCommand(grammarCheckCmd)
+}
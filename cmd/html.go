@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// htmlInlineElements are the elements within the text of a block, which are
+// translated along with it.
+var htmlInlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "br": true, "cite": true, "data": true,
+	"del": true, "dfn": true, "em": true, "font": true, "i": true, "img": true, "ins": true, "kbd": true,
+	"label": true, "mark": true, "q": true, "ruby": true, "rp": true, "rt": true, "s": true, "samp": true,
+	"small": true, "span": true, "strong": true, "sub": true, "sup": true, "time": true, "u": true,
+	"var": true, "wbr": true,
+}
+
+// htmlKeptElements are the elements whose content is never translated.
+var htmlKeptElements = map[string]bool{
+	"script": true, "style": true, "pre": true, "code": true, "textarea": true,
+	"svg": true, "math": true, "template": true, "noscript": true,
+}
+
+// htmlRawTextElements are the elements whose content is text, even when it
+// has angle brackets.
+var htmlRawTextElements = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// htmlVoidElements are the elements without an end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlEntity matches the character references of HTML text.
+var htmlEntity = regexp.MustCompile(`&(#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+
+// htmlNoTranslate matches the translate="no" attribute.
+var htmlNoTranslate = regexp.MustCompile(`(?i)\stranslate\s*=\s*["']?no\b`)
+
+// htmlToken is a tag, a comment or the text between them.
+type htmlToken struct {
+	Raw string
+	// Name is the lower case name of the element of a tag, empty for text
+	// and comments, which also include the doctype and the processing
+	// instructions.
+	Name    string
+	End     bool
+	Closed  bool
+	Comment bool
+}
+
+// tokenizeHTML splits an HTML document into its tags, comments and text,
+// which give back the document when joined.
+func tokenizeHTML(text string) []htmlToken {
+	var tokens []htmlToken
+	for i := 0; i < len(text); {
+		if text[i] != '<' || i+1 == len(text) {
+			end := strings.IndexByte(text[i+1:], '<')
+			if end < 0 {
+				end = len(text)
+			} else {
+				end += i + 1
+			}
+			tokens = append(tokens, htmlToken{Raw: text[i:end]})
+			i = end
+			continue
+		}
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"), strings.HasPrefix(rest, "<![CDATA["), rest[1] == '!' || rest[1] == '?':
+			closing := ">"
+			if strings.HasPrefix(rest, "<!--") {
+				closing = "-->"
+			} else if strings.HasPrefix(rest, "<![CDATA[") {
+				closing = "]]>"
+			}
+			end := strings.Index(rest, closing)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(closing)
+			}
+			tokens = append(tokens, htmlToken{Raw: rest[:end], Comment: true})
+			i += end
+		case rest[1] == '/' || isASCIILetter(rest[1]):
+			token := htmlTag(rest)
+			tokens = append(tokens, token)
+			i += len(token.Raw)
+			// The content of raw text elements runs until their end tag.
+			if htmlRawTextElements[token.Name] && !token.End && !token.Closed {
+				end := strings.Index(strings.ToLower(text[i:]), "</"+token.Name)
+				if end < 0 {
+					end = len(text) - i
+				}
+				if end > 0 {
+					tokens = append(tokens, htmlToken{Raw: text[i : i+end]})
+				}
+				i += end
+			}
+		default:
+			tokens = append(tokens, htmlToken{Raw: "<"})
+			i++
+		}
+	}
+	return tokens
+}
+
+// htmlTag reads the tag at the start of text, skipping the angle brackets
+// within its quoted attribute values.
+func htmlTag(text string) htmlToken {
+	end, quote := len(text), byte(0)
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			end = i + 1
+		}
+		if end < len(text) {
+			break
+		}
+	}
+	token := htmlToken{Raw: text[:end]}
+	name := strings.TrimPrefix(token.Raw[1:], "/")
+	token.End = len(name) < len(token.Raw)-1
+	n := 0
+	for n < len(name) && (isASCIILetter(name[n]) || name[n] >= '0' && name[n] <= '9' || name[n] == '-' || name[n] == ':') {
+		n++
+	}
+	token.Name = strings.ToLower(name[:n])
+	token.Closed = strings.HasSuffix(token.Raw, "/>") || htmlVoidElements[token.Name]
+	return token
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseHTML splits an HTML document into the text of its block elements,
+// with their inline elements, entities and comments as kept pieces, and the
+// markup around them.
+func parseHTML(text string) []docSegment {
+	tokens := tokenizeHTML(text)
+	var segments []docSegment
+	var run []htmlToken
+	flush := func() {
+		segments = append(segments, htmlText(run)...)
+		run = nil
+	}
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token.Name == "" && !token.Comment, token.Comment && len(run) > 0:
+			run = append(run, token)
+		case token.Name != "" && !token.End && !token.Closed && (htmlKeptElements[token.Name] || htmlNoTranslate.MatchString(token.Raw)):
+			// The element is kept whole, as a single piece when it is
+			// within the text of a block.
+			end := htmlElementEnd(tokens, i)
+			var b strings.Builder
+			for _, kept := range tokens[i : end+1] {
+				b.WriteString(kept.Raw)
+			}
+			if htmlInlineElements[token.Name] || token.Name == "code" {
+				run = append(run, htmlToken{Raw: b.String(), Comment: true})
+			} else {
+				flush()
+				segments = append(segments, docSegment{Text: b.String()})
+			}
+			i = end
+		case htmlInlineElements[token.Name]:
+			run = append(run, token)
+		default:
+			flush()
+			segments = append(segments, docSegment{Text: token.Raw})
+		}
+	}
+	flush()
+	return segments
+}
+
+// htmlElementEnd returns the index of the end tag of the element started by
+// tokens[start], or of the last token when it has none.
+func htmlElementEnd(tokens []htmlToken, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		if tokens[i].Name != tokens[start].Name || tokens[i].Closed {
+			continue
+		}
+		if tokens[i].End {
+			depth--
+		} else {
+			depth++
+		}
+		if depth == 0 {
+			return i
+		}
+	}
+	return len(tokens) - 1
+}
+
+// htmlText returns the segments of the text of a block, which is translated
+// with its tags, comments and entities as placeholders. The whitespace
+// around it is kept apart, and text without any letters is kept as it is.
+func htmlText(run []htmlToken) []docSegment {
+	var raw strings.Builder
+	letters := false
+	for _, token := range run {
+		raw.WriteString(token.Raw)
+		if token.Name == "" && !token.Comment {
+			letters = letters || strings.IndexFunc(htmlEntity.ReplaceAllString(token.Raw, ""), unicode.IsLetter) >= 0
+		}
+	}
+	if !letters {
+		if raw.Len() == 0 {
+			return nil
+		}
+		return []docSegment{{Text: raw.String()}}
+	}
+
+	var kept []string
+	var masked strings.Builder
+	for _, token := range run {
+		if token.Name != "" || token.Comment {
+			kept = append(kept, token.Raw)
+			masked.WriteString(docPlaceholder(len(kept) - 1))
+			continue
+		}
+		masked.WriteString(htmlEntity.ReplaceAllStringFunc(token.Raw, func(entity string) string {
+			kept = append(kept, entity)
+			return docPlaceholder(len(kept) - 1)
+		}))
+	}
+	text := masked.String()
+	trimmed := strings.TrimSpace(text)
+	start := strings.Index(text, trimmed)
+	return []docSegment{
+		{Text: text[:start]},
+		{Text: trimmed, Translate: true, Kept: kept},
+		{Text: text[start+len(trimmed):]},
+	}
+}
+
+// validHTMLTranslation reports whether the tags of translation nest the way
+// they do in source, unless they didn't in source already.
+func validHTMLTranslation(source, translation string) bool {
+	return htmlBalanced(translation) || !htmlBalanced(source)
+}
+
+// htmlBalanced reports whether every element of text is ended, in the order
+// the elements were started.
+func htmlBalanced(text string) bool {
+	var open []string
+	for _, token := range tokenizeHTML(text) {
+		switch {
+		case token.Name == "" || token.Closed:
+		case !token.End:
+			open = append(open, token.Name)
+		case len(open) == 0 || open[len(open)-1] != token.Name:
+			return false
+		default:
+			open = open[:len(open)-1]
+		}
+	}
+	return len(open) == 0
+}
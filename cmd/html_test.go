@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+const htmlFixture = `<!DOCTYPE html>
+<html lang="de">
+<head>
+  <title>Der Hund</title>
+  <style>p > b { color: red; }</style>
+  <script>if (a < b) { document.title = "Hund"; }</script>
+</head>
+<body>
+  <!-- Ein Kommentar -->
+  <h1 class="titel" data-note="a > b">Der Hund</h1>
+  <p>Der <b>Hund</b> bellt &amp; <a href="/katze" title="Die Katze">die Katze</a> läuft.</p>
+  <pre>Der Code
+  bleibt <b>so</b>.</pre>
+  <p>Rufe <code>bellen()</code> auf.</p>
+  <p translate="no">Markenname</p>
+  <textarea name="antwort">Deine Antwort</textarea>
+  <p>2024</p>
+</body>
+</html>
+`
+
+func TestTokenizeHTML(t *testing.T) {
+	var b strings.Builder
+	for _, token := range tokenizeHTML(htmlFixture) {
+		b.WriteString(token.Raw)
+	}
+	if b.String() != htmlFixture {
+		t.Errorf("joined tokens = %q, want the document", b.String())
+	}
+
+	tokens := tokenizeHTML(`<h1 data-note="a > b">`)
+	if len(tokens) != 1 || tokens[0].Name != "h1" || tokens[0].Raw != `<h1 data-note="a > b">` {
+		t.Errorf("tokens = %+v, want the whole tag", tokens)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	segments := parseHTML(htmlFixture)
+	if got := translateSegmentsWith(segments, func(s string) string { return s }); got != htmlFixture {
+		t.Errorf("wrote back %q", got)
+	}
+
+	var nodes []string
+	for _, segment := range segments {
+		if segment.Translate {
+			nodes = append(nodes, segment.Text)
+		}
+	}
+	want := []string{"Der Hund", "Der Hund", "Der ⟦1⟧Hund⟦2⟧ bellt ⟦3⟧ ⟦4⟧die Katze⟦5⟧ läuft.", "Rufe ⟦1⟧ auf."}
+	if strings.Join(nodes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("text nodes = %q, want %q", nodes, want)
+	}
+}
+
+func TestTranslateHTML(t *testing.T) {
+	translated := translateSegmentsWith(parseHTML(htmlFixture), strings.ToUpper)
+	for _, want := range []string{
+		"<title>DER HUND</title>",
+		// The content of script, style, pre, code and textarea is untouched.
+		"<style>p > b { color: red; }</style>",
+		`<script>if (a < b) { document.title = "Hund"; }</script>`,
+		"<pre>Der Code\n  bleibt <b>so</b>.</pre>",
+		"<p>RUFE <code>bellen()</code> AUF.</p>",
+		`<textarea name="antwort">Deine Antwort</textarea>`,
+		`<p translate="no">Markenname</p>`,
+		// Attributes, entities and comments are kept.
+		`<h1 class="titel" data-note="a > b">DER HUND</h1>`,
+		`<p>DER <b>HUND</b> BELLT &amp; <a href="/katze" title="Die Katze">DIE KATZE</a> LÄUFT.</p>`,
+		"<!-- Ein Kommentar -->",
+		"<p>2024</p>",
+	} {
+		if !strings.Contains(translated, want) {
+			t.Errorf("translated document lacks %s:\n%s", want, translated)
+		}
+	}
+}
+
+func TestValidHTMLTranslation(t *testing.T) {
+	for _, test := range []struct {
+		source, translation string
+		want                bool
+	}{
+		{"Der <b>Hund</b>", "The <b>dog</b>", true},
+		{"Der <b>Hund</b> <i>bellt</i>", "The <i>dog <b>barks</i></b>", false},
+		{"Der <b>Hund", "The </b>dog<b>", true},
+		{"Der Hund<br>bellt", "The dog<br>barks", true},
+	} {
+		if got := validHTMLTranslation(test.source, test.translation); got != test.want {
+			t.Errorf("validHTMLTranslation(%q, %q) = %v, want %v", test.source, test.translation, got, test.want)
+		}
+	}
+}
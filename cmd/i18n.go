@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// formatSpecifier matches the placeholders of the messages of localization
+// files: double-braced names such as {{count}}, %{name} and ${name}, printf
+// verbs, positional or named, and braced names, which the translations must
+// keep as they are.
+var formatSpecifier = regexp.MustCompile(`\{\{[^{}]*\}\}|[%$]\{[^{}]*\}|%(\d+\$)?(\([^)]*\))?[-+ #0]*(\d+|\*)?(\.(\d+|\*))?[hlLqjzt]*[diouxXeEfFgGaAcspn@%]|\{[a-zA-Z0-9_.]*\}`)
+
+var i18nCmd = &cobra.Command{
+	Use:   "i18n",
+	Short: "Translate the messages of localization files",
+	Long:  `The "i18n" commands translate the messages of the localization files of applications, keeping their format placeholders: "i18n translate" fills in JSON and YAML message bundles, "i18n po translate" gettext PO files and "i18n xliff translate" XLIFF files.`,
+}
+
+var i18nPoCmd = &cobra.Command{
+	Use:   "po",
+	Short: "Work with gettext PO files",
+}
+
+// maskMessage replaces the format specifiers of message with placeholders,
+// and keeps its leading and trailing whitespace apart, which translations
+// lose. It returns the text to translate along with them.
+func maskMessage(message string) (text string, kept []string, prefix, suffix string) {
+	trimmed := strings.TrimSpace(message)
+	start := strings.Index(message, trimmed)
+	prefix, suffix = message[:start], message[start+len(trimmed):]
+	text = formatSpecifier.ReplaceAllStringFunc(trimmed, func(specifier string) string {
+		kept = append(kept, specifier)
+		return docPlaceholder(len(kept) - 1)
+	})
+	return text, kept, prefix, suffix
+}
+
+func init() {
+	i18nCmd.AddCommand(i18nPoCmd)
+	rootCmd.AddCommand(i18nCmd)
+}
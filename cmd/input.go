@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readInputText returns the text to analyze. A single positional argument is
+// used as-is, unless it is "-", in which case the text is read from stdin. With
+// no arguments the text is read from stdin as long as it is not a terminal.
+func readInputText(args []string) (string, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return args[0], nil
+	}
+
+	if len(args) == 0 {
+		info, err := os.Stdin.Stat()
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeCharDevice != 0 {
+			return "", errors.New("no text provided: pass it as an argument or pipe it through stdin")
+		}
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return "", errors.New("no text provided on stdin")
+	}
+	return text, nil
+}
+
+// readInputFile reads and decodes a text file passed through --file.
+func readInputFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := decodeText(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("%s: file is empty", path)
+	}
+	return text, nil
+}
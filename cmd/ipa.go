@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ipaSymbols are the characters of the International Phonetic Alphabet
+// besides the basic Latin letters: the letters of its chart, the
+// suprasegmentals, the tone letters and the modifier letters. Combining
+// diacritics are allowed separately.
+const ipaSymbols = "æɐɑɒɓʙβɔɕçɗɖðʤəɘɚɛɜɝɞɟʄɡɠɢʛɦɧħɥʜɨɪʝɭɬɫɮʟɱɯɰŋɳɲɴøɵɸθœɶʘɹɺɾɻʀʁɽʂʃʈʧʉʊʋⱱʌɣɤʍχʎʏʑʐʒʔʡʕʢǀǁǂǃʦʣʨʥɿʅʮʯ" +
+	"ˈˌːˑ.|‖‿↗↘ꜛꜜ˥˦˧˨˩˞" +
+	"ʰʱʷʲˠˤⁿˡʴʵʶʼ"
+
+// invalidIPA returns the characters of transcription that are not part of
+// the IPA, once each, in the order they appear.
+func invalidIPA(transcription string) []string {
+	var invalid []string
+	seen := make(map[rune]bool)
+	for _, r := range transcription {
+		switch {
+		case r >= 'a' && r <= 'z', r == ' ', r == ',':
+		case strings.ContainsRune(ipaSymbols, r), unicode.Is(unicode.Mn, r):
+		default:
+			if !seen[r] {
+				seen[r] = true
+				invalid = append(invalid, string(r))
+			}
+		}
+	}
+	return invalid
+}
+
+// transcribeIPA asks the LLM for the IPA transcription of text, asking once
+// more when the transcription has characters that are not part of the IPA.
+func transcribeIPA(ctx context.Context, text string, opts analysisOptions) (string, error) {
+	req, err := renderPrompt(defaultIPAPrompt, opts.translateModel, promptData{Text: text, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return "", err
+	}
+	response, err := generate(ctx, opts, req)
+	if err != nil {
+		return "", fmt.Errorf("transcribing %q: %w", text, err)
+	}
+	transcription := trimIPA(response)
+	invalid := invalidIPA(transcription)
+	if len(invalid) == 0 {
+		return transcription, nil
+	}
+
+	correction := req
+	correction.Prompt = fmt.Sprintf("%s\n\nYour previous answer %q has characters that are not part of the International Phonetic Alphabet (%s). Respond again with only the IPA transcription.", req.Prompt, transcription, strings.Join(invalid, " "))
+	response, err = generate(ctx, opts, correction)
+	if err != nil {
+		return "", fmt.Errorf("transcribing %q: %w", text, err)
+	}
+	transcription = trimIPA(response)
+	if invalid := invalidIPA(transcription); len(invalid) > 0 {
+		return "", parseError(fmt.Errorf("transcribing %q: %q is not IPA (%s)", text, transcription, strings.Join(invalid, " ")))
+	}
+	return transcription, nil
+}
+
+// trimIPA removes the slashes or brackets a transcription is often enclosed
+// in, along with the whitespace around it and the punctuation of the text
+// copied at its end.
+func trimIPA(response string) string {
+	transcription := strings.TrimSpace(response)
+	transcription = strings.TrimPrefix(strings.TrimSuffix(transcription, "/"), "/")
+	transcription = strings.TrimPrefix(strings.TrimSuffix(transcription, "]"), "[")
+	return strings.TrimRight(strings.TrimSpace(transcription), ".!?,;: ")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// resolveKeepAlive returns the --keep-alive duration of the Ollama model,
+// falling back to STARTER_GO_CLI_KEEP_ALIVE and the config file. A number
+// is a count of seconds, like in the Ollama API, and is returned as a
+// duration.
+func resolveKeepAlive(cmd *cobra.Command, cfg *config) (string, error) {
+	keepAlive, err := resolveSetting(cmd, "keep-alive", "STARTER_GO_CLI_KEEP_ALIVE", cfg.KeepAlive, "")
+	if err != nil {
+		return "", fmt.Errorf("retrieving keep-alive flag: %w", err)
+	}
+	if keepAlive == "" {
+		return "", nil
+	}
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return (time.Duration(seconds) * time.Second).String(), nil
+	}
+	if _, err := time.ParseDuration(keepAlive); err != nil {
+		return "", fmt.Errorf("invalid keep-alive %q (expected a duration such as 10m, or -1 to keep the model loaded)", keepAlive)
+	}
+	return keepAlive, nil
+}
+
+// warmUp loads the models of the analysis before the first request, when
+// --keep-alive is set and the provider is able to, so that the model stays
+// loaded from the first section to the last. A model missing from the host
+// is pulled like for any other request; the other failures are only logged,
+// leaving them to the requests of the analysis.
+func warmUp(ctx context.Context, opts analysisOptions) {
+	warmer, ok := opts.provider.(llm.Warmer)
+	if !ok || opts.keepAlive == "" {
+		return
+	}
+	models := []string{opts.segmentModel}
+	if opts.translatorKey == "" && opts.translateModel != opts.segmentModel {
+		models = append(models, opts.translateModel)
+	}
+	for _, model := range models {
+		start := time.Now()
+		err := warmer.Warm(ctx, model)
+		if err != nil && pullMissingModel(ctx, opts, err) == nil {
+			err = warmer.Warm(ctx, model)
+		}
+		if err != nil {
+			verbosef("Warming up %s failed: %v", model, err)
+			continue
+		}
+		verbosef("Warmed up %s in %s, keeping it loaded for %s", model, time.Since(start).Round(time.Millisecond), opts.keepAlive)
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// detectLanguage returns the BCP 47 tag of the language text is written in,
+// detected with the --detector.
+func detectLanguage(ctx context.Context, text string, opts analysisOptions) (string, error) {
+	detection, err := identifyLanguage(ctx, text, opts)
+	if err != nil {
+		return "", err
+	}
+	return detection.Language, nil
+}
+
+// detectLanguageWithLLM asks the LLM which language text is written in, and
+// how confident it is.
+func detectLanguageWithLLM(ctx context.Context, text string, opts analysisOptions) (languageDetection, error) {
+	detection, err := newAnalyzer(opts).DetectLanguage(ctx, text, opts.segmentModel)
+	if err != nil {
+		return languageDetection{}, providerError(err)
+	}
+	return languageDetection{Language: detection.Language, Confidence: detection.Confidence, Detector: "llm"}, nil
+}
+
+// resolveWordLanguage returns the language of the word looked up by the
+// dictionary commands, from their --language flag or else
+// --source-language, since a single word is not enough to detect it.
+func resolveWordLanguage(cmd *cobra.Command, opts analysisOptions) (string, error) {
+	language, err := cmd.Flags().GetString("language")
+	if err != nil {
+		return "", fmt.Errorf("retrieving language flag: %w", err)
+	}
+	if language == "" {
+		language = opts.sourceLanguage
+	}
+	if language == "" {
+		return "", errors.New("set the language of the word with --language, such as --language de-DE")
+	}
+	return language, nil
+}
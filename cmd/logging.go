@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// logLevel controls the diagnostics written to stderr.
+type logLevel int
+
+const (
+	logQuiet logLevel = iota
+	// logVerbose logs every request with its latency and status.
+	logVerbose
+	// logDebug additionally dumps request and response bodies.
+	logDebug
+)
+
+var (
+	currentLogLevel logLevel
+	logMu           sync.Mutex
+)
+
+func logf(level logLevel, format string, a ...interface{}) {
+	if currentLogLevel < level {
+		return
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	fmt.Fprintf(os.Stderr, "%s %s\n", time.Now().Format("15:04:05.000"), fmt.Sprintf(format, a...))
+}
+
+// verbosef logs a message with --verbose or --debug.
+func verbosef(format string, a ...interface{}) {
+	logf(logVerbose, format, a...)
+}
+
+// debugf logs a message with --debug only.
+func debugf(format string, a ...interface{}) {
+	logf(logDebug, format, a...)
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// markdownFence matches the opening and closing lines of fenced code
+	// blocks.
+	markdownFence = regexp.MustCompile("^[ \t]*(`{3,}|~{3,})")
+	// markdownHeading matches ATX headings, with their optional closing
+	// hashes.
+	markdownHeading = regexp.MustCompile(`^( {0,3}#{1,6}[ \t]+)(.*?)([ \t]+#+[ \t]*)?$`)
+	// markdownBreak matches thematic breaks.
+	markdownBreak = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	// markdownSetext matches the underlines of setext headings.
+	markdownSetext = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	// markdownReference matches link reference definitions.
+	markdownReference = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:[ \t]`)
+	// markdownHTML matches the first line of HTML blocks.
+	markdownHTML = regexp.MustCompile(`^ {0,3}<[a-zA-Z/!?]`)
+	// markdownTableDivider matches the line between the header of a table
+	// and its rows.
+	markdownTableDivider = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	// markdownMarker matches the markers starting a line: its indentation,
+	// the quote markers and the list item marker, with the box of a task.
+	markdownMarker = regexp.MustCompile(`^[ \t]*(>[ \t]?)*([ \t]*([-*+]|\d{1,9}[.)])[ \t]+(\[[ xX]\][ \t]+)?)?`)
+	// markdownListItem matches the lines starting a list item.
+	markdownListItem = regexp.MustCompile(`^[ \t]*(>[ \t]?)*[ \t]*([-*+]|\d{1,9}[.)])[ \t]+`)
+	// markdownQuote matches the indentation and the quote markers of a line.
+	markdownQuote = regexp.MustCompile(`^[ \t]*(>[ \t]?)*`)
+	// markdownInline matches the pieces of text nodes that are not
+	// translated: code spans, images, link targets, autolinks, URLs and
+	// inline HTML.
+	markdownInline = regexp.MustCompile("`+[^`]*`+|!\\[[^\\]]*\\]\\([^)]*\\)|\\]\\([^)]*\\)|\\]\\[[^\\]]*\\]|<https?://[^>]*>|</?[a-zA-Z][^>]*>|https?://[^\\s)>]+")
+)
+
+// parseMarkdown splits a Markdown document into its text nodes, the text of
+// its headings, paragraphs, list items and table cells, and the markup and
+// code around them.
+func parseMarkdown(text string) []docSegment {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	var segments []docSegment
+	keep := func(markup string) {
+		segments = append(segments, docSegment{Text: markup})
+	}
+	node := func(prefix, text, suffix string) {
+		keep(prefix)
+		segments = append(segments, markdownText([]string{text}, nil))
+		keep(suffix)
+	}
+	row := func(line string) {
+		for i, cell := range strings.Split(line, "|") {
+			if i > 0 {
+				keep("|")
+			}
+			trimmed := strings.TrimSpace(cell)
+			if trimmed == "" {
+				keep(cell)
+				continue
+			}
+			start := strings.Index(cell, trimmed)
+			node(cell[:start], trimmed, cell[start+len(trimmed):])
+		}
+		keep("\n")
+	}
+
+	i := 0
+	// The YAML or TOML front matter is kept as it is.
+	if lines[0] == "---" || lines[0] == "+++" {
+		for j := 1; j < len(lines); j++ {
+			if lines[j] == lines[0] {
+				keep(strings.Join(lines[:j+1], "\n") + "\n")
+				i = j + 1
+				break
+			}
+		}
+	}
+	for i < len(lines) {
+		line := lines[i]
+		indented := (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) && (i == 0 || strings.TrimSpace(lines[i-1]) == "")
+		switch {
+		case strings.TrimSpace(line) == "":
+			keep(line + "\n")
+			i++
+		case markdownFence.MatchString(line):
+			fence := markdownFence.FindStringSubmatch(line)[1]
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+				j++
+			}
+			j = min(j+1, len(lines))
+			keep(strings.Join(lines[i:j], "\n") + "\n")
+			i = j
+		case indented, markdownHTML.MatchString(line):
+			// Indented code and HTML blocks run until the next blank line.
+			j := i
+			for j < len(lines) && strings.TrimSpace(lines[j]) != "" {
+				j++
+			}
+			keep(strings.Join(lines[i:j], "\n") + "\n")
+			i = j
+		case markdownBreak.MatchString(line), markdownReference.MatchString(line):
+			keep(line + "\n")
+			i++
+		case markdownHeading.MatchString(line):
+			match := markdownHeading.FindStringSubmatch(line)
+			node(match[1], match[2], match[3]+"\n")
+			i++
+		case strings.Contains(line, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") && markdownTableDivider.MatchString(lines[i+1]):
+			row(line)
+			keep(lines[i+1] + "\n")
+			i += 2
+			for i < len(lines) && strings.Contains(lines[i], "|") {
+				row(lines[i])
+				i++
+			}
+		default:
+			// The lines of a paragraph or a list item are translated
+			// together, keeping the breaks between them with the quote
+			// markers and the indentation of the following ones. The lines
+			// ending with a hard break are translated on their own.
+			prefix := markdownMarker.FindString(line)
+			keep(prefix)
+			texts := []string{line[len(prefix):]}
+			var breaks []string
+			for i++; i < len(lines) && markdownContinues(lines[i]); i++ {
+				last := texts[len(texts)-1]
+				text := strings.TrimRight(last, " \t")
+				lineBreak := last[len(text):] + "\n"
+				// Hard breaks are two spaces or more, or a backslash.
+				hard := len(lineBreak) > 2
+				if strings.HasSuffix(text, "\\") {
+					text, lineBreak, hard = text[:len(text)-1], "\\"+lineBreak, true
+				}
+				texts[len(texts)-1] = text
+				next := lines[i]
+				start := len(markdownQuote.FindString(next))
+				start += len(next[start:]) - len(strings.TrimLeft(next[start:], " \t"))
+				lineBreak += next[:start]
+				if hard {
+					segments = append(segments, markdownText(texts, breaks))
+					keep(lineBreak)
+					texts, breaks = nil, nil
+				} else {
+					breaks = append(breaks, lineBreak)
+				}
+				texts = append(texts, next[start:])
+			}
+			last := texts[len(texts)-1]
+			texts[len(texts)-1] = strings.TrimRight(last, " \t")
+			segments = append(segments, markdownText(texts, breaks))
+			suffix := last[len(texts[len(texts)-1]):] + "\n"
+			if i < len(lines) && markdownSetext.MatchString(lines[i]) && !markdownListItem.MatchString(prefix) {
+				suffix += lines[i] + "\n"
+				i++
+			}
+			keep(suffix)
+		}
+	}
+	// A document not ending with a line break is written back without one.
+	if !strings.HasSuffix(text, "\n") {
+		last := &segments[len(segments)-1]
+		last.Text = strings.TrimSuffix(last.Text, "\n")
+	}
+	return segments
+}
+
+// markdownContinues reports whether line continues the paragraph or the list
+// item before it rather than starting a block of its own.
+func markdownContinues(line string) bool {
+	return strings.TrimSpace(line) != "" &&
+		!markdownFence.MatchString(line) &&
+		!markdownHeading.MatchString(line) &&
+		!markdownBreak.MatchString(line) &&
+		!markdownSetext.MatchString(line) &&
+		!markdownListItem.MatchString(line) &&
+		!markdownHTML.MatchString(line)
+}
+
+// markdownText returns the text node of lines, separated by breaks, joined
+// into one with their markdownInline pieces replaced by placeholders. Text
+// without any letters outside of them is kept as it is.
+func markdownText(lines, breaks []string) docSegment {
+	if strings.IndexFunc(markdownInline.ReplaceAllString(strings.Join(lines, " "), ""), unicode.IsLetter) < 0 {
+		var b strings.Builder
+		for i, line := range lines {
+			if i > 0 {
+				b.WriteString(breaks[i-1])
+			}
+			b.WriteString(line)
+		}
+		return docSegment{Text: b.String()}
+	}
+	segment := docSegment{Translate: true}
+	masked := make([]string, len(lines))
+	words := 0
+	for i, line := range lines {
+		masked[i] = markdownInline.ReplaceAllStringFunc(line, func(piece string) string {
+			segment.Kept = append(segment.Kept, piece)
+			return docPlaceholder(len(segment.Kept) - 1)
+		})
+		if i > 0 {
+			segment.Breaks = append(segment.Breaks, docBreak{After: words, Text: breaks[i-1]})
+		}
+		words += len(strings.Fields(masked[i]))
+	}
+	segment.Text = strings.Join(masked, " ")
+	return segment
+}
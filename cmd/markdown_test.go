@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+const markdownFixture = `---
+title: Der Hund
+---
+# Der Hund
+
+Der Hund bellt, wenn er
+den ` + "`Briefträger`" + ` sieht.
+
+Roses are red,` + "  " + `
+violets are blue,\
+sugar is sweet.
+
+> Ein Zitat über
+> zwei Zeilen.
+
+- Erster Punkt
+  mit einer zweiten Zeile
+- [ ] Zweiter Punkt
+
+` + "```go" + `
+// Der Code bleibt.
+fmt.Println("Hund")
+` + "```" + `
+
+| Tier | Laut |
+| ---- | ---- |
+| Hund | Wau  |
+
+Siehe [die Seite](https://example.com/hund).
+`
+
+// markdownNodes returns the text nodes of segments.
+func markdownNodes(segments []docSegment) []string {
+	var nodes []string
+	for _, segment := range segments {
+		if segment.Translate {
+			nodes = append(nodes, segment.Text)
+		}
+	}
+	return nodes
+}
+
+func TestParseMarkdownRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		markdownFixture,
+		strings.TrimSuffix(markdownFixture, "\n"),
+		strings.ReplaceAll(markdownFixture, "\n", "\n\n"),
+		"Ohne Zeilenumbruch",
+		"",
+		"\n",
+	} {
+		if got := translateSegmentsWith(parseMarkdown(text), func(s string) string { return s }); got != text {
+			t.Errorf("parseMarkdown(%q) wrote back %q", text, got)
+		}
+	}
+}
+
+func TestParseMarkdownNodes(t *testing.T) {
+	want := []string{
+		"Der Hund",
+		"Der Hund bellt, wenn er den ⟦1⟧ sieht.",
+		"Roses are red,",
+		"violets are blue,",
+		"sugar is sweet.",
+		"Ein Zitat über zwei Zeilen.",
+		"Erster Punkt mit einer zweiten Zeile",
+		"Zweiter Punkt",
+		"Tier", "Laut", "Hund", "Wau",
+		"Siehe [die Seite⟦1⟧.",
+	}
+	got := markdownNodes(parseMarkdown(markdownFixture))
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("text nodes = %q, want %q", got, want)
+	}
+}
+
+func TestTranslateMarkdown(t *testing.T) {
+	translated := translateSegmentsWith(parseMarkdown(markdownFixture), strings.ToUpper)
+	for _, want := range []string{
+		"---\ntitle: Der Hund\n---\n# DER HUND\n\n",
+		// The lines of a paragraph keep their breaks, and the code span.
+		"DER HUND BELLT, WENN ER\nDEN `Briefträger` SIEHT.\n\n",
+		"ROSES ARE RED,  \nVIOLETS ARE BLUE,\\\nSUGAR IS SWEET.\n\n",
+		"> EIN ZITAT ÜBER\n> ZWEI ZEILEN.\n\n",
+		"- ERSTER PUNKT\n  MIT EINER ZWEITEN ZEILE\n- [ ] ZWEITER PUNKT\n\n",
+		"```go\n// Der Code bleibt.\nfmt.Println(\"Hund\")\n```\n\n",
+		"| TIER | LAUT |\n| ---- | ---- |\n| HUND | WAU  |\n\n",
+		"SIEHE [DIE SEITE](https://example.com/hund).\n",
+	} {
+		if !strings.Contains(translated, want) {
+			t.Errorf("translated document lacks %q:\n%s", want, translated)
+		}
+	}
+	if !strings.HasSuffix(translated, ".\n") || strings.HasSuffix(translated, "\n\n") {
+		t.Errorf("translated document ends with %q", translated[len(translated)-5:])
+	}
+
+	// A document without a final line break is written back without one.
+	if got := translateSegmentsWith(parseMarkdown("# Titel\n\nText"), strings.ToUpper); got != "# TITEL\n\nTEXT" {
+		t.Errorf("translated %q", got)
+	}
+}
+
+func TestBreakLines(t *testing.T) {
+	breaks := []docBreak{{After: 2, Text: "\n> "}, {After: 4, Text: "\n> "}}
+	for _, test := range []struct {
+		text string
+		want string
+	}{
+		{"a b c d e f", "a b\n> c d\n> e f"},
+		// The breaks go after about as many words, in proportion.
+		{"a b c d e f g h i", "a b c\n> d e f\n> g h i"},
+		{"a b c", "a\n> b\n> c"},
+		// Every line keeps a word, dropping the breaks without one.
+		{"a b", "a\n> b"},
+		{"a", "a"},
+		{"a  b\nc d  e f", "a b\n> c d\n> e f"},
+	} {
+		if got := breakLines(test.text, breaks, 6); got != test.want {
+			t.Errorf("breakLines(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// memoryStats counts the sections of an analysis found in the --memory,
+// exactly or by a fuzzy match, and the ones translated anew.
+type memoryStats struct {
+	Exact  int `json:"exact" yaml:"exact"`
+	Fuzzy  int `json:"fuzzy" yaml:"fuzzy"`
+	Misses int `json:"misses" yaml:"misses"`
+}
+
+// sub returns the stats since earlier.
+func (s memoryStats) sub(earlier memoryStats) memoryStats {
+	return memoryStats{Exact: s.Exact - earlier.Exact, Fuzzy: s.Fuzzy - earlier.Fuzzy, Misses: s.Misses - earlier.Misses}
+}
+
+// memoryEntry is a source and its translation, along with the trigrams of
+// the source that fuzzy matches are scored with.
+type memoryEntry struct {
+	source      string
+	translation string
+	grams       map[string]int
+}
+
+// translationMemory holds the translations of earlier runs, which the
+// sections are looked up in before being translated. The sections
+// translated during the run are added to it, so that the same section gets
+// the same translation in every document.
+type translationMemory struct {
+	// threshold is the lowest similarity of a fuzzy match, 1 allowing only
+	// exact ones.
+	threshold float64
+
+	mu sync.Mutex
+	// pairs holds the entries of every language pair, see memoryPair, and
+	// exact the same entries by memoryKey.
+	pairs map[string][]*memoryEntry
+	exact map[string]*memoryEntry
+	stats memoryStats
+}
+
+// memoryPair identifies a language pair of the memory. Sources match by
+// their primary language, as the detected languages often lack a region,
+// while translations keep theirs.
+func memoryPair(from, to string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(from), "-")
+	return primary + "\x00" + strings.ToLower(to)
+}
+
+// memoryKey returns source with its spacing normalized, as exact matches
+// ignore it.
+func memoryKey(source string) string {
+	return strings.Join(strings.Fields(source), " ")
+}
+
+// loadTranslationMemory reads the memory of the TMX files and the JSON
+// results of analise at paths. A source found in several of them takes the
+// translation of the last one.
+func loadTranslationMemory(paths []string, threshold float64) (*translationMemory, error) {
+	memory := &translationMemory{threshold: threshold, pairs: make(map[string][]*memoryEntry), exact: make(map[string]*memoryEntry)}
+	for _, path := range paths {
+		if strings.EqualFold(filepath.Ext(path), ".tmx") {
+			if err := memory.addTMX(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data, name, err := readResults(path)
+		if err != nil {
+			return nil, err
+		}
+		analyses, err := parseAnalyses(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, analysis := range analyses {
+			for _, item := range analysis.Results {
+				memory.add(item.Source, item.Translation, analysis.SourceLanguage, analysis.TranslationLanguage)
+				for language, translation := range item.Translations {
+					memory.add(item.Source, translation, analysis.SourceLanguage, language)
+				}
+			}
+		}
+	}
+	return memory, nil
+}
+
+// addTMX adds the translation units of the TMX file at path. The source of
+// a unit is its variant in the source language of the file, or its first
+// one.
+func (m *translationMemory) addTMX(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var document struct {
+		Header struct {
+			SourceLanguage string `xml:"srclang,attr"`
+		} `xml:"header"`
+		Units []struct {
+			Variants []struct {
+				// The lang attribute of TMX 1.1 is xml:lang since 1.2.
+				Language string `xml:"lang,attr"`
+				Segment  string `xml:"seg"`
+			} `xml:"tuv"`
+		} `xml:"body>tu"`
+	}
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("parsing TMX file %s: %w", path, err)
+	}
+	if len(document.Units) == 0 {
+		return fmt.Errorf("%s: no translation units", path)
+	}
+	for _, unit := range document.Units {
+		source := -1
+		for i, variant := range unit.Variants {
+			if strings.EqualFold(variant.Language, document.Header.SourceLanguage) {
+				source = i
+				break
+			}
+		}
+		if source < 0 {
+			source = 0
+		}
+		for i, variant := range unit.Variants {
+			if i != source {
+				m.add(unit.Variants[source].Segment, variant.Segment, unit.Variants[source].Language, variant.Language)
+			}
+		}
+	}
+	return nil
+}
+
+// add stores the translation of source from one language into another.
+func (m *translationMemory) add(source, translation, from, to string) {
+	source, translation = strings.TrimSpace(source), strings.TrimSpace(translation)
+	if source == "" || translation == "" || to == "" {
+		return
+	}
+	pair := memoryPair(from, to)
+	key := pair + "\x00" + memoryKey(source)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.exact[key]; ok {
+		entry.translation = translation
+		return
+	}
+	entry := &memoryEntry{source: source, translation: translation, grams: trigrams(source)}
+	m.exact[key] = entry
+	m.pairs[pair] = append(m.pairs[pair], entry)
+}
+
+// lookup returns the translation of source in the memory: the one of the
+// same source, or else the one of the most similar source scoring at least
+// the threshold. It counts the hit or the miss.
+func (m *translationMemory) lookup(source, from, to string) (string, bool) {
+	pair := memoryPair(from, to)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry, ok := m.exact[pair+"\x00"+memoryKey(source)]; ok {
+		m.stats.Exact++
+		return entry.translation, true
+	}
+	if m.threshold < 1 {
+		grams := trigrams(source)
+		var best *memoryEntry
+		bestScore := m.threshold
+		for _, entry := range m.pairs[pair] {
+			if score := trigramSimilarity(grams, entry.grams); score >= bestScore && (best == nil || score > bestScore) {
+				best, bestScore = entry, score
+			}
+		}
+		if best != nil {
+			verbosef("Fuzzy memory match %.2f for %q: %q", bestScore, source, best.source)
+			m.stats.Fuzzy++
+			return best.translation, true
+		}
+	}
+	m.stats.Misses++
+	return "", false
+}
+
+// Snapshot returns the stats so far.
+func (m *translationMemory) Snapshot() memoryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// translateWithMemory returns the translation of section in the --memory,
+// or else translates it and adds it to the memory.
+func translateWithMemory(ctx context.Context, section, from, to string, glossary []glossaryTerm, opts analysisOptions) (string, error) {
+	if opts.memory == nil {
+		return translateSection(ctx, section, from, to, glossary, opts)
+	}
+	if translation, ok := opts.memory.lookup(section, from, to); ok {
+		return translation, nil
+	}
+	translation, err := translateSection(ctx, section, from, to, glossary, opts)
+	if err != nil {
+		return "", err
+	}
+	opts.memory.add(section, translation, from, to)
+	return translation, nil
+}
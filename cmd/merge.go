@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// mergeStrategies are the ways merge resolves the sections translated
+// differently by several results.
+var mergeStrategies = []string{"newest", "longest", "interactive"}
+
+// resultOrigin is where a section of merged results was taken from.
+type resultOrigin struct {
+	// Results is the results file, and File the file of its analysis when
+	// it has one.
+	Results string `json:"results" yaml:"results"`
+	File    string `json:"file,omitempty" yaml:"file,omitempty"`
+	// ModifiedAt is when the results file was last written, the time the
+	// newest strategy goes by.
+	ModifiedAt time.Time `json:"modified_at" yaml:"modified_at"`
+}
+
+// resultsMerge gathers the sections of several results, an analysis per
+// language pair, along with what was left out.
+type resultsMerge struct {
+	Analyses []Analysis
+	// sections holds the index of every section of Analyses by its language
+	// pair and memoryKey.
+	sections map[string]int
+	pairs    map[string]int
+	// Duplicates counts the sections found again with the same translation,
+	// and Conflicts the ones found with another.
+	Duplicates int
+	Conflicts  int
+}
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <results.json>...",
+	Short: "Combine the results of several runs of analise into one",
+	Long: `The "merge" command combines the JSON results of analise, align or an earlier merge into a single file, with an analysis per pair of languages: the sections of every file are concatenated in order, and the ones found more than once, by their source, are only kept once.
+A section translated differently by several results is resolved by the --strategy: newest takes the translation of the results file written last, longest the longest translation, and interactive asks which one to keep for every conflict.
+Every section records where it comes from under origin: the results file, the file of its analysis and when the results were written, which later merges keep.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMerge,
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	strategy, err := cmd.Flags().GetString("strategy")
+	if err != nil {
+		return fmt.Errorf("retrieving strategy flag: %w", err)
+	}
+	known := false
+	for _, name := range mergeStrategies {
+		if strategy == name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unsupported strategy %q (expected %s)", strategy, strings.Join(mergeStrategies, ", "))
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+	if outputPath != "" {
+		if err := checkOutputPath(outputPath, force); err != nil {
+			return err
+		}
+	}
+	stdin := 0
+	for _, path := range args {
+		if path == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return errors.New("stdin can only be merged once")
+	}
+	if stdin > 0 && strategy == "interactive" {
+		return errors.New("--strategy interactive cannot be combined with results read from stdin")
+	}
+
+	// The conflicts are asked about on stderr, stdout being the merged
+	// results.
+	in := bufio.NewReader(cmd.InOrStdin())
+	resolve := func(kept, found ResultItem) (bool, error) {
+		switch strategy {
+		case "longest":
+			return translationLength(found) > translationLength(kept), nil
+		case "interactive":
+			return askMergeChoice(cmd.ErrOrStderr(), in, kept, found)
+		}
+		return found.Origin.ModifiedAt.After(kept.Origin.ModifiedAt), nil
+	}
+
+	merge := &resultsMerge{sections: make(map[string]int), pairs: make(map[string]int)}
+	for _, path := range args {
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		analyses, err := parseAnalyses(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		modifiedAt := time.Now()
+		if info, err := os.Stat(path); err == nil && path != "-" {
+			modifiedAt = info.ModTime()
+		}
+		for _, analysis := range analyses {
+			origin := resultOrigin{Results: name, File: analysis.File, ModifiedAt: modifiedAt}
+			if err := merge.add(analysis, origin, resolve); err != nil {
+				return err
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := writeJSON(&b, merge.Analyses); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	sections := 0
+	for _, analysis := range merge.Analyses {
+		sections += len(analysis.Results)
+	}
+	if outputPath == "" {
+		if _, err := cmd.OutOrStdout().Write(b.Bytes()); err != nil {
+			return err
+		}
+	} else if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Merged %d sections from %d files, leaving out %d duplicates and resolving %d conflicts\n", sections, len(args), merge.Duplicates, merge.Conflicts)
+	return nil
+}
+
+// add merges the sections of analysis, taken from origin unless they were
+// merged before. A section already merged with another translation is
+// replaced by the new one when resolve says so.
+func (m *resultsMerge) add(analysis Analysis, origin resultOrigin, resolve func(kept, found ResultItem) (bool, error)) error {
+	pair := strings.ToLower(analysis.SourceLanguage + "\x00" + analysis.TranslationLanguage + "\x00" + strings.Join(analysis.TranslationLanguages, ","))
+	index, ok := m.pairs[pair]
+	if !ok {
+		index = len(m.Analyses)
+		m.pairs[pair] = index
+		m.Analyses = append(m.Analyses, Analysis{
+			SourceLanguage:        analysis.SourceLanguage,
+			TranslationLanguage:   analysis.TranslationLanguage,
+			TranslationLanguages:  analysis.TranslationLanguages,
+			TransliterationScheme: analysis.TransliterationScheme,
+			Results:               []ResultItem{},
+		})
+	}
+	merged := &m.Analyses[index]
+
+	for _, item := range analysis.Results {
+		if item.Origin == nil {
+			itemOrigin := origin
+			item.Origin = &itemOrigin
+		}
+		key := pair + "\x00" + memoryKey(item.Source)
+		existing, ok := m.sections[key]
+		if !ok {
+			m.sections[key] = len(merged.Results)
+			merged.Results = append(merged.Results, item)
+			continue
+		}
+		kept := merged.Results[existing]
+		if sameTranslations(kept, item) {
+			m.Duplicates++
+			continue
+		}
+		m.Conflicts++
+		replace, err := resolve(kept, item)
+		if err != nil {
+			return err
+		}
+		if replace {
+			merged.Results[existing] = item
+		}
+	}
+	return nil
+}
+
+// sameTranslations reports whether a and b are translated alike, into every
+// language.
+func sameTranslations(a, b ResultItem) bool {
+	if a.Translation != b.Translation || len(a.Translations) != len(b.Translations) {
+		return false
+	}
+	for language, translation := range a.Translations {
+		if b.Translations[language] != translation {
+			return false
+		}
+	}
+	return true
+}
+
+// translationLength returns the length of the translations of item, in
+// characters.
+func translationLength(item ResultItem) int {
+	length := utf8.RuneCountInString(item.Translation)
+	for _, translation := range item.Translations {
+		length += utf8.RuneCountInString(translation)
+	}
+	return length
+}
+
+// askMergeChoice asks whether to keep the translation of kept or the one of
+// found, until 1 or 2 is typed, an empty answer keeping the first one. It
+// reports whether found was chosen.
+func askMergeChoice(out io.Writer, in *bufio.Reader, kept, found ResultItem) (bool, error) {
+	fmt.Fprintf(out, "\n%s\n", singleLine(kept.Source))
+	for n, item := range []ResultItem{kept, found} {
+		fmt.Fprintf(out, "  %d) %s (%s)\n", n+1, singleLine(mergeChoiceText(item)), item.Origin.Results)
+	}
+	for {
+		fmt.Fprint(out, "Keep which translation? [1] ")
+		line, err := in.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if n, convErr := strconv.Atoi(answer); convErr == nil && (n == 1 || n == 2) {
+			return n == 2, nil
+		}
+		if answer == "" && err == nil {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading choice: %w", err)
+		}
+	}
+}
+
+// mergeChoiceText returns the translation of item shown by askMergeChoice,
+// or its translations by language.
+func mergeChoiceText(item ResultItem) string {
+	if len(item.Translations) == 0 {
+		return item.Translation
+	}
+	languages := make([]string, 0, len(item.Translations))
+	for language := range item.Translations {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	parts := make([]string, len(languages))
+	for i, language := range languages {
+		parts[i] = language + ": " + item.Translations[language]
+	}
+	return strings.Join(parts, "; ")
+}
+
+func init() {
+	mergeCmd.Flags().String("strategy", "newest", "How sections translated differently are resolved: newest, longest or interactive")
+	mergeCmd.Flags().StringP("output", "o", "", "Write the merged results to this file instead of stdout")
+	mergeCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	rootCmd.AddCommand(mergeCmd)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultsMergeAdd(t *testing.T) {
+	older := resultOrigin{Results: "a.json", File: "a.txt", ModifiedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	newer := resultOrigin{Results: "b.json", File: "b.txt", ModifiedAt: older.ModifiedAt.Add(time.Hour)}
+	var resolved []string
+	newest := func(kept, found ResultItem) (bool, error) {
+		resolved = append(resolved, kept.Translation+"/"+found.Translation)
+		return found.Origin.ModifiedAt.After(kept.Origin.ModifiedAt), nil
+	}
+
+	merge := &resultsMerge{sections: make(map[string]int), pairs: make(map[string]int)}
+	first := Analysis{SourceLanguage: "de", TranslationLanguage: "en", Results: []ResultItem{
+		{Source: "Hund", Translation: "dog"},
+		{Source: "Katze", Translation: "cat"},
+	}}
+	second := Analysis{SourceLanguage: "DE", TranslationLanguage: "EN", Results: []ResultItem{
+		{Source: "Katze ", Translation: "cat"},
+		{Source: "Hund", Translation: "hound"},
+		{Source: "Maus", Translation: "mouse"},
+	}}
+	// Another language pair is merged apart, even for the same sources.
+	french := Analysis{SourceLanguage: "de", TranslationLanguage: "fr", Results: []ResultItem{{Source: "Hund", Translation: "chien"}}}
+	for _, add := range []struct {
+		analysis Analysis
+		origin   resultOrigin
+	}{{first, older}, {second, newer}, {french, older}} {
+		if err := merge.add(add.analysis, add.origin, newest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if merge.Duplicates != 1 || merge.Conflicts != 1 || strings.Join(resolved, " ") != "dog/hound" {
+		t.Errorf("%d duplicates and %d conflicts, resolved %q", merge.Duplicates, merge.Conflicts, resolved)
+	}
+	if len(merge.Analyses) != 2 {
+		t.Fatalf("merged %d analyses, want one by language pair", len(merge.Analyses))
+	}
+	var got []string
+	for _, analysis := range merge.Analyses {
+		for _, item := range analysis.Results {
+			got = append(got, analysis.TranslationLanguage+":"+item.Source+"="+item.Translation+"@"+item.Origin.Results)
+		}
+	}
+	if want := "en:Hund=hound@b.json en:Katze=cat@a.json en:Maus=mouse@b.json fr:Hund=chien@a.json"; strings.Join(got, " ") != want {
+		t.Errorf("merged %q, want %q", got, want)
+	}
+	if origin := merge.Analyses[0].Results[1].Origin; *origin != older {
+		t.Errorf("origin = %+v, want %+v", origin, older)
+	}
+}
+
+func TestResultsMergeKeepsOrigin(t *testing.T) {
+	// Sections of an earlier merge keep where they were first taken from.
+	earlier := &resultOrigin{Results: "first.json", File: "a.txt"}
+	merge := &resultsMerge{sections: make(map[string]int), pairs: make(map[string]int)}
+	analysis := Analysis{Results: []ResultItem{{Source: "Hund", Translation: "dog", Origin: earlier}, {Source: "Katze"}}}
+	if err := merge.add(analysis, resultOrigin{Results: "merged.json"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	results := merge.Analyses[0].Results
+	if results[0].Origin != earlier || results[1].Origin.Results != "merged.json" {
+		t.Errorf("origins = %+v, %+v", results[0].Origin, results[1].Origin)
+	}
+	if analysis.Results[1].Origin != nil {
+		t.Error("merging set the origin of the analysis added")
+	}
+}
+
+func TestResultsMergeResolveError(t *testing.T) {
+	errAborted := errors.New("aborted")
+	merge := &resultsMerge{sections: make(map[string]int), pairs: make(map[string]int)}
+	resolve := func(kept, found ResultItem) (bool, error) { return false, errAborted }
+	if err := merge.add(Analysis{Results: []ResultItem{{Source: "Hund", Translation: "dog"}}}, resultOrigin{}, resolve); err != nil {
+		t.Fatal(err)
+	}
+	if err := merge.add(Analysis{Results: []ResultItem{{Source: "Hund", Translation: "hound"}}}, resultOrigin{}, resolve); err != errAborted {
+		t.Errorf("add = %v, want the error of resolve", err)
+	}
+}
+
+func TestSameTranslations(t *testing.T) {
+	several := ResultItem{Translations: map[string]string{"en": "dog", "fr": "chien"}}
+	for _, test := range []struct {
+		a, b ResultItem
+		want bool
+	}{
+		{ResultItem{Translation: "dog"}, ResultItem{Translation: "dog"}, true},
+		{ResultItem{Translation: "dog"}, ResultItem{Translation: "hound"}, false},
+		{several, ResultItem{Translations: map[string]string{"fr": "chien", "en": "dog"}}, true},
+		{several, ResultItem{Translations: map[string]string{"en": "dog"}}, false},
+		{several, ResultItem{Translations: map[string]string{"en": "dog", "fr": "toutou"}}, false},
+	} {
+		if got := sameTranslations(test.a, test.b); got != test.want {
+			t.Errorf("sameTranslations(%+v, %+v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestTranslationLength(t *testing.T) {
+	if got := translationLength(ResultItem{Translation: "食べる"}); got != 3 {
+		t.Errorf("translationLength = %d, want 3", got)
+	}
+	if got := translationLength(ResultItem{Translations: map[string]string{"en": "dog", "fr": "chien"}}); got != 8 {
+		t.Errorf("translationLength = %d, want 8", got)
+	}
+}
+
+func TestAskMergeChoice(t *testing.T) {
+	kept := ResultItem{Source: "Hund", Translation: "dog", Origin: &resultOrigin{Results: "a.json"}}
+	found := ResultItem{Source: "Hund", Translation: "hound", Origin: &resultOrigin{Results: "b.json"}}
+	for input, want := range map[string]bool{"2\n": true, "1\n": false, "\n": false, "3\nx\n2\n": true} {
+		var out strings.Builder
+		choice, err := askMergeChoice(&out, bufio.NewReader(strings.NewReader(input)), kept, found)
+		if err != nil || choice != want {
+			t.Errorf("answering %q: askMergeChoice = %v, %v, want %v", input, choice, err, want)
+		}
+		if !strings.Contains(out.String(), "1) dog (a.json)\n  2) hound (b.json)") {
+			t.Errorf("asked %q", out.String())
+		}
+	}
+	if _, err := askMergeChoice(io.Discard, bufio.NewReader(strings.NewReader("3")), kept, found); err == nil {
+		t.Error("asking past the end of the input succeeded")
+	}
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// minedSentence is a sentence of a subtitle file with a single unknown word.
+type minedSentence struct {
+	Sentence string `json:"sentence" yaml:"sentence"`
+	// Word is the unknown word as it appears in the sentence, in lower case,
+	// and Lemma its dictionary form, unless the sentence was mined with
+	// --surface.
+	Word        string `json:"word" yaml:"word"`
+	Lemma       string `json:"lemma,omitempty" yaml:"lemma,omitempty"`
+	Translation string `json:"translation,omitempty" yaml:"translation,omitempty"`
+	File        string `json:"file" yaml:"file"`
+}
+
+// minedSentences is the output of the mine command.
+type minedSentences struct {
+	SourceLanguage string          `json:"source_language,omitempty" yaml:"source_language,omitempty"`
+	Usage          *usageTotals    `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Sentences      []minedSentence `json:"sentences" yaml:"sentences"`
+}
+
+var mineCmd = &cobra.Command{
+	Use:   "mine <subtitles>...",
+	Short: "Find the sentences of subtitle files with a single word you don't know",
+	Long: `The "mine" command reads .srt, .vtt and .ass subtitle files, splits their captions into sentences and keeps the ones with exactly one word missing from --known, a file of the words you know, one per line: sentences you almost understand are the easiest to learn new words from.
+The words are lemmatized by the LLM, so that "went" is known when "go" is; with --surface they are looked up as they are written, without any request. Captions are joined before splitting, so sentences running over several cues are kept whole.
+The sentences are written one per line, as input for "analise", or with --format json or yaml along with their unknown word, or with --format anki as a deck of cards with the sentence on the front, its translation in the --translation-language on the back and the word as notes. Each input file is its own Anki deck, named after the file unless --deck is set.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMine,
+}
+
+func runMine(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "text" && format != "json" && format != "yaml" && format != "anki" {
+		return fmt.Errorf("unsupported format %q (expected text, json, yaml or anki)", format)
+	}
+	knownPath, err := cmd.Flags().GetString("known")
+	if err != nil {
+		return fmt.Errorf("retrieving known flag: %w", err)
+	}
+	if knownPath == "" {
+		return errors.New("--known is required")
+	}
+	surface, err := cmd.Flags().GetBool("surface")
+	if err != nil {
+		return fmt.Errorf("retrieving surface flag: %w", err)
+	}
+	separatorName, err := cmd.Flags().GetString("separator")
+	if err != nil {
+		return fmt.Errorf("retrieving separator flag: %w", err)
+	}
+	separator, err := parseSeparator(separatorName)
+	if err != nil {
+		return err
+	}
+	tags, err := cmd.Flags().GetStringArray("tag")
+	if err != nil {
+		return fmt.Errorf("retrieving tag flag: %w", err)
+	}
+	deckName, err := cmd.Flags().GetString("deck")
+	if err != nil {
+		return fmt.Errorf("retrieving deck flag: %w", err)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	knownWords, err := loadWordList(knownPath)
+	if err != nil {
+		return fmt.Errorf("loading known words: %w", err)
+	}
+	known := make(map[string]bool, len(knownWords))
+	for _, word := range knownWords {
+		known[word] = true
+	}
+
+	// Sentences repeated within or across the files are mined once, from
+	// the first file they appear in.
+	var candidates []minedSentence
+	var texts []string
+	seen := make(map[string]bool)
+	for _, path := range args {
+		file, err := readSubtitles(path)
+		if err != nil {
+			return fmt.Errorf("reading subtitles: %w", err)
+		}
+		captions := make([]string, 0, len(file.Cues))
+		for _, cue := range file.Cues {
+			if caption := cue.plainText(); caption != "" {
+				captions = append(captions, caption)
+			}
+		}
+		text := strings.Join(captions, " ")
+		texts = append(texts, text)
+		for _, sentence := range splitSentences(text) {
+			if sentence = strings.TrimSpace(sentence); sentence != "" && !seen[sentence] {
+				seen[sentence] = true
+				candidates = append(candidates, minedSentence{Sentence: sentence, File: path})
+			}
+		}
+	}
+
+	var words []string
+	for _, candidate := range candidates {
+		words = append(words, textWords(candidate.Sentence)...)
+	}
+	if len(words) == 0 {
+		return errors.New("the subtitles have no words to mine")
+	}
+
+	mined := minedSentences{}
+	if surface && format != "anki" {
+		mined.Sentences = mineSentences(candidates, known, nil)
+	} else {
+		opts, err := analysisOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		defer opts.tracer.Flush()
+		warmUp(ctx, opts)
+
+		usageBefore := opts.usage.Snapshot()
+		lemmas := make(map[string]string)
+		if opts.sourceLanguage == "" {
+			if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(texts, " "), readabilitySampleWords), opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+		mined.SourceLanguage = opts.sourceLanguage
+		if !surface {
+			if lemmas, err = lemmatizeWords(ctx, words, opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+		mined.Sentences = mineSentences(candidates, known, lemmas)
+		if format == "anki" && len(mined.Sentences) > 0 {
+			if mined.Sentences, err = translateMined(ctx, mined.Sentences, opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+		usage := opts.usage.Snapshot().sub(usageBefore)
+		mined.Usage = &usage
+	}
+	if len(mined.Sentences) == 0 {
+		return errors.New("no sentences with a single unknown word")
+	}
+
+	var b bytes.Buffer
+	switch format {
+	case "anki":
+		cards := make([]flashcard, 0, len(mined.Sentences))
+		for _, sentence := range mined.Sentences {
+			deck := deckName
+			if deck == "" {
+				deck = strings.TrimSuffix(filepath.Base(sentence.File), filepath.Ext(sentence.File))
+			}
+			notes := sentence.Word
+			if sentence.Lemma != "" && sentence.Lemma != sentence.Word {
+				notes += " (" + sentence.Lemma + ")"
+			}
+			cards = append(cards, flashcard{Front: sentence.Sentence, Back: sentence.Translation, Notes: notes, Deck: deck})
+		}
+		err = writeAnkiDeck(&b, cards, separator, tags, true, false)
+	case "json":
+		err = writeJSON(&b, mined)
+	case "yaml":
+		err = writeYAML(&b, mined)
+	default:
+		for _, sentence := range mined.Sentences {
+			fmt.Fprintln(&b, sentence.Sentence)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("writing sentences: %w", err)
+	}
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d sentences to %s\n", len(mined.Sentences), outputPath)
+	return nil
+}
+
+// mineSentences keeps the candidates with exactly one distinct word whose
+// form and lemma in lemmas are both missing from known.
+func mineSentences(candidates []minedSentence, known map[string]bool, lemmas map[string]string) []minedSentence {
+	mined := []minedSentence{}
+	for _, candidate := range candidates {
+		unknown := make(map[string]bool)
+		for _, word := range textWords(candidate.Sentence) {
+			if lemma := lemmas[word]; !known[word] && (lemma == "" || !known[lemma]) {
+				unknown[word] = true
+				candidate.Word, candidate.Lemma = word, lemma
+			}
+		}
+		if len(unknown) == 1 {
+			mined = append(mined, candidate)
+		}
+	}
+	return mined
+}
+
+// translateMined translates every mined sentence into the
+// --translation-language.
+func translateMined(ctx context.Context, sentences []minedSentence, opts analysisOptions) ([]minedSentence, error) {
+	opts.progress.Start(len(sentences))
+	defer opts.progress.Finish()
+
+	translate := func(_ int, sentence minedSentence) (minedSentence, error) {
+		opts.progress.Begin(sentence.Sentence)
+		translation, err := translateSection(ctx, sentence.Sentence, opts.sourceLanguage, opts.translationLanguage, opts.glossary, opts)
+		if err != nil {
+			return minedSentence{}, err
+		}
+		sentence.Translation = translation
+		opts.progress.Advance()
+		return sentence, nil
+	}
+	return analyze.RunOrdered(sentences, opts.concurrency, translate, nil)
+}
+
+func init() {
+	addAnalysisFlags(mineCmd.Flags())
+	mineCmd.Flags().String("format", "text", "The output format: text, one sentence per line for analise, json, yaml, or anki for a deck of cards")
+	mineCmd.Flags().String("known", "", "A file of the words you know, one per line (required)")
+	mineCmd.Flags().Bool("surface", false, "Look the words up as they are written instead of by lemma, without any LLM request")
+	mineCmd.Flags().String("separator", "tab", "The field separator of --format anki: tab, comma, semicolon, pipe, colon, space or a single character")
+	mineCmd.Flags().StringArray("tag", nil, "A tag added to every note of --format anki (repeatable)")
+	mineCmd.Flags().String("deck", "", "The name of the deck (default is the name of each input file)")
+	mineCmd.Flags().StringP("output", "o", "", "Write the sentences to this file instead of stdout")
+	mineCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	rootCmd.AddCommand(mineCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const defaultModel = "llama3"
+
+// resolveModel returns the model name to use for a given pipeline stage.
+//
+// The stage-specific flag (e.g. "segment-model") wins, followed by the
+// stage-specific environment variable and configured value, the global
+// --model flag, the STARTER_GO_CLI_MODEL environment variable, the configured
+// model and finally the default model of the provider. Pass empty stage arguments for
+// commands that only use a single model.
+func resolveModel(cmd *cobra.Command, stageFlag, stageEnv, stageConfigured string) (string, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	model, err := resolveSetting(cmd, stageFlag, stageEnv, stageConfigured, "")
+	if err != nil || model != "" {
+		return model, err
+	}
+	provider, err := resolveProvider(cmd)
+	if err != nil {
+		return "", err
+	}
+	model, err = resolveSetting(cmd, "model", "STARTER_GO_CLI_MODEL", cfg.Model, providers[provider].model)
+	if err == nil && model == "" {
+		err = fmt.Errorf("%s has no default model: set --model", providers[provider].title)
+	}
+	return model, err
+}
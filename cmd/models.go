@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// modelsTimeout bounds the request listing the models.
+const modelsTimeout = 30 * time.Second
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the models available on the LLM host",
+	Long: `The "models" command lists the models available on the LLM host of the --provider, with their size and modification date when the host reports them.
+The model used by default, from --model, the environment or the config file, is marked with an asterisk.`,
+	Args: cobra.NoArgs,
+	RunE: runModels,
+}
+
+// modelItem is a model as printed by --format json.
+type modelItem struct {
+	Name     string     `json:"name"`
+	Size     int64      `json:"size,omitempty"`
+	Modified *time.Time `json:"modified,omitempty"`
+	Default  bool       `json:"default,omitempty"`
+}
+
+func runModels(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format %q (expected table or json)", format)
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	llmHost, err := resolveSetting(cmd, "llm-host", "STARTER_GO_CLI_LLM_HOST", cfg.LLMHost, "")
+	if err != nil {
+		return fmt.Errorf("retrieving llm-host flag: %w", err)
+	}
+	provider, err := newProvider(cmd, llmHost)
+	if err != nil {
+		return err
+	}
+	lister, ok := provider.(llm.ModelLister)
+	if !ok {
+		name, _ := resolveProvider(cmd)
+		return fmt.Errorf("%s cannot list its models", providers[name].title)
+	}
+	// Providers without a default model have nothing to mark.
+	defaultName, _ := resolveModel(cmd, "", "", "")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, modelsTimeout)
+	defer cancel()
+
+	models, err := lister.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("listing models: %w", providerError(err))
+	}
+
+	items := make([]modelItem, 0, len(models))
+	for _, model := range models {
+		item := modelItem{Name: model.Name, Size: model.Size, Default: isModel(model.Name, defaultName)}
+		if !model.Modified.IsZero() {
+			modified := model.Modified
+			item.Modified = &modified
+		}
+		items = append(items, item)
+	}
+
+	out := cmd.OutOrStdout()
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(items)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tMODIFIED\tDEFAULT")
+	for _, item := range items {
+		size, modified, mark := "-", "-", ""
+		if item.Size > 0 {
+			size = formatBytes(item.Size)
+		}
+		if item.Modified != nil {
+			modified = item.Modified.Local().Format("2006-01-02 15:04")
+		}
+		if item.Default {
+			mark = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.Name, size, modified, mark)
+	}
+	return tw.Flush()
+}
+
+// isModel reports whether name, as listed by the host, is the configured
+// model. Ollama lists the untagged models with their implicit "latest" tag.
+func isModel(name, configured string) bool {
+	return configured != "" && (name == configured || name == configured+":latest" || strings.TrimSuffix(configured, ":latest") == name)
+}
+
+func init() {
+	modelsCmd.Flags().StringP("llm-host", "l", "", "The URL of the LLM service (default depends on --provider)")
+	modelsCmd.Flags().String("format", "table", "The output format: table or json")
+
+	rootCmd.AddCommand(modelsCmd)
+}
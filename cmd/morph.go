@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// morphAnalysis is the output of the morph command.
+type morphAnalysis struct {
+	SourceLanguage string          `json:"source_language" yaml:"source_language"`
+	Usage          *usageTotals    `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Sentences      []morphSentence `json:"sentences" yaml:"sentences"`
+}
+
+// morphSentence is a sentence with the morphology of each of its words.
+type morphSentence struct {
+	Text  string      `json:"text" yaml:"text"`
+	Words []morphWord `json:"words" yaml:"words"`
+}
+
+// morphWord is a word with its lemma, its universal part-of-speech tag and
+// its Universal Dependencies features, such as Case=Dat or Tense=Past.
+type morphWord struct {
+	Word     string            `json:"word" yaml:"word"`
+	Lemma    string            `json:"lemma" yaml:"lemma"`
+	POS      string            `json:"pos" yaml:"pos"`
+	Features map[string]string `json:"features" yaml:"features"`
+}
+
+var morphCmd = &cobra.Command{
+	Use:   "morph [text]",
+	Short: "Print the lemma and morphological features of every word",
+	Long: `The "morph" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), divides it into sentences and asks the LLM for the lemma, the universal part-of-speech tag and the morphological features (tense, person, case, number and so on, as Universal Dependencies names and values) of every word.
+The backends able to constrain their output to a JSON schema (Ollama, OpenAI, llama.cpp and Gemini) are given the one of the response. The JSON output is meant for tooling, such as frequency lists over lemmas instead of surface forms; the table and CSV formats write the features as Case=Dat|Number=Sing.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runMorph,
+}
+
+func runMorph(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if err := checkDocumentFormat(format); err != nil {
+		return err
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	analysis, err := analyzeMorphology(ctx, text, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	header := []string{"sentence", "word", "lemma", "pos", "features"}
+	var rows [][]string
+	for i, sentence := range analysis.Sentences {
+		for _, word := range sentence.Words {
+			rows = append(rows, []string{strconv.Itoa(i + 1), word.Word, word.Lemma, word.POS, formatFeatures(word.Features)})
+		}
+	}
+	if err := writeDocument(cmd.OutOrStdout(), format, analysis, header, rows); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// analyzeMorphology asks the LLM for the morphology of every word of every
+// sentence of text.
+func analyzeMorphology(ctx context.Context, text string, opts analysisOptions) (morphAnalysis, error) {
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		sourceLanguage, err := detectLanguage(ctx, text, opts)
+		if err != nil {
+			return morphAnalysis{}, err
+		}
+		opts.sourceLanguage = sourceLanguage
+	}
+
+	sentences := splitSentences(text)
+	opts.progress.Start(len(sentences))
+	defer opts.progress.Finish()
+
+	analyzeSentence := func(_ int, sentence string) (morphSentence, error) {
+		opts.progress.Begin(sentence)
+		req, err := renderPrompt(defaultMorphPrompt, opts.translateModel, promptData{Text: sentence, SourceLanguage: opts.sourceLanguage})
+		if err != nil {
+			return morphSentence{}, err
+		}
+		analyzed := morphSentence{Words: []morphWord{}}
+		if err := generateJSON(ctx, opts, req, &analyzed); err != nil {
+			return morphSentence{}, fmt.Errorf("analyzing the morphology of %q: %w", sentence, err)
+		}
+		analyzed.Text = sentence
+		for i, word := range analyzed.Words {
+			analyzed.Words[i].POS = strings.ToUpper(strings.TrimSpace(word.POS))
+			if word.Features == nil {
+				analyzed.Words[i].Features = map[string]string{}
+			}
+		}
+		opts.progress.Advance()
+		return analyzed, nil
+	}
+	analyzed, err := analyze.RunOrdered(sentences, opts.concurrency, analyzeSentence, nil)
+	if err != nil {
+		return morphAnalysis{}, err
+	}
+
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	return morphAnalysis{
+		SourceLanguage: opts.sourceLanguage,
+		Usage:          &usage,
+		Sentences:      analyzed,
+	}, nil
+}
+
+// formatFeatures writes features as in the FEATS column of CoNLL-U, sorted
+// by name, such as "Case=Dat|Number=Sing".
+func formatFeatures(features map[string]string) string {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + features[name]
+	}
+	return strings.Join(pairs, "|")
+}
+
+func init() {
+	addAnalysisFlags(morphCmd.Flags())
+	morphCmd.Flags().String("format", "json", "The output format: json, yaml, table or csv")
+
+	rootCmd.AddCommand(morphCmd)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// entityTypes are the kinds of named entities recognized by ner.
+var entityTypes = []string{"person", "place", "organization", "date"}
+
+// namedEntity is a mention of a named entity in a text. Start and End are its
+// offsets in the text in characters, End being exclusive.
+type namedEntity struct {
+	Text  string `json:"text" yaml:"text"`
+	Type  string `json:"type" yaml:"type"`
+	Start int    `json:"start" yaml:"start"`
+	End   int    `json:"end" yaml:"end"`
+}
+
+// entityList is the output of the ner command.
+type entityList struct {
+	SourceLanguage string        `json:"source_language" yaml:"source_language"`
+	Usage          *usageTotals  `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Entities       []namedEntity `json:"entities" yaml:"entities"`
+}
+
+var nerCmd = &cobra.Command{
+	Use:   "ner [text]",
+	Short: "List the people, places, organizations and dates of a text",
+	Long: `The "ner" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted) and asks the LLM for the named entities it mentions: people, places, organizations and dates.
+Every mention is reported with its type and its offsets in the text, in characters from 0 with the end excluded, which are found in the text itself rather than trusted from the LLM; mentions that are not in the text are left out.
+Use "analise --keep-entities" to keep the names of the entities untranslated.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNER,
+}
+
+func runNER(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if err := checkDocumentFormat(format); err != nil {
+		return err
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		if opts.sourceLanguage, err = detectLanguage(ctx, text, opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+	}
+	entities, err := recognizeEntities(ctx, text, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	list := entityList{SourceLanguage: opts.sourceLanguage, Usage: &usage, Entities: entities}
+
+	header := []string{"text", "type", "start", "end"}
+	rows := make([][]string, 0, len(entities))
+	for _, entity := range entities {
+		rows = append(rows, []string{entity.Text, entity.Type, strconv.Itoa(entity.Start), strconv.Itoa(entity.End)})
+	}
+	if err := writeDocument(cmd.OutOrStdout(), format, list, header, rows); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// recognizeEntities asks the LLM for the named entities of text, in order,
+// and locates each mention in text.
+func recognizeEntities(ctx context.Context, text string, opts analysisOptions) ([]namedEntity, error) {
+	req, err := renderPrompt(defaultNERPrompt, opts.segmentModel, promptData{Text: text, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		Entities []namedEntity `json:"entities"`
+	}
+	if err := generateJSON(ctx, opts, req, &answer); err != nil {
+		return nil, fmt.Errorf("recognizing the named entities: %w", err)
+	}
+
+	// Every mention is looked for after the previous one, so that repeated
+	// names get the offsets of each of their mentions, and from the start
+	// of the text when the LLM listed it out of order.
+	entities := []namedEntity{}
+	from := 0
+	for _, entity := range answer.Entities {
+		entity.Text = strings.TrimSpace(entity.Text)
+		entity.Type = strings.ToLower(strings.TrimSpace(entity.Type))
+		if entity.Text == "" {
+			continue
+		}
+		start := strings.Index(text[from:], entity.Text)
+		if start >= 0 {
+			start += from
+		} else if start = strings.Index(text, entity.Text); start < 0 {
+			continue
+		}
+		from = start + len(entity.Text)
+		entity.Start = utf8.RuneCountInString(text[:start])
+		entity.End = entity.Start + utf8.RuneCountInString(entity.Text)
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+// checkEntityTypes reports an error when one of types is not one of
+// entityTypes.
+func checkEntityTypes(types []string) error {
+	for _, name := range types {
+		known := false
+		for _, entityType := range entityTypes {
+			known = known || name == entityType
+		}
+		if !known {
+			return fmt.Errorf("unsupported entity type %q (expected %s)", name, strings.Join(entityTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// entityGlossary returns the glossary keeping the entities of text with one
+// of the --keep-entities types as they are, along with the --glossary
+// terms, which win over the entities they overlap with.
+func entityGlossary(ctx context.Context, text string, opts analysisOptions) ([]glossaryTerm, error) {
+	entities, err := recognizeEntities(ctx, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	glossary := append([]glossaryTerm{}, opts.glossary...)
+	seen := make(map[string]bool, len(glossary))
+	for _, term := range glossary {
+		seen[strings.ToLower(term.Source)] = true
+	}
+	for _, entity := range entities {
+		kept := false
+		for _, entityType := range opts.keepEntities {
+			kept = kept || entity.Type == entityType
+		}
+		if key := strings.ToLower(entity.Text); kept && !seen[key] {
+			seen[key] = true
+			glossary = append(glossary, glossaryTerm{Source: entity.Text, Target: entity.Text})
+		}
+	}
+	return glossary, nil
+}
+
+func init() {
+	addAnalysisFlags(nerCmd.Flags())
+	nerCmd.Flags().String("format", "json", "The output format: json, yaml, table or csv")
+
+	rootCmd.AddCommand(nerCmd)
+}
@@ -0,0 +1,638 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// analysisOptions carries the settings shared by every request made while
+// analyzing a text.
+type analysisOptions struct {
+	provider     llm.Provider
+	providerName string
+	// llmHost is the host of the --provider, empty for its default one.
+	llmHost string
+	// translator is provider unless --translator selects a dedicated service,
+	// in which case translatorKey identifies it in the cache.
+	translator          llm.Translator
+	translatorKey       string
+	translationLanguage string
+	// translationLanguages holds every requested language; the first one is
+	// also available as translationLanguage.
+	translationLanguages []string
+	segmentModel         string
+	translateModel       string
+	concurrency          int
+	limiter              *rateLimiter
+	breaker              *circuitBreaker
+	retry                retryPolicy
+	requestTimeout       time.Duration
+	totalTimeout         time.Duration
+	progress             *progressBar
+	stream               bool
+	autoPull             bool
+	// keepAlive is the --keep-alive of the Ollama model, which is warmed up
+	// before the analysis when it is set.
+	keepAlive           string
+	segmentPrompt       promptTemplate
+	translatePrompt     promptTemplate
+	combinedPrompt      promptTemplate
+	combined            bool
+	granularity         string
+	minSectionWords     int
+	maxSectionWords     int
+	generationOptions   map[string]interface{}
+	sourceLanguage      string
+	verify              bool
+	verifyThreshold     float64
+	glossary            []glossaryTerm
+	glossaryRetranslate bool
+	// memory is the --memory, nil without one.
+	memory *translationMemory
+	cache  *responseCache
+	usage  *usageMeter
+	tracer *tracer
+	// transliterate adds the transliteration of every section, written
+	// with scheme, or the default scheme of the language when it is empty.
+	transliterate bool
+	scheme        string
+	// furigana is the style of the readings added to Japanese text, empty
+	// when they are not added.
+	furigana string
+	// pinyin is the style of the tones of the pinyin added to Chinese text,
+	// empty when it is not added.
+	pinyin string
+	// ipa adds the IPA transcription of every section.
+	ipa bool
+	// difficulty rates every section with a CEFR level, and minLevel is the
+	// position in cefrLevels of the easiest level kept.
+	difficulty bool
+	minLevel   int
+	// synonyms adds the synonyms and antonyms of the key words of every
+	// section.
+	synonyms bool
+	// explain adds the explanation of the grammar of every section.
+	explain bool
+	// pos is the style of the part-of-speech tags added to every section,
+	// empty when they are not added.
+	pos string
+	// keepEntities are the types of the named entities kept untranslated,
+	// as glossary terms translating to themselves.
+	keepEntities []string
+	// detector detects the language of the texts without a
+	// --source-language: llm, local or auto.
+	detector string
+	// speech reads every section aloud with --tts, nil when they are not.
+	speech *speechOutput
+	// resume holds the sections of the --resume output, nil without one.
+	resume *resumedResults
+}
+
+// addAnalysisFlags registers the flags read by analysisOptionsFromFlags.
+func addAnalysisFlags(flags *pflag.FlagSet) {
+	flags.StringP("llm-host", "l", "", "The URL of the LLM service: the generate endpoint for Ollama, the server root for llama.cpp, the API root for OpenAI, OpenAI-compatible servers and Gemini, the resource endpoint for Azure OpenAI (default depends on --provider)")
+	flags.StringArrayP("translation-language", "t", nil, "The language for translation in locale format, repeatable to translate into several languages at once (default is 'en-US')")
+	flags.StringP("source-language", "s", "", "The language of the text as a BCP 47 tag (detected automatically when not set)")
+	flags.String("detector", "", "How the language of the text is detected without --source-language: llm, local, without any request, or auto, asking the LLM only when the local detector is unsure (default is 'llm')")
+	flags.String("segment-model", "", "The model used to divide the text into sections (defaults to --model)")
+	flags.String("translate-model", "", "The model used to translate each section (defaults to --model)")
+	flags.IntP("concurrency", "c", 1, "The number of sections translated in parallel")
+	flags.Float64("rps", 0, "The maximum number of LLM requests per second, across all of the --concurrency workers (0 disables it)")
+	flags.Int("burst", 1, "The number of requests that may be sent at once before --rps applies")
+	flags.Int("breaker-threshold", 5, "The number of requests failing in a row after which all requests are paused (0 disables it)")
+	flags.Duration("breaker-cooldown", 30*time.Second, "How long requests are paused once --breaker-threshold is reached")
+	flags.Int("retries", 3, "The number of times a failed LLM request is retried")
+	flags.Duration("retry-delay", time.Second, "The initial delay between retries, doubled after every attempt")
+	flags.Bool("respect-rate-limits", true, "Wait for as long as the provider asks in its Retry-After and rate limit headers before retrying, without using up the retries")
+	flags.Duration("timeout", 2*time.Minute, "The maximum duration of a single LLM request (0 disables it)")
+	flags.Duration("total-timeout", 0, "The maximum duration of the whole run (0 disables it)")
+	flags.Bool("no-progress", false, "Don't show translation progress on stderr (it is also hidden when stderr is not a terminal)")
+	flags.String("segment-prompt-file", "", "A Go text/template file replacing the segmentation prompt ({{.Text}} and {{.Language}} are available)")
+	flags.String("translate-prompt-file", "", "A Go text/template file replacing the translation prompt ({{.Text}} is the section, {{.Language}} the target language)")
+	flags.StringArrayP("option", "O", nil, "An Ollama generation option as key=value, e.g. temperature=0.2, top_p=0.9, seed=42, num_ctx=4096 or num_predict=256 (repeatable)")
+	flags.Bool("verify", false, "Translate every section back into the source language and score how closely it matches the original")
+	flags.Float64("verify-threshold", 0.5, "The similarity score (0-1) below which a verified section is flagged as divergent")
+	flags.String("glossary", "", "A CSV file of source,target term pairs the translations must use")
+	flags.Bool("glossary-retranslate", false, "Translate sections that don't respect the --glossary once more instead of only flagging them")
+	flags.StringArray("memory", nil, "A translation memory, as a TMX file or the JSON results of an earlier run, whose translations are reused instead of asking the LLM (repeatable)")
+	flags.Float64("memory-threshold", 0.95, "The similarity score (0-1) from which a --memory source is a fuzzy match of a section (1 only allows exact matches)")
+	flags.Bool("transliterate", false, "Add the transliteration of every section into Latin script, such as romaji for Japanese or pinyin for Chinese")
+	flags.String("scheme", "", "The transliteration scheme, such as hepburn, pinyin or iso9 (default depends on the source language)")
+	flags.String("furigana", "", "Add the readings of the kanji of Japanese sections and words, in bracket notation (the default when given without a value) or as HTML ruby: bracket or ruby")
+	flags.Lookup("furigana").NoOptDefVal = "bracket"
+	flags.Bool("difficulty", false, "Rate every section and word with the CEFR level (A1 to C2) a learner needs to understand it")
+	flags.String("min-level", "", "Leave out the sections and words easier than this CEFR level, such as B1 (implies --difficulty)")
+	flags.Bool("explain", false, "Add an explanation of the grammar of every section, such as its cases, word order and conjugations, written in the translation language")
+	flags.Bool("synonyms", false, "Add the common synonyms and antonyms, in the source language, of the key words of every section and of every word")
+	flags.Bool("ipa", false, "Add the IPA transcription of every section and word, for pronunciation practice")
+	flags.String("pinyin", "", "Add the pinyin of Chinese sections and words, with tone marks (the default when given without a value) or tone numbers: marks or numbers")
+	flags.Lookup("pinyin").NoOptDefVal = "marks"
+	flags.String("pos", "", "Add the part-of-speech tag of every word of every section, inline as word/TAG (the default when given without a value) or as an array of tokens: inline or array")
+	flags.Lookup("pos").NoOptDefVal = "inline"
+	flags.StringSlice("keep-entities", nil, "Keep the named entities of these types untranslated, as if they were --glossary terms: person, place, organization or date (person, place and organization when given without a value)")
+	flags.Lookup("keep-entities").NoOptDefVal = "person,place,organization"
+	flags.String("granularity", "", "The size of the sections: phrase, clause, sentence or paragraph")
+	flags.Int("min-section-words", 0, "Merge sections with fewer words into their neighbours (0 disables it)")
+	flags.Int("max-section-words", 0, "Split sections with more words again (0 disables it)")
+	flags.String("translator", "", "The translation engine: llm, using --provider, deepl or google (default is 'llm')")
+	flags.String("translator-host", "", "The API root of the --translator service (default depends on the service)")
+	flags.String("google-project", "", "The Google Cloud project used by --translator google (default is the project of the credentials)")
+	flags.String("google-location", "", "The Google Cloud location used by --translator google (default is 'global')")
+	flags.String("google-glossary", "", "The ID or resource name of a Cloud Translation glossary for --translator google")
+	flags.String("formality", "", "The DeepL formality: default, more, less, prefer_more or prefer_less")
+	flags.Bool("combined", false, "Segment and translate the text with a single request, which is much faster for short texts")
+	flags.String("combined-prompt-file", "", "A Go text/template file replacing the --combined prompt ({{.Text}}, {{.Language}} and {{.Glossary}} are available)")
+	flags.Bool("no-cache", false, "Don't read or write the local response cache")
+	flags.Duration("cache-ttl", 7*24*time.Hour, "How long cached responses are reused (0 keeps them forever)")
+	flags.Bool("stream", false, "Stream responses from the LLM, showing tokens on stderr as they arrive")
+	flags.StringArray("failover", nil, "A provider taking over when the previous one is unreachable or keeps failing, as PROVIDER or PROVIDER=HOST (repeatable, replaces the failover list of the config file)")
+	flags.String("otel-endpoint", "", "The OTLP/HTTP collector receiving the trace of the LLM calls once the run is done, e.g. http://localhost:4318")
+	flags.Float64("max-cost", 0, "Abort before the estimated cost of the requests to paid providers exceeds this many US dollars (0 disables it)")
+	flags.Bool("auto-pull", false, "Pull models missing from the Ollama host without asking first")
+}
+
+// analysisOptionsFromFlags builds the analysis options from the flags
+// registered by addAnalysisFlags, falling back to environment variables and
+// defaults.
+func analysisOptionsFromFlags(cmd *cobra.Command) (analysisOptions, error) {
+	var opts analysisOptions
+	flags := cmd.Flags()
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return opts, err
+	}
+
+	llmHost, err := resolveSetting(cmd, "llm-host", "STARTER_GO_CLI_LLM_HOST", cfg.LLMHost, "")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving llm-host flag: %w", err)
+	}
+	provider, err := newProvider(cmd, llmHost)
+	if err != nil {
+		return opts, err
+	}
+	provider, err = newFailoverProvider(cmd, provider, llmHost)
+	if err != nil {
+		return opts, err
+	}
+	providerName, err := resolveProvider(cmd)
+	if err != nil {
+		return opts, err
+	}
+
+	translationLanguages, err := resolveTranslationLanguages(cmd, cfg)
+	if err != nil {
+		return opts, fmt.Errorf("retrieving language flag: %w", err)
+	}
+	if len(translationLanguages) == 0 {
+		translationLanguages = []string{"en-US"}
+		fmt.Fprintln(os.Stderr, "Using default translation language:", translationLanguages[0])
+	}
+
+	sourceLanguage, err := resolveSetting(cmd, "source-language", "STARTER_GO_CLI_SOURCE_LANGUAGE", cfg.SourceLanguage, "")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving source-language flag: %w", err)
+	}
+	detector, err := resolveSetting(cmd, "detector", "STARTER_GO_CLI_DETECTOR", cfg.Detector, "llm")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving detector flag: %w", err)
+	}
+	if err := checkDetector(detector); err != nil {
+		return opts, err
+	}
+
+	segmentModel, err := resolveModel(cmd, "segment-model", "STARTER_GO_CLI_SEGMENT_MODEL", cfg.SegmentModel)
+	if err != nil {
+		return opts, fmt.Errorf("retrieving segment-model flag: %w", err)
+	}
+
+	translateModel, err := resolveModel(cmd, "translate-model", "STARTER_GO_CLI_TRANSLATE_MODEL", cfg.TranslateModel)
+	if err != nil {
+		return opts, fmt.Errorf("retrieving translate-model flag: %w", err)
+	}
+
+	optionPairs, err := flags.GetStringArray("option")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving option flag: %w", err)
+	}
+	generationOptions, err := parseGenerationOptions(cfg.Options, optionPairs)
+	if err != nil {
+		return opts, err
+	}
+
+	concurrency, err := flags.GetInt("concurrency")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving concurrency flag: %w", err)
+	}
+	if concurrency < 1 {
+		return opts, errors.New("--concurrency must be at least 1")
+	}
+
+	rps, err := flags.GetFloat64("rps")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving rps flag: %w", err)
+	}
+	burst, err := flags.GetInt("burst")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving burst flag: %w", err)
+	}
+	if rps < 0 || burst < 1 {
+		return opts, errors.New("--rps cannot be negative and --burst must be at least 1")
+	}
+
+	breakerThreshold, err := flags.GetInt("breaker-threshold")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving breaker-threshold flag: %w", err)
+	}
+	breakerCooldown, err := flags.GetDuration("breaker-cooldown")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving breaker-cooldown flag: %w", err)
+	}
+	breaker := newCircuitBreaker(breakerThreshold, breakerCooldown)
+	if chain, ok := provider.(*failoverProvider); ok && breaker != nil {
+		breaker.failover = chain.failOver
+	}
+
+	retries, err := flags.GetInt("retries")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving retries flag: %w", err)
+	}
+	if retries < 0 {
+		return opts, errors.New("--retries cannot be negative")
+	}
+
+	retryDelay, err := flags.GetDuration("retry-delay")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving retry-delay flag: %w", err)
+	}
+
+	respectRateLimits, err := flags.GetBool("respect-rate-limits")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving respect-rate-limits flag: %w", err)
+	}
+
+	requestTimeout, err := flags.GetDuration("timeout")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving timeout flag: %w", err)
+	}
+
+	totalTimeout, err := flags.GetDuration("total-timeout")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving total-timeout flag: %w", err)
+	}
+
+	noProgress, err := flags.GetBool("no-progress")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving no-progress flag: %w", err)
+	}
+
+	stream, err := flags.GetBool("stream")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving stream flag: %w", err)
+	}
+	if stream && concurrency > 1 {
+		return opts, errors.New("--stream cannot be combined with --concurrency greater than 1")
+	}
+
+	otelEndpoint, err := resolveSetting(cmd, "otel-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", cfg.OTelEndpoint, "")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving otel-endpoint flag: %w", err)
+	}
+
+	maxCost, err := flags.GetFloat64("max-cost")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving max-cost flag: %w", err)
+	}
+	if maxCost < 0 {
+		return opts, errors.New("--max-cost cannot be negative")
+	}
+
+	autoPull, err := flags.GetBool("auto-pull")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving auto-pull flag: %w", err)
+	}
+
+	keepAlive, err := resolveKeepAlive(cmd, cfg)
+	if err != nil {
+		return opts, err
+	}
+
+	// Streamed tokens and logs already show progress and would garble the bar.
+	if stream || currentLogLevel >= logVerbose {
+		noProgress = true
+	}
+
+	segmentPromptFile, err := flags.GetString("segment-prompt-file")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving segment-prompt-file flag: %w", err)
+	}
+	segmentPrompt, err := loadPromptTemplate(segmentPromptFile, analyze.SegmentPrompt)
+	if err != nil {
+		return opts, fmt.Errorf("loading segmentation prompt: %w", err)
+	}
+
+	translatePromptFile, err := flags.GetString("translate-prompt-file")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving translate-prompt-file flag: %w", err)
+	}
+	translatePrompt, err := loadPromptTemplate(translatePromptFile, analyze.TranslatePrompt)
+	if err != nil {
+		return opts, fmt.Errorf("loading translation prompt: %w", err)
+	}
+
+	granularity, err := flags.GetString("granularity")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving granularity flag: %w", err)
+	}
+	if _, ok := analyze.Granularities[granularity]; granularity != "" && !ok {
+		return opts, fmt.Errorf("unsupported granularity %q (expected phrase, clause, sentence or paragraph)", granularity)
+	}
+
+	minSectionWords, err := flags.GetInt("min-section-words")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving min-section-words flag: %w", err)
+	}
+
+	maxSectionWords, err := flags.GetInt("max-section-words")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving max-section-words flag: %w", err)
+	}
+	if minSectionWords < 0 || maxSectionWords < 0 || (maxSectionWords > 0 && minSectionWords > maxSectionWords) {
+		return opts, errors.New("--min-section-words and --max-section-words must be positive, with the minimum not above the maximum")
+	}
+
+	combined, err := flags.GetBool("combined")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving combined flag: %w", err)
+	}
+	if combined && len(translationLanguages) > 1 {
+		return opts, errors.New("--combined requires a single --translation-language")
+	}
+
+	translator, translatorKey, err := newTranslator(cmd, provider)
+	if err != nil {
+		return opts, err
+	}
+	if combined && translatorKey != "" {
+		return opts, errors.New("--combined translates with the LLM and cannot be used with --translator")
+	}
+
+	combinedPromptFile, err := flags.GetString("combined-prompt-file")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving combined-prompt-file flag: %w", err)
+	}
+	combinedPrompt, err := loadPromptTemplate(combinedPromptFile, defaultCombinedPrompt)
+	if err != nil {
+		return opts, fmt.Errorf("loading combined prompt: %w", err)
+	}
+
+	noCache, err := flags.GetBool("no-cache")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving no-cache flag: %w", err)
+	}
+
+	cacheTTL, err := flags.GetDuration("cache-ttl")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving cache-ttl flag: %w", err)
+	}
+
+	cacheDir, err := resolveSetting(cmd, "", "STARTER_GO_CLI_CACHE_DIR", cfg.CacheDir, defaultCacheDir())
+	if err != nil {
+		return opts, err
+	}
+
+	cache, err := newResponseCache(noCache, cacheDir, cacheTTL)
+	if err != nil {
+		return opts, err
+	}
+
+	verify, err := flags.GetBool("verify")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving verify flag: %w", err)
+	}
+
+	verifyThreshold, err := flags.GetFloat64("verify-threshold")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving verify-threshold flag: %w", err)
+	}
+	if verifyThreshold < 0 || verifyThreshold > 1 {
+		return opts, errors.New("--verify-threshold must be between 0 and 1")
+	}
+
+	glossaryPath, err := resolveSetting(cmd, "glossary", "STARTER_GO_CLI_GLOSSARY", cfg.Glossary, "")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving glossary flag: %w", err)
+	}
+	var glossary []glossaryTerm
+	if glossaryPath != "" {
+		glossary, err = loadGlossary(glossaryPath)
+		if err != nil {
+			return opts, fmt.Errorf("loading glossary: %w", err)
+		}
+	}
+
+	glossaryRetranslate, err := flags.GetBool("glossary-retranslate")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving glossary-retranslate flag: %w", err)
+	}
+
+	memoryPaths, err := flags.GetStringArray("memory")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving memory flag: %w", err)
+	}
+	memoryThreshold, err := flags.GetFloat64("memory-threshold")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving memory-threshold flag: %w", err)
+	}
+	if memoryThreshold < 0 || memoryThreshold > 1 {
+		return opts, errors.New("--memory-threshold must be between 0 and 1")
+	}
+	var memory *translationMemory
+	if len(memoryPaths) > 0 {
+		if combined {
+			return opts, errors.New("--memory cannot be combined with --combined, which translates the sections along with their segmentation")
+		}
+		memory, err = loadTranslationMemory(memoryPaths, memoryThreshold)
+		if err != nil {
+			return opts, fmt.Errorf("loading translation memory: %w", err)
+		}
+	}
+
+	transliterate, err := flags.GetBool("transliterate")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving transliterate flag: %w", err)
+	}
+	scheme, err := flags.GetString("scheme")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving scheme flag: %w", err)
+	}
+	if err := checkTransliterationScheme(scheme); err != nil {
+		return opts, err
+	}
+	furigana, err := flags.GetString("furigana")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving furigana flag: %w", err)
+	}
+	if err := checkFuriganaStyle(furigana); err != nil {
+		return opts, err
+	}
+	difficulty, err := flags.GetBool("difficulty")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving difficulty flag: %w", err)
+	}
+	minLevelName, err := flags.GetString("min-level")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving min-level flag: %w", err)
+	}
+	var minLevel int
+	if minLevelName != "" {
+		if minLevel, err = parseCEFRLevel(minLevelName); err != nil {
+			return opts, err
+		}
+		difficulty = true
+	}
+	explain, err := flags.GetBool("explain")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving explain flag: %w", err)
+	}
+	synonyms, err := flags.GetBool("synonyms")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving synonyms flag: %w", err)
+	}
+	ipa, err := flags.GetBool("ipa")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving ipa flag: %w", err)
+	}
+	pinyin, err := flags.GetString("pinyin")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving pinyin flag: %w", err)
+	}
+	if err := checkPinyinStyle(pinyin); err != nil {
+		return opts, err
+	}
+	pos, err := flags.GetString("pos")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving pos flag: %w", err)
+	}
+	if err := checkPOSStyle(pos); err != nil {
+		return opts, err
+	}
+	keepEntities, err := flags.GetStringSlice("keep-entities")
+	if err != nil {
+		return opts, fmt.Errorf("retrieving keep-entities flag: %w", err)
+	}
+	if err := checkEntityTypes(keepEntities); err != nil {
+		return opts, err
+	}
+
+	if len(translationLanguages) > 1 && (verify || len(glossary) > 0 || len(keepEntities) > 0) {
+		return opts, errors.New("--verify, --glossary and --keep-entities require a single --translation-language")
+	}
+
+	return analysisOptions{
+		provider:             provider,
+		providerName:         providerName,
+		llmHost:              llmHost,
+		translator:           translator,
+		translatorKey:        translatorKey,
+		translationLanguage:  translationLanguages[0],
+		translationLanguages: translationLanguages,
+		segmentModel:         segmentModel,
+		translateModel:       translateModel,
+		concurrency:          concurrency,
+		limiter:              newRateLimiter(rps, burst),
+		breaker:              breaker,
+		retry:                retryPolicy{retries: retries, delay: retryDelay, respectRateLimits: respectRateLimits},
+		requestTimeout:       requestTimeout,
+		totalTimeout:         totalTimeout,
+		progress:             newProgressBar(noProgress),
+		stream:               stream,
+		autoPull:             autoPull,
+		keepAlive:            keepAlive,
+		segmentPrompt:        segmentPrompt,
+		translatePrompt:      translatePrompt,
+		combinedPrompt:       combinedPrompt,
+		combined:             combined,
+		granularity:          granularity,
+		minSectionWords:      minSectionWords,
+		maxSectionWords:      maxSectionWords,
+		generationOptions:    generationOptions,
+		sourceLanguage:       sourceLanguage,
+		verify:               verify,
+		verifyThreshold:      verifyThreshold,
+		glossary:             glossary,
+		glossaryRetranslate:  glossaryRetranslate,
+		memory:               memory,
+		transliterate:        transliterate,
+		scheme:               scheme,
+		furigana:             furigana,
+		pinyin:               pinyin,
+		ipa:                  ipa,
+		difficulty:           difficulty,
+		minLevel:             minLevel,
+		synonyms:             synonyms,
+		explain:              explain,
+		pos:                  pos,
+		keepEntities:         keepEntities,
+		detector:             detector,
+		cache:                cache,
+		usage:                newUsageMeter(maxCost, cfg.Prices),
+		tracer:               newTracer(cmd.Name(), otelEndpoint),
+	}, nil
+}
+
+// resolveTranslationLanguages returns the languages given with the repeatable
+// --translation-language flag, or else the comma-separated
+// STARTER_GO_CLI_TRANSLATION_LANGUAGE variable, or else the configured ones.
+func resolveTranslationLanguages(cmd *cobra.Command, cfg *config) ([]string, error) {
+	languages, err := cmd.Flags().GetStringArray("translation-language")
+	if err != nil {
+		return nil, err
+	}
+	if len(languages) == 0 {
+		if env := os.Getenv("STARTER_GO_CLI_TRANSLATION_LANGUAGE"); env != "" {
+			languages = strings.Split(env, ",")
+		}
+	}
+	if len(languages) == 0 {
+		languages = cfg.TranslationLanguages
+		if len(languages) == 0 && cfg.TranslationLanguage != "" {
+			languages = []string{cfg.TranslationLanguage}
+		}
+	}
+
+	seen := make(map[string]bool, len(languages))
+	var unique []string
+	for _, language := range languages {
+		language = strings.TrimSpace(language)
+		if language != "" && !seen[language] {
+			seen[language] = true
+			unique = append(unique, language)
+		}
+	}
+	return unique, nil
+}
+
+// runContext returns the context of a whole run, cancelled on Ctrl+C or once
+// the --total-timeout is exceeded.
+func runContext(opts analysisOptions) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	if opts.totalTimeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.totalTimeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
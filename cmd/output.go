@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkOutputPath fails early when path already exists and overwriting was
+// not requested, so no LLM requests are wasted on a result that can't be saved.
+func checkOutputPath(path string, force bool) error {
+	if force {
+		return nil
+	}
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("%s already exists (use --force to overwrite it)", path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// atomicFile is written through a temporary file next to its destination and
+// only renamed into place on Commit, so readers never observe a partially
+// written file.
+type atomicFile struct {
+	*os.File
+	path  string
+	force bool
+}
+
+func createAtomicFile(path string, force bool) (*atomicFile, error) {
+	if err := checkOutputPath(path, force); err != nil {
+		return nil, err
+	}
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: tmp, path: path, force: force}, nil
+}
+
+// Commit flushes the temporary file and moves it to its destination.
+func (f *atomicFile) Commit() error {
+	defer os.Remove(f.Name())
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	if err := checkOutputPath(f.path, f.force); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), f.path)
+}
+
+// Abort discards the temporary file, leaving the destination untouched.
+func (f *atomicFile) Abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// writeFileAtomic writes data to path through an atomicFile.
+func writeFileAtomic(path string, data []byte, force bool) error {
+	f, err := createAtomicFile(path, force)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
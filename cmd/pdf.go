@@ -0,0 +1,990 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// pdfParagraph is a paragraph of the text of a PDF, numbered from 1 within
+// its page.
+type pdfParagraph struct {
+	Page      int
+	Paragraph int
+	Text      string
+}
+
+// pdfObjectHeader matches the start of the indirect objects of a PDF.
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// The values of PDF objects besides numbers (float64), strings (string),
+// booleans, null (nil) and arrays ([]interface{}).
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfDict    map[string]interface{}
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte
+	}
+)
+
+// pdfDocument holds the objects of a PDF by number.
+type pdfDocument struct {
+	objects map[int]interface{}
+}
+
+// readPDF extracts the text of the pages of the PDF at path, split into
+// paragraphs. pages limits it to a range such as "1-3,5", and is every page
+// when empty.
+func readPDF(path, pages string) ([]pdfParagraph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\r "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("%s: not a PDF file", path)
+	}
+	doc := loadPDF(data)
+	pageDicts, err := doc.pages()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	selected, err := parsePageRange(pages, len(pageDicts))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var paragraphs []pdfParagraph
+	for _, number := range selected {
+		for i, text := range doc.pageParagraphs(pageDicts[number-1]) {
+			paragraphs = append(paragraphs, pdfParagraph{Page: number, Paragraph: i + 1, Text: text})
+		}
+	}
+	if len(paragraphs) == 0 {
+		return nil, fmt.Errorf("%s: no text found; scanned PDFs need OCR first", path)
+	}
+	return paragraphs, nil
+}
+
+// readAnalysisFile reads a --file of analise, returning the paragraphs of
+// its text along with it when it is a PDF document.
+func readAnalysisFile(path, pages string) (string, []pdfParagraph, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+		if pages != "" {
+			return "", nil, fmt.Errorf("%s: --pages only applies to PDF documents", path)
+		}
+		text, err := readInputFile(path)
+		return text, nil, err
+	}
+	paragraphs, err := readPDF(path, pages)
+	if err != nil {
+		return "", nil, err
+	}
+	return pdfText(paragraphs), paragraphs, nil
+}
+
+// parsePageRange returns the page numbers of spec, such as "1-3,5" or "4-",
+// in order, or every page from 1 to count when spec is empty.
+func parsePageRange(spec string, count int) ([]int, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = "1-"
+	}
+	seen := make(map[int]bool)
+	var pages []int
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil || from < 1 {
+			return nil, fmt.Errorf("invalid page range %q (expected pages such as 1-3,5)", spec)
+		}
+		to := from
+		if isRange {
+			to = count
+			if last = strings.TrimSpace(last); last != "" {
+				if to, err = strconv.Atoi(last); err != nil || to < from {
+					return nil, fmt.Errorf("invalid page range %q (expected pages such as 1-3,5)", spec)
+				}
+			}
+		}
+		if from > count || to > count {
+			return nil, fmt.Errorf("page %d is out of range (the PDF has %d pages)", max(from, to), count)
+		}
+		for page := from; page <= to; page++ {
+			if !seen[page] {
+				seen[page] = true
+				pages = append(pages, page)
+			}
+		}
+	}
+	sort.Ints(pages)
+	return pages, nil
+}
+
+// loadPDF reads the indirect objects of a PDF, including the ones of its
+// object streams, by scanning the file rather than trusting its cross
+// reference table. Later definitions of an object, from incremental updates,
+// win over earlier ones.
+func loadPDF(data []byte) *pdfDocument {
+	doc := &pdfDocument{objects: make(map[int]interface{})}
+	for _, match := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		lexer := &pdfLexer{data: data, pos: match[1]}
+		value := lexer.value()
+		if dict, ok := value.(pdfDict); ok {
+			if stream, ok := lexer.stream(dict); ok {
+				value = stream
+			}
+		}
+		doc.objects[num] = value
+	}
+
+	for _, value := range doc.objects {
+		stream, ok := value.(pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := doc.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		count, _ := doc.resolve(stream.dict["N"]).(float64)
+		first, _ := doc.resolve(stream.dict["First"]).(float64)
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(count); i++ {
+			num, _ := header.value().(float64)
+			offset, _ := header.value().(float64)
+			if _, defined := doc.objects[int(num)]; defined || int(first+offset) >= len(data) {
+				continue
+			}
+			doc.objects[int(num)] = (&pdfLexer{data: data, pos: int(first + offset)}).value()
+		}
+	}
+	return doc
+}
+
+// resolve follows the references of value to the object they name.
+func (d *pdfDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = d.objects[ref.num]
+	}
+	return nil
+}
+
+// dict returns the dictionary of value, or of its stream.
+func (d *pdfDocument) dict(value interface{}) pdfDict {
+	switch value := d.resolve(value).(type) {
+	case pdfDict:
+		return value
+	case pdfStream:
+		return value.dict
+	}
+	return nil
+}
+
+// pages returns the page dictionaries of the document, in order, with the
+// resources they inherit from the page tree.
+func (d *pdfDocument) pages() ([]pdfDict, error) {
+	var catalog pdfDict
+	for _, value := range d.objects {
+		if dict := d.dict(value); dict["Type"] == pdfName("Catalog") && dict["Pages"] != nil {
+			catalog = dict
+		}
+	}
+	if catalog == nil {
+		return nil, errors.New("no page tree found; the PDF may be encrypted or damaged")
+	}
+	var pages []pdfDict
+	// The depth limit stops the walk of page trees with cycles.
+	var walk func(node pdfDict, resources interface{}, depth int)
+	walk = func(node pdfDict, resources interface{}, depth int) {
+		if node == nil || depth > 64 {
+			return
+		}
+		if node["Resources"] != nil {
+			resources = node["Resources"]
+		}
+		kids, ok := d.resolve(node["Kids"]).([]interface{})
+		if !ok {
+			page := pdfDict{"Resources": resources, "Contents": node["Contents"]}
+			pages = append(pages, page)
+			return
+		}
+		for _, kid := range kids {
+			walk(d.dict(kid), resources, depth+1)
+		}
+	}
+	walk(d.dict(catalog["Pages"]), nil, 0)
+	if len(pages) == 0 {
+		return nil, errors.New("the PDF has no pages")
+	}
+	return pages, nil
+}
+
+// decodeStream returns the data of stream, decoded by its filters.
+func (d *pdfDocument) decodeStream(stream pdfStream) ([]byte, error) {
+	var filters []interface{}
+	switch filter := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{filter}
+	case []interface{}:
+		filters = filter
+	}
+	data := stream.data
+	for _, filter := range filters {
+		switch d.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Truncated streams still give the text before the damage.
+			decoded, err := io.ReadAll(r)
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			digits := bytes.Map(func(r rune) rune {
+				if unicode.Is(unicode.ASCII_Hex_Digit, r) {
+					return r
+				}
+				return -1
+			}, bytes.TrimSuffix(bytes.TrimSpace(data), []byte(">")))
+			if len(digits)%2 == 1 {
+				digits = append(digits, '0')
+			}
+			decoded := make([]byte, len(digits)/2)
+			if _, err := hex.Decode(decoded, digits); err != nil {
+				return nil, err
+			}
+			data = decoded
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			encoded := bytes.TrimSuffix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte("<~"))), []byte("~>"))
+			decoded := make([]byte, 4*len(encoded)/5+4)
+			n, _, err := ascii85.Decode(decoded, encoded, true)
+			if err != nil {
+				return nil, err
+			}
+			data = decoded[:n]
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+	}
+	return data, nil
+}
+
+// pageParagraphs extracts the text of page, split into paragraphs where the
+// gap between two lines is larger than their height.
+func (d *pdfDocument) pageParagraphs(page pdfDict) []string {
+	var contents []interface{}
+	switch value := d.resolve(page["Contents"]).(type) {
+	case pdfStream:
+		contents = []interface{}{value}
+	case []interface{}:
+		contents = value
+	}
+	var data bytes.Buffer
+	for _, content := range contents {
+		stream, ok := d.resolve(content).(pdfStream)
+		if !ok {
+			continue
+		}
+		decoded, err := d.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		data.Write(decoded)
+		data.WriteByte('\n')
+	}
+
+	fonts := make(map[string]*pdfFont)
+	for name, font := range d.dict(d.dict(page["Resources"])["Font"]) {
+		fonts[name] = d.font(d.dict(font))
+	}
+	return pdfTextParagraphs(data.Bytes(), fonts)
+}
+
+// pdfTextParagraphs runs the text operators of a content stream, decoding
+// the strings they show with fonts.
+func pdfTextParagraphs(content []byte, fonts map[string]*pdfFont) []string {
+	var paragraphs, lines []string
+	var line strings.Builder
+	font := &pdfFont{codeLength: 1, encoding: &winAnsiEncoding}
+	var size, leading, lastY, lastHeight float64
+	lineMatrix := [6]float64{1, 0, 0, 1, 0, 0}
+	textMatrix := lineMatrix
+	moved, started := false, false
+
+	endLine := func() {
+		if text := strings.TrimSpace(line.String()); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+	endParagraph := func() {
+		endLine()
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, joinPDFLines(lines))
+		}
+		lines = nil
+	}
+	show := func(text string) {
+		y := textMatrix[5]
+		height := math.Abs(size * textMatrix[3])
+		if height == 0 {
+			height = math.Abs(size)
+		}
+		if height == 0 {
+			height = 12
+		}
+		switch {
+		case !started:
+			started = true
+		case math.Abs(y-lastY) > 1.8*max(height, lastHeight):
+			endParagraph()
+		case math.Abs(y-lastY) > 0.5*max(height, lastHeight):
+			endLine()
+		case moved && line.Len() > 0 && !strings.HasSuffix(line.String(), " ") && !strings.HasPrefix(text, " "):
+			line.WriteByte(' ')
+		}
+		line.WriteString(text)
+		lastY, lastHeight, moved = y, height, false
+	}
+	moveLine := func(tx, ty float64) {
+		lineMatrix[4] += tx*lineMatrix[0] + ty*lineMatrix[2]
+		lineMatrix[5] += tx*lineMatrix[1] + ty*lineMatrix[3]
+		textMatrix, moved = lineMatrix, true
+	}
+
+	lexer := &pdfLexer{data: content}
+	var operands []interface{}
+	number := func(i int) float64 {
+		if i < len(operands) {
+			n, _ := operands[i].(float64)
+			return n
+		}
+		return 0
+	}
+	for {
+		value := lexer.value()
+		keyword, ok := value.(pdfKeyword)
+		if !ok {
+			if lexer.pos >= len(content) {
+				break
+			}
+			operands = append(operands, value)
+			continue
+		}
+		switch keyword {
+		case "BT":
+			lineMatrix = [6]float64{1, 0, 0, 1, 0, 0}
+			textMatrix, moved = lineMatrix, true
+		case "Tf":
+			if len(operands) == 2 {
+				name, _ := operands[0].(pdfName)
+				if fonts[string(name)] != nil {
+					font = fonts[string(name)]
+				}
+				size = number(1)
+			}
+		case "TL":
+			leading = number(0)
+		case "Td":
+			moveLine(number(0), number(1))
+		case "TD":
+			leading = -number(1)
+			moveLine(number(0), number(1))
+		case "Tm":
+			if len(operands) == 6 {
+				for i := range lineMatrix {
+					lineMatrix[i] = number(i)
+				}
+				textMatrix, moved = lineMatrix, true
+			}
+		case "T*":
+			moveLine(0, -leading)
+		case "Tj", "'", "\"":
+			if keyword != "Tj" {
+				moveLine(0, -leading)
+			}
+			if len(operands) > 0 {
+				if text, ok := operands[len(operands)-1].(string); ok {
+					show(font.decode(text))
+				}
+			}
+		case "TJ":
+			var b strings.Builder
+			var parts []interface{}
+			if len(operands) > 0 {
+				parts, _ = operands[len(operands)-1].([]interface{})
+			}
+			for _, part := range parts {
+				switch part := part.(type) {
+				case string:
+					b.WriteString(font.decode(part))
+				case float64:
+					// Moving back by more than a quarter of an em is a space.
+					if part < -250 && !strings.HasSuffix(b.String(), " ") {
+						b.WriteByte(' ')
+					}
+				}
+			}
+			show(b.String())
+		case "ID":
+			// The data of inline images runs until EI.
+			end := regexp.MustCompile(`\sEI\b`).FindIndex(content[lexer.pos:])
+			if end == nil {
+				lexer.pos = len(content)
+			} else {
+				lexer.pos += end[1]
+			}
+		}
+		operands = operands[:0]
+		if lexer.pos >= len(content) {
+			break
+		}
+	}
+	endParagraph()
+	return paragraphs
+}
+
+// joinPDFLines joins the lines of a paragraph, joining back the words
+// hyphenated at the end of a line.
+func joinPDFLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			previous := lines[i-1]
+			next := []rune(line)
+			if strings.HasSuffix(previous, "-") && len(previous) > 1 && unicode.IsLower(next[0]) {
+				text := strings.TrimSuffix(b.String(), "-")
+				b.Reset()
+				b.WriteString(text)
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// pdfText joins paragraphs into the text analyzed, with a blank line
+// between paragraphs.
+func pdfText(paragraphs []pdfParagraph) string {
+	texts := make([]string, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		texts[i] = paragraph.Text
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// paragraphLocator finds the paragraph of a PDF that each section, in
+// order, starts in.
+type paragraphLocator struct {
+	paragraphs []pdfParagraph
+	// text is the text of the paragraphs with their whitespace collapsed,
+	// and starts the offset of each paragraph in it.
+	text   string
+	starts []int
+	from   int
+}
+
+func newParagraphLocator(paragraphs []pdfParagraph) *paragraphLocator {
+	l := &paragraphLocator{paragraphs: paragraphs}
+	var b strings.Builder
+	for _, paragraph := range paragraphs {
+		l.starts = append(l.starts, b.Len())
+		b.WriteString(strings.Join(strings.Fields(paragraph.Text), " "))
+		b.WriteByte(' ')
+	}
+	l.text = b.String()
+	return l
+}
+
+// locate sets the page and the paragraph of item to the ones its source
+// starts in, looking for it after the previous section first. Sections
+// whose first words aren't in the text are left without them.
+func (l *paragraphLocator) locate(item *ResultItem) {
+	words := strings.Fields(item.Source)
+	if len(words) == 0 {
+		return
+	}
+	needle := strings.Join(words[:min(5, len(words))], " ")
+	start := strings.Index(l.text[l.from:], needle)
+	if start >= 0 {
+		start += l.from
+	} else if start = strings.Index(l.text, needle); start < 0 {
+		return
+	}
+	l.from = start + len(needle)
+	i := sort.SearchInts(l.starts, start+1) - 1
+	item.Page, item.Paragraph = l.paragraphs[i].Page, l.paragraphs[i].Paragraph
+}
+
+// pdfLexer reads the objects of a PDF file or the operators of a content
+// stream.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// isPDFDelimiter reports whether c ends a name, a number or a keyword.
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/% \t\r\n\f\x00", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.IndexByte(" \t\r\n\f\x00", c) >= 0:
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+// value reads the next object, or the next keyword such as an operator.
+// References are read as pdfRef, and the end of the data as nil.
+func (l *pdfLexer) value() interface{} {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil
+	}
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		start := l.pos + 1
+		for l.pos++; l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]); l.pos++ {
+		}
+		return pdfName(decodePDFName(string(l.data[start:l.pos])))
+	case c == '(':
+		return l.literalString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := pdfDict{}
+		for {
+			key := l.value()
+			name, ok := key.(pdfName)
+			if !ok {
+				return dict
+			}
+			dict[string(name)] = l.value()
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			end = len(l.data) - l.pos
+		}
+		digits := bytes.Map(func(r rune) rune {
+			if unicode.Is(unicode.ASCII_Hex_Digit, r) {
+				return r
+			}
+			return -1
+		}, l.data[l.pos+1:l.pos+end])
+		l.pos += end + 1
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		decoded := make([]byte, len(digits)/2)
+		hex.Decode(decoded, digits)
+		return string(decoded)
+	case c == '[':
+		l.pos++
+		array := []interface{}{}
+		for {
+			value := l.value()
+			if keyword, ok := value.(pdfKeyword); (ok && keyword == "]") || (value == nil && l.pos >= len(l.data)) {
+				return array
+			}
+			array = append(array, value)
+		}
+	case c == ']' || c == '>' || c == '{' || c == '}' || c == ')':
+		l.pos++
+		if c == '>' && l.pos < len(l.data) && l.data[l.pos] == '>' {
+			l.pos++
+			return pdfKeyword(">>")
+		}
+		return pdfKeyword([]byte{c})
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	token := string(l.data[start:l.pos])
+	number, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		switch token {
+		case "true", "false":
+			return token == "true"
+		case "null":
+			return nil
+		}
+		return pdfKeyword(token)
+	}
+	// An integer followed by another one and R is a reference.
+	if number == math.Trunc(number) && !strings.Contains(token, ".") {
+		save := l.pos
+		l.skipSpace()
+		genStart := l.pos
+		for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+			l.pos++
+		}
+		if l.pos > genStart {
+			gen, _ := strconv.Atoi(string(l.data[genStart:l.pos]))
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' && (l.pos+1 == len(l.data) || isPDFDelimiter(l.data[l.pos+1])) {
+				l.pos++
+				return pdfRef{num: int(number), gen: gen}
+			}
+		}
+		l.pos = save
+	}
+	return number
+}
+
+// literalString reads a string in parentheses, with its escapes.
+func (l *pdfLexer) literalString() string {
+	var b []byte
+	depth := 0
+	for l.pos++; l.pos < len(l.data); l.pos++ {
+		c := l.data[l.pos]
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				l.pos++
+				return string(b)
+			}
+			depth--
+		case c == '\\' && l.pos+1 < len(l.data):
+			l.pos++
+			c = l.data[l.pos]
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string.
+				if c == '\r' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := 0
+					for i := 0; i < 3 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					l.pos--
+					c = byte(n)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// stream reads the data of the stream following dict, if any.
+func (l *pdfLexer) stream(dict pdfDict) (pdfStream, bool) {
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		return pdfStream{}, false
+	}
+	start := l.pos + len("stream")
+	if bytes.HasPrefix(l.data[start:], []byte("\r\n")) {
+		start += 2
+	} else if start < len(l.data) && (l.data[start] == '\n' || l.data[start] == '\r') {
+		start++
+	}
+	if length, ok := dict["Length"].(float64); ok && start+int(length) <= len(l.data) {
+		end := start + int(length)
+		if bytes.HasPrefix(bytes.TrimLeft(l.data[end:], "\r\n "), []byte("endstream")) {
+			l.pos = end
+			return pdfStream{dict: dict, data: l.data[start:end]}, true
+		}
+	}
+	end := bytes.Index(l.data[start:], []byte("endstream"))
+	if end < 0 {
+		end = len(l.data) - start
+	}
+	l.pos = start + end
+	return pdfStream{dict: dict, data: bytes.TrimRight(l.data[start:start+end], "\r\n")}, true
+}
+
+// decodePDFName decodes the #xx escapes of a name.
+func decodePDFName(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if n, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// pdfFont decodes the strings shown with a font into text.
+type pdfFont struct {
+	// codeLength is the number of bytes of a character code.
+	codeLength int
+	// toUnicode maps the codes to their text, from the ToUnicode CMap of
+	// the font, and encoding the codes of simple fonts without one.
+	toUnicode map[int]string
+	encoding  *[256]rune
+}
+
+// font reads the encoding of a font dictionary.
+func (d *pdfDocument) font(dict pdfDict) *pdfFont {
+	font := &pdfFont{codeLength: 1}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLength = 2
+	}
+	if stream, ok := d.resolve(dict["ToUnicode"]).(pdfStream); ok {
+		if data, err := d.decodeStream(stream); err == nil {
+			font.toUnicode = parseToUnicode(data, &font.codeLength)
+		}
+	}
+	if font.codeLength != 1 {
+		return font
+	}
+
+	encoding := winAnsiEncoding
+	var differences []interface{}
+	switch value := d.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		encoding = *pdfEncodingNamed(value)
+	case pdfDict:
+		if base, ok := value["BaseEncoding"].(pdfName); ok {
+			encoding = *pdfEncodingNamed(base)
+		}
+		differences, _ = d.resolve(value["Differences"]).([]interface{})
+	}
+	code := 0
+	for _, difference := range differences {
+		switch difference := d.resolve(difference).(type) {
+		case float64:
+			code = int(difference)
+		case pdfName:
+			if r, ok := glyphRune(string(difference)); ok && code >= 0 && code < 256 {
+				encoding[code] = r
+			}
+			code++
+		}
+	}
+	font.encoding = &encoding
+	return font
+}
+
+// decode returns the text of the codes of s.
+func (f *pdfFont) decode(s string) string {
+	var b strings.Builder
+	for i := 0; i+f.codeLength <= len(s); i += f.codeLength {
+		code := 0
+		for _, c := range []byte(s[i : i+f.codeLength]) {
+			code = code<<8 | int(c)
+		}
+		if text, ok := f.toUnicode[code]; ok {
+			b.WriteString(text)
+		} else if f.encoding != nil && code < 256 && f.encoding[code] != 0 {
+			b.WriteRune(f.encoding[code])
+		}
+	}
+	return b.String()
+}
+
+// parseToUnicode reads the mappings of a ToUnicode CMap, setting codeLength
+// to the length of the codes of its code space.
+func parseToUnicode(data []byte, codeLength *int) map[int]string {
+	mappings := make(map[int]string)
+	code := func(s string) int {
+		n := 0
+		for _, c := range []byte(s) {
+			n = n<<8 | int(c)
+		}
+		return n
+	}
+	lexer := &pdfLexer{data: data}
+	var operands []interface{}
+	for lexer.pos < len(data) {
+		value := lexer.value()
+		keyword, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+		switch keyword {
+		case "endcodespacerange":
+			if len(operands) >= 2 {
+				if low, ok := operands[0].(string); ok && len(low) > 0 {
+					*codeLength = len(low)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				source, _ := operands[i].(string)
+				target, _ := operands[i+1].(string)
+				mappings[code(source)], _ = decodeUTF16([]byte(target), binary.BigEndian)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, _ := operands[i].(string)
+				high, _ := operands[i+1].(string)
+				from, to := code(low), code(high)
+				if to-from > 0xffff {
+					continue
+				}
+				switch target := operands[i+2].(type) {
+				case string:
+					units := utf16.Decode(utf16Units(target))
+					for c := from; c <= to && len(units) > 0; c++ {
+						mappings[c] = string(units)
+						units = append([]rune{}, units...)
+						units[len(units)-1]++
+					}
+				case []interface{}:
+					for j, item := range target {
+						if text, ok := item.(string); ok && from+j <= to {
+							mappings[from+j], _ = decodeUTF16([]byte(text), binary.BigEndian)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return mappings
+}
+
+// utf16Units reads s as big-endian UTF-16.
+func utf16Units(s string) []uint16 {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return units
+}
+
+// winAnsiEncoding, macRomanEncoding and standardEncoding are the encodings
+// of simple fonts, which share the printable ASCII characters.
+var winAnsiEncoding, macRomanEncoding, standardEncoding [256]rune
+
+// glyphNames maps the names of the glyphs of the encodings to their
+// characters, for the Differences of fonts.
+var glyphNames = map[string]rune{}
+
+func init() {
+	ascii := strings.Fields("space exclam quotedbl numbersign dollar percent ampersand quotesingle parenleft parenright asterisk plus comma hyphen period slash zero one two three four five six seven eight nine colon semicolon less equal greater question at")
+	for i, name := range ascii {
+		glyphNames[name] = rune(0x20 + i)
+	}
+	for r := 'A'; r <= 'Z'; r++ {
+		glyphNames[string(r)] = r
+		glyphNames[string(unicode.ToLower(r))] = unicode.ToLower(r)
+	}
+	for i, name := range strings.Fields("bracketleft backslash bracketright asciicircum underscore grave") {
+		glyphNames[name] = rune(0x5b + i)
+	}
+	for i, name := range strings.Fields("braceleft bar braceright asciitilde") {
+		glyphNames[name] = rune(0x7b + i)
+	}
+	latin1 := strings.Fields("Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla Egrave Eacute Ecircumflex Edieresis Igrave Iacute Icircumflex Idieresis Eth Ntilde Ograve Oacute Ocircumflex Otilde Odieresis multiply Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls agrave aacute acircumflex atilde adieresis aring ae ccedilla egrave eacute ecircumflex edieresis igrave iacute icircumflex idieresis eth ntilde ograve oacute ocircumflex otilde odieresis divide oslash ugrave uacute ucircumflex udieresis yacute thorn ydieresis")
+	for i, name := range latin1 {
+		glyphNames[name] = rune(0xc0 + i)
+	}
+	for name, r := range map[string]rune{
+		"quoteleft": '‘', "quoteright": '’', "quotedblleft": '“', "quotedblright": '”', "quotesinglbase": '‚', "quotedblbase": '„',
+		"endash": '–', "emdash": '—', "bullet": '•', "ellipsis": '…', "fi": 'ﬁ', "fl": 'ﬂ', "OE": 'Œ', "oe": 'œ', "Scaron": 'Š',
+		"scaron": 'š', "Zcaron": 'Ž', "zcaron": 'ž', "Ydieresis": 'Ÿ', "Euro": '€', "guillemotleft": '«', "guillemotright": '»',
+		"exclamdown": '¡', "questiondown": '¿', "degree": '°', "section": '§', "paragraph": '¶', "copyright": '©', "registered": '®',
+		"trademark": '™', "dagger": '†', "daggerdbl": '‡', "periodcentered": '·', "nbspace": ' ', "dotlessi": 'ı', "minus": '−',
+	} {
+		glyphNames[name] = r
+	}
+
+	for r := rune(0x20); r < 0x7f; r++ {
+		winAnsiEncoding[r], macRomanEncoding[r], standardEncoding[r] = r, r, r
+	}
+	standardEncoding['\''], standardEncoding['`'] = '’', '‘'
+	for i, r := range []rune("€\u0081‚ƒ„…†‡ˆ‰Š‹Œ\u008dŽ\u008f\u0090‘’“”•–—˜™š›œ\u009džŸ") {
+		winAnsiEncoding[0x80+i] = r
+	}
+	for r := rune(0xa0); r <= 0xff; r++ {
+		winAnsiEncoding[r], standardEncoding[r] = r, r
+	}
+	for i, r := range []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ") {
+		macRomanEncoding[0x80+i] = r
+	}
+}
+
+// pdfEncodingNamed returns the encoding of a simple font named name.
+func pdfEncodingNamed(name pdfName) *[256]rune {
+	switch name {
+	case "MacRomanEncoding":
+		return &macRomanEncoding
+	case "StandardEncoding":
+		return &standardEncoding
+	}
+	return &winAnsiEncoding
+}
+
+// glyphRune returns the character of a glyph name, including the uniXXXX
+// and uXXXX names.
+func glyphRune(name string) (rune, bool) {
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	digits := ""
+	switch {
+	case strings.HasPrefix(name, "uni") && len(name) == 7:
+		digits = name[3:]
+	case strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7:
+		digits = name[1:]
+	}
+	if n, err := strconv.ParseUint(digits, 16, 32); err == nil && n <= unicode.MaxRune {
+		return rune(n), true
+	}
+	return 0, false
+}
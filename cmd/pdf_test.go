@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pdfStreamFixture returns a stream object with dict and data, compressed
+// when flate is set.
+func pdfStreamFixture(t *testing.T, dict, data string, flate bool) string {
+	t.Helper()
+	if flate {
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data, dict = b.String(), dict+" /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// pdfFixture writes a PDF with objects, numbered from 1, to a temporary
+// file and returns its path; empty objects are left out, for the ones of
+// object streams. Its cross reference table is left out too, as readPDF
+// doesn't need it.
+func pdfFixture(t *testing.T, objects ...string) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("%PDF-1.5\n")
+	for i, object := range objects {
+		if object == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	path := filepath.Join(t.TempDir(), "fixture.pdf")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// bookPDF is a document of two pages sharing the fonts of their page tree:
+// the first with a simple font and an uncompressed content stream, the
+// second with a two-byte font of its own codes, defined in an object
+// stream, and compressed ones.
+func bookPDF(t *testing.T) string {
+	toUnicode := "/CIDInit /ProcSet findresource begin\nbegincmap\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n2 beginbfrange\n<0001> <001A> <0041>\n<0021> <003A> <0061>\nendbfrange\nendcmap"
+	font := "<< /Type /Font /Subtype /Type0 /BaseFont /Noto /ToUnicode 7 0 R >>"
+	return pdfFixture(t,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 8 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [9 0 R 10 0 R] >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /BaseEncoding /WinAnsiEncoding /Differences [1 /eacute] >> >>",
+		pdfStreamFixture(t, "", "BT /F1 12 Tf 72 720 Td (Als ich sechs Jahre alt war, sah ich ein-) Tj 0 -14 Td (mal ein gro\\337artiges Bild im \\001t\\351.) Tj 0 -40 Td [(Zweiter) -300 (Absatz.)] TJ ET", false),
+		pdfStreamFixture(t, "", toUnicode, true),
+		"",
+		pdfStreamFixture(t, "/Type /ObjStm /N 1 /First 4", "8 0 "+font, true),
+		pdfStreamFixture(t, "", "BT /F2 10 Tf 72 700 Td [<0008002500320032> -300 <001000320029002e003a>] TJ", true),
+		pdfStreamFixture(t, "", "ET", false),
+	)
+}
+
+func TestReadPDF(t *testing.T) {
+	path := bookPDF(t)
+	paragraphs, err := readPDF(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pdfParagraph{
+		{Page: 1, Paragraph: 1, Text: "Als ich sechs Jahre alt war, sah ich einmal ein großartiges Bild im été."},
+		{Page: 1, Paragraph: 2, Text: "Zweiter Absatz."},
+		{Page: 2, Paragraph: 1, Text: "Herr Prinz"},
+	}
+	if fmt.Sprint(paragraphs) != fmt.Sprint(want) {
+		t.Errorf("paragraphs = %v, want %v", paragraphs, want)
+	}
+
+	if paragraphs, err = readPDF(path, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(paragraphs) != fmt.Sprint(want[2:]) {
+		t.Errorf("paragraphs of page 2 = %v, want %v", paragraphs, want[2:])
+	}
+	if _, err := readPDF(path, "3"); err == nil || !strings.Contains(err.Error(), "the PDF has 2 pages") {
+		t.Errorf("reading page 3: %v", err)
+	}
+}
+
+func TestReadPDFWithoutText(t *testing.T) {
+	scanned := pdfFixture(t,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		pdfStreamFixture(t, "", "q 612 0 0 792 0 0 cm /Im1 Do Q", false),
+	)
+	if _, err := readPDF(scanned, ""); err == nil || !strings.Contains(err.Error(), "OCR") {
+		t.Errorf("reading a PDF without text: %v", err)
+	}
+
+	text := filepath.Join(t.TempDir(), "text.pdf")
+	if err := os.WriteFile(text, []byte("Als ich sechs Jahre alt war."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPDF(text, ""); err == nil || !strings.Contains(err.Error(), "not a PDF file") {
+		t.Errorf("reading a text file: %v", err)
+	}
+}
+
+func TestParsePageRange(t *testing.T) {
+	for _, test := range []struct {
+		spec string
+		want string
+	}{
+		{"", "[1 2 3 4 5]"},
+		{"1-3,5", "[1 2 3 5]"},
+		{"4-", "[4 5]"},
+		{" 5, 2-3 ,2", "[2 3 5]"},
+		{"3-2", "error"},
+		{"0", "error"},
+		{"6", "error"},
+		{"a-b", "error"},
+	} {
+		pages, err := parsePageRange(test.spec, 5)
+		got := fmt.Sprint(pages)
+		if err != nil {
+			got = "error"
+		}
+		if got != test.want {
+			t.Errorf("parsePageRange(%q) = %s, want %s", test.spec, got, test.want)
+		}
+	}
+}
+
+// The sections of the text of a PDF get back the page and the paragraph
+// they start in.
+func TestPDFParagraphLocator(t *testing.T) {
+	text, paragraphs, err := readAnalysisFile(bookPDF(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "Als ich sechs Jahre alt war,") || !strings.Contains(text, "im été.\n\nZweiter Absatz.\n\nHerr Prinz") {
+		t.Errorf("text = %q", text)
+	}
+
+	locator := newParagraphLocator(paragraphs)
+	var located []string
+	for _, source := range []string{"Als ich sechs Jahre alt war,", "sah ich einmal", "ein großartiges Bild im été.", "Zweiter Absatz.", "Herr Prinz", "Nirgendwo"} {
+		item := ResultItem{Source: source}
+		locator.locate(&item)
+		located = append(located, fmt.Sprintf("%d.%d", item.Page, item.Paragraph))
+	}
+	if got, want := strings.Join(located, " "), "1.1 1.1 1.1 1.2 2.1 0.0"; got != want {
+		t.Errorf("located = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pinyinStyles maps the ways of writing the tones of --pinyin to the
+// transliteration schemes producing them.
+var pinyinStyles = map[string]string{
+	"marks":   "pinyin",
+	"numbers": "pinyin-numbers",
+}
+
+// checkPinyinStyle reports whether style is one of pinyinStyles, or empty
+// when --pinyin is not set.
+func checkPinyinStyle(style string) error {
+	if _, ok := pinyinStyles[style]; ok || style == "" {
+		return nil
+	}
+	return fmt.Errorf("unsupported pinyin style %q (expected marks or numbers)", style)
+}
+
+// isChinese reports whether language is a BCP 47 tag of Chinese.
+func isChinese(language string) bool {
+	primary, _, _ := strings.Cut(strings.ToLower(language), "-")
+	return primary == "zh"
+}
+
+// pinyinApplies reports whether the pinyin of --pinyin is added to text in
+// language, warning when it was set for a language other than Chinese.
+func pinyinApplies(language string, opts analysisOptions) bool {
+	if opts.pinyin == "" {
+		return false
+	}
+	if !isChinese(language) {
+		fmt.Fprintf(os.Stderr, "Warning: --pinyin only applies to Chinese, not %s; leaving it out\n", language)
+		return false
+	}
+	return true
+}
+
+// annotatePinyin returns the pinyin of text, with the tones written in the
+// --pinyin style.
+func annotatePinyin(ctx context.Context, text string, opts analysisOptions) (string, error) {
+	scheme, err := resolveTransliterationScheme(opts.sourceLanguage, pinyinStyles[opts.pinyin])
+	if err != nil {
+		return "", err
+	}
+	return transliterateSection(ctx, text, scheme, opts)
+}
@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// poKeyword matches the lines starting a field of a PO entry.
+var poKeyword = regexp.MustCompile(`^(msgctxt|msgid|msgid_plural|msgstr(\[\d+\])?)[ \t]+(".*)$`)
+
+// poPluralForms matches the number of plural forms of the header of a PO
+// file.
+var poPluralForms = regexp.MustCompile(`nplurals\s*=\s*(\d+)`)
+
+// poEntry is an entry of a PO file: its comments, which include its flags
+// and the obsolete entries, and its fields.
+type poEntry struct {
+	Comments []string
+	Fields   []poField
+}
+
+// poField is a field of a PO entry, such as msgid or msgstr[1].
+type poField struct {
+	Keyword string
+	Value   string
+	// Raw are the lines the field was read from, written back as they are
+	// unless the field is translated.
+	Raw []string
+}
+
+// changed reports whether f was translated rather than read.
+func (f poField) changed() bool {
+	return f.Raw == nil
+}
+
+// field returns the value of the field keyword of e, reporting whether it
+// has one.
+func (e poEntry) field(keyword string) (string, bool) {
+	for _, field := range e.Fields {
+		if field.Keyword == keyword {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// translated reports whether any of the msgstr fields of e is filled in.
+func (e poEntry) translated() bool {
+	for _, field := range e.Fields {
+		if strings.HasPrefix(field.Keyword, "msgstr") && field.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// header reports whether e is the header of its file, whose msgid is empty.
+func (e poEntry) header() bool {
+	msgid, ok := e.field("msgid")
+	_, hasContext := e.field("msgctxt")
+	return ok && msgid == "" && !hasContext
+}
+
+// parsePO reads the entries of a PO file.
+func parsePO(text string) ([]poEntry, error) {
+	var entries []poEntry
+	var entry poEntry
+	flush := func() {
+		if len(entry.Comments) > 0 || len(entry.Fields) > 0 {
+			entries = append(entries, entry)
+		}
+		entry = poEntry{}
+	}
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			if len(entry.Fields) > 0 {
+				flush()
+			}
+			entry.Comments = append(entry.Comments, line)
+		case strings.HasPrefix(trimmed, `"`):
+			if len(entry.Fields) == 0 {
+				return nil, fmt.Errorf("line %d: string without a keyword", i+1)
+			}
+			value, err := unquotePO(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			field := &entry.Fields[len(entry.Fields)-1]
+			field.Value += value
+			field.Raw = append(field.Raw, line)
+		default:
+			match := poKeyword.FindStringSubmatch(trimmed)
+			if match == nil {
+				return nil, fmt.Errorf("line %d: unexpected %q", i+1, trimmed)
+			}
+			// Entries without a blank line between them start at their
+			// msgctxt, or at their msgid when they have none.
+			if _, ok := entry.field("msgid"); (ok && match[1] == "msgid") || (match[1] == "msgctxt" && len(entry.Fields) > 0) {
+				flush()
+			}
+			value, err := unquotePO(strings.TrimSpace(match[3]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			entry.Fields = append(entry.Fields, poField{Keyword: match[1], Value: value, Raw: []string{line}})
+		}
+	}
+	flush()
+	return entries, nil
+}
+
+// unquotePO decodes a quoted PO string, with its C escapes.
+func unquotePO(quoted string) (string, error) {
+	if len(quoted) < 2 || !strings.HasPrefix(quoted, `"`) || !strings.HasSuffix(quoted, `"`) {
+		return "", fmt.Errorf("invalid string %s", quoted)
+	}
+	var b strings.Builder
+	body := quoted[1 : len(quoted)-1]
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' || i+1 == len(body) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch body[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		default:
+			b.WriteByte(body[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// quotePO encodes value as a quoted PO string.
+func quotePO(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(value) + `"`
+}
+
+// writePO writes entries as a PO file, with a blank line between them. The
+// fields with a value of several lines are written a line per string, the
+// way gettext does.
+func writePO(entries []poEntry) []byte {
+	var b bytes.Buffer
+	for i, entry := range entries {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		for _, comment := range entry.Comments {
+			b.WriteString(comment + "\n")
+		}
+		for _, field := range entry.Fields {
+			if field.Raw != nil {
+				b.WriteString(strings.Join(field.Raw, "\n") + "\n")
+				continue
+			}
+			lines := strings.SplitAfter(field.Value, "\n")
+			if lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			if len(lines) <= 1 {
+				b.WriteString(field.Keyword + " " + quotePO(field.Value) + "\n")
+				continue
+			}
+			b.WriteString(field.Keyword + ` ""` + "\n")
+			for _, line := range lines {
+				b.WriteString(quotePO(line) + "\n")
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// markFuzzy adds the fuzzy flag to the flags comment of e, which it adds
+// before the comments on the previous msgid when there is none.
+func markFuzzy(e *poEntry) {
+	at := len(e.Comments)
+	for i, comment := range e.Comments {
+		switch {
+		case strings.HasPrefix(comment, "#,"):
+			for _, flag := range strings.Split(comment[2:], ",") {
+				if strings.TrimSpace(flag) == "fuzzy" {
+					return
+				}
+			}
+			e.Comments[i] = "#, fuzzy"
+			if flags := strings.TrimSpace(comment[2:]); flags != "" {
+				e.Comments[i] += ", " + flags
+			}
+			return
+		case strings.HasPrefix(comment, "#|") && at == len(e.Comments):
+			at = i
+		}
+	}
+	e.Comments = append(e.Comments[:at], append([]string{"#, fuzzy"}, e.Comments[at:]...)...)
+}
+
+var i18nPoTranslateCmd = &cobra.Command{
+	Use:   "translate <file.po>",
+	Short: "Fill in the untranslated messages of a gettext PO file",
+	Long: `The "translate" command reads a gettext PO file and translates the msgid of every entry whose msgstr is empty into the --translation-language, keeping its format specifiers, such as %s, %1$d, %(name)s or {name}, and its leading and trailing whitespace. The header, the comments, the flags, the contexts and the obsolete entries are kept as they are.
+Plural entries get a msgstr for every plural form of the Plural-Forms of the header, two when it has none: the first one translates the msgid and the others the msgid_plural.
+Entries already translated are left untouched unless --overwrite is given. Use --fuzzy to mark the translated entries as fuzzy, so that translators review them before they are used. Translations that lose any format specifier are left out, with a warning.
+The file is written to stdout, or to --output.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runI18nPoTranslate,
+}
+
+func runI18nPoTranslate(cmd *cobra.Command, args []string) error {
+	overwrite, err := cmd.Flags().GetBool("overwrite")
+	if err != nil {
+		return fmt.Errorf("retrieving overwrite flag: %w", err)
+	}
+	fuzzy, err := cmd.Flags().GetBool("fuzzy")
+	if err != nil {
+		return fmt.Errorf("retrieving fuzzy flag: %w", err)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	text, err := readInputFile(args[0])
+	if err != nil {
+		return fmt.Errorf("reading PO file: %w", err)
+	}
+	entries, err := parsePO(text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	plurals, count := 2, 0
+	var pending []int
+	for i, entry := range entries {
+		if entry.header() {
+			msgstr, _ := entry.field("msgstr")
+			if match := poPluralForms.FindStringSubmatch(msgstr); match != nil {
+				plurals, _ = strconv.Atoi(match[1])
+			}
+			continue
+		}
+		if _, ok := entry.field("msgid"); ok && (overwrite || !entry.translated()) {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Fprintln(os.Stderr, "Every message is already translated (use --overwrite to translate them again)")
+	}
+
+	if len(pending) > 0 {
+		opts, err := analysisOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if len(opts.translationLanguages) > 1 {
+			return errors.New("i18n po translate requires a single --translation-language")
+		}
+
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		defer opts.tracer.Flush()
+		warmUp(ctx, opts)
+
+		if opts.sourceLanguage == "" {
+			messages := make([]string, len(pending))
+			for n, i := range pending {
+				messages[n], _ = entries[i].field("msgid")
+			}
+			if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(messages, " "), readabilitySampleWords), opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+
+		opts.progress.Start(len(pending))
+		translate := func(_ int, i int) (poEntry, error) {
+			msgid, _ := entries[i].field("msgid")
+			opts.progress.Begin(msgid)
+			entry, err := translatePOEntry(ctx, entries[i], plurals, opts)
+			if err != nil {
+				return poEntry{}, err
+			}
+			opts.progress.Advance()
+			return entry, nil
+		}
+		translated, err := analyze.RunOrdered(pending, opts.concurrency, translate, nil)
+		opts.progress.Finish()
+		if err != nil {
+			return runError(ctx, opts, err)
+		}
+		for n, i := range pending {
+			if !translated[n].Fields[len(translated[n].Fields)-1].changed() {
+				continue
+			}
+			count++
+			if fuzzy {
+				markFuzzy(&translated[n])
+			}
+			entries[i] = translated[n]
+		}
+	}
+
+	data := writePO(entries)
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := writeFileAtomic(outputPath, data, force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d translated entries to %s\n", count, outputPath)
+	return nil
+}
+
+// translatePOEntry returns entry with its msgstr fields translated from its
+// msgid and msgid_plural, or with them empty when a translation loses any
+// of the format specifiers of its message.
+func translatePOEntry(ctx context.Context, entry poEntry, plurals int, opts analysisOptions) (poEntry, error) {
+	translations := make(map[string]string)
+	message := func(keyword string) (string, bool, error) {
+		source, _ := entry.field(keyword)
+		if translation, ok := translations[keyword]; ok {
+			return translation, true, nil
+		}
+		text, kept, prefix, suffix := maskMessage(source)
+		if text == "" {
+			return source, true, nil
+		}
+		translation, err := translateSection(ctx, text, opts.sourceLanguage, opts.translationLanguage, opts.glossary, opts)
+		if err != nil {
+			return "", false, err
+		}
+		translation, ok := restorePlaceholders(strings.TrimSpace(translation), kept)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: leaving %q untranslated, its translation lost some of its format specifiers\n", source)
+			return "", false, nil
+		}
+		translations[keyword] = prefix + translation + suffix
+		return translations[keyword], true, nil
+	}
+
+	var fields []poField
+	for _, field := range entry.Fields {
+		if !strings.HasPrefix(field.Keyword, "msgstr") {
+			fields = append(fields, field)
+		}
+	}
+	keywords := []string{"msgstr"}
+	if _, plural := entry.field("msgid_plural"); plural {
+		keywords = nil
+		for n := 0; n < max(plurals, 1); n++ {
+			keywords = append(keywords, fmt.Sprintf("msgstr[%d]", n))
+		}
+	}
+	for n, keyword := range keywords {
+		source := "msgid"
+		if n > 0 {
+			source = "msgid_plural"
+		}
+		translation, ok, err := message(source)
+		if err != nil {
+			return poEntry{}, err
+		}
+		if !ok {
+			// Entries are translated in full or kept as they were.
+			return entry, nil
+		}
+		fields = append(fields, poField{Keyword: keyword, Value: translation})
+	}
+	return poEntry{Comments: entry.Comments, Fields: fields}, nil
+}
+
+func init() {
+	addAnalysisFlags(i18nPoTranslateCmd.Flags())
+	i18nPoTranslateCmd.Flags().Bool("overwrite", false, "Translate the entries that are already translated again")
+	i18nPoTranslateCmd.Flags().Bool("fuzzy", false, "Mark the translated entries as fuzzy, for translators to review")
+	i18nPoTranslateCmd.Flags().StringP("output", "o", "", "Write the PO file to this file instead of stdout")
+	i18nPoTranslateCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	i18nPoCmd.AddCommand(i18nPoTranslateCmd)
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+const poFixture = `# Deutsche Übersetzung.
+msgid ""
+msgstr ""
+"Language: de\n"
+"Plural-Forms: nplurals=2; plural=(n != 1);\n"
+
+#: main.go:12
+#, c-format
+msgid "Hello, %s!"
+msgstr ""
+
+msgctxt "menu"
+msgid "Open"
+msgstr "Öffnen"
+
+msgctxt "verb"
+msgid "Open"
+msgstr ""
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d Dateien"
+
+msgid ""
+"First line\n"
+"second line with \"quotes\" and a \\ backslash"
+msgstr ""
+
+#~ msgid "Old"
+#~ msgstr "Alt"
+`
+
+func TestPORoundTrip(t *testing.T) {
+	entries, err := parsePO(poFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 7 {
+		t.Fatalf("read %d entries, want 7", len(entries))
+	}
+	if got := string(writePO(entries)); got != poFixture {
+		t.Errorf("wrote %q, want %q", got, poFixture)
+	}
+
+	if !entries[0].header() || entries[1].header() {
+		t.Error("the header is not the first entry alone")
+	}
+	if msgstr, _ := entries[0].field("msgstr"); msgstr != "Language: de\nPlural-Forms: nplurals=2; plural=(n != 1);\n" {
+		t.Errorf("header msgstr = %q", msgstr)
+	}
+	// Entries with a context are apart from the ones with the same msgid.
+	for i, want := range map[int]string{2: "menu", 3: "verb"} {
+		if msgctxt, _ := entries[i].field("msgctxt"); msgctxt != want {
+			t.Errorf("entry %d: msgctxt = %q, want %q", i, msgctxt, want)
+		}
+	}
+	if !entries[2].translated() || entries[3].translated() {
+		t.Error("translated entries are not told apart")
+	}
+	plural := entries[4]
+	if msgid, _ := plural.field("msgid_plural"); msgid != "%d files" {
+		t.Errorf("msgid_plural = %q", msgid)
+	}
+	if msgstr, _ := plural.field("msgstr[1]"); msgstr != "%d Dateien" {
+		t.Errorf("msgstr[1] = %q", msgstr)
+	}
+	if msgid, _ := entries[5].field("msgid"); msgid != "First line\nsecond line with \"quotes\" and a \\ backslash" {
+		t.Errorf("multi-line msgid = %q", msgid)
+	}
+	if want := []string{"#~ msgid \"Old\"", "#~ msgstr \"Alt\""}; !reflect.DeepEqual(entries[6].Comments, want) {
+		t.Errorf("obsolete entry = %q, want %q", entries[6].Comments, want)
+	}
+}
+
+func TestWritePOTranslated(t *testing.T) {
+	entries, err := parsePO(poFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Translated fields are written anew, a line per string when they have
+	// several, and the others as they were read.
+	entries[4].Fields[2] = poField{Keyword: "msgstr[0]", Value: "%d Datei"}
+	entries[4].Fields[3] = poField{Keyword: "msgstr[1]", Value: `%d "Dateien"`}
+	entries[5].Fields[1] = poField{Keyword: "msgstr", Value: "Erste Zeile\nzweite Zeile mit \"Anführungszeichen\" und einem \\ Backslash"}
+	entries = entries[4:6]
+	want := `msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d Datei"
+msgstr[1] "%d \"Dateien\""
+
+msgid ""
+"First line\n"
+"second line with \"quotes\" and a \\ backslash"
+msgstr ""
+"Erste Zeile\n"
+"zweite Zeile mit \"Anführungszeichen\" und einem \\ Backslash"
+`
+	data := writePO(entries)
+	if string(data) != want {
+		t.Errorf("wrote %q, want %q", data, want)
+	}
+
+	read, err := parsePO(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		for j, field := range entries[i].Fields {
+			if got := read[i].Fields[j]; got.Keyword != field.Keyword || got.Value != field.Value {
+				t.Errorf("read back %s %q, want %s %q", got.Keyword, got.Value, field.Keyword, field.Value)
+			}
+		}
+	}
+}
+
+func TestParsePOEntriesWithoutBlankLines(t *testing.T) {
+	entries, err := parsePO("msgid \"a\"\nmsgstr \"\"\nmsgctxt \"b\"\nmsgid \"a\"\nmsgstr \"\"\nmsgid \"c\"\nmsgstr \"\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("read %d entries, want 3", len(entries))
+	}
+
+	for _, text := range []string{"\"orphan\"\n", "msgid \"a\nmsgstr \"\"\n", "msgfoo \"a\"\n"} {
+		if entries, err := parsePO(text); err == nil {
+			t.Errorf("parsePO(%q) = %+v, want an error", text, entries)
+		}
+	}
+}
+
+func TestQuotePO(t *testing.T) {
+	for _, value := range []string{"", "plain", `say "hi"`, `C:\path`, "tab\there\nand\r\n"} {
+		quoted := quotePO(value)
+		got, err := unquotePO(quoted)
+		if err != nil || got != value {
+			t.Errorf("unquotePO(%s) = %q, %v, want %q", quoted, got, err, value)
+		}
+	}
+	if got, _ := unquotePO(`"\a\b\f\v\?"`); got != "\a\b\f\v?" {
+		t.Errorf("unquotePO = %q", got)
+	}
+	if _, err := unquotePO(`"unterminated`); err == nil {
+		t.Error("unquoted a string without its closing quote")
+	}
+}
+
+func TestMarkFuzzy(t *testing.T) {
+	for _, test := range []struct {
+		comments, want []string
+	}{
+		{nil, []string{"#, fuzzy"}},
+		{[]string{"#: main.go:1", "#, c-format"}, []string{"#: main.go:1", "#, fuzzy, c-format"}},
+		{[]string{"#, fuzzy, c-format"}, []string{"#, fuzzy, c-format"}},
+		{[]string{"#: main.go:1", `#| msgid "Old"`}, []string{"#: main.go:1", "#, fuzzy", `#| msgid "Old"`}},
+	} {
+		entry := poEntry{Comments: append([]string{}, test.comments...)}
+		markFuzzy(&entry)
+		if !reflect.DeepEqual(entry.Comments, test.want) {
+			t.Errorf("markFuzzy(%q) = %q, want %q", test.comments, entry.Comments, test.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// posStyles are the ways of writing the part-of-speech tags of --pos: inline
+// as word/TAG, or as an array of tagged tokens.
+var posStyles = []string{"inline", "array"}
+
+// taggedToken is a word of a section along with its universal
+// part-of-speech tag, such as NOUN or VERB.
+type taggedToken struct {
+	Token string `json:"token" yaml:"token"`
+	Tag   string `json:"tag" yaml:"tag"`
+}
+
+// checkPOSStyle reports whether style is one of posStyles, or empty when
+// --pos is not set.
+func checkPOSStyle(style string) error {
+	if style == "" {
+		return nil
+	}
+	for _, known := range posStyles {
+		if style == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported pos style %q (expected %s)", style, strings.Join(posStyles, " or "))
+}
+
+// tagPartsOfSpeech asks the LLM for the universal part-of-speech tag of
+// every word of section.
+func tagPartsOfSpeech(ctx context.Context, section string, opts analysisOptions) ([]taggedToken, error) {
+	req, err := renderPrompt(defaultPOSPrompt, opts.translateModel, promptData{Text: section, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return nil, err
+	}
+	var answer struct {
+		Tokens []taggedToken `json:"tokens"`
+	}
+	if err := generateJSON(ctx, opts, req, &answer); err != nil {
+		return nil, fmt.Errorf("tagging the parts of speech of %q: %w", section, err)
+	}
+	tokens := make([]taggedToken, 0, len(answer.Tokens))
+	for _, token := range answer.Tokens {
+		token.Token = strings.TrimSpace(token.Token)
+		token.Tag = strings.ToUpper(strings.TrimSpace(token.Tag))
+		if token.Token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// formatTaggedTokens writes tokens inline, such as "Die/DET Kinder/NOUN".
+func formatTaggedTokens(tokens []taggedToken) string {
+	parts := make([]string, len(tokens))
+	for i, token := range tokens {
+		parts[i] = token.Token + "/" + token.Tag
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth   = 24
+	progressPreviewLen = 30
+)
+
+// progressBar renders translation progress on stderr. A nil *progressBar is
+// valid and renders nothing, which is how progress is disabled.
+type progressBar struct {
+	mu      sync.Mutex
+	out     *os.File
+	total   int
+	done    int
+	current string
+	start   time.Time
+}
+
+// newProgressBar returns a progress bar writing to stderr, or nil when it is
+// disabled or stderr is not a terminal.
+func newProgressBar(disabled bool) *progressBar {
+	if disabled || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return &progressBar{out: os.Stderr}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start resets the bar for a text divided into total sections.
+func (p *progressBar) Start(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total, p.done, p.current, p.start = total, 0, "", time.Now()
+	p.render()
+}
+
+// Begin marks section as the one currently being translated.
+func (p *progressBar) Begin(section string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current = section
+	p.render()
+}
+
+// Advance records a translated section.
+func (p *progressBar) Advance() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.render()
+}
+
+// Suspend clears the bar while fn writes to the terminal and redraws it
+// afterwards, so streamed output doesn't get mixed with the bar.
+func (p *progressBar) Suspend(fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.out, "\r\033[K")
+	err := fn()
+	p.render()
+	return err
+}
+
+// Finish clears the bar so that later output starts on a clean line.
+func (p *progressBar) Finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.out, "\r\033[K")
+}
+
+func (p *progressBar) render() {
+	filled := 0
+	if p.total > 0 {
+		filled = progressBarWidth * p.done / p.total
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	eta := "--"
+	if p.done > 0 && p.done < p.total {
+		elapsed := time.Since(p.start)
+		eta = (elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)).Round(time.Second).String()
+	} else if p.done == p.total {
+		eta = "0s"
+	}
+
+	fmt.Fprintf(p.out, "\r\033[K[%s] %d/%d ETA %s %s", bar, p.done, p.total, eta, preview(p.current))
+}
+
+// preview shortens a section to fit on the progress line.
+func preview(section string) string {
+	section = strings.Join(strings.Fields(section), " ")
+	if section == "" {
+		return ""
+	}
+	runes := []rune(section)
+	if len(runes) > progressPreviewLen {
+		section = string(runes[:progressPreviewLen-1]) + "…"
+	}
+	return fmt.Sprintf("%q", section)
+}
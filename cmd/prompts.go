@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// promptData is the data available to prompt templates: the fields of
+// analyze.PromptData, the segmentation and translation prompts are executed
+// with, followed by the ones of the prompts of the other commands.
+type promptData struct {
+	// Text is the whole text when segmenting, or a single section when
+	// translating.
+	Text string
+	// Language is the translation language locale.
+	Language string
+	// SourceLanguage is the BCP 47 tag of the language the text is written
+	// in, either given with --source-language or detected.
+	SourceLanguage string
+	// Glossary holds the required translations of the terms found in Text.
+	Glossary []glossaryTerm
+	// Granularity, MinWords and MaxWords are the segmentation controls, zero
+	// when not set. Guidance describes them, or the length of a summary, as
+	// a sentence for the prompt.
+	Granularity string
+	MinWords    int
+	MaxWords    int
+	Guidance    string
+	// Scheme describes the romanization scheme when transliterating.
+	Scheme string
+	// Count and Level are the number of example sentences written and the
+	// CEFR level they are written for, empty when any level will do. Count
+	// is also the length in characters a caption is shortened to.
+	Count int
+	Level string
+}
+
+// promptTemplate renders the request of a pipeline stage; templates loaded
+// from a file render the whole request as the prompt.
+type promptTemplate = analyze.Prompt
+
+var (
+	defaultCombinedPrompt = promptTemplate{
+		System: template.Must(template.New("combined").Parse("Divide the given text into small sections, each representing a particular thought or idea, and translate each section to the requested language. Use grammar as a basis and avoid creating a section with a single word. You can break a phrase into subject and predicate.{{with .Guidance}} {{.}}{{end}}" + analyze.GlossaryInstruction + "\n\nProvide only the JSON array of objects with \"source\" and \"translation\" keys as the output without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Translate to en-US:\n\nHey, kannst du mir den heutigen Mittagsmenü schicken? Ich bin gerade total eingebunden bei der Arbeit.",
+			Output: "[\n    {\"source\": \"Hey\", \"translation\": \"Hey\"},\n    {\"source\": \"kannst du mir\", \"translation\": \"can you\"},\n    {\"source\": \"den heutigen Mittagsmenü schicken?\", \"translation\": \"send me today's lunch menu?\"},\n    {\"source\": \"Ich bin gerade\", \"translation\": \"I am currently\"},\n    {\"source\": \"total eingebunden\", \"translation\": \"completely tied up\"},\n    {\"source\": \"bei der Arbeit.\", \"translation\": \"at work.\"}\n]",
+		}},
+		Template: template.Must(template.New("combined text").Parse("Translate to {{.Language}}:\n\n{{.Text}}")),
+	}
+	defaultVocabPrompt = promptTemplate{
+		System: template.Must(template.New("vocab").Parse("List the content words of the given sentence, written in {{.SourceLanguage}}: its nouns, proper nouns, verbs, adjectives and adverbs, leaving out articles, pronouns, prepositions, conjunctions and particles. Give each word as it appears, its dictionary form, its part of speech as a Universal Dependencies tag (NOUN, PROPN, VERB, ADJ or ADV) and its translation to {{.Language}} in the dictionary form.\n\nProvide only the JSON array of objects with \"word\", \"lemma\", \"pos\" and \"translation\" keys as the output without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Die Kinder spielten gestern im großen Garten.",
+			Output: "[\n    {\"word\": \"Kinder\", \"lemma\": \"Kind\", \"pos\": \"NOUN\", \"translation\": \"child\"},\n    {\"word\": \"spielten\", \"lemma\": \"spielen\", \"pos\": \"VERB\", \"translation\": \"to play\"},\n    {\"word\": \"gestern\", \"lemma\": \"gestern\", \"pos\": \"ADV\", \"translation\": \"yesterday\"},\n    {\"word\": \"großen\", \"lemma\": \"groß\", \"pos\": \"ADJ\", \"translation\": \"big\"},\n    {\"word\": \"Garten\", \"lemma\": \"Garten\", \"pos\": \"NOUN\", \"translation\": \"garden\"}\n]",
+		}},
+		Template: template.Must(template.New("vocab text").Parse("{{.Text}}")),
+	}
+	defaultLemmaPrompt = promptTemplate{
+		System: template.Must(template.New("lemma").Parse("Give the lemma, the dictionary form, of each of the given {{.SourceLanguage}} words, which are one per line and in lower case: the infinitive of verbs, the singular of nouns and the base form of adjectives. Write the lemmas in lower case, unless the language capitalizes them, and a word that is its own lemma as it is.\n\nProvide only the JSON object with the given words as keys and their lemmas as values as the output, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "häuser\nging\nschönen\nund",
+			Output: "{\"häuser\": \"Haus\", \"ging\": \"gehen\", \"schönen\": \"schön\", \"und\": \"und\"}",
+		}},
+		Template: template.Must(template.New("lemma text").Parse("{{.Text}}")),
+		Schema:   json.RawMessage(`{"type": "object", "additionalProperties": {"type": "string"}}`),
+	}
+	defaultNERPrompt = promptTemplate{
+		System: template.Must(template.New("ner").Parse("Find the named entities of the given {{.SourceLanguage}} text: the people, places, organizations and dates it mentions. List every mention in the order it appears, written exactly as in the text, with its type: person, place, organization or date.\n\nProvide only the JSON object with an \"entities\" key as the output, an array of objects with \"text\" and \"type\" keys, and empty when the text mentions none, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Am 3. Mai besuchte Angela Merkel die Siemens AG in München.",
+			Output: "{\"entities\": [\n    {\"text\": \"3. Mai\", \"type\": \"date\"},\n    {\"text\": \"Angela Merkel\", \"type\": \"person\"},\n    {\"text\": \"Siemens AG\", \"type\": \"organization\"},\n    {\"text\": \"München\", \"type\": \"place\"}\n]}",
+		}},
+		Template: template.Must(template.New("ner text").Parse("{{.Text}}")),
+		Schema:   json.RawMessage(`{"type": "object", "properties": {"entities": {"type": "array", "items": {"type": "object", "properties": {"text": {"type": "string"}, "type": {"type": "string", "enum": ["person", "place", "organization", "date"]}}, "required": ["text", "type"]}}}, "required": ["entities"]}`),
+	}
+	defaultMorphPrompt = promptTemplate{
+		System: template.Must(template.New("morph").Parse("Analyze the morphology of the given {{.SourceLanguage}} sentence: give every word in order, leaving out the punctuation, with its lemma (the dictionary form), its universal part-of-speech tag (NOUN, VERB, ADJ, ADV, PRON, DET, ADP, AUX, CCONJ, SCONJ, NUM, PART, INTJ, PROPN or X), and its morphological features as Universal Dependencies names and values, such as Tense=Past, Person=3, Case=Dat, Number=Plur, Gender=Masc or Mood=Ind, leaving out the features the word doesn't have.\n\nProvide only the JSON object with a \"words\" key as the output, an array of objects with \"word\", \"lemma\", \"pos\" and \"features\" keys, where \"features\" is an object of strings, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Die Kinder spielten im Garten.",
+			Output: "{\"words\": [\n    {\"word\": \"Die\", \"lemma\": \"der\", \"pos\": \"DET\", \"features\": {\"Case\": \"Nom\", \"Definite\": \"Def\", \"Number\": \"Plur\"}},\n    {\"word\": \"Kinder\", \"lemma\": \"Kind\", \"pos\": \"NOUN\", \"features\": {\"Case\": \"Nom\", \"Gender\": \"Neut\", \"Number\": \"Plur\"}},\n    {\"word\": \"spielten\", \"lemma\": \"spielen\", \"pos\": \"VERB\", \"features\": {\"Mood\": \"Ind\", \"Number\": \"Plur\", \"Person\": \"3\", \"Tense\": \"Past\"}},\n    {\"word\": \"im\", \"lemma\": \"in\", \"pos\": \"ADP\", \"features\": {\"Case\": \"Dat\", \"Gender\": \"Masc\", \"Number\": \"Sing\"}},\n    {\"word\": \"Garten\", \"lemma\": \"Garten\", \"pos\": \"NOUN\", \"features\": {\"Case\": \"Dat\", \"Gender\": \"Masc\", \"Number\": \"Sing\"}}\n]}",
+		}},
+		Template: template.Must(template.New("morph text").Parse("{{.Text}}")),
+		Schema:   json.RawMessage(`{"type": "object", "properties": {"words": {"type": "array", "items": {"type": "object", "properties": {"word": {"type": "string"}, "lemma": {"type": "string"}, "pos": {"type": "string"}, "features": {"type": "object", "additionalProperties": {"type": "string"}}}, "required": ["word", "lemma", "pos", "features"]}}}, "required": ["words"]}`),
+	}
+	defaultPOSPrompt = promptTemplate{
+		System: template.Must(template.New("pos").Parse("Tag every word of the given {{.SourceLanguage}} text, in order and leaving out the punctuation, with its universal part-of-speech tag: NOUN, VERB, ADJ, ADV, PRON, DET, ADP, AUX, CCONJ, SCONJ, NUM, PART, INTJ, PROPN or X.\n\nProvide only the JSON object with a \"tokens\" key as the output, an array of objects with \"token\" and \"tag\" keys, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Die Kinder spielten im Garten.",
+			Output: "{\"tokens\": [\n    {\"token\": \"Die\", \"tag\": \"DET\"},\n    {\"token\": \"Kinder\", \"tag\": \"NOUN\"},\n    {\"token\": \"spielten\", \"tag\": \"VERB\"},\n    {\"token\": \"im\", \"tag\": \"ADP\"},\n    {\"token\": \"Garten\", \"tag\": \"NOUN\"}\n]}",
+		}},
+		Template: template.Must(template.New("pos text").Parse("{{.Text}}")),
+		Schema:   json.RawMessage(`{"type": "object", "properties": {"tokens": {"type": "array", "items": {"type": "object", "properties": {"token": {"type": "string"}, "tag": {"type": "string"}}, "required": ["token", "tag"]}}}, "required": ["tokens"]}`),
+	}
+	defaultGlossPrompt = promptTemplate{
+		System: template.Must(template.New("gloss").Parse("Gloss the given {{.SourceLanguage}} sentence word by word following the Leipzig Glossing Rules: give every word of the sentence in order, leaving out the punctuation, with its literal translation to {{.Language}} and the abbreviations of its grammatical categories, separated by hyphens for morphemes and periods for categories expressed together (such as child-PL or the.DEF.PL). Then translate the whole sentence freely to {{.Language}}.\n\nProvide only the JSON object with \"tokens\" and \"translation\" keys as the output, where \"tokens\" is an array of objects with \"token\" and \"gloss\" keys, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Die Kinder spielten im Garten.",
+			Output: "{\"tokens\": [\n    {\"token\": \"Die\", \"gloss\": \"the.DEF.NOM.PL\"},\n    {\"token\": \"Kinder\", \"gloss\": \"child-PL\"},\n    {\"token\": \"spielten\", \"gloss\": \"play-PST.3PL\"},\n    {\"token\": \"im\", \"gloss\": \"in.the.DEF.DAT.SG\"},\n    {\"token\": \"Garten\", \"gloss\": \"garden\"}\n], \"translation\": \"The children were playing in the garden.\"}",
+		}},
+		Template: template.Must(template.New("gloss text").Parse("{{.Text}}")),
+	}
+	defaultConjugatePrompt = promptTemplate{
+		System: template.Must(template.New("conjugate").Parse("Conjugate the given {{.SourceLanguage}} verb in every tense and mood of the language, for every grammatical person, and translate its infinitive to {{.Language}}. Name the tenses and moods as the grammars of the language do.\n\nProvide only the JSON object with \"infinitive\", \"translation\" and \"tenses\" keys as the output, where \"tenses\" is an array of objects with \"mood\", \"tense\" and \"forms\" keys and \"forms\" an array of objects with \"person\" and \"form\" keys, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "gehen",
+			Output: "{\"infinitive\": \"gehen\", \"translation\": \"to go\", \"tenses\": [\n    {\"mood\": \"Indikativ\", \"tense\": \"Präsens\", \"forms\": [{\"person\": \"ich\", \"form\": \"gehe\"}, {\"person\": \"du\", \"form\": \"gehst\"}, {\"person\": \"er/sie/es\", \"form\": \"geht\"}, {\"person\": \"wir\", \"form\": \"gehen\"}, {\"person\": \"ihr\", \"form\": \"geht\"}, {\"person\": \"sie/Sie\", \"form\": \"gehen\"}]},\n    {\"mood\": \"Indikativ\", \"tense\": \"Perfekt\", \"forms\": [{\"person\": \"ich\", \"form\": \"bin gegangen\"}, {\"person\": \"du\", \"form\": \"bist gegangen\"}, {\"person\": \"er/sie/es\", \"form\": \"ist gegangen\"}, {\"person\": \"wir\", \"form\": \"sind gegangen\"}, {\"person\": \"ihr\", \"form\": \"seid gegangen\"}, {\"person\": \"sie/Sie\", \"form\": \"sind gegangen\"}]}\n]}",
+		}},
+		Template: template.Must(template.New("conjugate verb").Parse("{{.Text}}")),
+	}
+	defaultDefinePrompt = promptTemplate{
+		System: template.Must(template.New("define").Parse("Write the dictionary entry of the given {{.SourceLanguage}} word: its dictionary form, its part of speech, its grammatical gender and plural form when the language has them, and its register (such as neutral, formal, informal, slang or archaic). List its most common senses, each with a definition written in {{.SourceLanguage}}, the translation of the word in that sense to {{.Language}} and two or three example sentences in {{.SourceLanguage}} with their translations to {{.Language}}.\n\nProvide only the JSON object with \"lemma\", \"part_of_speech\", \"gender\", \"plural\", \"register\" and \"senses\" keys as the output, where \"senses\" is an array of objects with \"definition\", \"translation\" and \"examples\" keys and \"examples\" an array of objects with \"text\" and \"translation\" keys, without any additional text or explanation. Leave out the keys that don't apply.")),
+		Examples: []llm.Example{{
+			Input:  "Häuser",
+			Output: "{\"lemma\": \"Haus\", \"part_of_speech\": \"noun\", \"gender\": \"neuter\", \"plural\": \"Häuser\", \"register\": \"neutral\", \"senses\": [\n    {\"definition\": \"Gebäude, in dem Menschen wohnen\", \"translation\": \"house\", \"examples\": [{\"text\": \"Wir haben ein Haus am See gekauft.\", \"translation\": \"We bought a house by the lake.\"}, {\"text\": \"Das Haus hat drei Stockwerke.\", \"translation\": \"The house has three floors.\"}]},\n    {\"definition\": \"Familie oder Haushalt\", \"translation\": \"household\", \"examples\": [{\"text\": \"Er kommt aus gutem Hause.\", \"translation\": \"He comes from a good family.\"}, {\"text\": \"Das ganze Haus schlief schon.\", \"translation\": \"The whole household was already asleep.\"}]}\n]}",
+		}},
+		Template: template.Must(template.New("define word").Parse("{{.Text}}")),
+	}
+	defaultGrammarCheckPrompt = promptTemplate{
+		System: template.Must(template.New("grammar check").Parse("You are a {{.SourceLanguage}} teacher correcting the writing of a learner. Find every grammar, spelling and word choice mistake of the given {{.SourceLanguage}} text and correct it, changing as little as possible and nothing that is right. For every mistake give the words as written, their correction, its category, one of agreement, word_order, case, conjugation, tense, spelling, punctuation, vocabulary or other, and a short explanation in {{.Language}}. Then give the whole corrected text.\n\nProvide only the JSON object with \"issues\" and \"corrected\" keys as the output, where \"issues\" is an array of objects with \"original\", \"correction\", \"category\" and \"explanation\" keys, and is empty when there are no mistakes, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Gestern ich habe mit mein Bruder im groß Park gespielt.",
+			Output: "{\"issues\": [\n    {\"original\": \"ich habe\", \"correction\": \"habe ich\", \"category\": \"word_order\", \"explanation\": \"The verb comes second in a main clause, right after Gestern.\"},\n    {\"original\": \"mit mein Bruder\", \"correction\": \"mit meinem Bruder\", \"category\": \"case\", \"explanation\": \"mit is followed by the dative case.\"},\n    {\"original\": \"im groß Park\", \"correction\": \"im großen Park\", \"category\": \"agreement\", \"explanation\": \"The adjective takes the ending of the masculine dative after im.\"}\n], \"corrected\": \"Gestern habe ich mit meinem Bruder im großen Park gespielt.\"}",
+		}},
+		Template: template.Must(template.New("grammar check text").Parse("{{.Text}}")),
+	}
+	defaultExplainPrompt = promptTemplate{
+		System:   template.Must(template.New("explain").Parse("You are a {{.SourceLanguage}} grammar tutor. Explain in {{.Language}}, to a learner of {{.SourceLanguage}}, why the given text is built the way it is: the cases and the reason for each, the position of the verbs, the tenses, moods and conjugations used, and any agreement or construction worth noticing. Be brief and concrete, quoting the words you explain.\n\nProvide only the explanation, as a single paragraph, without any additional text.")),
+		Template: template.Must(template.New("explain text").Parse("{{.Text}}")),
+	}
+	defaultSummarizePrompt = promptTemplate{
+		System:   template.Must(template.New("summarize").Parse("Summarize the given {{.SourceLanguage}} text in {{.Language}} for a reader who doesn't understand it yet, keeping its main points and leaving out the details. {{.Guidance}}\n\nProvide only the summary without any additional text or explanation.")),
+		Template: template.Must(template.New("summarize text").Parse("{{.Text}}")),
+	}
+	defaultSynonymsPrompt = promptTemplate{
+		System: template.Must(template.New("synonyms").Parse("List the key content words of the given {{.SourceLanguage}} text, or the word itself when the text is a single word, each with its common synonyms and antonyms in {{.SourceLanguage}}, in the sense it has in the text. Leave out the words without any.\n\nProvide only the JSON array of objects with \"word\", \"synonyms\" and \"antonyms\" keys as the output, where \"synonyms\" and \"antonyms\" are arrays of strings, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Das Wetter ist heute schön.",
+			Output: "[{\"word\": \"schön\", \"synonyms\": [\"herrlich\", \"angenehm\"], \"antonyms\": [\"schlecht\", \"scheußlich\"]}, {\"word\": \"heute\", \"synonyms\": [\"an diesem Tag\"], \"antonyms\": []}]",
+		}},
+		Template: template.Must(template.New("synonyms text").Parse("{{.Text}}")),
+	}
+	defaultExamplesPrompt = promptTemplate{
+		System:   template.Must(template.New("examples").Parse("Write {{.Count}} new example sentences in {{.SourceLanguage}} using the given word, which comes with its part of speech and its meaning, {{if .Level}}simple enough for a learner at CEFR level {{.Level}}, {{end}}each showing a different, common use of the word in that meaning, along with their translations to {{.Language}}.\n\nProvide only the JSON array of objects with \"text\" and \"translation\" keys as the output without any additional text or explanation.")),
+		Template: template.Must(template.New("examples word").Parse("{{.Text}}")),
+	}
+	defaultClozePrompt = promptTemplate{
+		System: template.Must(template.New("cloze").Parse("Pick the key vocabulary word of the given {{.SourceLanguage}} text, the content word most worth learning, copied exactly as it is written in the text, and write a short hint for it in {{.Language}}, such as its translation, without giving the word away.\n\nProvide only the JSON object with \"word\" and \"hint\" keys as the output without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Wir haben gestern einen langen Spaziergang gemacht.",
+			Output: "{\"word\": \"Spaziergang\", \"hint\": \"walk (noun)\"}",
+		}},
+		Template: template.Must(template.New("cloze text").Parse("{{.Text}}")),
+	}
+	defaultDifficultyPrompt = promptTemplate{
+		System:   template.Must(template.New("difficulty").Parse("Estimate the CEFR level (A1, A2, B1, B2, C1 or C2) a learner of {{.SourceLanguage}} needs to understand the given text, judging by its vocabulary and its grammar.\n\nProvide only the level without any additional text or explanation.")),
+		Template: template.Must(template.New("difficulty text").Parse("{{.Text}}")),
+	}
+	defaultIPAPrompt = promptTemplate{
+		System: template.Must(template.New("ipa").Parse("Transcribe the given {{.SourceLanguage}} text into the International Phonetic Alphabet as it is pronounced in the standard variety of the language, marking the primary stress of every word with ˈ and the long sounds with ː. Use only IPA symbols and don't enclose the transcription in slashes or brackets.\n\nProvide only the transcription without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Guten Morgen",
+			Output: "ˈɡuːtn̩ ˈmɔʁɡn̩",
+		}},
+		Template: template.Must(template.New("ipa text").Parse("{{.Text}}")),
+	}
+	defaultFuriganaPrompt = promptTemplate{
+		System: template.Must(template.New("furigana").Parse("Divide the given Japanese text into segments so that the kanji of every word are a segment of their own, with the kana following them (their okurigana) in the next segment, and give the reading in hiragana of every segment with kanji, as it is read in this text. Keep every character of the text in order, including kana, punctuation and spaces, so that joining the segments gives back the text.\n\nProvide only the JSON array of objects with \"text\" and \"reading\" keys as the output, where \"reading\" is empty for the segments without kanji, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "日本語を勉強しています。",
+			Output: "[{\"text\": \"日本語\", \"reading\": \"にほんご\"}, {\"text\": \"を\", \"reading\": \"\"}, {\"text\": \"勉強\", \"reading\": \"べんきょう\"}, {\"text\": \"しています。\", \"reading\": \"\"}]",
+		}},
+		Template: template.Must(template.New("furigana text").Parse("{{.Text}}")),
+	}
+	defaultTransliteratePrompt = promptTemplate{
+		System:   template.Must(template.New("transliterate").Parse("Transliterate the given {{.SourceLanguage}} text into Latin script using {{.Scheme}}. Transliterate every word, keep the punctuation, the numbers and the words already written in Latin script as they are, and don't translate anything.\n\nProvide only the transliteration without any additional text or explanation.")),
+		Template: template.Must(template.New("transliterate text").Parse("{{.Text}}")),
+	}
+	defaultAlignPrompt = promptTemplate{
+		System: template.Must(template.New("align").Parse("Align the numbered sentences of the given {{.SourceLanguage}} text with the numbered sentences of its {{.Language}} translation, which may have split, merged, left out or added sentences. Group every sentence with the ones it is translated by: a group has one or more consecutive source sentences and the consecutive translation sentences that translate them, or none when they were left out. Keep the groups in order, and put every sentence in exactly one group.\n\nProvide only the JSON array of objects with \"source\" and \"translation\" keys as the output, the arrays of the numbers of the sentences of each group, without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Source:\n1. Ich bin müde.\n2. Es war ein langer Tag, und ich gehe jetzt schlafen.\n3. Gute Nacht!\n\nTranslation:\n1. I'm tired.\n2. It was a long day.\n3. I'm going to bed now.\n4. Good night!",
+			Output: "[{\"source\": [1], \"translation\": [1]}, {\"source\": [2], \"translation\": [2, 3]}, {\"source\": [3], \"translation\": [4]}]",
+		}},
+		Template: template.Must(template.New("align text").Parse("{{.Text}}")),
+	}
+	defaultShortenCaptionPrompt = promptTemplate{
+		System:   template.Must(template.New("shorten caption").Parse("Shorten the given {{.Language}} subtitle caption to at most {{.Count}} characters so that it can be read in the time it is shown, keeping its meaning, its tone and any formatting tags. Leave out what the viewer can do without, such as repetitions, fillers and names of people being spoken to.\n\nProvide only the shortened caption without any additional text or explanation.")),
+		Template: template.Must(template.New("shorten caption text").Parse("{{.Text}}")),
+	}
+)
+
+// loadPromptTemplate parses the Go text/template in path, or returns fallback
+// when path is empty.
+func loadPromptTemplate(path string, fallback promptTemplate) (promptTemplate, error) {
+	if path == "" {
+		return fallback, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return promptTemplate{}, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return promptTemplate{}, fmt.Errorf("parsing prompt template: %w", err)
+	}
+	// Catch references to unknown fields before any request is made.
+	if err := tmpl.Execute(io.Discard, promptData{}); err != nil {
+		return promptTemplate{}, fmt.Errorf("checking prompt template: %w", err)
+	}
+	return promptTemplate{Template: tmpl}, nil
+}
+
+// renderPrompt executes a prompt template with data, returning the request
+// for model.
+func renderPrompt(tmpl promptTemplate, model string, data promptData) (llm.Request, error) {
+	return tmpl.Render(model, data)
+}
@@ -0,0 +1,540 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// providerSpec describes a --provider.
+type providerSpec struct {
+	// title names the provider in messages.
+	title string
+	// host is the default --llm-host.
+	host  string
+	model string
+	// apiKeyEnv lists the environment variables holding the API key, if
+	// the provider needs one.
+	apiKeyEnv []string
+	// optionalKey is set for the providers working with and without a key.
+	optionalKey bool
+	// paid is set for the providers charging for the tokens, see
+	// modelPrices.
+	paid bool
+}
+
+var providers = map[string]providerSpec{
+	"ollama": {
+		title: "Ollama",
+		host:  "http://localhost:11434/api/generate",
+		model: defaultModel,
+	},
+	"openai": {
+		title:     "OpenAI",
+		host:      "https://api.openai.com/v1",
+		model:     "gpt-4o-mini",
+		apiKeyEnv: []string{"STARTER_GO_CLI_OPENAI_API_KEY", "OPENAI_API_KEY"},
+		paid:      true,
+	},
+	"openai-compatible": {
+		title:       "OpenAI-compatible server",
+		apiKeyEnv:   []string{"STARTER_GO_CLI_OPENAI_COMPATIBLE_API_KEY"},
+		optionalKey: true,
+	},
+	"azure-openai": {
+		title:     "Azure OpenAI",
+		model:     "gpt-4o-mini",
+		apiKeyEnv: []string{"STARTER_GO_CLI_AZURE_OPENAI_API_KEY", "AZURE_OPENAI_API_KEY"},
+		paid:      true,
+	},
+	"bedrock": {
+		title: "AWS Bedrock",
+		model: "anthropic.claude-3-haiku-20240307-v1:0",
+		paid:  true,
+	},
+	"llamacpp": {
+		title: "llama.cpp",
+		host:  "http://localhost:8080",
+		// The server runs a single model; the name only tells cached
+		// responses apart.
+		model: "local",
+	},
+	"gemini": {
+		title:     "Gemini",
+		host:      "https://generativelanguage.googleapis.com/v1beta",
+		model:     "gemini-1.5-flash",
+		apiKeyEnv: []string{"STARTER_GO_CLI_GEMINI_API_KEY", "GEMINI_API_KEY"},
+		paid:      true,
+	},
+}
+
+// translators are the --translator services other than "llm", which
+// translates with the --provider.
+var translators = map[string]providerSpec{
+	"deepl": {
+		title:     "DeepL",
+		apiKeyEnv: []string{"STARTER_GO_CLI_DEEPL_API_KEY", "DEEPL_API_KEY"},
+	},
+	"google": {
+		title: "Google Cloud Translation",
+	},
+}
+
+// googleTranslateScope is the OAuth2 scope requested from the Application
+// Default Credentials for --translator google.
+const googleTranslateScope = "https://www.googleapis.com/auth/cloud-translation"
+
+// resolveProvider returns the name of the selected --provider.
+func resolveProvider(cmd *cobra.Command) (string, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return "", err
+	}
+	name, err := resolveSetting(cmd, "provider", "STARTER_GO_CLI_PROVIDER", cfg.Provider, "ollama")
+	if err != nil {
+		return "", fmt.Errorf("retrieving provider flag: %w", err)
+	}
+	if _, ok := providers[name]; !ok {
+		return "", fmt.Errorf("unsupported provider %q (supported: %s)", name, strings.Join(supportedProviders(), ", "))
+	}
+	return name, nil
+}
+
+func supportedProviders() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveAPIKey returns the API key of the provider or translator name from
+// its environment variables, the OS keyring or the api_keys of the config
+// file.
+func resolveAPIKey(cfg *config, name string, spec providerSpec) (string, error) {
+	if len(spec.apiKeyEnv) == 0 {
+		return cfg.APIKeys[name], nil
+	}
+	for _, env := range spec.apiKeyEnv {
+		if key := os.Getenv(env); key != "" {
+			return key, nil
+		}
+	}
+	if key := keyringAPIKey(name); key != "" {
+		return key, nil
+	}
+	if key := cfg.APIKeys[name]; key != "" {
+		return key, nil
+	}
+	if spec.optionalKey {
+		return "", nil
+	}
+	return "", fmt.Errorf("no API key for %s: set %s, run 'starter-go-cli auth set %s' or set api_keys.%s in the config file", spec.title, spec.apiKeyEnv[len(spec.apiKeyEnv)-1], name, name)
+}
+
+// newProvider returns the selected --provider, talking to host.
+func newProvider(cmd *cobra.Command, host string) (llm.Provider, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	name, err := resolveProvider(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return newNamedProvider(cmd, cfg, name, host)
+}
+
+// newNamedProvider returns the provider name, talking to host or to its
+// default host when empty.
+func newNamedProvider(cmd *cobra.Command, cfg *config, name, host string) (llm.Provider, error) {
+	client, err := httpClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if name == "bedrock" {
+		return newBedrockProvider(cmd, cfg, host, client)
+	}
+	if host == "" && name == "azure-openai" {
+		host = os.Getenv("AZURE_OPENAI_ENDPOINT")
+	}
+	if host == "" {
+		host = providers[name].host
+		if host == "" {
+			return nil, fmt.Errorf("%s has no default host: set --llm-host", providers[name].title)
+		}
+		fmt.Fprintf(os.Stderr, "Using default %s host: %s\n", providers[name].title, host)
+	}
+
+	apiKey, err := resolveAPIKey(cfg, name, providers[name])
+	if err != nil {
+		return nil, err
+	}
+	allowHeaders(host)
+
+	switch name {
+	case "gemini":
+		safetySettings, err := resolveSafetySettings(cmd, cfg)
+		if err != nil {
+			return nil, err
+		}
+		provider := llm.NewGemini(host, apiKey, safetySettings, stderrLogger{})
+		provider.Client = client
+		return provider, nil
+	case "azure-openai":
+		apiVersion, err := resolveSetting(cmd, "azure-api-version", "AZURE_OPENAI_API_VERSION", cfg.AzureAPIVersion, "2024-06-01")
+		if err != nil {
+			return nil, fmt.Errorf("retrieving azure-api-version flag: %w", err)
+		}
+		deployment, err := resolveSetting(cmd, "azure-deployment", "AZURE_OPENAI_DEPLOYMENT", cfg.AzureDeployment, "")
+		if err != nil {
+			return nil, fmt.Errorf("retrieving azure-deployment flag: %w", err)
+		}
+		provider := llm.NewAzureOpenAI(host, apiVersion, deployment, cfg.AzureDeployments, apiKey, stderrLogger{})
+		provider.Client = client
+		return provider, nil
+	case "llamacpp":
+		provider := llm.NewLlamaCpp(host, stderrLogger{})
+		provider.Client = client
+		return provider, nil
+	case "openai-compatible":
+		provider := llm.NewOpenAICompatible(host, apiKey, stderrLogger{})
+		provider.Client = client
+		return provider, nil
+	case "openai":
+		provider := llm.NewOpenAI(host, apiKey, stderrLogger{})
+		provider.Client = client
+		return provider, nil
+	default:
+		api, err := resolveSetting(cmd, "ollama-api", "STARTER_GO_CLI_OLLAMA_API", cfg.OllamaAPI, "chat")
+		if err != nil {
+			return nil, fmt.Errorf("retrieving ollama-api flag: %w", err)
+		}
+		if api != "chat" && api != "generate" {
+			return nil, fmt.Errorf("unsupported Ollama API %q (expected chat or generate)", api)
+		}
+		keepAlive, err := resolveKeepAlive(cmd, cfg)
+		if err != nil {
+			return nil, err
+		}
+		provider := llm.NewOllama(host, api == "chat", stderrLogger{})
+		provider.KeepAlive = keepAlive
+		provider.Client = client
+		return provider, nil
+	}
+}
+
+// newTranslator returns the selected --translator, along with the name
+// identifying its translations in the cache. The "llm" translator is
+// provider itself, identified by the translation model.
+func newTranslator(cmd *cobra.Command, provider llm.Provider) (llm.Translator, string, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	name, err := resolveSetting(cmd, "translator", "STARTER_GO_CLI_TRANSLATOR", cfg.Translator, "llm")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving translator flag: %w", err)
+	}
+	if name == "llm" {
+		return provider, "", nil
+	}
+	spec, ok := translators[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported translator %q (supported: llm, deepl, google)", name)
+	}
+
+	host, err := resolveSetting(cmd, "translator-host", "STARTER_GO_CLI_TRANSLATOR_HOST", cfg.TranslatorHost, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving translator-host flag: %w", err)
+	}
+	client, err := httpClient(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "google" {
+		return newGoogleTranslator(cmd, cfg, host, client)
+	}
+
+	apiKey, err := resolveAPIKey(cfg, name, spec)
+	if err != nil {
+		return nil, "", err
+	}
+	formality, err := resolveSetting(cmd, "formality", "STARTER_GO_CLI_FORMALITY", cfg.Formality, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving formality flag: %w", err)
+	}
+	switch formality {
+	case "", "default", "more", "less", "prefer_more", "prefer_less":
+	default:
+		return nil, "", fmt.Errorf("unsupported formality %q (expected default, more, less, prefer_more or prefer_less)", formality)
+	}
+
+	translator := llm.NewDeepL(host, apiKey, formality, stderrLogger{})
+	translator.Client = client
+	return translator, "deepl host=" + translator.BaseURL + " formality=" + formality, nil
+}
+
+// newGoogleTranslator returns the Cloud Translation translator,
+// authenticated with the Application Default Credentials. The project comes
+// from --google-project, GOOGLE_CLOUD_PROJECT, the config file or the
+// credentials.
+func newGoogleTranslator(cmd *cobra.Command, cfg *config, host string, client *http.Client) (llm.Translator, string, error) {
+	// The tokens are fetched through client as well.
+	ctx := context.WithValue(cmd.Context(), oauth2.HTTPClient, client)
+	credentials, err := google.FindDefaultCredentials(ctx, googleTranslateScope)
+	if err != nil {
+		return nil, "", fmt.Errorf("finding Google Application Default Credentials: %w", err)
+	}
+
+	project, err := resolveSetting(cmd, "google-project", "GOOGLE_CLOUD_PROJECT", cfg.GoogleProject, credentials.ProjectID)
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving google-project flag: %w", err)
+	}
+	if project == "" {
+		return nil, "", errors.New("no Google Cloud project: set --google-project or GOOGLE_CLOUD_PROJECT")
+	}
+	location, err := resolveSetting(cmd, "google-location", "STARTER_GO_CLI_GOOGLE_LOCATION", cfg.GoogleLocation, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving google-location flag: %w", err)
+	}
+	glossary, err := resolveSetting(cmd, "google-glossary", "STARTER_GO_CLI_GOOGLE_GLOSSARY", cfg.GoogleGlossary, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving google-glossary flag: %w", err)
+	}
+
+	token := func(context.Context) (string, error) {
+		t, err := credentials.TokenSource.Token()
+		if err != nil {
+			return "", err
+		}
+		return t.AccessToken, nil
+	}
+	translator := llm.NewGoogleTranslate(project, location, glossary, token, stderrLogger{})
+	translator.Client = client
+	if host != "" {
+		translator.BaseURL = host
+	}
+	return translator, "google host=" + translator.BaseURL + " glossary=" + translator.Glossary, nil
+}
+
+// resolveSafetySettings merges the safety_settings of the config file with
+// the --safety-setting flags, given as CATEGORY=THRESHOLD. The
+// HARM_CATEGORY_ prefix of the categories may be left out.
+func resolveSafetySettings(cmd *cobra.Command, cfg *config) (map[string]string, error) {
+	pairs, err := cmd.Flags().GetStringArray("safety-setting")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving safety-setting flag: %w", err)
+	}
+
+	settings := map[string]string{}
+	set := func(category, threshold string) {
+		category = strings.ToUpper(strings.TrimSpace(category))
+		if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+			category = "HARM_CATEGORY_" + category
+		}
+		settings[category] = strings.ToUpper(strings.TrimSpace(threshold))
+	}
+	for category, threshold := range cfg.SafetySettings {
+		set(category, threshold)
+	}
+	for _, pair := range pairs {
+		category, threshold, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(category) == "" || strings.TrimSpace(threshold) == "" {
+			return nil, fmt.Errorf("invalid safety setting %q (expected CATEGORY=THRESHOLD)", pair)
+		}
+		set(category, threshold)
+	}
+	return settings, nil
+}
+
+// stderrLogger forwards the diagnostics of the providers to --verbose and
+// --debug.
+type stderrLogger struct{}
+
+func (stderrLogger) Verbosef(format string, a ...interface{}) { verbosef(format, a...) }
+func (stderrLogger) Debugf(format string, a ...interface{})   { debugf(format, a...) }
+
+// streamWriter returns where streamed tokens are echoed, nil unless --stream.
+func streamWriter(opts analysisOptions) io.Writer {
+	if opts.stream {
+		return os.Stderr
+	}
+	return nil
+}
+
+// generate sends req through opts.provider and returns the model's
+// response.
+func generate(ctx context.Context, opts analysisOptions, req llm.Request) (string, error) {
+	req.Options = opts.generationOptions
+	req.Stream = streamWriter(opts)
+	key := func() string { return requestCacheKey(opts, req) }
+	return callProvider(ctx, opts, "generate", req.Model, key, func(ctx context.Context) (string, error) {
+		name, model := activeProvider(opts, req.Model)
+		return opts.usage.meterGeneration(name, model, req.Flatten(), func(usage *llm.Usage) (string, error) {
+			req.Usage = usage
+			return opts.provider.Generate(ctx, req)
+		})
+	})
+}
+
+// requestCacheKey returns the cache key of req sent to the active provider,
+// with the model the provider receives it for.
+func requestCacheKey(opts analysisOptions, req llm.Request) string {
+	name, host := opts.providerName, orDefaultHost(opts.providerName, opts.llmHost)
+	if chain, ok := opts.provider.(*failoverProvider); ok {
+		target := chain.active()
+		name, host, req.Model = target.name, target.host, target.modelFor(req.Model)
+	}
+	return cacheKey(name, host, req)
+}
+
+// activeProvider returns the name of the provider receiving the requests
+// for model, and the model it receives them for, which differ from the
+// --provider and model once the failover chain moved on.
+func activeProvider(opts analysisOptions, model string) (string, string) {
+	if chain, ok := opts.provider.(*failoverProvider); ok {
+		target := chain.active()
+		return target.name, target.modelFor(model)
+	}
+	return opts.providerName, model
+}
+
+// translate asks opts.translator for the translation of text, sending prompt
+// as the request to LLMs.
+func translate(ctx context.Context, opts analysisOptions, text, from, to string, prompt llm.Request) (string, error) {
+	prompt.Options = opts.generationOptions
+	// The dedicated translators are told apart by their translatorKey,
+	// which names their service and host.
+	key := func() string {
+		if opts.translatorKey != "" {
+			keyed := prompt
+			keyed.Model = opts.translatorKey
+			return cacheKey("", "", keyed)
+		}
+		return requestCacheKey(opts, prompt)
+	}
+	req := llm.TranslateRequest{
+		Model:    prompt.Model,
+		Text:     text,
+		From:     from,
+		To:       to,
+		System:   prompt.System,
+		Examples: prompt.Examples,
+		Prompt:   prompt.Prompt,
+		Options:  prompt.Options,
+		Stream:   streamWriter(opts),
+	}
+	model := prompt.Model
+	if opts.translatorKey != "" {
+		model = opts.translatorKey
+	}
+	return callProvider(ctx, opts, "translate", model, key, func(ctx context.Context) (string, error) {
+		if opts.translatorKey != "" {
+			// The key starts with the name of the service.
+			name, _, _ := strings.Cut(opts.translatorKey, " ")
+			return opts.usage.meterTranslation(name, text, func() (string, error) {
+				return opts.translator.Translate(ctx, req)
+			})
+		}
+		name, model := activeProvider(opts, req.Model)
+		return opts.usage.meterGeneration(name, model, prompt.Flatten(), func(usage *llm.Usage) (string, error) {
+			req.Usage = usage
+			return opts.translator.Translate(ctx, req)
+		})
+	})
+}
+
+// callProvider serves a request from opts.cache or makes it with call,
+// retrying transient failures according to opts.retry and storing the
+// response under the key returned by key, which is asked again once the
+// response arrives, traced as a span named op. A missing model is pulled
+// first when possible, see pullMissingModel. Every attempt waits for opts.breaker and opts.limiter
+// and is bounded by opts.requestTimeout, when set. Attempts failing while
+// the breaker is open are made again once it lets them through, without
+// using up the retries.
+func callProvider(ctx context.Context, opts analysisOptions, op, model string, key func() string, call func(context.Context) (string, error)) (string, error) {
+	name, _ := activeProvider(opts, model)
+	ctx, span := opts.tracer.Start(ctx, op, map[string]string{"provider": name, "model": model})
+	if response, ok := opts.cache.Get(key()); ok {
+		verbosef("Cache hit for %s request %s", model, key()[:12])
+		opts.tracer.End(span, "cached", nil)
+		return response, nil
+	}
+
+	var response string
+	attempts := 0
+	attempt := func() error {
+		attempts++
+		opts.tracer.Set(span, "attempts", strconv.Itoa(attempts))
+		if err := opts.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		attemptCtx := ctx
+		if opts.requestTimeout > 0 {
+			var cancel context.CancelFunc
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.requestTimeout)
+			defer cancel()
+		}
+
+		var err error
+		response, err = call(attemptCtx)
+		return providerError(err)
+	}
+	request := func() error {
+		for {
+			if err := opts.breaker.Allow(ctx); err != nil {
+				return err
+			}
+			again, err := opts.breaker.Record(attempt())
+			if !again {
+				return err
+			}
+		}
+	}
+	err := opts.retry.do(ctx, request)
+	if err != nil {
+		if err = pullMissingModel(ctx, opts, err); err == nil {
+			err = opts.retry.do(ctx, request)
+		}
+	}
+	opts.tracer.End(span, traceStatus(err), err)
+	if err != nil {
+		return "", err
+	}
+
+	// The key is that of the provider which answered, which the failover
+	// chain may have moved on to meanwhile.
+	if err := opts.cache.Put(key(), model, response); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not write response cache:", err)
+	}
+	return response, nil
+}
+
+// providerError attaches the exit code matching the kind of failure
+// reported by a provider: a host that couldn't be reached or answered with
+// an error status, or an answer that couldn't be parsed.
+func providerError(err error) error {
+	var connErr *llm.ConnectionError
+	var statusErr *llm.StatusError
+	var parseErr *llm.ParseError
+	switch {
+	case errors.As(err, &parseErr):
+		return parseError(err)
+	case errors.As(err, &connErr), errors.As(err, &statusErr):
+		return connectionError(err)
+	}
+	return err
+}
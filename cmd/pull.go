@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// modelPulls remembers the outcome of every pull of the run, so that
+// concurrent requests finding the same model missing pull or ask only once.
+var modelPulls = struct {
+	sync.Mutex
+	done map[string]error
+}{done: map[string]error{}}
+
+// pullMissingModel handles err, the failure of a request, when it is caused
+// by a model missing from a host able to download it: the model is pulled
+// with --auto-pull or once the user agrees to it, and nil is returned so the
+// request can be made again. Any other err is returned as it is.
+func pullMissingModel(ctx context.Context, opts analysisOptions, err error) error {
+	var notFound *llm.ModelNotFoundError
+	puller, ok := opts.provider.(llm.Puller)
+	if !errors.As(err, &notFound) || !ok {
+		return err
+	}
+	model := notFound.Model
+
+	modelPulls.Lock()
+	defer modelPulls.Unlock()
+	if pullErr, ok := modelPulls.done[model]; ok {
+		return pullErr
+	}
+
+	pullErr := opts.progress.Suspend(func() error {
+		if !opts.autoPull && !confirmPull(model) {
+			return fmt.Errorf("%w (pull it with --auto-pull or 'ollama pull %s')", err, model)
+		}
+		fmt.Fprintf(os.Stderr, "Pulling %s\n", model)
+		if err := puller.Pull(ctx, model, pullProgressPrinter(model)); err != nil {
+			return fmt.Errorf("pulling model %s: %w", model, err)
+		}
+		return nil
+	})
+	modelPulls.done[model] = pullErr
+	return pullErr
+}
+
+// confirmPull asks whether model should be pulled, which is only possible
+// when both stdin and stderr are terminals.
+func confirmPull(model string) bool {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stderr) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Model %s is not available on the host. Pull it now? [y/N] ", model)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// pullProgressPrinter returns the progress callback of the pull of model. On
+// a terminal the download of every layer is redrawn in place; otherwise only
+// the steps are printed, once each.
+func pullProgressPrinter(model string) func(llm.PullProgress) {
+	terminal := isTerminal(os.Stderr)
+	var status string
+	var drawing bool
+	return func(p llm.PullProgress) {
+		if terminal && p.Total > 0 {
+			fmt.Fprintf(os.Stderr, "\r\033[K%s: %s %d%% (%s/%s)", model, p.Status, p.Completed*100/p.Total, formatBytes(p.Completed), formatBytes(p.Total))
+			status, drawing = p.Status, true
+			return
+		}
+		if p.Status == status {
+			return
+		}
+		if drawing {
+			fmt.Fprintln(os.Stderr)
+			drawing = false
+		}
+		status = p.Status
+		fmt.Fprintf(os.Stderr, "%s: %s\n", model, p.Status)
+	}
+}
+
+// formatBytes formats n bytes with decimal units, the way Ollama reports
+// model sizes.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, prefix := float64(n)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "kMGT"[prefix])
+}
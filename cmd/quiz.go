@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// quizMiss is a question answered wrongly, reported in the summary.
+type quizMiss struct {
+	question string
+	answer   string
+	expected string
+}
+
+var quizCmd = &cobra.Command{
+	Use:   "quiz <results.json>...",
+	Short: "Drill the results of analise, vocab or cloze interactively",
+	Long: `The "quiz" command reads the JSON results of "analise", "vocab" or "cloze" and drills them: it shows every section or word, in random order, and asks for its translation, or for the blanked out word of a cloze.
+Typed answers are scored with fuzzy matching, so that case, punctuation and typos of a letter or two don't count against them; --threshold sets how close they must be. With --choices the translation is picked from a numbered list instead, and with --reverse the translation is shown and the source asked for.
+Type an empty line to skip a question, and end the input (Ctrl+D) to stop early. The score and the missed questions are printed at the end.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runQuiz,
+}
+
+func runQuiz(cmd *cobra.Command, args []string) error {
+	choices, err := cmd.Flags().GetInt("choices")
+	if err != nil {
+		return fmt.Errorf("retrieving choices flag: %w", err)
+	}
+	if choices == 1 || choices < 0 {
+		return errors.New("--choices must be 0, to type the answers, or at least 2")
+	}
+	questions, err := cmd.Flags().GetInt("questions")
+	if err != nil {
+		return fmt.Errorf("retrieving questions flag: %w", err)
+	}
+	if questions < 0 {
+		return errors.New("--questions cannot be negative")
+	}
+	threshold, err := cmd.Flags().GetFloat64("threshold")
+	if err != nil {
+		return fmt.Errorf("retrieving threshold flag: %w", err)
+	}
+	if threshold < 0 || threshold > 1 {
+		return errors.New("--threshold must be between 0 and 1")
+	}
+	reverse, err := cmd.Flags().GetBool("reverse")
+	if err != nil {
+		return fmt.Errorf("retrieving reverse flag: %w", err)
+	}
+	seed, err := cmd.Flags().GetInt64("seed")
+	if err != nil {
+		return fmt.Errorf("retrieving seed flag: %w", err)
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var cards []flashcard
+	for _, path := range args {
+		if path == "-" {
+			return errors.New("the results cannot be read from stdin, which is where the answers are typed")
+		}
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		fileCards, err := parseFlashcards(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		cards = append(cards, fileCards...)
+	}
+	if reverse {
+		for i := range cards {
+			cards[i].Front, cards[i].Back = cards[i].Back, cards[i].Front
+		}
+	}
+	if len(cards) == 0 {
+		return errors.New("no results to quiz on")
+	}
+	if choices > len(cards) {
+		return fmt.Errorf("--choices %d needs at least as many results, there are %d", choices, len(cards))
+	}
+
+	random := rand.New(rand.NewSource(seed))
+	random.Shuffle(len(cards), func(i, j int) { cards[i], cards[j] = cards[j], cards[i] })
+	asked := cards
+	if questions > 0 && questions < len(asked) {
+		asked = asked[:questions]
+	}
+
+	out := cmd.OutOrStdout()
+	in := bufio.NewReader(cmd.InOrStdin())
+	var misses []quizMiss
+	answered := 0
+	for i, card := range asked {
+		fmt.Fprintf(out, "\n[%d/%d] %s\n", i+1, len(asked), card.Front)
+
+		var options []string
+		if choices > 0 {
+			options = quizOptions(card, cards, choices, random)
+			for n, option := range options {
+				fmt.Fprintf(out, "  %d) %s\n", n+1, option)
+			}
+		}
+		fmt.Fprint(out, "> ")
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			if errors.Is(err, io.EOF) {
+				fmt.Fprintln(out)
+				break
+			}
+			return fmt.Errorf("reading answer: %w", err)
+		}
+		answered++
+
+		// Picked options are either right or wrong, however alike they are.
+		answer := strings.TrimSpace(line)
+		picked := false
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			answer, picked = options[n-1], true
+		}
+		if answer == "" {
+			fmt.Fprintf(out, "Skipped. The answer was: %s\n", card.Back)
+			misses = append(misses, quizMiss{question: card.Front, expected: card.Back})
+			continue
+		}
+		similarity := answerSimilarity(answer, card.Back)
+		if picked && answer != card.Back {
+			similarity = 0
+		}
+		if similarity >= threshold {
+			if similarity == 1 {
+				fmt.Fprintln(out, "Correct!")
+			} else {
+				fmt.Fprintf(out, "Correct (%.0f%% match): %s\n", similarity*100, card.Back)
+			}
+			continue
+		}
+		fmt.Fprintf(out, "Wrong. The answer was: %s\n", card.Back)
+		misses = append(misses, quizMiss{question: card.Front, answer: answer, expected: card.Back})
+	}
+
+	writeQuizSummary(out, answered, misses)
+	return nil
+}
+
+// answerSimilarity scores how alike a typed answer is to the expected one
+// between 0 and 1, from the edit distance of their normalized words. Unlike
+// textSimilarity it is lenient with typos, which matter in short answers.
+func answerSimilarity(answer, expected string) float64 {
+	normalize := func(s string) []rune {
+		words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		return []rune(strings.Join(words, " "))
+	}
+	a, b := normalize(answer), normalize(expected)
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// adjacent characters turning a into b.
+func editDistance(a, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+// quizOptions returns the multiple choice options of card: its answer and
+// choices-1 different answers of the other cards, shuffled.
+func quizOptions(card flashcard, cards []flashcard, choices int, random *rand.Rand) []string {
+	options := []string{card.Back}
+	seen := map[string]bool{card.Back: true}
+	for _, i := range random.Perm(len(cards)) {
+		if len(options) == choices {
+			break
+		}
+		if other := cards[i].Back; !seen[other] {
+			seen[other] = true
+			options = append(options, other)
+		}
+	}
+	random.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	return options
+}
+
+// writeQuizSummary writes the score of the answered questions along with the
+// ones that were missed.
+func writeQuizSummary(w io.Writer, answered int, misses []quizMiss) {
+	if answered == 0 {
+		fmt.Fprintln(w, "No questions answered.")
+		return
+	}
+	correct := answered - len(misses)
+	fmt.Fprintf(w, "\nScore: %d/%d (%.0f%%)\n", correct, answered, float64(correct)*100/float64(answered))
+	if len(misses) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nMissed:")
+	for _, miss := range misses {
+		fmt.Fprintf(w, "  %s\n    expected: %s\n", singleLine(miss.question), singleLine(miss.expected))
+		if miss.answer != "" {
+			fmt.Fprintf(w, "    answered: %s\n", singleLine(miss.answer))
+		}
+	}
+}
+
+func init() {
+	quizCmd.Flags().Int("choices", 0, "Pick the answer from this many numbered options instead of typing it (0 disables it)")
+	quizCmd.Flags().Int("questions", 0, "The number of questions asked (0 asks about every result)")
+	quizCmd.Flags().Float64("threshold", 0.8, "The similarity score (0-1) from which a typed answer counts as correct")
+	quizCmd.Flags().Bool("reverse", false, "Show the translations and ask for the sources")
+	quizCmd.Flags().Int64("seed", 0, "The seed of the random order of the questions, to repeat a quiz (0 picks a new one every time)")
+
+	rootCmd.AddCommand(quizCmd)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every request of the run, holding
+// up to burst requests and refilled with rps requests per second. A nil
+// *rateLimiter is valid and never waits, which is how limiting is disabled.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns the limiter of --rps and --burst, or nil when rps
+// is not positive.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &rateLimiter{rps: rps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until a request may be sent, or ctx is done. Requests are let
+// through in the order they started waiting.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rps
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// The token is taken right away, leaving the bucket in debt until it is
+	// refilled, so that the requests waiting after this one wait longer.
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rps * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	debugf("Rate limit reached, waiting %s", wait.Round(time.Millisecond))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// longWordLetters is the number of letters from which a word counts as long
+// in the LIX readability index.
+const longWordLetters = 7
+
+// readabilitySampleWords is the number of words of the text whose CEFR level
+// --llm asks for, and from which the language of long texts is detected.
+const readabilitySampleWords = 200
+
+// lixLevels are the highest LIX scores of each of the cefrLevels but the
+// last, which is every score above.
+var lixLevels = []float64{25, 30, 40, 50, 60}
+
+// readabilityScore is the output of the readability command.
+type readabilityScore struct {
+	Sentences   int `json:"sentences" yaml:"sentences"`
+	Words       int `json:"words" yaml:"words"`
+	UniqueWords int `json:"unique_words" yaml:"unique_words"`
+	// AverageSentenceLength is in words and AverageWordLength in letters.
+	AverageSentenceLength float64 `json:"average_sentence_length" yaml:"average_sentence_length"`
+	AverageWordLength     float64 `json:"average_word_length" yaml:"average_word_length"`
+	LongWordRatio         float64 `json:"long_word_ratio" yaml:"long_word_ratio"`
+	LIX                   float64 `json:"lix" yaml:"lix"`
+	// RareWordRatio is the share of the words missing from the common
+	// words of the --frequency-list, only set with it.
+	RareWordRatio *float64 `json:"rare_word_ratio,omitempty" yaml:"rare_word_ratio,omitempty"`
+	// Level is the estimated CEFR level, from the LIX score or the LLM as
+	// told by LevelSource.
+	Level       string       `json:"level" yaml:"level"`
+	LevelSource string       `json:"level_source" yaml:"level_source"`
+	Usage       *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+}
+
+var readabilityCmd = &cobra.Command{
+	Use:   "readability [text]",
+	Short: "Score how hard a text is to read, without analyzing it",
+	Long: `The "readability" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted) and scores how hard it is to read, to tell whether it suits your level before analyzing it: its number of sentences and words, their average lengths, the share of long words and its LIX readability index, from which its CEFR level is estimated.
+With --frequency-list, a file of the words of the language from the most frequent, one per line and optionally followed by its count, the share of the words missing from its first --common-words is reported as rare. With --llm the CEFR level is rated by the LLM instead, from the first 200 words of the text, which is a single request.
+The scores are only meaningful for languages written with spaces between words.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReadability,
+}
+
+func runReadability(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if err := checkDocumentFormat(format); err != nil {
+		return err
+	}
+	frequencyList, err := cmd.Flags().GetString("frequency-list")
+	if err != nil {
+		return fmt.Errorf("retrieving frequency-list flag: %w", err)
+	}
+	commonWords, err := cmd.Flags().GetInt("common-words")
+	if err != nil {
+		return fmt.Errorf("retrieving common-words flag: %w", err)
+	}
+	if commonWords < 1 {
+		return errors.New("--common-words must be at least 1")
+	}
+	useLLM, err := cmd.Flags().GetBool("llm")
+	if err != nil {
+		return fmt.Errorf("retrieving llm flag: %w", err)
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+	words := textWords(text)
+	if len(words) == 0 {
+		return errors.New("the text has no words to score")
+	}
+
+	score := scoreReadability(text, words)
+	if frequencyList != "" {
+		common, err := loadWordList(frequencyList)
+		if err != nil {
+			return fmt.Errorf("loading frequency list: %w", err)
+		}
+		if len(common) > commonWords {
+			common = common[:commonWords]
+		}
+		known := make(map[string]bool, len(common))
+		for _, word := range common {
+			known[word] = true
+		}
+		rare := 0
+		for _, word := range words {
+			if !known[word] {
+				rare++
+			}
+		}
+		ratio := roundScore(float64(rare) / float64(len(words)))
+		score.RareWordRatio = &ratio
+	}
+
+	if useLLM {
+		opts, err := analysisOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		defer opts.tracer.Flush()
+		warmUp(ctx, opts)
+
+		usageBefore := opts.usage.Snapshot()
+		sample := leadingWords(text, readabilitySampleWords)
+		if opts.sourceLanguage == "" {
+			if opts.sourceLanguage, err = detectLanguage(ctx, sample, opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+		if score.Level, err = rateDifficulty(ctx, sample, opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+		score.LevelSource = "llm"
+		usage := opts.usage.Snapshot().sub(usageBefore)
+		score.Usage = &usage
+	}
+
+	header := []string{"metric", "value"}
+	rows := [][]string{
+		{"sentences", strconv.Itoa(score.Sentences)},
+		{"words", strconv.Itoa(score.Words)},
+		{"unique_words", strconv.Itoa(score.UniqueWords)},
+		{"average_sentence_length", formatScore(score.AverageSentenceLength)},
+		{"average_word_length", formatScore(score.AverageWordLength)},
+		{"long_word_ratio", formatScore(score.LongWordRatio)},
+		{"lix", formatScore(score.LIX)},
+	}
+	if score.RareWordRatio != nil {
+		rows = append(rows, []string{"rare_word_ratio", formatScore(*score.RareWordRatio)})
+	}
+	rows = append(rows, []string{"level", score.Level + " (" + score.LevelSource + ")"})
+	if err := writeDocument(cmd.OutOrStdout(), format, score, header, rows); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// scoreReadability computes the scores of text, whose words are words, and
+// estimates its CEFR level from its LIX score.
+func scoreReadability(text string, words []string) readabilityScore {
+	sentences := max(1, len(splitSentences(text)))
+	unique := make(map[string]bool, len(words))
+	letters, long := 0, 0
+	for _, word := range words {
+		unique[word] = true
+		n := utf8.RuneCountInString(word)
+		letters += n
+		if n >= longWordLetters {
+			long++
+		}
+	}
+	averageSentenceLength := float64(len(words)) / float64(sentences)
+	longWordRatio := float64(long) / float64(len(words))
+	lix := averageSentenceLength + 100*longWordRatio
+
+	level := cefrLevels[len(cefrLevels)-1]
+	for i, highest := range lixLevels {
+		if lix <= highest {
+			level = cefrLevels[i]
+			break
+		}
+	}
+	return readabilityScore{
+		Sentences:             sentences,
+		Words:                 len(words),
+		UniqueWords:           len(unique),
+		AverageSentenceLength: roundScore(averageSentenceLength),
+		AverageWordLength:     roundScore(float64(letters) / float64(len(words))),
+		LongWordRatio:         roundScore(longWordRatio),
+		LIX:                   roundScore(lix),
+		Level:                 level,
+		LevelSource:           "lix",
+	}
+}
+
+// textWords returns the words of text in lower case, without the
+// punctuation around them. Apostrophes and hyphens within words are kept.
+func textWords(text string) []string {
+	var words []string
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && r != '\'' && r != '’' && r != '-'
+	}) {
+		if word := strings.Trim(field, "'’-"); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// leadingWords returns the first n words of text, as a sample of a text that
+// may be too long to send whole.
+func leadingWords(text string, n int) string {
+	fields := strings.Fields(text)
+	return strings.Join(fields[:min(n, len(fields))], " ")
+}
+
+// loadWordList reads a file of words, one per line and in lower case, such
+// as a frequency list or the words a learner knows. Anything after the
+// first whitespace of a line, such as a count, is ignored, as are empty
+// lines and the ones starting with #.
+func loadWordList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.ToLower(strings.Fields(line)[0]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%s has no words", path)
+	}
+	return words, nil
+}
+
+// roundScore rounds score to two decimals.
+func roundScore(score float64) float64 {
+	return float64(int(score*100+0.5)) / 100
+}
+
+// formatScore writes score without trailing zeros.
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func init() {
+	addAnalysisFlags(readabilityCmd.Flags())
+	readabilityCmd.Flags().String("format", "table", "The output format: table, json, yaml or csv")
+	readabilityCmd.Flags().String("frequency-list", "", "A file of the words of the language from the most frequent, one per line, to report the share of rare words")
+	readabilityCmd.Flags().Int("common-words", 2000, "The number of words of the --frequency-list that are common")
+	readabilityCmd.Flags().Bool("llm", false, "Ask the LLM for the CEFR level of the text instead of estimating it from its LIX score")
+
+	rootCmd.AddCommand(readabilityCmd)
+}
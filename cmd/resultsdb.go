@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// resultsRun is a run of analise recorded in a results database: its
+// analyses, along with when and by what they were made.
+type resultsRun struct {
+	// Results names the results file the run was read from, and is empty
+	// for the run of analise writing the database.
+	Results   string
+	CreatedAt time.Time
+	// Provider, Model and Translator are empty when the results don't
+	// record them.
+	Provider   string
+	Model      string
+	Translator string
+	Analyses   []Analysis
+}
+
+// sqliteExtensions are the extensions of the --output files written as
+// SQLite databases.
+var sqliteExtensions = []string{".db", ".sqlite", ".sqlite3"}
+
+// isSQLitePath reports whether the file at path is written as a SQLite
+// database.
+func isSQLitePath(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	for _, known := range sqliteExtensions {
+		if extension == known {
+			return true
+		}
+	}
+	return false
+}
+
+// The schema of the results databases. Every section of an analysis has a
+// translation per language it was translated into, so that the language
+// pairs are the source and target languages of the translations.
+const (
+	resultsRunsSQL = `CREATE TABLE runs (
+	id INTEGER PRIMARY KEY,
+	created_at TEXT NOT NULL,
+	results TEXT,
+	provider TEXT,
+	model TEXT,
+	translator TEXT
+)`
+	resultsAnalysesSQL = `CREATE TABLE analyses (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	file TEXT,
+	transcription TEXT,
+	source_language TEXT,
+	provider TEXT,
+	host TEXT
+)`
+	resultsSectionsSQL = `CREATE TABLE sections (
+	id INTEGER PRIMARY KEY,
+	analysis_id INTEGER NOT NULL REFERENCES analyses(id),
+	position INTEGER NOT NULL,
+	source TEXT NOT NULL,
+	page INTEGER,
+	paragraph INTEGER,
+	level TEXT,
+	ipa TEXT,
+	transliteration TEXT,
+	explanation TEXT,
+	audio TEXT
+)`
+	resultsTranslationsSQL = `CREATE TABLE translations (
+	id INTEGER PRIMARY KEY,
+	section_id INTEGER NOT NULL REFERENCES sections(id),
+	source_language TEXT,
+	target_language TEXT,
+	translation TEXT NOT NULL,
+	back_translation TEXT,
+	similarity REAL,
+	divergent INTEGER
+)`
+)
+
+// writeResultsDB writes runs as a SQLite database with a table of runs, of
+// their analyses, of the sections of the analyses and of their
+// translations, each row referring to the one it belongs to by its ID.
+func writeResultsDB(runs []resultsRun) ([]byte, error) {
+	// optional stores the values analise leaves out as NULL.
+	optional := func(value any) any {
+		switch value := value.(type) {
+		case string:
+			if value == "" {
+				return nil
+			}
+		case int:
+			if value == 0 {
+				return nil
+			}
+		}
+		return value
+	}
+
+	runsTable := sqliteTable{Name: "runs", SQL: resultsRunsSQL}
+	analysesTable := sqliteTable{Name: "analyses", SQL: resultsAnalysesSQL, Indexes: []sqliteIndex{
+		{Name: "analyses_run", SQL: "CREATE INDEX analyses_run ON analyses (run_id)", Columns: []int{1}},
+	}}
+	sectionsTable := sqliteTable{Name: "sections", SQL: resultsSectionsSQL, Indexes: []sqliteIndex{
+		{Name: "sections_analysis", SQL: "CREATE INDEX sections_analysis ON sections (analysis_id)", Columns: []int{1}},
+	}}
+	translationsTable := sqliteTable{Name: "translations", SQL: resultsTranslationsSQL, Indexes: []sqliteIndex{
+		{Name: "translations_section", SQL: "CREATE INDEX translations_section ON translations (section_id)", Columns: []int{1}},
+		{Name: "translations_pair", SQL: "CREATE INDEX translations_pair ON translations (source_language, target_language)", Columns: []int{2, 3}},
+	}}
+
+	for _, run := range runs {
+		runID := int64(len(runsTable.Rows) + 1)
+		runsTable.Rows = append(runsTable.Rows, sqliteRow{ID: runID, Values: []any{
+			nil, run.CreatedAt.UTC().Format(time.RFC3339), optional(run.Results), optional(run.Provider), optional(run.Model), optional(run.Translator),
+		}})
+		for _, analysis := range run.Analyses {
+			analysisID := int64(len(analysesTable.Rows) + 1)
+			analysesTable.Rows = append(analysesTable.Rows, sqliteRow{ID: analysisID, Values: []any{
+				nil, runID, optional(analysis.File), optional(analysis.Transcription), optional(analysis.SourceLanguage), optional(analysis.Provider), optional(analysis.Host),
+			}})
+			for position, item := range analysis.Results {
+				sectionID := int64(len(sectionsTable.Rows) + 1)
+				sectionsTable.Rows = append(sectionsTable.Rows, sqliteRow{ID: sectionID, Values: []any{
+					nil, analysisID, int64(position + 1), item.Source, optional(item.Page), optional(item.Paragraph),
+					optional(item.Level), optional(item.IPA), optional(item.Transliteration), optional(item.Explanation), optional(item.Audio),
+				}})
+
+				translation := func(language, text string, verified bool) {
+					if text == "" {
+						return
+					}
+					values := []any{nil, sectionID, optional(analysis.SourceLanguage), optional(language), text, nil, nil, nil}
+					if verified {
+						values[5] = optional(item.BackTranslation)
+						if item.Similarity != nil {
+							values[6] = *item.Similarity
+						}
+						if item.BackTranslation != "" {
+							divergent := int64(0)
+							if item.Divergent {
+								divergent = 1
+							}
+							values[7] = divergent
+						}
+					}
+					translationsTable.Rows = append(translationsTable.Rows, sqliteRow{ID: int64(len(translationsTable.Rows) + 1), Values: values})
+				}
+				if len(analysis.TranslationLanguages) == 0 {
+					translation(analysis.TranslationLanguage, item.Translation, true)
+				}
+				for _, language := range analysis.TranslationLanguages {
+					translation(language, item.Translations[language], false)
+				}
+			}
+		}
+	}
+	return writeSQLite([]sqliteTable{runsTable, analysesTable, sectionsTable, translationsTable})
+}
+
+var exportSQLiteCmd = &cobra.Command{
+	Use:   "sqlite <results.json>...",
+	Short: "Gather the results of analise into a SQLite database",
+	Long: `The "sqlite" command reads the JSON results of "analise" (from the given files, or stdin when the argument is "-") and writes them to the SQLite database --output, each file as a run, so that the results of many runs can be queried together with SQL.
+The database has the tables runs, with the results file and the time it was last written, analyses, with the file and source language of every analysis of a run, sections, with the source and position of every section of an analysis, and translations, with the source and target languages of every translation of a section and its --verify back-translation. Their rows refer to the one they belong to by its ID, such as sections.analysis_id:
+
+    SELECT s.source, t.translation FROM translations t JOIN sections s ON s.id = t.section_id WHERE t.target_language = 'en-US'
+
+The same database is written by "analise --output results.db", which also records the provider and the model of the run.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runExportSQLite,
+}
+
+func runExportSQLite(cmd *cobra.Command, args []string) error {
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	if outputPath == "" {
+		return errors.New("--output is required: the database to write, such as results.db")
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	var runs []resultsRun
+	sections := 0
+	for _, path := range args {
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		analyses, err := parseAnalyses(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		run := resultsRun{Results: name, CreatedAt: time.Now(), Analyses: analyses}
+		if info, err := os.Stat(path); err == nil && path != "-" {
+			run.CreatedAt = info.ModTime()
+		}
+		for _, analysis := range analyses {
+			sections += len(analysis.Results)
+		}
+		runs = append(runs, run)
+	}
+
+	data, err := writeResultsDB(runs)
+	if err != nil {
+		return fmt.Errorf("writing database: %w", err)
+	}
+	if err := writeFileAtomic(outputPath, data, force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d sections of %d runs to %s\n", sections, len(runs), outputPath)
+	return nil
+}
+
+func init() {
+	exportSQLiteCmd.Flags().StringP("output", "o", "", "The SQLite database to write")
+	exportSQLiteCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	exportCmd.AddCommand(exportSQLiteCmd)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// resumedResults are the sections of the output of an earlier run given to
+// --resume, which are reused instead of being translated again.
+type resumedResults struct {
+	// items holds the translated sections by the memoryKey of their source.
+	items map[string]ResultItem
+
+	mu     sync.Mutex
+	reused int
+}
+
+// newResumedResults reads the --resume output, or returns nil when --resume
+// is not set. Only its sections translated into the translation languages of
+// opts are kept.
+func newResumedResults(cmd *cobra.Command, opts analysisOptions) (*resumedResults, error) {
+	path, err := cmd.Flags().GetString("resume")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving resume flag: %w", err)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	if opts.combined {
+		return nil, errors.New("--resume cannot be combined with --combined, which translates the sections along with their segmentation")
+	}
+	data, name, err := readResults(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading resumed results: %w", err)
+	}
+	// The NDJSON of a run that failed halfway doesn't name the language of
+	// its sections, which are taken to be the ones of this run.
+	analyses, err := parseAnalyses(data)
+	if err != nil {
+		items, ndjsonErr := parseNDJSONResults(data)
+		if ndjsonErr != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		analyses = []Analysis{{Results: items}}
+	}
+
+	resumed := &resumedResults{items: make(map[string]ResultItem)}
+	for _, analysis := range analyses {
+		if !resumableAnalysis(analysis, opts) {
+			continue
+		}
+		for _, item := range analysis.Results {
+			if resumableItem(item, opts) {
+				item.Origin = nil
+				resumed.items[memoryKey(item.Source)] = item
+			}
+		}
+	}
+	verbosef("Resuming %d translated sections of %s", len(resumed.items), name)
+	return resumed, nil
+}
+
+// parseNDJSONResults parses results written with --format ndjson, one per
+// line.
+func parseNDJSONResults(data []byte) ([]ResultItem, error) {
+	var items []ResultItem
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var item ndjsonItem
+		if err := decoder.Decode(&item); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing NDJSON results: %w", err)
+		}
+		items = append(items, item.ResultItem)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("parsing NDJSON results: no results")
+	}
+	return items, nil
+}
+
+// resumableAnalysis reports whether the sections of analysis are translated
+// into the translation languages of opts, which analyses naming none are
+// taken to be.
+func resumableAnalysis(analysis Analysis, opts analysisOptions) bool {
+	if len(opts.translationLanguages) > 1 {
+		if len(analysis.TranslationLanguages) == 0 {
+			return analysis.TranslationLanguage == ""
+		}
+		for _, language := range opts.translationLanguages {
+			found := false
+			for _, translated := range analysis.TranslationLanguages {
+				if strings.EqualFold(language, translated) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	if len(analysis.TranslationLanguages) > 0 {
+		return false
+	}
+	return analysis.TranslationLanguage == "" || strings.EqualFold(analysis.TranslationLanguage, opts.translationLanguage)
+}
+
+// resumableItem reports whether item has a translation into every
+// translation language of opts.
+func resumableItem(item ResultItem, opts analysisOptions) bool {
+	if strings.TrimSpace(item.Source) == "" {
+		return false
+	}
+	if len(opts.translationLanguages) > 1 {
+		for _, language := range opts.translationLanguages {
+			if item.Translations[language] == "" {
+				return false
+			}
+		}
+		return true
+	}
+	return item.Translation != ""
+}
+
+// lookup returns the resumed result of section, if there is one. It is safe
+// to call on a nil *resumedResults, which has none.
+func (r *resumedResults) lookup(section string) (ResultItem, bool) {
+	if r == nil {
+		return ResultItem{}, false
+	}
+	item, ok := r.items[memoryKey(section)]
+	if !ok {
+		return ResultItem{}, false
+	}
+	r.mu.Lock()
+	r.reused++
+	r.mu.Unlock()
+	item.Source = section
+	return item, true
+}
+
+// Reused returns the number of sections reused so far.
+func (r *resumedResults) Reused() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reused
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import "testing"
+
+func TestResumableAnalysis(t *testing.T) {
+	single := analysisOptions{translationLanguage: "en-US", translationLanguages: []string{"en-US"}}
+	several := analysisOptions{translationLanguage: "en", translationLanguages: []string{"en", "fr"}}
+	for _, test := range []struct {
+		analysis Analysis
+		opts     analysisOptions
+		want     bool
+	}{
+		{Analysis{}, single, true},
+		{Analysis{TranslationLanguage: "en-us"}, single, true},
+		{Analysis{TranslationLanguage: "fr"}, single, false},
+		{Analysis{TranslationLanguages: []string{"en-US", "fr"}}, single, false},
+		{Analysis{}, several, true},
+		{Analysis{TranslationLanguage: "en"}, several, false},
+		{Analysis{TranslationLanguages: []string{"FR", "de", "EN"}}, several, true},
+		{Analysis{TranslationLanguages: []string{"en"}}, several, false},
+	} {
+		if got := resumableAnalysis(test.analysis, test.opts); got != test.want {
+			t.Errorf("resumableAnalysis(%+v, %q) = %v, want %v", test.analysis, test.opts.translationLanguages, got, test.want)
+		}
+	}
+}
+
+func TestResumableItem(t *testing.T) {
+	single := analysisOptions{translationLanguage: "en", translationLanguages: []string{"en"}}
+	several := analysisOptions{translationLanguage: "en", translationLanguages: []string{"en", "fr"}}
+	for _, test := range []struct {
+		item ResultItem
+		opts analysisOptions
+		want bool
+	}{
+		{ResultItem{Source: "Hund", Translation: "dog"}, single, true},
+		{ResultItem{Source: "Hund"}, single, false},
+		{ResultItem{Source: " \n", Translation: "dog"}, single, false},
+		{ResultItem{Source: "Hund", Translations: map[string]string{"en": "dog", "fr": "chien"}}, several, true},
+		{ResultItem{Source: "Hund", Translations: map[string]string{"en": "dog"}}, several, false},
+		{ResultItem{Source: "Hund", Translation: "dog"}, several, false},
+	} {
+		if got := resumableItem(test.item, test.opts); got != test.want {
+			t.Errorf("resumableItem(%+v, %q) = %v, want %v", test.item, test.opts.translationLanguages, got, test.want)
+		}
+	}
+}
+
+func TestResumedResultsLookup(t *testing.T) {
+	var none *resumedResults
+	if _, ok := none.lookup("Hund"); ok || none.Reused() != 0 {
+		t.Error("a nil *resumedResults has results")
+	}
+
+	resumed := &resumedResults{items: map[string]ResultItem{
+		memoryKey("Der Hund\nbellt."): {Source: "Der Hund\nbellt.", Translation: "The dog barks.", Page: 2},
+	}}
+	// Sections are found whatever their spacing, and take the source asked.
+	item, ok := resumed.lookup("Der  Hund bellt. ")
+	if !ok || item.Translation != "The dog barks." || item.Source != "Der  Hund bellt. " || item.Page != 2 {
+		t.Errorf("lookup = %+v, %v", item, ok)
+	}
+	if _, ok := resumed.lookup("Die Katze"); ok {
+		t.Error("found a section that wasn't resumed")
+	}
+	resumed.lookup("Der Hund bellt.")
+	if got := resumed.Reused(); got != 2 {
+		t.Errorf("Reused = %d, want 2", got)
+	}
+}
+
+func TestParseNDJSONResults(t *testing.T) {
+	items, err := parseNDJSONResults([]byte(`{"file":"a.txt","source":"Hund","translation":"dog"}
+{"source":"Katze","translation":"cat"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Source != "Hund" || items[0].Translation != "dog" || items[1].Source != "Katze" {
+		t.Errorf("items = %+v", items)
+	}
+
+	for _, data := range []string{"", "\n", `{"source":"Hund"`, "[]", "not JSON"} {
+		if items, err := parseNDJSONResults([]byte(data)); err == nil {
+			t.Errorf("parseNDJSONResults(%q) = %+v, want an error", data, items)
+		}
+	}
+}
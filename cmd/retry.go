@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+const maxRetryDelay = 30 * time.Second
+
+// maxRateLimitWaits is the number of times a request may wait for the
+// provider's rate limit to reset, on top of the retries.
+const maxRateLimitWaits = 10
+
+// retryPolicy retries failed LLM requests with exponential backoff and jitter.
+type retryPolicy struct {
+	retries int
+	delay   time.Duration
+	// respectRateLimits, set by --respect-rate-limits, waits for as long as
+	// the provider asked before retrying a rate limited request.
+	respectRateLimits bool
+}
+
+// do calls fn until it succeeds, returns a non-retryable error or the retries
+// are exhausted, in which case the last error is returned. A request the
+// provider asked to retry later, in the Retry-After or rate limit headers of
+// its answer, is retried once that time has passed without using up the
+// retries, unless ctx would be done by then. Waiting between attempts stops
+// as soon as ctx is done.
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	rateLimitWaits := 0
+	for attempt := 0; ; {
+		err := fn()
+		if err == nil || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+
+		var wait time.Duration
+		if after := p.retryAfter(err); after > 0 && rateLimitWaits < maxRateLimitWaits {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < after {
+				return err
+			}
+			rateLimitWaits++
+			wait = after
+			fmt.Fprintf(os.Stderr, "Request rate limited (%v), retrying in %s as the provider asked\n", err, wait.Round(time.Millisecond))
+		} else {
+			if attempt >= p.retries {
+				return err
+			}
+			wait = p.backoff(attempt)
+			attempt++
+			fmt.Fprintf(os.Stderr, "Request failed (%v), retrying in %s (%d/%d)\n", err, wait.Round(time.Millisecond), attempt, p.retries)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// retryAfter returns how long the provider asked to wait before retrying the
+// request failing with err, or zero when it didn't say or the policy doesn't
+// respect rate limits.
+func (p retryPolicy) retryAfter(err error) time.Duration {
+	var statusErr *llm.StatusError
+	if !p.respectRateLimits || !errors.As(err, &statusErr) {
+		return 0
+	}
+	return statusErr.RetryAfter
+}
+
+// backoff returns the delay before the given retry: the base delay doubled on
+// every attempt, with up to 50% of random jitter either way, capped. A zero
+// base delay retries right away.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	if p.delay <= 0 {
+		return 0
+	}
+	wait := p.delay << uint(attempt)
+	// Shifting too far overflows, or drops the high bits of the delay.
+	if attempt >= 63 || wait>>uint(attempt) != p.delay || wait > maxRetryDelay {
+		wait = maxRetryDelay
+	}
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait)+1))
+	if wait > maxRetryDelay {
+		wait = maxRetryDelay
+	}
+	return wait
+}
+
+// isRetryable reports whether err is worth retrying: the provider couldn't
+// be reached. Requests rejected by the host, other than for rate limiting,
+// would fail the same way again, and so would invalid requests and those
+// blocked by safety filters. Neither are the hosts the circuit breaker gave
+// up on, nor the answers that couldn't be parsed, which the host did send and
+// which don't count against it.
+func isRetryable(err error) bool {
+	var breakerErr *breakerError
+	if errors.As(err, &breakerErr) {
+		return false
+	}
+	var statusErr *llm.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500
+	}
+	var connErr *llm.ConnectionError
+	return errors.As(err, &connErr)
+}
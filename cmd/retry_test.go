@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+var (
+	errUnreachable = &llm.ConnectionError{Err: errors.New("connection refused")}
+	errOverloaded  = &llm.StatusError{Code: 503}
+	errBadRequest  = &llm.StatusError{Code: 400, Message: "unknown model"}
+)
+
+func TestIsRetryable(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("rendering prompt template"), false},
+		{context.Canceled, false},
+		{errUnreachable, true},
+		{fmt.Errorf("translating %q: %w", "Hund", errUnreachable), true},
+		{&llm.StatusError{Code: 429}, true},
+		{&llm.StatusError{Code: 500}, true},
+		{errOverloaded, true},
+		{errBadRequest, false},
+		{&llm.StatusError{Code: 401}, false},
+		{&llm.ParseError{Err: errors.New("no valid JSON array or object found in response")}, false},
+		{&breakerError{errUnreachable}, false},
+	} {
+		if got := isRetryable(test.err); got != test.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	immediate := retryPolicy{retries: 3}
+	for _, attempt := range []int{0, 1, 10, 64, 100} {
+		if wait := immediate.backoff(attempt); wait != 0 {
+			t.Errorf("backoff(%d) without a delay = %s, want 0", attempt, wait)
+		}
+	}
+
+	p := retryPolicy{retries: 3, delay: 100 * time.Millisecond}
+	for attempt, bounds := range map[int][2]time.Duration{
+		0:   {50 * time.Millisecond, 150 * time.Millisecond},
+		2:   {200 * time.Millisecond, 600 * time.Millisecond},
+		9:   {maxRetryDelay / 2, maxRetryDelay},
+		40:  {maxRetryDelay / 2, maxRetryDelay},
+		63:  {maxRetryDelay / 2, maxRetryDelay},
+		200: {maxRetryDelay / 2, maxRetryDelay},
+	} {
+		for i := 0; i < 100; i++ {
+			if wait := p.backoff(attempt); wait < bounds[0] || wait > bounds[1] {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt, wait, bounds[0], bounds[1])
+			}
+		}
+	}
+
+	// The cap holds after the jitter too.
+	long := retryPolicy{delay: maxRetryDelay - time.Second}
+	for i := 0; i < 100; i++ {
+		if wait := long.backoff(0); wait > maxRetryDelay {
+			t.Fatalf("backoff(0) = %s, beyond %s", wait, maxRetryDelay)
+		}
+	}
+}
+
+// failing returns a function failing with errs in turn and then succeeding,
+// counting its calls.
+func failing(calls *int, errs ...error) func() error {
+	return func() error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	ctx := context.Background()
+	p := retryPolicy{retries: 2}
+
+	calls := 0
+	if err := p.do(ctx, failing(&calls, errUnreachable, errOverloaded)); err != nil || calls != 3 {
+		t.Errorf("after two failures: err = %v with %d calls, want success on the third", err, calls)
+	}
+
+	calls = 0
+	if err := p.do(ctx, failing(&calls, errUnreachable, errUnreachable, errOverloaded, errUnreachable)); err != errOverloaded || calls != 3 {
+		t.Errorf("exhausting the retries: err = %v with %d calls, want the last error after 3", err, calls)
+	}
+
+	calls = 0
+	if err := p.do(ctx, failing(&calls, errBadRequest)); err != errBadRequest || calls != 1 {
+		t.Errorf("a bad request: err = %v with %d calls, want it after 1", err, calls)
+	}
+
+	calls = 0
+	parseErr := &llm.ParseError{Err: errors.New("unexpected end of JSON input")}
+	if err := p.do(ctx, failing(&calls, parseErr)); err != parseErr || calls != 1 {
+		t.Errorf("an unparsable answer: err = %v with %d calls, want it after 1", err, calls)
+	}
+}
+
+func TestRetryPolicyRateLimits(t *testing.T) {
+	limited := &llm.StatusError{Code: 429, RetryAfter: time.Millisecond}
+
+	// Waiting for the rate limit to reset doesn't use up the retries.
+	calls := 0
+	p := retryPolicy{retries: 0, respectRateLimits: true}
+	if err := p.do(context.Background(), failing(&calls, limited, limited, limited)); err != nil || calls != 4 {
+		t.Errorf("rate limited: err = %v with %d calls, want success on the fourth", err, calls)
+	}
+
+	calls = 0
+	p.respectRateLimits = false
+	if err := p.do(context.Background(), failing(&calls, limited)); err != limited || calls != 1 {
+		t.Errorf("rate limited, ignoring the limits: err = %v with %d calls, want it after 1", err, calls)
+	}
+
+	// A wait the deadline doesn't leave time for isn't even started.
+	calls = 0
+	p = retryPolicy{retries: 3, respectRateLimits: true}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	long := &llm.StatusError{Code: 429, RetryAfter: time.Hour}
+	if err := p.do(ctx, failing(&calls, long)); err != long || calls != 1 {
+		t.Errorf("rate limited past the deadline: err = %v with %d calls, want it after 1", err, calls)
+	}
+}
+
+func TestRetryPolicyCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := retryPolicy{retries: 5, delay: time.Hour}
+	calls := 0
+	done := make(chan error)
+	go func() { done <- p.do(ctx, failing(&calls, errUnreachable, errUnreachable)) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != errUnreachable {
+			t.Errorf("err = %v, want the failure before the cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the retries kept waiting after the context was canceled")
+	}
+}
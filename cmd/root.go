@@ -1,18 +1,68 @@
 package cmd
 
 import (
-	"github.com/spf13/cobra"
+	"fmt"
 	"os"
+
+	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "starter-go-cli",
 	Short: "An example CLI application in Go",
-	Long:  `starter-go-cli is a CLI application that processes text and outputs its translation into sections of it created based on their sematic meaning.`,
+	Long: `starter-go-cli is a CLI application that processes text and outputs its translation into sections of it created based on their sematic meaning.
+
+Results are written to stdout and diagnostics to stderr. The exit code is 0 on success, 1 for invalid usage and other errors, 2 when the LLM host can't be reached or keeps failing, 3 when its response can't be parsed, 4 when only some inputs of a batch failed, 5 when --total-timeout is exceeded and 130 when interrupted.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		verbose, err := cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return err
+		}
+		debug, err := cmd.Flags().GetBool("debug")
+		if err != nil {
+			return err
+		}
+		switch {
+		case debug:
+			currentLogLevel = logDebug
+		case verbose:
+			currentLogLevel = logVerbose
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return fmt.Errorf("%w\nRun '%s --help' for usage.", err, cmd.CommandPath())
+	})
+	rootCmd.PersistentFlags().String("provider", "", "The LLM backend: ollama, llamacpp, openai, openai-compatible, azure-openai, gemini or bedrock (default is 'ollama')")
+	rootCmd.PersistentFlags().StringP("model", "m", "", "The model used for LLM requests (default depends on --provider, 'llama3' for Ollama)")
+	rootCmd.PersistentFlags().String("ollama-api", "", "The Ollama API: chat, with the instructions in a system message, or generate for older hosts (default is 'chat')")
+	rootCmd.PersistentFlags().String("keep-alive", "", "How long Ollama keeps the model loaded after a request, e.g. 10m, or -1 until it stops; the model is also loaded before the analysis starts (default is the host's)")
+	rootCmd.PersistentFlags().String("azure-deployment", "", "The Azure OpenAI deployment receiving the requests (default is the deployment named after the model)")
+	rootCmd.PersistentFlags().String("azure-api-version", "", "The Azure OpenAI API version (default is '2024-06-01')")
+	rootCmd.PersistentFlags().String("aws-region", "", "The AWS region of Bedrock (default is the region of the AWS configuration)")
+	rootCmd.PersistentFlags().StringArray("safety-setting", nil, "A Gemini safety setting as CATEGORY=THRESHOLD, e.g. HARASSMENT=BLOCK_NONE (repeatable)")
+	rootCmd.PersistentFlags().String("proxy", "", "The proxy of every request, as an http://, https://, socks5:// or socks5h:// URL, or 'none' to ignore HTTP_PROXY and HTTPS_PROXY (default comes from those variables)")
+	rootCmd.PersistentFlags().String("ca-cert", "", "A PEM file of CA certificates trusted in addition to the system ones")
+	rootCmd.PersistentFlags().String("client-cert", "", "A PEM client certificate for mutual TLS, used with --client-key")
+	rootCmd.PersistentFlags().String("client-key", "", "The PEM private key of --client-cert")
+	rootCmd.PersistentFlags().Bool("insecure-skip-verify", false, "Don't verify TLS certificates, for lab setups only")
+	rootCmd.PersistentFlags().StringArray("header", nil, "A header added to the requests to the LLM host, as 'Key: Value' (repeatable)")
+	rootCmd.PersistentFlags().String("api-key", "", "A bearer token sent in the Authorization header of the requests to the LLM host without credentials of their own, e.g. to an Ollama host behind a reverse proxy")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Log requests, latencies and statuses to stderr")
+	rootCmd.PersistentFlags().Bool("debug", false, "Log requests along with their payloads and raw responses to stderr (implies --verbose)")
+	rootCmd.PersistentFlags().String("config", "", "The YAML configuration file (default is '$XDG_CONFIG_HOME/starter-go-cli/config.yaml')")
 }
 
+// Execute runs the command selected by the command-line arguments. Errors
+// are printed to stderr and turned into the documented exit codes.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitCode(err))
 	}
 }
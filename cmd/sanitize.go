@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// generateJSON sends req and decodes the JSON the model answers with into v,
+// asking once more when the answer can't be parsed; see analyze.GenerateJSON.
+func generateJSON(ctx context.Context, opts analysisOptions, req llm.Request, v interface{}) error {
+	return providerError(analyze.GenerateJSON(ctx, analysisClient{opts}, req, v))
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// resultsSchema is the JSON Schema of the JSON output of analise. It is
+// versioned by its $id, which changes along with the shape of the output.
+//
+//go:embed results.schema.json
+var resultsSchema []byte
+
+// jsonSchema is the subset of JSON Schema that resultsSchema is written in.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+}
+
+// schemaValidator checks documents against a schema, resolving the
+// references to its $defs.
+type schemaValidator struct {
+	root *jsonSchema
+}
+
+// newResultsValidator returns the validator of resultsSchema.
+func newResultsValidator() (*schemaValidator, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(resultsSchema, &root); err != nil {
+		return nil, fmt.Errorf("parsing the results schema: %w", err)
+	}
+	return &schemaValidator{root: &root}, nil
+}
+
+// validateResults reports the first place v, marshalled to JSON, doesn't
+// match the definition at ref of the schema, or the whole schema when ref
+// is empty.
+func (s *schemaValidator) validateResults(v interface{}, ref string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshalling results to JSON: %w", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("parsing results JSON: %w", err)
+	}
+	schema := s.root
+	if ref != "" {
+		if schema, err = s.resolve(ref); err != nil {
+			return err
+		}
+	}
+	if err := s.validate(schema, document, ""); err != nil {
+		return fmt.Errorf("the results don't match the schema: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the definition a $ref of the form #/$defs/name points to.
+func (s *schemaValidator) resolve(ref string) (*jsonSchema, error) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok || s.root.Defs[name] == nil {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+	return s.root.Defs[name], nil
+}
+
+// validate checks value against schema, where path is the JSON pointer of
+// value in the document.
+func (s *schemaValidator) validate(schema *jsonSchema, value interface{}, path string) error {
+	if schema.Ref != "" {
+		target, err := s.resolve(schema.Ref)
+		if err != nil {
+			return err
+		}
+		return s.validate(target, value, path)
+	}
+	at := path
+	if at == "" {
+		at = "/"
+	}
+
+	if len(schema.OneOf) > 0 {
+		// The alternative that failed the deepest in the document is the
+		// one value was meant to match.
+		matches := 0
+		var deepest *schemaError
+		for _, option := range schema.OneOf {
+			err := s.validate(option, value, path)
+			var mismatch *schemaError
+			switch {
+			case err == nil:
+				matches++
+			case !errors.As(err, &mismatch):
+				return err
+			case deepest == nil || len(mismatch.Path) > len(deepest.Path):
+				deepest = mismatch
+			}
+		}
+		switch {
+		case matches == 0:
+			return deepest
+		case matches > 1:
+			return &schemaError{at, fmt.Sprintf("matches %d of the alternatives of the schema instead of one", matches)}
+		}
+	}
+	if schema.Type != nil && !jsonTypeMatches(schema.Type, value) {
+		return &schemaError{at, fmt.Sprintf("expected %v, found %s", schema.Type, jsonTypeOf(value))}
+	}
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &schemaError{at, fmt.Sprintf("%v is not one of %v", value, schema.Enum)}
+		}
+	}
+	if number, ok := value.(float64); ok {
+		if schema.Minimum != nil && number < *schema.Minimum {
+			return &schemaError{at, fmt.Sprintf("%v is less than %v", number, *schema.Minimum)}
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			return &schemaError{at, fmt.Sprintf("%v is greater than %v", number, *schema.Maximum)}
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				return &schemaError{at, fmt.Sprintf("missing the required property %q", name)}
+			}
+		}
+		var additional *jsonSchema
+		closed := string(schema.AdditionalProperties) == "false"
+		if len(schema.AdditionalProperties) > 0 && !closed && string(schema.AdditionalProperties) != "true" {
+			additional = &jsonSchema{}
+			if err := json.Unmarshal(schema.AdditionalProperties, additional); err != nil {
+				return fmt.Errorf("parsing the schema: %w", err)
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			switch {
+			case ok:
+			case additional != nil:
+				property = additional
+			case closed:
+				return &schemaError{at, fmt.Sprintf("unexpected property %q", name)}
+			default:
+				continue
+			}
+			if err := s.validate(property, value[name], path+"/"+jsonPointerEscape(name)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range value {
+				if err := s.validate(schema.Items, item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaError is a place of a document that doesn't match its schema, at
+// the JSON pointer Path.
+type schemaError struct {
+	Path    string
+	Message string
+}
+
+func (e *schemaError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// jsonTypeMatches reports whether value is of the type, or one of the
+// types, of a schema.
+func jsonTypeMatches(types interface{}, value interface{}) bool {
+	var names []interface{}
+	switch types := types.(type) {
+	case string:
+		names = []interface{}{types}
+	case []interface{}:
+		names = types
+	}
+	actual := jsonTypeOf(value)
+	for _, name := range names {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeOf returns the JSON Schema type of a decoded JSON value. Numbers
+// without a fraction are integers.
+func jsonTypeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// jsonPointerEscape escapes a property name for a JSON pointer.
+func jsonPointerEscape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the results of analise",
+	Long: `The "schema" command prints the JSON Schema describing the JSON output of "analise": the analysis of a single text, or an array of analyses when they come from files, with their sections, translations and enrichments. The schema is versioned by its $id, which changes whenever the shape of the output does, so that downstream consumers can tell the results they rely on.
+Use "analise --validate-output" to check the results against it before they are written.`,
+	Args: cobra.NoArgs,
+	RunE: runSchema,
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+	_, err := cmd.OutOrStdout().Write(resultsSchema)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
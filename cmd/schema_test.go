@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeOllama returns an Ollama host splitting "Der Hund bellt. Die Katze
+// schläft." into its two sentences and translating them.
+func fakeOllama(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Messages) == 0 {
+			t.Errorf("decoding request: %v", err)
+			return
+		}
+		prompt := body.Messages[len(body.Messages)-1].Content
+		answer := "The dog barks."
+		switch {
+		case strings.Contains(prompt, "Der Hund bellt.") && strings.Contains(prompt, "Die Katze schläft."):
+			answer = `["Der Hund bellt.", "Die Katze schläft."]`
+		case strings.Contains(prompt, "Die Katze schläft."):
+			answer = "The cat sleeps."
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": map[string]string{"role": "assistant", "content": answer}, "done": true})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// runCommand runs the command line args, returning what it wrote to its
+// stdout and stderr.
+func runCommand(t *testing.T, args ...string) (string, string) {
+	t.Helper()
+	var stdout, stderr strings.Builder
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(&stdout)
+	rootCmd.SetErr(&stderr)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	return stdout.String(), stderr.String()
+}
+
+func TestResultsSchemaMatchesOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("STARTER_GO_CLI_CONFIG", filepath.Join(dir, "config.yaml"))
+	t.Setenv("STARTER_GO_CLI_CACHE_DIR", filepath.Join(dir, "cache"))
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	text := filepath.Join(dir, "tiere.txt")
+	if err := os.WriteFile(text, []byte("Der Hund bellt. Die Katze schläft."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	memory := filepath.Join(dir, "memory.json")
+	if err := os.WriteFile(memory, []byte(`{"source_language": "de", "translation_language": "en", "results": [{"source": "Der Hund bellt.", "translation": "The dog is barking."}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	validator, err := newResultsValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validate := func(name string, data []byte) {
+		t.Helper()
+		if err := validator.validateResults(json.RawMessage(data), ""); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, data)
+		}
+	}
+
+	results := filepath.Join(dir, "results.json")
+	runCommand(t, "analise", "--file", text, "--llm-host", fakeOllama(t).URL+"/api/generate", "--source-language", "de", "--translation-language", "en", "--memory", memory, "--no-progress", "--output", results)
+	data, err := os.ReadFile(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validate("analise", data)
+	var analyses []Analysis
+	if err := json.Unmarshal(data, &analyses); err != nil {
+		t.Fatal(err)
+	}
+	if len(analyses) != 1 || len(analyses[0].Results) != 2 || analyses[0].Memory == nil || analyses[0].Memory.Exact != 1 {
+		t.Errorf("analise results = %s, want two sections, one of them from the memory", data)
+	}
+
+	merged, stderr := runCommand(t, "merge", results, memory)
+	validate("merge", []byte(merged))
+	if !strings.Contains(merged, `"origin"`) {
+		t.Errorf("merged results have no origin:\n%s", merged)
+	}
+	if !strings.HasPrefix(stderr, "Merged 2 sections from 2 files") {
+		t.Errorf("merge wrote %q to stderr", stderr)
+	}
+}
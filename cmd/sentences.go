@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sentenceEnd matches the punctuation ending a sentence, along with the
+// closing quotes and brackets following it.
+var sentenceEnd = regexp.MustCompile(`[.!?…]+["'”’»)\]]*(\s+|$)|[。！？]+[」』”）]*\s*|\n\s*\n`)
+
+// splitSentences divides text into its sentences, trimmed, keeping their
+// punctuation. Blank lines end a sentence too, so that titles and list items
+// without punctuation stand on their own.
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, match := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if sentence := strings.TrimSpace(text[start:match[1]]); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = match[1]
+	}
+	if sentence := strings.TrimSpace(text[start:]); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// ttsBackends are the --tts backends. OpenAI's takes the API key of the
+// openai provider, and none for OpenAI-compatible servers.
+var ttsBackends = map[string]providerSpec{
+	"piper": {
+		title: "Piper",
+		host:  "http://localhost:5000",
+	},
+	"openai": {
+		title:       "OpenAI TTS",
+		host:        "https://api.openai.com/v1",
+		model:       "tts-1",
+		apiKeyEnv:   []string{"STARTER_GO_CLI_OPENAI_API_KEY", "OPENAI_API_KEY"},
+		optionalKey: true,
+	},
+}
+
+// speechOutput reads the sections aloud with --tts, writing a recording of
+// every section to dir.
+type speechOutput struct {
+	synthesizer llm.Synthesizer
+	// key tells apart the recordings of the backends, models and voices.
+	key string
+	dir string
+}
+
+// newSpeechOutput returns the --tts backend, or nil when --tts is not set.
+func newSpeechOutput(cmd *cobra.Command) (*speechOutput, error) {
+	backend, err := cmd.Flags().GetString("tts")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tts flag: %w", err)
+	}
+	if backend == "" {
+		return nil, nil
+	}
+	spec, ok := ttsBackends[backend]
+	if !ok {
+		names := make([]string, 0, len(ttsBackends))
+		for name := range ttsBackends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unsupported tts backend %q (supported: %s)", backend, strings.Join(names, ", "))
+	}
+	dir, err := cmd.Flags().GetString("tts-dir")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tts-dir flag: %w", err)
+	}
+	if dir == "" {
+		return nil, errors.New("--tts-dir cannot be empty")
+	}
+
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	host, err := resolveSetting(cmd, "tts-host", "STARTER_GO_CLI_TTS_HOST", cfg.TTSHost, spec.host)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tts-host flag: %w", err)
+	}
+	model, err := resolveSetting(cmd, "tts-model", "STARTER_GO_CLI_TTS_MODEL", cfg.TTSModel, spec.model)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tts-model flag: %w", err)
+	}
+	voice, err := resolveSetting(cmd, "tts-voice", "STARTER_GO_CLI_TTS_VOICE", cfg.TTSVoice, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tts-voice flag: %w", err)
+	}
+	client, err := httpClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var synthesizer llm.Synthesizer
+	switch backend {
+	case "piper":
+		piper := llm.NewPiper(host, voice, stderrLogger{})
+		piper.Client = client
+		synthesizer = piper
+	case "openai":
+		apiKey, err := resolveAPIKey(cfg, "openai", spec)
+		if err != nil {
+			return nil, err
+		}
+		speech := llm.NewOpenAISpeech(host, apiKey, model, voice, stderrLogger{})
+		speech.Client = client
+		synthesizer, model, voice = speech, speech.Model, speech.Voice
+	}
+	return &speechOutput{
+		synthesizer: synthesizer,
+		key:         strings.Join([]string{backend, host, model, voice}, "\x00"),
+		dir:         dir,
+	}, nil
+}
+
+// synthesizeSection returns the path to the recording of section, read
+// aloud by the --tts backend. Recordings are named after what they read, so
+// the ones written by earlier runs are reused.
+func synthesizeSection(ctx context.Context, section string, opts analysisOptions) (string, error) {
+	sum := sha256.Sum256([]byte(opts.speech.key + "\x00" + section))
+	path := filepath.Join(opts.speech.dir, hex.EncodeToString(sum[:8])+opts.speech.synthesizer.Extension())
+	if _, err := os.Stat(path); err == nil {
+		verbosef("Reusing the recording %s of %q", path, section)
+		return path, nil
+	}
+
+	var audio []byte
+	err := opts.retry.do(ctx, func() error {
+		attemptCtx := ctx
+		if opts.requestTimeout > 0 {
+			var cancel context.CancelFunc
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.requestTimeout)
+			defer cancel()
+		}
+		var err error
+		audio, err = opts.speech.synthesizer.Synthesize(attemptCtx, section)
+		return providerError(err)
+	})
+	if err != nil {
+		return "", fmt.Errorf("reading %q aloud: %w", section, err)
+	}
+	if err := os.MkdirAll(opts.speech.dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating the --tts-dir: %w", err)
+	}
+	if err := writeFileAtomic(path, audio, true); err != nil {
+		return "", fmt.Errorf("writing recording: %w", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,477 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// sqlitePageSize is the size of the pages of the SQLite databases written by
+// writeSQLite.
+const sqlitePageSize = 4096
+
+// sqliteTable is a table of a SQLite database, created by SQL, along with its
+// rows and its indexes. The values of a row are in the order of the columns
+// of the table; the value of an INTEGER PRIMARY KEY column is nil, as it is
+// the ID of the row.
+type sqliteTable struct {
+	Name    string
+	SQL     string
+	Rows    []sqliteRow
+	Indexes []sqliteIndex
+}
+
+// sqliteRow is a row of a sqliteTable. Its values are nil, int, int64,
+// float64, string or []byte.
+type sqliteRow struct {
+	ID     int64
+	Values []any
+}
+
+// sqliteIndex is an index of a sqliteTable, created by SQL, on the columns
+// of the table at Columns.
+type sqliteIndex struct {
+	Name    string
+	SQL     string
+	Columns []int
+}
+
+// sqliteFile is a SQLite database being written, as its pages. The first
+// page is the one of the schema, which is written last.
+type sqliteFile struct {
+	pages [][]byte
+}
+
+// writeSQLite writes a SQLite 3 database with tables, in the file format
+// that every version of SQLite reads, so that databases are written without
+// a SQLite library.
+func writeSQLite(tables []sqliteTable) ([]byte, error) {
+	f := &sqliteFile{pages: [][]byte{make([]byte, sqlitePageSize)}}
+	var schema []sqliteRow
+	for _, table := range tables {
+		root, err := f.tableTree(table.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("writing table %s: %w", table.Name, err)
+		}
+		schema = append(schema, sqliteRow{ID: int64(len(schema) + 1), Values: []any{"table", table.Name, table.Name, root, table.SQL}})
+		for _, index := range table.Indexes {
+			root, err := f.indexTree(table.Rows, index.Columns)
+			if err != nil {
+				return nil, fmt.Errorf("writing index %s: %w", index.Name, err)
+			}
+			schema = append(schema, sqliteRow{ID: int64(len(schema) + 1), Values: []any{"index", index.Name, table.Name, root, index.SQL}})
+		}
+	}
+
+	// The schema is on the first page, after the header of the database.
+	var cells [][]byte
+	for _, row := range schema {
+		cell, err := f.tableLeafCell(row)
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+	if !sqliteFits(100, 8, cells) {
+		return nil, errors.New("the schema of the database doesn't fit in its first page")
+	}
+	header := f.pages[0]
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], sqlitePageSize)
+	header[18], header[19] = 1, 1
+	// The fractions of a page that payloads may take.
+	header[21], header[22], header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(header[24:], 1)
+	binary.BigEndian.PutUint32(header[28:], uint32(len(f.pages)))
+	binary.BigEndian.PutUint32(header[40:], 1)
+	binary.BigEndian.PutUint32(header[44:], 4)
+	binary.BigEndian.PutUint32(header[56:], 1)
+	binary.BigEndian.PutUint32(header[92:], 1)
+	binary.BigEndian.PutUint32(header[96:], 3045000)
+	sqliteWritePage(header, 100, 0x0d, cells, 0)
+
+	return bytes.Join(f.pages, nil), nil
+}
+
+// alloc adds a page to the database, returning its number.
+func (f *sqliteFile) alloc() (uint32, []byte) {
+	page := make([]byte, sqlitePageSize)
+	f.pages = append(f.pages, page)
+	return uint32(len(f.pages)), page
+}
+
+// tableTree writes the b-tree of a table with rows, returning its root page.
+// Its leaves are filled in order, and every level above has a cell per
+// child but the last, keyed by the largest ID under it.
+func (f *sqliteFile) tableTree(rows []sqliteRow) (uint32, error) {
+	rows = append([]sqliteRow(nil), rows...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+
+	type child struct {
+		page uint32
+		key  int64
+	}
+	var level []child
+	var cells [][]byte
+	flush := func(key int64) {
+		number, page := f.alloc()
+		sqliteWritePage(page, 0, 0x0d, cells, 0)
+		level = append(level, child{number, key})
+		cells = nil
+	}
+	for i, row := range rows {
+		cell, err := f.tableLeafCell(row)
+		if err != nil {
+			return 0, err
+		}
+		if !sqliteFits(0, 8, append(cells, cell)) {
+			flush(rows[i-1].ID)
+		}
+		cells = append(cells, cell)
+	}
+	if len(rows) == 0 {
+		flush(0)
+	} else {
+		flush(rows[len(rows)-1].ID)
+	}
+
+	// An interior cell takes at most 4 bytes of child, 9 of key and 2 of
+	// pointer, so every interior page holds this many children.
+	const fanout = (sqlitePageSize-12)/15 + 1
+	for len(level) > 1 {
+		groups := (len(level) + fanout - 1) / fanout
+		var parents []child
+		for g := 0; g < groups; g++ {
+			group := level[g*len(level)/groups : (g+1)*len(level)/groups]
+			var cells [][]byte
+			for _, c := range group[:len(group)-1] {
+				cells = append(cells, binary.BigEndian.AppendUint32(nil, c.page))
+				cells[len(cells)-1] = sqliteAppendVarint(cells[len(cells)-1], uint64(c.key))
+			}
+			number, page := f.alloc()
+			last := group[len(group)-1]
+			sqliteWritePage(page, 0, 0x05, cells, last.page)
+			parents = append(parents, child{number, last.key})
+		}
+		level = parents
+	}
+	return level[0].page, nil
+}
+
+// indexTree writes the b-tree of the index on columns of a table with rows,
+// returning its root page. Every entry is the values of the columns along
+// with the ID of the row; the entries between the pages of a level are the
+// cells of the level above.
+func (f *sqliteFile) indexTree(rows []sqliteRow, columns []int) (uint32, error) {
+	entries := make([][]any, len(rows))
+	for i, row := range rows {
+		for _, column := range columns {
+			entries[i] = append(entries[i], row.Values[column])
+		}
+		entries[i] = append(entries[i], row.ID)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return sqliteCompareRecords(entries[i], entries[j]) < 0 })
+	cells := make([][]byte, len(entries))
+	for i, entry := range entries {
+		record, err := sqliteRecord(entry)
+		if err != nil {
+			return 0, err
+		}
+		cells[i] = f.payloadCell(nil, record, sqliteIndexMaxLocal)
+	}
+
+	// The leaves hold the cells between the separators.
+	var children []uint32
+	starts, separators := sqlitePack(cells, 0)
+	for i, start := range starts {
+		end := len(cells)
+		if i < len(separators) {
+			end = separators[i]
+		}
+		number, page := f.alloc()
+		sqliteWritePage(page, 0, 0x0a, cells[start:end], 0)
+		children = append(children, number)
+	}
+	var keys [][]byte
+	for _, separator := range separators {
+		keys = append(keys, cells[separator])
+	}
+
+	// Every interior cell is a child followed by the separator after it,
+	// with the last child the right pointer of its page.
+	for len(children) > 1 {
+		pairs := make([][]byte, len(keys))
+		for i, key := range keys {
+			pairs[i] = append(binary.BigEndian.AppendUint32(nil, children[i]), key...)
+		}
+		starts, separators := sqlitePack(pairs, 4)
+		var parents []uint32
+		var parentKeys [][]byte
+		for i, start := range starts {
+			end, right := len(pairs), children[len(children)-1]
+			if i < len(separators) {
+				end, right = separators[i], children[separators[i]]
+				parentKeys = append(parentKeys, keys[separators[i]])
+			}
+			number, page := f.alloc()
+			sqliteWritePage(page, 0, 0x02, pairs[start:end], right)
+			parents = append(parents, number)
+		}
+		children, keys = parents, parentKeys
+	}
+	return children[0], nil
+}
+
+// sqlitePack splits cells into pages, with a cell between every two pages
+// that goes up to the level above. It returns the first cell of every page
+// and the cells between them. headerExtra is the size of the right pointer
+// of interior pages.
+func sqlitePack(cells [][]byte, headerExtra int) (starts, separators []int) {
+	starts = []int{0}
+	size := 8 + headerExtra
+	for i := 0; i < len(cells); i++ {
+		if size+2+len(cells[i]) <= sqlitePageSize || i == starts[len(starts)-1] {
+			size += 2 + len(cells[i])
+			continue
+		}
+		// A page cannot be left empty, so the last cell is never a
+		// separator: the one before it is, and it has a page of its
+		// own.
+		if i == len(cells)-1 {
+			i--
+		}
+		separators = append(separators, i)
+		starts = append(starts, i+1)
+		size = 8 + headerExtra
+		i++
+		size += 2 + len(cells[i])
+	}
+	return starts, separators
+}
+
+// sqliteFits reports whether cells fit in a page with a header of
+// headerSize from offset.
+func sqliteFits(offset, headerSize int, cells [][]byte) bool {
+	size := offset + headerSize
+	for _, cell := range cells {
+		size += 2 + len(cell)
+	}
+	return size <= sqlitePageSize
+}
+
+// sqliteWritePage writes a b-tree page of kind with cells into page, from
+// offset; right is the right-most child of interior pages.
+func sqliteWritePage(page []byte, offset int, kind byte, cells [][]byte, right uint32) {
+	header := page[offset:]
+	header[0] = kind
+	binary.BigEndian.PutUint16(header[3:], uint16(len(cells)))
+	pointers := 8
+	if kind == 0x02 || kind == 0x05 {
+		binary.BigEndian.PutUint32(header[8:], right)
+		pointers = 12
+	}
+	content := sqlitePageSize
+	for i, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(header[pointers+2*i:], uint16(content))
+	}
+	// A content area starting at the end of the page is written as 0 only
+	// for 65536-byte pages.
+	binary.BigEndian.PutUint16(header[5:], uint16(content))
+}
+
+// The largest and the smallest parts of a payload kept in the cell of a
+// table leaf or of an index, the rest going to overflow pages.
+const (
+	sqliteTableMaxLocal = sqlitePageSize - 35
+	sqliteIndexMaxLocal = (sqlitePageSize-12)*64/255 - 23
+	sqliteMinLocal      = (sqlitePageSize-12)*32/255 - 23
+)
+
+// tableLeafCell returns the cell of row in a table leaf.
+func (f *sqliteFile) tableLeafCell(row sqliteRow) ([]byte, error) {
+	record, err := sqliteRecord(row.Values)
+	if err != nil {
+		return nil, err
+	}
+	cell := sqliteAppendVarint(nil, uint64(len(record)))
+	cell = sqliteAppendVarint(cell, uint64(row.ID))
+	return f.payloadCell(cell, record, sqliteTableMaxLocal), nil
+}
+
+// payloadCell appends the size of payload and as much of it as a cell keeps
+// to cell, writing the rest to overflow pages.
+func (f *sqliteFile) payloadCell(cell, payload []byte, maxLocal int) []byte {
+	if cell == nil {
+		cell = sqliteAppendVarint(nil, uint64(len(payload)))
+	}
+	if len(payload) <= maxLocal {
+		return append(cell, payload...)
+	}
+	local := sqliteMinLocal + (len(payload)-sqliteMinLocal)%(sqlitePageSize-4)
+	if local > maxLocal {
+		local = sqliteMinLocal
+	}
+	cell = append(cell, payload[:local]...)
+	rest := payload[local:]
+	first, page := f.alloc()
+	for {
+		n := copy(page[4:], rest)
+		rest = rest[n:]
+		if len(rest) == 0 {
+			break
+		}
+		var next uint32
+		next, page = f.alloc()
+		binary.BigEndian.PutUint32(f.pages[next-2], next)
+	}
+	return binary.BigEndian.AppendUint32(cell, first)
+}
+
+// sqliteRecord encodes values in the record format of SQLite: the serial
+// types of the values, then the values.
+func sqliteRecord(values []any) ([]byte, error) {
+	var types, body []byte
+	for _, value := range values {
+		switch value := value.(type) {
+		case nil:
+			types = sqliteAppendVarint(types, 0)
+		case int:
+			types, body = sqliteAppendInteger(types, body, int64(value))
+		case int64:
+			types, body = sqliteAppendInteger(types, body, value)
+		case uint32:
+			types, body = sqliteAppendInteger(types, body, int64(value))
+		case float64:
+			types = sqliteAppendVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(value))
+		case string:
+			types = sqliteAppendVarint(types, uint64(2*len(value)+13))
+			body = append(body, value...)
+		case []byte:
+			types = sqliteAppendVarint(types, uint64(2*len(value)+12))
+			body = append(body, value...)
+		default:
+			return nil, fmt.Errorf("unsupported SQLite value %T", value)
+		}
+	}
+	// The size of the header includes the varint of the size itself.
+	size := len(types) + 1
+	for len(sqliteAppendVarint(nil, uint64(size)))+len(types) != size {
+		size = len(sqliteAppendVarint(nil, uint64(size))) + len(types)
+	}
+	record := sqliteAppendVarint(nil, uint64(size))
+	record = append(record, types...)
+	return append(record, body...), nil
+}
+
+// sqliteAppendInteger appends the serial type and the bytes of value, in the
+// fewest bytes that hold it.
+func sqliteAppendInteger(types, body []byte, value int64) ([]byte, []byte) {
+	switch {
+	case value == 0:
+		return append(types, 8), body
+	case value == 1:
+		return append(types, 9), body
+	}
+	for serial, size := range []int{1, 2, 3, 4, 6, 8} {
+		limit := int64(1) << (8*size - 1)
+		if size == 8 || (value >= -limit && value < limit) {
+			for shift := 8 * (size - 1); shift >= 0; shift -= 8 {
+				body = append(body, byte(value>>shift))
+			}
+			return append(types, byte(serial+1)), body
+		}
+	}
+	return types, body
+}
+
+// sqliteAppendVarint appends v as a SQLite varint: big-endian groups of 7
+// bits, with the 9th byte, when there is one, holding 8.
+func sqliteAppendVarint(b []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var buf [9]byte
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	var groups []byte
+	for {
+		groups = append(groups, byte(v&0x7f))
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		if i > 0 {
+			groups[i] |= 0x80
+		}
+		b = append(b, groups[i])
+	}
+	return b
+}
+
+// sqliteCompareRecords compares the entries of an index value by value, the
+// way SQLite orders them: NULL first, then numbers, then text and blobs, in
+// bytes.
+func sqliteCompareRecords(a, b []any) int {
+	for i := range a {
+		if c := sqliteCompare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sqliteCompare compares two values of a record.
+func sqliteCompare(a, b any) int {
+	class := func(value any) (int, float64, []byte) {
+		switch value := value.(type) {
+		case int:
+			return 1, float64(value), nil
+		case int64:
+			return 1, float64(value), nil
+		case uint32:
+			return 1, float64(value), nil
+		case float64:
+			return 1, value, nil
+		case string:
+			return 2, 0, []byte(value)
+		case []byte:
+			return 3, 0, value
+		}
+		return 0, 0, nil
+	}
+	classA, numberA, bytesA := class(a)
+	classB, numberB, bytesB := class(b)
+	switch {
+	case classA != classB:
+		return classA - classB
+	case classA == 1 && numberA != numberB:
+		if numberA < numberB {
+			return -1
+		}
+		return 1
+	case classA == 1:
+		// Integers too large for a float64 to tell apart.
+		x, okA := a.(int64)
+		y, okB := b.(int64)
+		if okA && okB && x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	}
+	return bytes.Compare(bytesA, bytesB)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sqliteQuery runs query with the sqlite3 shell against the database at
+// path, skipping the test when the shell isn't installed.
+func sqliteQuery(t *testing.T, path, query string) string {
+	t.Helper()
+	shell, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	out, err := exec.Command(shell, "-batch", path, query).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 %q: %v\n%s", query, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// sqliteDepth returns the number of levels of the b-tree rooted at page,
+// following the right-most children down to a leaf.
+func sqliteDepth(t *testing.T, database []byte, page uint32) int {
+	t.Helper()
+	for depth := 1; ; depth++ {
+		data := database[(page-1)*sqlitePageSize:]
+		if page == 1 {
+			data = data[100:]
+		}
+		switch data[0] {
+		case 0x0a, 0x0d:
+			return depth
+		case 0x02, 0x05:
+			page = binary.BigEndian.Uint32(data[8:])
+		default:
+			t.Fatalf("page %d has kind %#x", page, data[0])
+		}
+	}
+}
+
+// sqliteTestText returns the text of the row with id, long enough for the
+// rows to take many pages, and much longer than a page for every 500th row.
+func sqliteTestText(id int) string {
+	text := fmt.Sprintf("section %05d ", id) + strings.Repeat("abcdefghij", 20)
+	if id%500 == 0 {
+		text += strings.Repeat(fmt.Sprintf("%d", id%10), 3*sqlitePageSize+id)
+	}
+	return text
+}
+
+func TestWriteSQLiteRoundTrip(t *testing.T) {
+	const count = 6000
+	var rows []sqliteRow
+	var sum, textLength int
+	for id := 1; id <= count; id++ {
+		text := sqliteTestText(id)
+		var blob any = []byte{byte(id), byte(id >> 8)}
+		if id%7 == 0 {
+			blob = nil
+		}
+		rows = append(rows, sqliteRow{ID: int64(id), Values: []any{nil, text, id * 3, float64(id) / 2, blob}})
+		sum += id * 3
+		textLength += len(text)
+	}
+	database, err := writeSQLite([]sqliteTable{{
+		Name:    "items",
+		SQL:     "CREATE TABLE items (id INTEGER PRIMARY KEY, text TEXT, n INTEGER, f REAL, b BLOB)",
+		Rows:    rows,
+		Indexes: []sqliteIndex{{Name: "items_text", SQL: "CREATE INDEX items_text ON items (text)", Columns: []int{1}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "items.db")
+	if err := os.WriteFile(path, database, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := sqliteQuery(t, path, "PRAGMA integrity_check;"); got != "ok" {
+		t.Fatalf("integrity check: %s", got)
+	}
+	want := fmt.Sprintf("%d|%d|%d|%d", count, sum, textLength, count-count/7)
+	if got := sqliteQuery(t, path, "SELECT count(*), sum(n), sum(length(text)), count(b) FROM items;"); got != want {
+		t.Errorf("totals = %s, want %s", got, want)
+	}
+	if got, want := sqliteQuery(t, path, "SELECT f, hex(b) FROM items WHERE id = 300;"), "150.0|2C01"; got != want {
+		t.Errorf("row 300 = %s, want %s", got, want)
+	}
+
+	// The long texts are spread over overflow pages, both in the table and
+	// in the index.
+	for _, id := range []int{500, 3000} {
+		query := fmt.Sprintf("SELECT text FROM items WHERE id = %d;", id)
+		if got := sqliteQuery(t, path, query); got != sqliteTestText(id) {
+			t.Errorf("text of row %d has %d bytes, want %d", id, len(got), len(sqliteTestText(id)))
+		}
+		query = fmt.Sprintf("SELECT id FROM items INDEXED BY items_text WHERE text = '%s';", sqliteTestText(id))
+		if got := sqliteQuery(t, path, query); got != strconv.Itoa(id) {
+			t.Errorf("index lookup of row %d = %s", id, got)
+		}
+	}
+	if got, want := sqliteQuery(t, path, "SELECT id FROM items INDEXED BY items_text WHERE text > 'section 05998' ORDER BY text;"), "5998\n5999\n6000"; got != want {
+		t.Errorf("index scan = %q, want %q", got, want)
+	}
+
+	// Both trees are deep enough to have interior pages over interior
+	// pages.
+	roots := strings.Fields(sqliteQuery(t, path, "SELECT rootpage FROM sqlite_schema ORDER BY type DESC;"))
+	for _, root := range roots {
+		page, err := strconv.ParseUint(root, 10, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if depth := sqliteDepth(t, database, uint32(page)); depth < 3 {
+			t.Errorf("b-tree rooted at page %d has %d levels, want at least 3", page, depth)
+		}
+	}
+}
+
+func TestWriteSQLiteEmptyTable(t *testing.T) {
+	database, err := writeSQLite([]sqliteTable{{Name: "empty", SQL: "CREATE TABLE empty (id INTEGER PRIMARY KEY, text TEXT)"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "empty.db")
+	if err := os.WriteFile(path, database, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := sqliteQuery(t, path, "PRAGMA integrity_check; SELECT count(*) FROM empty;"); got != "ok\n0" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriteSQLiteUnsupportedValue(t *testing.T) {
+	_, err := writeSQLite([]sqliteTable{{
+		Name: "items",
+		SQL:  "CREATE TABLE items (id INTEGER PRIMARY KEY, ok INTEGER)",
+		Rows: []sqliteRow{{ID: 1, Values: []any{nil, true}}},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "unsupported SQLite value bool") {
+		t.Fatalf("err = %v, want an unsupported value", err)
+	}
+}
@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// srsInitialEase is the ease factor of a new card, as in SM-2.
+const srsInitialEase = 2.5
+
+// srsMinEase is the lowest ease factor SM-2 lets a card reach.
+const srsMinEase = 1.3
+
+// srsCard is a card of the review deck along with its SM-2 schedule.
+type srsCard struct {
+	Front string `json:"front"`
+	Back  string `json:"back"`
+	Notes string `json:"notes,omitempty"`
+	// Deck is the name of the results the card was added from.
+	Deck  string    `json:"deck,omitempty"`
+	Added time.Time `json:"added"`
+	Due   time.Time `json:"due"`
+	// Interval is the number of days until the next review, Repetitions the
+	// number of successful reviews in a row and Ease the SM-2 ease factor.
+	Interval    int     `json:"interval"`
+	Repetitions int     `json:"repetitions"`
+	Ease        float64 `json:"ease"`
+	Lapses      int     `json:"lapses"`
+}
+
+// srsDeck is the file the review cards are stored in.
+type srsDeck struct {
+	Cards []srsCard `json:"cards"`
+}
+
+var srsCmd = &cobra.Command{
+	Use:   "srs",
+	Short: "Study the results of analise or vocab with spaced repetition",
+	Long: `The "srs" commands keep a local deck of cards scheduled with the SM-2 algorithm: "srs add" adds the sections of analise results, or the words of vocab results, as cards, and "srs review" asks about the cards that are due today.
+Every card is asked again after a number of days that grows with every successful review, and goes back to being asked the next day when it is forgotten. The deck is stored at --deck, in the config directory unless STARTER_GO_CLI_SRS_DECK or srs_deck in the config file say otherwise.`,
+}
+
+var srsAddCmd = &cobra.Command{
+	Use:   "add <results.json>...",
+	Short: "Add the results of analise or vocab to the review deck",
+	Long: `The "add" command reads the JSON results of "analise" or "vocab" (from the given files, or stdin when the argument is "-") and adds every section or word to the deck as a new card, due right away.
+Cards already in the deck, with the same front and back, are left as they are.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSRSAdd,
+}
+
+var srsReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review the cards of the deck that are due",
+	Long: `The "review" command shows the front of every due card, the ones due the longest first, and reveals its back once Enter is pressed. Grade how well you remembered it from 0 to 5, as in SM-2:
+0 - not at all, 1 - wrong, but it rang a bell, 2 - wrong, but easy once seen, 3 - right, with serious difficulty, 4 - right, after some hesitation, 5 - right, easily.
+Cards graded below 4 are asked again at the end of the review. The deck is saved after every answer, so ending the input (Ctrl+D) stops the review without losing any progress.`,
+	Args: cobra.NoArgs,
+	RunE: runSRSReview,
+}
+
+// defaultSRSDeckPath returns the location of the deck used unless --deck,
+// STARTER_GO_CLI_SRS_DECK or the config file say otherwise.
+func defaultSRSDeckPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "starter-go-cli", "srs.json")
+}
+
+// resolveSRSDeckPath returns the location of the deck.
+func resolveSRSDeckPath(cmd *cobra.Command) (string, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return "", err
+	}
+	path, err := resolveSetting(cmd, "deck", "STARTER_GO_CLI_SRS_DECK", cfg.SRSDeck, defaultSRSDeckPath())
+	if err != nil {
+		return "", fmt.Errorf("retrieving deck flag: %w", err)
+	}
+	if path == "" {
+		return "", errors.New("no location for the review deck: set it with --deck")
+	}
+	return path, nil
+}
+
+// loadSRSDeck reads the deck at path, which is empty when the file does not
+// exist yet.
+func loadSRSDeck(path string) (*srsDeck, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &srsDeck{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var deck srsDeck
+	if err := json.Unmarshal(data, &deck); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &deck, nil
+}
+
+// save writes the deck to path, creating its directory if needed.
+func (d *srsDeck) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), true)
+}
+
+// schedule updates the SM-2 schedule of card for a review graded quality,
+// from 0 to 5, at now.
+func (c *srsCard) schedule(quality int, now time.Time) {
+	if quality < 3 {
+		c.Repetitions, c.Interval = 0, 1
+		c.Lapses++
+	} else {
+		switch c.Repetitions {
+		case 0:
+			c.Interval = 1
+		case 1:
+			c.Interval = 6
+		default:
+			c.Interval = int(math.Round(float64(c.Interval) * c.Ease))
+		}
+		c.Repetitions++
+	}
+	miss := float64(5 - quality)
+	c.Ease = max(srsMinEase, c.Ease+0.1-miss*(0.08+miss*0.02))
+	c.Due = now.AddDate(0, 0, c.Interval)
+}
+
+func runSRSAdd(cmd *cobra.Command, args []string) error {
+	path, err := resolveSRSDeckPath(cmd)
+	if err != nil {
+		return err
+	}
+	deck, err := loadSRSDeck(path)
+	if err != nil {
+		return fmt.Errorf("loading review deck: %w", err)
+	}
+
+	stored := make(map[string]bool, len(deck.Cards))
+	for _, card := range deck.Cards {
+		stored[card.Front+"\x00"+card.Back] = true
+	}
+	now := time.Now()
+	added, skipped := 0, 0
+	for _, resultsPath := range args {
+		data, name, err := readResults(resultsPath)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		cards, err := parseFlashcards(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		deckName := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+		for _, card := range cards {
+			key := card.Front + "\x00" + card.Back
+			if stored[key] {
+				skipped++
+				continue
+			}
+			stored[key] = true
+			deck.Cards = append(deck.Cards, srsCard{
+				Front: card.Front,
+				Back:  card.Back,
+				Notes: card.Notes,
+				Deck:  deckName,
+				Added: now,
+				Due:   now,
+				Ease:  srsInitialEase,
+			})
+			added++
+		}
+	}
+
+	if err := deck.save(path); err != nil {
+		return fmt.Errorf("saving review deck: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Added %d cards to %s", added, path)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d were already in the deck)", skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+func runSRSReview(cmd *cobra.Command, args []string) error {
+	limit, err := cmd.Flags().GetInt("limit")
+	if err != nil {
+		return fmt.Errorf("retrieving limit flag: %w", err)
+	}
+	if limit < 0 {
+		return errors.New("--limit cannot be negative")
+	}
+	path, err := resolveSRSDeckPath(cmd)
+	if err != nil {
+		return err
+	}
+	deck, err := loadSRSDeck(path)
+	if err != nil {
+		return fmt.Errorf("loading review deck: %w", err)
+	}
+
+	out := cmd.OutOrStdout()
+	now := time.Now()
+	var queue []int
+	for i, card := range deck.Cards {
+		if !card.Due.After(now) {
+			queue = append(queue, i)
+		}
+	}
+	if len(queue) == 0 {
+		writeNextReview(out, deck)
+		return nil
+	}
+	sort.SliceStable(queue, func(a, b int) bool {
+		return deck.Cards[queue[a]].Due.Before(deck.Cards[queue[b]].Due)
+	})
+	if limit > 0 && limit < len(queue) {
+		queue = queue[:limit]
+	}
+
+	// Only the first grade of a card schedules it; the repetitions of the
+	// cards graded below 4 at the end of the review don't.
+	in := bufio.NewReader(cmd.InOrStdin())
+	due := len(queue)
+	graded := make(map[int]bool)
+	reviewed := 0
+	for n := 0; n < len(queue); n++ {
+		card := &deck.Cards[queue[n]]
+		if n < due {
+			fmt.Fprintf(out, "\n[%d/%d] %s\n", n+1, due, card.Front)
+		} else {
+			fmt.Fprintf(out, "\n[again] %s\n", card.Front)
+		}
+		fmt.Fprint(out, "(press Enter to show the answer) ")
+		if _, err := in.ReadString('\n'); err != nil {
+			fmt.Fprintln(out)
+			break
+		}
+		fmt.Fprintln(out, card.Back)
+		if card.Notes != "" {
+			fmt.Fprintln(out, card.Notes)
+		}
+
+		quality, err := readGrade(out, in)
+		if err != nil {
+			fmt.Fprintln(out)
+			break
+		}
+		if !graded[queue[n]] {
+			graded[queue[n]] = true
+			card.schedule(quality, time.Now())
+			if err := deck.save(path); err != nil {
+				return fmt.Errorf("saving review deck: %w", err)
+			}
+			reviewed++
+		}
+		if quality < 4 {
+			queue = append(queue, queue[n])
+		}
+	}
+
+	fmt.Fprintf(out, "\nReviewed %d of %d due cards.\n", reviewed, due)
+	writeNextReview(out, deck)
+	return nil
+}
+
+// readGrade asks for the SM-2 grade of a card until a valid one is typed,
+// failing at the end of the input.
+func readGrade(out io.Writer, in *bufio.Reader) (int, error) {
+	for {
+		fmt.Fprint(out, "How well did you remember it? [0-5] ")
+		line, err := in.ReadString('\n')
+		if quality, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && quality >= 0 && quality <= 5 {
+			return quality, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// writeNextReview writes when the next card of deck is due.
+func writeNextReview(w io.Writer, deck *srsDeck) {
+	if len(deck.Cards) == 0 {
+		fmt.Fprintln(w, `The review deck is empty; add results to it with "srs add".`)
+		return
+	}
+	next := deck.Cards[0].Due
+	for _, card := range deck.Cards[1:] {
+		if card.Due.Before(next) {
+			next = card.Due
+		}
+	}
+	if !next.After(time.Now()) {
+		fmt.Fprintln(w, "There are cards due now.")
+		return
+	}
+	fmt.Fprintf(w, "The next card is due on %s.\n", next.Local().Format("Mon Jan 2 15:04"))
+}
+
+func init() {
+	srsCmd.PersistentFlags().String("deck", "", "The file the review deck is stored in (default is srs.json in the config directory)")
+	srsReviewCmd.Flags().Int("limit", 0, "The maximum number of due cards reviewed (0 reviews all of them)")
+
+	srsCmd.AddCommand(srsAddCmd)
+	srsCmd.AddCommand(srsReviewCmd)
+	rootCmd.AddCommand(srsCmd)
+}
@@ -0,0 +1,517 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// subtitleTag matches the formatting tags of SRT and VTT cues, such as <i>,
+// <font color="red"> or <v Roger>, and the override blocks of ASS events,
+// such as {\an8} or {\i1}.
+var subtitleTag = regexp.MustCompile(`</?[a-zA-Z][^>]*>|\{\\[^}]*\}`)
+
+// srtBlockSeparator matches the blank lines between the cues of SRT and VTT
+// files.
+var srtBlockSeparator = regexp.MustCompile(`\n\s*\n`)
+
+// subtitleFile is the content of a subtitle file.
+type subtitleFile struct {
+	// Format is "srt", "vtt" or "ass".
+	Format string
+	// Header holds the blocks of a VTT file before its first cue: the WEBVTT
+	// line, then any STYLE, REGION and NOTE blocks.
+	Header []string
+	Cues   []subtitleCue
+}
+
+// subtitleCue is a caption of a subtitle file, shown from Start to End.
+type subtitleCue struct {
+	// ID is the counter of an SRT cue or the optional identifier of a VTT
+	// cue, and Settings the VTT cue settings following its timing, such as
+	// "align:start line:0".
+	ID       string
+	Start    string
+	End      string
+	Settings string
+	// Lines are the lines of the caption, with their formatting tags.
+	Lines []string
+}
+
+// plainText returns the text of the cue on a single line, without its
+// formatting tags.
+func (c subtitleCue) plainText() string {
+	text := strings.Join(c.Lines, " ")
+	text = strings.NewReplacer(`\N`, " ", `\n`, " ", `\h`, " ").Replace(text)
+	return strings.Join(strings.Fields(subtitleTag.ReplaceAllString(text, "")), " ")
+}
+
+// readSubtitles reads an .srt, .vtt or .ass subtitle file.
+func readSubtitles(path string) (subtitleFile, error) {
+	text, err := readInputFile(path)
+	if err != nil {
+		return subtitleFile{}, err
+	}
+	var file subtitleFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".srt":
+		file.Format = "srt"
+		file.Cues, err = parseSRT(text)
+	case ".vtt":
+		file.Format = "vtt"
+		file.Header, file.Cues, err = parseVTT(text)
+	case ".ass", ".ssa":
+		file.Format = "ass"
+		file.Cues, err = parseASS(text)
+	default:
+		err = errors.New("unsupported subtitle format (expected .srt, .vtt or .ass)")
+	}
+	if err != nil {
+		return subtitleFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// parseSRT parses the cues of SubRip subtitles: blocks of a counter, the
+// "start --> end" timing and the lines of the caption, separated by blank
+// lines.
+func parseSRT(text string) ([]subtitleCue, error) {
+	var cues []subtitleCue
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, block := range srtBlockSeparator.Split(strings.TrimSpace(text), -1) {
+		if cue, ok := parseCueBlock(block); ok {
+			cues = append(cues, cue)
+		}
+	}
+	if len(cues) == 0 {
+		return nil, errors.New("no SRT cues found")
+	}
+	return cues, nil
+}
+
+// parseVTT parses the cues of WebVTT subtitles, which are laid out like the
+// SRT ones but with optional identifiers and cue settings, after a header
+// starting with WEBVTT.
+func parseVTT(text string) ([]string, []subtitleCue, error) {
+	blocks := srtBlockSeparator.Split(strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n")), -1)
+	if !strings.HasPrefix(blocks[0], "WEBVTT") {
+		return nil, nil, errors.New("missing WEBVTT header")
+	}
+	var header []string
+	var cues []subtitleCue
+	for _, block := range blocks {
+		cue, ok := parseCueBlock(block)
+		switch {
+		case ok:
+			cues = append(cues, cue)
+		case len(cues) == 0:
+			header = append(header, strings.TrimSpace(block))
+		}
+	}
+	if len(cues) == 0 {
+		return nil, nil, errors.New("no VTT cues found")
+	}
+	return header, cues, nil
+}
+
+// parseCueBlock parses a block of an SRT or VTT file, reporting false when
+// it has no timing, such as the header and the NOTE blocks of VTT files.
+func parseCueBlock(block string) (subtitleCue, bool) {
+	lines := strings.Split(strings.TrimSpace(block), "\n")
+	timing := 0
+	for timing < len(lines) && !strings.Contains(lines[timing], "-->") {
+		timing++
+	}
+	if timing == len(lines) || strings.HasPrefix(lines[0], "NOTE") {
+		return subtitleCue{}, false
+	}
+	start, end, _ := strings.Cut(lines[timing], "-->")
+	cue := subtitleCue{Start: strings.TrimSpace(start), Lines: lines[timing+1:]}
+	if timing > 0 {
+		cue.ID = strings.TrimSpace(lines[timing-1])
+	}
+	fields := strings.Fields(end)
+	if len(fields) > 0 {
+		cue.End, cue.Settings = fields[0], strings.Join(fields[1:], " ")
+	}
+	return cue, true
+}
+
+// parseASS parses the Dialogue events of Advanced SubStation Alpha
+// subtitles, whose fields are named by the Format line of their section.
+func parseASS(text string) ([]subtitleCue, error) {
+	var cues []subtitleCue
+	var format []string
+	events := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			events = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !events || !ok {
+			continue
+		}
+		switch key {
+		case "Format":
+			format = strings.Split(value, ",")
+			for i := range format {
+				format[i] = strings.TrimSpace(format[i])
+			}
+		case "Dialogue":
+			if len(format) == 0 {
+				return nil, errors.New("ASS Dialogue event before the Format line")
+			}
+			// The text is the last field and may contain commas.
+			fields := strings.SplitN(value, ",", len(format))
+			if len(fields) < len(format) {
+				continue
+			}
+			var cue subtitleCue
+			for i, name := range format {
+				switch name {
+				case "Start":
+					cue.Start = strings.TrimSpace(fields[i])
+				case "End":
+					cue.End = strings.TrimSpace(fields[i])
+				case "Text":
+					cue.Lines = []string{fields[i]}
+				}
+			}
+			cues = append(cues, cue)
+		}
+	}
+	if len(cues) == 0 {
+		return nil, errors.New("no ASS Dialogue events found")
+	}
+	return cues, nil
+}
+
+// writeSubtitles writes the cues of file as an SRT or VTT file, converting
+// their timestamps to the format. SRT cues are numbered again from 1, and
+// VTT files keep the header of file when it has one.
+func writeSubtitles(w io.Writer, file subtitleFile, format string) error {
+	if format == "vtt" {
+		header := file.Header
+		if len(header) == 0 {
+			header = []string{"WEBVTT"}
+		}
+		for _, block := range header {
+			if _, err := fmt.Fprintf(w, "%s\n\n", block); err != nil {
+				return err
+			}
+		}
+	}
+	for i, cue := range file.Cues {
+		timing := subtitleTimestamp(cue.Start, format) + " --> " + subtitleTimestamp(cue.End, format)
+		id := fmt.Sprint(i + 1)
+		if format == "vtt" {
+			id = cue.ID
+			if cue.Settings != "" {
+				timing += " " + cue.Settings
+			}
+		}
+		if id != "" {
+			if _, err := fmt.Fprintln(w, id); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s\n%s\n\n", timing, strings.Join(cue.Lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subtitleTimestamp writes the timestamp of an SRT or VTT cue in format:
+// SRT separates the milliseconds with a comma and always has the hours,
+// which VTT may leave out.
+func subtitleTimestamp(timestamp, format string) string {
+	if format == "vtt" {
+		return strings.Replace(timestamp, ",", ".", 1)
+	}
+	if strings.Count(timestamp, ":") == 1 {
+		timestamp = "00:" + timestamp
+	}
+	return strings.Replace(timestamp, ".", ",", 1)
+}
+
+var subtitlesCmd = &cobra.Command{
+	Use:   "subtitles",
+	Short: "Work with .srt and .vtt subtitle files",
+	Long: `The "subtitles" commands read subtitle files in the SubRip (.srt) and WebVTT (.vtt) formats and write them back with their timing untouched: "subtitles translate" translates their captions.
+Use "mine" to find the sentences of subtitle files worth learning from.`,
+}
+
+var subtitlesTranslateCmd = &cobra.Command{
+	Use:   "translate <subtitles>",
+	Short: "Translate the captions of a subtitle file, keeping its timing",
+	Long: `The "translate" command reads an .srt or .vtt subtitle file and translates every caption into the --translation-language, keeping the timing of the cues, their VTT identifiers and settings, and the formatting tags around them, such as <i> or <font>. Tags within a caption are kept when the translation has them all, and dropped otherwise.
+The translations are wrapped into lines of at most --max-line-length characters, and the ones longer than --max-lines such lines are shortened by the LLM to fit; the ones still too long are kept on more lines, with a warning. Captions of a line per speaker, each starting with a dash, are translated line by line.
+The subtitles are written to stdout, or to --output, in the format of the input file unless --format says otherwise.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSubtitlesTranslate,
+}
+
+func runSubtitlesTranslate(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "" && format != "srt" && format != "vtt" {
+		return fmt.Errorf("unsupported format %q (expected srt or vtt)", format)
+	}
+	maxLineLength, err := cmd.Flags().GetInt("max-line-length")
+	if err != nil {
+		return fmt.Errorf("retrieving max-line-length flag: %w", err)
+	}
+	maxLines, err := cmd.Flags().GetInt("max-lines")
+	if err != nil {
+		return fmt.Errorf("retrieving max-lines flag: %w", err)
+	}
+	if maxLineLength < 1 || maxLines < 1 {
+		return errors.New("--max-line-length and --max-lines must be at least 1")
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	file, err := readSubtitles(args[0])
+	if err != nil {
+		return fmt.Errorf("reading subtitles: %w", err)
+	}
+	if file.Format == "ass" {
+		return fmt.Errorf("%s: translating .ass subtitles is not supported (expected .srt or .vtt)", args[0])
+	}
+	if format == "" {
+		format = file.Format
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(opts.translationLanguages) > 1 {
+		return errors.New("subtitles translate requires a single --translation-language")
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	if opts.sourceLanguage == "" {
+		captions := make([]string, len(file.Cues))
+		for i, cue := range file.Cues {
+			captions[i] = cue.plainText()
+		}
+		if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(captions, " "), readabilitySampleWords), opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+	}
+
+	opts.progress.Start(len(file.Cues))
+	translate := func(_ int, cue subtitleCue) (subtitleCue, error) {
+		opts.progress.Begin(cue.plainText())
+		cue, err := translateCue(ctx, cue, maxLineLength, maxLines, opts)
+		if err != nil {
+			return subtitleCue{}, err
+		}
+		opts.progress.Advance()
+		return cue, nil
+	}
+	file.Cues, err = analyze.RunOrdered(file.Cues, opts.concurrency, translate, nil)
+	opts.progress.Finish()
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	var b bytes.Buffer
+	if err := writeSubtitles(&b, file, format); err != nil {
+		return fmt.Errorf("writing subtitles: %w", err)
+	}
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d cues to %s\n", len(file.Cues), outputPath)
+	return nil
+}
+
+// translateCue translates the caption of cue and wraps it into lines of at
+// most maxLineLength characters. The lines of dialogue cues are translated
+// on their own.
+func translateCue(ctx context.Context, cue subtitleCue, maxLineLength, maxLines int, opts analysisOptions) (subtitleCue, error) {
+	dialogue := len(cue.Lines) > 1
+	for _, line := range cue.Lines {
+		_, inner, _ := splitOuterTags(line)
+		dialogue = dialogue && strings.HasPrefix(inner, "-")
+	}
+	if dialogue {
+		lines := make([]string, len(cue.Lines))
+		for i, line := range cue.Lines {
+			translation, err := translateCaption(ctx, line, maxLineLength, opts)
+			if err != nil {
+				return subtitleCue{}, err
+			}
+			lines[i] = translation
+		}
+		cue.Lines = lines
+		return cue, nil
+	}
+
+	translation, err := translateCaption(ctx, strings.Join(cue.Lines, " "), maxLineLength*maxLines, opts)
+	if err != nil {
+		return subtitleCue{}, err
+	}
+	cue.Lines = wrapCaption(translation, maxLineLength)
+	if len(cue.Lines) > maxLines {
+		fmt.Fprintf(os.Stderr, "Warning: the cue at %s is %d lines long after shortening\n", cue.Start, len(cue.Lines))
+	}
+	return cue, nil
+}
+
+// translateCaption translates caption, keeping the formatting tags around
+// it, and has the translation shortened when it is longer than maxLength
+// characters. Captions without any letters, such as music notes, are kept as
+// they are.
+func translateCaption(ctx context.Context, caption string, maxLength int, opts analysisOptions) (string, error) {
+	prefix, inner, suffix := splitOuterTags(caption)
+	if strings.IndexFunc(inner, unicode.IsLetter) < 0 {
+		return caption, nil
+	}
+	translation, err := translateSection(ctx, inner, opts.sourceLanguage, opts.translationLanguage, opts.glossary, opts)
+	if err != nil {
+		return "", err
+	}
+	translation = keepCaptionTags(inner, translation)
+	if captionLength(translation) > maxLength {
+		if translation, err = shortenCaption(ctx, translation, maxLength, opts); err != nil {
+			return "", err
+		}
+	}
+	return prefix + translation + suffix, nil
+}
+
+// shortenCaption asks the LLM to shorten caption to maxLength characters,
+// keeping the shortest of the two.
+func shortenCaption(ctx context.Context, caption string, maxLength int, opts analysisOptions) (string, error) {
+	req, err := renderPrompt(defaultShortenCaptionPrompt, opts.translateModel, promptData{Text: caption, Language: opts.translationLanguage, Count: maxLength})
+	if err != nil {
+		return "", err
+	}
+	shortened, err := generate(ctx, opts, req)
+	if err != nil {
+		return "", fmt.Errorf("shortening %q: %w", caption, err)
+	}
+	shortened = keepCaptionTags(caption, strings.TrimSpace(shortened))
+	if shortened == "" || captionLength(shortened) >= captionLength(caption) {
+		return caption, nil
+	}
+	return shortened, nil
+}
+
+// splitOuterTags splits the formatting tags opening and closing caption from
+// the text between them.
+func splitOuterTags(caption string) (prefix, inner, suffix string) {
+	inner = strings.TrimSpace(caption)
+	for {
+		loc := subtitleTag.FindStringIndex(inner)
+		if loc == nil || loc[0] != 0 {
+			break
+		}
+		prefix += inner[:loc[1]]
+		inner = inner[loc[1]:]
+	}
+	for {
+		matches := subtitleTag.FindAllStringIndex(inner, -1)
+		if len(matches) == 0 || matches[len(matches)-1][1] != len(inner) {
+			break
+		}
+		last := matches[len(matches)-1][0]
+		suffix = inner[last:] + suffix
+		inner = inner[:last]
+	}
+	return prefix, strings.TrimSpace(inner), suffix
+}
+
+// keepCaptionTags returns translation without any formatting tags unless it
+// has as many of them as caption.
+func keepCaptionTags(caption, translation string) string {
+	if len(subtitleTag.FindAllString(translation, -1)) == len(subtitleTag.FindAllString(caption, -1)) {
+		return translation
+	}
+	return strings.Join(strings.Fields(subtitleTag.ReplaceAllString(translation, "")), " ")
+}
+
+// captionLength is the number of characters of caption shown on screen,
+// without its formatting tags.
+func captionLength(caption string) int {
+	return utf8.RuneCountInString(subtitleTag.ReplaceAllString(caption, ""))
+}
+
+// wrapCaption splits caption into lines of at most maxLineLength
+// characters. Captions fitting on two lines are split where the lines have
+// the closest lengths, which is easier to read, and longer ones are filled
+// line by line. A word longer than a line is a line of its own.
+func wrapCaption(caption string, maxLineLength int) []string {
+	words := strings.Fields(caption)
+	if captionLength(caption) <= maxLineLength || len(words) < 2 {
+		return []string{strings.Join(words, " ")}
+	}
+	best, bestDifference := 0, -1
+	for i := 1; i < len(words); i++ {
+		first, second := captionLength(strings.Join(words[:i], " ")), captionLength(strings.Join(words[i:], " "))
+		difference := max(first-second, second-first)
+		if first <= maxLineLength && second <= maxLineLength && (bestDifference < 0 || difference < bestDifference) {
+			best, bestDifference = i, difference
+		}
+	}
+	if bestDifference >= 0 {
+		return []string{strings.Join(words[:best], " "), strings.Join(words[best:], " ")}
+	}
+
+	var lines []string
+	line := words[0]
+	for _, word := range words[1:] {
+		if captionLength(line+" "+word) > maxLineLength {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line += " " + word
+	}
+	return append(lines, line)
+}
+
+func init() {
+	addAnalysisFlags(subtitlesTranslateCmd.Flags())
+	subtitlesTranslateCmd.Flags().String("format", "", "The format of the translated subtitles: srt or vtt (default is the format of the input file)")
+	subtitlesTranslateCmd.Flags().Int("max-line-length", 42, "The maximum number of characters of a line of a caption")
+	subtitlesTranslateCmd.Flags().Int("max-lines", 2, "The maximum number of lines of a caption")
+	subtitlesTranslateCmd.Flags().StringP("output", "o", "", "Write the subtitles to this file instead of stdout")
+	subtitlesTranslateCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	subtitlesCmd.AddCommand(subtitlesTranslateCmd)
+	rootCmd.AddCommand(subtitlesCmd)
+}
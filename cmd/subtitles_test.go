@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const srtFixture = `1
+00:00:01,000 --> 00:00:03,500
+Guten Morgen!
+
+2
+00:00:04,000 --> 00:00:06,250
+<i>Wie geht es dir?</i>
+- Gut, danke.
+
+3
+01:02:03,004 --> 01:02:05,000
+Bis später.
+
+`
+
+const vttFixture = `WEBVTT - Lektion 1
+
+STYLE
+::cue { color: yellow; }
+
+NOTE Erstellt von Hand
+
+intro
+00:01.000 --> 00:03.500 align:start line:0
+Guten Morgen!
+
+00:00:04.000 --> 00:00:06.250
+<v Anna>Wie geht es dir?
+- Gut, danke.
+
+`
+
+func TestSRTRoundTrip(t *testing.T) {
+	cues, err := parseSRT(srtFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []subtitleCue{
+		{ID: "1", Start: "00:00:01,000", End: "00:00:03,500", Lines: []string{"Guten Morgen!"}},
+		{ID: "2", Start: "00:00:04,000", End: "00:00:06,250", Lines: []string{"<i>Wie geht es dir?</i>", "- Gut, danke."}},
+		{ID: "3", Start: "01:02:03,004", End: "01:02:05,000", Lines: []string{"Bis später."}},
+	}
+	if !reflect.DeepEqual(cues, want) {
+		t.Errorf("cues = %+v, want %+v", cues, want)
+	}
+
+	var out strings.Builder
+	if err := writeSubtitles(&out, subtitleFile{Format: "srt", Cues: cues}, "srt"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != srtFixture {
+		t.Errorf("wrote %q, want %q", out.String(), srtFixture)
+	}
+}
+
+func TestSRTNumbering(t *testing.T) {
+	// Cues are numbered again from 1, whatever their counters, and files with
+	// CRLF line endings or extra blank lines read the same.
+	text := "7\r\n00:00:01,000 --> 00:00:02,000\r\nEins\r\n\r\n\r\n3\r\n00:00:02,000 --> 00:00:03,000\r\nZwei\r\nDrei\r\n"
+	cues, err := parseSRT(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := writeSubtitles(&out, subtitleFile{Format: "srt", Cues: cues}, "srt"); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:01,000 --> 00:00:02,000\nEins\n\n2\n00:00:02,000 --> 00:00:03,000\nZwei\nDrei\n\n"
+	if out.String() != want {
+		t.Errorf("wrote %q, want %q", out.String(), want)
+	}
+
+	for _, text := range []string{"", "1\nno timing here\n", "\n\n"} {
+		if cues, err := parseSRT(text); err == nil {
+			t.Errorf("parseSRT(%q) = %+v, want an error", text, cues)
+		}
+	}
+}
+
+func TestVTTRoundTrip(t *testing.T) {
+	header, cues, err := parseVTT(vttFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"WEBVTT - Lektion 1", "STYLE\n::cue { color: yellow; }", "NOTE Erstellt von Hand"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %q, want %q", header, want)
+	}
+	want := []subtitleCue{
+		{ID: "intro", Start: "00:01.000", End: "00:03.500", Settings: "align:start line:0", Lines: []string{"Guten Morgen!"}},
+		{Start: "00:00:04.000", End: "00:00:06.250", Lines: []string{"<v Anna>Wie geht es dir?", "- Gut, danke."}},
+	}
+	if !reflect.DeepEqual(cues, want) {
+		t.Errorf("cues = %+v, want %+v", cues, want)
+	}
+
+	var out strings.Builder
+	if err := writeSubtitles(&out, subtitleFile{Format: "vtt", Header: header, Cues: cues}, "vtt"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != vttFixture {
+		t.Errorf("wrote %q, want %q", out.String(), vttFixture)
+	}
+
+	if _, _, err := parseVTT("1\n00:00:01,000 --> 00:00:02,000\nEins\n"); err == nil {
+		t.Error("parsed a VTT file without the WEBVTT header")
+	}
+	if _, _, err := parseVTT("WEBVTT\n\nNOTE nothing else\n"); err == nil {
+		t.Error("parsed a VTT file without cues")
+	}
+}
+
+func TestSubtitlesConversion(t *testing.T) {
+	// VTT cues written as SRT take the hours they may leave out and lose
+	// their identifiers and settings, which SRT doesn't have.
+	_, cues, err := parseVTT(vttFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srt strings.Builder
+	if err := writeSubtitles(&srt, subtitleFile{Format: "vtt", Cues: cues}, "srt"); err != nil {
+		t.Fatal(err)
+	}
+	want := "1\n00:00:01,000 --> 00:00:03,500\nGuten Morgen!\n\n2\n00:00:04,000 --> 00:00:06,250\n<v Anna>Wie geht es dir?\n- Gut, danke.\n\n"
+	if srt.String() != want {
+		t.Errorf("wrote %q, want %q", srt.String(), want)
+	}
+
+	// SRT cues written as VTT get the default header and keep no counter.
+	cues, err = parseSRT(srtFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues[0].ID = ""
+	var vtt strings.Builder
+	if err := writeSubtitles(&vtt, subtitleFile{Format: "srt", Cues: cues[:1]}, "vtt"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "WEBVTT\n\n00:00:01.000 --> 00:00:03.500\nGuten Morgen!\n\n"; vtt.String() != want {
+		t.Errorf("wrote %q, want %q", vtt.String(), want)
+	}
+
+	for _, test := range []struct {
+		timestamp, format, want string
+	}{
+		{"00:00:01,000", "vtt", "00:00:01.000"},
+		{"00:01.000", "vtt", "00:01.000"},
+		{"00:01.000", "srt", "00:00:01,000"},
+		{"01:02:03.004", "srt", "01:02:03,004"},
+		{"01:02:03,004", "srt", "01:02:03,004"},
+	} {
+		if got := subtitleTimestamp(test.timestamp, test.format); got != test.want {
+			t.Errorf("subtitleTimestamp(%q, %q) = %q, want %q", test.timestamp, test.format, got, test.want)
+		}
+	}
+}
+
+func TestParseASS(t *testing.T) {
+	cues, err := parseASS("[Script Info]\nTitle: Lektion\n\n[Events]\nFormat: Layer, Start, End, Style, Text\nDialogue: 0,0:00:01.00,0:00:02.50,Default,{\\i1}Ja, natürlich.{\\i0}\\NDanke.\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []subtitleCue{{Start: "0:00:01.00", End: "0:00:02.50", Lines: []string{`{\i1}Ja, natürlich.{\i0}\NDanke.`}}}
+	if !reflect.DeepEqual(cues, want) {
+		t.Errorf("cues = %+v, want %+v", cues, want)
+	}
+	if got := cues[0].plainText(); got != "Ja, natürlich. Danke." {
+		t.Errorf("plainText = %q", got)
+	}
+
+	if _, err := parseASS("[Events]\nDialogue: 0,0:00:01.00,0:00:02.50,Default,Ja\n"); err == nil {
+		t.Error("parsed a Dialogue event before the Format line")
+	}
+}
+
+func TestSplitOuterTags(t *testing.T) {
+	for caption, want := range map[string][3]string{
+		"Hallo":        {"", "Hallo", ""},
+		"<i>Hallo</i>": {"<i>", "Hallo", "</i>"},
+		`<font color="red"><b> Hallo </b></font>`: {`<font color="red"><b>`, "Hallo", "</b></font>"},
+		"Hallo <i>du</i> da":                      {"", "Hallo <i>du</i> da", ""},
+		`{\an8}Hallo`:                             {`{\an8}`, "Hallo", ""},
+	} {
+		prefix, inner, suffix := splitOuterTags(caption)
+		if got := [3]string{prefix, inner, suffix}; got != want {
+			t.Errorf("splitOuterTags(%q) = %q, want %q", caption, got, want)
+		}
+	}
+}
+
+func TestKeepCaptionTags(t *testing.T) {
+	if got := keepCaptionTags("Das ist <b>sehr</b> gut", "That is <b>very</b> good"); got != "That is <b>very</b> good" {
+		t.Errorf("keepCaptionTags kept %q", got)
+	}
+	if got := keepCaptionTags("Das ist <b>sehr</b> gut", "That is <b>very good"); got != "That is very good" {
+		t.Errorf("keepCaptionTags kept %q, want the tags dropped", got)
+	}
+}
+
+func TestWrapCaption(t *testing.T) {
+	for _, test := range []struct {
+		caption string
+		max     int
+		want    []string
+	}{
+		{"Guten Morgen", 42, []string{"Guten Morgen"}},
+		// Two lines are split where their lengths are the closest.
+		{"Ich habe heute keine Zeit für dich", 20, []string{"Ich habe heute", "keine Zeit für dich"}},
+		// Tags don't count towards the length.
+		{"<i>Ich habe heute keine Zeit</i>", 25, []string{"<i>Ich habe heute keine Zeit</i>"}},
+		{"eins zwei drei vier fünf sechs", 10, []string{"eins zwei", "drei vier", "fünf sechs"}},
+		{"Donaudampfschifffahrt ja", 10, []string{"Donaudampfschifffahrt", "ja"}},
+	} {
+		if got := wrapCaption(test.caption, test.max); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("wrapCaption(%q, %d) = %q, want %q", test.caption, test.max, got, test.want)
+		}
+	}
+}
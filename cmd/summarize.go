@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// summaryLengths maps each --length to the instruction added to the
+// summarization prompt.
+var summaryLengths = map[string]string{
+	"short":  "Write the summary in one or two sentences.",
+	"medium": "Write the summary as a single paragraph of about five sentences.",
+	"long":   "Write the summary in a few paragraphs covering every main point of the text.",
+}
+
+// textSummary is the output of the summarize command.
+type textSummary struct {
+	SourceLanguage string `json:"source_language" yaml:"source_language"`
+	// Language is the language the summary is written in.
+	Language string       `json:"language" yaml:"language"`
+	Length   string       `json:"length" yaml:"length"`
+	Summary  string       `json:"summary" yaml:"summary"`
+	Usage    *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+}
+
+var summarizeCmd = &cobra.Command{
+	Use:   "summarize [text]",
+	Short: "Summarize a text in your own language before analyzing it",
+	Long: `The "summarize" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted) and asks the LLM for a summary of it, written in --language, to get the gist of a text before deciding whether to analyze it section by section.
+The summary is one or two sentences long with --length short, a paragraph with medium and a few paragraphs with long. It is written in the --translation-language unless --language is set, and is printed as plain text, or as JSON or YAML with --format.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSummarize,
+}
+
+func runSummarize(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "text" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q (expected text, json or yaml)", format)
+	}
+	length, err := cmd.Flags().GetString("length")
+	if err != nil {
+		return fmt.Errorf("retrieving length flag: %w", err)
+	}
+	if _, ok := summaryLengths[length]; !ok {
+		return fmt.Errorf("unsupported length %q (expected short, medium or long)", length)
+	}
+	language, err := cmd.Flags().GetString("language")
+	if err != nil {
+		return fmt.Errorf("retrieving language flag: %w", err)
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if language == "" {
+		language = opts.translationLanguage
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		if opts.sourceLanguage, err = detectLanguage(ctx, text, opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+	}
+	summary, err := summarizeText(ctx, text, language, length, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	result := textSummary{SourceLanguage: opts.sourceLanguage, Language: language, Length: length, Summary: summary, Usage: &usage}
+
+	switch format {
+	case "json":
+		err = writeJSON(cmd.OutOrStdout(), result)
+	case "yaml":
+		err = writeYAML(cmd.OutOrStdout(), result)
+	default:
+		_, err = fmt.Fprintln(cmd.OutOrStdout(), summary)
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// summarizeText asks the LLM for a summary of text of the given length,
+// written in language.
+func summarizeText(ctx context.Context, text, language, length string, opts analysisOptions) (string, error) {
+	req, err := renderPrompt(defaultSummarizePrompt, opts.translateModel, promptData{Text: text, Language: language, SourceLanguage: opts.sourceLanguage, Guidance: summaryLengths[length]})
+	if err != nil {
+		return "", err
+	}
+	summary, err := generate(ctx, opts, req)
+	if err != nil {
+		return "", fmt.Errorf("summarizing the text: %w", err)
+	}
+	if summary = strings.TrimSpace(summary); summary == "" {
+		return "", parseError(errors.New("summarizing the text: empty response"))
+	}
+	return summary, nil
+}
+
+func init() {
+	addAnalysisFlags(summarizeCmd.Flags())
+	summarizeCmd.Flags().String("format", "text", "The output format: text, json or yaml")
+	summarizeCmd.Flags().String("length", "short", "The length of the summary: short, medium or long")
+	summarizeCmd.Flags().String("language", "", "The language of the summary as a BCP 47 tag, such as pt-BR (defaults to --translation-language)")
+
+	rootCmd.AddCommand(summarizeCmd)
+}
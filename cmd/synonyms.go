@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// wordRelations are the synonyms and antonyms of a word, in its own
+// language.
+type wordRelations struct {
+	Word     string   `json:"word" yaml:"word"`
+	Synonyms []string `json:"synonyms,omitempty" yaml:"synonyms,omitempty"`
+	Antonyms []string `json:"antonyms,omitempty" yaml:"antonyms,omitempty"`
+}
+
+// findSynonyms asks the LLM for the common synonyms and antonyms of the key
+// words of text, or of text itself when it is a single word. Words with
+// neither are left out.
+func findSynonyms(ctx context.Context, text string, opts analysisOptions) ([]wordRelations, error) {
+	req, err := renderPrompt(defaultSynonymsPrompt, opts.translateModel, promptData{Text: text, SourceLanguage: opts.sourceLanguage})
+	if err != nil {
+		return nil, err
+	}
+	var found []wordRelations
+	if err := generateJSON(ctx, opts, req, &found); err != nil {
+		return nil, fmt.Errorf("finding the synonyms of %q: %w", text, err)
+	}
+	relations := make([]wordRelations, 0, len(found))
+	for _, related := range found {
+		related.Word = strings.TrimSpace(related.Word)
+		if related.Word != "" && len(related.Synonyms)+len(related.Antonyms) > 0 {
+			relations = append(relations, related)
+		}
+	}
+	return relations, nil
+}
+
+// formatWordRelations writes relations on a single line, for the tabular
+// formats and the notes of flashcards, such as "happy = glad, cheerful ≠ sad".
+func formatWordRelations(relations []wordRelations) string {
+	parts := make([]string, 0, len(relations))
+	for _, related := range relations {
+		part := related.Word
+		if len(related.Synonyms) > 0 {
+			part += " = " + strings.Join(related.Synonyms, ", ")
+		}
+		if len(related.Antonyms) > 0 {
+			part += " ≠ " + strings.Join(related.Antonyms, ", ")
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
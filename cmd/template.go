@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// outputTemplateData is what --template templates are executed with: the
+// analyses, along with what made them and when.
+type outputTemplateData struct {
+	Analyses []Analysis
+	// Provider and Model are the --provider and the translation model, and
+	// Translator the --translator service when there is one.
+	Provider   string
+	Model      string
+	Translator string
+	// TranslationLanguages lists every --translation-language, in order.
+	TranslationLanguages []string
+	StartedAt            time.Time
+}
+
+// latexSpecials are the characters escaped by the latex function of output
+// templates.
+var latexSpecials = strings.NewReplacer(
+	`\`, `\textbackslash{}`, `{`, `\{`, `}`, `\}`, `$`, `\$`, `&`, `\&`, `#`, `\#`,
+	`%`, `\%`, `_`, `\_`, `^`, `\textasciicircum{}`, `~`, `\textasciitilde{}`,
+)
+
+// outputTemplateFuncs are the functions of --template templates, on top of
+// the builtin ones of text/template such as html and printf.
+var outputTemplateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+	"latex":   latexSpecials.Replace,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// loadOutputTemplate parses the Go text/template in path rendering the
+// output of analise.
+func loadOutputTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(outputTemplateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing output template: %w", err)
+	}
+	// Catch references to unknown fields before any request is made, with
+	// an analysis of a single section so that the ranges over them run.
+	sample := outputTemplateData{Analyses: []Analysis{{Results: []ResultItem{{}}}}}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("checking output template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// tmxUnit is a translation unit of a TMX file: a source along with its
+// translations, by language, and the metadata of where it came from.
+type tmxUnit struct {
+	Source         string
+	SourceLanguage string
+	Languages      []string
+	Translations   map[string]string
+	Props          [][2]string
+	Note           string
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Convert results into the formats of other tools",
+	Long:  `The "export" commands convert the results of analise into the formats of other tools: "export tmx" into a translation memory for CAT tools, and "export sqlite" into a SQLite database.`,
+}
+
+var exportTmxCmd = &cobra.Command{
+	Use:   "tmx <results.json>...",
+	Short: "Convert the results of analise into a TMX translation memory",
+	Long: `The "tmx" command reads the JSON results of "analise" (from the given files, or stdin when the argument is "-") and writes every translated section as a translation unit of a TMX 1.4 file, which CAT tools such as OmegaT, Trados or memoQ import as a translation memory.
+Every unit holds the source and its translations, with their languages, the file the section was analyzed from, its page for PDF documents, the provider that translated it and its --verify similarity as properties, and its --explain explanation as a note. The same translation found in several results is written once.
+Results that don't record the language of their translations, which older versions of analise didn't, are taken to be in the --translation-language.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runExportTmx,
+}
+
+func runExportTmx(cmd *cobra.Command, args []string) error {
+	language, err := cmd.Flags().GetString("translation-language")
+	if err != nil {
+		return fmt.Errorf("retrieving translation-language flag: %w", err)
+	}
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	var units []tmxUnit
+	seen := make(map[string]bool)
+	for _, path := range args {
+		data, name, err := readResults(path)
+		if err != nil {
+			return fmt.Errorf("reading results: %w", err)
+		}
+		analyses, err := parseAnalyses(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, analysis := range analyses {
+			if analysis.TranslationLanguage == "" && len(analysis.TranslationLanguages) == 0 {
+				if language == "" {
+					return fmt.Errorf("%s: the results don't record the language of their translations (set it with --translation-language)", name)
+				}
+				analysis.TranslationLanguage = language
+			}
+			for _, unit := range analysisTMXUnits(analysis, name) {
+				key := unit.SourceLanguage + "\x00" + unit.Source
+				for _, language := range unit.Languages {
+					key += "\x00" + language + "\x00" + unit.Translations[language]
+				}
+				if !seen[key] {
+					seen[key] = true
+					units = append(units, unit)
+				}
+			}
+		}
+	}
+	if len(units) == 0 {
+		return errors.New("no translated sections to export")
+	}
+
+	data := writeTMX(units, time.Now())
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
+	}
+	if err := writeFileAtomic(outputPath, data, force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d translation units to %s\n", len(units), outputPath)
+	return nil
+}
+
+// analysisTMXUnits has a translation unit per translated section of
+// analysis, read from the results named name.
+func analysisTMXUnits(analysis Analysis, name string) []tmxUnit {
+	languages := analysis.TranslationLanguages
+	if len(languages) == 0 {
+		languages = []string{analysis.TranslationLanguage}
+	}
+	file := analysis.File
+	if file == "" {
+		file = name
+	}
+
+	var units []tmxUnit
+	for _, item := range analysis.Results {
+		unit := tmxUnit{Source: item.Source, SourceLanguage: analysis.SourceLanguage, Translations: make(map[string]string), Note: item.Explanation}
+		for _, language := range languages {
+			translation := item.Translation
+			if len(analysis.TranslationLanguages) > 0 {
+				translation = item.Translations[language]
+			}
+			if translation != "" {
+				unit.Languages = append(unit.Languages, language)
+				unit.Translations[language] = translation
+			}
+		}
+		if len(unit.Languages) == 0 {
+			continue
+		}
+		unit.Props = append(unit.Props, [2]string{"x-file", file})
+		if item.Page > 0 {
+			unit.Props = append(unit.Props, [2]string{"x-page", strconv.Itoa(item.Page)})
+		}
+		if analysis.Provider != "" {
+			unit.Props = append(unit.Props, [2]string{"x-provider", analysis.Provider})
+		}
+		if item.Similarity != nil {
+			unit.Props = append(unit.Props, [2]string{"x-similarity", strconv.FormatFloat(*item.Similarity, 'f', 2, 64)})
+		}
+		units = append(units, unit)
+	}
+	return units
+}
+
+// writeTMX writes units as a TMX 1.4 document created at now. Its source
+// language is the one of every unit, or *all* when they have different
+// ones.
+func writeTMX(units []tmxUnit, now time.Time) []byte {
+	escape := func(text string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(text))
+		return b.String()
+	}
+	sourceLanguage := units[0].SourceLanguage
+	for _, unit := range units {
+		if unit.SourceLanguage != sourceLanguage {
+			sourceLanguage = "*all*"
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE tmx SYSTEM "tmx14.dtd">` + "\n")
+	b.WriteString(`<tmx version="1.4">` + "\n")
+	fmt.Fprintf(&b, `  <header creationtool="starter-go-cli" creationtoolversion="1.0" datatype="plaintext" segtype="sentence" adminlang="en-US" srclang="%s" o-tmf="starter-go-cli" creationdate="%s"/>`+"\n", escape(sourceLanguage), now.UTC().Format("20060102T150405Z"))
+	b.WriteString("  <body>\n")
+	for _, unit := range units {
+		b.WriteString("    <tu>\n")
+		for _, prop := range unit.Props {
+			fmt.Fprintf(&b, "      <prop type=\"%s\">%s</prop>\n", prop[0], escape(prop[1]))
+		}
+		if unit.Note != "" {
+			fmt.Fprintf(&b, "      <note>%s</note>\n", escape(unit.Note))
+		}
+		fmt.Fprintf(&b, "      <tuv xml:lang=\"%s\"><seg>%s</seg></tuv>\n", escape(unit.SourceLanguage), escape(unit.Source))
+		for _, language := range unit.Languages {
+			fmt.Fprintf(&b, "      <tuv xml:lang=\"%s\"><seg>%s</seg></tuv>\n", escape(language), escape(unit.Translations[language]))
+		}
+		b.WriteString("    </tu>\n")
+	}
+	b.WriteString("  </body>\n</tmx>\n")
+	return []byte(b.String())
+}
+
+func init() {
+	exportTmxCmd.Flags().StringP("translation-language", "t", "", "The language of the translations of the results that don't record it")
+	exportTmxCmd.Flags().StringP("output", "o", "", "Write the TMX file to this file instead of stdout")
+	exportTmxCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	exportCmd.AddCommand(exportTmxCmd)
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// otelTimeout bounds the export of the trace at the end of the run.
+const otelTimeout = 10 * time.Second
+
+// tracer follows the LLM calls of a run: every call gets a span with an ID,
+// logged with --verbose when it starts and stops, sent to the host in the
+// X-Request-ID header and, with --otel-endpoint, exported as an OTLP trace
+// once the run is done.
+type tracer struct {
+	mu       sync.Mutex
+	endpoint string
+	traceID  string
+	root     *traceSpan
+	spans    []*traceSpan
+}
+
+// traceSpan is an LLM call, and its attempts. The root span is the whole
+// run.
+type traceSpan struct {
+	id    string
+	name  string
+	start time.Time
+	end   time.Time
+	attrs map[string]string
+	// status is "ok", "cached" or the kind of failure, see traceStatus.
+	status string
+	err    error
+	// traceparent is the W3C trace context of the span, only set when the
+	// trace is exported.
+	traceparent string
+}
+
+// newTracer returns the tracer of the run named name, exporting it to the
+// OTLP/HTTP collector at endpoint unless it is empty.
+func newTracer(name, endpoint string) *tracer {
+	return &tracer{
+		endpoint: endpoint,
+		traceID:  randomHex(16),
+		root:     &traceSpan{id: randomHex(8), name: name, start: time.Now()},
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start starts the span of an LLM call, returning ctx carrying it for
+// traceTransport.
+func (t *tracer) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, *traceSpan) {
+	span := &traceSpan{id: randomHex(8), name: name, start: time.Now(), attrs: attrs}
+	if t.endpoint != "" {
+		span.traceparent = "00-" + t.traceID + "-" + span.id + "-01"
+	}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	verbosef("trace id=%s event=start op=%s%s", span.id, name, formatTraceAttrs(attrs))
+	return context.WithValue(ctx, traceSpanKey{}, span), span
+}
+
+// End stops span with the outcome of the call.
+func (t *tracer) End(span *traceSpan, status string, err error) {
+	t.mu.Lock()
+	span.end, span.status, span.err = time.Now(), status, err
+	t.mu.Unlock()
+	latency := span.end.Sub(span.start).Round(time.Millisecond)
+	if err != nil {
+		verbosef("trace id=%s event=end op=%s status=%s latency=%s%s error=%q", span.id, span.name, status, latency, formatTraceAttrs(span.attrs), err)
+		return
+	}
+	verbosef("trace id=%s event=end op=%s status=%s latency=%s%s", span.id, span.name, status, latency, formatTraceAttrs(span.attrs))
+}
+
+// Set sets an attribute of span, such as its number of attempts.
+func (t *tracer) Set(span *traceSpan, key, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span.attrs[key] = value
+}
+
+func sortedKeys(attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatTraceAttrs(attrs map[string]string) string {
+	var b strings.Builder
+	for _, key := range sortedKeys(attrs) {
+		value := attrs[key]
+		if strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, value)
+	}
+	return b.String()
+}
+
+// traceStatus names the outcome of an LLM call that failed with err.
+func traceStatus(err error) string {
+	var statusErr *llm.StatusError
+	var connErr *llm.ConnectionError
+	var parseErr *llm.ParseError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &statusErr):
+		return "http_" + strconv.Itoa(statusErr.Code)
+	case errors.As(err, &connErr):
+		return "unreachable"
+	case errors.As(err, &parseErr):
+		return "unparsable"
+	}
+	return "error"
+}
+
+// Flush ends the root span and exports the trace to --otel-endpoint, when
+// set. A failed export is only reported: the results are worth more than
+// their trace.
+func (t *tracer) Flush() {
+	t.mu.Lock()
+	t.root.end = time.Now()
+	t.mu.Unlock()
+	if t.endpoint == "" {
+		return
+	}
+
+	body, err := json.Marshal(t.export())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not export the trace:", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otelTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, otlpTracesURL(t.endpoint), bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not export the trace:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// The collector gets a client of its own, without the headers and
+	// credentials meant for the providers.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: could not export the trace:", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintf(os.Stderr, "Warning: could not export the trace: %s answered with status %d\n", llm.RedactURL(t.endpoint), resp.StatusCode)
+		return
+	}
+	verbosef("Exported trace %s with %d spans to %s", t.traceID, len(t.spans)+1, llm.RedactURL(t.endpoint))
+}
+
+// otlpTracesURL returns the traces URL of the OTLP/HTTP collector at
+// endpoint, which may be given with or without its /v1/traces path.
+func otlpTracesURL(endpoint string) string {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+// The OTLP/HTTP JSON encoding of a trace, with the IDs in hex.
+type (
+	otlpTrace struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// The span kinds and status codes of OTLP.
+const (
+	otlpKindInternal = 1
+	otlpKindClient   = 3
+	otlpStatusOK     = 1
+	otlpStatusError  = 2
+)
+
+func (t *tracer) export() otlpTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := []otlpSpan{t.otlpSpan(t.root, "", otlpKindInternal)}
+	for _, span := range t.spans {
+		if span.end.IsZero() {
+			// The call was still running when the run ended.
+			span.end, span.status = t.root.end, "unfinished"
+		}
+		spans = append(spans, t.otlpSpan(span, t.root.id, otlpKindClient))
+	}
+	return otlpTrace{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpValue{StringValue: rootCmd.Name()}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: rootCmd.Name()}, Spans: spans}},
+	}}}
+}
+
+func (t *tracer) otlpSpan(span *traceSpan, parent string, kind int) otlpSpan {
+	attributes := []otlpAttribute{}
+	for _, key := range sortedKeys(span.attrs) {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: span.attrs[key]}})
+	}
+	if span.status != "" {
+		attributes = append(attributes, otlpAttribute{Key: "status", Value: otlpValue{StringValue: span.status}})
+	}
+	status := otlpStatus{Code: otlpStatusOK}
+	if span.err != nil {
+		status = otlpStatus{Code: otlpStatusError, Message: span.err.Error()}
+	}
+	return otlpSpan{
+		TraceID:           t.traceID,
+		SpanID:            span.id,
+		ParentSpanID:      parent,
+		Name:              span.name,
+		Kind:              kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        attributes,
+		Status:            status,
+	}
+}
+
+// traceSpanKey is the context key of the span of an LLM call.
+type traceSpanKey struct{}
+
+// traceTransport sends the ID of the span of every request in the
+// X-Request-ID header, and with --otel-endpoint its W3C trace context in the
+// traceparent header so that the host can join the trace.
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span, ok := req.Context().Value(traceSpanKey{}).(*traceSpan)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Request-ID", span.id)
+	if span.traceparent != "" {
+		req.Header.Set("traceparent", span.traceparent)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+// speechServices are the services turning speech into text, and text into
+// speech, other than the --provider.
+var speechServices = map[string]providerSpec{
+	"whisper": {
+		title: "Whisper",
+		// The default endpoint of whisper.cpp servers.
+		host:        "http://localhost:8080/inference",
+		model:       "whisper-1",
+		apiKeyEnv:   []string{"STARTER_GO_CLI_WHISPER_API_KEY"},
+		optionalKey: true,
+	},
+}
+
+// newWhisper returns the transcriber of --audio, posting to --whisper-host
+// with the --whisper-model.
+func newWhisper(cmd *cobra.Command) (*llm.Whisper, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+	spec := speechServices["whisper"]
+	host, err := resolveSetting(cmd, "whisper-host", "STARTER_GO_CLI_WHISPER_HOST", cfg.WhisperHost, spec.host)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving whisper-host flag: %w", err)
+	}
+	model, err := resolveSetting(cmd, "whisper-model", "STARTER_GO_CLI_WHISPER_MODEL", cfg.WhisperModel, spec.model)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving whisper-model flag: %w", err)
+	}
+	apiKey, err := resolveAPIKey(cfg, "whisper", spec)
+	if err != nil {
+		return nil, err
+	}
+	client, err := httpClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	whisper := llm.NewWhisper(host, apiKey, model, stderrLogger{})
+	whisper.Client = client
+	return whisper, nil
+}
+
+// transcribeAudio returns the text spoken in the audio file at path, in the
+// --source-language when it is set. Transcriptions are cached like the
+// responses of the provider, keyed by the content of the file.
+func transcribeAudio(ctx context.Context, whisper *llm.Whisper, path string, opts analysisOptions) (string, error) {
+	audio, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	fmt.Fprintf(sum, "transcribe\x00%s\x00%s\x00%s\x00", whisper.Endpoint, whisper.Model, opts.sourceLanguage)
+	sum.Write(audio)
+	key := hex.EncodeToString(sum.Sum(nil))
+
+	fmt.Fprintf(os.Stderr, "Transcribing %s\n", path)
+	text, err := callProvider(ctx, opts, "transcribe", whisper.Model, func() string { return key }, func(ctx context.Context) (string, error) {
+		return whisper.Transcribe(ctx, filepath.Base(path), audio, opts.sourceLanguage)
+	})
+	if err != nil {
+		return "", fmt.Errorf("transcribing %s: %w", path, err)
+	}
+	if text == "" {
+		return "", fmt.Errorf("%s: no speech was transcribed", path)
+	}
+	return text, nil
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// docSegment is a piece of a document: the text nodes are translated and
+// everything else, such as markup and code, is kept as it is.
+type docSegment struct {
+	Text      string
+	Translate bool
+	// Kept are the pieces of a text node that are not translated, such as
+	// code spans and link targets, which stand in Text as placeholders.
+	Kept []string
+	// Breaks are the line breaks within a text node, which is translated as
+	// a single line.
+	Breaks []docBreak
+}
+
+// docBreak is a line break of a text node, with the markup around it, such
+// as the quote markers of the next line, after the first After words of
+// its Text.
+type docBreak struct {
+	After int
+	Text  string
+}
+
+// docFormat is a document format of translate-doc.
+type docFormat struct {
+	parse func(text string) []docSegment
+	// valid reports whether the translation of a text node, with its kept
+	// pieces back in place, keeps the structure of the source. A nil valid
+	// accepts any translation keeping the placeholders.
+	valid func(source, translation string) bool
+}
+
+// docFormats are the formats of translate-doc, by file extension.
+var docFormats = map[string]docFormat{
+	".md":       {parse: parseMarkdown},
+	".markdown": {parse: parseMarkdown},
+	".html":     {parse: parseHTML, valid: validHTMLTranslation},
+	".htm":      {parse: parseHTML, valid: validHTMLTranslation},
+	".xhtml":    {parse: parseHTML, valid: validHTMLTranslation},
+}
+
+// docPlaceholder returns the placeholder of the i-th kept piece of a text
+// node.
+func docPlaceholder(i int) string {
+	return fmt.Sprintf("⟦%d⟧", i+1)
+}
+
+var translateDocCmd = &cobra.Command{
+	Use:   "translate-doc <file>",
+	Short: "Translate a Markdown, HTML, EPUB or DOCX document, keeping its structure",
+	Long: `The "translate-doc" command reads a Markdown (.md or .markdown), HTML (.html, .htm or .xhtml), EPUB (.epub) or Word (.docx) document and translates its text into the --translation-language, keeping everything else as it is.
+Markdown documents keep their front matter, code blocks and code spans, HTML, link and image targets, the markers of headings, lists, quotes and tables, and the blank lines between blocks. Every heading, paragraph, list item and table cell is translated on its own. The lines of a paragraph are translated together, and the translation broken into as many lines after about as many of its words; the lines ending with a hard break are translated on their own.
+HTML documents keep their tags, attributes, entities and comments, and the content of the script, style, pre, code and textarea elements and of the ones marked translate="no". The text of every block element is translated along with its inline markup, such as <b> or <a>, and the translations whose tags no longer nest the way they did are kept untranslated, with a warning.
+The pieces kept within the text, such as code spans, links and tags, are replaced by placeholders which the translation must keep; the text whose translation loses any is kept untranslated, with a warning.
+EPUB books (.epub) have their chapters and table of contents translated as HTML documents, and the title, description, subjects and language of their metadata updated, and are written to --output, or next to the book with the language in their name. Every translated file is checkpointed in a file next to the output, so that an interrupted translation resumes after the last translated chapter when run again.
+Word documents (.docx) have the paragraphs of their body, tables, headers, footers and notes translated, keeping the style of every run of text: the runs of a paragraph are translated together, with the markup between them as placeholders. They are written to --output, or next to the document with the language in their name.
+Other documents are written to stdout, or to --output.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTranslateDoc,
+}
+
+func runTranslateDoc(cmd *cobra.Command, args []string) error {
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	path := args[0]
+	extension := strings.ToLower(filepath.Ext(path))
+	switch extension {
+	case ".epub":
+		return translateEPUB(cmd, path, outputPath, force)
+	case ".docx":
+		return translateDOCX(cmd, path, outputPath, force)
+	}
+	format, ok := docFormats[extension]
+	if !ok {
+		return fmt.Errorf("%s: unsupported document format (expected .md, .markdown, .html, .htm, .xhtml, .epub or .docx)", path)
+	}
+	text, err := readInputFile(path)
+	if err != nil {
+		return fmt.Errorf("reading document: %w", err)
+	}
+	segments := format.parse(text)
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(opts.translationLanguages) > 1 {
+		return errors.New("translate-doc requires a single --translation-language")
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	var nodes []string
+	for _, segment := range segments {
+		if segment.Translate {
+			nodes = append(nodes, segment.Text)
+		}
+	}
+	if len(nodes) == 0 {
+		return errors.New("the document has no text to translate")
+	}
+	if opts.sourceLanguage == "" {
+		if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(nodes, " "), readabilitySampleWords), opts); err != nil {
+			return runError(ctx, opts, err)
+		}
+	}
+	if segments, err = translateSegments(ctx, segments, format.valid, opts); err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	var b bytes.Buffer
+	for _, segment := range segments {
+		b.WriteString(segment.Text)
+	}
+	if outputPath == "" {
+		_, err = cmd.OutOrStdout().Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(outputPath, b.Bytes(), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d translated text nodes to %s\n", len(nodes), outputPath)
+	return nil
+}
+
+// translateSegments translates the text nodes of segments, putting their
+// kept pieces back in place of the placeholders. Text nodes whose
+// translation loses any of them, or is not valid, are kept untranslated.
+func translateSegments(ctx context.Context, segments []docSegment, valid func(source, translation string) bool, opts analysisOptions) ([]docSegment, error) {
+	var nodes []int
+	for i, segment := range segments {
+		if segment.Translate {
+			nodes = append(nodes, i)
+		}
+	}
+	opts.progress.Start(len(nodes))
+	defer opts.progress.Finish()
+
+	translate := func(_ int, i int) (string, error) {
+		segment := segments[i]
+		opts.progress.Begin(segment.Text)
+		translation, err := translateSection(ctx, segment.Text, opts.sourceLanguage, opts.translationLanguage, opts.glossary, opts)
+		if err != nil {
+			return "", err
+		}
+		opts.progress.Advance()
+		return translation, nil
+	}
+	translations, err := analyze.RunOrdered(nodes, opts.concurrency, translate, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	translated := append([]docSegment{}, segments...)
+	for n, i := range nodes {
+		segment := segments[i]
+		source, _ := segment.restore(segment.Text)
+		text, ok := segment.restore(strings.TrimSpace(translations[n]))
+		switch {
+		case !ok:
+			fmt.Fprintf(os.Stderr, "Warning: keeping %q untranslated, its translation lost some of its markup\n", source)
+			text = source
+		case valid != nil && !valid(source, text):
+			fmt.Fprintf(os.Stderr, "Warning: keeping %q untranslated, its translation breaks the structure of its markup\n", source)
+			text = source
+		}
+		translated[i] = docSegment{Text: text}
+	}
+	return translated, nil
+}
+
+// restore returns text, the translation of the text node of s or its own
+// text, with the line breaks and the kept pieces of s back in place,
+// reporting false when any of its placeholders is missing or repeated.
+func (s docSegment) restore(text string) (string, bool) {
+	if len(s.Breaks) > 0 {
+		text = breakLines(text, s.Breaks, len(strings.Fields(s.Text)))
+	}
+	return restorePlaceholders(text, s.Kept)
+}
+
+// breakLines puts breaks back between the words of text, each after as many
+// of them, in proportion, as it was after in a text of words words. Every
+// line keeps a word at least, and the breaks finding none left are dropped.
+func breakLines(text string, breaks []docBreak, words int) string {
+	fields := strings.Fields(text)
+	var b strings.Builder
+	last := 0
+	for k, lineBreak := range breaks {
+		at := (lineBreak.After*len(fields) + words/2) / words
+		at = min(max(at, last+1), len(fields)-(len(breaks)-k))
+		if at <= last {
+			continue
+		}
+		b.WriteString(strings.Join(fields[last:at], " "))
+		b.WriteString(lineBreak.Text)
+		last = at
+	}
+	b.WriteString(strings.Join(fields[last:], " "))
+	return b.String()
+}
+
+// restorePlaceholders puts kept back in place of their placeholders in text,
+// reporting false when any of them is missing or repeated.
+func restorePlaceholders(text string, kept []string) (string, bool) {
+	for i, piece := range kept {
+		placeholder := docPlaceholder(i)
+		if strings.Count(text, placeholder) != 1 {
+			return "", false
+		}
+		text = strings.Replace(text, placeholder, piece, 1)
+	}
+	return text, true
+}
+
+func init() {
+	addAnalysisFlags(translateDocCmd.Flags())
+	translateDocCmd.Flags().StringP("output", "o", "", "Write the translated document to this file instead of stdout")
+	translateDocCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	rootCmd.AddCommand(translateDocCmd)
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// transliterationScheme is a way of writing a language in Latin script.
+type transliterationScheme struct {
+	name string
+	// description names the scheme in the transliteration prompt.
+	description string
+}
+
+// cyrillicSchemes are the romanizations shared by the languages written in
+// Cyrillic script.
+var cyrillicSchemes = []transliterationScheme{
+	{"bgn-pcgn", "the BGN/PCGN romanization"},
+	{"iso9", "the ISO 9 transliteration"},
+	{"ala-lc", "the ALA-LC romanization"},
+	{"scientific", "the scholarly (scientific) transliteration"},
+}
+
+// arabicSchemes are the romanizations shared by the languages written in
+// Arabic script.
+var arabicSchemes = []transliterationScheme{
+	{"ala-lc", "the ALA-LC romanization"},
+	{"din31635", "the DIN 31635 transliteration"},
+	{"buckwalter", "the Buckwalter transliteration"},
+}
+
+// transliterationSchemes lists the schemes of every language by its primary
+// subtag, the default one first. Languages missing from it are written with
+// their most widely used romanization.
+var transliterationSchemes = map[string][]transliterationScheme{
+	"ja": {
+		{"hepburn", "the Hepburn romanization (romaji)"},
+		{"kunrei", "the Kunrei-shiki romanization (romaji)"},
+		{"nihon", "the Nihon-shiki romanization (romaji)"},
+	},
+	"zh": {
+		{"pinyin", "Hanyu Pinyin with tone marks"},
+		{"pinyin-numbers", "Hanyu Pinyin with tone numbers"},
+		{"wade-giles", "the Wade-Giles romanization"},
+	},
+	"ko": {
+		{"revised", "the Revised Romanization of Korean"},
+		{"mccune-reischauer", "the McCune-Reischauer romanization"},
+	},
+	"ru": cyrillicSchemes,
+	"uk": cyrillicSchemes,
+	"be": cyrillicSchemes,
+	"bg": cyrillicSchemes,
+	"sr": cyrillicSchemes,
+	"mk": cyrillicSchemes,
+	"ar": arabicSchemes,
+	"fa": arabicSchemes,
+	"ur": arabicSchemes,
+	"el": {
+		{"elot743", "the ELOT 743 (ISO 843) transliteration"},
+		{"ala-lc", "the ALA-LC romanization"},
+	},
+	"hi": {
+		{"iast", "the IAST transliteration"},
+		{"iso15919", "the ISO 15919 transliteration"},
+	},
+}
+
+// transliteratedSentence is a sentence along with its transliteration.
+type transliteratedSentence struct {
+	Text            string `json:"text" yaml:"text"`
+	Transliteration string `json:"transliteration" yaml:"transliteration"`
+}
+
+// transliteration is the output of the transliterate command.
+type transliteration struct {
+	SourceLanguage string                   `json:"source_language" yaml:"source_language"`
+	Scheme         string                   `json:"scheme" yaml:"scheme"`
+	Usage          *usageTotals             `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Sentences      []transliteratedSentence `json:"sentences" yaml:"sentences"`
+}
+
+var transliterateCmd = &cobra.Command{
+	Use:   "transliterate [text]",
+	Short: "Write a text in Latin script",
+	Long: `The "transliterate" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), divides it into sentences and asks the LLM to write each one in Latin script, such as Japanese in romaji, Chinese in pinyin or Russian and Arabic in their romanizations.
+Every language is written with its most widely used scheme unless another one is chosen with --scheme; run "transliterate --list-schemes" to see them. The text format prints a transliterated sentence per line; use --format for the sentences side by side with their transliterations.
+Use "analise --transliterate" to add the transliteration of every section to the analysis instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTransliterate,
+}
+
+func runTransliterate(cmd *cobra.Command, args []string) error {
+	listSchemes, err := cmd.Flags().GetBool("list-schemes")
+	if err != nil {
+		return fmt.Errorf("retrieving list-schemes flag: %w", err)
+	}
+	if listSchemes {
+		return writeTransliterationSchemes(cmd.OutOrStdout())
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if format != "text" {
+		if err := checkDocumentFormat(format); err != nil {
+			return err
+		}
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	result, err := transliterateText(ctx, text, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	if format == "text" {
+		var b strings.Builder
+		for _, sentence := range result.Sentences {
+			b.WriteString(sentence.Transliteration + "\n")
+		}
+		_, err = io.WriteString(cmd.OutOrStdout(), b.String())
+	} else {
+		header := []string{"text", "transliteration"}
+		rows := make([][]string, 0, len(result.Sentences))
+		for _, sentence := range result.Sentences {
+			rows = append(rows, []string{sentence.Text, sentence.Transliteration})
+		}
+		err = writeDocument(cmd.OutOrStdout(), format, result, header, rows)
+	}
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// transliterateText asks the LLM for the transliteration of every sentence of
+// text.
+func transliterateText(ctx context.Context, text string, opts analysisOptions) (transliteration, error) {
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		sourceLanguage, err := detectLanguage(ctx, text, opts)
+		if err != nil {
+			return transliteration{}, err
+		}
+		opts.sourceLanguage = sourceLanguage
+	}
+	scheme, err := resolveTransliterationScheme(opts.sourceLanguage, opts.scheme)
+	if err != nil {
+		return transliteration{}, err
+	}
+
+	sentences := splitSentences(text)
+	opts.progress.Start(len(sentences))
+	defer opts.progress.Finish()
+
+	transliterate := func(_ int, sentence string) (transliteratedSentence, error) {
+		opts.progress.Begin(sentence)
+		romanized, err := transliterateSection(ctx, sentence, scheme, opts)
+		if err != nil {
+			return transliteratedSentence{}, err
+		}
+		opts.progress.Advance()
+		return transliteratedSentence{Text: sentence, Transliteration: romanized}, nil
+	}
+	transliterated, err := analyze.RunOrdered(sentences, opts.concurrency, transliterate, nil)
+	if err != nil {
+		return transliteration{}, err
+	}
+
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	return transliteration{
+		SourceLanguage: opts.sourceLanguage,
+		Scheme:         scheme.name,
+		Usage:          &usage,
+		Sentences:      transliterated,
+	}, nil
+}
+
+// transliterateSection asks the LLM to write section in Latin script with
+// scheme.
+func transliterateSection(ctx context.Context, section string, scheme transliterationScheme, opts analysisOptions) (string, error) {
+	req, err := renderPrompt(defaultTransliteratePrompt, opts.translateModel, promptData{Text: section, SourceLanguage: opts.sourceLanguage, Scheme: scheme.description})
+	if err != nil {
+		return "", err
+	}
+	romanized, err := generate(ctx, opts, req)
+	if err != nil {
+		return "", fmt.Errorf("transliterating %q: %w", section, err)
+	}
+	return strings.TrimSpace(romanized), nil
+}
+
+// resolveTransliterationScheme returns the scheme named by --scheme for
+// language, or its default scheme when name is empty.
+func resolveTransliterationScheme(language, name string) (transliterationScheme, error) {
+	primary, _, _ := strings.Cut(strings.ToLower(language), "-")
+	schemes, ok := transliterationSchemes[primary]
+	if !ok {
+		if name == "" {
+			return transliterationScheme{"standard", "its most widely used romanization"}, nil
+		}
+		// Languages without schemes of their own may still use one of a
+		// script they share, such as Kazakh with a Cyrillic scheme.
+		for _, schemes := range transliterationSchemes {
+			for _, scheme := range schemes {
+				if scheme.name == name {
+					return scheme, nil
+				}
+			}
+		}
+		return transliterationScheme{}, fmt.Errorf("unknown transliteration scheme %q", name)
+	}
+	if name == "" {
+		return schemes[0], nil
+	}
+	names := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		if scheme.name == name {
+			return scheme, nil
+		}
+		names = append(names, scheme.name)
+	}
+	return transliterationScheme{}, fmt.Errorf("the transliteration scheme %q does not apply to %s (expected one of %s)", name, language, strings.Join(names, ", "))
+}
+
+// checkTransliterationScheme reports whether name is one of the known
+// schemes, so that a misspelled --scheme fails before any request is made.
+func checkTransliterationScheme(name string) error {
+	if name == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, schemes := range transliterationSchemes {
+		for _, scheme := range schemes {
+			if scheme.name == name {
+				return nil
+			}
+			seen[scheme.name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown transliteration scheme %q (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+// writeTransliterationSchemes writes the schemes of every language, the
+// default one first.
+func writeTransliterationSchemes(w io.Writer) error {
+	languages := make([]string, 0, len(transliterationSchemes))
+	for language := range transliterationSchemes {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	header := []string{"language", "scheme", "description"}
+	var rows [][]string
+	for _, language := range languages {
+		for i, scheme := range transliterationSchemes[language] {
+			name := scheme.name
+			if i == 0 {
+				name += " (default)"
+			}
+			rows = append(rows, []string{language, name, scheme.description})
+		}
+	}
+	return writeTable(w, header, rows)
+}
+
+func init() {
+	addAnalysisFlags(transliterateCmd.Flags())
+	transliterateCmd.Flags().String("format", "text", "The output format: text, with a transliterated sentence per line, json, yaml, csv or table")
+	transliterateCmd.Flags().Bool("list-schemes", false, "List the transliteration schemes of every language and exit")
+
+	rootCmd.AddCommand(transliterateCmd)
+}
@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// idleConnsPerHost is the number of idle connections kept open to every
+// host, raised to --concurrency when it is higher so that every worker
+// reuses its own connection.
+const idleConnsPerHost = 16
+
+// sharedClient is the HTTP client of every provider and translator, built
+// once by httpClient.
+var sharedClient *http.Client
+
+// sharedHeaders adds the --header and --api-key headers to the requests of
+// sharedClient for the LLM hosts, or is nil when there are none.
+var sharedHeaders *headerTransport
+
+// httpClient returns the HTTP client shared by every provider and
+// translator, going through the proxy of --proxy, STARTER_GO_CLI_PROXY or
+// the config file, or else through the one of the standard HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY variables. Connections are kept alive and pooled
+// across all of the requests of the run, over HTTP/2 when the host supports
+// it. The client adds the ID of its trace span to every request, and the
+// --header and --api-key headers to the requests for the LLM hosts, which
+// newNamedProvider registers with allowHeaders.
+func httpClient(cmd *cobra.Command) (*http.Client, error) {
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := resolveSetting(cmd, "proxy", "STARTER_GO_CLI_PROXY", cfg.Proxy, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving proxy flag: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = idleConnsPerHost
+	transport.IdleConnTimeout = 90 * time.Second
+	if flag := cmd.Flags().Lookup("concurrency"); flag != nil {
+		if concurrency, err := cmd.Flags().GetInt("concurrency"); err == nil && concurrency > transport.MaxIdleConnsPerHost {
+			transport.MaxIdleConnsPerHost = concurrency
+			transport.MaxIdleConns = max(transport.MaxIdleConns, concurrency)
+		}
+	}
+	switch proxy {
+	case "":
+		transport.Proxy = http.ProxyFromEnvironment
+	case "none":
+		transport.Proxy = nil
+	default:
+		proxyURL, err := parseProxyURL(proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := resolveTLSConfig(cmd, cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	header, apiKey, err := resolveHeaders(cmd, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var roundTripper http.RoundTripper = &traceTransport{base: transport}
+	if len(header) > 0 || apiKey != "" {
+		sharedHeaders = &headerTransport{base: roundTripper, header: header, apiKey: apiKey, hosts: make(map[string]bool)}
+		roundTripper = sharedHeaders
+	}
+	sharedClient = &http.Client{Transport: roundTripper}
+	return sharedClient, nil
+}
+
+// resolveTLSConfig returns the TLS configuration of --ca-cert, which is
+// trusted along with the system roots, --client-cert and --client-key, for
+// mutual TLS, and --insecure-skip-verify, each falling back to the config
+// file.
+func resolveTLSConfig(cmd *cobra.Command, cfg *config) (*tls.Config, error) {
+	caCert, err := resolveSetting(cmd, "ca-cert", "STARTER_GO_CLI_CA_CERT", cfg.CACert, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving ca-cert flag: %w", err)
+	}
+	clientCert, err := resolveSetting(cmd, "client-cert", "STARTER_GO_CLI_CLIENT_CERT", cfg.ClientCert, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving client-cert flag: %w", err)
+	}
+	clientKey, err := resolveSetting(cmd, "client-key", "STARTER_GO_CLI_CLIENT_KEY", cfg.ClientKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving client-key flag: %w", err)
+	}
+	insecure := cfg.InsecureSkipVerify
+	if cmd.Flags().Changed("insecure-skip-verify") {
+		insecure, err = cmd.Flags().GetBool("insecure-skip-verify")
+		if err != nil {
+			return nil, fmt.Errorf("retrieving insecure-skip-verify flag: %w", err)
+		}
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if insecure {
+		fmt.Fprintln(os.Stderr, "Warning: TLS certificates are not verified (--insecure-skip-verify)")
+	}
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (clientCert == "") != (clientKey == "") {
+		return nil, errors.New("--client-cert and --client-key must be set together")
+	}
+	if clientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// resolveHeaders returns the headers of the config file overridden by the
+// --header flags, given as "Key: Value", along with the bearer token of
+// --api-key, STARTER_GO_CLI_API_KEY or the config file.
+func resolveHeaders(cmd *cobra.Command, cfg *config) (http.Header, string, error) {
+	lines, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving header flag: %w", err)
+	}
+	apiKey, err := resolveSetting(cmd, "api-key", "STARTER_GO_CLI_API_KEY", cfg.APIKey, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("retrieving api-key flag: %w", err)
+	}
+
+	header := http.Header{}
+	for name, value := range cfg.Headers {
+		header.Set(name, value)
+	}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, "", fmt.Errorf("invalid header %q (expected 'Key: Value')", line)
+		}
+		header.Set(name, strings.TrimSpace(value))
+	}
+	return header, apiKey, nil
+}
+
+// credentialHeaders are the headers the providers authenticate their
+// requests with, which the bearer token of --api-key doesn't override.
+var credentialHeaders = []string{"Authorization", "Api-Key", "X-Goog-Api-Key", "X-Api-Key"}
+
+// headerTransport adds header to the requests sent through base for the
+// hosts registered with allow, except for the headers the requests already
+// have, along with apiKey as a bearer token for the requests without
+// credentials of their own. The requests for other hosts, such as the ones
+// of the AWS credential chain or the trace exporter, are sent as they are.
+type headerTransport struct {
+	base   http.RoundTripper
+	header http.Header
+	apiKey string
+
+	mu    sync.Mutex
+	hosts map[string]bool
+}
+
+// allowHeaders adds the --header and --api-key headers to the requests for
+// the host of endpoint.
+func allowHeaders(endpoint string) {
+	sharedHeaders.allow(endpoint)
+}
+
+// allow adds the headers to the requests for the host of endpoint. It is
+// safe to call on a nil *headerTransport, which adds none.
+func (t *headerTransport) allow(endpoint string) {
+	if t == nil {
+		return
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hosts[requestHost(u)] = true
+}
+
+// requestHost returns the host of u in lower case, without the default port
+// of its scheme.
+func requestHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "https":
+		return strings.TrimSuffix(host, ":443")
+	case u.Scheme == "http":
+		return strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	allowed := t.hosts[requestHost(req.URL)]
+	t.mu.Unlock()
+	if !allowed {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for name, values := range t.header {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	if t.apiKey != "" {
+		authenticated := false
+		for _, name := range credentialHeaders {
+			if req.Header.Get(name) != "" {
+				authenticated = true
+			}
+		}
+		if !authenticated {
+			req.Header.Set("Authorization", "Bearer "+t.apiKey)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// parseProxyURL parses the URL of an HTTP, HTTPS or SOCKS5 proxy. A bare
+// host:port is an HTTP proxy.
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil || proxyURL.Host == "" {
+		proxyURL, err = url.Parse("http://" + raw)
+	}
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q", raw)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return proxyURL, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q (expected http, https, socks5 or socks5h)", proxyURL.Scheme)
+}
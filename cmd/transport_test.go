@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderTransport(t *testing.T) {
+	received := make(chan http.Header, 1)
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	})
+	llmHost := httptest.NewServer(record)
+	defer llmHost.Close()
+	otherHost := httptest.NewServer(record)
+	defer otherHost.Close()
+
+	transport := &headerTransport{
+		base:   http.DefaultTransport,
+		header: http.Header{"X-Team": {"lingo"}, "X-Proxy-Token": {"proxy-secret"}},
+		apiKey: "llm-secret",
+		hosts:  make(map[string]bool),
+	}
+	transport.allow(llmHost.URL + "/api/generate")
+	client := &http.Client{Transport: transport}
+	send := func(url string, header http.Header) http.Header {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return <-received
+	}
+
+	got := send(llmHost.URL+"/api/chat", nil)
+	if got.Get("Authorization") != "Bearer llm-secret" || got.Get("X-Team") != "lingo" || got.Get("X-Proxy-Token") != "proxy-secret" {
+		t.Errorf("the LLM host got %v", got)
+	}
+
+	// The headers of the request win, and requests carrying credentials of
+	// their own, like the ones of Gemini and Azure OpenAI, don't get the
+	// bearer token.
+	got = send(llmHost.URL+"/v1beta/models", http.Header{"X-Goog-Api-Key": {"gemini-key"}, "X-Team": {"own"}})
+	if got.Get("Authorization") != "" || got.Get("X-Team") != "own" || got.Get("X-Proxy-Token") != "proxy-secret" {
+		t.Errorf("a request with credentials got %v", got)
+	}
+	got = send(llmHost.URL+"/openai/deployments", http.Header{"Api-Key": {"azure-key"}})
+	if got.Get("Authorization") != "" {
+		t.Errorf("a request with an Azure key got Authorization %q", got.Get("Authorization"))
+	}
+
+	// Other hosts, such as the ones of the AWS credentials or the trace
+	// exporter, get none of them.
+	got = send(otherHost.URL+"/v1/traces", nil)
+	for _, name := range []string{"Authorization", "X-Team", "X-Proxy-Token"} {
+		if got.Get(name) != "" {
+			t.Errorf("another host got %s: %s", name, got.Get(name))
+		}
+	}
+}
+
+func TestHeaderTransportExplicitAuthorization(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	// An Authorization given with --header replaces the one of --api-key.
+	transport := &headerTransport{base: http.DefaultTransport, header: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}, apiKey: "llm-secret", hosts: make(map[string]bool)}
+	transport.allow(server.URL)
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/api/tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := <-received; got != "Basic dXNlcjpwYXNz" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	var disabled *headerTransport
+	disabled.allow(server.URL)
+}
+
+func TestRequestHost(t *testing.T) {
+	transport := &headerTransport{hosts: make(map[string]bool)}
+	transport.allow("https://API.openai.com:443/v1")
+	transport.allow("http://localhost:11434/api/generate")
+	transport.allow("not a URL")
+	for raw, want := range map[string]bool{
+		"https://api.openai.com/v1/chat/completions": true,
+		"http://localhost:11434/api/chat":            true,
+		"http://localhost:8080/api/chat":             false,
+		"https://sts.amazonaws.com/":                 false,
+	} {
+		req, err := http.NewRequest(http.MethodGet, raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := transport.hosts[requestHost(req.URL)]; got != want {
+			t.Errorf("headers for %s = %v, want %v", raw, got, want)
+		}
+	}
+}
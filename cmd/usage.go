@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// modelPrice is the price of a model in US dollars per million tokens.
+type modelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// modelPrices are the list prices of the models of the paid providers,
+// matched as prefixes of the model names so that dated versions share the
+// price of their family. The prices of the config file take precedence.
+var modelPrices = map[string]modelPrice{
+	"gpt-4o-mini":                 {Input: 0.15, Output: 0.60},
+	"gpt-4o":                      {Input: 5, Output: 15},
+	"gpt-4-turbo":                 {Input: 10, Output: 30},
+	"gpt-4":                       {Input: 30, Output: 60},
+	"gpt-3.5-turbo":               {Input: 0.50, Output: 1.50},
+	"gemini-1.5-flash":            {Input: 0.075, Output: 0.30},
+	"gemini-1.5-pro":              {Input: 3.50, Output: 10.50},
+	"gemini-1.0-pro":              {Input: 0.50, Output: 1.50},
+	"anthropic.claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"anthropic.claude-3-sonnet":   {Input: 3, Output: 15},
+	"anthropic.claude-3-5-sonnet": {Input: 3, Output: 15},
+	"anthropic.claude-3-opus":     {Input: 15, Output: 75},
+	"meta.llama3-8b":              {Input: 0.30, Output: 0.60},
+	"meta.llama3-70b":             {Input: 2.65, Output: 3.50},
+	"amazon.titan-text-lite":      {Input: 0.15, Output: 0.20},
+	"amazon.titan-text-express":   {Input: 0.20, Output: 0.60},
+}
+
+// translatorPrices are the prices of the --translator services in US dollars
+// per million characters.
+var translatorPrices = map[string]float64{
+	"deepl":  25,
+	"google": 20,
+}
+
+// usageTotals is the usage of an analysis, or of the whole run.
+type usageTotals struct {
+	PromptTokens     int `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens" yaml:"completion_tokens"`
+	// Characters counts the text sent to the --translator service.
+	Characters int `json:"characters,omitempty" yaml:"characters,omitempty"`
+	// Estimated is set when some of the counts are estimates, because the
+	// host didn't report them.
+	Estimated bool `json:"estimated,omitempty" yaml:"estimated,omitempty"`
+	// Cost is in US dollars, and only counts the paid providers with a
+	// known price.
+	Cost float64 `json:"cost,omitempty" yaml:"cost,omitempty"`
+}
+
+// sub returns the usage since earlier.
+func (u usageTotals) sub(earlier usageTotals) usageTotals {
+	return usageTotals{
+		PromptTokens:     u.PromptTokens - earlier.PromptTokens,
+		CompletionTokens: u.CompletionTokens - earlier.CompletionTokens,
+		Characters:       u.Characters - earlier.Characters,
+		Estimated:        u.Estimated,
+		Cost:             u.Cost - earlier.Cost,
+	}
+}
+
+// usageMeter adds up the usage of the requests of the run, and stops them
+// before --max-cost is exceeded.
+type usageMeter struct {
+	mu      sync.Mutex
+	maxCost float64
+	prices  map[string]modelPrice
+	total   usageTotals
+	// pending is the estimated cost of the requests in flight.
+	pending float64
+}
+
+// newUsageMeter returns the meter of --max-cost, 0 for no limit, with the
+// prices of the config file taking precedence over the list prices.
+func newUsageMeter(maxCost float64, configured map[string]modelPrice) *usageMeter {
+	prices := make(map[string]modelPrice, len(modelPrices)+len(configured))
+	for model, price := range modelPrices {
+		prices[model] = price
+	}
+	for model, price := range configured {
+		prices[model] = price
+	}
+	return &usageMeter{maxCost: maxCost, prices: prices}
+}
+
+// estimateTokens approximates the number of tokens of text, at four
+// characters per token, for the hosts that don't count them.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// price returns the price of model, when the provider name charges for it.
+func (m *usageMeter) price(name, model string) (modelPrice, bool) {
+	if !providers[name].paid {
+		return modelPrice{}, false
+	}
+	if price, ok := m.prices[model]; ok {
+		return price, true
+	}
+	// Bedrock inference profiles prefix the model ID with a geography.
+	for _, geography := range []string{"us.", "eu.", "apac."} {
+		model = strings.TrimPrefix(model, geography)
+	}
+	var best string
+	for prefix := range m.prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	price, ok := m.prices[best]
+	return price, ok && best != ""
+}
+
+// Snapshot returns the usage so far.
+func (m *usageMeter) Snapshot() usageTotals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+// reserve checks that a request with the estimated cost may be sent without
+// exceeding --max-cost, and counts it as pending until settle.
+func (m *usageMeter) reserve(cost float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maxCost > 0 && m.total.Cost+m.pending+cost > m.maxCost {
+		return fmt.Errorf("the next request would bring the estimated cost to $%.6f, above --max-cost $%.6f", m.total.Cost+m.pending+cost, m.maxCost)
+	}
+	m.pending += cost
+	return nil
+}
+
+// settle replaces the reserved estimate of a request with what it cost.
+func (m *usageMeter) settle(reserved float64, usage usageTotals) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending -= reserved
+	m.total.PromptTokens += usage.PromptTokens
+	m.total.CompletionTokens += usage.CompletionTokens
+	m.total.Characters += usage.Characters
+	m.total.Estimated = m.total.Estimated || usage.Estimated
+	m.total.Cost += usage.Cost
+}
+
+// meterGeneration makes a request of provider name to model with call,
+// which receives the usage to fill in. The request is refused when its
+// estimated cost, with a response as long as the prompt, could exceed
+// --max-cost; the counts the host doesn't report are estimated from prompt
+// and the response.
+func (m *usageMeter) meterGeneration(name, model, prompt string, call func(*llm.Usage) (string, error)) (string, error) {
+	price, paid := m.price(name, model)
+	promptEstimate := estimateTokens(prompt)
+	reserved := 0.0
+	if paid {
+		reserved = float64(promptEstimate) * (price.Input + price.Output) / 1e6
+	}
+	if err := m.reserve(reserved); err != nil {
+		return "", err
+	}
+
+	var usage llm.Usage
+	response, err := call(&usage)
+	if err != nil {
+		m.settle(reserved, usageTotals{})
+		return "", err
+	}
+
+	totals := usageTotals{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens}
+	if usage.PromptTokens == 0 {
+		totals.PromptTokens, totals.Estimated = promptEstimate, true
+	}
+	if usage.CompletionTokens == 0 {
+		totals.CompletionTokens, totals.Estimated = estimateTokens(response), true
+	}
+	if paid {
+		totals.Cost = (float64(totals.PromptTokens)*price.Input + float64(totals.CompletionTokens)*price.Output) / 1e6
+	}
+	m.settle(reserved, totals)
+	return response, nil
+}
+
+// meterTranslation makes a request of the translator service name for text
+// with call, charged by the character.
+func (m *usageMeter) meterTranslation(name, text string, call func() (string, error)) (string, error) {
+	characters := utf8.RuneCountInString(text)
+	cost := float64(characters) * translatorPrices[name] / 1e6
+	if err := m.reserve(cost); err != nil {
+		return "", err
+	}
+
+	response, err := call()
+	if err != nil {
+		m.settle(cost, usageTotals{})
+		return "", err
+	}
+	m.settle(cost, usageTotals{Characters: characters, Cost: cost})
+	return response, nil
+}
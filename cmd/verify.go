@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// verifyResult translates result back into the source language and records
+// how similar the back-translation is to the original section.
+func verifyResult(ctx context.Context, result *ResultItem, opts analysisOptions) error {
+	backTranslation, err := translateSection(ctx, result.Translation, opts.translationLanguage, opts.sourceLanguage, nil, opts)
+	if err != nil {
+		return fmt.Errorf("verifying %q: %w", result.Source, err)
+	}
+
+	similarity := textSimilarity(result.Source, backTranslation)
+	result.BackTranslation = backTranslation
+	result.Similarity = &similarity
+	result.Divergent = similarity < opts.verifyThreshold
+	return nil
+}
+
+// textSimilarity scores how alike two texts are between 0 and 1, using the
+// Dice coefficient of their character trigrams. Case, punctuation and spacing
+// are ignored, so rephrasings that keep most words score high.
+func textSimilarity(a, b string) float64 {
+	return trigramSimilarity(trigrams(a), trigrams(b))
+}
+
+// trigramSimilarity is textSimilarity of the texts with the trigrams ta and
+// tb.
+func trigramSimilarity(ta, tb map[string]int) float64 {
+	if len(ta) == 0 && len(tb) == 0 {
+		return 1
+	}
+
+	total, shared := 0, 0
+	for gram, n := range ta {
+		total += n
+		if m, ok := tb[gram]; ok {
+			shared += min(n, m)
+		}
+	}
+	for _, n := range tb {
+		total += n
+	}
+
+	score := 2 * float64(shared) / float64(total)
+	return math.Round(score*100) / 100
+}
+
+// trigrams counts the character trigrams of the normalized words of s.
+func trigrams(s string) map[string]int {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	grams := make(map[string]int)
+	for _, word := range words {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams[string(runes[i:i+3])]++
+		}
+	}
+	return grams
+}
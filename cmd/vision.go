@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+	"github.com/spf13/cobra"
+)
+
+const defaultVisionModel = "llava"
+
+// extractTextPrompt asks a vision model for the text of an image. The
+// language of the text is appended when it is known.
+const extractTextPrompt = "Transcribe all the text visible in the image, such as the items of a menu, a sign or the page of a book, exactly as it is written and in its reading order. Keep its line breaks, don't translate or correct it, and respond with only the text, without any description of the image or additional explanation. If the image contains no text, respond with nothing."
+
+// visionModel returns the --vision-model reading --image.
+func visionModel(cmd *cobra.Command) (string, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return "", err
+	}
+	model, err := resolveSetting(cmd, "vision-model", "STARTER_GO_CLI_VISION_MODEL", cfg.VisionModel, defaultVisionModel)
+	if err != nil {
+		return "", fmt.Errorf("retrieving vision-model flag: %w", err)
+	}
+	return model, nil
+}
+
+// extractImageText returns the text in the image file at path, which the
+// vision model reads through the provider, in the --source-language when it
+// is set.
+func extractImageText(ctx context.Context, path, model string, opts analysisOptions) (string, error) {
+	image, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if kind := http.DetectContentType(image); !strings.HasPrefix(kind, "image/") {
+		return "", fmt.Errorf("%s is not an image (detected %s)", path, kind)
+	}
+	prompt := extractTextPrompt
+	if opts.sourceLanguage != "" {
+		prompt += fmt.Sprintf(" The text is written in %s.", opts.sourceLanguage)
+	}
+
+	fmt.Fprintf(os.Stderr, "Reading the text of %s\n", path)
+	text, err := generate(ctx, opts, llm.Request{Model: model, Prompt: prompt, Images: [][]byte{image}})
+	if err != nil {
+		return "", fmt.Errorf("reading the text of %s: %w", path, err)
+	}
+	text = strings.TrimSpace(analyze.StripCodeFences(text))
+	if text == "" {
+		return "", fmt.Errorf("%s: no text was found in the image", path)
+	}
+	return text, nil
+}
@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+// vocabEntry is a word of the vocabulary, found Count times in the text and
+// first in Sentence.
+type vocabEntry struct {
+	Word  string `json:"word" yaml:"word"`
+	Lemma string `json:"lemma" yaml:"lemma"`
+	// Level is the CEFR level of the lemma, only set with --difficulty.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// IPA is the IPA transcription of the lemma, only set with --ipa.
+	IPA string `json:"ipa,omitempty" yaml:"ipa,omitempty"`
+	// Pinyin is the pinyin of the lemma, only set with --pinyin for
+	// Chinese.
+	Pinyin string `json:"pinyin,omitempty" yaml:"pinyin,omitempty"`
+	// Furigana is the lemma with the readings of its kanji, only set with
+	// --furigana for Japanese.
+	Furigana    string `json:"furigana,omitempty" yaml:"furigana,omitempty"`
+	POS         string `json:"pos" yaml:"pos"`
+	Translation string `json:"translation" yaml:"translation"`
+	Sentence    string `json:"sentence" yaml:"sentence"`
+	Count       int    `json:"count" yaml:"count"`
+	// Synonyms and Antonyms are words of the same language with the same and
+	// the opposite meaning, only set with --synonyms.
+	Synonyms []string `json:"synonyms,omitempty" yaml:"synonyms,omitempty"`
+	Antonyms []string `json:"antonyms,omitempty" yaml:"antonyms,omitempty"`
+	// Examples are new sentences using the word, only set with --examples.
+	Examples []exampleText `json:"examples,omitempty" yaml:"examples,omitempty"`
+}
+
+// vocabExamples are the example sentences written for every word: count of
+// them, for learners at level, or at the level of the word when it is empty.
+type vocabExamples struct {
+	count int
+	level string
+}
+
+// vocabulary is the output of the vocab command.
+type vocabulary struct {
+	SourceLanguage      string       `json:"source_language" yaml:"source_language"`
+	TranslationLanguage string       `json:"translation_language" yaml:"translation_language"`
+	Usage               *usageTotals `json:"usage,omitempty" yaml:"usage,omitempty"`
+	Entries             []vocabEntry `json:"entries" yaml:"entries"`
+}
+
+// vocabAnnotation is a column of the tabular formats written next to the
+// lemma, such as its pronunciation.
+type vocabAnnotation struct {
+	name  string
+	value func(vocabEntry) string
+}
+
+// vocabAnnotations are the columns following the lemma, in order.
+var vocabAnnotations = []vocabAnnotation{
+	{"ipa", func(entry vocabEntry) string { return entry.IPA }},
+	{"pinyin", func(entry vocabEntry) string { return entry.Pinyin }},
+	{"furigana", func(entry vocabEntry) string { return entry.Furigana }},
+	{"level", func(entry vocabEntry) string { return entry.Level }},
+	{"synonyms", func(entry vocabEntry) string { return strings.Join(entry.Synonyms, ", ") }},
+	{"antonyms", func(entry vocabEntry) string { return strings.Join(entry.Antonyms, ", ") }},
+}
+
+var vocabCmd = &cobra.Command{
+	Use:   "vocab [text]",
+	Short: "List the vocabulary of a text with dictionary forms, parts of speech and translations",
+	Long: `The "vocab" command takes a string of text as an argument (or from stdin when the argument is "-" or omitted), divides it into sentences and asks the LLM for the content words of each one: nouns, verbs, adjectives and adverbs.
+Every word is listed once, in the order it first appears, with its dictionary form (lemma), its part of speech as a Universal Dependencies tag, its translation into the --translation-language, the sentence it first appeared in and the number of times it appears.
+With --furigana, the dictionary forms of Japanese words come with the readings of their kanji, and with --pinyin the ones of Chinese words with their pinyin. --ipa adds the IPA transcription of every dictionary form, and --synonyms its synonyms and antonyms.
+--difficulty rates every word with a CEFR level (A1 to C2), and --min-level leaves out the words easier than the given one.
+--examples writes new example sentences using every word, with their translations, so that it can be studied beyond the sentence it was found in; --examples-level sets the CEFR level they are written for.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runVocab,
+}
+
+func runVocab(cmd *cobra.Command, args []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return fmt.Errorf("retrieving format flag: %w", err)
+	}
+	if err := checkDocumentFormat(format); err != nil {
+		return err
+	}
+	var examples vocabExamples
+	examples.count, err = cmd.Flags().GetInt("examples")
+	if err != nil {
+		return fmt.Errorf("retrieving examples flag: %w", err)
+	}
+	if examples.count < 0 {
+		return errors.New("--examples cannot be negative")
+	}
+	examplesLevel, err := cmd.Flags().GetString("examples-level")
+	if err != nil {
+		return fmt.Errorf("retrieving examples-level flag: %w", err)
+	}
+	if examplesLevel != "" {
+		rank, err := parseCEFRLevel(examplesLevel)
+		if err != nil {
+			return err
+		}
+		examples.level = cefrLevels[rank]
+	}
+
+	text, err := readInputText(args)
+	if err != nil {
+		return fmt.Errorf("reading input text: %w", err)
+	}
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	defer opts.tracer.Flush()
+	warmUp(ctx, opts)
+
+	vocab, err := extractVocabulary(ctx, text, examples, opts)
+	if err != nil {
+		return runError(ctx, opts, err)
+	}
+
+	// The pronunciation columns follow the lemma, when any entry has them.
+	var annotations []vocabAnnotation
+	for _, annotation := range vocabAnnotations {
+		for _, entry := range vocab.Entries {
+			if annotation.value(entry) != "" {
+				annotations = append(annotations, annotation)
+				break
+			}
+		}
+	}
+	header := []string{"word", "lemma"}
+	for _, annotation := range annotations {
+		header = append(header, annotation.name)
+	}
+	header = append(header, "pos", "translation", "count", "sentence")
+	if examples.count > 0 {
+		header = append(header, "examples")
+	}
+	rows := make([][]string, 0, len(vocab.Entries))
+	for _, entry := range vocab.Entries {
+		row := []string{entry.Word, entry.Lemma}
+		for _, annotation := range annotations {
+			row = append(row, annotation.value(entry))
+		}
+		row = append(row, entry.POS, entry.Translation, strconv.Itoa(entry.Count), entry.Sentence)
+		if examples.count > 0 {
+			row = append(row, joinExamples(entry.Examples))
+		}
+		rows = append(rows, row)
+	}
+	if err := writeDocument(cmd.OutOrStdout(), format, vocab, header, rows); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// extractVocabulary asks the LLM for the content words of every sentence of
+// text, merging the occurrences of a word with the same lemma and part of
+// speech, and for the examples of every word.
+func extractVocabulary(ctx context.Context, text string, examples vocabExamples, opts analysisOptions) (vocabulary, error) {
+	usageBefore := opts.usage.Snapshot()
+	if opts.sourceLanguage == "" {
+		sourceLanguage, err := detectLanguage(ctx, text, opts)
+		if err != nil {
+			return vocabulary{}, err
+		}
+		opts.sourceLanguage = sourceLanguage
+	}
+
+	sentences := splitSentences(text)
+	opts.progress.Start(len(sentences))
+	defer opts.progress.Finish()
+
+	extract := func(_ int, sentence string) ([]vocabEntry, error) {
+		opts.progress.Begin(sentence)
+		req, err := renderPrompt(defaultVocabPrompt, opts.translateModel, promptData{Text: sentence, Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage})
+		if err != nil {
+			return nil, err
+		}
+		var entries []vocabEntry
+		if err := generateJSON(ctx, opts, req, &entries); err != nil {
+			return nil, fmt.Errorf("extracting the vocabulary of %q: %w", sentence, err)
+		}
+		opts.progress.Advance()
+		return entries, nil
+	}
+	perSentence, err := analyze.RunOrdered(sentences, opts.concurrency, extract, nil)
+	if err != nil {
+		return vocabulary{}, err
+	}
+
+	vocab := vocabulary{SourceLanguage: opts.sourceLanguage, TranslationLanguage: opts.translationLanguage, Entries: []vocabEntry{}}
+	seen := make(map[string]int)
+	for i, entries := range perSentence {
+		for _, entry := range entries {
+			entry.Word, entry.Lemma = strings.TrimSpace(entry.Word), strings.TrimSpace(entry.Lemma)
+			entry.POS = strings.ToUpper(strings.TrimSpace(entry.POS))
+			if entry.Lemma == "" {
+				entry.Lemma = entry.Word
+			}
+			if entry.Lemma == "" {
+				continue
+			}
+			key := strings.ToLower(entry.Lemma) + "\x00" + entry.POS
+			if index, ok := seen[key]; ok {
+				vocab.Entries[index].Count++
+				continue
+			}
+			entry.Sentence, entry.Count = sentences[i], 1
+			seen[key] = len(vocab.Entries)
+			vocab.Entries = append(vocab.Entries, entry)
+		}
+	}
+
+	furigana := furiganaApplies(opts.sourceLanguage, opts)
+	pinyin := pinyinApplies(opts.sourceLanguage, opts)
+	if furigana || pinyin || opts.ipa || opts.difficulty || opts.synonyms || examples.count > 0 {
+		annotate := func(_ int, entry vocabEntry) (vocabEntry, error) {
+			var err error
+			if opts.difficulty {
+				if entry.Level, err = rateDifficulty(ctx, entry.Lemma, opts); err != nil {
+					return vocabEntry{}, err
+				}
+				// Words easier than --min-level are left out, and need
+				// nothing else.
+				if belowLevel(entry.Level, opts) {
+					return entry, nil
+				}
+			}
+			if opts.ipa {
+				if entry.IPA, err = transcribeIPA(ctx, entry.Lemma, opts); err != nil {
+					return vocabEntry{}, err
+				}
+			}
+			if furigana {
+				entry.Furigana, err = annotateFurigana(ctx, entry.Lemma, opts)
+			} else if pinyin {
+				entry.Pinyin, err = annotatePinyin(ctx, entry.Lemma, opts)
+			}
+			if err != nil {
+				return vocabEntry{}, err
+			}
+			if opts.synonyms {
+				relations, err := findSynonyms(ctx, entry.Lemma, opts)
+				if err != nil {
+					return vocabEntry{}, err
+				}
+				for _, related := range relations {
+					entry.Synonyms = append(entry.Synonyms, related.Synonyms...)
+					entry.Antonyms = append(entry.Antonyms, related.Antonyms...)
+				}
+			}
+			if examples.count > 0 {
+				if entry.Examples, err = writeExamples(ctx, entry, examples, opts); err != nil {
+					return vocabEntry{}, err
+				}
+			}
+			return entry, nil
+		}
+		annotated, err := analyze.RunOrdered(vocab.Entries, opts.concurrency, annotate, nil)
+		if err != nil {
+			return vocabulary{}, err
+		}
+		vocab.Entries = vocab.Entries[:0]
+		for _, entry := range annotated {
+			if !belowLevel(entry.Level, opts) {
+				vocab.Entries = append(vocab.Entries, entry)
+			}
+		}
+	}
+	usage := opts.usage.Snapshot().sub(usageBefore)
+	vocab.Usage = &usage
+	return vocab, nil
+}
+
+// writeExamples asks the LLM for new example sentences using the word of
+// entry, in the sense it was translated with.
+func writeExamples(ctx context.Context, entry vocabEntry, examples vocabExamples, opts analysisOptions) ([]exampleText, error) {
+	level := examples.level
+	if level == "" {
+		level = entry.Level
+	}
+	word := fmt.Sprintf("%s (%s, %q)", entry.Lemma, entry.POS, entry.Translation)
+	req, err := renderPrompt(defaultExamplesPrompt, opts.translateModel, promptData{Text: word, Language: opts.translationLanguage, SourceLanguage: opts.sourceLanguage, Count: examples.count, Level: level})
+	if err != nil {
+		return nil, err
+	}
+	var written []exampleText
+	if err := generateJSON(ctx, opts, req, &written); err != nil {
+		return nil, fmt.Errorf("writing examples of %q: %w", entry.Lemma, err)
+	}
+	if len(written) > examples.count {
+		written = written[:examples.count]
+	}
+	return written, nil
+}
+
+// joinExamples writes examples on a single line for the tabular formats.
+func joinExamples(examples []exampleText) string {
+	parts := make([]string, 0, len(examples))
+	for _, example := range examples {
+		part := example.Text
+		if example.Translation != "" {
+			part += " (" + example.Translation + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " | ")
+}
+
+func init() {
+	addAnalysisFlags(vocabCmd.Flags())
+	vocabCmd.Flags().String("format", "json", "The output format: json, yaml, csv or table")
+	vocabCmd.Flags().Int("examples", 0, "The number of new example sentences written for every word, with their translations (0 disables it)")
+	vocabCmd.Flags().String("examples-level", "", "The CEFR level the --examples are written for, such as A2 (default is the --difficulty of the word, if rated)")
+
+	rootCmd.AddCommand(vocabCmd)
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/analyze"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// xliffVersion2 matches the root element of XLIFF 2 documents.
+	xliffVersion2 = regexp.MustCompile(`<xliff\b[^>]*\bversion\s*=\s*["']2`)
+	// xliffUnit matches the trans-unit elements of XLIFF 1.2 and the unit
+	// elements of XLIFF 2, with their start tag as the first group.
+	xliffUnit = regexp.MustCompile(`(?s)(<trans-unit\b[^>]*>).*?</trans-unit>|(<unit\b[^>]*>).*?</unit>`)
+	// xliffSegment matches the segments of the units of XLIFF 2.
+	xliffSegment = regexp.MustCompile(`(?s)(<segment\b[^>]*>).*?</segment>`)
+	xliffSource  = regexp.MustCompile(`(?s)<source\b[^>]*>(.*?)</source>`)
+	xliffTarget  = regexp.MustCompile(`(?s)<target\b([^>]*?)(/>|>(.*?)</target>)`)
+	// xliffFile matches the elements holding the languages of a document:
+	// the file elements of XLIFF 1.2 and the root element of XLIFF 2.
+	xliffFile = regexp.MustCompile(`<file\b[^>]*>|<xliff\b[^>]*>`)
+)
+
+// xliffSegmentSpan is a segment of an XLIFF document without a translation:
+// a trans-unit of XLIFF 1.2, or a segment of XLIFF 2.
+type xliffSegmentSpan struct {
+	start, end int
+	source     string
+}
+
+var i18nXliffCmd = &cobra.Command{
+	Use:   "xliff",
+	Short: "Work with XLIFF 1.2 and 2 files",
+}
+
+var i18nXliffTranslateCmd = &cobra.Command{
+	Use:   "translate <file.xlf>",
+	Short: "Fill in the untranslated units of an XLIFF file",
+	Long: `The "translate" command reads an XLIFF 1.2 or 2 file and translates the source of every trans-unit (or segment, for XLIFF 2) without a target into the --translation-language, keeping its inline elements, such as <g>, <x/>, <ph> or <pc>, and its format specifiers. The units marked translate="no" and the targets already there are kept as they are.
+The translations are marked with the translated state, and the target language of the file is set to the --translation-language. The source language of the file is used unless --source-language is given. Translations that lose any inline element or format specifier are left out, with a warning.
+The document is written to stdout, or to --output. Use "analise --format xliff" to export the results of an analysis as an XLIFF file instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runI18nXliffTranslate,
+}
+
+func runI18nXliffTranslate(cmd *cobra.Command, args []string) error {
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return fmt.Errorf("retrieving output flag: %w", err)
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return fmt.Errorf("retrieving force flag: %w", err)
+	}
+
+	text, err := readInputFile(args[0])
+	if err != nil {
+		return fmt.Errorf("reading XLIFF file: %w", err)
+	}
+	if !strings.Contains(text, "<xliff") {
+		return fmt.Errorf("%s: not an XLIFF document", args[0])
+	}
+	version2 := xliffVersion2.MatchString(text)
+	spans := xliffUntranslated(text, version2)
+
+	opts, err := analysisOptionsFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(opts.translationLanguages) > 1 {
+		return errors.New("i18n xliff translate requires a single --translation-language")
+	}
+
+	translations := make([]string, len(spans))
+	if len(spans) > 0 {
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		defer opts.tracer.Flush()
+		warmUp(ctx, opts)
+
+		if opts.sourceLanguage == "" {
+			opts.sourceLanguage = xliffAttribute(xliffFile.FindString(text), "source-language", "srcLang")
+		}
+		if opts.sourceLanguage == "" {
+			sources := make([]string, len(spans))
+			for i, span := range spans {
+				sources[i] = htmlEntity.ReplaceAllString(span.source, " ")
+			}
+			if opts.sourceLanguage, err = detectLanguage(ctx, leadingWords(strings.Join(sources, " "), readabilitySampleWords), opts); err != nil {
+				return runError(ctx, opts, err)
+			}
+		}
+
+		opts.progress.Start(len(spans))
+		translate := func(_ int, span xliffSegmentSpan) (string, error) {
+			opts.progress.Begin(span.source)
+			translation, err := translateXLIFFSource(ctx, span.source, opts)
+			if err != nil {
+				return "", err
+			}
+			opts.progress.Advance()
+			return translation, nil
+		}
+		translations, err = analyze.RunOrdered(spans, opts.concurrency, translate, nil)
+		opts.progress.Finish()
+		if err != nil {
+			return runError(ctx, opts, err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "Every unit is already translated")
+	}
+
+	// The segments are filled in from the last one, so that the offsets of
+	// the others stay where they were.
+	count := 0
+	for i := len(spans) - 1; i >= 0; i-- {
+		if translations[i] == "" {
+			continue
+		}
+		span := spans[i]
+		text = text[:span.start] + fillXLIFFTarget(text[span.start:span.end], translations[i], version2) + text[span.end:]
+		count++
+	}
+	text = xliffFile.ReplaceAllStringFunc(text, func(tag string) string {
+		if version2 && strings.HasPrefix(tag, "<xliff") {
+			return setXMLAttribute(tag, "trgLang", opts.translationLanguage)
+		}
+		if !version2 && strings.HasPrefix(tag, "<file") {
+			return setXMLAttribute(tag, "target-language", opts.translationLanguage)
+		}
+		return tag
+	})
+
+	if outputPath == "" {
+		_, err = io.WriteString(cmd.OutOrStdout(), text)
+		return err
+	}
+	if err := writeFileAtomic(outputPath, []byte(text), force); err != nil {
+		return fmt.Errorf("writing output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d translated units to %s\n", count, outputPath)
+	return nil
+}
+
+// xliffUntranslated returns the segments of an XLIFF document whose target
+// is missing or empty, leaving out the units marked translate="no".
+func xliffUntranslated(text string, version2 bool) []xliffSegmentSpan {
+	var spans []xliffSegmentSpan
+	add := func(start, end int) {
+		element := xliffMainPart(text[start:end])
+		source := xliffSource.FindStringSubmatch(element)
+		if source == nil || strings.TrimSpace(source[1]) == "" {
+			return
+		}
+		if target := xliffTarget.FindStringSubmatch(element); target != nil && strings.TrimSpace(target[3]) != "" {
+			return
+		}
+		spans = append(spans, xliffSegmentSpan{start: start, end: end, source: source[1]})
+	}
+	for _, unit := range xliffUnit.FindAllStringSubmatchIndex(text, -1) {
+		var startTag string
+		if unit[2] >= 0 {
+			startTag = text[unit[2]:unit[3]]
+		} else {
+			startTag = text[unit[4]:unit[5]]
+		}
+		if htmlNoTranslate.MatchString(startTag) {
+			continue
+		}
+		if !version2 {
+			add(unit[0], unit[1])
+			continue
+		}
+		for _, segment := range xliffSegment.FindAllStringIndex(text[unit[0]:unit[1]], -1) {
+			add(unit[0]+segment[0], unit[0]+segment[1])
+		}
+	}
+	return spans
+}
+
+// xliffMainPart returns the part of a trans-unit before its alternative
+// translations, whose sources and targets are not the ones of the unit.
+func xliffMainPart(element string) string {
+	if i := strings.Index(element, "<alt-trans"); i >= 0 {
+		return element[:i]
+	}
+	return element
+}
+
+// translateXLIFFSource translates the content of a source element, with its
+// inline elements, entities and format specifiers as placeholders. It
+// returns an empty translation, with a warning, when the translation loses
+// any of them, and the source as it is when it has nothing to translate.
+func translateXLIFFSource(ctx context.Context, source string, opts analysisOptions) (string, error) {
+	var b strings.Builder
+	for _, segment := range htmlText(tokenizeHTML(source)) {
+		if !segment.Translate {
+			b.WriteString(segment.Text)
+			continue
+		}
+		text := formatSpecifier.ReplaceAllStringFunc(segment.Text, func(specifier string) string {
+			segment.Kept = append(segment.Kept, specifier)
+			return docPlaceholder(len(segment.Kept) - 1)
+		})
+		translation, err := translateSection(ctx, text, opts.sourceLanguage, opts.translationLanguage, opts.glossary, opts)
+		if err != nil {
+			return "", err
+		}
+		translation, ok := restorePlaceholders(strings.TrimSpace(translation), segment.Kept)
+		if !ok || !validHTMLTranslation(source, translation) {
+			fmt.Fprintf(os.Stderr, "Warning: leaving %q untranslated, its translation lost some of its inline elements\n", source)
+			return "", nil
+		}
+		b.WriteString(translation)
+	}
+	return b.String(), nil
+}
+
+// fillXLIFFTarget sets the target of a trans-unit or of an XLIFF 2 segment
+// to translation, adding it after the source when there is none, and marks
+// it as translated.
+func fillXLIFFTarget(element, translation string, version2 bool) string {
+	main := xliffMainPart(element)
+	rest := element[len(main):]
+	if version2 {
+		start := xliffSegment.FindStringSubmatch(main)[1]
+		main = setXMLAttribute(start, "state", "translated") + main[len(start):]
+	}
+	startTag := "<target>"
+	if !version2 {
+		startTag = `<target state="translated">`
+	}
+
+	if target := xliffTarget.FindStringSubmatchIndex(main); target != nil {
+		attributes := main[target[2]:target[3]]
+		tag := "<target" + attributes + ">"
+		if !version2 {
+			tag = setXMLAttribute(tag, "state", "translated")
+		}
+		return main[:target[0]] + tag + translation + "</target>" + main[target[1]:] + rest
+	}
+	source := xliffSource.FindStringIndex(main)
+	indent := main[strings.LastIndexByte(main[:source[0]], '\n')+1 : source[0]]
+	if strings.TrimSpace(indent) != "" {
+		indent = ""
+	}
+	return main[:source[1]] + "\n" + indent + startTag + translation + "</target>" + main[source[1]:] + rest
+}
+
+// xliffAttribute returns the value of the first of names that tag has.
+func xliffAttribute(tag string, names ...string) string {
+	for _, name := range names {
+		if match := regexp.MustCompile(`\s` + name + `\s*=\s*("([^"]*)"|'([^']*)')`).FindStringSubmatch(tag); match != nil {
+			return match[2] + match[3]
+		}
+	}
+	return ""
+}
+
+// setXMLAttribute sets the attribute name of the start tag tag to value.
+func setXMLAttribute(tag, name, value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	attribute := name + `="` + strings.ReplaceAll(b.String(), `"`, "&quot;") + `"`
+	existing := regexp.MustCompile(`\s` + name + `\s*=\s*("[^"]*"|'[^']*')`)
+	if existing.MatchString(tag) {
+		return existing.ReplaceAllLiteralString(tag, " "+attribute)
+	}
+	end := strings.TrimSuffix(strings.TrimSuffix(tag, ">"), "/")
+	return end + " " + attribute + tag[len(end):]
+}
+
+// renderXLIFF writes analyses as an XLIFF 1.2 document, with a file element
+// per analysis and translation language and a trans-unit per section, for
+// CAT tools.
+func renderXLIFF(w io.Writer, analyses []Analysis, _ bool) error {
+	var b strings.Builder
+	escape := func(text string) string {
+		var e strings.Builder
+		xml.EscapeText(&e, []byte(text))
+		return e.String()
+	}
+	b.WriteString(xml.Header)
+	b.WriteString(`<xliff version="1.2" xmlns="urn:oasis:names:tc:xliff:document:1.2">` + "\n")
+	for _, analysis := range analyses {
+		original := analysis.File
+		if original == "" {
+			original = "text"
+		}
+		languages := analysis.TranslationLanguages
+		if len(languages) == 0 {
+			languages = []string{analysis.TranslationLanguage}
+		}
+		for _, language := range languages {
+			fmt.Fprintf(&b, `  <file original="%s" source-language="%s" target-language="%s" datatype="plaintext">`+"\n", escape(original), escape(analysis.SourceLanguage), escape(language))
+			b.WriteString("    <body>\n")
+			for i, item := range analysis.Results {
+				translation := item.Translation
+				if len(analysis.TranslationLanguages) > 0 {
+					translation = item.Translations[language]
+				}
+				fmt.Fprintf(&b, "      <trans-unit id=\"%d\">\n", i+1)
+				fmt.Fprintf(&b, "        <source>%s</source>\n", escape(item.Source))
+				if translation != "" {
+					fmt.Fprintf(&b, "        <target state=\"translated\">%s</target>\n", escape(translation))
+				}
+				if item.Explanation != "" {
+					fmt.Fprintf(&b, "        <note>%s</note>\n", escape(item.Explanation))
+				}
+				b.WriteString("      </trans-unit>\n")
+			}
+			b.WriteString("    </body>\n  </file>\n")
+		}
+	}
+	b.WriteString("</xliff>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func init() {
+	addAnalysisFlags(i18nXliffTranslateCmd.Flags())
+	i18nXliffTranslateCmd.Flags().StringP("output", "o", "", "Write the XLIFF document to this file instead of stdout")
+	i18nXliffTranslateCmd.Flags().Bool("force", false, "Overwrite the --output file if it already exists")
+
+	i18nXliffCmd.AddCommand(i18nXliffTranslateCmd)
+	i18nCmd.AddCommand(i18nXliffCmd)
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// xlsxMaxWidth is the widest a column of an xlsx sheet gets, in characters;
+// the cells of wider columns wrap.
+const xlsxMaxWidth = 60
+
+// xlsxNumeric are the columns of tableRows written as numbers.
+var xlsxNumeric = map[string]bool{"page": true, "paragraph": true, "similarity": true}
+
+// xlsxSheet is a worksheet of a workbook, as the header and rows of
+// tableRows.
+type xlsxSheet struct {
+	Name   string
+	Header []string
+	Rows   [][]string
+}
+
+// renderXLSX writes analyses as an Excel workbook with the columns of the
+// tabular formats, the source next to its translation. Results translated
+// into several languages get a sheet per language.
+func renderXLSX(w io.Writer, analyses []Analysis, withFiles bool) error {
+	var languages []string
+	for _, analysis := range analyses {
+		if len(analysis.TranslationLanguages) > 0 {
+			languages = analysis.TranslationLanguages
+			break
+		}
+	}
+	if len(languages) == 0 {
+		name := "Results"
+		if len(analyses) > 0 && analyses[0].TranslationLanguage != "" {
+			name = analyses[0].TranslationLanguage
+		}
+		header, rows := tableRows(analyses, withFiles)
+		return writeXLSX(w, []xlsxSheet{{Name: name, Header: header, Rows: rows}})
+	}
+
+	// Every sheet has the analyses with the translations into its language
+	// alone.
+	var sheets []xlsxSheet
+	for _, language := range languages {
+		translated := make([]Analysis, len(analyses))
+		for i, analysis := range analyses {
+			analysis.TranslationLanguage, analysis.TranslationLanguages = language, nil
+			analysis.Results = append([]ResultItem(nil), analysis.Results...)
+			for j := range analysis.Results {
+				analysis.Results[j].Translation = analysis.Results[j].Translations[language]
+			}
+			translated[i] = analysis
+		}
+		header, rows := tableRows(translated, withFiles)
+		sheets = append(sheets, xlsxSheet{Name: language, Header: header, Rows: rows})
+	}
+	return writeXLSX(w, sheets)
+}
+
+// writeXLSX writes sheets as an Office Open XML workbook. Their header is
+// bold and stays in view when scrolling, and their columns are as wide as
+// their contents, up to xlsxMaxWidth.
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	var workbook, relationships, contentTypes strings.Builder
+	for i, sheet := range sheets {
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(xlsxSheetName(sheet.Name, sheets[:i])), i+1, i+1)
+		fmt.Fprintf(&relationships, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	fmt.Fprintf(&relationships, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	files := [][2]string{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			contentTypes.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbook.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			relationships.String() + `</Relationships>`},
+		// The cell styles are the default one, the bold one of the headers
+		// and the wrapping one of the widest columns.
+		{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+			`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" applyAlignment="1"><alignment vertical="top" wrapText="1"/></xf></cellXfs>` +
+			`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+			`</styleSheet>`},
+	}
+	for i, sheet := range sheets {
+		files = append(files, [2]string{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxWorksheet(sheet)})
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		entry, err := archive.Create(file[0])
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(entry, file[1]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// xlsxWorksheet returns the XML of the worksheet of sheet.
+func xlsxWorksheet(sheet xlsxSheet) string {
+	widths := make([]int, len(sheet.Header))
+	for i, name := range sheet.Header {
+		widths[i] = xlsxTextWidth(name)
+	}
+	for _, row := range sheet.Rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], xlsxTextWidth(cell))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<cols>`)
+	for i, width := range widths {
+		// The padding leaves room for the margins of the cells.
+		fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, min(width, xlsxMaxWidth)+2)
+	}
+	b.WriteString(`</cols><sheetData>`)
+	writeRow := func(number int, cells []string, header bool) {
+		fmt.Fprintf(&b, `<row r="%d">`, number)
+		for i, cell := range cells {
+			if cell == "" {
+				continue
+			}
+			ref := xlsxColumn(i) + strconv.Itoa(number)
+			style := 0
+			switch {
+			case header:
+				style = 1
+			case widths[i] > xlsxMaxWidth:
+				style = 2
+			}
+			if _, err := strconv.ParseFloat(cell, 64); err == nil && !header && xlsxNumeric[sheet.Header[i]] {
+				fmt.Fprintf(&b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, cell)
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xlsxEscape(cell))
+		}
+		b.WriteString(`</row>`)
+	}
+	writeRow(1, sheet.Header, true)
+	for i, row := range sheet.Rows {
+		writeRow(i+2, row, false)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// xlsxColumn returns the letters naming the column at index i, such as A or
+// AB.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxTextWidth returns the width of the longest line of text, in
+// characters, wide characters such as kanji counting as two.
+func xlsxTextWidth(text string) int {
+	widest := 0
+	for _, line := range strings.Split(text, "\n") {
+		width := 0
+		for _, r := range line {
+			width++
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				width++
+			}
+		}
+		widest = max(widest, width)
+	}
+	return widest
+}
+
+// xlsxSheetName returns name as a sheet name Excel accepts, unlike the ones
+// of the sheets before it: at most 31 characters, without []:*?/\.
+func xlsxSheetName(name string, before []xlsxSheet) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if name == "" {
+		name = "Sheet"
+	}
+	taken := func(candidate string) bool {
+		for i, sheet := range before {
+			if strings.EqualFold(xlsxSheetName(sheet.Name, before[:i]), candidate) {
+				return true
+			}
+		}
+		return false
+	}
+	unique := name
+	for n := 2; taken(unique); n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		runes := []rune(name)
+		unique = string(runes[:min(len(runes), 31-len(suffix))]) + suffix
+	}
+	return unique
+}
+
+// xlsxEscape escapes text for the XML of a workbook.
+func xlsxEscape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
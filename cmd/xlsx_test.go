@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// xlsxCell is a cell of a worksheet, as read back by readXLSX.
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Style  int    `xml:"s,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+// xlsxReadSheet is a worksheet of a workbook written by writeXLSX.
+type xlsxReadSheet struct {
+	Name string
+	// Cells holds the text of the cells by reference, such as B2, and
+	// Numbers the references of the numeric ones.
+	Cells   map[string]string
+	Numbers map[string]bool
+	Styles  map[string]int
+	Widths  []string
+	Frozen  bool
+}
+
+// readXLSX reads back the sheets of a workbook, following its relationships
+// the way spreadsheet applications do.
+func readXLSX(t *testing.T, data []byte) []xlsxReadSheet {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := zipFiles(archive)
+	read := func(name string, v any) {
+		t.Helper()
+		file, ok := files[name]
+		if !ok {
+			t.Fatalf("the workbook has no %s", name)
+		}
+		text, err := readZipFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := xml.Unmarshal([]byte(text), v); err != nil {
+			t.Fatalf("parsing %s: %v", name, err)
+		}
+	}
+
+	var contentTypes struct {
+		Overrides []struct {
+			PartName string `xml:"PartName,attr"`
+		} `xml:"Override"`
+	}
+	read("[Content_Types].xml", &contentTypes)
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	read("xl/workbook.xml", &workbook)
+	var relationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	read("xl/_rels/workbook.xml.rels", &relationships)
+	targets := make(map[string]string)
+	for _, relationship := range relationships.Relationships {
+		targets[relationship.ID] = "xl/" + relationship.Target
+	}
+	declared := make(map[string]bool)
+	for _, override := range contentTypes.Overrides {
+		declared[strings.TrimPrefix(override.PartName, "/")] = true
+	}
+
+	var sheets []xlsxReadSheet
+	for _, entry := range workbook.Sheets {
+		name := targets[entry.ID]
+		if !declared[name] {
+			t.Errorf("%s has no content type", name)
+		}
+		var worksheet struct {
+			Panes []struct {
+				State string `xml:"state,attr"`
+			} `xml:"sheetViews>sheetView>pane"`
+			Cols []struct {
+				Width string `xml:"width,attr"`
+			} `xml:"cols>col"`
+			Cells []xlsxCell `xml:"sheetData>row>c"`
+		}
+		read(name, &worksheet)
+		sheet := xlsxReadSheet{Name: entry.Name, Cells: map[string]string{}, Numbers: map[string]bool{}, Styles: map[string]int{}}
+		sheet.Frozen = len(worksheet.Panes) == 1 && worksheet.Panes[0].State == "frozen"
+		for _, col := range worksheet.Cols {
+			sheet.Widths = append(sheet.Widths, col.Width)
+		}
+		for _, cell := range worksheet.Cells {
+			sheet.Cells[cell.Ref], sheet.Styles[cell.Ref] = cell.Inline, cell.Style
+			if cell.Type == "" {
+				sheet.Cells[cell.Ref], sheet.Numbers[cell.Ref] = cell.Value, true
+			}
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets
+}
+
+func TestRenderXLSX(t *testing.T) {
+	long := strings.Repeat("Ein sehr langer Satz. ", 4)
+	analyses := []Analysis{{
+		File:                "a.pdf",
+		TranslationLanguage: "en",
+		Results: []ResultItem{
+			{Source: "Tom & <Jerry>", Translation: "  Tom & <Jerry>", Page: 1, Paragraph: 2},
+			{Source: long, Translation: "食べる", Page: 3, Paragraph: 1},
+		},
+	}}
+	var b bytes.Buffer
+	if err := renderXLSX(&b, analyses, true); err != nil {
+		t.Fatal(err)
+	}
+	sheets := readXLSX(t, b.Bytes())
+	if len(sheets) != 1 || sheets[0].Name != "en" || !sheets[0].Frozen {
+		t.Fatalf("sheets = %+v", sheets)
+	}
+	sheet := sheets[0]
+	want := map[string]string{
+		"A1": "file", "B1": "page", "C1": "paragraph", "D1": "source", "E1": "translation",
+		"A2": "a.pdf", "B2": "1", "C2": "2", "D2": "Tom & <Jerry>", "E2": "  Tom & <Jerry>",
+		"A3": "a.pdf", "B3": "3", "C3": "1", "D3": long, "E3": "食べる",
+	}
+	for ref, text := range want {
+		if sheet.Cells[ref] != text {
+			t.Errorf("%s = %q, want %q", ref, sheet.Cells[ref], text)
+		}
+	}
+	if len(sheet.Cells) != len(want) {
+		t.Errorf("the sheet has %d cells, want %d", len(sheet.Cells), len(want))
+	}
+	if !sheet.Numbers["B2"] || !sheet.Numbers["C3"] || sheet.Numbers["A2"] || sheet.Numbers["B1"] {
+		t.Errorf("numeric cells = %v, want the pages and paragraphs", sheet.Numbers)
+	}
+
+	// Headers are bold, and the cells of the columns too wide to show whole
+	// wrap, the widths taking wide characters as two.
+	if sheet.Styles["D1"] != 1 || sheet.Styles["D3"] != 2 || sheet.Styles["E3"] != 0 {
+		t.Errorf("styles = %v", sheet.Styles)
+	}
+	if got := strings.Join(sheet.Widths, " "); got != fmt.Sprintf("7 6 11 %d 17", xlsxMaxWidth+2) {
+		t.Errorf("widths = %s", got)
+	}
+}
+
+func TestRenderXLSXLanguages(t *testing.T) {
+	analyses := []Analysis{{
+		TranslationLanguages: []string{"en", "fr"},
+		Results: []ResultItem{
+			{Source: "Hund", Translations: map[string]string{"en": "dog", "fr": "chien"}},
+			{Source: "Katze", Translations: map[string]string{"en": "cat"}},
+		},
+	}}
+	var b bytes.Buffer
+	if err := renderXLSX(&b, analyses, false); err != nil {
+		t.Fatal(err)
+	}
+	sheets := readXLSX(t, b.Bytes())
+	var got []string
+	for _, sheet := range sheets {
+		got = append(got, fmt.Sprintf("%s: %s %s=%s %s=%s", sheet.Name, sheet.Cells["B1"], sheet.Cells["A2"], sheet.Cells["B2"], sheet.Cells["A3"], sheet.Cells["B3"]))
+	}
+	if want := "en: translation Hund=dog Katze=cat|fr: translation Hund=chien Katze="; strings.Join(got, "|") != want {
+		t.Errorf("sheets = %q, want %q", got, want)
+	}
+	if analyses[0].Results[0].Translation != "" {
+		t.Error("rendering the sheets changed the analyses")
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	var sheets []xlsxSheet
+	var names []string
+	for _, name := range []string{"en", "EN", "a/b:c", strings.Repeat("x", 40), strings.Repeat("x", 40), ""} {
+		names = append(names, xlsxSheetName(name, sheets))
+		sheets = append(sheets, xlsxSheet{Name: name})
+	}
+	want := []string{"en", "EN (2)", "a_b_c", strings.Repeat("x", 31), strings.Repeat("x", 27) + " (2)", "Sheet"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
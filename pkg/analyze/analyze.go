@@ -0,0 +1,239 @@
+// Package analyze divides texts into sections and translates them with the
+// providers of package llm. It is the pipeline of the analise command, for
+// the Go programs embedding it without going through the CLI:
+//
+//	provider := llm.NewOllama("http://localhost:11434/api/generate", true, nil)
+//	results, err := analyze.New(provider, nil).Analyze(ctx, text, analyze.Options{
+//		SegmentModel:         "llama3",
+//		TranslateModel:       "llama3",
+//		TranslationLanguages: []string{"en-US"},
+//	})
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// Generator completes prompts.
+type Generator interface {
+	Generate(ctx context.Context, req llm.Request) (string, error)
+}
+
+// Client sends the requests of the pipeline. Every llm.Provider is one.
+type Client interface {
+	Generator
+	llm.Translator
+}
+
+// Options configure the pipeline. Only TranslationLanguages is required.
+type Options struct {
+	// SegmentModel divides the text into sections and detects its language,
+	// and TranslateModel translates them.
+	SegmentModel   string
+	TranslateModel string
+	// SourceLanguage is the BCP 47 tag of the language of the text, which
+	// Analyze detects when it is empty.
+	SourceLanguage string
+	// TranslationLanguages are the locales every section is translated into.
+	TranslationLanguages []string
+	// Granularity is the size of the sections, a key of Granularities, and
+	// MinSectionWords and MaxSectionWords bound their number of words; zero
+	// leaves them to the model.
+	Granularity     string
+	MinSectionWords int
+	MaxSectionWords int
+	// Glossary holds the translations the terms of the text must get. It is
+	// only applied when translating into a single language.
+	Glossary []GlossaryTerm
+	// SegmentPrompt and TranslatePrompt replace the built-in prompts when
+	// set.
+	SegmentPrompt   *Prompt
+	TranslatePrompt *Prompt
+	// Concurrency is the number of sections translated in parallel, one
+	// when it is zero.
+	Concurrency int
+}
+
+// translationLanguage returns the first of the translation languages, the
+// one the segmentation prompt mentions.
+func (o Options) translationLanguage() string {
+	if len(o.TranslationLanguages) == 0 {
+		return ""
+	}
+	return o.TranslationLanguages[0]
+}
+
+func (o Options) segmentPrompt() Prompt {
+	if o.SegmentPrompt != nil {
+		return *o.SegmentPrompt
+	}
+	return SegmentPrompt
+}
+
+func (o Options) translatePrompt() Prompt {
+	if o.TranslatePrompt != nil {
+		return *o.TranslatePrompt
+	}
+	return TranslatePrompt
+}
+
+// ResultItem is a section of the text with its translation, or its
+// translations by language when there are several translation languages.
+type ResultItem struct {
+	Source       string            `json:"source"`
+	Translation  string            `json:"translation,omitempty"`
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// Analyzer runs the pipeline against a Client.
+type Analyzer struct {
+	client Client
+	log    llm.Logger
+}
+
+// New returns an Analyzer sending its requests to client and its
+// diagnostics to log, which may be nil.
+func New(client Client, log llm.Logger) *Analyzer {
+	if log == nil {
+		log = nopLogger{}
+	}
+	return &Analyzer{client: client, log: log}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Verbosef(string, ...interface{}) {}
+func (nopLogger) Debugf(string, ...interface{})   {}
+
+// Pipeline holds the steps its callers add to the pipeline of Analyze, or
+// replace, for results of type R. Only Translate is required.
+type Pipeline[R any] struct {
+	// DetectLanguage returns the language of a text without a
+	// SourceLanguage, instead of asking the SegmentModel.
+	DetectLanguage func(ctx context.Context, text string) (string, error)
+	// Prepare is called once the language of the text is known, before it
+	// is segmented, and may change the options of the rest of the run, such
+	// as adding glossary terms.
+	Prepare func(ctx context.Context, text string, opts *Options) error
+	// Segment divides the text into sections instead of Analyzer.Segment.
+	Segment func(ctx context.Context, text string, opts Options) ([]string, error)
+	// Translate returns the result of the i-th section. The sections are
+	// translated Concurrency at a time.
+	Translate func(ctx context.Context, i int, section string, opts Options) (R, error)
+	// Done, when set, is called with every result in order, as soon as it
+	// and all of the preceding ones are ready.
+	Done func(i int, result R) error
+}
+
+// Analyze divides text into sections and translates every one of them into
+// the translation languages of opts, detecting the language of text first
+// when opts doesn't name it. It is the pipeline of the analise command
+// without its additional steps, such as the translation memory, the
+// verification of the translations and the enrichments of the sections,
+// which Run adds to it.
+func (a *Analyzer) Analyze(ctx context.Context, text string, opts Options) ([]ResultItem, error) {
+	return Run(ctx, a, text, opts, Pipeline[ResultItem]{
+		Translate: func(ctx context.Context, _ int, section string, opts Options) (ResultItem, error) {
+			return a.translateResult(ctx, section, opts)
+		},
+	})
+}
+
+// Run analyzes text like Analyze, with the steps of p.
+func Run[R any](ctx context.Context, a *Analyzer, text string, opts Options, p Pipeline[R]) ([]R, error) {
+	if len(opts.TranslationLanguages) == 0 {
+		return nil, errors.New("no translation language set")
+	}
+	if p.Translate == nil {
+		return nil, errors.New("no translation step set")
+	}
+	if opts.SourceLanguage == "" {
+		detect := func(ctx context.Context, text string) (string, error) {
+			detection, err := a.DetectLanguage(ctx, text, opts.SegmentModel)
+			return detection.Language, err
+		}
+		if p.DetectLanguage != nil {
+			detect = p.DetectLanguage
+		}
+		language, err := detect(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		opts.SourceLanguage = language
+		a.log.Verbosef("Detected source language: %s", language)
+	}
+	if p.Prepare != nil {
+		if err := p.Prepare(ctx, text, &opts); err != nil {
+			return nil, err
+		}
+	}
+
+	segment := a.Segment
+	if p.Segment != nil {
+		segment = p.Segment
+	}
+	sections, err := segment(ctx, text, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	translate := func(i int, section string) (R, error) {
+		return p.Translate(ctx, i, section, opts)
+	}
+	return RunOrdered(sections, opts.Concurrency, translate, p.Done)
+}
+
+// translateResult translates section into every translation language of
+// opts, in parallel.
+func (a *Analyzer) translateResult(ctx context.Context, section string, opts Options) (ResultItem, error) {
+	if len(opts.TranslationLanguages) == 1 {
+		translation, err := a.Translate(ctx, section, opts.SourceLanguage, opts.TranslationLanguages[0], GlossaryTermsIn(opts.Glossary, section), opts)
+		if err != nil {
+			return ResultItem{}, err
+		}
+		return ResultItem{Source: section, Translation: translation}, nil
+	}
+
+	languages := opts.TranslationLanguages
+	translate := func(_ int, language string) (string, error) {
+		return a.Translate(ctx, section, opts.SourceLanguage, language, nil, opts)
+	}
+	translations, err := RunOrdered(languages, len(languages), translate, nil)
+	if err != nil {
+		return ResultItem{}, err
+	}
+	byLanguage := make(map[string]string, len(languages))
+	for i, language := range languages {
+		byLanguage[language] = translations[i]
+	}
+	return ResultItem{Source: section, Translations: byLanguage}, nil
+}
+
+// Translate asks the TranslateModel to translate a single section from one
+// language into another. glossary holds the terms the translation must
+// respect.
+func (a *Analyzer) Translate(ctx context.Context, section, from, to string, glossary []GlossaryTerm, opts Options) (string, error) {
+	req, err := opts.translatePrompt().Render(opts.TranslateModel, PromptData{Text: section, Language: to, SourceLanguage: from, Glossary: glossary})
+	if err != nil {
+		return "", err
+	}
+
+	translation, err := a.client.Translate(ctx, llm.TranslateRequest{
+		Model:    req.Model,
+		Text:     section,
+		From:     from,
+		To:       to,
+		System:   req.System,
+		Examples: req.Examples,
+		Prompt:   req.Prompt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("translating %q: %w", section, err)
+	}
+
+	return translation, nil
+}
@@ -0,0 +1,279 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// recordingLogger keeps the verbose messages logged.
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Verbosef(format string, a ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, a...))
+}
+
+func (l *recordingLogger) Debugf(string, ...interface{}) {}
+
+// prefixing returns a translate function answering with the language and
+// the text of every request, recording them.
+func prefixing(requests *[]llm.TranslateRequest) func(llm.TranslateRequest) (string, error) {
+	return func(req llm.TranslateRequest) (string, error) {
+		*requests = append(*requests, req)
+		return req.To + ":" + req.Text, nil
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	var generated []llm.Request
+	answer := answering("de", `Sure: ["Der Hund bellt.", "Die Katze schläft."]`)
+	var translated []llm.TranslateRequest
+	client := &fakeClient{
+		generate: func(req llm.Request) (string, error) {
+			generated = append(generated, req)
+			return answer(req)
+		},
+		translate: prefixing(&translated),
+	}
+	log := &recordingLogger{}
+	results, err := New(client, log).Analyze(context.Background(), "Der Hund bellt. Die Katze schläft.", Options{
+		SegmentModel:         "segmenter",
+		TranslateModel:       "translator",
+		TranslationLanguages: []string{"en"},
+		Glossary:             []GlossaryTerm{{Source: "Katze", Target: "kitty"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResultItem{
+		{Source: "Der Hund bellt.", Translation: "en:Der Hund bellt."},
+		{Source: "Die Katze schläft.", Translation: "en:Die Katze schläft."},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+
+	// The language is detected, then the text segmented, by the
+	// SegmentModel.
+	if len(generated) != 2 || generated[0].Model != "segmenter" || generated[1].Model != "segmenter" || !strings.Contains(generated[1].Prompt, "Der Hund bellt. Die Katze schläft.") {
+		t.Errorf("generate requests = %+v", generated)
+	}
+	if !reflect.DeepEqual(log.messages, []string{"Detected source language: de"}) {
+		t.Errorf("logged %q", log.messages)
+	}
+	// Every section is translated from the detected language, with the
+	// glossary terms it has.
+	for i, req := range translated {
+		if req.Model != "translator" || req.From != "de" || req.To != "en" || strings.Contains(req.System, "kitty") != (i == 1) {
+			t.Errorf("translate request %d = %+v", i, req)
+		}
+	}
+}
+
+func TestAnalyzeSeveralLanguages(t *testing.T) {
+	var translated []llm.TranslateRequest
+	client := &fakeClient{generate: answering(`["Hund", "Katze"]`), translate: prefixing(&translated)}
+	results, err := New(client, nil).Analyze(context.Background(), "Hund Katze", Options{
+		SourceLanguage:       "de",
+		TranslationLanguages: []string{"en", "fr", "es"},
+		Glossary:             []GlossaryTerm{{Source: "Katze", Target: "kitty"}},
+		Concurrency:          2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResultItem{
+		{Source: "Hund", Translations: map[string]string{"en": "en:Hund", "fr": "fr:Hund", "es": "es:Hund"}},
+		{Source: "Katze", Translations: map[string]string{"en": "en:Katze", "fr": "fr:Katze", "es": "es:Katze"}},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	// Every section is translated into every language, without the glossary,
+	// and the language isn't detected when it is given.
+	if len(translated) != 6 {
+		t.Errorf("sent %d translate requests, want 6", len(translated))
+	}
+	for _, req := range translated {
+		if req.From != "de" || strings.Contains(req.System, "kitty") {
+			t.Errorf("translate request = %+v", req)
+		}
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	client := &fakeClient{generate: func(llm.Request) (string, error) {
+		return "", errors.New("unexpected generate request")
+	}}
+	var steps []string
+	var done []int
+	results, err := Run(context.Background(), New(client, nil), "a b c d", Options{TranslationLanguages: []string{"en"}, Concurrency: 4}, Pipeline[string]{
+		DetectLanguage: func(ctx context.Context, text string) (string, error) {
+			steps = append(steps, "detect "+text)
+			return "de", nil
+		},
+		Prepare: func(ctx context.Context, text string, opts *Options) error {
+			steps = append(steps, "prepare "+opts.SourceLanguage)
+			opts.TranslateModel = "prepared"
+			return nil
+		},
+		Segment: func(ctx context.Context, text string, opts Options) ([]string, error) {
+			steps = append(steps, "segment "+opts.SourceLanguage)
+			return strings.Fields(text), nil
+		},
+		Translate: func(ctx context.Context, i int, section string, opts Options) (string, error) {
+			return fmt.Sprintf("%d:%s:%s", i, section, opts.TranslateModel), nil
+		},
+		Done: func(i int, result string) error {
+			done = append(done, i)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"0:a:prepared", "1:b:prepared", "2:c:prepared", "3:d:prepared"}; !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %q, want %q", results, want)
+	}
+	if want := []string{"detect a b c d", "prepare de", "segment de"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %q, want %q", steps, want)
+	}
+	if !reflect.DeepEqual(done, []int{0, 1, 2, 3}) {
+		t.Errorf("done with %v, want every result in order", done)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	errUnavailable := errors.New("model unavailable")
+	translate := func(ctx context.Context, _ int, section string, _ Options) (string, error) {
+		return section, nil
+	}
+	unavailable := func(llm.Request) (string, error) {
+		return "", errUnavailable
+	}
+	for _, test := range []struct {
+		name     string
+		client   *fakeClient
+		opts     Options
+		pipeline Pipeline[string]
+		want     string
+	}{
+		{"no language", &fakeClient{}, Options{}, Pipeline[string]{Translate: translate}, "no translation language set"},
+		{"no translation step", &fakeClient{}, Options{TranslationLanguages: []string{"en"}}, Pipeline[string]{}, "no translation step set"},
+		{"detection", &fakeClient{generate: unavailable}, Options{TranslationLanguages: []string{"en"}}, Pipeline[string]{Translate: translate}, "detecting source language: model unavailable"},
+		{"segmentation", &fakeClient{generate: unavailable}, Options{SourceLanguage: "de", TranslationLanguages: []string{"en"}}, Pipeline[string]{Translate: translate}, "segmenting text: model unavailable"},
+		{"preparation", &fakeClient{}, Options{SourceLanguage: "de", TranslationLanguages: []string{"en"}}, Pipeline[string]{
+			Prepare:   func(context.Context, string, *Options) error { return errUnavailable },
+			Translate: translate,
+		}, "model unavailable"},
+		{"translation", &fakeClient{generate: answering(`["Hund", "Katze"]`)}, Options{SourceLanguage: "de", TranslationLanguages: []string{"en"}}, Pipeline[string]{
+			Translate: func(ctx context.Context, _ int, section string, _ Options) (string, error) {
+				if section == "Katze" {
+					return "", errUnavailable
+				}
+				return section, nil
+			},
+		}, "model unavailable"},
+	} {
+		results, err := Run(context.Background(), New(test.client, nil), "Hund Katze", test.opts, test.pipeline)
+		if err == nil || err.Error() != test.want || results != nil {
+			t.Errorf("%s: Run = %q, %v, want the error %q", test.name, results, err, test.want)
+		}
+		if strings.HasSuffix(test.want, errUnavailable.Error()) && !errors.Is(err, errUnavailable) {
+			t.Errorf("%s: %v doesn't wrap the error of its step", test.name, err)
+		}
+	}
+}
+
+func TestAnalyzeTranslationError(t *testing.T) {
+	unreachable := &llm.ConnectionError{Err: errors.New("connection refused")}
+	// The section fails in the last of the languages.
+	for _, languages := range [][]string{{"en"}, {"en", "fr"}} {
+		client := &fakeClient{
+			generate: answering(`["Hund", "Katze"]`),
+			translate: func(req llm.TranslateRequest) (string, error) {
+				if req.Text == "Katze" && req.To == languages[len(languages)-1] {
+					return "", unreachable
+				}
+				return "ok", nil
+			},
+		}
+		results, err := New(client, nil).Analyze(context.Background(), "Hund Katze", Options{SourceLanguage: "de", TranslationLanguages: languages})
+		var connErr *llm.ConnectionError
+		if !errors.As(err, &connErr) || !strings.HasPrefix(err.Error(), `translating "Katze": `) || results != nil {
+			t.Errorf("translating into %q: Analyze = %+v, %v, want the connection error of the section", languages, results, err)
+		}
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	// Every item waits for the next one, so that they finish in reverse.
+	items := []int{0, 1, 2, 3, 4}
+	finished := make([]chan struct{}, len(items))
+	for i := range finished {
+		finished[i] = make(chan struct{})
+	}
+	var done []int
+	results, err := RunOrdered(items, len(items), func(i int, item int) (string, error) {
+		if i+1 < len(items) {
+			<-finished[i+1]
+		}
+		close(finished[i])
+		return fmt.Sprint(item * item), nil
+	}, func(i int, result string) error {
+		done = append(done, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"0", "1", "4", "9", "16"}; !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %q, want %q", results, want)
+	}
+	if !reflect.DeepEqual(done, items) {
+		t.Errorf("done with %v, want every result in order", done)
+	}
+
+	for _, workers := range []int{-1, 0, 1, 10} {
+		results, err := RunOrdered(items, workers, func(i int, item int) (int, error) { return item + 1, nil }, nil)
+		if err != nil || !reflect.DeepEqual(results, []int{1, 2, 3, 4, 5}) {
+			t.Errorf("%d workers: RunOrdered = %v, %v", workers, results, err)
+		}
+	}
+	if results, err := RunOrdered(nil, 3, func(int, int) (int, error) { return 0, nil }, nil); err != nil || len(results) != 0 {
+		t.Errorf("no items: RunOrdered = %v, %v", results, err)
+	}
+}
+
+func TestRunOrderedErrors(t *testing.T) {
+	errFailed := errors.New("failed")
+	items := []string{"a", "b", "c", "d"}
+	results, err := RunOrdered(items, 2, func(i int, item string) (string, error) {
+		if item == "c" {
+			return "", errFailed
+		}
+		return item, nil
+	}, nil)
+	if err != errFailed || results != nil {
+		t.Errorf("RunOrdered = %q, %v, want the error of fn", results, err)
+	}
+
+	var done []int
+	results, err = RunOrdered(items, 1, func(i int, item string) (string, error) { return item, nil }, func(i int, result string) error {
+		done = append(done, i)
+		if i == 1 {
+			return errFailed
+		}
+		return nil
+	})
+	if err != errFailed || results != nil || !reflect.DeepEqual(done, []int{0, 1}) {
+		t.Errorf("RunOrdered = %q, %v after done with %v, want the error of done", results, err, done)
+	}
+}
@@ -0,0 +1,162 @@
+package analyze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// DecodeJSON decodes the first JSON array or object found in an LLM response
+// into v. Markdown code fences and surrounding prose are ignored, and
+// trailing commas, a common mistake of smaller models, are repaired.
+func DecodeJSON(response string, v interface{}) error {
+	candidate, err := extractJSON(StripCodeFences(response))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(candidate), v)
+}
+
+// GenerateJSON sends req to client and decodes the JSON the model answers
+// with into v. When the answer can't be parsed the request is sent once
+// more, pointing out what was wrong with the first answer, before giving up
+// with an *llm.ParseError.
+func GenerateJSON(ctx context.Context, client Generator, req llm.Request, v interface{}) error {
+	response, err := client.Generate(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	parseErr := DecodeJSON(response, v)
+	if parseErr == nil {
+		return nil
+	}
+
+	// Whatever the first answer decoded into v is dropped.
+	reset(v)
+	correction := req
+	correction.Prompt = fmt.Sprintf("%s\n\nYour previous answer could not be parsed (%v). Respond again with only valid JSON in the requested shape, without code fences or any other text.", req.Prompt, parseErr)
+	response, err = client.Generate(ctx, correction)
+	if err != nil {
+		return err
+	}
+	if err := DecodeJSON(response, v); err != nil {
+		return &llm.ParseError{Err: fmt.Errorf("parsing response JSON: %w", err)}
+	}
+	return nil
+}
+
+// reset sets the value v points to back to its zero value.
+func reset(v interface{}) {
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Pointer && !value.IsNil() {
+		value.Elem().Set(reflect.Zero(value.Elem().Type()))
+	}
+}
+
+// StripCodeFences returns the contents of the first ``` fenced block in s, or
+// s unchanged when it has none.
+func StripCodeFences(s string) string {
+	start := strings.Index(s, "```")
+	if start < 0 {
+		return s
+	}
+	body := s[start+3:]
+	// Skip the info string, e.g. ```json.
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// extractJSON returns the first balanced JSON array or object in s that is
+// valid once trailing commas are removed.
+func extractJSON(s string) (string, error) {
+	for start := 0; start < len(s); start++ {
+		if s[start] != '[' && s[start] != '{' {
+			continue
+		}
+		end := matchingBracket(s, start)
+		if end < 0 {
+			continue
+		}
+		candidate := removeTrailingCommas(s[start : end+1])
+		if json.Valid([]byte(candidate)) {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no valid JSON array or object found in response")
+}
+
+// matchingBracket returns the index of the bracket closing the one at start,
+// skipping over string literals, or -1 when it is never closed.
+func matchingBracket(s string, start int) int {
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// removeTrailingCommas drops commas directly followed, ignoring whitespace,
+// by a closing bracket outside of string literals.
+func removeTrailingCommas(s string) string {
+	var b strings.Builder
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			b.WriteByte(c)
+			continue
+		}
+		if c == '"' {
+			inString = true
+		}
+		if c == ',' {
+			rest := strings.TrimLeft(s[i+1:], " \t\r\n")
+			if strings.HasPrefix(rest, "]") || strings.HasPrefix(rest, "}") {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+func TestExtractJSON(t *testing.T) {
+	for _, test := range []struct {
+		response string
+		want     string
+	}{
+		{`["Hund", "Katze"]`, `["Hund", "Katze"]`},
+		{"Here are the sections:\n[\"Hund\"]\nHope this helps!", `["Hund"]`},
+		{`{"a": [1, 2,], "b": {"c": 3,},}`, `{"a": [1, 2], "b": {"c": 3}}`},
+		{`["a, ]", "b"]`, `["a, ]", "b"]`},
+		{`["say \"]\"", "b",]`, `["say \"]\"", "b"]`},
+		{`[see below] ["Hund"]`, `["Hund"]`},
+		{`{"unterminated": [1, 2} ["Hund"]`, `["Hund"]`},
+		{"no JSON here", ""},
+		{`["never closed"`, ""},
+	} {
+		got, err := extractJSON(test.response)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("extractJSON(%q) = %q, want an error", test.response, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("extractJSON(%q) = %q, %v, want %q", test.response, got, err, test.want)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var sections []string
+	if err := DecodeJSON("Sure!\n```json\n[\n  \"Hund\",\n  \"Katze\",\n]\n```\nAnything else?", &sections); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sections, []string{"Hund", "Katze"}) {
+		t.Errorf("sections = %q", sections)
+	}
+
+	var wrongShape []string
+	if err := DecodeJSON(`{"sections": ["Hund"]}`, &wrongShape); err == nil {
+		t.Error("an object was decoded into a slice")
+	}
+	if err := DecodeJSON("I cannot help with that.", &sections); err == nil {
+		t.Error("a response without JSON was decoded")
+	}
+}
+
+func TestStripCodeFences(t *testing.T) {
+	for response, want := range map[string]string{
+		"```json\n[1]\n```":            "[1]\n",
+		"before\n```\n[2]\n```\nafter": "[2]\n",
+		"```\n[3]":                     "[3]",
+		"[4]":                          "[4]",
+	} {
+		if got := StripCodeFences(response); got != want {
+			t.Errorf("StripCodeFences(%q) = %q, want %q", response, got, want)
+		}
+	}
+}
+
+// fakeGenerator answers the requests with responses in turn, recording
+// their prompts.
+type fakeGenerator struct {
+	responses []string
+	err       error
+	prompts   []string
+}
+
+func (g *fakeGenerator) Generate(ctx context.Context, req llm.Request) (string, error) {
+	g.prompts = append(g.prompts, req.Prompt)
+	if g.err != nil {
+		return "", g.err
+	}
+	response := g.responses[0]
+	g.responses = g.responses[1:]
+	return response, nil
+}
+
+func TestGenerateJSON(t *testing.T) {
+	type pair struct {
+		Source      string `json:"source"`
+		Translation string `json:"translation"`
+	}
+
+	// The first answer decodes partly before failing; none of it is kept.
+	generator := &fakeGenerator{responses: []string{`{"source": "Hund", "translation": 1}`, `{"translation": "dog"}`}}
+	var v pair
+	if err := GenerateJSON(context.Background(), generator, llm.Request{Prompt: "Translate Hund"}, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v != (pair{Translation: "dog"}) {
+		t.Errorf("v = %+v, want only the corrected answer", v)
+	}
+	if len(generator.prompts) != 2 || !strings.HasPrefix(generator.prompts[1], "Translate Hund\n\nYour previous answer could not be parsed") {
+		t.Errorf("prompts = %q, want a correction", generator.prompts)
+	}
+
+	generator = &fakeGenerator{responses: []string{`["Hund"]`}}
+	var sections []string
+	if err := GenerateJSON(context.Background(), generator, llm.Request{}, &sections); err != nil || len(generator.prompts) != 1 {
+		t.Errorf("a valid answer: err = %v after %d requests", err, len(generator.prompts))
+	}
+
+	generator = &fakeGenerator{responses: []string{"no", "still no"}}
+	err := GenerateJSON(context.Background(), generator, llm.Request{}, &sections)
+	var parseErr *llm.ParseError
+	if !errors.As(err, &parseErr) || len(generator.prompts) != 2 {
+		t.Errorf("two invalid answers: err = %v after %d requests, want an *llm.ParseError after 2", err, len(generator.prompts))
+	}
+
+	unreachable := &llm.ConnectionError{Err: errors.New("connection refused")}
+	generator = &fakeGenerator{err: unreachable}
+	if err := GenerateJSON(context.Background(), generator, llm.Request{}, &sections); err != unreachable || len(generator.prompts) != 1 {
+		t.Errorf("a failed request: err = %v after %d requests", err, len(generator.prompts))
+	}
+}
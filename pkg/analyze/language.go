@@ -0,0 +1,102 @@
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+const detectLanguagePrompt = "Identify the language of the text below. Respond with only its BCP 47 language tag, such as de-DE, pt-BR or ja-JP, followed by your confidence between 0 and 1, such as \"de-DE 0.95\", without any additional text or explanation.\n\nText:\n\n%s"
+
+// languageTagPattern matches a BCP 47 tag like "de", "de-DE" or "zh-Hant-TW".
+var languageTagPattern = regexp.MustCompile(`\b[A-Za-z]{2,3}(?:-[A-Za-z0-9]{2,8})*\b`)
+
+// confidencePattern matches a confidence between 0 and 1, such as 0.95.
+var confidencePattern = regexp.MustCompile(`\b(?:0(?:\.\d+)?|1(?:\.0+)?)\b`)
+
+// Detection is the language of a text along with the confidence of the
+// model, between 0 and 1, or zero when it didn't give one.
+type Detection struct {
+	Language   string
+	Confidence float64
+}
+
+// DetectionRequest returns the request asking model which language text is
+// written in.
+func DetectionRequest(model, text string) llm.Request {
+	return llm.Request{Model: model, Prompt: fmt.Sprintf(detectLanguagePrompt, text)}
+}
+
+// DetectLanguage asks model which language text is written in, and how
+// confident it is. An answer without a language tag is an *llm.ParseError.
+func (a *Analyzer) DetectLanguage(ctx context.Context, text, model string) (Detection, error) {
+	response, err := a.client.Generate(ctx, DetectionRequest(model, text))
+	if err != nil {
+		return Detection{}, fmt.Errorf("detecting source language: %w", err)
+	}
+
+	tag := extractLanguageTag(response)
+	if tag == "" {
+		return Detection{}, &llm.ParseError{Err: fmt.Errorf("detecting source language: no language tag in response %q", response)}
+	}
+	detection := Detection{Language: tag}
+	if match := confidencePattern.FindString(response); match != "" {
+		detection.Confidence, _ = strconv.ParseFloat(match, 64)
+	}
+	return detection, nil
+}
+
+// languageCodes holds the ISO 639-1 codes, the only two-letter tags taken
+// out of prose.
+var languageCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(`aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs ca ce ch co cr cs cu cv cy
+		da de dv dz ee el en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv ha he hi ho hr ht hu hy hz ia id ie ig ii ik io
+		is it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb lg li ln lo lt lu lv mg mh mi mk ml mn mr ms mt my na
+		nb nd ne ng nl nn no nr nv ny oc oj om or os pa pi pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so sq sr ss
+		st su sv sw ta te tg th ti tk tl tn to tr ts tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// proseWords are the ISO 639-1 codes that are also English words, such as
+// "is" or "it", which are only taken for tags when the answer is the tag.
+var proseWords = map[string]bool{
+	"am": true, "an": true, "as": true, "be": true, "he": true, "hi": true, "is": true,
+	"it": true, "my": true, "no": true, "or": true, "so": true, "to": true,
+}
+
+// extractLanguageTag picks the language tag out of a response that may
+// contain prose around it. The answer asked for, the tag alone or followed
+// by the confidence, is taken as it is. Out of prose, tags with a region are
+// preferred, then two-letter ones, and only those of a known ISO 639-1
+// language that isn't also an English word, since ordinary words match the
+// form of tags too.
+func extractLanguageTag(response string) string {
+	fields := strings.Fields(strings.Trim(strings.TrimSpace(response), ".\"'`"))
+	if len(fields) == 1 || len(fields) == 2 && confidencePattern.FindString(fields[1]) == fields[1] {
+		tag := strings.Trim(fields[0], ".,:;\"'`")
+		if tag != "" && languageTagPattern.FindString(tag) == tag {
+			return tag
+		}
+	}
+
+	candidates := languageTagPattern.FindAllString(response, -1)
+	for _, candidate := range candidates {
+		primary, _, region := strings.Cut(candidate, "-")
+		if region && languageCodes[strings.ToLower(primary)] {
+			return candidate
+		}
+	}
+	for _, candidate := range candidates {
+		if code := strings.ToLower(candidate); len(candidate) == 2 && languageCodes[code] && !proseWords[code] {
+			return candidate
+		}
+	}
+	return ""
+}
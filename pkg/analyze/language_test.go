@@ -0,0 +1,28 @@
+package analyze
+
+import "testing"
+
+func TestExtractLanguageTag(t *testing.T) {
+	for response, want := range map[string]string{
+		"de-DE 0.95":      "de-DE",
+		"de":              "de",
+		"`ja-JP`":         "ja-JP",
+		"it 0.8":          "it",
+		"no.":             "no",
+		"zh-Hant-TW, 0.9": "zh-Hant-TW",
+		"yue-HK 0.7":      "yue-HK",
+		"Language: pt-BR": "pt-BR",
+		"Language: fr":    "fr",
+		"The text is in German (de), confidence 0.9.": "de",
+		"I think it is English or so, en-GB 0.6":      "en-GB",
+		// Words of prose are not tags, even when they look like one.
+		"It is English": "",
+		"As in the other texts, this is Russian.": "",
+		"The text is a well-known poem":           "",
+		"":                                        "",
+	} {
+		if got := extractLanguageTag(response); got != want {
+			t.Errorf("extractLanguageTag(%q) = %q, want %q", response, got, want)
+		}
+	}
+}
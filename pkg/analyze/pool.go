@@ -0,0 +1,88 @@
+package analyze
+
+import "sync"
+
+// RunOrdered applies fn to every item using at most workers goroutines and
+// returns the results in the order of items. When done is not nil it is called
+// with each result in order, as soon as all of the preceding ones are ready.
+// Processing stops at the first error, which is returned.
+func RunOrdered[T, R any](items []T, workers int, fn func(int, T) (R, error), done func(int, R) error) ([]R, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	type outcome struct {
+		index  int
+		result R
+		err    error
+	}
+
+	jobs := make(chan int)
+	outcomes := make(chan outcome)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, err := fn(i, items[i])
+				outcomes <- outcome{index: i, result: result, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range items {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	results := make([]R, len(items))
+	ready := make([]bool, len(items))
+	next := 0
+	var firstErr error
+
+	for o := range outcomes {
+		if firstErr != nil {
+			continue
+		}
+		if o.err != nil {
+			firstErr = o.err
+			close(stop)
+			continue
+		}
+
+		results[o.index] = o.result
+		ready[o.index] = true
+		for next < len(items) && ready[next] {
+			if done != nil {
+				if err := done(next, results[next]); err != nil {
+					firstErr = err
+					close(stop)
+					break
+				}
+			}
+			next++
+		}
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
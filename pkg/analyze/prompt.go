@@ -0,0 +1,103 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// PromptData is the data the segmentation and translation prompts are
+// executed with.
+type PromptData struct {
+	// Text is the whole text when segmenting, or a single section when
+	// translating.
+	Text string
+	// Language is the translation language locale.
+	Language string
+	// SourceLanguage is the BCP 47 tag of the language the text is written
+	// in.
+	SourceLanguage string
+	// Glossary holds the required translations of the terms found in Text.
+	Glossary []GlossaryTerm
+	// Granularity, MinWords and MaxWords are the segmentation controls, zero
+	// when not set. Guidance describes them as a sentence for the prompt.
+	Granularity string
+	MinWords    int
+	MaxWords    int
+	Guidance    string
+}
+
+// GlossaryTerm is the translation a source term must consistently get.
+type GlossaryTerm struct {
+	Source string
+	Target string
+}
+
+// GlossaryTermsIn returns the glossary terms whose source appears in text.
+func GlossaryTermsIn(glossary []GlossaryTerm, text string) []GlossaryTerm {
+	lower := strings.ToLower(text)
+	var terms []GlossaryTerm
+	for _, term := range glossary {
+		if strings.Contains(lower, strings.ToLower(term.Source)) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Prompt renders the request of a pipeline stage. The built-in prompts keep
+// their instructions in System and their examples apart, so that chat APIs
+// get them as messages of their own; a prompt with only a Template renders
+// the whole request as the prompt.
+type Prompt struct {
+	System   *template.Template
+	Examples []llm.Example
+	Template *template.Template
+	// Schema is the JSON schema of the response, for the backends able to
+	// constrain their output to it.
+	Schema json.RawMessage
+}
+
+// GlossaryInstruction lists the required term translations of a
+// PromptData, when there are any, for the templates of the prompts.
+const GlossaryInstruction = "{{if .Glossary}}\n\nTranslate these terms exactly as follows:\n{{range .Glossary}}\n{{.Source}} => {{.Target}}{{end}}{{end}}"
+
+var (
+	// SegmentPrompt is the built-in prompt dividing a text into sections,
+	// answered with a JSON array of strings.
+	SegmentPrompt = Prompt{
+		System: template.Must(template.New("segment").Parse("Divide the given text into small sections, each representing a particular thought or idea. Use grammar as a basis and avoid creating a section with a single word. You can break a phrase into subject and predicate.{{with .Guidance}} {{.}}{{end}}\n\nProvide only the JSON array of sections as the output without any additional text or explanation.")),
+		Examples: []llm.Example{{
+			Input:  "Hey, kannst du mir den heutigen Mittagsmenü schicken? Ich bin gerade total eingebunden bei der Arbeit und schaffe es nicht reinzukommen.",
+			Output: "[\n    \"Hey\",\n    \"kannst du mir\",\n    \"den heutigen Mittagsmenü schicken?\",\n    \"Ich bin gerade\",\n    \"total eingebunden\",\n    \"bei der Arbeit\",\n    \"und\",\n    \"schaffe es nicht reinzukommen.\"\n]",
+		}},
+		Template: template.Must(template.New("segment text").Parse("{{.Text}}")),
+	}
+	// TranslatePrompt is the built-in prompt translating a section.
+	TranslatePrompt = Prompt{
+		System:   template.Must(template.New("translate").Parse("Translate the given text to {{.Language}}." + GlossaryInstruction + "\n\nProvide only the translation without any additional text or explanation.")),
+		Template: template.Must(template.New("translate text").Parse("{{.Text}}")),
+	}
+)
+
+// Render executes p with data, which is usually a PromptData, returning the
+// request for model.
+func (p Prompt) Render(model string, data interface{}) (llm.Request, error) {
+	req := llm.Request{Model: model, Examples: p.Examples, Schema: p.Schema}
+	var b strings.Builder
+	if p.System != nil {
+		if err := p.System.Execute(&b, data); err != nil {
+			return req, fmt.Errorf("rendering prompt template: %w", err)
+		}
+		req.System = b.String()
+		b.Reset()
+	}
+	if err := p.Template.Execute(&b, data); err != nil {
+		return req, fmt.Errorf("rendering prompt template: %w", err)
+	}
+	req.Prompt = b.String()
+	return req, nil
+}
@@ -0,0 +1,190 @@
+package analyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// Granularities maps each granularity of Options to the instruction added to
+// the segmentation prompt.
+var Granularities = map[string]string{
+	"phrase":    "Each section should be a short phrase of a few words.",
+	"clause":    "Each section should be a single clause built around its own verb.",
+	"sentence":  "Each section should be one complete sentence.",
+	"paragraph": "Each section should be a whole paragraph.",
+}
+
+// SegmentationGuidance describes the segmentation controls of opts for the
+// prompt, empty when none is set.
+func SegmentationGuidance(opts Options) string {
+	var parts []string
+	if instruction, ok := Granularities[opts.Granularity]; ok {
+		parts = append(parts, instruction)
+	}
+	switch {
+	case opts.MinSectionWords > 0 && opts.MaxSectionWords > 0:
+		parts = append(parts, fmt.Sprintf("Each section must have between %d and %d words.", opts.MinSectionWords, opts.MaxSectionWords))
+	case opts.MinSectionWords > 0:
+		parts = append(parts, fmt.Sprintf("Each section must have at least %d words.", opts.MinSectionWords))
+	case opts.MaxSectionWords > 0:
+		parts = append(parts, fmt.Sprintf("Each section must have at most %d words.", opts.MaxSectionWords))
+	}
+	return strings.Join(parts, " ")
+}
+
+// segmentPromptData returns the data of the segmentation prompt for text.
+func segmentPromptData(text string, opts Options) PromptData {
+	return PromptData{
+		Text:           text,
+		Language:       opts.translationLanguage(),
+		SourceLanguage: opts.SourceLanguage,
+		Granularity:    opts.Granularity,
+		MinWords:       opts.MinSectionWords,
+		MaxWords:       opts.MaxSectionWords,
+		Guidance:       SegmentationGuidance(opts),
+	}
+}
+
+// Segment asks the SegmentModel to divide text into small sections, each
+// representing a particular thought or idea, and then enforces the section
+// sizes of opts.
+func (a *Analyzer) Segment(ctx context.Context, text string, opts Options) ([]string, error) {
+	req, err := opts.segmentPrompt().Render(opts.SegmentModel, segmentPromptData(text, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []string
+	if err := GenerateJSON(ctx, a.client, req, &sections); err != nil {
+		return nil, fmt.Errorf("segmenting text: %w", err)
+	}
+
+	return a.enforceSectionSizes(ctx, sections, opts)
+}
+
+func wordCount(s string) int {
+	return len(strings.Fields(s))
+}
+
+// enforceSectionSizes re-splits sections longer than MaxSectionWords, first
+// by asking the model to segment them again and then, for whatever is still
+// too long, at punctuation or word boundaries. Sections shorter than
+// MinSectionWords are then merged into their neighbours.
+func (a *Analyzer) enforceSectionSizes(ctx context.Context, sections []string, opts Options) ([]string, error) {
+	if opts.MaxSectionWords > 0 {
+		var resized []string
+		for _, section := range sections {
+			if wordCount(section) <= opts.MaxSectionWords {
+				resized = append(resized, section)
+				continue
+			}
+
+			a.log.Verbosef("Section of %d words exceeds the maximum section size, splitting it again", wordCount(section))
+			parts, err := a.resegment(ctx, section, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range parts {
+				resized = append(resized, splitByWords(part, opts.MaxSectionWords)...)
+			}
+		}
+		sections = resized
+	}
+
+	if opts.MinSectionWords > 0 {
+		sections = mergeShortSections(sections, opts.MinSectionWords, opts.MaxSectionWords)
+	}
+	return sections, nil
+}
+
+// resegment asks the model to divide an overly long section. Answers that
+// don't cover the section, word for word and in order, or can't be parsed,
+// leave it whole for splitByWords to handle.
+func (a *Analyzer) resegment(ctx context.Context, section string, opts Options) ([]string, error) {
+	req, err := opts.segmentPrompt().Render(opts.SegmentModel, segmentPromptData(section, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	if err := GenerateJSON(ctx, a.client, req, &parts); err != nil {
+		var parseErr *llm.ParseError
+		if errors.As(err, &parseErr) {
+			return []string{section}, nil
+		}
+		return nil, fmt.Errorf("splitting long section: %w", err)
+	}
+	if len(parts) < 2 {
+		return []string{section}, nil
+	}
+	if !sameWords(strings.Join(parts, " "), section) {
+		a.log.Verbosef("The model changed the words of the long section when splitting it, splitting it at word boundaries instead")
+		return []string{section}, nil
+	}
+	return parts, nil
+}
+
+// sameWords reports whether a and b have the same words, whatever the
+// spacing between them.
+func sameWords(a, b string) bool {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+	if len(wordsA) != len(wordsB) {
+		return false
+	}
+	for i := range wordsA {
+		if wordsA[i] != wordsB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// splitByWords divides section into chunks of at most max words, preferring
+// to cut right after punctuation in the second half of each chunk.
+func splitByWords(section string, max int) []string {
+	words := strings.Fields(section)
+	var chunks []string
+	for len(words) > max {
+		cut := max
+		for i := max; i > max/2; i-- {
+			if strings.ContainsAny(words[i-1][len(words[i-1])-1:], ",;:.!?") {
+				cut = i
+				break
+			}
+		}
+		chunks = append(chunks, strings.Join(words[:cut], " "))
+		words = words[cut:]
+	}
+	if len(words) > 0 {
+		chunks = append(chunks, strings.Join(words, " "))
+	}
+	return chunks
+}
+
+// mergeShortSections joins sections with fewer than min words to the
+// following section, or to the previous one at the end of the text, as long
+// as the result doesn't exceed max words (when max is set).
+func mergeShortSections(sections []string, min, max int) []string {
+	fits := func(a, b string) bool {
+		return max <= 0 || wordCount(a)+wordCount(b) <= max
+	}
+
+	var merged []string
+	for i := 0; i < len(sections); i++ {
+		section := sections[i]
+		for wordCount(section) < min && i+1 < len(sections) && fits(section, sections[i+1]) {
+			i++
+			section += " " + sections[i]
+		}
+		if wordCount(section) < min && len(merged) > 0 && fits(merged[len(merged)-1], section) {
+			merged[len(merged)-1] += " " + section
+			continue
+		}
+		merged = append(merged, section)
+	}
+	return merged
+}
@@ -0,0 +1,99 @@
+package analyze
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/danielleitelima/starter-go-cli/pkg/llm"
+)
+
+// fakeClient is a Client answering with generate and translate, which are
+// called one request at a time.
+type fakeClient struct {
+	generate  func(req llm.Request) (string, error)
+	translate func(req llm.TranslateRequest) (string, error)
+
+	mu sync.Mutex
+}
+
+func (c *fakeClient) Generate(ctx context.Context, req llm.Request) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generate(req)
+}
+
+func (c *fakeClient) Translate(ctx context.Context, req llm.TranslateRequest) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.translate(req)
+}
+
+// answering returns a generate function answering with responses in turn.
+func answering(responses ...string) func(llm.Request) (string, error) {
+	return func(llm.Request) (string, error) {
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	}
+}
+
+func TestResegment(t *testing.T) {
+	section := "Der Hund bellt laut, und die Katze schläft."
+	for _, test := range []struct {
+		answer string
+		want   []string
+	}{
+		{`["Der Hund bellt laut,", "und die Katze schläft."]`, []string{"Der Hund bellt laut,", "und die Katze schläft."}},
+		{`["Der  Hund bellt laut, ", "und die Katze\nschläft."]`, []string{"Der  Hund bellt laut, ", "und die Katze\nschläft."}},
+		// Splits repeating, dropping or rewriting words leave it whole.
+		{`["Der Hund bellt laut,", "Der Hund bellt laut,", "und die Katze schläft."]`, []string{section}},
+		{`["Der Hund bellt laut,", "und die Katze"]`, []string{section}},
+		{`["Der Hund bellt,", "und die Katze schläft."]`, []string{section}},
+		{`["und die Katze schläft.", "Der Hund bellt laut,"]`, []string{section}},
+		{`["The dog barks loudly,", "and the cat sleeps."]`, []string{section}},
+		{`[]`, []string{section}},
+		{`["Der Hund bellt laut, und die Katze schläft."]`, []string{section}},
+	} {
+		a := New(&fakeClient{generate: answering(test.answer)}, nil)
+		parts, err := a.resegment(context.Background(), section, Options{MaxSectionWords: 4})
+		if err != nil || !reflect.DeepEqual(parts, test.want) {
+			t.Errorf("answering %s: resegment = %q, %v, want %q", test.answer, parts, err, test.want)
+		}
+	}
+}
+
+func TestSegmentMaxSectionWords(t *testing.T) {
+	// The model repeats the words of the long section when splitting it,
+	// which is split at word boundaries instead.
+	a := New(&fakeClient{generate: answering(
+		`["Der Hund bellt laut im Garten.", "Ja."]`,
+		`["Der Hund", "Der Hund", "bellt laut", "im Garten."]`,
+	)}, nil)
+	sections, err := a.Segment(context.Background(), "Der Hund bellt laut im Garten. Ja.", Options{MaxSectionWords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Der Hund", "bellt laut", "im Garten.", "Ja."}; !reflect.DeepEqual(sections, want) {
+		t.Errorf("sections = %q, want %q", sections, want)
+	}
+	if got := strings.Join(sections, " "); got != "Der Hund bellt laut im Garten. Ja." {
+		t.Errorf("the sections add up to %q", got)
+	}
+}
+
+func TestSplitByWords(t *testing.T) {
+	got := splitByWords("eins zwei drei, vier fünf sechs sieben", 4)
+	if want := []string{"eins zwei drei,", "vier fünf sechs sieben"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("splitByWords = %q, want %q", got, want)
+	}
+}
+
+func TestMergeShortSections(t *testing.T) {
+	got := mergeShortSections([]string{"Ja.", "Der Hund bellt.", "Die Katze schläft tief.", "Gut."}, 3, 4)
+	if want := []string{"Ja. Der Hund bellt.", "Die Katze schläft tief.", "Gut."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeShortSections = %q, want %q", got, want)
+	}
+}
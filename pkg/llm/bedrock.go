@@ -0,0 +1,258 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Bedrock is the provider for the models of AWS Bedrock, called through
+// InvokeModel. Anthropic Claude, Meta Llama and Amazon Titan models are
+// supported; each family has its own request and response schema.
+type Bedrock struct {
+	// BaseURL is the root of the runtime API. NewBedrock derives it from
+	// the region.
+	BaseURL string
+	// Sign adds the AWS Signature Version 4 authentication to a request.
+	Sign func(req *http.Request, body []byte) error
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewBedrock returns the provider for the Bedrock runtime of region.
+func NewBedrock(region string, sign func(*http.Request, []byte) error, log Logger) *Bedrock {
+	return &Bedrock{
+		BaseURL: fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region),
+		Sign:    sign,
+		Log:     log,
+	}
+}
+
+// bedrockMaxTokens bounds the responses of the models requiring a limit,
+// unless num_predict says otherwise.
+const bedrockMaxTokens = 4096
+
+// bedrockFamily returns the model family of a model ID, which may carry the
+// prefix of a cross-region inference profile such as "us.".
+func bedrockFamily(model string) string {
+	for _, family := range []string{"anthropic", "meta", "amazon"} {
+		if strings.HasPrefix(model, family+".") || strings.Contains(model, "."+family+".") {
+			return family
+		}
+	}
+	return ""
+}
+
+// bedrockOptions maps the generation options of the CLI, named after
+// Ollama's, to the parameters of each model family. Other options are
+// dropped.
+var bedrockOptions = map[string]map[string]string{
+	"anthropic": {"temperature": "temperature", "top_p": "top_p", "top_k": "top_k", "stop": "stop_sequences", "num_predict": "max_tokens", "max_tokens": "max_tokens"},
+	"meta":      {"temperature": "temperature", "top_p": "top_p", "num_predict": "max_gen_len", "max_tokens": "max_gen_len"},
+	"amazon":    {"temperature": "temperature", "top_p": "topP", "stop": "stopSequences", "num_predict": "maxTokenCount", "max_tokens": "maxTokenCount"},
+}
+
+func (b *Bedrock) invokeBody(req Request) (map[string]interface{}, error) {
+	family := bedrockFamily(req.Model)
+	params := map[string]interface{}{}
+	for name, value := range req.Options {
+		if param, ok := bedrockOptions[family][name]; ok {
+			params[param] = value
+		}
+	}
+
+	if len(req.Images) > 0 && (family == "meta" || family == "amazon") {
+		return nil, fmt.Errorf("Bedrock model %q doesn't take images (use an anthropic model)", req.Model)
+	}
+	switch family {
+	case "anthropic":
+		if _, ok := params["max_tokens"]; !ok {
+			params["max_tokens"] = bedrockMaxTokens
+		}
+		params["anthropic_version"] = "bedrock-2023-05-31"
+		if req.System != "" {
+			params["system"] = req.System
+		}
+		// Claude takes the system instructions apart from the turns.
+		messages := req.messages()
+		if req.System != "" {
+			messages = messages[1:]
+		}
+		if len(req.Images) > 0 {
+			content := []map[string]interface{}{}
+			for i, image := range req.encodedImages() {
+				content = append(content, map[string]interface{}{
+					"type":   "image",
+					"source": map[string]string{"type": "base64", "media_type": imageType(req.Images[i]), "data": image},
+				})
+			}
+			content = append(content, map[string]interface{}{"type": "text", "text": req.Prompt})
+			turns := make([]interface{}, 0, len(messages))
+			for _, message := range messages[:len(messages)-1] {
+				turns = append(turns, message)
+			}
+			params["messages"] = append(turns, map[string]interface{}{"role": "user", "content": content})
+			return params, nil
+		}
+		params["messages"] = messages
+		return params, nil
+	case "meta":
+		params["prompt"] = llama3Prompt(req)
+		return params, nil
+	case "amazon":
+		return map[string]interface{}{"inputText": req.Flatten(), "textGenerationConfig": params}, nil
+	}
+	return nil, fmt.Errorf("unsupported Bedrock model %q (expected an anthropic, meta or amazon model)", req.Model)
+}
+
+// llama3Prompt formats the conversation of req with the chat template of
+// Llama 3.
+func llama3Prompt(req Request) string {
+	var b strings.Builder
+	b.WriteString("<|begin_of_text|>")
+	for _, message := range req.messages() {
+		fmt.Fprintf(&b, "<|start_header_id|>%s<|end_header_id|>\n\n%s<|eot_id|>", message.Role, message.Content)
+	}
+	b.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+	return b.String()
+}
+
+func (b *Bedrock) endpoint(model string) string {
+	// The colon of versioned model IDs must be escaped for the signature to
+	// match the one computed by AWS.
+	return fmt.Sprintf("%s/model/%s/invoke", strings.TrimSuffix(b.BaseURL, "/"), strings.ReplaceAll(url.PathEscape(model), ":", "%3A"))
+}
+
+func (b *Bedrock) invoke(ctx context.Context, model string, payload interface{}) ([]byte, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return httpRequest{
+		client:   b.Client,
+		log:      b.Log,
+		endpoint: b.endpoint(model),
+		body:     payloadBytes,
+		failure:  bedrockStatusError,
+		sign:     b.Sign,
+	}.do(ctx)
+}
+
+// Preview returns the endpoint and body Generate would send for req.
+func (b *Bedrock) Preview(req Request) (string, []byte, error) {
+	payload, err := b.invokeBody(req)
+	if err != nil {
+		return "", nil, err
+	}
+	body, err := json.MarshalIndent(payload, "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return b.endpoint(req.Model), body, nil
+}
+
+// Generate invokes the model with the prompt and returns its completion.
+// Bedrock streams with its own binary event encoding, so a streamed request
+// gets the whole response written to Stream once it is complete.
+func (b *Bedrock) Generate(ctx context.Context, req Request) (string, error) {
+	payload, err := b.invokeBody(req)
+	if err != nil {
+		return "", err
+	}
+	body, err := b.invoke(ctx, req.Model, payload)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Generation string `json:"generation"`
+		Results    []struct {
+			OutputText string `json:"outputText"`
+			TokenCount int    `json:"tokenCount"`
+		} `json:"results"`
+		// The token counts are named after the family of the model.
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+		PromptTokenCount     int `json:"prompt_token_count"`
+		GenerationTokenCount int `json:"generation_token_count"`
+		InputTextTokenCount  int `json:"inputTextTokenCount"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+
+	var text strings.Builder
+	switch bedrockFamily(req.Model) {
+	case "anthropic":
+		for _, content := range response.Content {
+			if content.Type == "text" {
+				text.WriteString(content.Text)
+			}
+		}
+		req.record(response.Usage.InputTokens, response.Usage.OutputTokens)
+	case "meta":
+		text.WriteString(response.Generation)
+		req.record(response.PromptTokenCount, response.GenerationTokenCount)
+	case "amazon":
+		completionTokens := 0
+		for _, result := range response.Results {
+			text.WriteString(result.OutputText)
+			completionTokens += result.TokenCount
+		}
+		req.record(response.InputTextTokenCount, completionTokens)
+	}
+
+	if req.Stream != nil {
+		fmt.Fprintln(req.Stream, text.String())
+	}
+	return text.String(), nil
+}
+
+// Translate prompts the model for the translation.
+func (b *Bedrock) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	return b.Generate(ctx, req.generateRequest())
+}
+
+// Embed returns the embedding of text computed by an Amazon Titan
+// embeddings model, such as amazon.titan-embed-text-v2:0.
+func (b *Bedrock) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	body, err := b.invoke(ctx, model, map[string]string{"inputText": text})
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	return response.Embedding, nil
+}
+
+// bedrockStatusError describes a failed response with the exception type
+// and message Bedrock gives.
+func bedrockStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &errResponse) == nil {
+		statusErr.Message = errResponse.Message
+	}
+	if errorType, _, _ := strings.Cut(resp.Header.Get("x-amzn-ErrorType"), ":"); errorType != "" {
+		statusErr.Message = strings.TrimSpace(errorType + " " + statusErr.Message)
+	}
+	return statusErr
+}
@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DeepL translates with the DeepL API. It only implements Translator.
+type DeepL struct {
+	// BaseURL is the root of the API. Keys of free accounts, ending with
+	// ":fx", need https://api-free.deepl.com/v2 instead of the default
+	// https://api.deepl.com/v2.
+	BaseURL string
+	APIKey  string
+	// Formality is default, more, less, prefer_more or prefer_less; empty
+	// leaves it to DeepL.
+	Formality string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewDeepL returns the translator for apiKey, using the free API for free
+// account keys unless baseURL is set.
+func NewDeepL(baseURL, apiKey, formality string, log Logger) *DeepL {
+	if baseURL == "" {
+		baseURL = "https://api.deepl.com/v2"
+		if strings.HasSuffix(apiKey, ":fx") {
+			baseURL = "https://api-free.deepl.com/v2"
+		}
+	}
+	return &DeepL{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, Formality: formality, Log: log}
+}
+
+type deepLTranslateRequest struct {
+	Text       []string `json:"text"`
+	SourceLang string   `json:"source_lang,omitempty"`
+	TargetLang string   `json:"target_lang"`
+	Formality  string   `json:"formality,omitempty"`
+}
+
+type deepLTranslateResponse struct {
+	Translations []struct {
+		DetectedSourceLanguage string `json:"detected_source_language"`
+		Text                   string `json:"text"`
+	} `json:"translations"`
+}
+
+// deepLRegionalTargets are the target languages DeepL tells apart by region.
+var deepLRegionalTargets = map[string]bool{"EN": true, "PT": true}
+
+// deepLLanguage converts a language tag such as de-DE into a DeepL language
+// code. Source languages never carry a region, targets only for
+// deepLRegionalTargets.
+func deepLLanguage(tag string, target bool) string {
+	tag = strings.ToUpper(strings.ReplaceAll(tag, "_", "-"))
+	language, region, _ := strings.Cut(tag, "-")
+	if target && region != "" && deepLRegionalTargets[language] {
+		return language + "-" + region
+	}
+	return language
+}
+
+func (d *DeepL) translateRequest(req TranslateRequest) deepLTranslateRequest {
+	return deepLTranslateRequest{
+		Text:       []string{req.Text},
+		SourceLang: deepLLanguage(req.From, false),
+		TargetLang: deepLLanguage(req.To, true),
+		Formality:  d.Formality,
+	}
+}
+
+// PreviewTranslate returns the endpoint and body Translate would send for
+// req.
+func (d *DeepL) PreviewTranslate(req TranslateRequest) (string, []byte, error) {
+	body, err := json.MarshalIndent(d.translateRequest(req), "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return d.BaseURL + "/translate", body, nil
+}
+
+// Translate returns DeepL's translation of the request's text. The prompt,
+// model and options of the request are ignored.
+func (d *DeepL) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	payloadBytes, err := json.Marshal(d.translateRequest(req))
+	if err != nil {
+		return "", fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "DeepL-Auth-Key "+d.APIKey)
+	body, err := httpRequest{
+		client:   d.Client,
+		log:      d.Log,
+		endpoint: d.BaseURL + "/translate",
+		header:   header,
+		body:     payloadBytes,
+		failure:  deepLStatusError,
+	}.do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var response deepLTranslateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	if len(response.Translations) == 0 {
+		return "", &ParseError{fmt.Errorf("response has no translations")}
+	}
+	return response.Translations[0].Text, nil
+}
+
+// deepLStatusError describes a failed response with the message DeepL gives,
+// such as when the character quota is exceeded.
+func deepLStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &errResponse) == nil {
+		statusErr.Message = errResponse.Message
+	}
+	if resp.StatusCode == 456 && statusErr.Message == "" {
+		statusErr.Message = "quota exceeded"
+	}
+	return statusErr
+}
@@ -0,0 +1,353 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Gemini is the provider for the Google Gemini API.
+type Gemini struct {
+	// BaseURL is the root of the API, such as
+	// https://generativelanguage.googleapis.com/v1beta.
+	BaseURL string
+	APIKey  string
+	// SafetySettings maps harm categories, such as
+	// HARM_CATEGORY_HARASSMENT, to the threshold blocking them, such as
+	// BLOCK_NONE. They are sent with every request.
+	SafetySettings map[string]string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewGemini returns the provider for the API at baseURL, authenticated with
+// apiKey.
+func NewGemini(baseURL, apiKey string, safetySettings map[string]string, log Logger) *Gemini {
+	return &Gemini{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, SafetySettings: safetySettings, Log: log}
+}
+
+// geminiOptions maps the generation options of the CLI, named after Ollama's,
+// to the fields of the generation config. Other options are dropped.
+var geminiOptions = map[string]string{
+	"temperature": "temperature",
+	"top_p":       "topP",
+	"top_k":       "topK",
+	"seed":        "seed",
+	"stop":        "stopSequences",
+	"num_predict": "maxOutputTokens",
+	"max_tokens":  "maxOutputTokens",
+}
+
+type geminiPart struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *geminiBlob `json:"inline_data,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type geminiGenerateRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+	SafetySettings    []geminiSafetySetting  `json:"safetySettings,omitempty"`
+}
+
+type geminiGenerateResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	// UsageMetadata is in every chunk of a stream, with the counts so far.
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type geminiEmbedResponse struct {
+	Embedding struct {
+		Values []float64 `json:"values"`
+	} `json:"embedding"`
+}
+
+// googleErrorResponse is the body of the error responses of Google APIs.
+type googleErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type       string `json:"@type"`
+			RetryDelay string `json:"retryDelay"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (g *Gemini) endpoint(model, method string) string {
+	return fmt.Sprintf("%s/models/%s:%s", g.BaseURL, url.PathEscape(model), method)
+}
+
+func (g *Gemini) request(endpoint string, body []byte) httpRequest {
+	header := http.Header{}
+	if g.APIKey != "" {
+		header.Set("x-goog-api-key", g.APIKey)
+	}
+	return httpRequest{
+		client:   g.Client,
+		log:      g.Log,
+		endpoint: endpoint,
+		header:   header,
+		body:     body,
+		failure:  googleStatusError,
+	}
+}
+
+func (g *Gemini) generateRequest(req Request) geminiGenerateRequest {
+	var body geminiGenerateRequest
+	if req.System != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+	for _, example := range req.Examples {
+		body.Contents = append(body.Contents,
+			geminiContent{Role: "user", Parts: []geminiPart{{Text: example.Input}}},
+			geminiContent{Role: "model", Parts: []geminiPart{{Text: example.Output}}})
+	}
+	prompt := geminiContent{Role: "user", Parts: []geminiPart{{Text: req.Prompt}}}
+	for i, image := range req.encodedImages() {
+		prompt.Parts = append(prompt.Parts, geminiPart{InlineData: &geminiBlob{MimeType: imageType(req.Images[i]), Data: image}})
+	}
+	body.Contents = append(body.Contents, prompt)
+	for name, value := range req.Options {
+		if field, ok := geminiOptions[name]; ok {
+			if body.GenerationConfig == nil {
+				body.GenerationConfig = map[string]interface{}{}
+			}
+			body.GenerationConfig[field] = value
+		}
+	}
+	if req.Schema != nil {
+		if body.GenerationConfig == nil {
+			body.GenerationConfig = map[string]interface{}{}
+		}
+		body.GenerationConfig["responseMimeType"] = "application/json"
+		body.GenerationConfig["responseJsonSchema"] = req.Schema
+	}
+
+	categories := make([]string, 0, len(g.SafetySettings))
+	for category := range g.SafetySettings {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		body.SafetySettings = append(body.SafetySettings, geminiSafetySetting{Category: category, Threshold: g.SafetySettings[category]})
+	}
+	return body
+}
+
+func (g *Gemini) generateEndpoint(req Request) string {
+	if req.Stream != nil {
+		return g.endpoint(req.Model, "streamGenerateContent") + "?alt=sse"
+	}
+	return g.endpoint(req.Model, "generateContent")
+}
+
+// Preview returns the endpoint and body Generate would send for req.
+func (g *Gemini) Preview(req Request) (string, []byte, error) {
+	body, err := json.MarshalIndent(g.generateRequest(req), "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return g.generateEndpoint(req), body, nil
+}
+
+// Generate sends the prompt to the generateContent API and returns the text
+// of the first candidate.
+func (g *Gemini) Generate(ctx context.Context, req Request) (string, error) {
+	payloadBytes, err := json.Marshal(g.generateRequest(req))
+	if err != nil {
+		return "", fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	request := g.request(g.generateEndpoint(req), payloadBytes)
+	if req.Stream != nil {
+		request.stream = func(body io.Reader) (string, error) { return readGeminiStream(body, req) }
+	}
+	body, err := request.do(ctx)
+	if err != nil {
+		return "", err
+	}
+	if req.Stream != nil {
+		return string(body), nil
+	}
+
+	var response geminiGenerateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	req.record(response.UsageMetadata.PromptTokenCount, response.UsageMetadata.CandidatesTokenCount)
+	return response.text()
+}
+
+// Translate prompts the model for the translation.
+func (g *Gemini) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	return g.Generate(ctx, req.generateRequest())
+}
+
+// Embed returns the embedding of text computed by the embedContent API.
+func (g *Gemini) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	payloadBytes, err := json.Marshal(map[string]geminiContent{"content": {Parts: []geminiPart{{Text: text}}}})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	body, err := g.request(g.endpoint(model, "embedContent"), payloadBytes).do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response geminiEmbedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	return response.Embedding.Values, nil
+}
+
+// ListModels returns the models available to the API key, from the models
+// API.
+func (g *Gemini) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	pageToken := ""
+	for {
+		endpoint := g.BaseURL + "/models?pageSize=1000"
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		request := g.request(endpoint, nil)
+		request.method = http.MethodGet
+		body, err := request.do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+		}
+		for _, model := range response.Models {
+			models = append(models, ModelInfo{Name: strings.TrimPrefix(model.Name, "models/")})
+		}
+		if response.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+// text joins the text parts of the first candidate. A response without
+// text, because the prompt or the answer was blocked, is an error naming the
+// reason.
+func (r geminiGenerateResponse) text() (string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", &BlockedError{Provider: "Gemini", Prompt: true, Reason: r.PromptFeedback.BlockReason}
+	}
+	if len(r.Candidates) == 0 {
+		return "", &ParseError{fmt.Errorf("response has no candidates")}
+	}
+
+	candidate := r.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 && !geminiCompleted(candidate.FinishReason) {
+		return "", &BlockedError{Provider: "Gemini", Reason: candidate.FinishReason}
+	}
+	return text.String(), nil
+}
+
+// geminiCompleted reports whether a candidate finished for a reason other
+// than being blocked.
+func geminiCompleted(finishReason string) bool {
+	switch finishReason {
+	case "", "STOP", "MAX_TOKENS":
+		return true
+	}
+	return false
+}
+
+// googleStatusError describes a failed response of a Google API, with the
+// message of its error object and, for rate limiting, when to try again.
+func googleStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse googleErrorResponse
+	if json.Unmarshal(body, &errResponse) != nil {
+		return statusErr
+	}
+	statusErr.Message = errResponse.Error.Message
+	for _, detail := range errResponse.Error.Details {
+		if !strings.HasSuffix(detail.Type, "RetryInfo") {
+			continue
+		}
+		if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay > statusErr.RetryAfter {
+			statusErr.RetryAfter = delay
+		}
+	}
+	return statusErr
+}
+
+// readGeminiStream assembles a response streamed as server-sent events,
+// echoing every token to req.Stream as soon as it arrives.
+func readGeminiStream(body io.Reader, req Request) (string, error) {
+	var response strings.Builder
+	err := readEventStream(body, func(data []byte) error {
+		var chunk geminiGenerateResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return &ParseError{fmt.Errorf("parsing streamed response: %w", err)}
+		}
+		req.record(chunk.UsageMetadata.PromptTokenCount, chunk.UsageMetadata.CandidatesTokenCount)
+		if len(chunk.Candidates) == 0 && chunk.PromptFeedback.BlockReason == "" {
+			return nil
+		}
+		text, err := chunk.text()
+		if err != nil {
+			return err
+		}
+		response.WriteString(text)
+		fmt.Fprint(req.Stream, text)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(req.Stream)
+
+	return response.String(), nil
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeminiGenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-1.5-flash:generateContent" || r.Header.Get("x-goog-api-key") != "gemini-key" || r.URL.Query().Get("key") != "" {
+			t.Errorf("request to %s with headers %v", r.URL, r.Header)
+		}
+		var body geminiGenerateRequest
+		decodeBody(t, r, &body)
+		want := geminiGenerateRequest{
+			SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: "Be brief."}}},
+			Contents: []geminiContent{
+				{Role: "user", Parts: []geminiPart{{Text: "Hund"}}},
+				{Role: "model", Parts: []geminiPart{{Text: "dog"}}},
+				{Role: "user", Parts: []geminiPart{{Text: "Katze"}}},
+			},
+			GenerationConfig: map[string]interface{}{"temperature": 0.2, "maxOutputTokens": 100.0},
+			SafetySettings: []geminiSafetySetting{
+				{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_NONE"},
+				{Category: "HARM_CATEGORY_HATE_SPEECH", Threshold: "BLOCK_ONLY_HIGH"},
+			},
+		}
+		if !reflect.DeepEqual(body, want) {
+			t.Errorf("body = %+v, want %+v", body, want)
+		}
+		w.Write([]byte(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "ca"}, {"text": "t"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 8, "candidatesTokenCount": 1}}`))
+	}))
+	defer server.Close()
+
+	g := NewGemini(server.URL+"/v1beta", "gemini-key", map[string]string{"HARM_CATEGORY_HATE_SPEECH": "BLOCK_ONLY_HIGH", "HARM_CATEGORY_HARASSMENT": "BLOCK_NONE"}, nil)
+	var usage Usage
+	response, err := g.Generate(context.Background(), Request{
+		Model:    "gemini-1.5-flash",
+		System:   "Be brief.",
+		Examples: []Example{{Input: "Hund", Output: "dog"}},
+		Prompt:   "Katze",
+		Options:  map[string]interface{}{"temperature": 0.2, "num_predict": 100, "num_ctx": 8192},
+		Usage:    &usage,
+	})
+	if err != nil || response != "cat" || usage != (Usage{PromptTokens: 8, CompletionTokens: 1}) {
+		t.Errorf("Generate = %q, %v with usage %+v", response, err, usage)
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-1.5-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("request to %s", r.URL)
+		}
+		w.Write([]byte("data: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Der \"}]}}]}\n\ndata: {\"usageMetadata\": {\"promptTokenCount\": 4}}\n\ndata: {\"candidates\": [{\"content\": {\"parts\": [{\"text\": \"Hund\"}]}, \"finishReason\": \"STOP\"}]}\n\n"))
+	}))
+	defer server.Close()
+
+	var stream strings.Builder
+	response, err := NewGemini(server.URL, "gemini-key", nil, nil).Translate(context.Background(), TranslateRequest{Model: "gemini-1.5-flash", Text: "The dog", To: "de", Stream: &stream})
+	if err != nil || response != "Der Hund" || stream.String() != "Der Hund\n" {
+		t.Errorf("Translate = %q, %v, streamed %q", response, err, stream.String())
+	}
+}
+
+func TestGeminiErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/models/blocked-prompt:generateContent":
+			w.Write([]byte(`{"promptFeedback": {"blockReason": "SAFETY"}}`))
+		case "/models/blocked-response:generateContent":
+			w.Write([]byte(`{"candidates": [{"content": {"parts": []}, "finishReason": "RECITATION"}]}`))
+		case "/models/empty:generateContent":
+			w.Write([]byte(`{"candidates": []}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED", "details": [{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "7s"}]}}`))
+		}
+	}))
+	defer server.Close()
+	g := NewGemini(server.URL, "gemini-key", nil, nil)
+	ctx := context.Background()
+
+	var blocked *BlockedError
+	if _, err := g.Generate(ctx, Request{Model: "blocked-prompt"}); !errors.As(err, &blocked) || !blocked.Prompt || blocked.Reason != "SAFETY" {
+		t.Errorf("Generate = %v, want the prompt blocked", err)
+	}
+	if _, err := g.Generate(ctx, Request{Model: "blocked-response"}); !errors.As(err, &blocked) || blocked.Prompt || blocked.Reason != "RECITATION" {
+		t.Errorf("Generate = %v, want the response blocked", err)
+	}
+	var parseErr *ParseError
+	if _, err := g.Generate(ctx, Request{Model: "empty"}); !errors.As(err, &parseErr) {
+		t.Errorf("Generate = %v, want a *ParseError", err)
+	}
+	var statusErr *StatusError
+	if _, err := g.Generate(ctx, Request{Model: "gemini-1.5-pro"}); !errors.As(err, &statusErr) || *statusErr != (StatusError{Code: 429, Message: "Resource has been exhausted", RetryAfter: 7 * time.Second}) {
+		t.Errorf("Generate = %v, want the rate limit with its retry delay", err)
+	}
+}
+
+func TestGeminiEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]geminiContent
+		decodeBody(t, r, &body)
+		if r.URL.Path != "/models/text-embedding-004:embedContent" || body["content"].Parts[0].Text != "Hund" {
+			t.Errorf("request to %s with %+v", r.URL.Path, body)
+		}
+		w.Write([]byte(`{"embedding": {"values": [0.25, 0.5]}}`))
+	}))
+	defer server.Close()
+
+	embedding, err := NewGemini(server.URL, "gemini-key", nil, nil).Embed(context.Background(), "text-embedding-004", "Hund")
+	if err != nil || !reflect.DeepEqual(embedding, []float64{0.25, 0.5}) {
+		t.Errorf("Embed = %v, %v", embedding, err)
+	}
+}
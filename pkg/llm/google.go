@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GoogleTranslate translates with the Cloud Translation API v3. It only
+// implements Translator.
+type GoogleTranslate struct {
+	// BaseURL is the root of the API, https://translation.googleapis.com/v3
+	// unless set.
+	BaseURL  string
+	Project  string
+	Location string
+	// Glossary is the ID or the full resource name of a glossary the
+	// translations must follow; empty for none. Glossaries live in a
+	// regional Location, such as us-central1.
+	Glossary string
+	// Token returns the OAuth2 access token authenticating each request.
+	Token func(ctx context.Context) (string, error)
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewGoogleTranslate returns the translator for project in location, which
+// defaults to global.
+func NewGoogleTranslate(project, location, glossary string, token func(context.Context) (string, error), log Logger) *GoogleTranslate {
+	if location == "" {
+		location = "global"
+	}
+	return &GoogleTranslate{
+		BaseURL:  "https://translation.googleapis.com/v3",
+		Project:  project,
+		Location: location,
+		Glossary: glossary,
+		Token:    token,
+		Log:      log,
+	}
+}
+
+type googleTranslateRequest struct {
+	Contents           []string `json:"contents"`
+	MimeType           string   `json:"mimeType"`
+	SourceLanguageCode string   `json:"sourceLanguageCode,omitempty"`
+	TargetLanguageCode string   `json:"targetLanguageCode"`
+	GlossaryConfig     *struct {
+		Glossary string `json:"glossary"`
+	} `json:"glossaryConfig,omitempty"`
+}
+
+type googleTranslation struct {
+	TranslatedText string `json:"translatedText"`
+}
+
+type googleTranslateResponse struct {
+	Translations         []googleTranslation `json:"translations"`
+	GlossaryTranslations []googleTranslation `json:"glossaryTranslations"`
+}
+
+func (g *GoogleTranslate) parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", g.Project, g.Location)
+}
+
+func (g *GoogleTranslate) endpoint() string {
+	return strings.TrimSuffix(g.BaseURL, "/") + "/" + g.parent() + ":translateText"
+}
+
+// glossaryName returns the resource name of Glossary.
+func (g *GoogleTranslate) glossaryName() string {
+	if strings.HasPrefix(g.Glossary, "projects/") {
+		return g.Glossary
+	}
+	return g.parent() + "/glossaries/" + g.Glossary
+}
+
+func (g *GoogleTranslate) translateRequest(req TranslateRequest) googleTranslateRequest {
+	body := googleTranslateRequest{
+		Contents:           []string{req.Text},
+		MimeType:           "text/plain",
+		SourceLanguageCode: req.From,
+		TargetLanguageCode: req.To,
+	}
+	if g.Glossary != "" {
+		body.GlossaryConfig = &struct {
+			Glossary string `json:"glossary"`
+		}{g.glossaryName()}
+	}
+	return body
+}
+
+// PreviewTranslate returns the endpoint and body Translate would send for
+// req.
+func (g *GoogleTranslate) PreviewTranslate(req TranslateRequest) (string, []byte, error) {
+	body, err := json.MarshalIndent(g.translateRequest(req), "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return g.endpoint(), body, nil
+}
+
+// Translate returns Google's translation of the request's text, following
+// the glossary when one is set. The prompt, model and options of the request
+// are ignored.
+func (g *GoogleTranslate) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	payloadBytes, err := json.Marshal(g.translateRequest(req))
+	if err != nil {
+		return "", fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	token, err := g.Token(ctx)
+	if err != nil {
+		return "", &ConnectionError{fmt.Errorf("obtaining Google credentials: %w", err)}
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	header.Set("x-goog-user-project", g.Project)
+
+	body, err := httpRequest{
+		client:   g.Client,
+		log:      g.Log,
+		endpoint: g.endpoint(),
+		header:   header,
+		body:     payloadBytes,
+		failure:  googleStatusError,
+	}.do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var response googleTranslateResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	translations := response.Translations
+	if len(response.GlossaryTranslations) > 0 {
+		translations = response.GlossaryTranslations
+	}
+	if len(translations) == 0 {
+		return "", &ParseError{fmt.Errorf("response has no translations")}
+	}
+	return translations[0].TranslatedText, nil
+}
@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpRequest is a JSON request to a provider's API, with the parts that
+// differ between providers.
+type httpRequest struct {
+	client *http.Client
+	log    Logger
+	// method is POST when empty, which is how the requests with a body are
+	// sent.
+	method   string
+	endpoint string
+	header   http.Header
+	body     []byte
+	// contentType is the type of body, application/json when empty. Bodies
+	// of other types are not logged.
+	contentType string
+	// binary is set for responses that are not text, such as audio, which
+	// are not logged.
+	binary bool
+	// failure builds the error for a response with an unexpected status;
+	// nil reports the bare status.
+	failure func(resp *http.Response, body []byte) error
+	// stream, when set, reads a successful response as a stream and returns
+	// the assembled text in place of the body.
+	stream func(io.Reader) (string, error)
+	// sign, when set, authenticates the request once it is complete.
+	sign func(req *http.Request, body []byte) error
+}
+
+// do sends the request and returns the response body.
+func (r httpRequest) do(ctx context.Context) ([]byte, error) {
+	method := r.method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.endpoint, bytes.NewReader(r.body))
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP request: %w", err)
+	}
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	contentType := r.contentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if r.body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if r.sign != nil {
+		if err := r.sign(req, r.body); err != nil {
+			return nil, &ConnectionError{fmt.Errorf("signing HTTP request: %w", err)}
+		}
+	}
+
+	client := r.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	log := r.log
+	if log == nil {
+		log = nopLogger{}
+	}
+
+	host := method + " " + RedactURL(r.endpoint)
+	if r.body != nil && r.contentType == "" {
+		log.Debugf("%s request body: %s", host, r.body)
+	}
+	start := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Verbosef("%s failed after %s: %v", host, time.Since(start).Round(time.Millisecond), err)
+		return nil, &ConnectionError{fmt.Errorf("making HTTP request: %w", err)}
+	}
+	defer func() {
+		// Drain what is left of the body so the connection can be reused.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		log.Verbosef("%s -> %d in %s", host, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+		log.Debugf("%s response body: %s", host, body)
+		if r.failure != nil {
+			return nil, &ConnectionError{r.failure(resp, body)}
+		}
+		return nil, &ConnectionError{&StatusError{Code: resp.StatusCode}}
+	}
+
+	if r.stream != nil {
+		response, err := r.stream(resp.Body)
+		log.Verbosef("%s -> %d streamed in %s", host, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+		log.Debugf("%s streamed response: %q", host, response)
+		return []byte(response), err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ConnectionError{fmt.Errorf("reading response body: %w", err)}
+	}
+	log.Verbosef("%s -> %d in %s (%d bytes)", host, resp.StatusCode, time.Since(start).Round(time.Millisecond), len(body))
+	if !r.binary {
+		log.Debugf("%s response body: %s", host, body)
+	}
+	return body, nil
+}
+
+// retryAfter reads how long to wait before the next request from the
+// Retry-After header, in seconds or as an HTTP date, the retry-after-ms header
+// of OpenAI and Azure or, failing those, the rate limit reset headers: the
+// x-ratelimit-reset-requests and x-ratelimit-reset-tokens durations such as
+// "1s" or "6m0s", and x-ratelimit-reset in seconds or as a Unix time.
+func retryAfter(header http.Header) time.Duration {
+	if value := strings.TrimSpace(header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+			return time.Until(date).Round(time.Second)
+		}
+	}
+	if milliseconds, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && milliseconds > 0 {
+		return time.Duration(milliseconds * float64(time.Millisecond))
+	}
+
+	var wait time.Duration
+	for _, name := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if reset, err := time.ParseDuration(header.Get(name)); err == nil && reset > wait {
+			wait = reset
+		}
+	}
+	if reset, err := strconv.ParseFloat(header.Get("x-ratelimit-reset"), 64); err == nil && reset > 0 {
+		// Large values are timestamps rather than delays.
+		if reset > 1e9 {
+			reset = time.Until(time.Unix(int64(reset), 0)).Seconds()
+		}
+		if delay := time.Duration(reset * float64(time.Second)); delay > wait {
+			wait = delay
+		}
+	}
+	return wait
+}
+
+// readEventStream calls fn with the data of every server-sent event in body,
+// until the stream ends or sends [DONE].
+func readEventStream(body io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		event, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		event = strings.TrimSpace(event)
+		if event == "[DONE]" {
+			break
+		}
+		if err := fn([]byte(event)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &ConnectionError{fmt.Errorf("reading streamed response: %w", err)}
+	}
+	return nil
+}
+
+// sensitiveParams are query parameters holding credentials, which are never
+// logged.
+var sensitiveParams = []string{"key", "api_key", "apikey", "api-key", "token", "access_token", "secret", "password"}
+
+// RedactURL hides the credentials a URL may carry in its user info or query
+// string.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	query := u.Query()
+	redacted := false
+	for name := range query {
+		for _, sensitive := range sensitiveParams {
+			if strings.EqualFold(name, sensitive) {
+				query.Set(name, "REDACTED")
+				redacted = true
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LlamaCpp is the provider for the built-in server of llama.cpp. The server
+// runs a single model, so the model of the requests is ignored.
+type LlamaCpp struct {
+	// BaseURL is the root of the server, such as http://localhost:8080.
+	BaseURL string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewLlamaCpp returns the provider for the server at baseURL.
+func NewLlamaCpp(baseURL string, log Logger) *LlamaCpp {
+	return &LlamaCpp{BaseURL: strings.TrimSuffix(baseURL, "/"), Log: log}
+}
+
+// llamaCppOptions renames the generation options whose llama.cpp name
+// differs from Ollama's. The others are passed as they are.
+var llamaCppOptions = map[string]string{
+	"num_predict": "n_predict",
+	"max_tokens":  "n_predict",
+}
+
+type llamaCppCompletion struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+	// The token counts are set once Stop.
+	TokensEvaluated int `json:"tokens_evaluated"`
+	TokensPredicted int `json:"tokens_predicted"`
+}
+
+func (l *LlamaCpp) request(path string, body []byte) httpRequest {
+	return httpRequest{client: l.Client, log: l.Log, endpoint: l.BaseURL + path, body: body, failure: llamaCppStatusError}
+}
+
+func (l *LlamaCpp) completionRequest(req Request) map[string]interface{} {
+	body := map[string]interface{}{}
+	for name, value := range req.Options {
+		if renamed, ok := llamaCppOptions[name]; ok {
+			name = renamed
+		}
+		body[name] = value
+	}
+	body["prompt"] = req.Flatten()
+	body["stream"] = req.Stream != nil
+	if req.Schema != nil {
+		body["json_schema"] = req.Schema
+	}
+	return body
+}
+
+// Preview returns the endpoint and body Generate would send for req.
+func (l *LlamaCpp) Preview(req Request) (string, []byte, error) {
+	body, err := json.MarshalIndent(l.completionRequest(req), "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return l.BaseURL + "/completion", body, nil
+}
+
+// Generate sends the prompt to the /completion API and returns the
+// completion.
+func (l *LlamaCpp) Generate(ctx context.Context, req Request) (string, error) {
+	if len(req.Images) > 0 {
+		return "", errors.New("the llama.cpp backend doesn't take images")
+	}
+	payloadBytes, err := json.Marshal(l.completionRequest(req))
+	if err != nil {
+		return "", fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	request := l.request("/completion", payloadBytes)
+	if req.Stream != nil {
+		request.stream = func(body io.Reader) (string, error) { return readLlamaCppStream(body, req) }
+	}
+	body, err := request.do(ctx)
+	if err != nil {
+		return "", err
+	}
+	if req.Stream != nil {
+		return string(body), nil
+	}
+
+	var response llamaCppCompletion
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	req.record(response.TokensEvaluated, response.TokensPredicted)
+	return response.Content, nil
+}
+
+// Translate prompts the model for the translation.
+func (l *LlamaCpp) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	return l.Generate(ctx, req.generateRequest())
+}
+
+// Embed returns the embedding of text computed by the /embedding API, which
+// needs the server to run with --embedding.
+func (l *LlamaCpp) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	payloadBytes, err := json.Marshal(map[string]string{"content": text})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	body, err := l.request("/embedding", payloadBytes).do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	return response.Embedding, nil
+}
+
+// ListModels returns the model loaded by the server, from its
+// OpenAI-compatible models API.
+func (l *LlamaCpp) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	request := l.request("/v1/models", nil)
+	request.method = http.MethodGet
+	body, err := request.do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseOpenAIModels(body)
+}
+
+// llamaCppStatusError describes a failed response with the message of the
+// server's error object.
+func llamaCppStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errResponse) == nil {
+		statusErr.Message = errResponse.Error.Message
+	}
+	return statusErr
+}
+
+// readLlamaCppStream assembles a response streamed as server-sent events,
+// echoing every token to live as soon as it arrives.
+func readLlamaCppStream(body io.Reader, req Request) (string, error) {
+	var response strings.Builder
+	err := readEventStream(body, func(data []byte) error {
+		var chunk llamaCppCompletion
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return &ParseError{fmt.Errorf("parsing streamed response: %w", err)}
+		}
+		response.WriteString(chunk.Content)
+		fmt.Fprint(req.Stream, chunk.Content)
+		if chunk.Stop {
+			req.record(chunk.TokensEvaluated, chunk.TokensPredicted)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(req.Stream)
+
+	return response.String(), nil
+}
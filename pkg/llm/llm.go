@@ -0,0 +1,296 @@
+// Package llm talks to the language models and translation services used by
+// the CLI. Every backend implements Provider, so the commands only decide
+// what to ask and never how it travels over the wire.
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Request asks a model to complete a prompt.
+type Request struct {
+	Model string
+	// System holds the instructions, sent apart from the prompt by the
+	// backends with chat APIs.
+	System string
+	// Examples are earlier exchanges showing the expected answers.
+	Examples []Example
+	Prompt   string
+	// Images are pictures the prompt is about, such as photos of a text to
+	// read, for vision models. The backends without vision reject them.
+	Images [][]byte
+	// Options are backend-specific generation parameters, such as
+	// temperature or num_ctx for Ollama.
+	Options map[string]interface{}
+	// Schema, when set, is the JSON schema of the expected response. The
+	// backends able to constrain their output to a schema do; the others
+	// only go by the prompt.
+	Schema json.RawMessage
+	// Stream, when set, receives the response tokens as soon as they arrive.
+	Stream io.Writer
+	// Usage, when set, receives the number of tokens the host counted for
+	// the request. It is left untouched by the hosts that don't count them.
+	Usage *Usage
+}
+
+// Usage is the number of tokens of a request and of its response.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// record stores the token counts reported by the host in r.Usage, when set.
+func (r Request) record(promptTokens, completionTokens int) {
+	if r.Usage != nil && (promptTokens > 0 || completionTokens > 0) {
+		*r.Usage = Usage{PromptTokens: promptTokens, CompletionTokens: completionTokens}
+	}
+}
+
+// Example is an input with the answer expected for it.
+type Example struct {
+	Input  string
+	Output string
+}
+
+// Flatten returns the request as a single prompt, for the backends without
+// chat APIs: the system instructions, the examples and the prompt, in that
+// order.
+func (r Request) Flatten() string {
+	var b strings.Builder
+	if r.System != "" {
+		b.WriteString(r.System)
+		b.WriteString("\n\n")
+	}
+	for _, example := range r.Examples {
+		fmt.Fprintf(&b, "Example input:\n\n%s\n\nExample output:\n\n%s\n\n", example.Input, example.Output)
+	}
+	if len(r.Examples) > 0 {
+		b.WriteString("Actual input:\n\n")
+	}
+	b.WriteString(r.Prompt)
+	return b.String()
+}
+
+// chatMessage is a turn of a conversation in the chat APIs of Ollama and
+// OpenAI.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images are the base64-encoded pictures of the turn, which only Ollama
+	// takes in this form.
+	Images []string `json:"images,omitempty"`
+}
+
+// messages returns the request as a conversation: the system instructions,
+// the examples as earlier user and assistant turns, and the prompt.
+func (r Request) messages() []chatMessage {
+	var messages []chatMessage
+	if r.System != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: r.System})
+	}
+	for _, example := range r.Examples {
+		messages = append(messages, chatMessage{Role: "user", Content: example.Input}, chatMessage{Role: "assistant", Content: example.Output})
+	}
+	return append(messages, chatMessage{Role: "user", Content: r.Prompt})
+}
+
+// encodedImages returns the Images of the request encoded in base64.
+func (r Request) encodedImages() []string {
+	var images []string
+	for _, image := range r.Images {
+		images = append(images, base64.StdEncoding.EncodeToString(image))
+	}
+	return images
+}
+
+// imageType returns the media type of image, such as image/jpeg.
+func imageType(image []byte) string {
+	return http.DetectContentType(image)
+}
+
+// TranslateRequest asks for Text to be translated from one language into
+// another.
+type TranslateRequest struct {
+	Model string
+	Text  string
+	From  string
+	To    string
+	// System, Examples and Prompt are the request sent to LLM backends,
+	// when the caller renders its own. Without a prompt they use a
+	// built-in one.
+	System   string
+	Examples []Example
+	Prompt   string
+	Options  map[string]interface{}
+	Stream   io.Writer
+	Usage    *Usage
+}
+
+// Translator translates texts. Every Provider is one, and dedicated
+// translation services implement it alone.
+type Translator interface {
+	// Translate returns the translation of the request's text.
+	Translate(ctx context.Context, req TranslateRequest) (string, error)
+}
+
+// Provider is a backend able to run the requests of the CLI.
+type Provider interface {
+	Translator
+	// Generate returns the model's response to the request's prompt.
+	Generate(ctx context.Context, req Request) (string, error)
+	// Embed returns the embedding vector of text.
+	Embed(ctx context.Context, model, text string) ([]float64, error)
+}
+
+// Previewer is implemented by the providers able to show the HTTP request
+// Generate would send, for --dry-run.
+type Previewer interface {
+	Preview(req Request) (endpoint string, body []byte, err error)
+}
+
+// TranslatePreviewer is implemented by the translators able to show the HTTP
+// request Translate would send, for --dry-run.
+type TranslatePreviewer interface {
+	PreviewTranslate(req TranslateRequest) (endpoint string, body []byte, err error)
+}
+
+// ModelLister is implemented by the providers able to list the models
+// available on their host.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// ModelInfo describes a model available on a host. Size and Modified are
+// zero when the host doesn't report them.
+type ModelInfo struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// Puller is implemented by the providers able to download a missing model,
+// calling progress as the download advances.
+type Puller interface {
+	Pull(ctx context.Context, model string, progress func(PullProgress)) error
+}
+
+// PullProgress is a step of a model download. Total and Completed count the
+// bytes of the layer being downloaded, and are zero for the other steps.
+type PullProgress struct {
+	Status    string
+	Total     int64
+	Completed int64
+}
+
+// Warmer is implemented by the providers able to load a model ahead of the
+// first request.
+type Warmer interface {
+	Warm(ctx context.Context, model string) error
+}
+
+// Logger receives the diagnostics of the providers: Verbosef describes every
+// request with its latency and status, Debugf adds the bodies.
+type Logger interface {
+	Verbosef(format string, a ...interface{})
+	Debugf(format string, a ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Verbosef(string, ...interface{}) {}
+func (nopLogger) Debugf(string, ...interface{})   {}
+
+// StatusError is returned when a provider answers with an unexpected HTTP
+// status.
+type StatusError struct {
+	Code int
+	// Message is the explanation given by the provider, when it has one.
+	Message string
+	// RetryAfter is how long the provider asked to wait before trying
+	// again, zero when it didn't say.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("received status code %d", e.Code)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %s)", e.RetryAfter)
+	}
+	return msg
+}
+
+// BlockedError reports a prompt or response withheld by the safety filters
+// of a provider. Retrying would be blocked again.
+type BlockedError struct {
+	Provider string
+	// Prompt is true when the prompt was blocked, false for the response.
+	Prompt bool
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	what := "response"
+	if e.Prompt {
+		what = "prompt"
+	}
+	return fmt.Sprintf("%s blocked the %s: %s", e.Provider, what, e.Reason)
+}
+
+// ModelNotFoundError reports that the model of a request isn't available on
+// the host, which a Puller may be able to fix.
+type ModelNotFoundError struct {
+	Model string
+	Err   error
+}
+
+func (e *ModelNotFoundError) Error() string { return e.Err.Error() }
+func (e *ModelNotFoundError) Unwrap() error { return e.Err }
+
+// ConnectionError reports that a provider could not be reached or answered
+// with an error.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string { return e.Err.Error() }
+func (e *ConnectionError) Unwrap() error { return e.Err }
+
+// ParseError reports a provider response that could not be decoded.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// generateRequest returns the request LLM backends send for a translation,
+// with a built-in prompt when req doesn't bring its own.
+func (req TranslateRequest) generateRequest() Request {
+	r := Request{
+		Model:    req.Model,
+		System:   req.System,
+		Examples: req.Examples,
+		Prompt:   req.Prompt,
+		Options:  req.Options,
+		Stream:   req.Stream,
+		Usage:    req.Usage,
+	}
+	if r.Prompt == "" {
+		from := ""
+		if req.From != "" {
+			from = " from " + req.From
+		}
+		r.Prompt = fmt.Sprintf("Translate the following text%s to %s:\n\n%s\n\nProvide only the translation without any additional text or explanation.", from, req.To, req.Text)
+	}
+	return r
+}
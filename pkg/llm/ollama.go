@@ -0,0 +1,348 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Ollama is the provider for an Ollama host.
+type Ollama struct {
+	// Endpoint is the URL of the generate API, such as
+	// http://localhost:11434/api/generate.
+	Endpoint string
+	// Chat sends the requests to the chat API next to Endpoint, with the
+	// system instructions and examples as their own messages. Hosts too old
+	// to have it get the flattened request sent to Endpoint instead.
+	Chat bool
+	// KeepAlive is how long the host keeps the model loaded after a
+	// request, as a duration such as "10m"; empty means the default of the
+	// host, and a negative duration keeps it loaded until the host stops.
+	KeepAlive string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+
+	// noChat is set once the host turned out not to have the chat API.
+	noChat atomic.Bool
+}
+
+// NewOllama returns the provider for the generate API at endpoint, using the
+// chat API instead when chat is set.
+func NewOllama(endpoint string, chat bool, log Logger) *Ollama {
+	return &Ollama{Endpoint: endpoint, Chat: chat, Log: log}
+}
+
+type ollamaGenerateRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	Images    []string               `json:"images,omitempty"`
+	Stream    bool                   `json:"stream"`
+	Format    json.RawMessage        `json:"format,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []chatMessage          `json:"messages"`
+	Stream    bool                   `json:"stream"`
+	Format    json.RawMessage        `json:"format,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+// ollamaResponse is the response of the generate and chat APIs, and every
+// line of their streamed responses.
+type ollamaResponse struct {
+	// Response is set by the generate API.
+	Response string `json:"response"`
+	// Message is set by the chat API.
+	Message chatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error"`
+	// The token counts are set once Done.
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (r ollamaResponse) text() string {
+	return r.Response + r.Message.Content
+}
+
+type ollamaPullRequest struct {
+	Name   string `json:"name"`
+	Stream bool   `json:"stream"`
+}
+
+// ollamaPullResponse is a line of the streamed response of the pull API.
+type ollamaPullResponse struct {
+	Status    string `json:"status"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+type ollamaEmbedRequest struct {
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+type ollamaEmbedResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+func (o *Ollama) log() Logger {
+	if o.Log != nil {
+		return o.Log
+	}
+	return nopLogger{}
+}
+
+func (o *Ollama) request(endpoint string, body []byte) httpRequest {
+	return httpRequest{client: o.Client, log: o.Log, endpoint: endpoint, body: body, failure: ollamaStatusError}
+}
+
+// apiEndpoint derives the URL of another API of the host from Endpoint.
+func (o *Ollama) apiEndpoint(name string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(o.Endpoint, "/api/generate"), "/api/chat") + "/api/" + name
+}
+
+func (o *Ollama) useChat() bool {
+	return o.Chat && !o.noChat.Load()
+}
+
+// payload returns the endpoint and body of req, for the chat API when it is
+// used.
+func (o *Ollama) payload(req Request) (string, interface{}) {
+	if o.useChat() {
+		messages := req.messages()
+		messages[len(messages)-1].Images = req.encodedImages()
+		return o.apiEndpoint("chat"), ollamaChatRequest{
+			Model:     req.Model,
+			Messages:  messages,
+			Stream:    req.Stream != nil,
+			Format:    req.Schema,
+			Options:   req.Options,
+			KeepAlive: o.KeepAlive,
+		}
+	}
+	return o.Endpoint, ollamaGenerateRequest{
+		Model:     req.Model,
+		Prompt:    req.Flatten(),
+		Images:    req.encodedImages(),
+		Stream:    req.Stream != nil,
+		Format:    req.Schema,
+		Options:   req.Options,
+		KeepAlive: o.KeepAlive,
+	}
+}
+
+// Preview returns the endpoint and body Generate would send for req.
+func (o *Ollama) Preview(req Request) (string, []byte, error) {
+	endpoint, payload := o.payload(req)
+	body, err := json.MarshalIndent(payload, "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return endpoint, body, nil
+}
+
+// Generate sends the request to the chat or generate API and returns the
+// response.
+func (o *Ollama) Generate(ctx context.Context, req Request) (string, error) {
+	chat := o.useChat()
+	endpoint, payload := o.payload(req)
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	request := o.request(endpoint, payloadBytes)
+	if req.Stream != nil {
+		request.stream = func(body io.Reader) (string, error) { return readOllamaStream(body, req) }
+	}
+	body, err := request.do(ctx)
+	if chat && isMissingEndpoint(err) {
+		o.log().Verbosef("%s has no chat API, falling back to %s", RedactURL(endpoint), RedactURL(o.Endpoint))
+		o.noChat.Store(true)
+		return o.Generate(ctx, req)
+	}
+	if err != nil {
+		return "", modelError(req.Model, err)
+	}
+	if req.Stream != nil {
+		return string(body), nil
+	}
+
+	var responsePayload ollamaResponse
+	if err := json.Unmarshal(body, &responsePayload); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	req.record(responsePayload.PromptEvalCount, responsePayload.EvalCount)
+	return responsePayload.text(), nil
+}
+
+// Warm loads model into memory with a generate request without a prompt, so
+// that the first real request doesn't wait for it.
+func (o *Ollama) Warm(ctx context.Context, model string) error {
+	payloadBytes, err := json.Marshal(ollamaGenerateRequest{Model: model, KeepAlive: o.KeepAlive})
+	if err != nil {
+		return fmt.Errorf("marshalling request payload: %w", err)
+	}
+	if _, err := o.request(o.apiEndpoint("generate"), payloadBytes).do(ctx); err != nil {
+		return modelError(model, err)
+	}
+	return nil
+}
+
+// Translate prompts the model for the translation.
+func (o *Ollama) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	return o.Generate(ctx, req.generateRequest())
+}
+
+// Embed returns the embedding of text computed by the embeddings API.
+func (o *Ollama) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	payloadBytes, err := json.Marshal(ollamaEmbedRequest{Model: model, Prompt: text, KeepAlive: o.KeepAlive})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	body, err := o.request(o.apiEndpoint("embeddings"), payloadBytes).do(ctx)
+	if err != nil {
+		return nil, modelError(model, err)
+	}
+
+	var responsePayload ollamaEmbedResponse
+	if err := json.Unmarshal(body, &responsePayload); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	return responsePayload.Embedding, nil
+}
+
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name       string    `json:"name"`
+		Size       int64     `json:"size"`
+		ModifiedAt time.Time `json:"modified_at"`
+	} `json:"models"`
+}
+
+// ListModels returns the models installed on the host, from the tags API.
+func (o *Ollama) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	request := o.request(o.apiEndpoint("tags"), nil)
+	request.method = http.MethodGet
+	body, err := request.do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ollamaTagsResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	models := make([]ModelInfo, 0, len(response.Models))
+	for _, model := range response.Models {
+		models = append(models, ModelInfo{Name: model.Name, Size: model.Size, Modified: model.ModifiedAt})
+	}
+	return models, nil
+}
+
+// Pull downloads model with the pull API, reporting every step of the
+// download to progress.
+func (o *Ollama) Pull(ctx context.Context, model string, progress func(PullProgress)) error {
+	payloadBytes, err := json.Marshal(ollamaPullRequest{Name: model, Stream: true})
+	if err != nil {
+		return fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	request := o.request(o.apiEndpoint("pull"), payloadBytes)
+	request.stream = func(body io.Reader) (string, error) {
+		decoder := json.NewDecoder(body)
+		for {
+			var chunk ollamaPullResponse
+			if err := decoder.Decode(&chunk); err != nil {
+				if err == io.EOF {
+					return "", &ConnectionError{errors.New("pull ended before completing")}
+				}
+				return "", &ParseError{fmt.Errorf("parsing streamed response: %w", err)}
+			}
+			if chunk.Error != "" {
+				return "", fmt.Errorf("pulling %s: %s", model, chunk.Error)
+			}
+			progress(PullProgress{Status: chunk.Status, Total: chunk.Total, Completed: chunk.Completed})
+			if chunk.Status == "success" {
+				return "", nil
+			}
+		}
+	}
+	_, err = request.do(ctx)
+	return err
+}
+
+// modelError reports Ollama's 404 for a missing model as a
+// ModelNotFoundError, leaving other errors as they are.
+func modelError(model string, err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound && strings.Contains(statusErr.Message, "not found") {
+		return &ModelNotFoundError{Model: model, Err: err}
+	}
+	return err
+}
+
+// ollamaStatusError describes a failed response with the error Ollama gives,
+// such as a missing model.
+func ollamaStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &errResponse) == nil {
+		statusErr.Message = errResponse.Error
+	}
+	return statusErr
+}
+
+// isMissingEndpoint reports whether err is the plain 404 of an API the host
+// doesn't have, rather than Ollama's own 404 for a missing model.
+func isMissingEndpoint(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound && statusErr.Message == ""
+}
+
+// readOllamaStream assembles a streamed response, echoing every token to
+// req.Stream as soon as it arrives.
+func readOllamaStream(body io.Reader, req Request) (string, error) {
+	var response strings.Builder
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk ollamaResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", &ParseError{fmt.Errorf("parsing streamed response: %w", err)}
+		}
+		if chunk.Error != "" {
+			return "", &ConnectionError{fmt.Errorf("streamed response: %s", chunk.Error)}
+		}
+
+		response.WriteString(chunk.text())
+		fmt.Fprint(req.Stream, chunk.text())
+		if chunk.Done {
+			req.record(chunk.PromptEvalCount, chunk.EvalCount)
+			break
+		}
+	}
+	fmt.Fprintln(req.Stream)
+
+	return response.String(), nil
+}
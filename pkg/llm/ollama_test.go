@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOllamaChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request to %s %s", r.Method, r.URL.Path)
+		}
+		var body ollamaChatRequest
+		decodeBody(t, r, &body)
+		want := ollamaChatRequest{
+			Model: "llama3",
+			Messages: []chatMessage{
+				{Role: "system", Content: "Be brief."},
+				{Role: "user", Content: "Hund"},
+				{Role: "assistant", Content: "dog"},
+				{Role: "user", Content: "Katze", Images: []string{"aW1n"}},
+			},
+			Format:    []byte(`{"type":"string"}`),
+			Options:   map[string]interface{}{"temperature": 0.2},
+			KeepAlive: "10m",
+		}
+		if !reflect.DeepEqual(body, want) {
+			t.Errorf("body = %+v, want %+v", body, want)
+		}
+		w.Write([]byte(`{"message": {"role": "assistant", "content": "cat"}, "done": true, "prompt_eval_count": 12, "eval_count": 2}`))
+	}))
+	defer server.Close()
+
+	o := NewOllama(server.URL+"/api/generate", true, nil)
+	o.KeepAlive = "10m"
+	var usage Usage
+	response, err := o.Generate(context.Background(), Request{
+		Model:    "llama3",
+		System:   "Be brief.",
+		Examples: []Example{{Input: "Hund", Output: "dog"}},
+		Prompt:   "Katze",
+		Images:   [][]byte{[]byte("img")},
+		Options:  map[string]interface{}{"temperature": 0.2},
+		Schema:   []byte(`{"type":"string"}`),
+		Usage:    &usage,
+	})
+	if err != nil || response != "cat" {
+		t.Fatalf("Generate = %q, %v", response, err)
+	}
+	if usage != (Usage{PromptTokens: 12, CompletionTokens: 2}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestOllamaWithoutChat(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var body ollamaGenerateRequest
+		decodeBody(t, r, &body)
+		if body.Prompt != "Be brief.\n\nKatze" || body.Stream {
+			t.Errorf("body = %+v, want the flattened request", body)
+		}
+		w.Write([]byte(`{"response": "cat", "done": true}`))
+	}))
+	defer server.Close()
+
+	// Hosts without the chat API get the generate API from then on.
+	o := NewOllama(server.URL+"/api/generate", true, nil)
+	for i := 0; i < 2; i++ {
+		if response, err := o.Generate(context.Background(), Request{Model: "llama3", System: "Be brief.", Prompt: "Katze"}); err != nil || response != "cat" {
+			t.Fatalf("Generate = %q, %v", response, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(paths, " "); got != "/api/chat /api/generate /api/generate" {
+		t.Errorf("requests to %s", got)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body ollamaGenerateRequest
+		decodeBody(t, r, &body)
+		if !body.Stream {
+			t.Error("the request isn't streamed")
+		}
+		w.Write([]byte("{\"response\": \"Der \"}\n{\"response\": \"Hund\"}\n{\"response\": \"\", \"done\": true, \"prompt_eval_count\": 5, \"eval_count\": 2}\n"))
+	}))
+	defer server.Close()
+
+	var stream strings.Builder
+	var usage Usage
+	response, err := NewOllama(server.URL+"/api/generate", false, nil).Translate(context.Background(), TranslateRequest{Model: "llama3", Text: "The dog", To: "de", Stream: &stream, Usage: &usage})
+	if err != nil || response != "Der Hund" || stream.String() != "Der Hund\n" {
+		t.Fatalf("Translate = %q, %v, streamed %q", response, err, stream.String())
+	}
+	if usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestOllamaErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/generate":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "model \"llama9\" not found, try pulling it first"}`))
+		case "/api/embeddings":
+			w.Write([]byte(`{"embedding": [0.5, -1]`))
+		default:
+			w.Header().Set("Retry-After", "4")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	o := NewOllama(server.URL+"/api/generate", false, nil)
+	ctx := context.Background()
+
+	_, err := o.Generate(ctx, Request{Model: "llama9"})
+	var notFound *ModelNotFoundError
+	var statusErr *StatusError
+	if !errors.As(err, &notFound) || notFound.Model != "llama9" || !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound {
+		t.Errorf("Generate = %v, want a missing model", err)
+	}
+
+	var parseErr *ParseError
+	if _, err := o.Embed(ctx, "nomic-embed-text", "Hund"); !errors.As(err, &parseErr) {
+		t.Errorf("Embed = %v, want a *ParseError", err)
+	}
+
+	err = o.Pull(ctx, "llama3", func(PullProgress) {})
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) || !errors.As(err, &statusErr) || statusErr.Code != http.StatusServiceUnavailable || statusErr.RetryAfter.Seconds() != 4 {
+		t.Errorf("Pull = %v, want the status with its retry delay", err)
+	}
+
+	server.Close()
+	_, err = o.Generate(ctx, Request{Model: "llama3"})
+	if !errors.As(err, &connErr) || errors.As(err, &statusErr) {
+		t.Errorf("Generate on a closed host = %v, want a *ConnectionError without a status", err)
+	}
+}
+
+func TestOllamaEmbedAndModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/embeddings":
+			var body ollamaEmbedRequest
+			decodeBody(t, r, &body)
+			if body.Model != "nomic-embed-text" || body.Prompt != "Hund" {
+				t.Errorf("body = %+v", body)
+			}
+			w.Write([]byte(`{"embedding": [0.5, -1]}`))
+		case "/api/tags":
+			if r.Method != http.MethodGet {
+				t.Errorf("tags requested with %s", r.Method)
+			}
+			w.Write([]byte(`{"models": [{"name": "llama3:latest", "size": 4661224676, "modified_at": "2024-05-01T10:00:00Z"}]}`))
+		}
+	}))
+	defer server.Close()
+	o := NewOllama(server.URL+"/api/chat", true, nil)
+
+	embedding, err := o.Embed(context.Background(), "nomic-embed-text", "Hund")
+	if err != nil || !reflect.DeepEqual(embedding, []float64{0.5, -1}) {
+		t.Errorf("Embed = %v, %v", embedding, err)
+	}
+	models, err := o.ListModels(context.Background())
+	if err != nil || len(models) != 1 || models[0].Name != "llama3:latest" || models[0].Size != 4661224676 || models[0].Modified.Year() != 2024 {
+		t.Errorf("ListModels = %+v, %v", models, err)
+	}
+}
@@ -0,0 +1,356 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenAI is the provider for the OpenAI API, Azure OpenAI and the servers
+// mimicking its chat completions endpoint.
+type OpenAI struct {
+	// BaseURL is the root of the API, such as https://api.openai.com/v1.
+	BaseURL string
+	APIKey  string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+
+	// azure routes the requests to Azure OpenAI deployments when set.
+	azure *azureTarget
+	// compatible passes the options OpenAI doesn't know to the server, for
+	// servers extending the API such as vLLM and LocalAI.
+	compatible bool
+
+	// ignored remembers the options already reported as unsupported.
+	ignored sync.Map
+}
+
+// azureTarget holds how Azure OpenAI addresses a model: through the
+// deployment serving it and an API version.
+type azureTarget struct {
+	apiVersion string
+	// deployments maps model names to deployment names. Models missing
+	// from it go to deployment or, without one, to the deployment named
+	// after them.
+	deployments map[string]string
+	deployment  string
+}
+
+// NewOpenAI returns the provider for the API at baseURL, authenticated with
+// apiKey.
+func NewOpenAI(baseURL, apiKey string, log Logger) *OpenAI {
+	return &OpenAI{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, Log: log}
+}
+
+// NewOpenAICompatible returns the provider for a server implementing the
+// OpenAI API at baseURL, such as LM Studio, vLLM, LocalAI or Groq. apiKey may
+// be empty for servers that don't check it.
+func NewOpenAICompatible(baseURL, apiKey string, log Logger) *OpenAI {
+	o := NewOpenAI(baseURL, apiKey, log)
+	o.compatible = true
+	return o
+}
+
+// NewAzureOpenAI returns the provider for the Azure OpenAI resource at
+// endpoint, such as https://example.openai.azure.com. Each request goes to
+// the deployment that deployments maps its model to, then to deployment
+// and finally to the deployment named after the model.
+func NewAzureOpenAI(endpoint, apiVersion, deployment string, deployments map[string]string, apiKey string, log Logger) *OpenAI {
+	return &OpenAI{
+		BaseURL: strings.TrimSuffix(endpoint, "/") + "/openai/deployments",
+		APIKey:  apiKey,
+		Log:     log,
+		azure:   &azureTarget{apiVersion: apiVersion, deployments: deployments, deployment: deployment},
+	}
+}
+
+// openAIOptions maps the generation options of the CLI, named after Ollama's,
+// to the chat completions parameters. Options missing here are not
+// supported by the API and are dropped.
+var openAIOptions = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"seed":              "seed",
+	"stop":              "stop",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"num_predict":       "max_tokens",
+	"max_tokens":        "max_tokens",
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+		Delta   chatMessage `json:"delta"`
+	} `json:"choices"`
+	// Usage is only in the last chunk of a stream, and only when asked
+	// for with stream_options.
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// record stores the usage of r in req, when it has one.
+func (r openAIChatResponse) record(req Request) {
+	if r.Usage != nil {
+		req.record(r.Usage.PromptTokens, r.Usage.CompletionTokens)
+	}
+}
+
+type openAIEmbedResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// openAIErrorResponse is the body of the API's error responses.
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+func (o *OpenAI) log() Logger {
+	if o.Log != nil {
+		return o.Log
+	}
+	return nopLogger{}
+}
+
+// endpoint returns the URL of the API at path for model.
+func (o *OpenAI) endpoint(model, path string) string {
+	if o.azure == nil {
+		return o.BaseURL + path
+	}
+	deployment := model
+	if name, ok := o.azure.deployments[model]; ok {
+		deployment = name
+	} else if o.azure.deployment != "" {
+		deployment = o.azure.deployment
+	}
+	return fmt.Sprintf("%s/%s%s?api-version=%s", o.BaseURL, url.PathEscape(deployment), path, url.QueryEscape(o.azure.apiVersion))
+}
+
+func (o *OpenAI) request(model, path string, body []byte) httpRequest {
+	header := http.Header{}
+	switch {
+	case o.APIKey == "":
+	case o.azure != nil:
+		header.Set("api-key", o.APIKey)
+	default:
+		header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+	return httpRequest{
+		client:   o.Client,
+		log:      o.Log,
+		endpoint: o.endpoint(model, path),
+		header:   header,
+		body:     body,
+		failure:  openAIStatusError,
+	}
+}
+
+func (o *OpenAI) chatRequest(req Request) map[string]interface{} {
+	body := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.messages(),
+	}
+	if len(req.Images) > 0 {
+		body["messages"] = openAIVisionMessages(req)
+	}
+	if req.Stream != nil {
+		body["stream"] = true
+		// Streamed usage is recent and OpenAI's own, which other servers may
+		// reject.
+		if !o.compatible && o.azure == nil {
+			body["stream_options"] = map[string]bool{"include_usage": true}
+		}
+	}
+	if req.Schema != nil {
+		body["response_format"] = map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": map[string]interface{}{"name": "response", "schema": req.Schema},
+		}
+	}
+	for name, value := range req.Options {
+		if param, ok := openAIOptions[name]; ok {
+			body[param] = value
+		} else if o.compatible {
+			body[name] = value
+		} else if _, seen := o.ignored.LoadOrStore(name, true); !seen {
+			o.log().Verbosef("Ignoring option %s, which the OpenAI API doesn't support", name)
+		}
+	}
+	return body
+}
+
+// openAIVisionMessages returns the conversation of req with the images sent
+// as data URLs in the parts of the last user message.
+func openAIVisionMessages(req Request) []interface{} {
+	var messages []interface{}
+	turns := req.messages()
+	for _, message := range turns[:len(turns)-1] {
+		messages = append(messages, message)
+	}
+	parts := []map[string]interface{}{{"type": "text", "text": req.Prompt}}
+	for i, image := range req.encodedImages() {
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]string{"url": "data:" + imageType(req.Images[i]) + ";base64," + image},
+		})
+	}
+	return append(messages, map[string]interface{}{"role": "user", "content": parts})
+}
+
+// Preview returns the endpoint and body Generate would send for req.
+func (o *OpenAI) Preview(req Request) (string, []byte, error) {
+	body, err := json.MarshalIndent(o.chatRequest(req), "", "    ")
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return o.endpoint(req.Model, "/chat/completions"), body, nil
+}
+
+// Generate sends the prompt as a user message to the chat completions API
+// and returns the reply.
+func (o *OpenAI) Generate(ctx context.Context, req Request) (string, error) {
+	payloadBytes, err := json.Marshal(o.chatRequest(req))
+	if err != nil {
+		return "", fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	request := o.request(req.Model, "/chat/completions", payloadBytes)
+	if req.Stream != nil {
+		request.stream = func(body io.Reader) (string, error) { return readOpenAIStream(body, req) }
+	}
+	body, err := request.do(ctx)
+	if err != nil {
+		return "", err
+	}
+	if req.Stream != nil {
+		return string(body), nil
+	}
+
+	var response openAIChatResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	if len(response.Choices) == 0 {
+		return "", &ParseError{fmt.Errorf("response has no choices")}
+	}
+	response.record(req)
+	return response.Choices[0].Message.Content, nil
+}
+
+// Translate prompts the model for the translation.
+func (o *OpenAI) Translate(ctx context.Context, req TranslateRequest) (string, error) {
+	return o.Generate(ctx, req.generateRequest())
+}
+
+// Embed returns the embedding of text computed by the embeddings API.
+func (o *OpenAI) Embed(ctx context.Context, model, text string) ([]float64, error) {
+	payloadBytes, err := json.Marshal(map[string]string{"model": model, "input": text})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+
+	body, err := o.request(model, "/embeddings", payloadBytes).do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var response openAIEmbedResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	if len(response.Data) == 0 {
+		return nil, &ParseError{fmt.Errorf("response has no embedding")}
+	}
+	return response.Data[0].Embedding, nil
+}
+
+// ListModels returns the models available to the API key, from the models
+// API. Azure OpenAI only serves the deployments of the resource, which the
+// API doesn't list.
+func (o *OpenAI) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if o.azure != nil {
+		return nil, errors.New("Azure OpenAI cannot list the deployments of a resource")
+	}
+	request := o.request("", "/models", nil)
+	request.method = http.MethodGet
+	body, err := request.do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseOpenAIModels(body)
+}
+
+// parseOpenAIModels decodes the response of an OpenAI-style models API.
+func parseOpenAIModels(body []byte) ([]ModelInfo, error) {
+	var response struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	models := make([]ModelInfo, 0, len(response.Data))
+	for _, model := range response.Data {
+		info := ModelInfo{Name: model.ID}
+		if model.Created > 0 {
+			info.Modified = time.Unix(model.Created, 0)
+		}
+		models = append(models, info)
+	}
+	return models, nil
+}
+
+// openAIStatusError describes a failed response, with the message of the
+// API's error object and, for rate limiting, when to try again.
+func openAIStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse openAIErrorResponse
+	if json.Unmarshal(body, &errResponse) == nil && errResponse.Error.Message != "" {
+		statusErr.Message = errResponse.Error.Message
+	}
+	return statusErr
+}
+
+// readOpenAIStream assembles a response streamed as server-sent events,
+// echoing every token to req.Stream as soon as it arrives.
+func readOpenAIStream(body io.Reader, req Request) (string, error) {
+	var response strings.Builder
+	err := readEventStream(body, func(data []byte) error {
+		var chunk openAIChatResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return &ParseError{fmt.Errorf("parsing streamed response: %w", err)}
+		}
+		if len(chunk.Choices) > 0 {
+			token := chunk.Choices[0].Delta.Content
+			response.WriteString(token)
+			fmt.Fprint(req.Stream, token)
+		}
+		chunk.record(req)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(req.Stream)
+
+	return response.String(), nil
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAIGenerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("request to %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body map[string]interface{}
+		decodeBody(t, r, &body)
+		want := map[string]interface{}{
+			"model": "gpt-4o-mini",
+			"messages": []interface{}{
+				map[string]interface{}{"role": "system", "content": "Be brief."},
+				map[string]interface{}{"role": "user", "content": "Katze"},
+			},
+			"temperature": 0.2,
+			"max_tokens":  100.0,
+		}
+		if !reflect.DeepEqual(body, want) {
+			t.Errorf("body = %v, want %v", body, want)
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "cat"}}], "usage": {"prompt_tokens": 9, "completion_tokens": 1}}`))
+	}))
+	defer server.Close()
+
+	// Options the API doesn't have, such as num_ctx, are dropped.
+	var usage Usage
+	response, err := NewOpenAI(server.URL+"/v1/", "sk-test", nil).Generate(context.Background(), Request{
+		Model:   "gpt-4o-mini",
+		System:  "Be brief.",
+		Prompt:  "Katze",
+		Options: map[string]interface{}{"temperature": 0.2, "num_predict": 100, "num_ctx": 8192},
+		Usage:   &usage,
+	})
+	if err != nil || response != "cat" || usage != (Usage{PromptTokens: 9, CompletionTokens: 1}) {
+		t.Errorf("Generate = %q, %v with usage %+v", response, err, usage)
+	}
+}
+
+func TestOpenAICompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("sent Authorization %q without an API key", r.Header.Get("Authorization"))
+		}
+		var body map[string]interface{}
+		decodeBody(t, r, &body)
+		if body["num_ctx"] != 8192.0 || body["stream"] != true || body["stream_options"] != nil {
+			t.Errorf("body = %v, want the option passed and a plain stream", body)
+		}
+		w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"Der \"}}]}\n\ndata: {\"choices\": [{\"delta\": {\"content\": \"Hund\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	var stream strings.Builder
+	response, err := NewOpenAICompatible(server.URL, "", nil).Translate(context.Background(), TranslateRequest{
+		Model:   "local",
+		Text:    "The dog",
+		To:      "de",
+		Options: map[string]interface{}{"num_ctx": 8192},
+		Stream:  &stream,
+	})
+	if err != nil || response != "Der Hund" || stream.String() != "Der Hund\n" {
+		t.Errorf("Translate = %q, %v, streamed %q", response, err, stream.String())
+	}
+}
+
+func TestAzureOpenAI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/translator/chat/completions" || r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("request to %s", r.URL)
+		}
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("request headers %v", r.Header)
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "cat"}}]}`))
+	}))
+	defer server.Close()
+
+	o := NewAzureOpenAI(server.URL+"/", "2024-06-01", "default", map[string]string{"gpt-4o": "translator"}, "azure-key", nil)
+	if response, err := o.Generate(context.Background(), Request{Model: "gpt-4o", Prompt: "Katze"}); err != nil || response != "cat" {
+		t.Errorf("Generate = %q, %v", response, err)
+	}
+	if got := o.endpoint("gpt-4o-mini", "/embeddings"); got != server.URL+"/openai/deployments/default/embeddings?api-version=2024-06-01" {
+		t.Errorf("endpoint = %s, want the default deployment", got)
+	}
+	if _, err := o.ListModels(context.Background()); err == nil {
+		t.Error("listed the deployments of Azure OpenAI")
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat/completions":
+			w.Header().Set("retry-after-ms", "1500")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"}}`))
+		case "/embeddings":
+			w.Write([]byte(`{"data": []}`))
+		}
+	}))
+	defer server.Close()
+	o := NewOpenAI(server.URL, "sk-test", nil)
+
+	_, err := o.Generate(context.Background(), Request{Model: "gpt-4o-mini"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || *statusErr != (StatusError{Code: 429, Message: "Rate limit reached", RetryAfter: 1500 * time.Millisecond}) {
+		t.Errorf("Generate = %v, want the rate limit", err)
+	}
+	var parseErr *ParseError
+	if _, err := o.Embed(context.Background(), "text-embedding-3-small", "Hund"); !errors.As(err, &parseErr) {
+		t.Errorf("Embed = %v, want a *ParseError", err)
+	}
+}
+
+func TestParseOpenAIModels(t *testing.T) {
+	models, err := parseOpenAIModels([]byte(`{"data": [{"id": "gpt-4o", "created": 1715367049}, {"id": "local"}]}`))
+	if err != nil || len(models) != 2 || models[0].Name != "gpt-4o" || models[0].Modified.Unix() != 1715367049 || !models[1].Modified.IsZero() {
+		t.Errorf("parseOpenAIModels = %+v, %v", models, err)
+	}
+}
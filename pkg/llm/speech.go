@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Synthesizer reads texts aloud.
+type Synthesizer interface {
+	// Synthesize returns the audio of text being read, in the format named
+	// by Extension.
+	Synthesize(ctx context.Context, text string) ([]byte, error)
+	// Extension is the file extension of the audio, such as ".mp3".
+	Extension() string
+}
+
+// OpenAISpeech reads texts aloud with the speech endpoint of OpenAI and
+// OpenAI-compatible servers.
+type OpenAISpeech struct {
+	// BaseURL is the root of the API, such as https://api.openai.com/v1.
+	BaseURL string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	Model  string
+	Voice  string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewOpenAISpeech returns the synthesizer of the API at baseURL, OpenAI's by
+// default, reading with voice, alloy by default, and model, tts-1 by
+// default.
+func NewOpenAISpeech(baseURL, apiKey, model, voice string, log Logger) *OpenAISpeech {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	if model == "" {
+		model = "tts-1"
+	}
+	if voice == "" {
+		voice = "alloy"
+	}
+	return &OpenAISpeech{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, Model: model, Voice: voice, Log: log}
+}
+
+type openAISpeechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+// Synthesize returns the MP3 audio of text read by the voice.
+func (s *OpenAISpeech) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	payloadBytes, err := json.Marshal(openAISpeechRequest{Model: s.Model, Input: text, Voice: s.Voice, ResponseFormat: "mp3"})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	header := http.Header{}
+	if s.APIKey != "" {
+		header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	return httpRequest{
+		client:   s.Client,
+		log:      s.Log,
+		endpoint: s.BaseURL + "/audio/speech",
+		header:   header,
+		body:     payloadBytes,
+		binary:   true,
+		failure:  openAIStatusError,
+	}.do(ctx)
+}
+
+// Extension returns ".mp3".
+func (s *OpenAISpeech) Extension() string { return ".mp3" }
+
+// Piper reads texts aloud with the HTTP server of Piper, a local neural
+// text-to-speech engine.
+type Piper struct {
+	// Endpoint is the URL of the server, such as http://localhost:5000.
+	Endpoint string
+	// Voice is the voice the server reads with, such as de_DE-thorsten-high;
+	// empty uses the voice it was started with.
+	Voice string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewPiper returns the synthesizer of the Piper server at endpoint,
+// http://localhost:5000 by default.
+func NewPiper(endpoint, voice string, log Logger) *Piper {
+	if endpoint == "" {
+		endpoint = "http://localhost:5000"
+	}
+	return &Piper{Endpoint: strings.TrimSuffix(endpoint, "/"), Voice: voice, Log: log}
+}
+
+type piperRequest struct {
+	Text  string `json:"text"`
+	Voice string `json:"voice,omitempty"`
+}
+
+// Synthesize returns the WAV audio of text read by the voice.
+func (p *Piper) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	payloadBytes, err := json.Marshal(piperRequest{Text: text, Voice: p.Voice})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request payload: %w", err)
+	}
+	return httpRequest{
+		client:   p.Client,
+		log:      p.Log,
+		endpoint: p.Endpoint,
+		body:     payloadBytes,
+		binary:   true,
+		failure: func(resp *http.Response, body []byte) error {
+			return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(body)), RetryAfter: retryAfter(resp.Header)}
+		},
+	}.do(ctx)
+}
+
+// Extension returns ".wav".
+func (p *Piper) Extension() string { return ".wav" }
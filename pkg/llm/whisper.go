@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Whisper transcribes audio with a Whisper model: through the transcription
+// endpoint of OpenAI and OpenAI-compatible servers, such as faster-whisper
+// servers, or the inference endpoint of a whisper.cpp server, which take the
+// same form.
+type Whisper struct {
+	// Endpoint is the URL the audio is posted to, such as
+	// https://api.openai.com/v1/audio/transcriptions or
+	// http://localhost:8080/inference.
+	Endpoint string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	// Model is the name of the model, which whisper.cpp servers ignore.
+	Model string
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Log receives the request diagnostics; nil discards them.
+	Log Logger
+}
+
+// NewWhisper returns the transcriber posting to endpoint, or to the
+// transcription endpoint under it when it is the root of an OpenAI API,
+// ending with /v1.
+func NewWhisper(endpoint, apiKey, model string, log Logger) *Whisper {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1") {
+		endpoint += "/audio/transcriptions"
+	}
+	return &Whisper{Endpoint: endpoint, APIKey: apiKey, Model: model, Log: log}
+}
+
+// Transcribe returns the text spoken in audio, read from the file name. The
+// language, a BCP 47 tag, helps the model when it is known; it is detected
+// otherwise.
+func (w *Whisper) Transcribe(ctx context.Context, name string, audio []byte, language string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return "", fmt.Errorf("creating request payload: %w", err)
+	}
+	if _, err := file.Write(audio); err != nil {
+		return "", fmt.Errorf("creating request payload: %w", err)
+	}
+	fields := [][2]string{{"response_format", "json"}, {"temperature", "0"}}
+	if w.Model != "" {
+		fields = append(fields, [2]string{"model", w.Model})
+	}
+	// Whisper takes ISO 639-1 codes, without the region.
+	if primary, _, _ := strings.Cut(language, "-"); primary != "" {
+		fields = append(fields, [2]string{"language", strings.ToLower(primary)})
+	}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return "", fmt.Errorf("creating request payload: %w", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("creating request payload: %w", err)
+	}
+
+	header := http.Header{}
+	if w.APIKey != "" {
+		header.Set("Authorization", "Bearer "+w.APIKey)
+	}
+	response, err := httpRequest{
+		client:      w.Client,
+		log:         w.Log,
+		endpoint:    w.Endpoint,
+		header:      header,
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+		failure:     whisperStatusError,
+	}.do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var transcription struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(response, &transcription); err != nil {
+		return "", &ParseError{fmt.Errorf("parsing JSON response: %w", err)}
+	}
+	return strings.TrimSpace(transcription.Text), nil
+}
+
+// whisperStatusError describes a failed response with the message of the
+// error object of OpenAI APIs, or the error string of whisper.cpp servers.
+func whisperStatusError(resp *http.Response, body []byte) error {
+	statusErr := &StatusError{Code: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var errResponse struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &errResponse) == nil && errResponse.Error != nil {
+		var object struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(errResponse.Error, &statusErr.Message) != nil && json.Unmarshal(errResponse.Error, &object) == nil {
+			statusErr.Message = object.Message
+		}
+	}
+	return statusErr
+}